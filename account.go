@@ -1,6 +1,7 @@
 package staticbackend
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -444,7 +445,10 @@ func (a *accounts) addUser(w http.ResponseWriter, r *http.Request) {
 
 		mship := backend.Membership(conf)
 		_, _, err = mship.CreateUser(auth.AccountID, data.Email, data.Password, 0)
-		if err != nil {
+		if errors.Is(err, backend.ErrWeakPassword) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} else if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -480,7 +484,8 @@ func (a *accounts) deleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := backend.DB.RemoveUser(auth, conf.Name, id); err != nil {
+	mship := backend.Membership(conf)
+	if err := mship.RemoveUser(auth, id); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -0,0 +1,83 @@
+package staticbackend
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/staticbackendhq/core/backend"
+	"github.com/staticbackendhq/core/logger"
+	"github.com/staticbackendhq/core/middleware"
+	"github.com/staticbackendhq/core/model"
+)
+
+type apiKeys struct {
+	log *logger.Logger
+}
+
+func (k *apiKeys) list(w http.ResponseWriter, r *http.Request) {
+	conf, _, err := middleware.Extract(r, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	keys, err := backend.DB.ListAPIKeys(conf.Name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if keys == nil {
+		keys = make([]model.APIKey, 0)
+	}
+
+	respond(w, http.StatusOK, keys)
+}
+
+func (k *apiKeys) create(w http.ResponseWriter, r *http.Request) {
+	conf, auth, err := middleware.Extract(r, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var data = new(struct {
+		Name    string     `json:"name"`
+		Scopes  []string   `json:"scopes"`
+		Expires *time.Time `json:"expires"`
+	})
+	if err := parseBody(r.Body, &data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mship := backend.Membership(conf)
+	key, apiKey, err := mship.CreateAPIKey(auth, data.Name, data.Scopes, data.Expires)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the key is only returned at creation
+	respond(w, http.StatusCreated, struct {
+		Key    string       `json:"key"`
+		APIKey model.APIKey `json:"apiKey"`
+	}{key, apiKey})
+}
+
+func (k *apiKeys) revoke(w http.ResponseWriter, r *http.Request) {
+	conf, _, err := middleware.Extract(r, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := getURLPart(r.URL.Path, 3)
+
+	if err := backend.DB.RevokeAPIKey(conf.Name, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respond(w, http.StatusOK, true)
+}
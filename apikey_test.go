@@ -0,0 +1,112 @@
+package staticbackend
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/staticbackendhq/core/backend"
+	"github.com/staticbackendhq/core/model"
+)
+
+func TestAPIKeys(t *testing.T) {
+	keys := &apiKeys{log: backend.Log}
+
+	data := map[string]any{
+		"name":   "reporting service",
+		"scopes": []string{"read:apikeytasks"},
+	}
+	resp := dbReq(t, keys.create, "POST", "/apikeys/create", data, true)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+
+	var created struct {
+		Key    string       `json:"key"`
+		APIKey model.APIKey `json:"apiKey"`
+	}
+	if err := parseBody(resp.Body, &created); err != nil {
+		t.Fatal(err)
+	}
+
+	resp = dbReq(t, db.add, "POST", "/db/apikeytasks", Task{Title: "by admin"})
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+
+	resp = tokenReq(t, db.dbreq, "GET", "/db/apikeytasks", created.Key, nil)
+	defer resp.Body.Close()
+
+	var result model.PagedResult
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	} else if err := parseBody(resp.Body, &result); err != nil {
+		t.Fatal(err)
+	} else if result.Total != 1 {
+		t.Errorf("expected 1 document got %d", result.Total)
+	}
+
+	t.Run("out of scope", func(t *testing.T) {
+		resp := tokenReq(t, db.dbreq, "POST", "/db/apikeytasks", created.Key, Task{Title: "by key"})
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("expected status 403 for a write got %s", resp.Status)
+		}
+
+		resp = tokenReq(t, db.dbreq, "GET", "/db/othertasks", created.Key, nil)
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("expected status 403 for another collection got %s", resp.Status)
+		}
+	})
+
+	t.Run("last used", func(t *testing.T) {
+		key, err := backend.DB.GetAPIKey(dbName, created.APIKey.ID)
+		if err != nil {
+			t.Fatal(err)
+		} else if key.LastUsed == nil || time.Since(*key.LastUsed) > time.Minute {
+			t.Errorf("expected the key to be used got %v", key.LastUsed)
+		}
+	})
+
+	t.Run("invalid secret", func(t *testing.T) {
+		resp := tokenReq(t, db.dbreq, "GET", "/db/apikeytasks", created.Key+"x", nil)
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status 401 got %s", resp.Status)
+		}
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		resp := dbReq(t, keys.revoke, "GET", "/apikeys/revoke/"+created.APIKey.ID, nil, true)
+		defer resp.Body.Close()
+
+		if resp.StatusCode > 299 {
+			t.Fatal(GetResponseBody(t, resp))
+		}
+
+		resp = tokenReq(t, db.dbreq, "GET", "/db/apikeytasks", created.Key, nil)
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status 401 got %s", resp.Status)
+		}
+	})
+
+	t.Run("invalid scopes", func(t *testing.T) {
+		data := map[string]any{"name": "invalid", "scopes": []string{"delete:apikeytasks"}}
+		resp := dbReq(t, keys.create, "POST", "/apikeys/create", data, true)
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status 400 got %s", resp.Status)
+		}
+	})
+}
@@ -0,0 +1,47 @@
+package backend
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// CreateAPIKey creates a scoped API key acting as the authenticated user.
+// The returned key is only available at creation, its secret is not stored.
+func (u User) CreateAPIKey(auth model.Auth, name string, scopes []string, expires *time.Time) (string, model.APIKey, error) {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 {
+		return "", model.APIKey{}, errors.New("the API key name is required")
+	} else if err := database.ValidateScopes(scopes); err != nil {
+		return "", model.APIKey{}, err
+	} else if expires != nil && expires.Before(time.Now()) {
+		return "", model.APIKey{}, errors.New("the API key expiry must be in the future")
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return "", model.APIKey{}, err
+	}
+
+	k := model.APIKey{
+		ID:        DB.NewID(),
+		Name:      name,
+		AccountID: auth.AccountID,
+		UserID:    auth.UserID,
+		Email:     auth.Email,
+		Scopes:    scopes,
+		Expires:   expires,
+		Created:   time.Now(),
+	}
+
+	key, hash := database.NewAPIKey(k.ID, secret)
+	k.KeyHash = hash
+
+	if err := DB.CreateAPIKey(u.conf.Name, k); err != nil {
+		return "", model.APIKey{}, err
+	}
+	return key, k, nil
+}
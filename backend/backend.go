@@ -213,6 +213,9 @@ func Setup(cfg config.AppConfig) {
 		DB = postgresql.New(cl, Cache.PublishDocument, Log)
 	}
 
+	Cache.SetRulesFinder(findReadRule)
+	Cache.SetRetentionFinder(findChannelRetention)
+
 	mp := cfg.MailProvider
 	if strings.EqualFold(mp, email.MailProviderSES) {
 		Emailer = email.AWSSES{}
@@ -0,0 +1,129 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/function"
+	"github.com/staticbackendhq/core/model"
+)
+
+// channelRulesKey caches the channel rules of a database, they're looked up
+// each time a connection joins or writes to a channel
+func channelRulesKey(dbName string) string {
+	return "channel-rules:" + dbName
+}
+
+// SetChannelRules replaces the authorization rules of the realtime channels
+// of a database
+func SetChannelRules(dbName string, rules []model.ChannelRule) error {
+	if err := database.ValidateChannelRules(rules); err != nil {
+		return err
+	}
+
+	settings, err := DB.GetSettings(dbName)
+	if err != nil {
+		return err
+	}
+
+	settings.ChannelRules = rules
+	if err := DB.SaveSettings(dbName, settings); err != nil {
+		return err
+	}
+
+	return Cache.SetTyped(channelRulesKey(dbName), rules)
+}
+
+// channelRules returns the channel rules of a database from the cache and
+// falls back to the database settings
+func channelRules(dbName string) ([]model.ChannelRule, error) {
+	var rules []model.ChannelRule
+	if err := Cache.GetTyped(channelRulesKey(dbName), &rules); err == nil {
+		return rules, nil
+	}
+
+	settings, err := DB.GetSettings(dbName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Cache.SetTyped(channelRulesKey(dbName), settings.ChannelRules); err != nil {
+		return nil, err
+	}
+	return settings.ChannelRules, nil
+}
+
+// AuthorizeChannel returns database.ErrChannelDenied if the caller is not
+// allowed to join (read) or write to a channel. Channels without a matching
+// rule are open. The rule's join function, if any, must also approve the
+// joins, it's denied when the function does not complete in time.
+func AuthorizeChannel(dbName, channel string, auth model.Auth, write bool) error {
+	rules, err := channelRules(dbName)
+	if err != nil {
+		return err
+	}
+
+	rule, params, ok := database.MatchChannel(rules, channel)
+	if !ok {
+		return nil
+	}
+
+	if len(auth.UserID) > 0 {
+		auth, err = database.WithRoleName(DB, dbName, auth)
+		if err != nil {
+			return err
+		}
+
+		auth, err = database.WithTeams(DB, dbName, auth)
+		if err != nil {
+			return err
+		}
+	}
+
+	allowed, err := database.CanAccessChannel(rule, params, write, auth)
+	if err != nil {
+		return err
+	} else if !allowed {
+		return database.ErrChannelDenied
+	}
+
+	if write || len(rule.JoinFunction) == 0 {
+		return nil
+	}
+
+	fn, err := DB.GetFunctionForExecution(dbName, rule.JoinFunction)
+	if err != nil {
+		return fmt.Errorf("unable to find the join function %s: %w", rule.JoinFunction, err)
+	}
+
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	env := &function.ExecutionEnvironment{
+		Auth:      auth,
+		BaseName:  dbName,
+		DataStore: DB,
+		Search:    Search,
+		Volatile:  Cache,
+		Data:      fn,
+		Email:     Emailer,
+		Log:       Log,
+	}
+
+	msg := model.Command{Type: model.MsgTypeJoin, Channel: channel, Data: string(b)}
+	approved, err := env.Approve(msg)
+	if errors.Is(err, function.ErrApproveTimeout) {
+		// a function that never returns denies the joins
+		Log.Warn().Msgf("the join function %s did not complete in time", rule.JoinFunction)
+		return database.ErrChannelDenied
+	} else if err != nil {
+		return fmt.Errorf("error executing the join function %s: %w", rule.JoinFunction, err)
+	} else if !approved {
+		return database.ErrChannelDenied
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 
+	"github.com/staticbackendhq/core/database"
 	"github.com/staticbackendhq/core/model"
 )
 
@@ -12,6 +13,10 @@ type Database[T any] struct {
 	auth model.Auth
 	conf model.DatabaseConfig
 	col  string
+	// tx is set when the operations run in a transaction
+	tx database.Persister
+	// expand are the references resolved by List, Query and GetByID
+	expand []string
 }
 
 // Collection returns a ready to use Database to perform DB operations on a
@@ -28,6 +33,64 @@ func Collection[T any](auth model.Auth, base model.DatabaseConfig, col string) D
 	}
 }
 
+// WithTransaction runs fn in a database transaction. Use InTx to perform a
+// Database's operations in the transaction, they are rolled back if fn
+// returns an error.
+//
+//    err := backend.WithTransaction(func(tx database.Persister) error {
+//      if _, err := orders.InTx(tx).Create(order); err != nil {
+//        return err
+//      }
+//      return stock.InTx(tx).IncrementValue(productID, "qty", -1)
+//    })
+func WithTransaction(fn func(tx database.Persister) error) error {
+	return DB.WithTransaction(fn)
+}
+
+// InTx returns a Database performing its operations in the transaction
+func (d Database[T]) InTx(tx database.Persister) Database[T] {
+	d.tx = tx
+	return d
+}
+
+// Expand returns a Database resolving references in List, Query and GetByID.
+// Each expansion is in the "field:collection" format, the field holding a
+// document ID or a list of IDs is replaced by the referenced documents the
+// user can read. T must be able to receive the documents in those fields.
+//
+//    type Post struct {
+//      ID     string `json:"id"`
+//      Author User   `json:"author"`
+//    }
+//
+//    post, err := backend.Collection[Post](auth, base, "posts").
+//      Expand("author:users").
+//      GetByID(id)
+func (d Database[T]) Expand(expansions ...string) Database[T] {
+	d.expand = append(append([]string{}, d.expand...), expansions...)
+	return d
+}
+
+// resolve expands the references of the documents
+func (d Database[T]) resolve(docs []map[string]any) error {
+	if len(d.expand) == 0 {
+		return nil
+	}
+
+	expansions, err := database.ParseExpand(d.expand)
+	if err != nil {
+		return err
+	}
+	return database.Expand(d.db(), d.auth, d.conf.Name, docs, expansions)
+}
+
+func (d Database[T]) db() database.Persister {
+	if d.tx != nil {
+		return d.tx
+	}
+	return DB
+}
+
 // Create creates a record in the collection/repository
 func (d Database[T]) Create(data T) (inserted T, err error) {
 	doc, err := toDoc(data)
@@ -35,7 +98,7 @@ func (d Database[T]) Create(data T) (inserted T, err error) {
 		return
 	}
 
-	doc, err = DB.CreateDocument(d.auth, d.conf.Name, d.col, doc)
+	doc, err = d.db().CreateDocument(d.auth, d.conf.Name, d.col, doc)
 	if err != nil {
 		return
 	}
@@ -55,7 +118,7 @@ func (d Database[T]) BulkCreate(entities []T) error {
 
 		docs = append(docs, x)
 	}
-	return DB.BulkCreateDocument(d.auth, d.conf.Name, d.col, docs)
+	return d.db().BulkCreateDocument(d.auth, d.conf.Name, d.col, docs)
 }
 
 // PageResult wraps a slice of type T with paging information
@@ -64,13 +127,18 @@ type PagedResult[T any] struct {
 	Size    int64
 	Total   int64
 	Results []T
+	// Cursor is set when the page is full, pass it in the ListParams to
+	// get the next page
+	Cursor string
 }
 
 // List returns records from a collection/repository using paging/sorting params
 func (d Database[T]) List(lp model.ListParams) (res PagedResult[T], err error) {
-	r, err := DB.ListDocuments(d.auth, d.conf.Name, d.col, lp)
+	r, err := d.db().ListDocuments(d.auth, d.conf.Name, d.col, lp)
 	if err != nil {
 		return
+	} else if err = d.resolve(r.Results); err != nil {
+		return
 	}
 
 	for _, doc := range r.Results {
@@ -85,20 +153,23 @@ func (d Database[T]) List(lp model.ListParams) (res PagedResult[T], err error) {
 	res.Page = r.Page
 	res.Size = r.Size
 	res.Total = r.Total
+	res.Cursor = r.Cursor
 
 	return
 }
 
 // Query returns records that match with the provided filters.
 func (d Database[T]) Query(filters [][]any, lp model.ListParams) (res PagedResult[T], err error) {
-	clauses, err := DB.ParseQuery(filters)
+	clauses, err := d.db().ParseQuery(filters)
 	if err != nil {
 		return
 	}
 
-	r, err := DB.QueryDocuments(d.auth, d.conf.Name, d.col, clauses, lp)
+	r, err := d.db().QueryDocuments(d.auth, d.conf.Name, d.col, clauses, lp)
 	if err != nil {
 		return
+	} else if err = d.resolve(r.Results); err != nil {
+		return
 	}
 
 	for _, doc := range r.Results {
@@ -113,15 +184,19 @@ func (d Database[T]) Query(filters [][]any, lp model.ListParams) (res PagedResul
 	res.Page = r.Page
 	res.Size = r.Size
 	res.Total = r.Total
+	res.Cursor = r.Cursor
 
 	return
 }
 
-// GetByID returns a specific record from a collection/repository
-func (d Database[T]) GetByID(id string) (entity T, err error) {
-	doc, err := DB.GetDocumentByID(d.auth, d.conf.Name, d.col, id)
+// GetByID returns a specific record from a collection/repository. The optional
+// fields limit the returned fields, prefix a field with - to exclude it.
+func (d Database[T]) GetByID(id string, fields ...string) (entity T, err error) {
+	doc, err := d.db().GetDocumentByID(d.auth, d.conf.Name, d.col, id, fields...)
 	if err != nil {
 		return
+	} else if err = d.resolve([]map[string]any{doc}); err != nil {
+		return
 	}
 
 	err = fromDoc(doc, &entity)
@@ -135,7 +210,7 @@ func (d Database[T]) Update(id string, v any) (entity T, err error) {
 		return
 	}
 
-	x, err := DB.UpdateDocument(d.auth, d.conf.Name, d.col, id, doc)
+	x, err := d.db().UpdateDocument(d.auth, d.conf.Name, d.col, id, doc)
 	if err != nil {
 		return
 	}
@@ -146,7 +221,7 @@ func (d Database[T]) Update(id string, v any) (entity T, err error) {
 
 // UpdateMany updates multiple records matching filters
 func (d Database[T]) UpdateMany(filters [][]any, v any) (int64, error) {
-	clauses, err := DB.ParseQuery(filters)
+	clauses, err := d.db().ParseQuery(filters)
 	if err != nil {
 		return 0, err
 	}
@@ -155,17 +230,51 @@ func (d Database[T]) UpdateMany(filters [][]any, v any) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
-	return DB.UpdateDocuments(d.auth, d.conf.Name, d.col, clauses, doc)
+	return d.db().UpdateDocuments(d.auth, d.conf.Name, d.col, clauses, doc)
 }
 
 // IncrementValue increments or decrements a specifc field from a collection/repository
 func (d Database[T]) IncrementValue(id, field string, n int) error {
-	return DB.IncrementValue(d.auth, d.conf.Name, d.col, id, field, n)
+	return d.db().IncrementValue(d.auth, d.conf.Name, d.col, id, field, n)
 }
 
 // Delete removes a record from a collection
 func (d Database[T]) Delete(id string) (int64, error) {
-	return DB.DeleteDocument(d.auth, d.conf.Name, d.col, id)
+	return d.db().DeleteDocument(d.auth, d.conf.Name, d.col, id)
+}
+
+// Aggregate computes the metrics for each group of records in the collection
+// matching the filters. Each row has the group by fields and the metric names
+// as keys and is decoded into R.
+//
+//    type Sales struct {
+//      Status string  `json:"status"`
+//      Total  float64 `json:"total"`
+//    }
+//
+//    backend.Aggregate[Sales](auth, base, "orders", nil, []string{"status"},
+//      database.Metric{Name: "total", Op: database.MetricSum, Field: "amount"},
+//    )
+func Aggregate[R any](auth model.Auth, base model.DatabaseConfig, col string, filters [][]any, groupBy []string, metrics ...database.Metric) (rows []R, err error) {
+	clauses, err := DB.ParseQuery(filters)
+	if err != nil {
+		return
+	}
+
+	docs, err := DB.Aggregate(auth, base.Name, col, clauses, groupBy, metrics)
+	if err != nil {
+		return
+	}
+
+	for _, doc := range docs {
+		var v R
+		if err = fromDoc(doc, &v); err != nil {
+			return
+		}
+
+		rows = append(rows, v)
+	}
+	return
 }
 
 func toDoc(v any) (doc map[string]any, err error) {
@@ -199,14 +308,31 @@ func fromDoc(doc map[string]any, v interface{}) error {
 //
 // This would filter for the false value in the "done" field.
 //
-// Supported operators: =, !=, >, <, >=, <=, in, !in
+// Nested groups created with Or, And and Not can be mixed with the criteria:
+//
+//    backend.BuildQueryFilters(
+//      "done", "=", false,
+//      backend.Or(
+//        [][]any{{"likes", ">", 10}},
+//        [][]any{{"tags", "in", "hot"}},
+//      ),
+//    )
+//
+// Supported operators: =, !=, >, <, >=, <=, in, !in, contains, startsWith,
+// ieq (case-insensitive equal), regex, exists, !exists, between (a list of
+// lower and upper bounds), containsAny, containsAll and size
 func BuildQueryFilters(p ...any) (q [][]any, err error) {
-	if len(p)%3 != 0 {
-		err = errors.New("parameters should all have 3 values for each criteria")
-		return
-	}
-
 	for i := 0; i < len(p); i++ {
+		if g, ok := p[i].(QueryGroup); ok {
+			q = append(q, []any(g))
+			continue
+		}
+
+		if i+2 >= len(p) {
+			err = errors.New("parameters should all have 3 values for each criteria")
+			return
+		}
+
 		q = append(q, []any{
 			p[i], p[i+1], p[i+2],
 		})
@@ -216,3 +342,35 @@ func BuildQueryFilters(p ...any) (q [][]any, err error) {
 
 	return
 }
+
+// QueryGroup is a nested group of filters created by Or, And and Not
+type QueryGroup []any
+
+// Or returns a group matching if any of the filters match. Each filters
+// is the result of BuildQueryFilters and its criteria are ANDed.
+func Or(filters ...[][]any) QueryGroup {
+	return newQueryGroup("or", filters)
+}
+
+// And returns a group matching if all of the filters match
+func And(filters ...[][]any) QueryGroup {
+	return newQueryGroup("and", filters)
+}
+
+// Not returns a group matching if the filters do not all match
+func Not(filters ...[][]any) QueryGroup {
+	return newQueryGroup("not", filters)
+}
+
+func newQueryGroup(group string, filters [][][]any) QueryGroup {
+	var clauses [][]any
+	for _, f := range filters {
+		if len(f) == 1 {
+			clauses = append(clauses, f[0])
+			continue
+		}
+
+		clauses = append(clauses, []any{"and", f})
+	}
+	return QueryGroup{group, clauses}
+}
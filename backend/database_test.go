@@ -1,10 +1,12 @@
 package backend_test
 
 import (
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/staticbackendhq/core/backend"
+	"github.com/staticbackendhq/core/database"
 	"github.com/staticbackendhq/core/model"
 )
 
@@ -137,3 +139,178 @@ func TestDatabaseBuildQueryFilters(t *testing.T) {
 		t.Error("filters are not properly created")
 	}
 }
+
+func TestDatabaseQueryGroups(t *testing.T) {
+	db := backend.Collection[Task](adminAuth, base, "tasks")
+
+	tasks := []Task{
+		newTask("grp1", false),
+		newTask("grp2", true),
+		newTask("grp3", false),
+	}
+
+	if err := db.BulkCreate(tasks); err != nil {
+		t.Fatal(err)
+	}
+
+	grp1, err := backend.BuildQueryFilters("title", "=", "grp1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grp2, err := backend.BuildQueryFilters("title", "=", "grp2", "done", "=", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filters, err := backend.BuildQueryFilters(backend.Or(grp1, grp2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lp := model.ListParams{Page: 1, Size: 50}
+	res, err := db.Query(filters, lp)
+	if err != nil {
+		t.Fatal(err)
+	} else if res.Total != 2 {
+		t.Errorf("expected total to be 2 got %d", res.Total)
+	}
+}
+
+func TestDatabaseListCursor(t *testing.T) {
+	db := backend.Collection[Task](adminAuth, base, "cursortasks")
+
+	tasks := []Task{
+		newTask("c1", false),
+		newTask("c2", false),
+		newTask("c3", false),
+	}
+
+	if err := db.BulkCreate(tasks); err != nil {
+		t.Fatal(err)
+	}
+
+	lp := model.ListParams{Page: 1, Size: 2}
+	first, err := db.List(lp)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(first.Cursor) == 0 {
+		t.Fatal("expected a cursor for a full page")
+	}
+
+	lp.Cursor = first.Cursor
+	next, err := db.List(lp)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(next.Results) != 1 || next.Results[0].Title != "c3" {
+		t.Errorf("expected the last task got %v", next.Results)
+	} else if len(next.Cursor) > 0 {
+		t.Error("expected no cursor after the last page")
+	}
+}
+
+func TestDatabaseAggregate(t *testing.T) {
+	db := backend.Collection[Task](adminAuth, base, "aggtasks")
+
+	tasks := []Task{
+		newTask("agg a", true),
+		newTask("agg b", true),
+		newTask("agg c", false),
+	}
+	if err := db.BulkCreate(tasks); err != nil {
+		t.Fatal(err)
+	}
+
+	type Stats struct {
+		Done  bool  `json:"done"`
+		Count int64 `json:"count"`
+	}
+
+	rows, err := backend.Aggregate[Stats](adminAuth, base, "aggtasks", nil, []string{"done"},
+		database.Metric{Name: "count", Op: database.MetricCount},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Stats{{Done: false, Count: 1}, {Done: true, Count: 2}}
+	if len(rows) != len(expected) || rows[0] != expected[0] || rows[1] != expected[1] {
+		t.Errorf("expected %v got %v", expected, rows)
+	}
+}
+
+func TestDatabaseTransaction(t *testing.T) {
+	db := backend.Collection[Task](adminAuth, base, "txtasks")
+
+	if _, err := db.Create(newTask("tx before", false)); err != nil {
+		t.Fatal(err)
+	}
+
+	errAbort := errors.New("abort")
+
+	err := backend.WithTransaction(func(tx database.Persister) error {
+		if _, err := db.InTx(tx).Create(newTask("tx rolled back", false)); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("expected the abort error got %v", err)
+	}
+
+	err = backend.WithTransaction(func(tx database.Persister) error {
+		_, err := db.InTx(tx).Create(newTask("tx committed", true))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := db.List(model.ListParams{Page: 1, Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var titles []string
+	for _, task := range res.Results {
+		titles = append(titles, task.Title)
+	}
+
+	if len(titles) != 2 || titles[0] != "tx before" || titles[1] != "tx committed" {
+		t.Errorf("expected the committed tasks only got %v", titles)
+	}
+}
+
+func TestDatabaseExpand(t *testing.T) {
+	type Project struct {
+		ID    string `json:"id"`
+		Name  string `json:"name"`
+		Owner any    `json:"owner"`
+	}
+
+	owner, err := backend.Collection[Task](adminAuth, base, "expandowners").Create(newTask("owner", false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := backend.Collection[Project](adminAuth, base, "expandprojects")
+
+	project, err := db.Create(Project{Name: "expanded", Owner: owner.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check, err := db.Expand("owner:expandowners").GetByID(project.ID)
+	if err != nil {
+		t.Fatal(err)
+	} else if doc, ok := check.Owner.(map[string]any); !ok || doc["title"] != "owner" {
+		t.Errorf("expected the owner to be expanded got %v", check.Owner)
+	}
+
+	// expansions are not kept on the collection
+	if check, err = db.GetByID(project.ID); err != nil {
+		t.Fatal(err)
+	} else if check.Owner != owner.ID {
+		t.Errorf("expected the owner ID got %v", check.Owner)
+	}
+}
@@ -0,0 +1,195 @@
+package backend
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+// IdentityLinkTTL is how long a user has to complete the OAuth flow when
+// linking a provider to their account
+const IdentityLinkTTL = 10 * time.Minute
+
+var (
+	// ErrIdentityNotLinked is returned when signing in with a provider that
+	// did not verify the email of an existing user, the user needs to sign in
+	// and link the provider from their account
+	ErrIdentityNotLinked = errors.New("an account already exists with this email, sign in and link this provider to it")
+	// ErrIdentityInUse is returned when linking an identity that's linked to
+	// another user
+	ErrIdentityInUse = errors.New("this identity is linked to another user")
+	// ErrInvalidIdentityLink is returned when the link request is unknown or
+	// expired
+	ErrInvalidIdentityLink = errors.New("invalid or expired identity link request")
+)
+
+type identityLink struct {
+	DBName    string    `json:"dbName"`
+	AccountID string    `json:"accountId"`
+	UserID    string    `json:"userId"`
+	Email     string    `json:"email"`
+	Expires   time.Time `json:"expires"`
+}
+
+// SignInWithIdentity signs in the user linked to an external login identity.
+// The user is created on their first sign in. An existing user having the
+// identity's email is linked only when the provider verified the email,
+// otherwise ErrIdentityNotLinked is returned.
+func (u User) SignInWithIdentity(ident model.Identity, emailVerified bool) (model.Tokens, error) {
+	ident.Email = strings.ToLower(ident.Email)
+
+	linked, err := DB.FindIdentity(u.conf.Name, ident.Provider, ident.Subject)
+	if err != nil {
+		return model.Tokens{}, err
+	}
+
+	if len(linked.ID) > 0 {
+		tok, err := DB.GetUserByID(u.conf.Name, linked.AccountID, linked.UserID)
+		if err != nil {
+			return model.Tokens{}, err
+		}
+		return u.SignIn(tok)
+	}
+
+	exists, err := DB.UserEmailExists(u.conf.Name, ident.Email)
+	if err != nil {
+		return model.Tokens{}, err
+	}
+
+	if exists {
+		if !emailVerified {
+			return model.Tokens{}, ErrIdentityNotLinked
+		}
+
+		tok, err := DB.FindUserByEmail(u.conf.Name, ident.Email)
+		if err != nil {
+			return model.Tokens{}, err
+		}
+
+		if err := u.addIdentity(tok, ident); err != nil {
+			return model.Tokens{}, err
+		}
+
+		if !tok.EmailVerified {
+			if err := u.setEmailVerified(tok); err != nil {
+				return model.Tokens{}, err
+			}
+			tok.EmailVerified = true
+		}
+		return u.SignIn(tok)
+	}
+
+	// the user has no password, they can set one by resetting it
+	pw, err := newSecret()
+	if err != nil {
+		return model.Tokens{}, err
+	}
+
+	tokens, tok, err := u.register(ident.Email, pw, 0, emailVerified)
+	if err != nil {
+		return model.Tokens{}, err
+	}
+
+	if err := u.addIdentity(tok, ident); err != nil {
+		return model.Tokens{}, err
+	}
+	return tokens, nil
+}
+
+func (u User) addIdentity(tok model.User, ident model.Identity) error {
+	ident.AccountID = tok.AccountID
+	ident.UserID = tok.ID
+
+	_, err := DB.AddIdentity(u.conf.Name, ident)
+	return err
+}
+
+// NewIdentityLink returns the request ID to use with the OAuth flow to link a
+// provider to the user's account
+func (u User) NewIdentityLink(auth model.Auth) (reqID string, err error) {
+	reqID, err = newSecret()
+	if err != nil {
+		return
+	}
+
+	link := identityLink{
+		DBName:    u.conf.Name,
+		AccountID: auth.AccountID,
+		UserID:    auth.UserID,
+		Email:     auth.Email,
+		Expires:   time.Now().Add(IdentityLinkTTL),
+	}
+	err = Cache.SetTyped("identity-link-"+reqID, link)
+	return
+}
+
+// IsIdentityLink returns true if the OAuth request was started with
+// NewIdentityLink
+func IsIdentityLink(reqID string) bool {
+	var link identityLink
+	if err := Cache.GetTyped("identity-link-"+reqID, &link); err != nil {
+		return false
+	}
+	return link.Expires.After(time.Now())
+}
+
+// LinkIdentity links an external login identity to the user who requested
+// the link, a link request can only be used once
+func (u User) LinkIdentity(reqID string, ident model.Identity) (model.Identity, error) {
+	var link identityLink
+	if err := Cache.GetTyped("identity-link-"+reqID, &link); err != nil {
+		return model.Identity{}, ErrInvalidIdentityLink
+	} else if link.Expires.Before(time.Now()) || link.DBName != u.conf.Name {
+		return model.Identity{}, ErrInvalidIdentityLink
+	}
+
+	// the cache has no delete, the request is expired instead
+	used := identityLink{Expires: time.Time{}}
+	if err := Cache.SetTyped("identity-link-"+reqID, used); err != nil {
+		return model.Identity{}, err
+	}
+
+	linked, err := DB.FindIdentity(u.conf.Name, ident.Provider, ident.Subject)
+	if err != nil {
+		return model.Identity{}, err
+	} else if len(linked.ID) > 0 {
+		if linked.UserID != link.UserID {
+			return model.Identity{}, ErrIdentityInUse
+		}
+		return linked, nil
+	}
+
+	ident.AccountID = link.AccountID
+	ident.UserID = link.UserID
+	ident.Email = strings.ToLower(ident.Email)
+
+	id, err := DB.AddIdentity(u.conf.Name, ident)
+	if err != nil {
+		return model.Identity{}, err
+	}
+
+	ident.ID = id
+	return ident, nil
+}
+
+// ListIdentities returns the identities linked to the user
+func (u User) ListIdentities(auth model.Auth) ([]model.Identity, error) {
+	list, err := DB.ListIdentities(u.conf.Name, auth.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if list == nil {
+		list = make([]model.Identity, 0)
+	}
+	return list, nil
+}
+
+// UnlinkIdentity removes an identity from the user's account. Users signing
+// in only with external logins can still use a magic link or reset their
+// password.
+func (u User) UnlinkIdentity(auth model.Auth, id string) error {
+	return DB.DeleteIdentity(u.conf.Name, auth.UserID, id)
+}
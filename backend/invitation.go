@@ -0,0 +1,308 @@
+package backend
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/email"
+	"github.com/staticbackendhq/core/model"
+)
+
+const (
+	// InvitationTTL is how long an invitation can be accepted
+	InvitationTTL = 7 * 24 * time.Hour
+
+	// the account creators have this role (see Register), they can invite
+	// users with a role up to theirs
+	accountOwnerRole = 50
+)
+
+var (
+	// ErrInvalidInvitation is returned when accepting an unknown, revoked or
+	// expired invitation
+	ErrInvalidInvitation = errors.New("invalid or expired invitation")
+	// ErrInvitationNotAllowed is returned when the caller cannot invite users
+	// with the role
+	ErrInvitationNotAllowed = errors.New("you cannot invite users with this role")
+)
+
+// InvitationData is the invitation emailed to an invitee. The Body is the
+// email's HTML where [link] is replaced by the Link, the token is appended to
+// it as ?token=. The Link is the application's page accepting the invitation.
+type InvitationData struct {
+	Email     string `json:"email"`
+	Role      int    `json:"role"`
+	FromEmail string `json:"fromEmail"`
+	FromName  string `json:"fromName"`
+	Subject   string `json:"subject"`
+	Body      string `json:"body"`
+	Link      string `json:"link"`
+}
+
+type invitationLink struct {
+	DBName  string    `json:"dbName"`
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// Invite invites an email to join the caller's account and emails the
+// invitation's token. Only the account owners can invite, with a role up to
+// theirs.
+func (u User) Invite(auth model.Auth, data InvitationData) (model.Invitation, error) {
+	data.Email = strings.ToLower(strings.TrimSpace(data.Email))
+
+	if auth.Role < accountOwnerRole || data.Role < 0 || data.Role > auth.Role {
+		return model.Invitation{}, ErrInvitationNotAllowed
+	} else if !strings.Contains(data.Email, "@") {
+		return model.Invitation{}, errors.New("invalid email")
+	} else if len(data.Link) == 0 {
+		// the invitee chooses their password on the application's page
+		return model.Invitation{}, errors.New("the invitation link is required")
+	}
+
+	exists, err := DB.UserEmailExists(u.conf.Name, data.Email)
+	if err != nil {
+		return model.Invitation{}, err
+	} else if exists {
+		return model.Invitation{}, errors.New("email already in use")
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return model.Invitation{}, err
+	}
+
+	id := DB.NewID()
+	token, hash := database.NewInvitationToken(id, secret)
+
+	inv := model.Invitation{
+		ID:        id,
+		AccountID: auth.AccountID,
+		Email:     data.Email,
+		Role:      data.Role,
+		TokenHash: hash,
+		InvitedBy: auth.UserID,
+		Created:   time.Now(),
+		Expires:   time.Now().Add(InvitationTTL),
+	}
+	if err := DB.CreateInvitation(u.conf.Name, inv); err != nil {
+		return model.Invitation{}, err
+	}
+
+	link := data.Link
+	sep := "?"
+	if strings.Contains(link, "?") {
+		sep = "&"
+	}
+	link += sep + "token=" + url.QueryEscape(token)
+
+	mail := email.SendMailData{
+		From:     data.FromEmail,
+		FromName: data.FromName,
+		To:       inv.Email,
+		Subject:  data.Subject,
+		HTMLBody: strings.Replace(data.Body, "[link]", link, -1),
+	}
+
+	if len(mail.From) == 0 {
+		mail.From = Config.FromEmail
+		mail.FromName = Config.FromName
+	}
+	if len(mail.Subject) == 0 {
+		mail.Subject = "You've been invited"
+	}
+	if len(data.Body) == 0 {
+		mail.HTMLBody = fmt.Sprintf(`<p>%s invited you, accept the invitation by visiting this link:</p><p><a href="%s">%s</a></p>`, auth.Email, link, link)
+	}
+
+	if err := Emailer.Send(mail); err != nil {
+		return model.Invitation{}, err
+	}
+	return inv, nil
+}
+
+// ListInvitations returns the pending invitations of the caller's account
+func (u User) ListInvitations(auth model.Auth) ([]model.Invitation, error) {
+	if auth.Role < accountOwnerRole {
+		return nil, ErrInvitationNotAllowed
+	}
+
+	list, err := DB.ListInvitations(u.conf.Name, auth.AccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	if list == nil {
+		list = make([]model.Invitation, 0)
+	}
+	return list, nil
+}
+
+// RevokeInvitation removes a pending invitation of the caller's account
+func (u User) RevokeInvitation(auth model.Auth, id string) error {
+	if auth.Role < accountOwnerRole {
+		return ErrInvitationNotAllowed
+	}
+	return DB.DeleteInvitation(u.conf.Name, auth.AccountID, id)
+}
+
+// AcceptInvitation creates the invitee's user with a password in the account
+// of the invitation and starts their session, see SignIn for users having
+// two-factor authentication
+func (u User) AcceptInvitation(token, password string) (model.Tokens, model.User, error) {
+	if err := u.checkPasswordPolicy(password); err != nil {
+		return model.Tokens{}, model.User{}, err
+	}
+
+	inv, err := u.useInvitation(token)
+	if err != nil {
+		return model.Tokens{}, model.User{}, err
+	}
+
+	tok, err := u.joinAccount(inv, password)
+	if err != nil {
+		return model.Tokens{}, model.User{}, err
+	}
+
+	tokens, err := u.SignIn(tok)
+	return tokens, tok, err
+}
+
+// NewInvitationLink returns the request ID to use with the OAuth flow to
+// accept an invitation with an external login
+func (u User) NewInvitationLink(token string) (reqID string, err error) {
+	if _, err = u.findInvitation(token); err != nil {
+		return
+	}
+
+	reqID, err = newSecret()
+	if err != nil {
+		return
+	}
+
+	link := invitationLink{
+		DBName:  u.conf.Name,
+		Token:   token,
+		Expires: time.Now().Add(IdentityLinkTTL),
+	}
+	err = Cache.SetTyped("invitation-link-"+reqID, link)
+	return
+}
+
+// IsInvitationLink returns true if the OAuth request was started with
+// NewInvitationLink
+func IsInvitationLink(reqID string) bool {
+	var link invitationLink
+	if err := Cache.GetTyped("invitation-link-"+reqID, &link); err != nil {
+		return false
+	}
+	return link.Expires.After(time.Now())
+}
+
+// AcceptInvitationWithIdentity creates the invitee's user linked to an
+// external login identity and starts their session, a link request can only
+// be used once
+func (u User) AcceptInvitationWithIdentity(reqID string, ident model.Identity) (model.Tokens, error) {
+	var link invitationLink
+	if err := Cache.GetTyped("invitation-link-"+reqID, &link); err != nil {
+		return model.Tokens{}, ErrInvalidInvitation
+	} else if link.Expires.Before(time.Now()) || link.DBName != u.conf.Name {
+		return model.Tokens{}, ErrInvalidInvitation
+	}
+
+	// the cache has no delete, the request is expired instead
+	used := invitationLink{Expires: time.Time{}}
+	if err := Cache.SetTyped("invitation-link-"+reqID, used); err != nil {
+		return model.Tokens{}, err
+	}
+
+	linked, err := DB.FindIdentity(u.conf.Name, ident.Provider, ident.Subject)
+	if err != nil {
+		return model.Tokens{}, err
+	} else if len(linked.ID) > 0 {
+		return model.Tokens{}, ErrIdentityInUse
+	}
+
+	inv, err := u.useInvitation(link.Token)
+	if err != nil {
+		return model.Tokens{}, err
+	}
+
+	// the user has no password, they can set one by resetting it
+	pw, err := newSecret()
+	if err != nil {
+		return model.Tokens{}, err
+	}
+
+	tok, err := u.joinAccount(inv, pw)
+	if err != nil {
+		return model.Tokens{}, err
+	}
+
+	ident.Email = strings.ToLower(ident.Email)
+	if err := u.addIdentity(tok, ident); err != nil {
+		return model.Tokens{}, err
+	}
+	return u.SignIn(tok)
+}
+
+// findInvitation returns the pending invitation of a token
+func (u User) findInvitation(token string) (model.Invitation, error) {
+	id, secret, ok := database.ParseInvitationToken(token)
+	if !ok {
+		return model.Invitation{}, ErrInvalidInvitation
+	}
+
+	inv, err := DB.GetInvitation(u.conf.Name, id)
+	if err != nil || len(inv.ID) == 0 {
+		return model.Invitation{}, ErrInvalidInvitation
+	}
+
+	hash := database.HashAPIKeySecret(secret)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(inv.TokenHash)) != 1 || inv.IsExpired() {
+		return model.Invitation{}, ErrInvalidInvitation
+	}
+	return inv, nil
+}
+
+// useInvitation returns the pending invitation of a token and removes it so
+// it's accepted once
+func (u User) useInvitation(token string) (model.Invitation, error) {
+	inv, err := u.findInvitation(token)
+	if err != nil {
+		return model.Invitation{}, err
+	}
+
+	if err := DB.DeleteInvitation(u.conf.Name, inv.AccountID, inv.ID); err != nil {
+		return model.Invitation{}, err
+	}
+
+	exists, err := DB.UserEmailExists(u.conf.Name, inv.Email)
+	if err != nil {
+		return model.Invitation{}, err
+	} else if exists {
+		return model.Invitation{}, errors.New("email already in use")
+	}
+	return inv, nil
+}
+
+// joinAccount creates the invitee's user, their email is verified since
+// they received the invitation
+func (u User) joinAccount(inv model.Invitation, password string) (model.User, error) {
+	tok, err := u.insertUser(inv.AccountID, inv.Email, password, inv.Role)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	if err := DB.SetEmailVerified(u.conf.Name, tok.ID); err != nil {
+		return model.User{}, err
+	}
+
+	tok.EmailVerified = true
+	return tok, nil
+}
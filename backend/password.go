@@ -0,0 +1,102 @@
+package backend
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+// MaxPasswordLength is the highest minimum length of a password policy,
+// bcrypt ignores what's after 72 bytes
+const MaxPasswordLength = 72
+
+// ErrWeakPassword is returned when a password does not satisfy the password
+// policy of the database
+var ErrWeakPassword = errors.New("the password does not meet the password policy")
+
+//go:embed breached_passwords.txt
+var breachedPasswordList string
+
+var (
+	breachedPasswords     map[string]struct{}
+	breachedPasswordsOnce sync.Once
+)
+
+// ValidatePassword validates a password against a password policy
+func ValidatePassword(policy model.PasswordPolicy, password string) error {
+	if n := utf8.RuneCountInString(password); n < policy.MinLength {
+		return fmt.Errorf("%w, it must have at least %d characters", ErrWeakPassword, policy.MinLength)
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+
+	if policy.RequireLower && !lower {
+		return fmt.Errorf("%w, it must have a lowercase letter", ErrWeakPassword)
+	} else if policy.RequireUpper && !upper {
+		return fmt.Errorf("%w, it must have an uppercase letter", ErrWeakPassword)
+	} else if policy.RequireDigit && !digit {
+		return fmt.Errorf("%w, it must have a digit", ErrWeakPassword)
+	} else if policy.RequireSymbol && !symbol {
+		return fmt.Errorf("%w, it must have a symbol", ErrWeakPassword)
+	} else if policy.RejectBreached && isBreachedPassword(password) {
+		return fmt.Errorf("%w, it is a commonly used password", ErrWeakPassword)
+	}
+	return nil
+}
+
+// isBreachedPassword returns true if the password is in the bundled list of
+// common passwords found in breaches, the comparison ignores the case
+func isBreachedPassword(password string) bool {
+	breachedPasswordsOnce.Do(func() {
+		breachedPasswords = make(map[string]struct{})
+		for _, p := range strings.Fields(breachedPasswordList) {
+			breachedPasswords[strings.ToLower(p)] = struct{}{}
+		}
+	})
+
+	_, ok := breachedPasswords[strings.ToLower(password)]
+	return ok
+}
+
+// SetPasswordPolicy saves the password policy of the database, it applies to
+// the passwords set afterward
+func (u User) SetPasswordPolicy(policy model.PasswordPolicy) error {
+	if policy.MinLength < 0 || policy.MinLength > MaxPasswordLength {
+		return fmt.Errorf("the minimum length must be between 0 and %d", MaxPasswordLength)
+	}
+
+	settings, err := DB.GetSettings(u.conf.Name)
+	if err != nil {
+		return err
+	}
+
+	settings.PasswordPolicy = policy
+	return DB.SaveSettings(u.conf.Name, settings)
+}
+
+// checkPasswordPolicy validates a password against the policy of the database
+func (u User) checkPasswordPolicy(password string) error {
+	settings, err := DB.GetSettings(u.conf.Name)
+	if err != nil {
+		return err
+	}
+	return ValidatePassword(settings.PasswordPolicy, password)
+}
@@ -0,0 +1,49 @@
+package backend_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/staticbackendhq/core/backend"
+	"github.com/staticbackendhq/core/model"
+)
+
+func TestValidatePassword(t *testing.T) {
+	policy := model.PasswordPolicy{
+		MinLength:      10,
+		RequireLower:   true,
+		RequireUpper:   true,
+		RequireDigit:   true,
+		RequireSymbol:  true,
+		RejectBreached: true,
+	}
+
+	tests := []struct {
+		password string
+		valid    bool
+	}{
+		{"Sh0rt!", false},
+		{"NOLOWERCASE1!", false},
+		{"nouppercase1!", false},
+		{"NoDigitsHere!", false},
+		{"NoSymbols123", false},
+		{"Correct-Horse-9", true},
+		{"Ünïcödé-Pass-7", true},
+	}
+
+	for _, tt := range tests {
+		err := backend.ValidatePassword(policy, tt.password)
+		if tt.valid && err != nil {
+			t.Errorf("expected %s to be valid got %v", tt.password, err)
+		} else if !tt.valid && !errors.Is(err, backend.ErrWeakPassword) {
+			t.Errorf("expected %s to be rejected got %v", tt.password, err)
+		}
+	}
+
+	breached := model.PasswordPolicy{RejectBreached: true}
+	if err := backend.ValidatePassword(breached, "Password123"); !errors.Is(err, backend.ErrWeakPassword) {
+		t.Errorf("expected a breached password to be rejected got %v", err)
+	} else if err := backend.ValidatePassword(model.PasswordPolicy{}, "password123"); err != nil {
+		t.Errorf("expected the zero policy to accept any password got %v", err)
+	}
+}
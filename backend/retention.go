@@ -0,0 +1,62 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/staticbackendhq/core/cache"
+	"github.com/staticbackendhq/core/model"
+)
+
+// retentionKey caches the channels' retention of a database, it's looked up
+// each time a message is published
+func retentionKey(dbName string) string {
+	return "retention:" + dbName
+}
+
+// SetChannelRetention replaces the retention of the realtime channels of a
+// database, the messages published afterward are retained
+func SetChannelRetention(dbName string, retention []model.ChannelRetention) error {
+	for _, r := range retention {
+		if len(r.Channel) == 0 {
+			return errors.New("the channel is required")
+		} else if r.MaxMessages < 0 || r.MaxMinutes < 0 {
+			return errors.New("the limits cannot be negative")
+		} else if r.MaxMessages == 0 && r.MaxMinutes == 0 {
+			return fmt.Errorf("the channel %s requires a limit", r.Channel)
+		} else if r.MaxMessages > cache.MaxRetainedMessages {
+			return fmt.Errorf("a channel cannot retain more than %d messages", cache.MaxRetainedMessages)
+		}
+	}
+
+	settings, err := DB.GetSettings(dbName)
+	if err != nil {
+		return err
+	}
+
+	settings.ChannelRetention = retention
+	if err := DB.SaveSettings(dbName, settings); err != nil {
+		return err
+	}
+
+	return Cache.SetTyped(retentionKey(dbName), retention)
+}
+
+// findChannelRetention is the cache.RetentionFinder of the channels
+func findChannelRetention(dbName, channel string) (model.ChannelRetention, bool, error) {
+	var retention []model.ChannelRetention
+	if err := Cache.GetTyped(retentionKey(dbName), &retention); err != nil {
+		settings, err := DB.GetSettings(dbName)
+		if err != nil {
+			return model.ChannelRetention{}, false, err
+		}
+
+		retention = settings.ChannelRetention
+		if err := Cache.SetTyped(retentionKey(dbName), retention); err != nil {
+			return model.ChannelRetention{}, false, err
+		}
+	}
+
+	r, ok := model.FindChannelRetention(retention, channel)
+	return r, ok, nil
+}
@@ -0,0 +1,88 @@
+package backend
+
+import (
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// rulesKey caches the collection rules of a database, they're looked up for
+// each database event sent to the subscribers
+func rulesKey(dbName string) string {
+	return "rules:" + dbName
+}
+
+// SaveCollectionRules validates and replaces the permission rules of a
+// collection
+func SaveCollectionRules(dbName string, rules model.CollectionRules) error {
+	if err := database.ValidateRules(rules.Rules); err != nil {
+		return err
+	} else if err := DB.SaveRules(dbName, rules); err != nil {
+		return err
+	}
+	return refreshRules(dbName)
+}
+
+// DeleteCollectionRules removes the rules of a collection, the permissions
+// from its name apply again
+func DeleteCollectionRules(dbName, col string) error {
+	if err := DB.DeleteRules(dbName, col); err != nil {
+		return err
+	}
+	return refreshRules(dbName)
+}
+
+// refreshRules caches the collection rules of a database
+func refreshRules(dbName string) error {
+	list, err := DB.ListRules(dbName)
+	if err != nil {
+		return err
+	}
+	return Cache.SetTyped(rulesKey(dbName), list)
+}
+
+// collectionRules returns the collection rules of a database from the cache
+// and falls back to the database
+func collectionRules(dbName string) ([]model.CollectionRules, error) {
+	var list []model.CollectionRules
+	if err := Cache.GetTyped(rulesKey(dbName), &list); err == nil {
+		return list, nil
+	}
+
+	list, err := DB.ListRules(dbName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Cache.SetTyped(rulesKey(dbName), list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// findReadRule is the cache.RulesFinder of the database events. The role's
+// name is resolved like database.SecureFilter does since the roles may have
+// changed after the subscriber's session was created.
+func findReadRule(auth model.Auth, dbName, col string) (database.Filter, bool, error) {
+	list, err := collectionRules(dbName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, rules := range list {
+		if rules.Collection != col {
+			continue
+		}
+
+		auth, err = database.WithRoleName(DB, dbName, auth)
+		if err != nil {
+			return nil, false, err
+		}
+
+		auth, err = database.WithTeams(DB, dbName, auth)
+		if err != nil {
+			return nil, false, err
+		}
+		return database.RuleFilter(rules.Rules, false, auth)
+	}
+	return nil, false, nil
+}
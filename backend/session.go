@@ -0,0 +1,213 @@
+package backend
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gbrlsnchs/jwt/v3"
+	"github.com/staticbackendhq/core/cache"
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/internal"
+	"github.com/staticbackendhq/core/model"
+)
+
+const (
+	// AccessTokenTTL is how long an access token is valid
+	AccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL is how long a session lasts without being refreshed
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// ErrInvalidRefreshToken is returned when a refresh token is unknown, expired
+// or was already used
+var ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+// StartSession creates a session for a user and returns its access and
+// refresh tokens
+func (u User) StartSession(tok model.User) (model.Tokens, error) {
+	secret, err := newSecret()
+	if err != nil {
+		return model.Tokens{}, err
+	}
+
+	s := model.Session{
+		ID:        DB.NewID(),
+		AccountID: tok.AccountID,
+		UserID:    tok.ID,
+		Expires:   time.Now().Add(RefreshTokenTTL),
+	}
+
+	refreshToken, hash := database.NewRefreshToken(s.ID, secret)
+	s.RefreshHash = hash
+
+	if err := DB.CreateSession(u.conf.Name, s); err != nil {
+		return model.Tokens{}, err
+	}
+
+	return u.issueTokens(tok, s.ID, refreshToken)
+}
+
+// RefreshSession exchanges a refresh token for new tokens, the refresh token
+// cannot be used again. A refresh token used twice was likely stolen, the
+// session is revoked when it happens.
+func (u User) RefreshSession(refreshToken string) (model.Tokens, error) {
+	id, secret, ok := database.ParseRefreshToken(refreshToken)
+	if !ok {
+		return model.Tokens{}, ErrInvalidRefreshToken
+	}
+
+	s, err := DB.GetSession(u.conf.Name, id)
+	if err != nil || len(s.ID) == 0 {
+		return model.Tokens{}, ErrInvalidRefreshToken
+	} else if s.IsExpired() {
+		if err := u.EndSession(id); err != nil {
+			return model.Tokens{}, err
+		}
+		return model.Tokens{}, ErrInvalidRefreshToken
+	}
+
+	newSecret, err := newSecret()
+	if err != nil {
+		return model.Tokens{}, err
+	}
+
+	newRefreshToken, newHash := database.NewRefreshToken(id, newSecret)
+
+	hash := database.HashAPIKeySecret(secret)
+	rotated, err := DB.RotateSession(u.conf.Name, id, hash, newHash, time.Now().Add(RefreshTokenTTL))
+	if err != nil {
+		return model.Tokens{}, err
+	} else if !rotated {
+		if err := u.EndSession(id); err != nil {
+			return model.Tokens{}, err
+		}
+		return model.Tokens{}, ErrInvalidRefreshToken
+	}
+
+	tok, err := DB.GetUserByID(u.conf.Name, s.AccountID, s.UserID)
+	if err != nil {
+		return model.Tokens{}, ErrInvalidRefreshToken
+	}
+
+	return u.issueTokens(tok, id, newRefreshToken)
+}
+
+// EndSession signs out a session, its refresh token cannot be used and its
+// access tokens are revoked
+func (u User) EndSession(sessionID string) error {
+	if err := DB.DeleteSession(u.conf.Name, sessionID); err != nil {
+		return err
+	}
+	return cache.RevokeSession(Cache, sessionID)
+}
+
+// EndAllSessions signs out all sessions of a user
+func (u User) EndAllSessions(userID string) error {
+	list, err := DB.ListSessions(u.conf.Name, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range list {
+		if err := cache.RevokeSession(Cache, s.ID); err != nil {
+			return err
+		}
+	}
+	return DB.DeleteSessions(u.conf.Name, userID)
+}
+
+// ListSessions returns the active sessions of a user
+func (u User) ListSessions(userID string) ([]model.Session, error) {
+	list, err := DB.ListSessions(u.conf.Name, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	active := make([]model.Session, 0)
+	for _, s := range list {
+		if !s.IsExpired() {
+			active = append(active, s)
+		}
+	}
+	return active, nil
+}
+
+// RemoveUser removes a user from an account and signs out all their sessions
+func (u User) RemoveUser(auth model.Auth, userID string) error {
+	if err := u.EndAllSessions(userID); err != nil {
+		return err
+	} else if err := DB.RemoveUser(auth, u.conf.Name, userID); err != nil {
+		return err
+	}
+	return DB.DeleteIdentities(u.conf.Name, userID)
+}
+
+// issueTokens caches the user's Auth and returns a new access token for the
+// session
+func (u User) issueTokens(tok model.User, sessionID, refreshToken string) (model.Tokens, error) {
+	token := fmt.Sprintf("%s|%s", tok.ID, tok.Token)
+
+	jwtBytes, err := GetJWT(token, sessionID)
+	if err != nil {
+		return model.Tokens{}, err
+	}
+
+	auth := model.Auth{
+		AccountID: tok.AccountID,
+		UserID:    tok.ID,
+		Email:     tok.Email,
+		Role:      tok.Role,
+		Token:     tok.Token,
+
+		EmailVerified: tok.EmailVerified,
+	}
+
+	auth, err = database.WithRoleName(DB, u.conf.Name, auth)
+	if err != nil {
+		return model.Tokens{}, err
+	}
+
+	if err := Cache.SetTyped(token, auth); err != nil {
+		return model.Tokens{}, err
+	}
+	if err := Cache.SetTyped("base:"+token, u.conf); err != nil {
+		return model.Tokens{}, err
+	}
+
+	return model.Tokens{
+		Token:        string(jwtBytes),
+		RefreshToken: refreshToken,
+		Expires:      time.Now().Add(AccessTokenTTL),
+	}, nil
+}
+
+// GetJWT returns a short-lived access token of a session from a token
+func GetJWT(token, sessionID string) ([]byte, error) {
+	now := time.Now()
+	pl := model.JWTPayload{
+		Payload: jwt.Payload{
+			Issuer:         "StaticBackend",
+			ExpirationTime: jwt.NumericDate(now.Add(AccessTokenTTL)),
+			NotBefore:      jwt.NumericDate(now),
+			IssuedAt:       jwt.NumericDate(now),
+			JWTID:          internal.RandStringRunes(32),
+		},
+		Token:   token,
+		Session: sessionID,
+	}
+
+	return jwt.Sign(pl, model.HashSecret)
+}
+
+// newSecret returns a random hex encoded secret for the API keys and
+// refresh tokens
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
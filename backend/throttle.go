@@ -0,0 +1,206 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+const (
+	// LoginAttemptsBeforeLockout is the number of failed sign in allowed for
+	// an email and IP before they're locked out
+	LoginAttemptsBeforeLockout = 5
+	// LoginLockout is the first lockout, it doubles on each failure after it
+	// up to MaxLoginLockout
+	LoginLockout = 30 * time.Second
+	// MaxLoginLockout is the longest lockout
+	MaxLoginLockout = time.Hour
+
+	// MagicLinkAttempts is the number of invalid codes allowed for an email
+	// before its magic link is invalidated
+	MagicLinkAttempts = 10
+
+	// TwoFactorAttempts is the number of second factor codes a user can try
+	// before being locked out. Signing in with the password again does not
+	// give more attempts, they're forgotten once a code is valid.
+	TwoFactorAttempts = 10
+
+	// the failed attempts are forgotten after a day without failures
+	attemptsTTL = 24 * time.Hour
+)
+
+// ErrTooManyAttempts is returned while sign in attempts are locked out, see
+// LockoutError for how long
+var ErrTooManyAttempts = errors.New("too many failed attempts, please try again later")
+
+// LockoutError is returned while sign in attempts are locked out after too
+// many failures
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("too many failed attempts, please try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// Unwrap makes errors.Is(err, ErrTooManyAttempts) true
+func (e *LockoutError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+type attempts struct {
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"lockedUntil"`
+	Expires     time.Time `json:"expires"`
+}
+
+// attemptLimiter counts the failed attempts of a key in the cache, the key
+// is locked out after a number of free failures with a lockout doubling on
+// each failure
+type attemptLimiter struct {
+	key        string
+	free       int
+	lockout    time.Duration
+	maxLockout time.Duration
+}
+
+func (u User) loginLimiter(email, ip string) attemptLimiter {
+	return attemptLimiter{
+		key:        fmt.Sprintf("login-attempts-%s-%s-%s", u.conf.Name, email, ip),
+		free:       LoginAttemptsBeforeLockout,
+		lockout:    LoginLockout,
+		maxLockout: MaxLoginLockout,
+	}
+}
+
+func (u User) magicLinkLimiter(email string) attemptLimiter {
+	return attemptLimiter{
+		key:        fmt.Sprintf("ml-attempts-%s-%s", u.conf.Name, email),
+		free:       MagicLinkAttempts,
+		lockout:    LoginLockout,
+		maxLockout: MaxLoginLockout,
+	}
+}
+
+// twoFactorLimiter counts the second factor attempts of a user, see take
+func (u User) twoFactorLimiter(userID string) attemptLimiter {
+	return attemptLimiter{
+		key:  fmt.Sprintf("2fa-attempts-%s-%s", u.conf.Name, userID),
+		free: TwoFactorAttempts,
+	}
+}
+
+func (l attemptLimiter) get() (a attempts, ok bool) {
+	if err := Cache.GetTyped(l.key, &a); err != nil || time.Now().After(a.Expires) {
+		return attempts{}, false
+	}
+	return a, true
+}
+
+// check returns a LockoutError while the key is locked out
+func (l attemptLimiter) check() error {
+	a, ok := l.get()
+	if !ok {
+		return nil
+	}
+
+	if wait := time.Until(a.LockedUntil); wait > 0 {
+		return &LockoutError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// fail records a failed attempt and locks the key out when it has no free
+// attempts left
+func (l attemptLimiter) fail() (attempts, error) {
+	now := time.Now()
+
+	a, _ := l.get()
+	a.Failures++
+	a.Expires = now.Add(attemptsTTL)
+
+	if over := a.Failures - l.free; over >= 0 {
+		lockout := l.maxLockout
+		if over < 32 && l.lockout<<over < l.maxLockout {
+			lockout = l.lockout << over
+		}
+		a.LockedUntil = now.Add(lockout)
+	}
+
+	return a, Cache.SetTyped(l.key, a)
+}
+
+// take counts an attempt before it's verified and returns a LockoutError
+// when there are no free attempts left until reset. The attempts are counted
+// with Cache.Inc so concurrent attempts cannot exceed the free ones.
+func (l attemptLimiter) take() (attempts, error) {
+	counter := l.key + "-count"
+
+	a, ok := l.get()
+	if !ok {
+		a = attempts{Expires: time.Now().Add(attemptsTTL)}
+		if err := Cache.SetTyped(l.key, a); err != nil {
+			return a, err
+		} else if err := Cache.Set(counter, "0"); err != nil {
+			return a, err
+		}
+	}
+
+	n, err := Cache.Inc(counter, 1)
+	if err != nil {
+		return a, err
+	}
+
+	a.Failures = int(n)
+	if a.Failures > l.free {
+		a.LockedUntil = a.Expires
+		return a, &LockoutError{RetryAfter: time.Until(a.Expires)}
+	}
+	return a, nil
+}
+
+// reset forgets the failed attempts after a success
+func (l attemptLimiter) reset() error {
+	if _, ok := l.get(); !ok {
+		return nil
+	}
+	return Cache.SetTyped(l.key, attempts{})
+}
+
+// auditLoginFailure logs a failed sign in and publishes a login_failed event
+// on the audit channel, functions can be triggered by it
+func (u User) auditLoginFailure(method, email, ip string, a attempts) {
+	ev := model.LoginFailure{
+		Email:    email,
+		IP:       ip,
+		Method:   method,
+		Failures: a.Failures,
+		Time:     time.Now(),
+	}
+	if a.LockedUntil.After(ev.Time) {
+		ev.LockedUntil = &a.LockedUntil
+	}
+
+	if Log != nil {
+		Log.Warn().Msgf("failed %s sign in for %s from %s on %s (%d failures)", method, email, ip, u.conf.Name, a.Failures)
+	}
+
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+
+	msg := model.Command{
+		SID:     model.SystemID,
+		Type:    model.MsgTypeLoginFailed,
+		Data:    string(b),
+		Channel: model.AuditChannel,
+		Base:    u.conf.Name,
+	}
+	if err := Cache.Publish(msg); err != nil && Log != nil {
+		Log.Error().Err(err).Msg("unable to publish the audit event")
+	}
+}
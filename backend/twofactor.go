@@ -0,0 +1,340 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/internal"
+	"github.com/staticbackendhq/core/model"
+)
+
+const (
+	// TwoFactorChallengeTTL is how long a user has to enter their code after
+	// their password
+	TwoFactorChallengeTTL = 5 * time.Minute
+
+	twoFactorIssuer        = "StaticBackend"
+	maxChallengeAttempts   = 5
+	recoveryCodesGenerated = 10
+)
+
+var (
+	// ErrTwoFactorRequired is returned with a challenge when signing in a
+	// user having two-factor authentication (see VerifyTwoFactor)
+	ErrTwoFactorRequired = errors.New("two-factor authentication code required")
+	// ErrInvalidTwoFactorCode is returned when a code or challenge is invalid
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor authentication code")
+	// ErrRootTwoFactorRequired is returned when signing in a root user without
+	// two-factor authentication and the database requires it
+	ErrRootTwoFactorRequired = errors.New("two-factor authentication is required for root users")
+)
+
+type twoFactorChallenge struct {
+	AccountID string    `json:"accountId"`
+	UserID    string    `json:"userId"`
+	IP        string    `json:"ip"`
+	Expires   time.Time `json:"expires"`
+}
+
+// twoFactorAttemptsKey counts the codes tried for a challenge
+func twoFactorAttemptsKey(challenge string) string {
+	return "2fa-" + challenge + "-attempts"
+}
+
+// SignIn starts a session for a user who passed their first factor. When the
+// user has two-factor authentication ErrTwoFactorRequired is returned with
+// a challenge instead. ErrEmailNotVerified is returned when the database
+// blocks unverified users.
+func (u User) SignIn(tok model.User) (model.Tokens, error) {
+	return u.signIn(tok, "")
+}
+
+// signIn is SignIn for a user signing in from an IP, the IP's password
+// failures are forgotten once the second factor passes
+func (u User) signIn(tok model.User, ip string) (model.Tokens, error) {
+	if err := u.checkEmailVerified(tok); err != nil {
+		return model.Tokens{}, err
+	}
+
+	tf, err := DB.GetTwoFactor(u.conf.Name, tok.ID)
+	if err != nil {
+		return model.Tokens{}, err
+	}
+
+	if !tf.Enabled {
+		if err := u.checkRootTwoFactor(tok.Role); err != nil {
+			return model.Tokens{}, err
+		}
+		return u.StartSession(tok)
+	}
+
+	challenge, err := newSecret()
+	if err != nil {
+		return model.Tokens{}, err
+	}
+
+	c := twoFactorChallenge{
+		AccountID: tok.AccountID,
+		UserID:    tok.ID,
+		IP:        ip,
+		Expires:   time.Now().Add(TwoFactorChallengeTTL),
+	}
+	if err := Cache.SetTyped("2fa-"+challenge, c); err != nil {
+		return model.Tokens{}, err
+	} else if err := Cache.Set(twoFactorAttemptsKey(challenge), "0"); err != nil {
+		return model.Tokens{}, err
+	}
+
+	return model.Tokens{Challenge: challenge, Expires: c.Expires}, ErrTwoFactorRequired
+}
+
+// VerifyTwoFactor starts the session of a sign in challenge with a TOTP or
+// recovery code. The challenge ends after a few invalid codes and the user is
+// locked out after TwoFactorAttempts invalid codes across their challenges,
+// the failures are published as login_failed events on the audit channel.
+func (u User) VerifyTwoFactor(challenge, code string) (model.Tokens, error) {
+	var c twoFactorChallenge
+	if err := Cache.GetTyped("2fa-"+challenge, &c); err != nil {
+		return model.Tokens{}, ErrInvalidTwoFactorCode
+	} else if c.Expires.Before(time.Now()) {
+		return model.Tokens{}, ErrInvalidTwoFactorCode
+	}
+
+	tok, err := DB.GetUserByID(u.conf.Name, c.AccountID, c.UserID)
+	if err != nil {
+		return model.Tokens{}, err
+	}
+
+	// the attempts are counted before the code is checked so concurrent
+	// requests cannot try more codes
+	n, err := Cache.Inc(twoFactorAttemptsKey(challenge), 1)
+	if err != nil {
+		return model.Tokens{}, err
+	} else if n > maxChallengeAttempts {
+		return model.Tokens{}, ErrInvalidTwoFactorCode
+	}
+
+	limiter := u.twoFactorLimiter(c.UserID)
+	a, err := limiter.take()
+	if errors.Is(err, ErrTooManyAttempts) {
+		u.auditLoginFailure("2fa", tok.Email, c.IP, a)
+		return model.Tokens{}, err
+	} else if err != nil {
+		return model.Tokens{}, err
+	}
+
+	ok, err := u.CheckTwoFactor(c.UserID, code)
+	if err != nil {
+		return model.Tokens{}, err
+	} else if !ok {
+		u.auditLoginFailure("2fa", tok.Email, c.IP, a)
+		return model.Tokens{}, ErrInvalidTwoFactorCode
+	}
+
+	// a challenge is used once
+	c.Expires = time.Time{}
+	if err := Cache.SetTyped("2fa-"+challenge, c); err != nil {
+		return model.Tokens{}, err
+	} else if err := limiter.reset(); err != nil {
+		return model.Tokens{}, err
+	} else if err := u.loginLimiter(strings.ToLower(tok.Email), c.IP).reset(); err != nil {
+		return model.Tokens{}, err
+	}
+
+	return u.StartSession(tok)
+}
+
+// CheckTwoFactor returns true if the code is a valid TOTP or recovery code of
+// a user having two-factor authentication. Codes cannot be used twice.
+func (u User) CheckTwoFactor(userID, code string) (bool, error) {
+	tf, err := DB.GetTwoFactor(u.conf.Name, userID)
+	if err != nil || !tf.Enabled {
+		return false, err
+	}
+
+	secret, err := model.Decrypt(tf.Secret)
+	if err != nil {
+		return false, err
+	}
+
+	if step, ok := internal.ValidateTOTP(string(secret), code, time.Now(), tf.LastStep); ok {
+		tf.LastStep = step
+		return true, DB.SaveTwoFactor(u.conf.Name, tf)
+	}
+
+	hash := database.HashAPIKeySecret(normalizeRecoveryCode(code))
+	for i, h := range tf.RecoveryCodes {
+		if h == hash {
+			tf.RecoveryCodes = append(tf.RecoveryCodes[:i], tf.RecoveryCodes[i+1:]...)
+			return true, DB.SaveTwoFactor(u.conf.Name, tf)
+		}
+	}
+	return false, nil
+}
+
+// SetupTwoFactor starts the enrollment of a user's authenticator app, it's
+// enabled once they confirm a code with EnableTwoFactor
+func (u User) SetupTwoFactor(auth model.Auth) (model.TwoFactorSetup, error) {
+	tf, err := DB.GetTwoFactor(u.conf.Name, auth.UserID)
+	if err != nil {
+		return model.TwoFactorSetup{}, err
+	} else if tf.Enabled {
+		return model.TwoFactorSetup{}, errors.New("two-factor authentication is already enabled")
+	}
+
+	secret, err := internal.NewTOTPSecret()
+	if err != nil {
+		return model.TwoFactorSetup{}, err
+	}
+
+	enc, err := model.Encrypt([]byte(secret))
+	if err != nil {
+		return model.TwoFactorSetup{}, err
+	}
+
+	tf = model.TwoFactor{UserID: auth.UserID, Secret: enc}
+	if err := DB.SaveTwoFactor(u.conf.Name, tf); err != nil {
+		return model.TwoFactorSetup{}, err
+	}
+
+	return model.TwoFactorSetup{
+		Secret: secret,
+		URI:    internal.TOTPURI(twoFactorIssuer, auth.Email, secret),
+	}, nil
+}
+
+// EnableTwoFactor enables the two-factor authentication of a user with a
+// code from their authenticator app and returns their recovery codes
+func (u User) EnableTwoFactor(auth model.Auth, code string) ([]string, error) {
+	tf, err := DB.GetTwoFactor(u.conf.Name, auth.UserID)
+	if err != nil {
+		return nil, err
+	} else if len(tf.UserID) == 0 {
+		return nil, errors.New("two-factor authentication is not set up")
+	} else if tf.Enabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+
+	secret, err := model.Decrypt(tf.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := internal.ValidateTOTP(string(secret), code, time.Now(), tf.LastStep)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	tf.Enabled = true
+	tf.LastStep = step
+	tf.RecoveryCodes = hashes
+	return codes, DB.SaveTwoFactor(u.conf.Name, tf)
+}
+
+// DisableTwoFactor removes the two-factor authentication of a user, a valid
+// code is required
+func (u User) DisableTwoFactor(auth model.Auth, code string) error {
+	if err := u.checkRootTwoFactor(auth.Role); err != nil {
+		return err
+	}
+
+	ok, err := u.CheckTwoFactor(auth.UserID, code)
+	if err != nil {
+		return err
+	} else if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return DB.DeleteTwoFactor(u.conf.Name, auth.UserID)
+}
+
+// NewRecoveryCodes replaces the recovery codes of a user, a valid code is
+// required
+func (u User) NewRecoveryCodes(auth model.Auth, code string) ([]string, error) {
+	ok, err := u.CheckTwoFactor(auth.UserID, code)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	tf, err := DB.GetTwoFactor(u.conf.Name, auth.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	tf.RecoveryCodes = hashes
+	return codes, DB.SaveTwoFactor(u.conf.Name, tf)
+}
+
+// RequireRootTwoFactor changes if the root users of the database must use
+// two-factor authentication. The root user requiring it must have it enabled
+// so they're not locked out.
+func (u User) RequireRootTwoFactor(auth model.Auth, require bool) error {
+	if require {
+		tf, err := DB.GetTwoFactor(u.conf.Name, auth.UserID)
+		if err != nil {
+			return err
+		} else if !tf.Enabled {
+			return errors.New("enable two-factor authentication for your user first")
+		}
+	}
+
+	settings, err := DB.GetSettings(u.conf.Name)
+	if err != nil {
+		return err
+	}
+
+	settings.RequireRootTwoFactor = require
+	return DB.SaveSettings(u.conf.Name, settings)
+}
+
+// checkRootTwoFactor returns ErrRootTwoFactorRequired for root users when the
+// database requires two-factor authentication
+func (u User) checkRootTwoFactor(role int) error {
+	if role < 100 {
+		return nil
+	}
+
+	settings, err := DB.GetSettings(u.conf.Name)
+	if err != nil {
+		return err
+	} else if settings.RequireRootTwoFactor {
+		return ErrRootTwoFactorRequired
+	}
+	return nil
+}
+
+// newRecoveryCodes returns the recovery codes given to the user and their
+// hashes that are stored
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < recoveryCodesGenerated; i++ {
+		secret, err := newSecret()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		code := fmt.Sprintf("%s-%s", secret[:5], secret[5:10])
+		codes = append(codes, code)
+		hashes = append(hashes, database.HashAPIKeySecret(normalizeRecoveryCode(code)))
+	}
+	return
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
@@ -1,19 +1,22 @@
 package backend
 
 import (
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"math/rand"
 	"strings"
-	"time"
 
-	"github.com/gbrlsnchs/jwt/v3"
+	"github.com/staticbackendhq/core/database"
 	"github.com/staticbackendhq/core/email"
-	"github.com/staticbackendhq/core/internal"
 	"github.com/staticbackendhq/core/model"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrInvalidMagicLinkCode is returned for an invalid, used or expired magic
+// link code
+var ErrInvalidMagicLinkCode = errors.New("invalid magic link code")
+
 // User handles everything related to accounts and users inside a database
 type User struct {
 	conf model.DatabaseConfig
@@ -23,85 +26,126 @@ func newUser(base model.DatabaseConfig) User {
 	return User{conf: base}
 }
 
-// Authenticate tries to authenticate an email/password and return a session token
-func (u User) Authenticate(email, password string) (string, error) {
+// Authenticate tries to authenticate an email/password and starts a session,
+// see AuthenticateFrom to lock out the failed attempts per IP
+func (u User) Authenticate(email, password string) (model.Tokens, error) {
+	return u.AuthenticateFrom(email, password, "")
+}
+
+// AuthenticateFrom tries to authenticate an email/password from an IP and
+// starts a session, see SignIn for users having two-factor authentication.
+//
+// The email and IP are locked out after LoginAttemptsBeforeLockout failures
+// and a LockoutError is returned until the lockout ends. The failures are
+// published as login_failed events on the audit channel.
+func (u User) AuthenticateFrom(email, password, ip string) (model.Tokens, error) {
 	email = strings.ToLower(email)
 
-	tok, err := DB.FindUserByEmail(u.conf.Name, email)
+	limiter := u.loginLimiter(email, ip)
+	if err := limiter.check(); err != nil {
+		return model.Tokens{}, err
+	}
+
+	tok, err := u.checkPassword(email, password)
 	if err != nil {
-		return "", err
+		a, ferr := limiter.fail()
+		if ferr != nil {
+			return model.Tokens{}, ferr
+		}
+
+		u.auditLoginFailure("password", email, ip, a)
+		return model.Tokens{}, err
 	}
 
-	if err = bcrypt.CompareHashAndPassword([]byte(tok.Password), []byte(password)); err != nil {
-		return "", errors.New("invalid email/password")
+	tokens, err := u.signIn(tok, ip)
+	if errors.Is(err, ErrTwoFactorRequired) {
+		// the failures are forgotten once the second factor passes
+		return tokens, err
 	}
 
-	token := fmt.Sprintf("%s|%s", tok.ID, tok.Token)
+	if rerr := limiter.reset(); rerr != nil {
+		return model.Tokens{}, rerr
+	}
+	return tokens, err
+}
 
-	jwt, err := GetJWT(token)
+// checkPassword returns the user if the password matches
+func (u User) checkPassword(email, password string) (model.User, error) {
+	email = strings.ToLower(email)
+
+	tok, err := DB.FindUserByEmail(u.conf.Name, email)
 	if err != nil {
-		return "", err
+		return model.User{}, err
 	}
 
-	auth := model.Auth{
-		AccountID: tok.AccountID,
-		UserID:    tok.ID,
-		Email:     tok.Email,
-		Role:      tok.Role,
-		Token:     tok.Token,
+	if err = bcrypt.CompareHashAndPassword([]byte(tok.Password), []byte(password)); err != nil {
+		return model.User{}, errors.New("invalid email/password")
 	}
+	return tok, nil
+}
 
-	//TODO: find a good way to find all occurences of those two
-	// and make them easily callable via a shared function
-	if err = Cache.SetTyped(token, auth); err != nil {
-		return "", err
-	}
-	if err = Cache.SetTyped("base:"+token, u.conf); err != nil {
-		return "", err
-	}
+// Register creates a new account and user and starts a session
+func (u User) Register(email, password string) (model.Tokens, error) {
+	// account creator have the role=50 (Account Admin)
+	tokens, _, err := u.RegisterWithRole(email, password, 50)
+	return tokens, err
+}
 
-	return string(jwt), nil
+// RegisterWithRole creates a new account with a user having the role and
+// starts a session. When the database blocks unverified users the user is
+// created without a session and ErrEmailNotVerified is returned.
+func (u User) RegisterWithRole(email, password string, role int) (model.Tokens, model.User, error) {
+	if err := u.checkPasswordPolicy(password); err != nil {
+		return model.Tokens{}, model.User{}, err
+	}
+	return u.register(email, password, role, false)
 }
 
-// Register creates a new account and user
-func (u User) Register(email, password string) (string, error) {
+// register creates an account with a user, emailVerified is true when the
+// email was verified by an external login provider
+func (u User) register(email, password string, role int, emailVerified bool) (model.Tokens, model.User, error) {
 	email = strings.ToLower(email)
 
 	exists, err := DB.UserEmailExists(u.conf.Name, email)
 	if err != nil {
-		return "", err
+		return model.Tokens{}, model.User{}, err
 	} else if exists {
-		return "", errors.New("invalid email")
+		return model.Tokens{}, model.User{}, errors.New("invalid email")
 	}
 
-	// account creator have the role=50 (Account Admin)
-	jwtBytes, tok, err := u.CreateAccountAndUser(email, password, 50)
+	acctID, err := DB.CreateAccount(u.conf.Name, email)
 	if err != nil {
-		return "", err
+		return model.Tokens{}, model.User{}, err
 	}
 
-	token := string(jwtBytes)
-
-	auth := model.Auth{
-		AccountID: tok.AccountID,
-		UserID:    tok.ID,
-		Email:     tok.Email,
-		Role:      tok.Role,
-		Token:     tok.Token,
+	tok, err := u.insertUser(acctID, email, password, role)
+	if err != nil {
+		return model.Tokens{}, model.User{}, err
 	}
 
-	if err := Cache.SetTyped(token, auth); err != nil {
-		return "", err
+	if emailVerified {
+		if err := DB.SetEmailVerified(u.conf.Name, tok.ID); err != nil {
+			return model.Tokens{}, tok, err
+		}
+		tok.EmailVerified = true
+	} else if err := u.SendVerificationEmail(tok); err != nil {
+		return model.Tokens{}, tok, err
 	}
-	if err := Cache.SetTyped("base:"+token, u.conf); err != nil {
-		return "", err
+
+	if err := u.checkEmailVerified(tok); err != nil {
+		return model.Tokens{}, tok, err
 	}
 
-	return token, nil
+	tokens, err := u.StartSession(tok)
+	return tokens, tok, err
 }
 
 // CreateAccountAndUser creates an account with a user
 func (u User) CreateAccountAndUser(email, password string, role int) ([]byte, model.User, error) {
+	if err := u.checkPasswordPolicy(password); err != nil {
+		return nil, model.User{}, err
+	}
+
 	acctID, err := DB.CreateAccount(u.conf.Name, email)
 	if err != nil {
 		return nil, model.User{}, err
@@ -114,48 +158,52 @@ func (u User) CreateAccountAndUser(email, password string, role int) ([]byte, mo
 	return jwtBytes, tok, nil
 }
 
-// CreateUser creates a user for an Account
+// CreateUser creates a user for an Account and returns the access token of
+// their first session, a verification email is sent when the database has
+// email verification enabled
 func (u User) CreateUser(accountID, email, password string, role int) ([]byte, model.User, error) {
-	b, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err := u.checkPasswordPolicy(password); err != nil {
+		return nil, model.User{}, err
+	}
+
+	tok, err := u.insertUser(accountID, email, password, role)
 	if err != nil {
 		return nil, model.User{}, err
 	}
 
-	tok := model.User{
-		AccountID: accountID,
-		Email:     email,
-		Token:     DB.NewID(),
-		Password:  string(b),
-		Role:      role,
+	if err := u.SendVerificationEmail(tok); err != nil {
+		return nil, tok, err
 	}
 
-	tokID, err := DB.CreateUser(u.conf.Name, tok)
+	tokens, err := u.StartSession(tok)
 	if err != nil {
-		return nil, model.User{}, err
+		return nil, tok, err
 	}
 
-	tok.ID = tokID
-
-	token := fmt.Sprintf("%s|%s", tokID, tok.Token)
+	return []byte(tokens.Token), tok, nil
+}
 
-	// Get their JWT
-	jwtBytes, err := GetJWT(token)
+func (u User) insertUser(accountID, email, password string, role int) (model.User, error) {
+	b, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		return nil, tok, err
+		return model.User{}, err
 	}
 
-	auth := model.Auth{
-		AccountID: tok.AccountID,
-		UserID:    tok.ID,
-		Email:     tok.Email,
+	tok := model.User{
+		AccountID: accountID,
+		Email:     email,
+		Token:     DB.NewID(),
+		Password:  string(b),
 		Role:      role,
-		Token:     tok.Token,
 	}
-	if err := Cache.SetTyped(token, auth); err != nil {
-		return nil, tok, err
+
+	tokID, err := DB.CreateUser(u.conf.Name, tok)
+	if err != nil {
+		return model.User{}, err
 	}
 
-	return jwtBytes, tok, nil
+	tok.ID = tokID
+	return tok, nil
 }
 
 // SetPasswordResetCode sets the password forget code for a user
@@ -177,6 +225,10 @@ func (u User) SetPasswordResetCode(email, code string) error {
 func (u User) ResetPassword(email, code, password string) error {
 	email = strings.ToLower(email)
 
+	if err := u.checkPasswordPolicy(password); err != nil {
+		return err
+	}
+
 	b, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return err
@@ -185,79 +237,84 @@ func (u User) ResetPassword(email, code, password string) error {
 	return DB.ResetPassword(u.conf.Name, email, code, string(b))
 }
 
-// SetUserRole changes the role of a user
+// SetUserRole changes the role of a user, their cached session is updated
+// with the new role
 func (u User) SetUserRole(email string, role int) error {
 	email = strings.ToLower(email)
-	return DB.SetUserRole(u.conf.Name, email, role)
-}
-
-// UserSetPassword password changes initiated by the user
-func (u User) UserSetPassword(email, oldpw, newpw string) error {
-	email = strings.ToLower(email)
+	if err := DB.SetUserRole(u.conf.Name, email, role); err != nil {
+		return err
+	}
 
 	tok, err := DB.FindUserByEmail(u.conf.Name, email)
 	if err != nil {
 		return err
 	}
 
-	if _, err := u.Authenticate(email, oldpw); err != nil {
-		return err
+	key := fmt.Sprintf("%s|%s", tok.ID, tok.Token)
+
+	var auth model.Auth
+	if err := Cache.GetTyped(key, &auth); err != nil {
+		// the user has no active session
+		return nil
 	}
 
-	b, err := bcrypt.GenerateFromPassword([]byte(newpw), bcrypt.DefaultCost)
+	auth.Role = role
+	auth, err = database.WithRoleName(DB, u.conf.Name, auth)
 	if err != nil {
 		return err
 	}
-
-	return DB.UserSetPassword(u.conf.Name, tok.ID, string(b))
+	return Cache.SetTyped(key, auth)
 }
 
-// GetAuthToken returns a session token for a user
-func (u User) GetAuthToken(tok model.User) (jwtBytes []byte, err error) {
-	token := fmt.Sprintf("%s|%s", tok.ID, tok.Token)
-
-	// get their JWT
-	jwtBytes, err = GetJWT(token)
+// SetUserRoleName gives a named role to a user (see database.FindRoleByName)
+func (u User) SetUserRoleName(email, name string) error {
+	role, err := database.FindRoleByName(DB, u.conf.Name, name)
 	if err != nil {
-		return
+		return err
 	}
+	return u.SetUserRole(email, role.Level)
+}
 
-	auth := model.Auth{
-		AccountID: tok.AccountID,
-		UserID:    tok.ID,
-		Email:     tok.Email,
-		Role:      tok.Role,
-		Token:     tok.Token,
+// SetUserTeams replaces the teams of a user, the rules referencing
+// auth.teams use them right away
+func (u User) SetUserTeams(email string, teams []string) error {
+	if err := database.ValidateTeams(teams); err != nil {
+		return err
 	}
 
-	//TODO: find a good way to find all occurences of those two
-	// and make them easily callable via a shared function
-	if err = Cache.SetTyped(token, auth); err != nil {
-		return
+	tok, err := DB.FindUserByEmail(u.conf.Name, strings.ToLower(email))
+	if err != nil {
+		return err
 	}
-	if err = Cache.SetTyped("base:"+token, u.conf); err != nil {
-		return
+	return DB.SetUserTeams(u.conf.Name, tok.ID, teams)
+}
+
+// UserSetPassword password changes initiated by the user
+func (u User) UserSetPassword(email, oldpw, newpw string) error {
+	email = strings.ToLower(email)
+
+	tok, err := DB.FindUserByEmail(u.conf.Name, email)
+	if err != nil {
+		return err
 	}
 
-	return
-}
+	if _, err := u.checkPassword(email, oldpw); err != nil {
+		return err
+	} else if err := u.checkPasswordPolicy(newpw); err != nil {
+		return err
+	}
 
-// GetJWT returns a session token from a token
-func GetJWT(token string) ([]byte, error) {
-	now := time.Now()
-	pl := model.JWTPayload{
-		Payload: jwt.Payload{
-			Issuer:         "StaticBackend",
-			ExpirationTime: jwt.NumericDate(now.Add(12 * time.Hour)),
-			NotBefore:      jwt.NumericDate(now.Add(30 * time.Minute)),
-			IssuedAt:       jwt.NumericDate(now),
-			JWTID:          internal.RandStringRunes(32),
-		},
-		Token: token,
+	b, err := bcrypt.GenerateFromPassword([]byte(newpw), bcrypt.DefaultCost)
+	if err != nil {
+		return err
 	}
 
-	return jwt.Sign(pl, model.HashSecret)
+	return DB.UserSetPassword(u.conf.Name, tok.ID, string(b))
+}
 
+// GetAuthToken starts a session for a user
+func (u User) GetAuthToken(tok model.User) (model.Tokens, error) {
+	return u.StartSession(tok)
 }
 
 // MagicLinkData magic links for no-password sign-in
@@ -282,7 +339,7 @@ func (u User) SetupMagicLink(data MagicLinkData) error {
 	}
 	data.MagicLink += fmt.Sprintf("?code=%d&email=%s", code, data.Email)
 
-	if err := Cache.Set("ml-"+data.Email, fmt.Sprintf("%d a", code)); err != nil {
+	if err := Cache.Set("ml-"+data.Email, fmt.Sprintf("%d", code)); err != nil {
 		return err
 	}
 
@@ -299,44 +356,61 @@ func (u User) SetupMagicLink(data MagicLinkData) error {
 	return nil
 }
 
-// ValidateMagicLink validates a magic link code and returns a session token on
-// success
-func (u User) ValidateMagicLink(email, code string) (string, error) {
+// ValidateMagicLink validates a magic link code and starts a session on
+// success, see SignIn for users having two-factor authentication.
+//
+// The code can be used once. After MagicLinkAttempts invalid codes the link
+// is invalidated and the email is locked out like AuthenticateFrom.
+func (u User) ValidateMagicLink(email, code string) (model.Tokens, error) {
 	email = strings.ToLower(email)
 
-	val, err := Cache.Get("ml-" + email)
-	if err != nil {
-		return "", err
+	limiter := u.magicLinkLimiter(email)
+	if err := limiter.check(); err != nil {
+		return model.Tokens{}, err
 	}
 
-	parts := strings.Split(val, " ")
-	if len(parts) != 2 {
-		return "", errors.New("invalid code")
+	val, err := Cache.Get("ml-" + email)
+	if err != nil || len(val) == 0 {
+		return model.Tokens{}, ErrInvalidMagicLinkCode
 	}
 
 	// if the code isn't what was set we make sure they're not trying to
 	// "brute force" random code.
-	if parts[0] != code {
-		if len(parts[1]) >= 10 {
-			return "", errors.New("maximum retry reached")
+	if subtle.ConstantTimeCompare([]byte(val), []byte(code)) != 1 {
+		a, err := limiter.fail()
+		if err != nil {
+			return model.Tokens{}, err
 		}
 
-		if err := Cache.Set("ml-"+email, val+"a"); err != nil {
-			return "", err
+		u.auditLoginFailure("magic_link", email, "", a)
+
+		if a.Failures >= MagicLinkAttempts {
+			if err := Cache.Set("ml-"+email, ""); err != nil {
+				return model.Tokens{}, err
+			}
 		}
+		return model.Tokens{}, ErrInvalidMagicLinkCode
 	}
 
 	// they got the right code, return a session token
+	if err := Cache.Set("ml-"+email, ""); err != nil {
+		return model.Tokens{}, err
+	} else if err := limiter.reset(); err != nil {
+		return model.Tokens{}, err
+	}
 
 	tok, err := DB.FindUserByEmail(u.conf.Name, email)
 	if err != nil {
-		return "", err
+		return model.Tokens{}, err
 	}
 
-	jwtBytes, err := u.GetAuthToken(tok)
-	if err != nil {
-		return "", err
+	// the code was received by email which verifies it
+	if !tok.EmailVerified {
+		if err := u.setEmailVerified(tok); err != nil {
+			return model.Tokens{}, err
+		}
+		tok.EmailVerified = true
 	}
 
-	return string(jwtBytes), nil
+	return u.SignIn(tok)
 }
@@ -0,0 +1,250 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gbrlsnchs/jwt/v3"
+	"github.com/staticbackendhq/core/email"
+	"github.com/staticbackendhq/core/model"
+)
+
+const (
+	// EmailVerificationTTL is how long a verification link is valid
+	EmailVerificationTTL = 24 * time.Hour
+	// VerificationResendInterval is the minimum time between two
+	// verification emails sent to a user
+	VerificationResendInterval = time.Minute
+
+	maxVerificationEmailsPerHour = 5
+	verificationSubject          = "email-verification"
+)
+
+var (
+	// ErrEmailNotVerified is returned when signing in an unverified user
+	// and the database blocks them
+	ErrEmailNotVerified = errors.New("your email must be verified before signing in, check your inbox for the verification email")
+	// ErrInvalidVerificationToken is returned when a verification token is
+	// invalid or expired
+	ErrInvalidVerificationToken = errors.New("invalid or expired email verification link")
+	// ErrVerificationRateLimited is returned when resending a verification
+	// email too often
+	ErrVerificationRateLimited = errors.New("a verification email was sent recently, please try again later")
+)
+
+type verificationPayload struct {
+	jwt.Payload
+	DBName    string `json:"db"`
+	AccountID string `json:"aid"`
+	UserID    string `json:"uid"`
+	Email     string `json:"email"`
+}
+
+type verificationSends struct {
+	Count  int       `json:"count"`
+	Window time.Time `json:"window"`
+	Last   time.Time `json:"last"`
+}
+
+// SendVerificationEmail emails a verification link to a user when the
+// database has email verification enabled
+func (u User) SendVerificationEmail(tok model.User) error {
+	settings, err := DB.GetSettings(u.conf.Name)
+	if err != nil {
+		return err
+	}
+
+	ev := settings.EmailVerification
+	if !ev.Enabled || tok.EmailVerified {
+		return nil
+	}
+
+	token, err := u.newVerificationToken(tok)
+	if err != nil {
+		return err
+	}
+
+	link := ev.Link
+	if len(link) == 0 {
+		link = Config.AppURL + "/verify-email"
+	}
+
+	sep := "?"
+	if strings.Contains(link, "?") {
+		sep = "&"
+	}
+	link += sep + "token=" + url.QueryEscape(token)
+
+	mail := email.SendMailData{
+		From:     ev.FromEmail,
+		FromName: ev.FromName,
+		To:       tok.Email,
+		Subject:  ev.Subject,
+		HTMLBody: strings.Replace(ev.Body, "[link]", link, -1),
+	}
+
+	if len(mail.From) == 0 {
+		mail.From = Config.FromEmail
+		mail.FromName = Config.FromName
+	}
+	if len(mail.Subject) == 0 {
+		mail.Subject = "Verify your email"
+	}
+	if len(ev.Body) == 0 {
+		mail.HTMLBody = fmt.Sprintf(`<p>Please verify your email by visiting this link:</p><p><a href="%s">%s</a></p>`, link, link)
+	}
+
+	return Emailer.Send(mail)
+}
+
+// ResendVerificationEmail sends another verification email, at most one per
+// VerificationResendInterval and 5 per hour. Nothing is sent for unknown or
+// verified emails so the response does not reveal if a user exists.
+func (u User) ResendVerificationEmail(address string) error {
+	address = strings.ToLower(address)
+
+	key := fmt.Sprintf("verify-email-%s-%s", u.conf.Name, address)
+
+	now := time.Now()
+
+	var sends verificationSends
+	if err := Cache.GetTyped(key, &sends); err != nil || now.Sub(sends.Window) > time.Hour {
+		sends = verificationSends{Window: now}
+	}
+
+	if now.Sub(sends.Last) < VerificationResendInterval || sends.Count >= maxVerificationEmailsPerHour {
+		return ErrVerificationRateLimited
+	}
+
+	sends.Count++
+	sends.Last = now
+	if err := Cache.SetTyped(key, sends); err != nil {
+		return err
+	}
+
+	exists, err := DB.UserEmailExists(u.conf.Name, address)
+	if err != nil || !exists {
+		return err
+	}
+
+	tok, err := DB.FindUserByEmail(u.conf.Name, address)
+	if err != nil {
+		return err
+	}
+
+	return u.SendVerificationEmail(tok)
+}
+
+// VerifyEmail marks the email of the user of a verification token as
+// verified, the sessions of the user see it on their next request
+func (u User) VerifyEmail(token string) (model.User, error) {
+	var pl verificationPayload
+
+	expValidator := jwt.ExpirationTimeValidator(time.Now())
+	subValidator := jwt.SubjectValidator(verificationSubject)
+	validator := jwt.ValidatePayload(&pl.Payload, expValidator, subValidator)
+	if _, err := jwt.Verify([]byte(token), model.HashSecret, &pl, validator); err != nil {
+		return model.User{}, ErrInvalidVerificationToken
+	} else if pl.DBName != u.conf.Name {
+		return model.User{}, ErrInvalidVerificationToken
+	}
+
+	tok, err := DB.GetUserByID(u.conf.Name, pl.AccountID, pl.UserID)
+	if err != nil {
+		return model.User{}, ErrInvalidVerificationToken
+	} else if tok.Email != pl.Email {
+		// the email changed since the link was sent
+		return model.User{}, ErrInvalidVerificationToken
+	}
+
+	if err := u.setEmailVerified(tok); err != nil {
+		return model.User{}, err
+	}
+
+	tok.EmailVerified = true
+	return tok, nil
+}
+
+// setEmailVerified marks the user as verified and updates the cached Auth of
+// their sessions
+func (u User) setEmailVerified(tok model.User) error {
+	if err := DB.SetEmailVerified(u.conf.Name, tok.ID); err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s|%s", tok.ID, tok.Token)
+
+	var auth model.Auth
+	if err := Cache.GetTyped(key, &auth); err != nil {
+		// the user has no active session
+		return nil
+	}
+
+	auth.EmailVerified = true
+	return Cache.SetTyped(key, auth)
+}
+
+// SetEmailVerification saves the email verification settings of the
+// database, the Link must be an absolute http(s) URL when set
+func (u User) SetEmailVerification(ev model.EmailVerification) error {
+	if len(ev.Link) > 0 {
+		link, err := url.Parse(ev.Link)
+		if err != nil || (link.Scheme != "http" && link.Scheme != "https") || len(link.Host) == 0 {
+			return fmt.Errorf("invalid verification link %q, use an absolute http(s) URL", ev.Link)
+		}
+	}
+
+	for _, p := range ev.Permissions {
+		if len(p) == 0 || strings.ContainsAny(p, " \t\n,") {
+			return fmt.Errorf("invalid permission %q", p)
+		}
+	}
+
+	settings, err := DB.GetSettings(u.conf.Name)
+	if err != nil {
+		return err
+	}
+
+	settings.EmailVerification = ev
+	return DB.SaveSettings(u.conf.Name, settings)
+}
+
+// checkEmailVerified returns ErrEmailNotVerified when the database blocks
+// unverified users from signing in
+func (u User) checkEmailVerified(tok model.User) error {
+	if tok.EmailVerified {
+		return nil
+	}
+
+	settings, err := DB.GetSettings(u.conf.Name)
+	if err != nil {
+		return err
+	}
+
+	ev := settings.EmailVerification
+	if ev.Enabled && ev.BlockLogin {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
+func (u User) newVerificationToken(tok model.User) (string, error) {
+	now := time.Now()
+	pl := verificationPayload{
+		Payload: jwt.Payload{
+			Subject:        verificationSubject,
+			ExpirationTime: jwt.NumericDate(now.Add(EmailVerificationTTL)),
+			IssuedAt:       jwt.NumericDate(now),
+		},
+		DBName:    u.conf.Name,
+		AccountID: tok.AccountID,
+		UserID:    tok.ID,
+		Email:     tok.Email,
+	}
+
+	b, err := jwt.Sign(pl, model.HashSecret)
+	return string(b), err
+}
@@ -20,6 +20,9 @@ type Cache struct {
 	Rdb *redis.Client
 	Ctx context.Context
 	log *logger.Logger
+
+	rules     RulesFinder
+	retention RetentionFinder
 }
 
 // NewCache returns an initiated Redis client
@@ -91,6 +94,28 @@ func (c *Cache) Dec(key string, by int64) (int64, error) {
 	return c.Rdb.DecrBy(c.Ctx, key, by).Result()
 }
 
+// HashSet sets the value of a field in a hash, the hash expires like the
+// other keys if it's not updated
+func (c *Cache) HashSet(key, field, value string) error {
+	_, err := c.Rdb.TxPipelined(c.Ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(c.Ctx, key, field, value)
+		pipe.Expire(c.Ctx, key, 12*time.Hour)
+		return nil
+	})
+	return err
+}
+
+// HashDel removes a field from a hash (atomic per Redis)
+func (c *Cache) HashDel(key, field string) (bool, error) {
+	n, err := c.Rdb.HDel(c.Ctx, key, field).Result()
+	return n > 0, err
+}
+
+// HashGetAll returns all the fields and values of a hash
+func (c *Cache) HashGetAll(key string) (map[string]string, error) {
+	return c.Rdb.HGetAll(c.Ctx, key).Result()
+}
+
 // Subscribe subscribes to a topic to receive messages on system/user events
 func (c *Cache) Subscribe(send chan model.Command, token, channel string, close chan bool) {
 	pubsub := c.Rdb.Subscribe(c.Ctx, channel)
@@ -111,15 +136,19 @@ func (c *Cache) Subscribe(send chan model.Command, token, channel string, close
 				return
 			}
 
-			// TODO: this will need more thinking
-			if msg.Type == model.MsgTypeChanIn {
-				msg.Type = model.MsgTypeChanOut
-			} else if msg.IsSystemEvent {
-
-			} else if msg.IsDBEvent() && !c.HasPermission(token, channel, msg.Data) {
+			msg, ok := deliverable(msg, token, channel, c.HasPermission)
+			if !ok {
 				continue
 			}
-			send <- msg
+
+			// the receiver might be gone or too slow, we stop waiting
+			// once the subscription is closed
+			select {
+			case send <- msg:
+			case <-close:
+				_ = pubsub.Close()
+				return
+			}
 		case <-close:
 			_ = pubsub.Close()
 			return
@@ -130,6 +159,18 @@ func (c *Cache) Subscribe(send chan model.Command, token, channel string, close
 // Publish sends a message and all subscribers will receive it if they're
 // subscribed to that topic
 func (c *Cache) Publish(msg model.Command) error {
+	// the message is retained even if there's no subscriber, they'll
+	// receive it when they reconnect
+	if r, ok, err := findRetention(c.retention, msg); err != nil {
+		c.log.Error().Err(err).Msgf("error getting the retention of %s", msg.Channel)
+	} else if ok {
+		id, err := c.retain(msg, r)
+		if err != nil {
+			c.log.Error().Err(err).Msgf("error retaining message for %s", msg.Channel)
+		}
+		msg.ID = id
+	}
+
 	b, err := json.Marshal(msg)
 	if err != nil {
 		return err
@@ -177,35 +218,103 @@ func (c *Cache) Publish(msg model.Command) error {
 // PublishDocument publishes a database update message (created, updated, deleted)
 // All subscribers will get notified
 func (c *Cache) PublishDocument(auth model.Auth, dbName, channel, typ string, v interface{}) {
-	b, err := json.Marshal(v)
+	msg, err := documentEvent(auth, dbName, channel, typ, v)
 	if err != nil {
 		c.log.Error().Err(err).Msg("error publishing db doc")
 		return
 	}
 
-	msg := model.Command{
-		Channel: channel,
-		Data:    string(b),
-		Type:    typ,
-		Auth:    auth,
-		Base:    dbName,
-	}
-
 	if err := c.Publish(msg); err != nil {
 		c.log.Error().Err(err).Msg("unable to publish db doc events")
 	}
 }
 
+// SetRulesFinder sets how the collection rules are found when checking the
+// permissions of database events
+func (c *Cache) SetRulesFinder(fn RulesFinder) {
+	c.rules = fn
+}
+
+// SetRetentionFinder sets how the channels' retention is found when
+// publishing messages
+func (c *Cache) SetRetentionFinder(fn RetentionFinder) {
+	c.retention = fn
+}
+
+// retain appends a message to the channel's stream and returns its ID. The
+// stream expires once its messages are too old.
+func (c *Cache) retain(msg model.Command, r model.ChannelRetention) (string, error) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+
+	key := historyKey(msg.Base, msg.Channel)
+
+	args := &redis.XAddArgs{
+		Stream: key,
+		MaxLen: int64(maxRetained(r)),
+		Values: map[string]interface{}{"msg": string(b)},
+	}
+	id, err := c.Rdb.XAdd(c.Ctx, args).Result()
+	if err != nil {
+		return "", err
+	}
+
+	if r.MaxMinutes > 0 {
+		if err := c.Rdb.Expire(c.Ctx, key, time.Duration(r.MaxMinutes)*time.Minute).Err(); err != nil {
+			return id, err
+		}
+	}
+	return id, nil
+}
+
+// History returns the messages retained for a channel that were published
+// after the lastID, they're filtered like the subscriptions' messages
+func (c *Cache) History(token, dbName, channel, lastID string) ([]model.Command, error) {
+	r, ok, err := findRetention(c.retention, model.Command{Base: dbName, Channel: channel})
+	if err != nil || !ok {
+		return nil, err
+	} else if _, err := parseEventID(lastID); err != nil {
+		return nil, err
+	}
+
+	// the start ID is inclusive, replayable drops the last message
+	key := historyKey(dbName, channel)
+	entries, err := c.Rdb.XRangeN(c.Ctx, key, lastID, "+", int64(maxRetained(r))).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var msgs []model.Command
+	for _, entry := range entries {
+		s, ok := entry.Values["msg"].(string)
+		if !ok {
+			continue
+		}
+
+		var msg model.Command
+		if err := json.Unmarshal([]byte(s), &msg); err != nil {
+			return nil, err
+		}
+
+		msg.ID = entry.ID
+		msgs = append(msgs, msg)
+	}
+
+	return replayable(msgs, token, channel, lastID, r, c.HasPermission)
+}
+
 // HasPermission determines if a session token has permission to a collection
-func (c *Cache) HasPermission(token, repo, payload string) bool {
+func (c *Cache) HasPermission(token, dbName, repo, payload string) bool {
 	// sbsys is a reserved channel used internally, no need to check for
 	// permissions
 	if repo == "sbsys" {
 		return true
 	}
 
-	var me model.Auth
-	if err := c.GetTyped(token, &me); err != nil {
+	me, err := GetSessionAuth(c, token)
+	if err != nil {
 		return false
 	}
 
@@ -216,6 +325,10 @@ func (c *Cache) HasPermission(token, repo, payload string) bool {
 		return false
 	}
 
+	if allowed, ruled := ruleAllows(c.rules, me, dbName, repo, docs); ruled {
+		return allowed
+	}
+
 	switch internal.ReadPermission(repo) {
 	case internal.PermGroup:
 		acctID, ok := docs["accountId"]
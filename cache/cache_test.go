@@ -3,6 +3,7 @@ package cache
 import (
 	"encoding/json"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -65,7 +66,7 @@ func TestCacheSubscribe(t *testing.T) {
 			timer := time.NewTimer(5 * time.Second)
 			select {
 			case res := <-receiver:
-				if res != payload {
+				if !reflect.DeepEqual(res, payload) {
 					t.Error("Incorrect message is received")
 				}
 				break
@@ -109,7 +110,7 @@ func TestCacheSubscribeOnDBEvent(t *testing.T) {
 			defer timer.Stop()
 			select {
 			case res := <-receiver:
-				if res != payload {
+				if !reflect.DeepEqual(res, payload) {
 					t.Error("Incorrect message is received")
 				}
 				break
@@ -203,7 +204,7 @@ func TestCachePublishDocument(t *testing.T) {
 			defer timer.Stop()
 			select {
 			case res := <-receiver:
-				if res != payload {
+				if !reflect.DeepEqual(res, payload) {
 					t.Error("Incorrect message is received")
 				}
 				break
@@ -215,3 +216,188 @@ func TestCachePublishDocument(t *testing.T) {
 		})
 	}
 }
+
+func TestCachePresence(t *testing.T) {
+	tests := []suite{
+		{name: "presence with redis cache", cache: redisCache},
+		{name: "presence with dev mem cache", cache: devCache},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := make(chan model.Command)
+			closeCn := make(chan bool)
+			defer close(closeCn)
+
+			go tc.cache.Subscribe(receiver, "", "presence-room", closeCn)
+			time.Sleep(10 * time.Millisecond) // need to wait for proper subscriber startup
+
+			now := time.Now()
+			active := model.PresenceMember{ConnectionID: "conn-1", UserID: "user-1", Joined: now, LastSeen: now}
+			stale := model.PresenceMember{
+				ConnectionID: "conn-2",
+				UserID:       "user-2",
+				Joined:       now.Add(-time.Hour),
+				LastSeen:     now.Add(-2 * PresenceTimeout),
+			}
+
+			for _, m := range []model.PresenceMember{active, stale} {
+				if err := SetPresence(tc.cache, "presence-db", "presence-room", m); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			p, err := GetPresence(tc.cache, "presence-db", "presence-room")
+			if err != nil {
+				t.Fatal(err)
+			} else if p.Count != 1 || p.Members[0].ConnectionID != active.ConnectionID {
+				t.Fatalf("expected only the active member got %v", p)
+			}
+
+			select {
+			case msg := <-receiver:
+				if msg.Type != model.MsgTypePresenceTimeout {
+					t.Errorf("expected a presence timeout event got %s", msg.Type)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("The channel does not received a message")
+			}
+
+			if removed, err := RemovePresence(tc.cache, "presence-db", "presence-room", active.ConnectionID); err != nil {
+				t.Fatal(err)
+			} else if !removed {
+				t.Error("expected the member to be removed")
+			}
+
+			if removed, _ := RemovePresence(tc.cache, "presence-db", "presence-room", active.ConnectionID); removed {
+				t.Error("expected the member to be removed once")
+			}
+		})
+	}
+}
+
+func TestCacheHistory(t *testing.T) {
+	tests := []suite{
+		{name: "history with redis cache", cache: redisCache},
+		{name: "history with dev mem cache", cache: devCache},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.cache.SetRetentionFinder(func(dbName, channel string) (model.ChannelRetention, bool, error) {
+				r := model.ChannelRetention{Channel: "history-*", MaxMessages: 3}
+				return r, r.Matches(channel), nil
+			})
+			defer tc.cache.SetRetentionFinder(nil)
+
+			receiver := make(chan model.Command)
+			closeCn := make(chan bool)
+			defer close(closeCn)
+
+			go tc.cache.Subscribe(receiver, "", "history-chan", closeCn)
+			time.Sleep(10 * time.Millisecond) // need to wait for proper subscriber startup
+
+			var ids []string
+			for _, data := range []string{"msg 1", "msg 2", "msg 3", "msg 4"} {
+				msg := model.Command{Type: model.MsgTypeChanIn, Data: data, Channel: "history-chan", Base: "history-db"}
+				if err := tc.cache.Publish(msg); err != nil {
+					t.Fatal(err)
+				}
+
+				select {
+				case res := <-receiver:
+					if len(res.ID) == 0 {
+						t.Fatal("expected the retained message to have an ID")
+					}
+					ids = append(ids, res.ID)
+				case <-time.After(5 * time.Second):
+					t.Fatal("The channel does not received a message")
+				}
+			}
+
+			msgs, err := tc.cache.History("", "history-db", "history-chan", ids[1])
+			if err != nil {
+				t.Fatal(err)
+			} else if len(msgs) != 2 || msgs[0].ID != ids[2] || msgs[1].Data != "msg 4" {
+				t.Fatalf("expected the 2 messages after %s got %v", ids[1], msgs)
+			} else if msgs[0].Type != model.MsgTypeChanOut {
+				t.Errorf("expected the replayed messages type to be %s got %s", model.MsgTypeChanOut, msgs[0].Type)
+			}
+
+			// only the last 3 messages are retained
+			if msgs, _ := tc.cache.History("", "history-db", "history-chan", "0"); len(msgs) != 3 {
+				t.Errorf("expected 3 retained messages got %d", len(msgs))
+			}
+
+			if _, err := tc.cache.History("", "history-db", "history-chan", "invalid"); err != ErrInvalidEventID {
+				t.Errorf("expected ErrInvalidEventID got %v", err)
+			}
+
+			if msgs, _ := tc.cache.History("", "history-db", "not-retained", "0"); len(msgs) != 0 {
+				t.Errorf("expected no messages for a channel without retention got %v", msgs)
+			}
+		})
+	}
+}
+
+func TestCacheSessionAuth(t *testing.T) {
+	tests := []suite{
+		{name: "session auth with redis cache", cache: redisCache},
+		{name: "session auth with dev mem cache", cache: devCache},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			auth := adminAuth
+			auth.SessionID = "session-" + tc.name
+
+			if err := SetSessionAuth(tc.cache, "active-token", auth, time.Now().Add(time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			if err := SetSessionAuth(tc.cache, "expired-token", auth, time.Now().Add(-time.Second)); err != nil {
+				t.Fatal(err)
+			}
+
+			if a, err := GetSessionAuth(tc.cache, "active-token"); err != nil {
+				t.Fatal(err)
+			} else if a.UserID != auth.UserID || a.SessionID != auth.SessionID {
+				t.Errorf("expected %v got %v", auth, a)
+			}
+
+			if _, err := GetSessionAuth(tc.cache, "expired-token"); err != ErrSessionEnded {
+				t.Errorf("expected the expired token to be refused got %v", err)
+			}
+
+			if err := RevokeSession(tc.cache, auth.SessionID); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := GetSessionAuth(tc.cache, "active-token"); err != ErrSessionEnded {
+				t.Errorf("expected the revoked session to be refused got %v", err)
+			}
+		})
+	}
+}
+
+func TestCacheDevExpires(t *testing.T) {
+	if err := devCache.Set("expiring-key", "value"); err != nil {
+		t.Fatal(err)
+	}
+
+	devCache.m.Lock()
+	devCache.expires["expiring-key"] = time.Now().Add(-time.Second)
+	devCache.swept = time.Time{}
+	devCache.m.Unlock()
+
+	if _, err := devCache.Get("expiring-key"); err == nil {
+		t.Error("expected the expired key to be gone")
+	}
+
+	if err := devCache.Set("other-key", "value"); err != nil {
+		t.Fatal(err)
+	}
+
+	devCache.m.RLock()
+	_, ok := devCache.data["expiring-key"]
+	devCache.m.RUnlock()
+	if ok {
+		t.Error("expected the expired key to be removed from memory")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/staticbackendhq/core/cache/observer"
 	"github.com/staticbackendhq/core/internal"
@@ -12,18 +13,32 @@ import (
 	"github.com/staticbackendhq/core/model"
 )
 
+// devTTL is how long the values set in the dev cache are kept, the same
+// as the Redis cache
+const devTTL = 12 * time.Hour
+
 // CacheDev used in local dev mode and is memory-based
 type CacheDev struct {
 	data     map[string]string
+	expires  map[string]time.Time
+	swept    time.Time
+	hashes   map[string]map[string]string
+	streams  map[string][]model.Command
 	log      *logger.Logger
 	observer observer.Observer
 	m        *sync.RWMutex
+
+	rules     RulesFinder
+	retention RetentionFinder
 }
 
 // NewDevCache returns a memory-based Volatilizer
 func NewDevCache(log *logger.Logger) *CacheDev {
 	return &CacheDev{
 		data:     make(map[string]string),
+		expires:  make(map[string]time.Time),
+		hashes:   make(map[string]map[string]string),
+		streams:  make(map[string][]model.Command),
 		observer: observer.NewObserver(log),
 		log:      log,
 		m:        &sync.RWMutex{},
@@ -36,21 +51,46 @@ func (d *CacheDev) Get(key string) (val string, err error) {
 	defer d.m.RUnlock()
 
 	val, ok := d.data[key]
-	if !ok {
-		err = errors.New("key not found in cache")
+	if !ok || d.expired(key) {
+		return "", errors.New("key not found in cache")
 	}
 	return
 }
 
-// Set sets a value for a key
+// Set sets a value for a key, it expires after 12 hours
 func (d *CacheDev) Set(key string, value string) error {
 	d.m.Lock()
 	defer d.m.Unlock()
 
 	d.data[key] = value
+	d.expires[key] = time.Now().Add(devTTL)
+
+	d.sweep()
 	return nil
 }
 
+// expired returns true if the value of a key has expired
+func (d *CacheDev) expired(key string) bool {
+	exp, ok := d.expires[key]
+	return ok && time.Now().After(exp)
+}
+
+// sweep removes the expired values, at most once per minute
+func (d *CacheDev) sweep() {
+	now := time.Now()
+	if now.Sub(d.swept) < time.Minute {
+		return
+	}
+
+	d.swept = now
+	for key, exp := range d.expires {
+		if now.After(exp) {
+			delete(d.data, key)
+			delete(d.expires, key)
+		}
+	}
+}
+
 // GetTyped retrives the value for a key and unmarshal the JSON value into the
 func (d *CacheDev) GetTyped(key string, v any) error {
 	val, err := d.Get(key)
@@ -71,27 +111,81 @@ func (d *CacheDev) SetTyped(key string, v any) error {
 	return d.Set(key, string(b))
 }
 
-// Inc increments a value (non-atomic)
+// Inc increments a value (atomic)
 func (d *CacheDev) Inc(key string, by int64) (n int64, err error) {
-	if err = d.GetTyped(key, &n); err != nil {
-		if err.Error() == "key not found in cache" {
-			n = 0
-		} else {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	if d.expired(key) {
+		delete(d.data, key)
+		delete(d.expires, key)
+	}
+
+	if val, ok := d.data[key]; ok {
+		if err = json.Unmarshal([]byte(val), &n); err != nil {
 			return
 		}
 	}
 
 	n += by
 
-	err = d.SetTyped(key, n)
+	d.data[key] = fmt.Sprintf("%d", n)
 	return
 }
 
-// Dec decrements a value (non-atomic)
+// Dec decrements a value (atomic)
 func (d *CacheDev) Dec(key string, by int64) (int64, error) {
 	return d.Inc(key, -1*by)
 }
 
+// HashSet sets the value of a field in a hash
+func (d *CacheDev) HashSet(key, field, value string) error {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	h, ok := d.hashes[key]
+	if !ok {
+		h = make(map[string]string)
+		d.hashes[key] = h
+	}
+
+	h[field] = value
+	return nil
+}
+
+// HashDel removes a field from a hash
+func (d *CacheDev) HashDel(key, field string) (bool, error) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	h, ok := d.hashes[key]
+	if !ok {
+		return false, nil
+	}
+
+	if _, ok := h[field]; !ok {
+		return false, nil
+	}
+
+	delete(h, field)
+	if len(h) == 0 {
+		delete(d.hashes, key)
+	}
+	return true, nil
+}
+
+// HashGetAll returns a copy of all the fields and values of a hash
+func (d *CacheDev) HashGetAll(key string) (map[string]string, error) {
+	d.m.RLock()
+	defer d.m.RUnlock()
+
+	fields := make(map[string]string)
+	for k, v := range d.hashes[key] {
+		fields[k] = v
+	}
+	return fields, nil
+}
+
 // Subscribe subscribes to a topic to receive messages on system/user events
 func (d *CacheDev) Subscribe(send chan model.Command, token, channel string, close chan bool) {
 	pubsub := d.observer.Subscribe(channel)
@@ -109,15 +203,20 @@ func (d *CacheDev) Subscribe(send chan model.Command, token, channel string, clo
 				return
 			}
 
-			// TODO: this will need more thinking
-			if msg.Type == model.MsgTypeChanIn {
-				msg.Type = model.MsgTypeChanOut
-			} else if msg.IsSystemEvent {
-
-			} else if msg.IsDBEvent() && !d.HasPermission(token, channel, msg.Data) {
+			msg, ok := deliverable(msg, token, channel, d.HasPermission)
+			if !ok {
 				continue
 			}
-			send <- msg
+
+			// the receiver might be gone or too slow, we stop waiting
+			// once the subscription is closed
+			select {
+			case send <- msg:
+			case <-close:
+				_ = pubsub.Close()
+				_ = d.observer.Unsubscribe(channel, pubsub)
+				return
+			}
 		case <-close:
 			_ = pubsub.Close()
 			_ = d.observer.Unsubscribe(channel, pubsub)
@@ -129,6 +228,14 @@ func (d *CacheDev) Subscribe(send chan model.Command, token, channel string, clo
 // Publish sends a message and all subscribers will receive it if they're
 // subscribed to that topic
 func (d *CacheDev) Publish(msg model.Command) error {
+	// the message is retained even if there's no subscriber, they'll
+	// receive it when they reconnect
+	if r, ok, err := findRetention(d.retention, msg); err != nil {
+		d.log.Error().Err(err).Msgf("error getting the retention of %s", msg.Channel)
+	} else if ok {
+		msg.ID = d.retain(msg, r)
+	}
+
 	b, err := json.Marshal(msg)
 	if err != nil {
 		return err
@@ -165,33 +272,79 @@ func (d *CacheDev) Publish(msg model.Command) error {
 // PublishDocument publishes a database update message (created, updated, deleted)
 // All subscribers will get notified
 func (d *CacheDev) PublishDocument(auth model.Auth, dbName, channel, typ string, v any) {
-	b, err := json.Marshal(v)
+	msg, err := documentEvent(auth, dbName, channel, typ, v)
 	if err != nil {
 		d.log.Error().Err(err).Msg("error publishing db doc")
 		return
 	}
 
-	msg := model.Command{
-		Channel: channel,
-		Data:    string(b),
-		Type:    typ,
-		Auth:    auth,
-		Base:    dbName,
-	}
-
 	if err := d.Publish(msg); err != nil {
 		d.log.Error().Err(err).Msg("unable to publish db doc events")
 	}
 }
 
+// SetRulesFinder sets how the collection rules are found when checking the
+// permissions of database events
+func (d *CacheDev) SetRulesFinder(fn RulesFinder) {
+	d.rules = fn
+}
+
+// SetRetentionFinder sets how the channels' retention is found when
+// publishing messages
+func (d *CacheDev) SetRetentionFinder(fn RetentionFinder) {
+	d.retention = fn
+}
+
+// retain appends a message to the channel's history and returns its ID, the
+// IDs are generated like the Redis stream ones
+func (d *CacheDev) retain(msg model.Command, r model.ChannelRetention) string {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	key := historyKey(msg.Base, msg.Channel)
+	msgs := d.streams[key]
+
+	id := eventID{ms: time.Now().UnixMilli()}
+	if n := len(msgs); n > 0 {
+		if last, err := parseEventID(msgs[n-1].ID); err == nil && !id.after(last) {
+			id = eventID{ms: last.ms, seq: last.seq + 1}
+		}
+	}
+
+	msg.ID = id.String()
+	msgs = append(msgs, msg)
+
+	if max := maxRetained(r); len(msgs) > max {
+		msgs = append([]model.Command{}, msgs[len(msgs)-max:]...)
+	}
+
+	d.streams[key] = msgs
+	return msg.ID
+}
+
+// History returns the messages retained for a channel that were published
+// after the lastID, they're filtered like the subscriptions' messages
+func (d *CacheDev) History(token, dbName, channel, lastID string) ([]model.Command, error) {
+	r, ok, err := findRetention(d.retention, model.Command{Base: dbName, Channel: channel})
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	d.m.RLock()
+	msgs := append([]model.Command{}, d.streams[historyKey(dbName, channel)]...)
+	d.m.RUnlock()
+
+	return replayable(msgs, token, channel, lastID, r, d.HasPermission)
+}
+
 // HasPermission determines if a session token has permission to a collection
-func (d *CacheDev) HasPermission(token, repo, payload string) bool {
+func (d *CacheDev) HasPermission(token, dbName, repo, payload string) bool {
 	if repo == "sbsys" {
 		return true
 	}
 
-	var me model.Auth
-	if err := d.GetTyped(token, &me); err != nil {
+	me, err := GetSessionAuth(d, token)
+	if err != nil {
 		return false
 	}
 
@@ -202,6 +355,10 @@ func (d *CacheDev) HasPermission(token, repo, payload string) bool {
 		return false
 	}
 
+	if allowed, ruled := ruleAllows(d.rules, me, dbName, repo, docs); ruled {
+		return allowed
+	}
+
 	switch internal.ReadPermission(repo) {
 	case internal.PermGroup:
 		acctID, ok := docs["accountId"]
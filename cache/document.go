@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"encoding/json"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+// documentEvent returns the message of a database event. The changed fields
+// of a model.DocumentUpdate are set on the message and its document is the
+// message's data.
+func documentEvent(auth model.Auth, dbName, channel, typ string, v any) (model.Command, error) {
+	var fields []string
+	if u, ok := v.(model.DocumentUpdate); ok {
+		v, fields = u.Document, u.Fields
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return model.Command{}, err
+	}
+
+	msg := model.Command{
+		Channel: channel,
+		Data:    string(b),
+		Type:    typ,
+		Auth:    auth,
+		Base:    dbName,
+		Fields:  fields,
+	}
+	return msg, nil
+}
@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+// MaxRetainedMessages is the maximum number of messages kept per channel,
+// including for channels retaining their messages for some time
+const MaxRetainedMessages = 1000
+
+// ErrInvalidEventID is returned when replaying from an invalid event ID
+var ErrInvalidEventID = errors.New("invalid last event id")
+
+// RetentionFinder returns the retention of a channel, the messages published
+// to a channel with a retention are kept for replay
+type RetentionFinder func(dbName, channel string) (retention model.ChannelRetention, ok bool, err error)
+
+func historyKey(dbName, channel string) string {
+	return fmt.Sprintf("history:%s:%s", dbName, channel)
+}
+
+// findRetention returns the retention of the message's channel. The presence
+// events are not retained, they only make sense while they happen.
+func findRetention(find RetentionFinder, msg model.Command) (model.ChannelRetention, bool, error) {
+	if find == nil || len(msg.Base) == 0 || msg.IsSystemEvent || msg.Channel == "sbsys" {
+		return model.ChannelRetention{}, false, nil
+	}
+
+	switch msg.Type {
+	case model.MsgTypeJoined,
+		model.MsgTypePresenceJoined,
+		model.MsgTypePresenceLeft,
+		model.MsgTypePresenceTimeout,
+		model.MsgTypePresenceUpdated:
+		return model.ChannelRetention{}, false, nil
+	}
+
+	return find(msg.Base, msg.Channel)
+}
+
+// maxRetained returns the number of messages kept for a retention
+func maxRetained(r model.ChannelRetention) int {
+	if r.MaxMessages > 0 && r.MaxMessages < MaxRetainedMessages {
+		return r.MaxMessages
+	}
+	return MaxRetainedMessages
+}
+
+// eventID is the ID of a retained message, it's ordered by time across all
+// channels, like Redis stream IDs: <unix milliseconds>-<sequence>
+type eventID struct {
+	ms  int64
+	seq int64
+}
+
+func parseEventID(s string) (id eventID, err error) {
+	ms, seq, found := strings.Cut(s, "-")
+
+	id.ms, err = strconv.ParseInt(ms, 10, 64)
+	if err != nil || id.ms < 0 {
+		return id, ErrInvalidEventID
+	}
+
+	if found {
+		id.seq, err = strconv.ParseInt(seq, 10, 64)
+		if err != nil || id.seq < 0 {
+			return id, ErrInvalidEventID
+		}
+	}
+	return id, nil
+}
+
+func (id eventID) String() string {
+	return fmt.Sprintf("%d-%d", id.ms, id.seq)
+}
+
+func (id eventID) after(other eventID) bool {
+	return id.ms > other.ms || (id.ms == other.ms && id.seq > other.seq)
+}
+
+// replayable returns the retained messages published after the last ID that
+// did not expire and that the subscriber can receive, ordered by ID
+func replayable(msgs []model.Command, token, channel, lastID string, r model.ChannelRetention, allowed func(token, dbName, repo, payload string) bool) ([]model.Command, error) {
+	last, err := parseEventID(lastID)
+	if err != nil {
+		return nil, err
+	}
+
+	var oldest eventID
+	if r.MaxMinutes > 0 {
+		oldest.ms = time.Now().Add(-time.Duration(r.MaxMinutes) * time.Minute).UnixMilli()
+	}
+
+	replay := make([]model.Command, 0)
+	for _, msg := range msgs {
+		id, err := parseEventID(msg.ID)
+		if err != nil {
+			return nil, err
+		} else if !id.after(last) || oldest.after(id) {
+			continue
+		}
+
+		if msg, ok := deliverable(msg, token, channel, allowed); ok {
+			replay = append(replay, msg)
+		}
+	}
+	return replay, nil
+}
+
+// deliverable prepares a message for a subscriber, the database events are
+// only delivered if the subscriber is allowed to read the document
+func deliverable(msg model.Command, token, channel string, allowed func(token, dbName, repo, payload string) bool) (model.Command, bool) {
+	// TODO: this will need more thinking
+	if msg.Type == model.MsgTypeChanIn {
+		msg.Type = model.MsgTypeChanOut
+	} else if msg.IsSystemEvent {
+
+	} else if msg.IsDBEvent() && !allowed(token, msg.Base, channel, msg.Data) {
+		return msg, false
+	}
+	return msg, true
+}
@@ -23,11 +23,15 @@ type Subscriber interface {
 type memSubscriber struct {
 	closed bool
 	msgCh  chan interface{}
+	// done is closed instead of msgCh so pending publishes never send on a
+	// closed channel
+	done chan struct{}
+	mx   sync.Mutex
 }
 
 func NewSubscriber() *memSubscriber {
 	ch := make(chan interface{})
-	sub := &memSubscriber{closed: false, msgCh: ch}
+	sub := &memSubscriber{closed: false, msgCh: ch, done: make(chan struct{})}
 	return sub
 }
 
@@ -36,10 +40,13 @@ func (ps *memSubscriber) Channel() <-chan interface{} {
 }
 
 func (ps *memSubscriber) Close() error {
+	ps.mx.Lock()
+	defer ps.mx.Unlock()
+
 	if ps.closed {
 		return errors.New("channel is already closed")
 	}
-	close(ps.msgCh)
+	close(ps.done)
 	ps.closed = true
 	return nil
 }
@@ -84,6 +91,8 @@ func (o *memObserver) Publish(channel string, msg interface{}) error {
 				if !timer.Stop() {
 					<-timer.C
 				}
+			case <-msub.done:
+				timer.Stop()
 			case <-timer.C:
 				o.log.Error().Msg("the previous message is not read; dropping this message")
 				timer.Stop()
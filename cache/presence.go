@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+const (
+	// PresenceHeartbeat is how often an instance refreshes the presence of
+	// its connections
+	PresenceHeartbeat = 30 * time.Second
+	// PresenceTimeout is how long a member stays present without being
+	// refreshed, i.e. when its instance stopped. The timed out members are
+	// only removed when the presence is read, which every instance does for
+	// its joined channels each PresenceHeartbeat, so a presence_timeout
+	// event is published up to PresenceTimeout + PresenceHeartbeat (2
+	// minutes) after the member's last refresh.
+	PresenceTimeout = 3 * PresenceHeartbeat
+)
+
+func presenceKey(dbName, channel string) string {
+	return fmt.Sprintf("presence:%s:%s", dbName, channel)
+}
+
+// SetPresence adds or refreshes a member of a channel
+func SetPresence(v Volatilizer, dbName, channel string, m model.PresenceMember) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	return v.HashSet(presenceKey(dbName, channel), m.ConnectionID, string(b))
+}
+
+// RemovePresence removes a member from a channel, it returns false if the
+// member was not present
+func RemovePresence(v Volatilizer, dbName, channel, connectionID string) (bool, error) {
+	return v.HashDel(presenceKey(dbName, channel), connectionID)
+}
+
+// GetPresence returns the members of a channel ordered by join time. The
+// members that timed out are removed and a presence_timeout event is
+// published to the channel for each of them. This is the only place the
+// timeouts are detected, a channel nobody reads nor refreshes keeps its
+// timed out members until then.
+func GetPresence(v Volatilizer, dbName, channel string) (model.Presence, error) {
+	p := model.Presence{Channel: channel, Members: make([]model.PresenceMember, 0)}
+
+	fields, err := v.HashGetAll(presenceKey(dbName, channel))
+	if err != nil {
+		return p, err
+	}
+
+	for id, val := range fields {
+		var m model.PresenceMember
+		if err := json.Unmarshal([]byte(val), &m); err != nil {
+			return p, err
+		}
+
+		if time.Since(m.LastSeen) < PresenceTimeout {
+			p.Members = append(p.Members, m)
+			continue
+		}
+
+		// only the instance removing the member publishes the event
+		removed, err := RemovePresence(v, dbName, channel, id)
+		if err != nil {
+			return p, err
+		} else if removed {
+			if err := PublishPresence(v, dbName, channel, model.MsgTypePresenceTimeout, m); err != nil {
+				return p, err
+			}
+		}
+	}
+
+	sort.Slice(p.Members, func(i, j int) bool {
+		return p.Members[i].Joined.Before(p.Members[j].Joined)
+	})
+
+	p.Count = len(p.Members)
+	return p, nil
+}
+
+// PublishPresence publishes a presence event for a member to the channel
+func PublishPresence(v Volatilizer, dbName, channel, typ string, m model.PresenceMember) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	msg := model.Command{
+		SID:     model.SystemID,
+		Type:    typ,
+		Data:    string(b),
+		Channel: channel,
+		Base:    dbName,
+	}
+	return v.Publish(msg)
+}
@@ -0,0 +1,26 @@
+package cache
+
+import (
+	"strings"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// ruleAllows checks the document against the read rule of the event's
+// collection, ruled is false when the collection has no read rule and the
+// permissions from its name apply
+func ruleAllows(find RulesFinder, me model.Auth, dbName, repo string, doc map[string]any) (allowed bool, ruled bool) {
+	if find == nil || me.Role == 100 {
+		return false, false
+	}
+
+	filter, ok, err := find(me, dbName, strings.TrimPrefix(repo, "db-"))
+	if err != nil {
+		// the event is not sent when the rules cannot be checked
+		return false, true
+	} else if !ok {
+		return false, false
+	}
+	return database.Match(doc, filter), true
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"errors"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+const revokedSessionPrefix = "revoked-session:"
+
+// ErrSessionEnded is returned when the session of a cached token was
+// revoked or expired
+var ErrSessionEnded = errors.New("your session has ended, please sign in again")
+
+// sessionAuth is the authentication cached for a session token
+type sessionAuth struct {
+	model.Auth
+	Expires time.Time `json:"expires"`
+}
+
+// RevokeSession adds a session to the revocation list, the access tokens
+// issued for it are rejected. The access tokens are short-lived so they
+// expire before the entry does.
+func RevokeSession(v Volatilizer, sessionID string) error {
+	return v.Set(revokedSessionPrefix+sessionID, "1")
+}
+
+// IsSessionRevoked returns true if a session is in the revocation list
+func IsSessionRevoked(v Volatilizer, sessionID string) bool {
+	_, err := v.Get(revokedSessionPrefix + sessionID)
+	return err == nil
+}
+
+// SetSessionAuth caches the authentication of a session token along with
+// the time the token expires. A zero expires never expires.
+func SetSessionAuth(v Volatilizer, token string, auth model.Auth, expires time.Time) error {
+	return v.SetTyped(token, sessionAuth{Auth: auth, Expires: expires})
+}
+
+// GetSessionAuth returns the cached authentication of a session token. It
+// returns ErrSessionEnded if the token expired or its session was revoked
+// since it was cached.
+func GetSessionAuth(v Volatilizer, token string) (model.Auth, error) {
+	var sa sessionAuth
+	if err := v.GetTyped(token, &sa); err != nil {
+		return model.Auth{}, err
+	}
+
+	if !sa.Expires.IsZero() && time.Now().After(sa.Expires) {
+		return model.Auth{}, ErrSessionEnded
+	}
+
+	if len(sa.SessionID) > 0 && IsSessionRevoked(v, sa.SessionID) {
+		return model.Auth{}, ErrSessionEnded
+	}
+
+	return sa.Auth, nil
+}
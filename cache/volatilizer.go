@@ -1,10 +1,18 @@
 package cache
 
-import "github.com/staticbackendhq/core/model"
+import (
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
 
 // PublishDocumentEvent used to publish database events
 type PublishDocumentEvent func(auth model.Auth, dbName, channel, typ string, v interface{})
 
+// RulesFinder returns the filter from the read rule of a collection a
+// database event must satisfy to be sent to a subscriber, ok is false when
+// the collection has no read rule and the permissions from its name apply
+type RulesFinder func(auth model.Auth, dbName, col string) (filter database.Filter, ok bool, err error)
+
 // Volatilizer is the cache and pub/sub interface
 type Volatilizer interface {
 	// Get returns a string value from a key
@@ -19,12 +27,27 @@ type Volatilizer interface {
 	Inc(key string, by int64) (int64, error)
 	// Dec decrements a value for a key
 	Dec(key string, by int64) (int64, error)
+	// HashSet sets the value of a field in a hash
+	HashSet(key, field, value string) error
+	// HashDel removes a field from a hash, returns false if it did not exist
+	HashDel(key, field string) (bool, error)
+	// HashGetAll returns all the fields and values of a hash
+	HashGetAll(key string) (map[string]string, error)
 	// Subscribe subscribes to a pub/sub channel
 	Subscribe(send chan model.Command, token, channel string, close chan bool)
 	// Publish publishes a message to a channel
 	Publish(msg model.Command) error
 	// PublishDocument publish a database message to a channel
 	PublishDocument(auth model.Auth, dbname, channel, typ string, v any)
+	// SetRulesFinder sets how the collection rules are found when checking
+	// the permissions of database events
+	SetRulesFinder(fn RulesFinder)
+	// SetRetentionFinder sets how the channels' retention is found when
+	// publishing messages
+	SetRetentionFinder(fn RetentionFinder)
+	// History returns the messages retained for a channel that were
+	// published after the lastID
+	History(token, dbName, channel, lastID string) ([]model.Command, error)
 	// QueueWork add a work queue item
 	QueueWork(key, value string) error
 	// DequeueWork dequeue work item (if available)
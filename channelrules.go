@@ -0,0 +1,48 @@
+package staticbackend
+
+import (
+	"net/http"
+
+	"github.com/staticbackendhq/core/backend"
+	"github.com/staticbackendhq/core/middleware"
+	"github.com/staticbackendhq/core/model"
+)
+
+// channelRules returns or replaces the authorization rules of the realtime
+// channels
+func channelRules(w http.ResponseWriter, r *http.Request) {
+	conf, _, err := middleware.Extract(r, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		settings, err := backend.DB.GetSettings(conf.Name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rules := settings.ChannelRules
+		if rules == nil {
+			rules = make([]model.ChannelRule, 0)
+		}
+
+		respond(w, http.StatusOK, rules)
+		return
+	}
+
+	var rules []model.ChannelRule
+	if err := parseBody(r.Body, &rules); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := backend.SetChannelRules(conf.Name, rules); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	respond(w, http.StatusOK, true)
+}
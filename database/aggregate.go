@@ -0,0 +1,99 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Aggregation operators computed for each group
+const (
+	MetricCount         = "count"
+	MetricCountDistinct = "countDistinct"
+	MetricSum           = "sum"
+	MetricAvg           = "avg"
+	MetricMin           = "min"
+	MetricMax           = "max"
+)
+
+// Metric is a value computed for each group of documents and returned under
+// its Name. The count metric does not need a Field. The sum, avg, min and max
+// metrics only use number values and countDistinct ignores missing and null
+// values.
+type Metric struct {
+	Name  string `json:"name"`
+	Op    string `json:"op"`
+	Field string `json:"field"`
+}
+
+// ValidateAggregate returns an error if the group by fields or the metrics
+// are invalid. Metric names must be unique and cannot be a group by field
+// since both are returned as keys of the same row.
+func ValidateAggregate(groupBy []string, metrics []Metric) error {
+	if len(metrics) == 0 {
+		return errors.New("at least one metric is required")
+	}
+
+	names := make(map[string]bool)
+	for _, field := range groupBy {
+		if !validField.MatchString(field) {
+			return fmt.Errorf("invalid group by field: %s", field)
+		} else if names[field] {
+			return fmt.Errorf("duplicate group by field: %s", field)
+		}
+
+		names[field] = true
+	}
+
+	for _, m := range metrics {
+		switch m.Op {
+		case MetricCount:
+		case MetricCountDistinct, MetricSum, MetricAvg, MetricMin, MetricMax:
+			if !validField.MatchString(m.Field) {
+				return fmt.Errorf("invalid field for metric %s: %s", m.Name, m.Field)
+			}
+		default:
+			return fmt.Errorf("invalid metric operator: %s", m.Op)
+		}
+
+		if !validProjectionField.MatchString(m.Name) {
+			return fmt.Errorf("invalid metric name: %s", m.Name)
+		} else if names[m.Name] {
+			return fmt.Errorf("duplicate metric name: %s", m.Name)
+		}
+
+		names[m.Name] = true
+	}
+	return nil
+}
+
+// MetricValue normalizes the value computed by a database engine. Counts are
+// returned as int64, a sum as float64 (0 when there's no number) and the
+// other metrics as float64 or nil when there's no number.
+func MetricValue(op string, v any) any {
+	rv := reflect.ValueOf(v)
+
+	var f float64
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f = float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f = float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		f = rv.Float()
+	default:
+		switch op {
+		case MetricCount, MetricCountDistinct:
+			return int64(0)
+		case MetricSum:
+			return float64(0)
+		}
+		return nil
+	}
+
+	switch op {
+	case MetricCount, MetricCountDistinct:
+		return int64(f)
+	}
+	return f
+}
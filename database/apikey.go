@@ -0,0 +1,69 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// APIKeyPrefix prefixes the API keys so they're distinguishable from the
+// session tokens
+const APIKeyPrefix = "sbk_"
+
+const (
+	// ScopeFunctionExec allows executing the server-side functions
+	ScopeFunctionExec = "fn:exec"
+	// ScopeStorageUpload allows uploading files
+	ScopeStorageUpload = "storage:upload"
+)
+
+// ValidateScopes validates the API key scopes, they're read:collection,
+// write:collection, fn:exec and storage:upload. The * collection allows
+// all collections.
+func ValidateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("at least one scope is required")
+	}
+
+	for _, s := range scopes {
+		if s == ScopeFunctionExec || s == ScopeStorageUpload {
+			continue
+		}
+
+		action, col, ok := strings.Cut(s, ":")
+		if !ok || (action != "read" && action != "write") {
+			return fmt.Errorf("invalid scope %q, use read:collection, write:collection, fn:exec or storage:upload", s)
+		} else if col != "*" && !validExpandCollection.MatchString(col) {
+			return fmt.Errorf("invalid collection in scope %q", s)
+		}
+	}
+	return nil
+}
+
+// NewAPIKey returns the key given to the user and the hash of its secret
+// that's stored, the secret is not recoverable from the hash
+func NewAPIKey(id, secret string) (key, hash string) {
+	return fmt.Sprintf("%s%s.%s", APIKeyPrefix, id, secret), HashAPIKeySecret(secret)
+}
+
+// ParseAPIKey returns the ID and secret of a key
+func ParseAPIKey(key string) (id, secret string, ok bool) {
+	return parseSecretKey(APIKeyPrefix, key)
+}
+
+func parseSecretKey(prefix, key string) (id, secret string, ok bool) {
+	if !strings.HasPrefix(key, prefix) {
+		return "", "", false
+	}
+
+	id, secret, ok = strings.Cut(strings.TrimPrefix(key, prefix), ".")
+	return id, secret, ok && len(id) > 0 && len(secret) > 0
+}
+
+// HashAPIKeySecret returns the hex encoded SHA-256 of a key's secret. Secrets
+// are random so they don't need a slow password hash.
+func HashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,41 @@
+package database
+
+import "testing"
+
+func TestParseAPIKey(t *testing.T) {
+	key, hash := NewAPIKey("key-id", "secret")
+
+	id, secret, ok := ParseAPIKey(key)
+	if !ok {
+		t.Fatalf("expected %s to be parsed", key)
+	} else if id != "key-id" || secret != "secret" {
+		t.Errorf("expected key-id and secret got %s and %s", id, secret)
+	} else if HashAPIKeySecret(secret) != hash {
+		t.Error("expected the secret's hash to match")
+	}
+
+	for _, key := range []string{"key-id.secret", "sbk_key-id", "sbk_.secret", "sbk_key-id."} {
+		if _, _, ok := ParseAPIKey(key); ok {
+			t.Errorf("expected %s to be invalid", key)
+		}
+	}
+}
+
+func TestValidateScopes(t *testing.T) {
+	if err := ValidateScopes([]string{"read:tasks", "write:*", ScopeFunctionExec, ScopeStorageUpload}); err != nil {
+		t.Error(err)
+	}
+
+	invalid := [][]string{
+		nil,
+		{"read"},
+		{"delete:tasks"},
+		{"read:sb-tasks"},
+		{"fn:deploy"},
+	}
+	for _, scopes := range invalid {
+		if err := ValidateScopes(scopes); err == nil {
+			t.Errorf("expected an error for %v", scopes)
+		}
+	}
+}
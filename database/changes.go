@@ -0,0 +1,27 @@
+package database
+
+import (
+	"sort"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+// ChangedFields returns the sorted names of the fields of an update. The
+// version and updated fields are omitted since they change on every update.
+func ChangedFields(update map[string]any) []string {
+	fields := make([]string, 0, len(update))
+	for k := range update {
+		if k == FieldVersion || k == FieldUpdated {
+			continue
+		}
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Updated returns the event published for an updated document with the
+// fields that changed
+func Updated(doc any, fields []string) model.DocumentUpdate {
+	return model.DocumentUpdate{Document: doc, Fields: fields}
+}
@@ -0,0 +1,20 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChangedFields(t *testing.T) {
+	update := map[string]any{
+		"title":      "renamed",
+		"done":       true,
+		FieldVersion: 2,
+		FieldUpdated: "2024-01-01",
+	}
+
+	expected := []string{"done", "title"}
+	if fields := ChangedFields(update); !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected the fields %v got %v", expected, fields)
+	}
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+// ErrChannelDenied is returned when a connection is not allowed to join or
+// write to a channel
+var ErrChannelDenied = errors.New("permission denied by the channel rules")
+
+var channelPlaceholder = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// compileChannelPattern returns the expression matching the channels of a
+// pattern and the names of its placeholders
+func compileChannelPattern(pattern string) (*regexp.Regexp, []string, error) {
+	var expr strings.Builder
+	var names []string
+
+	expr.WriteString("^")
+
+	last := 0
+	for _, loc := range channelPlaceholder.FindAllStringSubmatchIndex(pattern, -1) {
+		literal := pattern[last:loc[0]]
+		if strings.ContainsAny(literal, "{}") {
+			return nil, nil, fmt.Errorf("invalid placeholder in the channel pattern %s", pattern)
+		}
+
+		name := pattern[loc[2]:loc[3]]
+		for _, n := range names {
+			if n == name {
+				return nil, nil, fmt.Errorf("the placeholder {%s} is repeated in the channel pattern %s", name, pattern)
+			}
+		}
+
+		expr.WriteString(regexp.QuoteMeta(literal))
+		expr.WriteString("(.+?)")
+		names = append(names, name)
+		last = loc[1]
+	}
+
+	if strings.ContainsAny(pattern[last:], "{}") {
+		return nil, nil, fmt.Errorf("invalid placeholder in the channel pattern %s", pattern)
+	}
+
+	expr.WriteString(regexp.QuoteMeta(pattern[last:]))
+	expr.WriteString("$")
+
+	re, err := regexp.Compile(expr.String())
+	return re, names, err
+}
+
+// ValidateChannelRules validates the channel patterns and the clauses of the
+// read and write rules
+func ValidateChannelRules(rules []model.ChannelRule) error {
+	for _, rule := range rules {
+		if len(rule.Pattern) == 0 {
+			return errors.New("the channel pattern is required")
+		} else if _, _, err := compileChannelPattern(rule.Pattern); err != nil {
+			return err
+		}
+
+		r := model.Rules{Read: rule.Read, Write: rule.Write}
+		if err := ValidateRules(r); err != nil {
+			return fmt.Errorf("channel %s: %w", rule.Pattern, err)
+		}
+	}
+	return nil
+}
+
+// MatchChannel returns the first rule matching the channel and the values
+// of its pattern's placeholders
+func MatchChannel(rules []model.ChannelRule, channel string) (model.ChannelRule, map[string]any, bool) {
+	for _, rule := range rules {
+		re, names, err := compileChannelPattern(rule.Pattern)
+		if err != nil {
+			continue
+		}
+
+		m := re.FindStringSubmatch(channel)
+		if m == nil {
+			continue
+		}
+
+		params := make(map[string]any, len(names))
+		for i, name := range names {
+			params[name] = m[i+1]
+		}
+		return rule, params, true
+	}
+	return model.ChannelRule{}, nil, false
+}
+
+// CanAccessChannel returns true if the caller satisfies the channel's read
+// (join) or write rule. The rules are evaluated against the placeholders'
+// values, a non-empty rule requires an authenticated caller.
+func CanAccessChannel(rule model.ChannelRule, params map[string]any, write bool, auth model.Auth) (bool, error) {
+	clauses := rule.Read
+	if write {
+		clauses = rule.Write
+	}
+
+	if len(clauses) == 0 {
+		return true, nil
+	} else if len(auth.UserID) == 0 {
+		return false, nil
+	}
+
+	filter, err := ResolveRule(clauses, auth)
+	if err != nil {
+		return false, err
+	}
+	return Match(params, filter), nil
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+func TestMatchChannel(t *testing.T) {
+	rules := []model.ChannelRule{
+		{Pattern: "room-{id}"},
+		{Pattern: "team.{teamId}.{topic}"},
+		{Pattern: "lobby"},
+	}
+
+	tests := []struct {
+		channel string
+		pattern string
+		params  map[string]any
+	}{
+		{"room-abc", "room-{id}", map[string]any{"id": "abc"}},
+		{"room-a-b", "room-{id}", map[string]any{"id": "a-b"}},
+		{"team.red.news", "team.{teamId}.{topic}", map[string]any{"teamId": "red", "topic": "news"}},
+		{"lobby", "lobby", map[string]any{}},
+		{"room-", "", nil},
+		{"teamxred.news", "", nil},
+		{"lobby-2", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.channel, func(t *testing.T) {
+			rule, params, ok := MatchChannel(rules, tt.channel)
+			if len(tt.pattern) == 0 {
+				if ok {
+					t.Fatalf("expected no rule to match, got %s", rule.Pattern)
+				}
+				return
+			}
+
+			if !ok {
+				t.Fatalf("expected the rule %s to match", tt.pattern)
+			} else if rule.Pattern != tt.pattern {
+				t.Errorf("expected the rule %s got %s", tt.pattern, rule.Pattern)
+			} else if len(params) != len(tt.params) {
+				t.Fatalf("expected the params %v got %v", tt.params, params)
+			}
+
+			for k, v := range tt.params {
+				if params[k] != v {
+					t.Errorf("expected %s to be %v got %v", k, v, params[k])
+				}
+			}
+		})
+	}
+}
+
+func TestCanAccessChannel(t *testing.T) {
+	owner := model.Auth{AccountID: "acct1", UserID: "user1"}
+	other := model.Auth{AccountID: "acct1", UserID: "user2"}
+	admin := model.Auth{AccountID: "acct1", UserID: "user3", Role: 100}
+
+	rule := model.ChannelRule{
+		Pattern: "private-{id}",
+		Read:    parseRule(t, `[["or", [["id", "=", "auth.userId"], ["auth.role", ">=", 100]]]]`),
+		Write:   parseRule(t, `[["id", "=", "auth.userId"]]`),
+	}
+
+	tests := []struct {
+		name    string
+		write   bool
+		auth    model.Auth
+		allowed bool
+	}{
+		{"owner reads", false, owner, true},
+		{"owner writes", true, owner, true},
+		{"other reads", false, other, false},
+		{"other writes", true, other, false},
+		{"admin reads", false, admin, true},
+		{"admin writes", true, admin, false},
+		{"anonymous reads", false, model.Auth{}, false},
+	}
+
+	params := map[string]any{"id": "user1"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, err := CanAccessChannel(rule, params, tt.write, tt.auth)
+			if err != nil {
+				t.Fatal(err)
+			} else if allowed != tt.allowed {
+				t.Errorf("expected allowed to be %v", tt.allowed)
+			}
+		})
+	}
+
+	open := model.ChannelRule{Pattern: "lobby"}
+	if allowed, err := CanAccessChannel(open, nil, true, model.Auth{}); err != nil {
+		t.Fatal(err)
+	} else if !allowed {
+		t.Error("expected a rule without clauses to be open")
+	}
+}
+
+func TestValidateChannelRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  model.ChannelRule
+		valid bool
+	}{
+		{"valid", model.ChannelRule{Pattern: "room-{id}", Read: parseRule(t, `[["id", "=", "auth.userId"]]`)}, true},
+		{"no pattern", model.ChannelRule{}, false},
+		{"unclosed placeholder", model.ChannelRule{Pattern: "room-{id"}, false},
+		{"invalid placeholder", model.ChannelRule{Pattern: "room-{1d}"}, false},
+		{"repeated placeholder", model.ChannelRule{Pattern: "{id}-{id}"}, false},
+		{"invalid clause", model.ChannelRule{Pattern: "room-{id}", Write: parseRule(t, `[["id", "~", "x"]]`)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateChannelRules([]model.ChannelRule{tt.rule})
+			if tt.valid && err != nil {
+				t.Errorf("expected the rule to be valid: %v", err)
+			} else if !tt.valid && err == nil {
+				t.Error("expected the rule to be invalid")
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+// Sort fields that are not stored in the document's data
+const (
+	SortCreated = "created"
+	SortID      = "id"
+)
+
+// Cursor is the position of the last document of a page. The next page
+// starts after the (sort values, id) of this document.
+type Cursor struct {
+	Sort   string `json:"s"`
+	Values []any  `json:"v"`
+	ID     string `json:"id"`
+}
+
+// SortKeys returns the validated sort keys, created ascending by default.
+// Keys after id are dropped since id is unique.
+func SortKeys(params model.ListParams) ([]model.SortKey, error) {
+	keys := params.Sort
+	if len(keys) == 0 {
+		keys = []model.SortKey{{Field: params.SortBy, Descending: params.SortDescending}}
+	}
+
+	var sortKeys []model.SortKey
+	for _, key := range keys {
+		if len(key.Field) == 0 {
+			key.Field = SortCreated
+		} else if strings.EqualFold(key.Field, SortID) {
+			key.Field = SortID
+		} else if !validField.MatchString(key.Field) {
+			return nil, fmt.Errorf("invalid sort field: %s", key.Field)
+		}
+
+		sortKeys = append(sortKeys, key)
+
+		if key.Field == SortID {
+			break
+		}
+	}
+	return sortKeys, nil
+}
+
+// IDDescending returns true if the id used to break ties is sorted descending,
+// it follows the direction of the last sort key.
+func IDDescending(keys []model.SortKey) bool {
+	return keys[len(keys)-1].Descending
+}
+
+// sortSignature identifies the sort order a cursor was created for
+func sortSignature(keys []model.SortKey) string {
+	var fields []string
+	for _, key := range keys {
+		if key.Descending {
+			fields = append(fields, "-"+key.Field)
+			continue
+		}
+		fields = append(fields, key.Field)
+	}
+	return strings.Join(fields, ",")
+}
+
+// EncodeCursor returns the opaque cursor token for the document with this
+// id and sort values. The token is only valid for the same sort order.
+func EncodeCursor(params model.ListParams, values []any, id string) string {
+	keys, err := SortKeys(params)
+	if err != nil {
+		return ""
+	}
+
+	c := Cursor{
+		Sort:   sortSignature(keys),
+		Values: values,
+		ID:     id,
+	}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor returns the Cursor from the params' cursor token
+func DecodeCursor(params model.ListParams) (c Cursor, err error) {
+	b, err := base64.RawURLEncoding.DecodeString(params.Cursor)
+	if err != nil {
+		return c, errors.New("invalid cursor")
+	}
+
+	if err = json.Unmarshal(b, &c); err != nil {
+		return c, errors.New("invalid cursor")
+	}
+
+	keys, err := SortKeys(params)
+	if err != nil {
+		return
+	} else if c.Sort != sortSignature(keys) || len(c.Values) != len(keys) || len(c.ID) == 0 {
+		return c, errors.New("the cursor does not match the sort parameters")
+	}
+	return
+}
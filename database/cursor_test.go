@@ -0,0 +1,33 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+func TestCursorMatchesSortParams(t *testing.T) {
+	params := model.ListParams{Sort: []model.SortKey{{Field: "likes", Descending: true}, {Field: "title"}}}
+	params.Cursor = EncodeCursor(params, []any{10.0, "x"}, "abc")
+
+	c, err := DecodeCursor(params)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(c.Values) != 2 || c.Values[0] != 10.0 || c.ID != "abc" {
+		t.Errorf("unexpected cursor %v", c)
+	}
+
+	params.Sort[1].Descending = true
+	if _, err := DecodeCursor(params); err == nil {
+		t.Error("expected an error when the direction changed")
+	}
+
+	params.Cursor = "not-a-cursor"
+	if _, err := DecodeCursor(params); err == nil {
+		t.Error("expected an error for an invalid cursor")
+	}
+
+	if _, err := SortKeys(model.ListParams{SortBy: "likes; DROP TABLE x"}); err == nil {
+		t.Error("expected an error for an invalid sort field")
+	}
+}
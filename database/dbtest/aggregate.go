@@ -0,0 +1,113 @@
+package dbtest
+
+import (
+	"testing"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// Aggregate checks the metrics computed per group and without group
+func Aggregate(t *testing.T, datastore database.Persister, auth model.Auth, dbName string) {
+	col := "aggregates"
+
+	docs := []interface{}{
+		map[string]interface{}{"status": "paid", "amount": 10, "customer": "a"},
+		map[string]interface{}{"status": "paid", "amount": 20.5, "customer": "b"},
+		map[string]interface{}{"status": "paid", "amount": "n/a", "customer": "a"},
+		map[string]interface{}{"status": "open", "amount": 5, "customer": "c"},
+		map[string]interface{}{"amount": 7, "customer": nil},
+	}
+	if err := datastore.BulkCreateDocument(auth, dbName, col, docs); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := []database.Metric{
+		{Name: "n", Op: database.MetricCount},
+		{Name: "customers", Op: database.MetricCountDistinct, Field: "customer"},
+		{Name: "total", Op: database.MetricSum, Field: "amount"},
+		{Name: "average", Op: database.MetricAvg, Field: "amount"},
+		{Name: "lowest", Op: database.MetricMin, Field: "amount"},
+		{Name: "highest", Op: database.MetricMax, Field: "amount"},
+	}
+
+	t.Run("group by", func(t *testing.T) {
+		rows, err := datastore.Aggregate(auth, dbName, col, nil, []string{"status"}, metrics)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []map[string]any{
+			{"status": nil, "n": int64(1), "customers": int64(0), "total": 7.0, "average": 7.0, "lowest": 7.0, "highest": 7.0},
+			{"status": "open", "n": int64(1), "customers": int64(1), "total": 5.0, "average": 5.0, "lowest": 5.0, "highest": 5.0},
+			{"status": "paid", "n": int64(3), "customers": int64(2), "total": 30.5, "average": 15.25, "lowest": 10.0, "highest": 20.5},
+		}
+
+		if len(rows) != len(expected) {
+			t.Fatalf("expected %d rows got %v", len(expected), rows)
+		}
+
+		for i, row := range rows {
+			for k, v := range expected[i] {
+				if row[k] != v {
+					t.Errorf("row %d: expected %s to be %v (%T) got %v (%T)", i, k, v, v, row[k], row[k])
+				}
+			}
+		}
+	})
+
+	t.Run("without group by", func(t *testing.T) {
+		filter, err := datastore.ParseQuery([][]interface{}{{"status", "=", "paid"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rows, err := datastore.Aggregate(auth, dbName, col, filter, nil, metrics[:3])
+		if err != nil {
+			t.Fatal(err)
+		} else if len(rows) != 1 {
+			t.Fatalf("expected 1 row got %v", rows)
+		} else if rows[0]["n"] != int64(3) || rows[0]["total"] != 30.5 {
+			t.Errorf("expected 3 docs with a total of 30.5 got %v", rows[0])
+		}
+	})
+
+	t.Run("no matching documents", func(t *testing.T) {
+		filter, err := datastore.ParseQuery([][]interface{}{{"status", "=", "unknown"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rows, err := datastore.Aggregate(auth, dbName, col, filter, nil, metrics)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(rows) != 1 {
+			t.Fatalf("expected 1 row got %v", rows)
+		} else if rows[0]["n"] != int64(0) || rows[0]["total"] != 0.0 || rows[0]["average"] != nil {
+			t.Errorf("expected empty metrics got %v", rows[0])
+		}
+
+		rows, err = datastore.Aggregate(auth, dbName, col, filter, []string{"status"}, metrics)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(rows) != 0 {
+			t.Errorf("expected no groups got %v", rows)
+		}
+	})
+
+	t.Run("invalid metrics", func(t *testing.T) {
+		invalid := [][]database.Metric{
+			nil,
+			{{Name: "x", Op: "median", Field: "amount"}},
+			{{Name: "x", Op: database.MetricSum}},
+			{{Name: "status", Op: database.MetricCount}},
+			{{Name: "x'", Op: database.MetricCount}},
+		}
+
+		for _, m := range invalid {
+			if _, err := datastore.Aggregate(auth, dbName, col, nil, []string{"status"}, m); err == nil {
+				t.Errorf("expected an error for metrics %v", m)
+			}
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package dbtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// APIKeys checks that the API keys are created, listed, touched and revoked
+func APIKeys(t *testing.T, datastore database.Persister, auth model.Auth, dbName string) {
+	expires := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+	id := datastore.NewID()
+	_, hash := database.NewAPIKey(id, "secret")
+
+	key := model.APIKey{
+		ID:        id,
+		Name:      "unit test",
+		KeyHash:   hash,
+		AccountID: auth.AccountID,
+		UserID:    auth.UserID,
+		Email:     auth.Email,
+		Scopes:    []string{"read:tasks", database.ScopeFunctionExec},
+		Expires:   &expires,
+	}
+
+	if err := datastore.CreateAPIKey(dbName, key); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := datastore.GetAPIKey(dbName, id)
+	if err != nil {
+		t.Fatal(err)
+	} else if saved.KeyHash != hash || saved.UserID != auth.UserID || len(saved.Scopes) != 2 {
+		t.Errorf("expected the key to be saved got %v", saved)
+	} else if saved.Expires == nil || !saved.Expires.Equal(expires) {
+		t.Errorf("expected the key to expire at %v got %v", expires, saved.Expires)
+	} else if saved.LastUsed != nil {
+		t.Errorf("expected the key never used got %v", saved.LastUsed)
+	}
+
+	used := time.Now().Truncate(time.Second)
+	if err := datastore.TouchAPIKey(dbName, id, used); err != nil {
+		t.Fatal(err)
+	}
+
+	if keys, err := datastore.ListAPIKeys(dbName); err != nil {
+		t.Fatal(err)
+	} else if len(keys) != 1 || keys[0].ID != id {
+		t.Fatalf("expected the key to be listed got %v", keys)
+	} else if keys[0].LastUsed == nil || !keys[0].LastUsed.Equal(used) {
+		t.Errorf("expected the key to be used at %v got %v", used, keys[0].LastUsed)
+	}
+
+	if err := datastore.RevokeAPIKey(dbName, id); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := datastore.GetAPIKey(dbName, id); err == nil {
+		t.Error("expected the revoked key not to be found")
+	}
+}
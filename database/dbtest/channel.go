@@ -0,0 +1,55 @@
+package dbtest
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// ChannelRules checks that the realtime channels' rules are saved with the
+// other settings
+func ChannelRules(t *testing.T, datastore database.Persister, dbName string) {
+	s, err := datastore.GetSettings(dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer datastore.SaveSettings(dbName, s)
+
+	rules := []model.ChannelRule{
+		{
+			Pattern:      "room-{id}",
+			Read:         [][]any{{"id", "=", "auth.userId"}},
+			Write:        [][]any{{"id", "=", "auth.userId"}},
+			JoinFunction: "approve-join",
+		},
+		{Pattern: "lobby"},
+	}
+
+	s.ChannelRules = rules
+	if err := datastore.SaveSettings(dbName, s); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := datastore.GetSettings(dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the rules' values are compared as JSON since they're stored as JSON
+	expected, err := json.Marshal(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(saved.ChannelRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != string(expected) {
+		t.Errorf("expected the rules %s got %s", expected, got)
+	} else if len(saved.ChannelRetention) != len(s.ChannelRetention) {
+		t.Error("expected the other settings to be kept")
+	}
+}
@@ -0,0 +1,120 @@
+// Package dbtest contains the tests every database.Persister implementation
+// runs to make sure they behave the same way.
+package dbtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// QueryConformance runs the query operators against the data store. Every
+// operator must return the same documents on all persisters.
+func QueryConformance(t *testing.T, datastore database.Persister, auth model.Auth, dbName string) {
+	col := "conformance"
+	now := time.Now()
+
+	docs := []interface{}{
+		map[string]interface{}{
+			"title":   "Hello World",
+			"likes":   float64(2),
+			"tags":    []interface{}{"go", "db"},
+			"note":    "first",
+			"ref":     "10",
+			"done":    true,
+			"author":  map[string]interface{}{"name": "ada"},
+			"created": now.Add(-48 * time.Hour).Format(time.RFC3339Nano),
+		},
+		map[string]interface{}{
+			"title":   "hello there",
+			"likes":   float64(10),
+			"tags":    []interface{}{"go"},
+			"done":    false,
+			"author":  map[string]interface{}{"name": "bob"},
+			"created": now.Add(-24 * time.Hour).Format(time.RFC3339Nano),
+		},
+		map[string]interface{}{
+			"title":   "Goodbye",
+			"likes":   float64(30),
+			"tags":    "go",
+			"author":  "ada",
+			"created": now.Add(-1 * time.Hour).Format(time.RFC3339Nano),
+		},
+	}
+
+	if err := datastore.BulkCreateDocument(auth, dbName, col, docs); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		clauses  [][]interface{}
+		expected int64
+	}{
+		{"contains", [][]interface{}{{"title", "contains", "llo"}}, 2},
+		{"contains is case sensitive", [][]interface{}{{"title", "contains", "world"}}, 0},
+		{"starts with", [][]interface{}{{"title", "startsWith", "hello"}}, 1},
+		{"case insensitive equal", [][]interface{}{{"title", "ieq", "hello world"}}, 1},
+		{"regex", [][]interface{}{{"title", "regex", "^[hH]ello"}}, 2},
+		{"regex anchored at end", [][]interface{}{{"title", "regex", "bye$"}}, 1},
+		{"string operator on number", [][]interface{}{{"likes", "contains", "1"}}, 0},
+		{"exists", [][]interface{}{{"note", "exists", true}}, 1},
+		{"not exists", [][]interface{}{{"note", "!exists", true}}, 2},
+		{"exists false", [][]interface{}{{"note", "exists", false}}, 2},
+		{"not equal on missing field", [][]interface{}{{"note", "!=", "second"}}, 1},
+		{"not in on missing field", [][]interface{}{{"note", "!in", []interface{}{"second"}}}, 1},
+		{"in numbers", [][]interface{}{{"likes", "in", []interface{}{10, 30}}}, 2},
+		{"not in numbers", [][]interface{}{{"likes", "!in", []interface{}{10}}}, 2},
+		{"in booleans", [][]interface{}{{"done", "in", []interface{}{true}}}, 1},
+		{"not in booleans", [][]interface{}{{"done", "!in", []interface{}{true}}}, 1},
+		{"nested field", [][]interface{}{{"author.name", "=", "ada"}}, 1},
+		{"nested field exists", [][]interface{}{{"author.name", "exists", true}}, 2},
+		{"nested field in", [][]interface{}{{"author.name", "in", []interface{}{"ada", "bob"}}}, 2},
+		{"in does not match a number with a string", [][]interface{}{{"likes", "in", []interface{}{"10"}}}, 0},
+		{"in does not match a string with a number", [][]interface{}{{"ref", "in", []interface{}{10}}}, 0},
+		{"string comparison on numbers", [][]interface{}{{"likes", ">", "2"}}, 0},
+		{"string lower comparison on numbers", [][]interface{}{{"likes", "<", "2"}}, 0},
+		{"between numbers", [][]interface{}{{"likes", "between", []interface{}{5, 30}}}, 2},
+		{"between times", [][]interface{}{{"created", "between", []interface{}{now.Add(-36 * time.Hour), now}}}, 2},
+		{"contains any", [][]interface{}{{"tags", "containsAny", []interface{}{"db", "rust"}}}, 1},
+		{"contains any on arrays only", [][]interface{}{{"tags", "containsAny", "go"}}, 2},
+		{"contains all", [][]interface{}{{"tags", "containsAll", []interface{}{"go", "db"}}}, 1},
+		{"contains all single", [][]interface{}{{"tags", "containsAll", []interface{}{"go"}}}, 2},
+		{"size", [][]interface{}{{"tags", "size", 2}}, 1},
+		{"size on arrays only", [][]interface{}{{"tags", "size", 1}}, 1},
+		{"operators in groups", [][]interface{}{
+			{"or", [][]interface{}{{"title", "contains", "bye"}, {"tags", "size", 2}}},
+		}, 2},
+		{"negated operator", [][]interface{}{
+			{"not", [][]interface{}{{"title", "startsWith", "hello"}}},
+		}, 2},
+		{"negated operator on missing field", [][]interface{}{
+			{"not", [][]interface{}{{"note", "=", "first"}}},
+		}, 2},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			filter, err := datastore.ParseQuery(tc.clauses)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := datastore.QueryDocuments(auth, dbName, col, filter, model.ListParams{Page: 1, Size: 10})
+			if err != nil {
+				t.Fatal(err)
+			} else if result.Total != tc.expected {
+				t.Errorf("expected total to be %d got %d", tc.expected, result.Total)
+			}
+
+			count, err := datastore.Count(auth, dbName, col, filter)
+			if err != nil {
+				t.Fatal(err)
+			} else if count != tc.expected {
+				t.Errorf("expected count to be %d got %d", tc.expected, count)
+			}
+		})
+	}
+}
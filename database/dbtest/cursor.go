@@ -0,0 +1,131 @@
+package dbtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// CursorPagination pages through a collection with cursors and makes sure
+// every document is returned once and in the expected order.
+func CursorPagination(t *testing.T, datastore database.Persister, auth model.Auth, dbName string) {
+	col := "cursorpaging"
+
+	// duplicated ranks are ordered by id and the missing one is sorted first
+	ranks := []any{3, 1, 2, 1, nil, 5, 4}
+
+	var docs []interface{}
+	for i, rank := range ranks {
+		doc := map[string]interface{}{"title": fmt.Sprintf("doc %d", i), "even": i%2 == 0}
+		if rank != nil {
+			doc["rank"] = rank
+		}
+		docs = append(docs, doc)
+	}
+
+	if err := datastore.BulkCreateDocument(auth, dbName, col, docs); err != nil {
+		t.Fatal(err)
+	}
+
+	// pages returns all the documents by following the cursors
+	pages := func(t *testing.T, params model.ListParams, filter database.Filter) []map[string]any {
+		var all []map[string]any
+		for i := 0; i < 10; i++ {
+			var res model.PagedResult
+			var err error
+			if filter == nil {
+				res, err = datastore.ListDocuments(auth, dbName, col, params)
+			} else {
+				res, err = datastore.QueryDocuments(auth, dbName, col, filter, params)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			all = append(all, res.Results...)
+
+			if len(res.Cursor) == 0 {
+				return all
+			}
+			params.Cursor = res.Cursor
+		}
+		t.Fatal("the cursor never ended")
+		return nil
+	}
+
+	ranksOf := func(docs []map[string]any) (s string) {
+		for _, doc := range docs {
+			s += fmt.Sprintf("%v,", doc["rank"])
+		}
+		return
+	}
+
+	t.Run("created order", func(t *testing.T) {
+		docs := pages(t, model.ListParams{Page: 1, Size: 3}, nil)
+		if got := ranksOf(docs); got != "3,1,2,1,<nil>,5,4," {
+			t.Errorf("unexpected order %s", got)
+		}
+	})
+
+	t.Run("sort ascending", func(t *testing.T) {
+		docs := pages(t, model.ListParams{Page: 1, Size: 2, SortBy: "rank"}, nil)
+		if got := ranksOf(docs); got != "<nil>,1,1,2,3,4,5," {
+			t.Errorf("unexpected order %s", got)
+		}
+	})
+
+	t.Run("sort descending", func(t *testing.T) {
+		docs := pages(t, model.ListParams{Page: 1, Size: 3, SortBy: "rank", SortDescending: true}, nil)
+		if got := ranksOf(docs); got != "5,4,3,2,1,1,<nil>," {
+			t.Errorf("unexpected order %s", got)
+		}
+	})
+
+	t.Run("query", func(t *testing.T) {
+		filter, err := datastore.ParseQuery([][]interface{}{{"even", "=", true}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		docs := pages(t, model.ListParams{Page: 1, Size: 1, SortBy: "rank"}, filter)
+		if got := ranksOf(docs); got != "<nil>,2,3,4," {
+			t.Errorf("unexpected order %s", got)
+		}
+	})
+
+	t.Run("inserts between pages", func(t *testing.T) {
+		params := model.ListParams{Page: 1, Size: 4}
+		first, err := datastore.ListDocuments(auth, dbName, col, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := datastore.CreateDocument(auth, dbName, col, map[string]interface{}{"rank": 6}); err != nil {
+			t.Fatal(err)
+		}
+
+		params.Cursor = first.Cursor
+		next, err := datastore.ListDocuments(auth, dbName, col, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := ranksOf(next.Results); got != "<nil>,5,4,6," {
+			t.Errorf("unexpected order %s", got)
+		}
+	})
+
+	t.Run("cursor for another sort", func(t *testing.T) {
+		res, err := datastore.ListDocuments(auth, dbName, col, model.ListParams{Page: 1, Size: 2, SortBy: "rank"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		params := model.ListParams{Page: 1, Size: 2, SortBy: "title", Cursor: res.Cursor}
+		if _, err := datastore.ListDocuments(auth, dbName, col, params); err == nil {
+			t.Error("expected an error using a cursor with another sort")
+		}
+	})
+}
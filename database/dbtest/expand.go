@@ -0,0 +1,92 @@
+package dbtest
+
+import (
+	"testing"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// Expand checks that references are replaced by their documents and that the
+// read permissions of the referenced collection are respected
+func Expand(t *testing.T, datastore database.Persister, auth model.Auth, dbName string) {
+	authors := "expand_authors"
+	posts := "pub_expand_posts"
+
+	var authorIDs []string
+	for _, name := range []string{"alice", "bob"} {
+		doc, err := datastore.CreateDocument(auth, dbName, authors, map[string]any{"name": name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		authorIDs = append(authorIDs, doc["id"].(string))
+	}
+
+	missingID := datastore.NewID()
+
+	post, err := datastore.CreateDocument(auth, dbName, posts, map[string]any{
+		"title":    "expanded",
+		"author":   authorIDs[0],
+		"editors":  []any{authorIDs[1], missingID, authorIDs[0]},
+		"reviewer": missingID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expansions, err := database.ParseExpand([]string{"author:" + authors, "editors:" + authors + ",reviewer:" + authors})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(auth model.Auth) map[string]any {
+		doc, err := datastore.GetDocumentByID(auth, dbName, posts, post["id"].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := database.Expand(datastore, auth, dbName, []map[string]any{doc}, expansions); err != nil {
+			t.Fatal(err)
+		}
+		return doc
+	}
+
+	t.Run("references are expanded", func(t *testing.T) {
+		doc := get(auth)
+
+		author, ok := doc["author"].(map[string]any)
+		if !ok {
+			t.Fatalf("expected author to be a document got %v", doc["author"])
+		} else if author["name"] != "alice" {
+			t.Errorf("expected author alice got %v", author["name"])
+		}
+
+		editors, ok := doc["editors"].([]any)
+		if !ok || len(editors) != 2 {
+			t.Fatalf("expected 2 editors got %v", doc["editors"])
+		} else if editor := editors[0].(map[string]any); editor["name"] != "bob" {
+			t.Errorf("expected the first editor to be bob got %v", editor["name"])
+		}
+
+		if doc["reviewer"] != nil {
+			t.Errorf("expected a missing reference to be nil got %v", doc["reviewer"])
+		}
+	})
+
+	t.Run("read permissions apply", func(t *testing.T) {
+		// a user from another account can read the public posts but not
+		// the authors
+		other := model.Auth{
+			AccountID: datastore.NewID(),
+			UserID:    datastore.NewID(),
+			Email:     "other@expand.com",
+		}
+
+		doc := get(other)
+		if doc["author"] != nil {
+			t.Errorf("expected author to be nil got %v", doc["author"])
+		} else if editors, ok := doc["editors"].([]any); !ok || len(editors) != 0 {
+			t.Errorf("expected no editors got %v", doc["editors"])
+		}
+	})
+}
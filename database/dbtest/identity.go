@@ -0,0 +1,76 @@
+package dbtest
+
+import (
+	"testing"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// Identities checks that external login identities are linked, unique per
+// provider and subject and unlinked
+func Identities(t *testing.T, datastore database.Persister, auth model.Auth, dbName string) {
+	if ident, err := datastore.FindIdentity(dbName, "keycloak", "subject-1"); err != nil {
+		t.Fatal(err)
+	} else if len(ident.ID) > 0 {
+		t.Fatalf("expected no identity got %v", ident)
+	}
+
+	ident := model.Identity{
+		AccountID: auth.AccountID,
+		UserID:    auth.UserID,
+		Provider:  "keycloak",
+		Subject:   "subject-1",
+		Email:     auth.Email,
+	}
+
+	id, err := datastore.AddIdentity(dbName, ident)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := datastore.AddIdentity(dbName, ident); err == nil {
+		t.Error("expected an error linking the same identity twice")
+	}
+
+	ident.Provider = "github"
+	if _, err := datastore.AddIdentity(dbName, ident); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := datastore.FindIdentity(dbName, "keycloak", "subject-1")
+	if err != nil {
+		t.Fatal(err)
+	} else if found.ID != id || found.UserID != auth.UserID || found.AccountID != auth.AccountID {
+		t.Errorf("expected the identity %s of the user got %v", id, found)
+	} else if found.Email != auth.Email || found.Linked.IsZero() {
+		t.Errorf("expected the email and linked time to be set got %v", found)
+	}
+
+	list, err := datastore.ListIdentities(dbName, auth.UserID)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(list) != 2 {
+		t.Fatalf("expected 2 identities got %d", len(list))
+	}
+
+	if err := datastore.DeleteIdentity(dbName, auth.UserID, id); err != nil {
+		t.Fatal(err)
+	}
+
+	if ident, err := datastore.FindIdentity(dbName, "keycloak", "subject-1"); err != nil {
+		t.Fatal(err)
+	} else if len(ident.ID) > 0 {
+		t.Errorf("expected the identity to be unlinked got %v", ident)
+	}
+
+	if err := datastore.DeleteIdentities(dbName, auth.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	if list, err := datastore.ListIdentities(dbName, auth.UserID); err != nil {
+		t.Fatal(err)
+	} else if len(list) > 0 {
+		t.Errorf("expected all identities to be unlinked got %v", list)
+	}
+}
@@ -0,0 +1,80 @@
+package dbtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// Invitations checks that the invitations of an account are created, listed
+// and deleted only by their account
+func Invitations(t *testing.T, datastore database.Persister, auth model.Auth, dbName string) {
+	expires := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+	var ids []string
+	for _, email := range []string{"invite1@dbtest.com", "invite2@dbtest.com"} {
+		id := datastore.NewID()
+		_, hash := database.NewInvitationToken(id, "secret")
+
+		inv := model.Invitation{
+			ID:        id,
+			AccountID: auth.AccountID,
+			Email:     email,
+			Role:      10,
+			TokenHash: hash,
+			InvitedBy: auth.UserID,
+			Expires:   expires,
+		}
+		if err := datastore.CreateInvitation(dbName, inv); err != nil {
+			t.Fatal(err)
+		}
+
+		ids = append(ids, id)
+		// the list is ordered by creation
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	saved, err := datastore.GetInvitation(dbName, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	} else if saved.Email != "invite1@dbtest.com" || saved.Role != 10 || saved.InvitedBy != auth.UserID {
+		t.Errorf("expected the invitation to be saved got %v", saved)
+	} else if saved.TokenHash != database.HashAPIKeySecret("secret") {
+		t.Errorf("expected the token hash to be saved got %s", saved.TokenHash)
+	} else if !saved.Expires.Equal(expires) {
+		t.Errorf("expected the invitation to expire at %v got %v", expires, saved.Expires)
+	}
+
+	list, err := datastore.ListInvitations(dbName, auth.AccountID)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(list) != 2 || list[0].ID != ids[1] {
+		t.Fatalf("expected 2 invitations most recent first got %v", list)
+	}
+
+	// another account cannot delete the invitation
+	if err := datastore.DeleteInvitation(dbName, datastore.NewID(), ids[0]); err != nil {
+		t.Fatal(err)
+	} else if _, err := datastore.GetInvitation(dbName, ids[0]); err != nil {
+		t.Errorf("expected the invitation to exist got %v", err)
+	}
+
+	for _, id := range ids {
+		if err := datastore.DeleteInvitation(dbName, auth.AccountID, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := datastore.GetInvitation(dbName, ids[0]); err == nil {
+		t.Error("expected the invitation to be deleted")
+	}
+
+	list, err = datastore.ListInvitations(dbName, auth.AccountID)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(list) != 0 {
+		t.Errorf("expected no invitations got %v", list)
+	}
+}
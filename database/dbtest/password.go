@@ -0,0 +1,39 @@
+package dbtest
+
+import (
+	"testing"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// PasswordPolicy checks that the password policy is saved with the other
+// settings
+func PasswordPolicy(t *testing.T, datastore database.Persister, dbName string) {
+	s, err := datastore.GetSettings(dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer datastore.SaveSettings(dbName, s)
+
+	policy := model.PasswordPolicy{
+		MinLength:      12,
+		RequireUpper:   true,
+		RequireDigit:   true,
+		RejectBreached: true,
+	}
+
+	s.PasswordPolicy = policy
+	if err := datastore.SaveSettings(dbName, s); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := datastore.GetSettings(dbName)
+	if err != nil {
+		t.Fatal(err)
+	} else if saved.PasswordPolicy != policy {
+		t.Errorf("expected the password policy %v got %v", policy, saved.PasswordPolicy)
+	} else if saved.RequireRootTwoFactor != s.RequireRootTwoFactor {
+		t.Error("expected the other settings to be kept")
+	}
+}
@@ -0,0 +1,158 @@
+package dbtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// ProjectionAndSort checks the fields projection on every read and the
+// ordering on multiple sort keys.
+func ProjectionAndSort(t *testing.T, datastore database.Persister, auth model.Auth, dbName string) {
+	col := "projections"
+
+	rows := []struct {
+		title string
+		group string
+		rank  int
+	}{
+		{"a", "x", 1},
+		{"b", "y", 3},
+		{"c", "x", 2},
+		{"d", "y", 1},
+		{"e", "x", 3},
+	}
+
+	var docs []interface{}
+	for _, row := range rows {
+		docs = append(docs, map[string]interface{}{
+			"title": row.title,
+			"group": row.group,
+			"rank":  row.rank,
+			"big":   []interface{}{"a large", "payload", true},
+		})
+	}
+
+	if err := datastore.BulkCreateDocument(auth, dbName, col, docs); err != nil {
+		t.Fatal(err)
+	}
+
+	titles := func(docs []map[string]any) (s string) {
+		for _, doc := range docs {
+			s += fmt.Sprintf("%v", doc["title"])
+		}
+		return
+	}
+
+	// sort on group ascending then rank descending
+	sortKeys := []model.SortKey{{Field: "group"}, {Field: "rank", Descending: true}}
+
+	t.Run("multiple sort keys", func(t *testing.T) {
+		params := model.ListParams{Page: 1, Size: 10, Sort: sortKeys}
+		res, err := datastore.ListDocuments(auth, dbName, col, params)
+		if err != nil {
+			t.Fatal(err)
+		} else if got := titles(res.Results); got != "ecabd" {
+			t.Errorf("expected order ecabd got %s", got)
+		}
+	})
+
+	t.Run("include fields", func(t *testing.T) {
+		params := model.ListParams{Page: 1, Size: 10, Fields: []string{"title"}}
+		res, err := datastore.ListDocuments(auth, dbName, col, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, doc := range res.Results {
+			if len(doc) != 3 || doc["title"] == nil || doc["id"] == nil || doc["accountId"] == nil {
+				t.Fatalf("expected only title, id and accountId got %v", doc)
+			}
+		}
+	})
+
+	t.Run("exclude fields", func(t *testing.T) {
+		filter, err := datastore.ParseQuery([][]interface{}{{"group", "=", "x"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		params := model.ListParams{Page: 1, Size: 10, Fields: []string{"-big", "-rank"}}
+		res, err := datastore.QueryDocuments(auth, dbName, col, filter, params)
+		if err != nil {
+			t.Fatal(err)
+		} else if res.Total != 3 {
+			t.Fatalf("expected 3 docs got %d", res.Total)
+		}
+
+		for _, doc := range res.Results {
+			if _, ok := doc["big"]; ok {
+				t.Fatalf("expected big to be excluded got %v", doc)
+			} else if _, ok := doc["rank"]; ok {
+				t.Fatalf("expected rank to be excluded got %v", doc)
+			} else if doc["group"] != "x" || doc["id"] == nil {
+				t.Fatalf("expected the other fields got %v", doc)
+			}
+		}
+	})
+
+	t.Run("cursor on fields not projected", func(t *testing.T) {
+		params := model.ListParams{Page: 1, Size: 2, Sort: sortKeys, Fields: []string{"title"}}
+
+		var all []map[string]any
+		for i := 0; i < 5; i++ {
+			res, err := datastore.ListDocuments(auth, dbName, col, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			all = append(all, res.Results...)
+			if len(res.Cursor) == 0 {
+				break
+			}
+			params.Cursor = res.Cursor
+		}
+
+		if got := titles(all); got != "ecabd" {
+			t.Errorf("expected order ecabd got %s", got)
+		}
+	})
+
+	t.Run("get by ids", func(t *testing.T) {
+		res, err := datastore.ListDocuments(auth, dbName, col, model.ListParams{Page: 1, Size: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		id := fmt.Sprintf("%v", res.Results[0]["id"])
+		doc, err := datastore.GetDocumentByID(auth, dbName, col, id, "title", "rank")
+		if err != nil {
+			t.Fatal(err)
+		} else if len(doc) != 4 || doc["rank"] == nil {
+			t.Errorf("expected title, rank, id and accountId got %v", doc)
+		}
+
+		ids := []string{id, fmt.Sprintf("%v", res.Results[1]["id"])}
+		list, err := datastore.GetDocumentsByIDs(auth, dbName, col, ids, "-big")
+		if err != nil {
+			t.Fatal(err)
+		} else if len(list) != 2 {
+			t.Fatalf("expected 2 docs got %d", len(list))
+		}
+
+		for _, doc := range list {
+			if _, ok := doc["big"]; ok || doc["title"] == nil {
+				t.Errorf("expected big to be excluded got %v", doc)
+			}
+		}
+	})
+
+	t.Run("invalid projection", func(t *testing.T) {
+		params := model.ListParams{Page: 1, Size: 2, Fields: []string{"title", "-big"}}
+		if _, err := datastore.ListDocuments(auth, dbName, col, params); err == nil {
+			t.Error("expected an error when including and excluding fields")
+		}
+	})
+}
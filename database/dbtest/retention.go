@@ -0,0 +1,38 @@
+package dbtest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// ChannelRetention checks that the realtime channels' retention is saved with
+// the other settings
+func ChannelRetention(t *testing.T, datastore database.Persister, dbName string) {
+	s, err := datastore.GetSettings(dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer datastore.SaveSettings(dbName, s)
+
+	retention := []model.ChannelRetention{
+		{Channel: "chat", MaxMessages: 50},
+		{Channel: "room-*", MaxMessages: 10, MaxMinutes: 60},
+	}
+
+	s.ChannelRetention = retention
+	if err := datastore.SaveSettings(dbName, s); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := datastore.GetSettings(dbName)
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(saved.ChannelRetention, retention) {
+		t.Errorf("expected the retention %v got %v", retention, saved.ChannelRetention)
+	} else if saved.PasswordPolicy != s.PasswordPolicy {
+		t.Error("expected the other settings to be kept")
+	}
+}
@@ -0,0 +1,96 @@
+package dbtest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// Roles checks that the named roles are stored and usable in the collection
+// rules through auth.role
+func Roles(t *testing.T, datastore database.Persister, auth model.Auth, dbName string) {
+	editor := model.Role{Name: "editor", Level: 40, Permissions: []string{"posts:write"}}
+	viewer := model.Role{Name: "viewer", Level: 10}
+
+	for _, role := range []model.Role{editor, viewer} {
+		if err := datastore.SaveRole(dbName, role); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// saving again replaces the role
+	editor.Permissions = append(editor.Permissions, "posts:delete")
+	if err := datastore.SaveRole(dbName, editor); err != nil {
+		t.Fatal(err)
+	}
+
+	roles, err := datastore.ListRoles(dbName)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(roles) != 2 || roles[0].Name != viewer.Name || roles[1].Name != editor.Name {
+		t.Fatalf("expected the viewer and editor roles ordered by level got %v", roles)
+	} else if !roles[1].Can("posts:delete") || roles[0].Can("posts:write") {
+		t.Errorf("expected the editor's permissions to be replaced got %v", roles)
+	}
+
+	if role, ok, err := database.FindRole(datastore, dbName, editor.Level); err != nil {
+		t.Fatal(err)
+	} else if !ok || role.Name != editor.Name {
+		t.Errorf("expected the editor role for level %d got %v", editor.Level, role)
+	}
+
+	if _, err := database.FindRoleByName(datastore, dbName, "unknown"); !errors.Is(err, database.ErrRoleNotFound) {
+		t.Errorf("expected a role not found error got %v", err)
+	}
+
+	t.Run("rules by role name", func(t *testing.T) {
+		col := "roles_posts"
+
+		rules := model.CollectionRules{
+			Collection: col,
+			Rules: model.Rules{
+				Read: [][]any{{"auth.role", "in", []any{editor.Name, viewer.Name}}},
+			},
+		}
+		if err := datastore.SaveRules(dbName, rules); err != nil {
+			t.Fatal(err)
+		}
+		defer datastore.DeleteRules(dbName, col)
+
+		if _, err := datastore.CreateDocument(auth, dbName, col, map[string]any{"title": "post"}); err != nil {
+			t.Fatal(err)
+		}
+
+		// the role's name is resolved from the level
+		member := auth
+		member.Role = viewer.Level
+		if count, err := datastore.Count(member, dbName, col, nil); err != nil {
+			t.Fatal(err)
+		} else if count != 1 {
+			t.Errorf("expected the viewer to count 1 document got %d", count)
+		}
+
+		member.Role = 0
+		if count, err := datastore.Count(member, dbName, col, nil); err != nil {
+			t.Fatal(err)
+		} else if count != 0 {
+			t.Errorf("expected a user without role to count 0 document got %d", count)
+		}
+	})
+
+	t.Run("delete role", func(t *testing.T) {
+		if err := datastore.DeleteRole(dbName, viewer.Name); err != nil {
+			t.Fatal(err)
+		} else if err := datastore.DeleteRole(dbName, editor.Name); err != nil {
+			t.Fatal(err)
+		}
+
+		if roles, err := datastore.ListRoles(dbName); err != nil {
+			t.Fatal(err)
+		} else if len(roles) != 0 {
+			t.Errorf("expected no roles got %v", roles)
+		}
+	})
+}
@@ -0,0 +1,245 @@
+package dbtest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// Rules checks that the collection rules are stored and enforced on reads,
+// writes and creations for the non-root callers
+func Rules(t *testing.T, datastore database.Persister, auth model.Auth, dbName string) {
+	col := "rules_tasks"
+
+	rules := model.CollectionRules{
+		Collection: col,
+		Rules: model.Rules{
+			Read: [][]any{{"or", []any{
+				[]any{"public", "=", true},
+				[]any{"ownerId", "=", "auth.userId"},
+			}}},
+			Write: [][]any{
+				{"auth.role", ">=", 50},
+				{"accountId", "=", "auth.accountId"},
+			},
+		},
+	}
+
+	if err := datastore.SaveRules(dbName, rules); err != nil {
+		t.Fatal(err)
+	}
+
+	// saving again replaces the rules
+	if err := datastore.SaveRules(dbName, rules); err != nil {
+		t.Fatal(err)
+	}
+
+	if saved, ok, err := datastore.GetRules(dbName, col); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("expected the rules to be found")
+	} else if len(saved.Rules.Read) != 1 || len(saved.Rules.Write) != 2 {
+		t.Errorf("expected 1 read and 2 write clauses got %v", saved.Rules)
+	}
+
+	if list, err := datastore.ListRules(dbName); err != nil {
+		t.Fatal(err)
+	} else if len(list) != 1 || list[0].Collection != col {
+		t.Errorf("expected 1 rules for %s got %v", col, list)
+	}
+
+	if _, ok, err := datastore.GetRules(dbName, "no_rules"); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Error("expected no rules for a collection without any")
+	}
+
+	// root bypasses the rules
+	private, err := datastore.CreateDocument(auth, dbName, col, map[string]any{"title": "private", "public": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := datastore.CreateDocument(auth, dbName, col, map[string]any{"title": "public", "public": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privateID, publicID := private["id"].(string), public["id"].(string)
+
+	// owner is the documents' owner without the admin role, manager has the
+	// role and stranger is a user from another account
+	owner := auth
+	owner.Role = 0
+	manager := auth
+	manager.Role = 50
+	stranger := model.Auth{AccountID: datastore.NewID(), UserID: datastore.NewID(), Email: "stranger@test.com", Role: 50}
+
+	params := model.ListParams{Page: 1, Size: 50}
+
+	t.Run("reads are filtered", func(t *testing.T) {
+		if res, err := datastore.ListDocuments(owner, dbName, col, params); err != nil {
+			t.Fatal(err)
+		} else if res.Total != 2 {
+			t.Errorf("expected the owner to list 2 documents got %d", res.Total)
+		}
+
+		res, err := datastore.ListDocuments(stranger, dbName, col, params)
+		if err != nil {
+			t.Fatal(err)
+		} else if res.Total != 1 || len(res.Results) != 1 || res.Results[0]["id"] != publicID {
+			t.Errorf("expected the stranger to list the public document got %v", res.Results)
+		}
+
+		filter, err := datastore.ParseQuery([][]any{{"title", "!=", "none"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res, err := datastore.QueryDocuments(stranger, dbName, col, filter, params); err != nil {
+			t.Fatal(err)
+		} else if res.Total != 1 {
+			t.Errorf("expected the stranger to query 1 document got %d", res.Total)
+		}
+
+		if count, err := datastore.Count(stranger, dbName, col, nil); err != nil {
+			t.Fatal(err)
+		} else if count != 1 {
+			t.Errorf("expected the stranger to count 1 document got %d", count)
+		}
+
+		if _, err := datastore.GetDocumentByID(stranger, dbName, col, privateID); err == nil {
+			t.Error("expected the stranger not to get the private document")
+		}
+
+		if _, err := datastore.GetDocumentByID(owner, dbName, col, privateID); err != nil {
+			t.Errorf("expected the owner to get the private document got %v", err)
+		}
+
+		if docs, err := datastore.GetDocumentsByIDs(stranger, dbName, col, []string{privateID, publicID}); err != nil {
+			t.Fatal(err)
+		} else if len(docs) != 1 || docs[0]["id"] != publicID {
+			t.Errorf("expected the stranger to get the public document got %v", docs)
+		}
+	})
+
+	t.Run("writes are restricted", func(t *testing.T) {
+		// the update is either refused or does not match the document
+		datastore.UpdateDocument(owner, dbName, col, publicID, map[string]any{"title": "by owner"})
+
+		if doc, err := datastore.GetDocumentByID(auth, dbName, col, publicID); err != nil {
+			t.Fatal(err)
+		} else if doc["title"] != "public" {
+			t.Errorf("expected the owner without the role not to update got %v", doc["title"])
+		}
+
+		filter, err := datastore.ParseQuery([][]any{{"public", "=", true}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n, err := datastore.UpdateDocuments(stranger, dbName, col, filter, map[string]any{"title": "by stranger"}); err != nil {
+			t.Fatal(err)
+		} else if n != 0 {
+			t.Errorf("expected the stranger to update 0 document got %d", n)
+		}
+
+		if _, err := datastore.UpdateDocument(manager, dbName, col, publicID, map[string]any{"title": "by manager"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if doc, err := datastore.GetDocumentByID(auth, dbName, col, publicID); err != nil {
+			t.Fatal(err)
+		} else if doc["title"] != "by manager" {
+			t.Errorf("expected the manager to update got %v", doc["title"])
+		}
+
+		if n, _ := datastore.DeleteDocument(stranger, dbName, col, privateID); n != 0 {
+			t.Errorf("expected the stranger to delete 0 document got %d", n)
+		}
+
+		if count, err := datastore.Count(auth, dbName, col, nil); err != nil {
+			t.Fatal(err)
+		} else if count != 2 {
+			t.Errorf("expected 2 documents got %d", count)
+		}
+	})
+
+	t.Run("creations are restricted", func(t *testing.T) {
+		_, err := datastore.CreateDocument(owner, dbName, col, map[string]any{"title": "by owner"})
+		if !errors.Is(err, database.ErrPermissionDenied) {
+			t.Errorf("expected a permission denied error got %v", err)
+		}
+
+		if _, err := datastore.CreateDocument(manager, dbName, col, map[string]any{"title": "by manager"}); err != nil {
+			t.Errorf("expected the manager to create got %v", err)
+		}
+	})
+
+	t.Run("updates are checked once applied", func(t *testing.T) {
+		locked := model.CollectionRules{
+			Collection: "rules_locked",
+			Rules: model.Rules{
+				Write: [][]any{
+					{"ownerId", "=", "auth.userId"},
+					{"locked", "=", false},
+				},
+			},
+		}
+		if err := datastore.SaveRules(dbName, locked); err != nil {
+			t.Fatal(err)
+		}
+		defer datastore.DeleteRules(dbName, locked.Collection)
+
+		doc, err := datastore.CreateDocument(owner, dbName, locked.Collection, map[string]any{"title": "draft", "locked": false})
+		if err != nil {
+			t.Fatal(err)
+		}
+		id := doc["id"].(string)
+
+		// the stored document satisfies the rule but not the updated one
+		_, err = datastore.UpdateDocument(owner, dbName, locked.Collection, id, map[string]any{"locked": true})
+		if !errors.Is(err, database.ErrPermissionDenied) {
+			t.Errorf("expected a permission denied error got %v", err)
+		}
+
+		filter, err := datastore.ParseQuery([][]any{{"title", "=", "draft"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = datastore.UpdateDocuments(owner, dbName, locked.Collection, filter, map[string]any{"locked": true})
+		if !errors.Is(err, database.ErrPermissionDenied) {
+			t.Errorf("expected a permission denied error updating many got %v", err)
+		}
+
+		if doc, err := datastore.GetDocumentByID(auth, dbName, locked.Collection, id); err != nil {
+			t.Fatal(err)
+		} else if doc["locked"] != false {
+			t.Errorf("expected the document to stay unlocked got %v", doc["locked"])
+		}
+
+		if _, err := datastore.UpdateDocument(owner, dbName, locked.Collection, id, map[string]any{"title": "edited"}); err != nil {
+			t.Errorf("expected an update satisfying the rule to succeed got %v", err)
+		}
+	})
+
+	t.Run("delete rules", func(t *testing.T) {
+		if err := datastore.DeleteRules(dbName, col); err != nil {
+			t.Fatal(err)
+		}
+
+		// the collection's name permissions apply, the stranger's account
+		// does not own any document
+		if count, err := datastore.Count(stranger, dbName, col, nil); err != nil {
+			t.Fatal(err)
+		} else if count != 0 {
+			t.Errorf("expected the stranger to count 0 document got %d", count)
+		}
+
+		if _, err := datastore.CreateDocument(owner, dbName, col, map[string]any{"title": "by owner"}); err != nil {
+			t.Errorf("expected the owner to create once the rules are removed got %v", err)
+		}
+	})
+}
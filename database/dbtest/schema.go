@@ -0,0 +1,133 @@
+package dbtest
+
+import (
+	"testing"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// Schemas checks that the collection schemas are stored and enforced when
+// creating and updating documents
+func Schemas(t *testing.T, datastore database.Persister, auth model.Auth, dbName string) {
+	col := "schemas"
+
+	maxPriority := float64(5)
+	schema := model.CollectionSchema{
+		Collection: col,
+		Schema: model.Schema{
+			Type:     database.SchemaObject,
+			Required: []string{"title"},
+			Properties: map[string]*model.Schema{
+				"title":    {Type: database.SchemaString},
+				"status":   {Type: database.SchemaString, Enum: []any{"todo", "done"}, Default: "todo"},
+				"priority": {Type: database.SchemaInteger, Maximum: &maxPriority},
+			},
+		},
+	}
+
+	if err := datastore.SaveSchema(dbName, schema); err != nil {
+		t.Fatal(err)
+	}
+
+	// saving again replaces the schema
+	if err := datastore.SaveSchema(dbName, schema); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, ok, err := datastore.GetSchema(dbName, col)
+	if err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("expected the schema to be found")
+	} else if saved.Schema.Properties["status"].Default != "todo" {
+		t.Errorf("expected the status default to be saved got %v", saved.Schema.Properties["status"])
+	}
+
+	if list, err := datastore.ListSchemas(dbName); err != nil {
+		t.Fatal(err)
+	} else if len(list) != 1 || list[0].Collection != col {
+		t.Errorf("expected 1 schema for %s got %v", col, list)
+	}
+
+	if _, ok, err := datastore.GetSchema(dbName, "no_schema"); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Error("expected no schema for a collection without one")
+	}
+
+	doc, err := datastore.CreateDocument(auth, dbName, col, map[string]any{"title": "first", "priority": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := doc["id"].(string)
+
+	t.Run("defaults are applied", func(t *testing.T) {
+		doc, err := datastore.GetDocumentByID(auth, dbName, col, id)
+		if err != nil {
+			t.Fatal(err)
+		} else if doc["status"] != "todo" {
+			t.Errorf("expected status todo got %v", doc["status"])
+		}
+	})
+
+	t.Run("invalid creation", func(t *testing.T) {
+		_, err := datastore.CreateDocument(auth, dbName, col, map[string]any{"priority": 1})
+		if !database.IsValidationError(err) {
+			t.Errorf("expected a validation error got %v", err)
+		}
+
+		docs := []any{
+			map[string]any{"title": "valid"},
+			map[string]any{"title": "invalid", "priority": 10},
+		}
+
+		before, err := datastore.Count(auth, dbName, col, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := datastore.BulkCreateDocument(auth, dbName, col, docs); !database.IsValidationError(err) {
+			t.Errorf("expected a validation error got %v", err)
+		}
+
+		if after, err := datastore.Count(auth, dbName, col, nil); err != nil {
+			t.Fatal(err)
+		} else if after != before {
+			t.Errorf("expected no documents created got %d, was %d", after, before)
+		}
+	})
+
+	t.Run("invalid updates", func(t *testing.T) {
+		_, err := datastore.UpdateDocument(auth, dbName, col, id, map[string]any{"status": "late"})
+		if !database.IsValidationError(err) {
+			t.Errorf("expected a validation error got %v", err)
+		}
+
+		filter, err := datastore.ParseQuery([][]any{{"title", "=", "first"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = datastore.UpdateDocuments(auth, dbName, col, filter, map[string]any{"priority": "high"})
+		if !database.IsValidationError(err) {
+			t.Errorf("expected a validation error got %v", err)
+		}
+
+		// partial updates only validate the fields present
+		if _, err := datastore.UpdateDocument(auth, dbName, col, id, map[string]any{"status": "done"}); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("delete schema", func(t *testing.T) {
+		if err := datastore.DeleteSchema(dbName, col); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := datastore.CreateDocument(auth, dbName, col, map[string]any{"priority": "any"}); err != nil {
+			t.Errorf("expected no validation once the schema is removed got %v", err)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package dbtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// Sessions checks that the sessions are created, rotated only once per
+// refresh token hash and deleted
+func Sessions(t *testing.T, datastore database.Persister, auth model.Auth, dbName string) {
+	expires := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+	var ids []string
+	for i := 0; i < 2; i++ {
+		id := datastore.NewID()
+		_, hash := database.NewRefreshToken(id, "secret")
+
+		s := model.Session{
+			ID:          id,
+			AccountID:   auth.AccountID,
+			UserID:      auth.UserID,
+			RefreshHash: hash,
+			Expires:     expires,
+		}
+		if err := datastore.CreateSession(dbName, s); err != nil {
+			t.Fatal(err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	saved, err := datastore.GetSession(dbName, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	} else if saved.UserID != auth.UserID || saved.RefreshHash != database.HashAPIKeySecret("secret") {
+		t.Errorf("expected the session to be saved got %v", saved)
+	} else if !saved.Expires.Equal(expires) {
+		t.Errorf("expected the session to expire at %v got %v", expires, saved.Expires)
+	}
+
+	newExpires := expires.Add(time.Hour)
+	newHash := database.HashAPIKeySecret("new-secret")
+
+	ok, err := datastore.RotateSession(dbName, ids[0], database.HashAPIKeySecret("stolen"), newHash, newExpires)
+	if err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Error("expected the session not to rotate with the wrong hash")
+	}
+
+	ok, err = datastore.RotateSession(dbName, ids[0], saved.RefreshHash, newHash, newExpires)
+	if err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("expected the session to rotate")
+	}
+
+	// the previous refresh token cannot be used twice
+	if ok, err := datastore.RotateSession(dbName, ids[0], saved.RefreshHash, newHash, newExpires); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Error("expected the session not to rotate a second time with the same hash")
+	}
+
+	if rotated, err := datastore.GetSession(dbName, ids[0]); err != nil {
+		t.Fatal(err)
+	} else if rotated.RefreshHash != newHash || !rotated.Expires.Equal(newExpires) {
+		t.Errorf("expected the session to be rotated got %v", rotated)
+	}
+
+	if list, err := datastore.ListSessions(dbName, auth.UserID); err != nil {
+		t.Fatal(err)
+	} else if len(list) != 2 {
+		t.Fatalf("expected 2 sessions got %v", list)
+	}
+
+	if err := datastore.DeleteSession(dbName, ids[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := datastore.GetSession(dbName, ids[0]); err == nil {
+		t.Error("expected the deleted session not to be found")
+	}
+
+	if err := datastore.DeleteSessions(dbName, auth.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	if list, err := datastore.ListSessions(dbName, auth.UserID); err != nil {
+		t.Fatal(err)
+	} else if len(list) != 0 {
+		t.Errorf("expected no sessions got %v", list)
+	}
+}
@@ -0,0 +1,76 @@
+package dbtest
+
+import (
+	"testing"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// Teams checks that the teams of the users are stored and usable in the
+// collection rules through auth.teams
+func Teams(t *testing.T, datastore database.Persister, auth model.Auth, dbName string) {
+	if teams, err := datastore.ListUserTeams(dbName, auth.UserID); err != nil {
+		t.Fatal(err)
+	} else if len(teams) != 0 {
+		t.Fatalf("expected no teams got %v", teams)
+	}
+
+	if err := datastore.SetUserTeams(dbName, auth.UserID, []string{"red", "blue"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// setting the teams again replaces them
+	if err := datastore.SetUserTeams(dbName, auth.UserID, []string{"red", "green"}); err != nil {
+		t.Fatal(err)
+	}
+
+	teams, err := datastore.ListUserTeams(dbName, auth.UserID)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(teams) != 2 || teams[0] != "green" || teams[1] != "red" {
+		t.Fatalf("expected the green and red teams got %v", teams)
+	}
+
+	t.Run("rules by team", func(t *testing.T) {
+		col := "teams_posts"
+
+		rules := model.CollectionRules{
+			Collection: col,
+			Rules: model.Rules{
+				Read: [][]any{{"teamId", "in", "auth.teams"}},
+			},
+		}
+		if err := datastore.SaveRules(dbName, rules); err != nil {
+			t.Fatal(err)
+		}
+		defer datastore.DeleteRules(dbName, col)
+
+		docs := []any{
+			map[string]any{"title": "red post", "teamId": "red"},
+			map[string]any{"title": "blue post", "teamId": "blue"},
+		}
+		if err := datastore.BulkCreateDocument(auth, dbName, col, docs); err != nil {
+			t.Fatal(err)
+		}
+
+		// the teams are resolved from the user
+		member := auth
+		member.Role = 0
+		if count, err := datastore.Count(member, dbName, col, nil); err != nil {
+			t.Fatal(err)
+		} else if count != 1 {
+			t.Errorf("expected the red team member to count 1 document got %d", count)
+		}
+
+		if err := datastore.SetUserTeams(dbName, auth.UserID, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		if count, err := datastore.Count(member, dbName, col, nil); err != nil {
+			t.Fatal(err)
+		} else if count != 0 {
+			t.Errorf("expected a user without teams to count 0 document got %d", count)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package dbtest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// Transaction checks that the operations made in a transaction are committed
+// together or rolled back together
+func Transaction(t *testing.T, datastore database.Persister, auth model.Auth, dbName string) {
+	col := "transactions"
+
+	if _, err := datastore.CreateDocument(auth, dbName, col, map[string]any{"step": "before"}); err != nil {
+		t.Fatal(err)
+	}
+
+	count := func(t *testing.T) int64 {
+		n, err := datastore.Count(auth, dbName, col, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	errAbort := errors.New("abort")
+
+	t.Run("commit", func(t *testing.T) {
+		var id string
+		err := datastore.WithTransaction(func(tx database.Persister) error {
+			doc, err := tx.CreateDocument(auth, dbName, col, map[string]any{"step": "commit"})
+			if err != nil {
+				return err
+			}
+
+			id = doc["id"].(string)
+
+			// the transaction reads its own writes
+			if n, err := tx.Count(auth, dbName, col, nil); err != nil {
+				return err
+			} else if n != 2 {
+				t.Errorf("expected 2 docs in the transaction got %d", n)
+			}
+
+			_, err = tx.UpdateDocument(auth, dbName, col, id, map[string]any{"step": "committed"})
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		doc, err := datastore.GetDocumentByID(auth, dbName, col, id)
+		if err != nil {
+			t.Fatal(err)
+		} else if doc["step"] != "committed" {
+			t.Errorf("expected the update to be committed got %v", doc)
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		before := count(t)
+
+		err := datastore.WithTransaction(func(tx database.Persister) error {
+			if _, err := tx.CreateDocument(auth, dbName, col, map[string]any{"step": "rollback"}); err != nil {
+				return err
+			}
+
+			// nested transactions are part of the outer one
+			err := tx.WithTransaction(func(nested database.Persister) error {
+				_, err := nested.CreateDocument(auth, dbName, col, map[string]any{"step": "nested"})
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			return errAbort
+		})
+		if !errors.Is(err, errAbort) {
+			t.Fatalf("expected the abort error got %v", err)
+		}
+
+		if after := count(t); after != before {
+			t.Errorf("expected %d docs after the rollback got %d", before, after)
+		}
+	})
+
+	t.Run("bulk create is atomic", func(t *testing.T) {
+		before := count(t)
+
+		docs := []interface{}{
+			map[string]any{"step": "bulk"},
+			map[string]any{"step": "bulk"},
+			"not a document",
+		}
+		if err := datastore.BulkCreateDocument(auth, dbName, col, docs); err == nil {
+			t.Fatal("expected an error for an invalid document")
+		}
+
+		if after := count(t); after != before {
+			t.Errorf("expected %d docs after the failed bulk create got %d", before, after)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package dbtest
+
+import (
+	"testing"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// TwoFactor checks that the two-factor authentication of a user and the
+// database settings are saved, replaced and removed
+func TwoFactor(t *testing.T, datastore database.Persister, auth model.Auth, dbName string) {
+	if tf, err := datastore.GetTwoFactor(dbName, auth.UserID); err != nil {
+		t.Fatal(err)
+	} else if len(tf.UserID) > 0 {
+		t.Fatalf("expected no two-factor authentication got %v", tf)
+	}
+
+	tf := model.TwoFactor{
+		UserID: auth.UserID,
+		Secret: []byte("encrypted-secret"),
+	}
+	if err := datastore.SaveTwoFactor(dbName, tf); err != nil {
+		t.Fatal(err)
+	}
+
+	tf.Enabled = true
+	tf.RecoveryCodes = []string{"hash1", "hash2"}
+	tf.LastStep = 42
+	if err := datastore.SaveTwoFactor(dbName, tf); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := datastore.GetTwoFactor(dbName, auth.UserID)
+	if err != nil {
+		t.Fatal(err)
+	} else if saved.UserID != auth.UserID || string(saved.Secret) != "encrypted-secret" {
+		t.Errorf("expected the two-factor authentication to be saved got %v", saved)
+	} else if !saved.Enabled || len(saved.RecoveryCodes) != 2 || saved.LastStep != 42 {
+		t.Errorf("expected the two-factor authentication to be replaced got %v", saved)
+	}
+
+	if err := datastore.DeleteTwoFactor(dbName, auth.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	if tf, err := datastore.GetTwoFactor(dbName, auth.UserID); err != nil {
+		t.Fatal(err)
+	} else if len(tf.UserID) > 0 {
+		t.Errorf("expected the two-factor authentication to be removed got %v", tf)
+	}
+
+	if s, err := datastore.GetSettings(dbName); err != nil {
+		t.Fatal(err)
+	} else if s.RequireRootTwoFactor {
+		t.Error("expected the default settings")
+	}
+
+	for _, require := range []bool{true, false} {
+		s := model.DatabaseSettings{RequireRootTwoFactor: require}
+		if err := datastore.SaveSettings(dbName, s); err != nil {
+			t.Fatal(err)
+		}
+
+		if saved, err := datastore.GetSettings(dbName); err != nil {
+			t.Fatal(err)
+		} else if saved.RequireRootTwoFactor != require {
+			t.Errorf("expected RequireRootTwoFactor to be %v", require)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package dbtest
+
+import (
+	"testing"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// EmailVerification checks that a user's email is marked as verified and the
+// email verification settings are saved
+func EmailVerification(t *testing.T, datastore database.Persister, auth model.Auth, dbName string) {
+	tok := model.User{
+		AccountID: auth.AccountID,
+		Email:     "verify@dbtest.com",
+		Token:     datastore.NewID(),
+		Password:  "not-a-hash",
+	}
+
+	id, err := datastore.CreateUser(dbName, tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user, err := datastore.FindUserByEmail(dbName, tok.Email); err != nil {
+		t.Fatal(err)
+	} else if user.EmailVerified {
+		t.Fatal("expected a new user not to be verified")
+	}
+
+	if err := datastore.SetEmailVerified(dbName, id); err != nil {
+		t.Fatal(err)
+	}
+
+	if user, err := datastore.GetUserByID(dbName, auth.AccountID, id); err != nil {
+		t.Fatal(err)
+	} else if !user.EmailVerified {
+		t.Error("expected the user's email to be verified")
+	}
+
+	s, err := datastore.GetSettings(dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer datastore.SaveSettings(dbName, s)
+
+	s.EmailVerification = model.EmailVerification{
+		Enabled:     true,
+		BlockLogin:  true,
+		Permissions: []string{"posts:write"},
+		Link:        "https://app.test.com/verify",
+		Subject:     "Verify your email",
+		Body:        "<a href='[link]'>verify</a>",
+	}
+	if err := datastore.SaveSettings(dbName, s); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := datastore.GetSettings(dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ev := saved.EmailVerification
+	if !ev.Enabled || !ev.BlockLogin || ev.Link != s.EmailVerification.Link || ev.Body != s.EmailVerification.Body {
+		t.Errorf("expected the email verification settings to be saved got %v", ev)
+	} else if !ev.Denies("posts:write") || ev.Denies("posts:read") {
+		t.Errorf("expected only posts:write to be denied got %v", ev.Permissions)
+	}
+}
@@ -0,0 +1,104 @@
+package dbtest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// Versioning checks that documents are versioned and that updates expecting
+// another version are rejected
+func Versioning(t *testing.T, datastore database.Persister, auth model.Auth, dbName string) {
+	col := "versions"
+
+	doc, err := datastore.CreateDocument(auth, dbName, col, map[string]any{"title": "v1", "n": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := doc["id"].(string)
+
+	version := func(t *testing.T) int64 {
+		doc, err := datastore.GetDocumentByID(auth, dbName, col, id)
+		if err != nil {
+			t.Fatal(err)
+		} else if _, ok := doc[database.FieldUpdated]; !ok {
+			t.Errorf("expected an updated field got %v", doc)
+		}
+		return database.Version(doc)
+	}
+
+	if v := version(t); v != 1 {
+		t.Fatalf("expected version 1 got %d", v)
+	}
+
+	t.Run("update increments the version", func(t *testing.T) {
+		updated, err := datastore.UpdateDocument(auth, dbName, col, id, map[string]any{"title": "v2"})
+		if err != nil {
+			t.Fatal(err)
+		} else if v := database.Version(updated); v != 2 {
+			t.Errorf("expected version 2 got %d", v)
+		}
+	})
+
+	t.Run("expected version matches", func(t *testing.T) {
+		update := map[string]any{"title": "v3", database.FieldVersion: 2}
+		updated, err := datastore.UpdateDocument(auth, dbName, col, id, update)
+		if err != nil {
+			t.Fatal(err)
+		} else if v := database.Version(updated); v != 3 {
+			t.Errorf("expected version 3 got %d", v)
+		} else if updated["title"] != "v3" {
+			t.Errorf("expected title v3 got %v", updated["title"])
+		}
+	})
+
+	t.Run("stale version conflicts", func(t *testing.T) {
+		update := map[string]any{"title": "stale", database.FieldVersion: 2}
+		_, err := datastore.UpdateDocument(auth, dbName, col, id, update)
+		if !errors.Is(err, database.ErrVersionConflict) {
+			t.Fatalf("expected a version conflict got %v", err)
+		}
+
+		doc, err := datastore.GetDocumentByID(auth, dbName, col, id)
+		if err != nil {
+			t.Fatal(err)
+		} else if doc["title"] != "v3" {
+			t.Errorf("expected the stale update to be rejected got %v", doc["title"])
+		} else if v := database.Version(doc); v != 3 {
+			t.Errorf("expected version 3 got %d", v)
+		}
+	})
+
+	t.Run("update documents and increment", func(t *testing.T) {
+		filter, err := datastore.ParseQuery([][]any{{"title", "=", "v3"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n, err := datastore.UpdateDocuments(auth, dbName, col, filter, map[string]any{"tag": "bulk"}); err != nil {
+			t.Fatal(err)
+		} else if n != 1 {
+			t.Fatalf("expected 1 updated document got %d", n)
+		}
+
+		if v := version(t); v != 4 {
+			t.Errorf("expected version 4 got %d", v)
+		}
+
+		if err := datastore.IncrementValue(auth, dbName, col, id, "n", 2); err != nil {
+			t.Fatal(err)
+		}
+
+		doc, err := datastore.GetDocumentByID(auth, dbName, col, id)
+		if err != nil {
+			t.Fatal(err)
+		} else if v := database.Version(doc); v != 5 {
+			t.Errorf("expected version 5 got %d", v)
+		} else if doc["title"] != "v3" || doc["tag"] != "bulk" {
+			t.Errorf("expected the other fields to be kept got %v", doc)
+		}
+	})
+}
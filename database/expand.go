@@ -0,0 +1,139 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+// expandBatchSize is the maximum number of IDs fetched at once per collection
+const expandBatchSize = 100
+
+var validExpandCollection = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Expansion replaces the document IDs stored in Field, a single ID or a list
+// of IDs, by the documents of Collection
+type Expansion struct {
+	Field      string
+	Collection string
+}
+
+// ParseExpand parses expansions in the "field:collection" format, a value may
+// hold multiple expansions separated by a comma
+func ParseExpand(values []string) (expansions []Expansion, err error) {
+	seen := make(map[string]bool)
+
+	for _, value := range values {
+		for _, s := range strings.Split(value, ",") {
+			s = strings.TrimSpace(s)
+			if len(s) == 0 {
+				continue
+			}
+
+			field, col, ok := strings.Cut(s, ":")
+			if !ok || !validProjectionField.MatchString(field) || !validExpandCollection.MatchString(col) {
+				return nil, fmt.Errorf("invalid expand %q, the format is field:collection", s)
+			} else if strings.HasPrefix(col, "sb_") {
+				return nil, fmt.Errorf("invalid expand %q, system collections cannot be expanded", s)
+			} else if seen[field] {
+				return nil, fmt.Errorf("the field %s is expanded more than once", field)
+			}
+
+			seen[field] = true
+			expansions = append(expansions, Expansion{Field: field, Collection: col})
+		}
+	}
+	return
+}
+
+// Expand resolves the expansions of the documents in place. The referenced
+// documents are fetched in batches with GetDocumentsByIDs so the read
+// permissions of their collection apply, references that cannot be read are
+// set to nil or removed from their list.
+func Expand(datastore Persister, auth model.Auth, dbName string, docs []map[string]any, expansions []Expansion) error {
+	for _, exp := range expansions {
+		var ids []string
+		seen := make(map[string]bool)
+		for _, doc := range docs {
+			for _, id := range referencedIDs(doc[exp.Field]) {
+				if !seen[id] {
+					seen[id] = true
+					ids = append(ids, id)
+				}
+			}
+		}
+
+		refs := make(map[string]map[string]any)
+		for start := 0; start < len(ids); start += expandBatchSize {
+			end := start + expandBatchSize
+			if end > len(ids) {
+				end = len(ids)
+			}
+
+			list, err := datastore.GetDocumentsByIDs(auth, dbName, exp.Collection, ids[start:end])
+			if err != nil {
+				return fmt.Errorf("unable to expand %s: %w", exp.Field, err)
+			}
+
+			for _, ref := range list {
+				if id, ok := ref["id"].(string); ok {
+					refs[id] = ref
+				}
+			}
+		}
+
+		for _, doc := range docs {
+			v, ok := doc[exp.Field]
+			if !ok || v == nil {
+				continue
+			}
+
+			if id, ok := v.(string); ok {
+				if ref, ok := refs[id]; ok {
+					doc[exp.Field] = ref
+				} else {
+					doc[exp.Field] = nil
+				}
+				continue
+			}
+
+			if !isList(reflect.ValueOf(v)) {
+				continue
+			}
+
+			expanded := make([]any, 0)
+			for _, id := range referencedIDs(v) {
+				if ref, ok := refs[id]; ok {
+					expanded = append(expanded, ref)
+				}
+			}
+			doc[exp.Field] = expanded
+		}
+	}
+	return nil
+}
+
+// referencedIDs returns the IDs of a field holding an ID or a list of IDs
+func referencedIDs(v any) (ids []string) {
+	if id, ok := v.(string); ok {
+		if len(id) > 0 {
+			ids = append(ids, id)
+		}
+		return
+	}
+
+	rv := reflect.ValueOf(v)
+	if !isList(rv) {
+		return
+	}
+
+	for i := 0; i < rv.Len(); i++ {
+		if id, ok := rv.Index(i).Interface().(string); ok && len(id) > 0 {
+			ids = append(ids, id)
+		}
+	}
+	return
+}
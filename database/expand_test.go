@@ -0,0 +1,43 @@
+package database
+
+import "testing"
+
+func TestParseExpand(t *testing.T) {
+	expansions, err := ParseExpand([]string{"author:users, tags:tags", "owner_team:teams"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Expansion{
+		{Field: "author", Collection: "users"},
+		{Field: "tags", Collection: "tags"},
+		{Field: "owner_team", Collection: "teams"},
+	}
+
+	if len(expansions) != len(expected) {
+		t.Fatalf("expected %d expansions got %v", len(expected), expansions)
+	}
+
+	for i, exp := range expected {
+		if expansions[i] != exp {
+			t.Errorf("expected %v got %v", exp, expansions[i])
+		}
+	}
+}
+
+func TestParseExpandErrors(t *testing.T) {
+	tests := []string{
+		"author",
+		"author:",
+		":users",
+		"author:users;drop",
+		"author:sb_accounts",
+		"author:users,author:members",
+	}
+
+	for _, s := range tests {
+		if _, err := ParseExpand([]string{s}); err == nil {
+			t.Errorf("expected an error for %s", s)
+		}
+	}
+}
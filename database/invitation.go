@@ -0,0 +1,18 @@
+package database
+
+import "fmt"
+
+// InvitationTokenPrefix prefixes the invitation tokens so they're
+// distinguishable from the other tokens
+const InvitationTokenPrefix = "sbi_"
+
+// NewInvitationToken returns the token emailed to the invitee and the hash of
+// its secret that's stored with the invitation
+func NewInvitationToken(id, secret string) (token, hash string) {
+	return fmt.Sprintf("%s%s.%s", InvitationTokenPrefix, id, secret), HashAPIKeySecret(secret)
+}
+
+// ParseInvitationToken returns the invitation ID and secret of a token
+func ParseInvitationToken(token string) (id, secret string, ok bool) {
+	return parseSecretKey(InvitationTokenPrefix, token)
+}
@@ -0,0 +1,289 @@
+package database
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Match returns true if the document satisfies all the clauses of the
+// filter. It's used where documents are filtered outside of the database
+// engine, i.e. the memory data store and realtime events.
+func Match(doc map[string]any, filter Filter) bool {
+	for _, clause := range filter {
+		if !matchClause(doc, clause) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchGroup returns true if the document satisfies the nested group
+func matchGroup(doc map[string]any, clause Clause) bool {
+	switch clause.Group {
+	case GroupOr:
+		for _, c := range clause.Clauses {
+			if matchClause(doc, c) {
+				return true
+			}
+		}
+		return false
+	case GroupNot:
+		return !matchGroup(doc, Clause{Group: GroupAnd, Clauses: clause.Clauses})
+	default:
+		for _, c := range clause.Clauses {
+			if !matchClause(doc, c) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// matchClause returns true if the document's field satisfies the clause
+func matchClause(doc map[string]any, clause Clause) bool {
+	if clause.IsGroup() {
+		return matchGroup(doc, clause)
+	}
+
+	v, ok := Lookup(doc, clause.Field)
+
+	switch clause.Op {
+	case OpExists:
+		return ok
+	case OpNotExists:
+		return !ok
+	case OpContains, OpStartsWith, OpEqualFold, OpRegex:
+		s, isText := v.(string)
+		return isText && matchText(s, clause.Op, clause.Value.(string))
+	case OpBetween:
+		if v == nil {
+			return false
+		}
+
+		bounds := clause.Value.([]any)
+		lower, isKind := compare(v, bounds[0], clause.Kind)
+		if !isKind {
+			return false
+		}
+		upper, _ := compare(v, bounds[1], clause.Kind)
+		return lower >= 0 && upper <= 0
+	case OpContainsAny, OpContainsAll, OpSize:
+		items, isList := toList(v)
+		if !isList {
+			return false
+		}
+
+		switch clause.Op {
+		case OpContainsAny:
+			return containsAny(items, clause.Value.([]any))
+		case OpContainsAll:
+			for _, x := range clause.Value.([]any) {
+				if !containsAny(items, []any{x}) {
+					return false
+				}
+			}
+			return true
+		default:
+			return float64(len(items)) == clause.Value.(float64)
+		}
+	}
+
+	switch clause.Kind {
+	case KindNull:
+		isNull := !ok || v == nil
+		if clause.Op == OpNotEqual {
+			return !isNull
+		}
+		return isNull
+	case KindList:
+		found := containsAny(v, clause.Value.([]any))
+		if clause.Op == OpNotIn {
+			return ok && v != nil && !found
+		}
+		return found
+	}
+
+	if !ok || v == nil {
+		return false
+	}
+
+	n, ok := compare(v, clause.Value, clause.Kind)
+	if !ok {
+		return false
+	}
+
+	switch clause.Op {
+	case OpEqual:
+		return n == 0
+	case OpNotEqual:
+		return n != 0
+	case OpGreater:
+		return n > 0
+	case OpLower:
+		return n < 0
+	case OpGreaterThanEqual:
+		return n >= 0
+	case OpLowerThanEqual:
+		return n <= 0
+	}
+	return false
+}
+
+// compare returns -1, 0 or 1 when comparing the document value v with val
+// using the kind of the clause, ok is false if v is not of that kind.
+func compare(v any, val any, kind ValueKind) (n int, ok bool) {
+	switch kind {
+	case KindNumber:
+		x, ok := toNumber(v)
+		if !ok {
+			return 0, false
+		}
+		y := val.(float64)
+		if x < y {
+			return -1, true
+		} else if x > y {
+			return 1, true
+		}
+		return 0, true
+	case KindBool:
+		x, ok := v.(bool)
+		if !ok {
+			return 0, false
+		}
+		y := val.(bool)
+		if x == y {
+			return 0, true
+		} else if y {
+			return -1, true
+		}
+		return 1, true
+	case KindTime:
+		x, ok := toTime(v)
+		if !ok {
+			return 0, false
+		}
+		y := val.(time.Time)
+		if x.Before(y) {
+			return -1, true
+		} else if x.After(y) {
+			return 1, true
+		}
+		return 0, true
+	default:
+		x, ok := v.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(x, val.(string)), true
+	}
+}
+
+// Lookup returns the value of a document's field, a dotted field is the path
+// of a nested value, i.e. "address.city"
+func Lookup(doc map[string]any, field string) (any, bool) {
+	v, ok := doc[field]
+	if ok || !strings.Contains(field, ".") {
+		return v, ok
+	}
+
+	parts := strings.Split(field, ".")
+	var cur any = doc
+	for _, part := range parts {
+		m, isMap := cur.(map[string]any)
+		if !isMap {
+			return nil, false
+		}
+
+		if cur, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// matchText returns true if s matches the value with the string operator op
+func matchText(s, op, val string) bool {
+	switch op {
+	case OpContains:
+		return strings.Contains(s, val)
+	case OpStartsWith:
+		return strings.HasPrefix(s, val)
+	case OpEqualFold:
+		return strings.EqualFold(s, val)
+	case OpRegex:
+		// the pattern has been validated by ParseQuery
+		re, err := regexp.Compile(val)
+		return err == nil && re.MatchString(s)
+	}
+	return false
+}
+
+// containsAny returns true if v or one of its items if it's a slice is in list
+func containsAny(v any, list []any) bool {
+	items, ok := toList(v)
+	if !ok {
+		items = []any{v}
+	}
+
+	for _, item := range items {
+		for _, x := range list {
+			if equal(item, x) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// toList returns the items of v if it's a slice
+func toList(v any) ([]any, bool) {
+	if items, ok := v.([]any); ok {
+		return items, true
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+
+	items := make([]any, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		items[i] = rv.Index(i).Interface()
+	}
+	return items, true
+}
+
+func toTime(v any) (time.Time, bool) {
+	switch x := v.(type) {
+	case time.Time:
+		return x, true
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, x)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+// equal returns true if v is the list item val, they must be of the same
+// type, i.e. the number 10 does not equal "10"
+func equal(v any, val any) bool {
+	switch y := val.(type) {
+	case nil:
+		return v == nil
+	case string:
+		x, ok := v.(string)
+		return ok && x == y
+	case bool:
+		x, ok := v.(bool)
+		return ok && x == y
+	case float64:
+		x, ok := toNumber(v)
+		return ok && x == y
+	case time.Time:
+		x, ok := toTime(v)
+		return ok && x.Equal(y)
+	}
+	return reflect.DeepEqual(v, val)
+}
@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"encoding/json"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+func (m *Memory) Aggregate(auth model.Auth, dbName, col string, filter database.Filter, groupBy []string, metrics []database.Metric) ([]map[string]any, error) {
+	if err := database.ValidateAggregate(groupBy, metrics); err != nil {
+		return nil, err
+	}
+
+	list, err := all[map[string]any](m, dbName, col)
+	if err != nil {
+		return nil, err
+	}
+
+	if list, err = m.secureRead(auth, dbName, col, list); err != nil {
+		return nil, err
+	}
+
+	filtered := filterByClauses(list, filter)
+
+	var keys []string
+	groups := make(map[string][]map[string]any)
+	for _, doc := range filtered {
+		var values []any
+		for _, field := range groupBy {
+			v, _ := database.Lookup(doc, field)
+			values = append(values, v)
+		}
+
+		key := string(mustJSON(values))
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], doc)
+	}
+
+	// without group by all documents are a single group, even when empty
+	if len(groupBy) == 0 && len(keys) == 0 {
+		keys = append(keys, "")
+	}
+
+	var rows []map[string]any
+	for _, key := range keys {
+		docs := groups[key]
+
+		row := make(map[string]any)
+		for _, field := range groupBy {
+			row[field], _ = database.Lookup(docs[0], field)
+		}
+
+		for _, metric := range metrics {
+			row[metric.Name] = compute(metric, docs)
+		}
+
+		rows = append(rows, row)
+	}
+
+	sortSlice(rows, func(a, b map[string]any) bool {
+		for _, field := range groupBy {
+			if n := compareValues(a[field], b[field]); n != 0 {
+				return n < 0
+			}
+		}
+		return false
+	})
+
+	return rows, nil
+}
+
+// compute returns the metric value for the documents of a group
+func compute(metric database.Metric, docs []map[string]any) any {
+	if metric.Op == database.MetricCount {
+		return int64(len(docs))
+	}
+
+	if metric.Op == database.MetricCountDistinct {
+		distinct := make(map[string]bool)
+		for _, doc := range docs {
+			if v, _ := database.Lookup(doc, metric.Field); v != nil {
+				distinct[string(mustJSON(v))] = true
+			}
+		}
+		return int64(len(distinct))
+	}
+
+	var numbers []float64
+	for _, doc := range docs {
+		v, _ := database.Lookup(doc, metric.Field)
+		if f, ok := toFloat(v); ok {
+			numbers = append(numbers, f)
+		}
+	}
+
+	if len(numbers) == 0 {
+		return database.MetricValue(metric.Op, nil)
+	}
+
+	v := numbers[0]
+	for _, f := range numbers[1:] {
+		switch metric.Op {
+		case database.MetricMin:
+			if f < v {
+				v = f
+			}
+		case database.MetricMax:
+			if f > v {
+				v = f
+			}
+		default:
+			v += f
+		}
+	}
+
+	if metric.Op == database.MetricAvg {
+		v = v / float64(len(numbers))
+	}
+	return v
+}
+
+func mustJSON(v any) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return []byte{}
+	}
+	return b
+}
@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+func (m *Memory) CreateAPIKey(dbName string, key model.APIKey) error {
+	key.Created = time.Now()
+	return create(m, dbName, "sb_api_keys", key.ID, key)
+}
+
+func (m *Memory) ListAPIKeys(dbName string) ([]model.APIKey, error) {
+	list, err := all[model.APIKey](m, dbName, "sb_api_keys")
+	if err != nil {
+		return nil, err
+	}
+
+	sortSlice(list, func(a, b model.APIKey) bool {
+		return a.Created.After(b.Created)
+	})
+	return list, nil
+}
+
+func (m *Memory) GetAPIKey(dbName, id string) (key model.APIKey, err error) {
+	if err = getByID(m, dbName, "sb_api_keys", id, &key); err != nil {
+		return
+	} else if key.ID != id {
+		err = errDocumentNotFound
+	}
+	return
+}
+
+func (m *Memory) RevokeAPIKey(dbName, id string) error {
+	key := fmt.Sprintf("%s_sb_api_keys", dbName)
+
+	mx.Lock()
+	delete(m.DB[key], id)
+	mx.Unlock()
+	return nil
+}
+
+func (m *Memory) TouchAPIKey(dbName, id string, used time.Time) error {
+	key, err := m.GetAPIKey(dbName, id)
+	if err != nil {
+		return err
+	}
+
+	key.LastUsed = &used
+	return create(m, dbName, "sb_api_keys", key.ID, key)
+}
@@ -3,10 +3,12 @@ package memory
 import (
 	"errors"
 	"fmt"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/staticbackendhq/core/database"
 	"github.com/staticbackendhq/core/model"
 )
 
@@ -18,11 +20,19 @@ const (
 )
 
 func (m *Memory) CreateDocument(auth model.Auth, dbName, col string, doc map[string]interface{}) (map[string]interface{}, error) {
+	if err := m.enforceSchema(dbName, col, doc, false); err != nil {
+		return nil, err
+	} else if err := database.CanCreate(m, auth, dbName, col, doc); err != nil {
+		return nil, err
+	}
+
 	id := m.NewID()
 	doc[FieldID] = id
 	doc[FieldAccountID] = auth.AccountID
 	doc[FieldOwnerID] = auth.UserID
 	doc[FieldCreated] = time.Now()
+	doc[database.FieldVersion] = int64(1)
+	doc[database.FieldUpdated] = doc[FieldCreated]
 
 	if err := create(m, dbName, col, id, doc); err != nil {
 		return nil, err
@@ -34,12 +44,22 @@ func (m *Memory) CreateDocument(auth model.Auth, dbName, col string, doc map[str
 }
 
 func (m *Memory) BulkCreateDocument(auth model.Auth, dbName, col string, docs []interface{}) error {
+	// all documents are validated first so none are created on error
+	var list []map[string]any
 	for _, v := range docs {
 		doc, ok := v.(map[string]any)
 		if !ok {
 			return fmt.Errorf("cannot cast to map[sring]any")
+		} else if err := m.enforceSchema(dbName, col, doc, false); err != nil {
+			return err
+		} else if err := database.CanCreate(m, auth, dbName, col, doc); err != nil {
+			return err
 		}
 
+		list = append(list, doc)
+	}
+
+	for _, doc := range list {
 		if _, err := m.CreateDocument(auth, dbName, col, doc); err != nil {
 			return err
 		}
@@ -57,30 +77,14 @@ func (m *Memory) ListDocuments(auth model.Auth, dbName, col string, params model
 		return
 	}
 
-	list = secureRead(auth, col, list)
-
-	if params.SortDescending {
-		list = sortSlice(list, func(a, b map[string]any) bool {
-			return fmt.Sprintf("%v", a[FieldCreated]) > fmt.Sprintf("%v", b[FieldCreated])
-		})
-	}
-
-	start := (params.Page - 1) * params.Size
-	end := start + params.Size - 1
-
-	if l := int64(len(list)); end > l {
-		end = l
+	if list, err = m.secureRead(auth, dbName, col, list); err != nil {
+		return
 	}
 
-	result.Page = params.Page
-	result.Size = params.Size
-	result.Total = int64(len(list))
-	result.Results = list[start:end]
-
-	return
+	return paginate(list, params)
 }
 
-func (m *Memory) QueryDocuments(auth model.Auth, dbName, col string, filter map[string]any, params model.ListParams) (result model.PagedResult, err error) {
+func (m *Memory) QueryDocuments(auth model.Auth, dbName, col string, filter database.Filter, params model.ListParams) (result model.PagedResult, err error) {
 	list, err := all[map[string]any](m, dbName, col)
 	if err != nil {
 		if errors.Is(err, errCollectionNotFound) {
@@ -89,85 +93,142 @@ func (m *Memory) QueryDocuments(auth model.Auth, dbName, col string, filter map[
 		return
 	}
 
-	list = secureRead(auth, col, list)
+	if list, err = m.secureRead(auth, dbName, col, list); err != nil {
+		return
+	}
 
 	filtered := filterByClauses(list, filter)
 
-	start := (params.Page - 1) * params.Size
-	end := start + params.Size - 1
+	return paginate(filtered, params)
+}
 
-	if l := int64(len(filtered)); end > l {
-		end = l
+func (m *Memory) GetDocumentByID(auth model.Auth, dbName, col, id string, fields ...string) (doc map[string]interface{}, err error) {
+	projection, err := database.ParseFields(fields)
+	if err != nil {
+		return
 	}
 
-	result.Page = params.Page
-	result.Size = params.Size
-	result.Total = int64(len(filtered))
-	result.Results = filtered[start:end]
-
-	return
-}
-
-func (m *Memory) GetDocumentByID(auth model.Auth, dbName, col, id string) (doc map[string]interface{}, err error) {
 	err = getByID(m, dbName, col, id, &doc)
 
-	list := secureRead(auth, col, []map[string]any{doc})
-	if len(list) == 0 {
+	list, rerr := m.secureRead(auth, dbName, col, []map[string]any{doc})
+	if rerr != nil {
+		err = rerr
+	} else if len(list) == 0 {
 		err = errors.New("not authorized")
 	} else {
 		doc = list[0]
+		projection.Apply(doc)
 	}
 	return
 }
 
-func (m *Memory) GetDocumentsByIDs(auth model.Auth, dbName, col string, ids []string) (docs []map[string]interface{}, err error) {
+func (m *Memory) GetDocumentsByIDs(auth model.Auth, dbName, col string, ids []string, fields ...string) (docs []map[string]interface{}, err error) {
+	projection, err := database.ParseFields(fields)
+	if err != nil {
+		return []map[string]interface{}{}, err
+	}
 
 	for _, id := range ids {
 		var doc map[string]interface{}
-		if err := getByID(m, dbName, col, id, &doc); err != nil {
+		if err := getByID(m, dbName, col, id, &doc); errors.Is(err, errDocumentNotFound) {
+			// like the other persisters, missing documents are skipped
+			continue
+		} else if err != nil {
 			return []map[string]interface{}{}, err
 		}
 		docs = append(docs, doc)
 	}
 
-	docs = secureRead(auth, col, docs)
+	if docs, err = m.secureRead(auth, dbName, col, docs); err != nil {
+		return []map[string]interface{}{}, err
+	}
+
+	for _, doc := range docs {
+		projection.Apply(doc)
+	}
 	return docs, nil
 }
 
 func (m *Memory) UpdateDocument(auth model.Auth, dbName, col, id string, doc map[string]any) (exists map[string]any, err error) {
+	expected, hasExpected, err := database.ExpectedVersion(doc)
+	if err != nil {
+		return
+	}
+
 	exists, err = m.GetDocumentByID(auth, dbName, col, id)
 	if err != nil {
 		return
-	} else if !canWrite(auth, col, exists) {
+	}
+
+	ok, err := m.canWrite(auth, dbName, col, exists)
+	if err != nil {
+		return
+	} else if !ok {
 		err = errors.New("not authorized")
 		return
 	}
 
+	version := database.Version(exists)
+	if hasExpected && version != expected {
+		return nil, database.ErrVersionConflict
+	}
+
 	removeNotEditableFields(doc)
 
+	if err = m.enforceSchema(dbName, col, doc, true); err != nil {
+		return nil, err
+	} else if err = m.canUpdate(auth, dbName, col, []map[string]any{exists}, doc); err != nil {
+		return nil, err
+	}
+
 	for k, v := range doc {
 		exists[k] = v
 	}
 
+	exists[database.FieldVersion] = version + 1
+	exists[database.FieldUpdated] = time.Now()
+
 	err = create(m, dbName, col, id, exists)
 
-	m.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBUpdated, exists)
+	m.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBUpdated, database.Updated(exists, database.ChangedFields(doc)))
 
 	return
 }
 
-func (m *Memory) UpdateDocuments(auth model.Auth, dbName, col string, filter map[string]interface{}, updateFields map[string]interface{}) (n int64, err error) {
+func (m *Memory) UpdateDocuments(auth model.Auth, dbName, col string, filter database.Filter, updateFields map[string]interface{}) (n int64, err error) {
 	list, err := all[map[string]any](m, dbName, col)
 
 	if err != nil {
 		return
+	} else if list, err = m.secureRead(auth, dbName, col, list); err != nil {
+		return
 	}
-	list = secureRead(auth, col, list)
 
 	removeNotEditableFields(updateFields)
 	filtered := filterByClauses(list, filter)
 
+	// the version is not checked for multiple documents
+	if _, _, err = database.ExpectedVersion(updateFields); err != nil {
+		return
+	} else if err = m.enforceSchema(dbName, col, updateFields, true); err != nil {
+		return
+	}
+
+	var writable []map[string]any
 	for _, v := range filtered {
+		if ok, err := m.canWrite(auth, dbName, col, v); err != nil {
+			return n, err
+		} else if ok {
+			writable = append(writable, v)
+		}
+	}
+
+	// none of the documents are updated if one would be out of the rule
+	if err = m.canUpdate(auth, dbName, col, writable, updateFields); err != nil {
+		return
+	}
+
+	for _, v := range writable {
 		_, err := m.UpdateDocument(auth, dbName, col, v[FieldID].(string), updateFields)
 		if err != nil {
 			return n, err
@@ -182,7 +243,11 @@ func (m *Memory) IncrementValue(auth model.Auth, dbName, col, id, field string,
 	doc, err := m.GetDocumentByID(auth, dbName, col, id)
 	if err != nil {
 		return err
-	} else if !canWrite(auth, col, doc) {
+	}
+
+	if ok, err := m.canWrite(auth, dbName, col, doc); err != nil {
+		return err
+	} else if !ok {
 		return errors.New("unauthorized")
 	}
 
@@ -199,8 +264,10 @@ func (m *Memory) IncrementValue(auth model.Auth, dbName, col, id, field string,
 	i += n
 
 	doc[field] = i
+	doc[database.FieldVersion] = database.Version(doc) + 1
+	doc[database.FieldUpdated] = time.Now()
 
-	m.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBUpdated, doc)
+	m.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBUpdated, database.Updated(doc, []string{field}))
 
 	return create(m, dbName, col, id, doc)
 }
@@ -209,7 +276,12 @@ func (m *Memory) DeleteDocument(auth model.Auth, dbName, col, id string) (n int6
 	doc, err := m.GetDocumentByID(auth, dbName, col, id)
 	if err != nil {
 		return
-	} else if !canWrite(auth, col, doc) {
+	}
+
+	ok, err := m.canWrite(auth, dbName, col, doc)
+	if err != nil {
+		return
+	} else if !ok {
 		err = errors.New("not authorized")
 		return
 	}
@@ -233,13 +305,14 @@ func (m *Memory) DeleteDocument(auth model.Auth, dbName, col, id string) (n int6
 	return
 }
 
-func (m *Memory) DeleteDocuments(auth model.Auth, dbName, col string, filters map[string]any) (n int64, err error) {
+func (m *Memory) DeleteDocuments(auth model.Auth, dbName, col string, filters database.Filter) (n int64, err error) {
 	list, err := all[map[string]any](m, dbName, col)
 
 	if err != nil {
 		return
+	} else if list, err = m.secureRead(auth, dbName, col, list); err != nil {
+		return
 	}
-	list = secureRead(auth, col, list)
 
 	filtered := filterByClauses(list, filters)
 
@@ -250,7 +323,9 @@ func (m *Memory) DeleteDocuments(auth model.Auth, dbName, col string, filters ma
 	}
 
 	for _, doc := range filtered {
-		if !canWrite(auth, col, doc) {
+		if ok, err := m.canWrite(auth, dbName, col, doc); err != nil {
+			return n, err
+		} else if !ok {
 			continue
 		}
 
@@ -279,43 +354,32 @@ func (m *Memory) ListCollections(dbName string) (repos []string, err error) {
 
 }
 
-func extractOperatorAndValue(s string) (op string, field string) {
-	parts := strings.Split(s, " ")
-	if len(parts) < 2 {
-		return
-	}
-
-	op = parts[0]
-	field = strings.Join(parts[1:], " ")
-	return
-}
-
 func removeNotEditableFields(m map[string]any) {
 	delete(m, FieldID)
 	delete(m, FieldAccountID)
 	delete(m, FieldOwnerID)
 }
 
-func equal(v any, val any) bool {
-	return fmt.Sprintf("%v", v) == fmt.Sprintf("%v", val)
-}
-
-func notEqual(v any, val any) bool {
-	return fmt.Sprintf("%v", v) != fmt.Sprintf("%v", val)
-}
-
-func greater(v any, val any) bool {
-	return fmt.Sprintf("%v", v) > fmt.Sprintf("%v", val)
-}
-
-func lower(v any, val any) bool {
-	return fmt.Sprintf("%v", v) < fmt.Sprintf("%v", val)
-}
-
-func greaterThanEqual(v any, val any) bool {
-	return fmt.Sprintf("%v", v) >= fmt.Sprintf("%v", val)
+func toFloat(v any) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
 }
 
-func lowerThanEqual(v any, val any) bool {
-	return fmt.Sprintf("%v", v) <= fmt.Sprintf("%v", val)
+func toTime(v any) (time.Time, bool) {
+	switch x := v.(type) {
+	case time.Time:
+		return x, true
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, x)
+		return t, err == nil
+	}
+	return time.Time{}, false
 }
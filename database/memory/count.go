@@ -1,16 +1,19 @@
 package memory
 
 import (
+	"github.com/staticbackendhq/core/database"
 	"github.com/staticbackendhq/core/model"
 )
 
-func (m *Memory) Count(auth model.Auth, dbName, col string, filter map[string]interface{}) (int64, error) {
+func (m *Memory) Count(auth model.Auth, dbName, col string, filter database.Filter) (int64, error) {
 	list, err := all[map[string]any](m, dbName, col)
 	if err != nil {
 		return -1, err
 	}
 
-	list = secureRead(auth, col, list)
+	if list, err = m.secureRead(auth, dbName, col, list); err != nil {
+		return -1, err
+	}
 
 	filtered := filterByClauses(list, filter)
 
@@ -2,6 +2,8 @@ package memory
 
 import (
 	"testing"
+
+	"github.com/staticbackendhq/core/database/dbtest"
 )
 
 func TestCount(t *testing.T) {
@@ -53,3 +55,7 @@ func TestCountWIthNoFilters(t *testing.T) {
 		t.Fatalf("expected 19 got %v", count)
 	}
 }
+
+func TestAggregate(t *testing.T) {
+	dbtest.Aggregate(t, datastore, adminAuth, confDBName)
+}
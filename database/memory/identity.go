@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+// identityMx keeps the provider and subject of the identities unique
+var identityMx sync.Mutex
+
+func (m *Memory) AddIdentity(dbName string, ident model.Identity) (id string, err error) {
+	identityMx.Lock()
+	defer identityMx.Unlock()
+
+	existing, err := m.FindIdentity(dbName, ident.Provider, ident.Subject)
+	if err != nil {
+		return
+	} else if len(existing.ID) > 0 {
+		err = errors.New("this identity is already linked to a user")
+		return
+	}
+
+	ident.ID = m.NewID()
+	ident.Linked = time.Now()
+	err = create(m, dbName, "sb_identities", ident.ID, ident)
+	return ident.ID, err
+}
+
+func (m *Memory) FindIdentity(dbName, provider, subject string) (model.Identity, error) {
+	list, err := all[model.Identity](m, dbName, "sb_identities")
+	if err != nil {
+		return model.Identity{}, err
+	}
+
+	for _, ident := range list {
+		if ident.Provider == provider && ident.Subject == subject {
+			return ident, nil
+		}
+	}
+	return model.Identity{}, nil
+}
+
+func (m *Memory) ListIdentities(dbName, userID string) ([]model.Identity, error) {
+	list, err := all[model.Identity](m, dbName, "sb_identities")
+	if err != nil {
+		return nil, err
+	}
+
+	list = filter(list, func(ident model.Identity) bool {
+		return ident.UserID == userID
+	})
+
+	sortSlice(list, func(a, b model.Identity) bool {
+		return a.Linked.Before(b.Linked)
+	})
+	return list, nil
+}
+
+func (m *Memory) DeleteIdentity(dbName, userID, id string) error {
+	var ident model.Identity
+	if err := getByID(m, dbName, "sb_identities", id, &ident); err != nil {
+		return nil
+	} else if ident.UserID != userID {
+		return nil
+	}
+
+	key := fmt.Sprintf("%s_sb_identities", dbName)
+
+	mx.Lock()
+	delete(m.DB[key], id)
+	mx.Unlock()
+	return nil
+}
+
+func (m *Memory) DeleteIdentities(dbName, userID string) error {
+	list, err := m.ListIdentities(dbName, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, ident := range list {
+		if err := m.DeleteIdentity(dbName, userID, ident.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
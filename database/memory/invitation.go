@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+func (m *Memory) CreateInvitation(dbName string, inv model.Invitation) error {
+	inv.Created = time.Now()
+	return create(m, dbName, "sb_invitations", inv.ID, inv)
+}
+
+func (m *Memory) GetInvitation(dbName, id string) (inv model.Invitation, err error) {
+	if err = getByID(m, dbName, "sb_invitations", id, &inv); err != nil {
+		return
+	} else if inv.ID != id {
+		err = errDocumentNotFound
+	}
+	return
+}
+
+func (m *Memory) ListInvitations(dbName, accountID string) ([]model.Invitation, error) {
+	list, err := all[model.Invitation](m, dbName, "sb_invitations")
+	if err != nil {
+		return nil, err
+	}
+
+	list = filter(list, func(inv model.Invitation) bool {
+		return inv.AccountID == accountID
+	})
+
+	sortSlice(list, func(a, b model.Invitation) bool {
+		return a.Created.After(b.Created)
+	})
+	return list, nil
+}
+
+func (m *Memory) DeleteInvitation(dbName, accountID, id string) error {
+	inv, err := m.GetInvitation(dbName, id)
+	if err != nil || inv.AccountID != accountID {
+		return nil
+	}
+
+	key := fmt.Sprintf("%s_sb_invitations", dbName)
+
+	mx.Lock()
+	delete(m.DB[key], id)
+	mx.Unlock()
+	return nil
+}
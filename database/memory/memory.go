@@ -14,6 +14,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/staticbackendhq/core/cache"
 	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
 )
 
 /*const (
@@ -22,7 +23,10 @@ import (
 	FieldOwnerID   = "ownerId"
 )*/
 
-var errCollectionNotFound = errors.New("collection not found")
+var (
+	errCollectionNotFound = errors.New("collection not found")
+	errDocumentNotFound   = errors.New("document not found")
+)
 
 func init() {
 	gob.Register(map[string]any{})
@@ -35,6 +39,9 @@ var mx *sync.RWMutex = &sync.RWMutex{}
 type Memory struct {
 	DB              map[string]map[string][]byte
 	PublishDocument cache.PublishDocumentEvent
+
+	// tx is set for the persister passed to WithTransaction
+	tx bool
 }
 
 func New(pubdoc cache.PublishDocumentEvent) database.Persister {
@@ -109,7 +116,7 @@ func getByID[T any](m *Memory, dbName, col, id string, v T) error {
 
 	b, ok := repo[id]
 	if !ok {
-		return errors.New("document not found")
+		return errDocumentNotFound
 	} else if err := mustDec(b, v); err != nil {
 		return err
 	}
@@ -153,40 +160,9 @@ func filter[T any](list []T, fn func(x T) bool) []T {
 	return results
 }
 
-func filterByClauses(list []map[string]any, filter map[string]any) (filtered []map[string]any) {
+func filterByClauses(list []map[string]any, filter database.Filter) (filtered []map[string]any) {
 	for _, doc := range list {
-		matches := 0
-		for k, v := range filter {
-			op, field := extractOperatorAndValue(k)
-			switch op {
-			case "=":
-				if equal(doc[field], v) {
-					matches++
-				}
-			case "!=":
-				if notEqual(doc[field], v) {
-					matches++
-				}
-			case ">":
-				if greater(doc[field], v) {
-					matches++
-				}
-			case "<":
-				if lower(doc[field], v) {
-					matches++
-				}
-			case ">=":
-				if greaterThanEqual(doc[field], v) {
-					matches++
-				}
-			case "<=":
-				if lowerThanEqual(doc[field], v) {
-					matches++
-				}
-			}
-		}
-
-		if matches == len(filter) {
+		if database.Match(doc, filter) {
 			filtered = append(filtered, doc)
 		}
 	}
@@ -200,6 +176,161 @@ func sortSlice[T any](list []T, fn func(a, b T) bool) []T {
 	return list
 }
 
+// paginate sorts the documents on the (sort keys, id) and returns the page
+// starting at the params' page offset or after its cursor with the fields
+// projection applied
+func paginate(list []map[string]any, params model.ListParams) (result model.PagedResult, err error) {
+	keys, err := database.SortKeys(params)
+	if err != nil {
+		return
+	}
+
+	projection, err := database.ParseFields(params.Fields)
+	if err != nil {
+		return
+	}
+
+	fields := make([]string, len(keys))
+	for i, key := range keys {
+		switch key.Field {
+		case database.SortCreated:
+			fields[i] = FieldCreated
+		case database.SortID:
+			fields[i] = FieldID
+		default:
+			fields[i] = key.Field
+		}
+	}
+
+	// position returns the order of the document compared to the values and id
+	position := func(doc map[string]any, values []any, id string) int {
+		for i, key := range keys {
+			n := compareValues(doc[fields[i]], values[i])
+			if key.Field == database.SortID {
+				n = strings.Compare(fmt.Sprintf("%v", doc[FieldID]), id)
+			}
+
+			if n != 0 {
+				if key.Descending {
+					return -n
+				}
+				return n
+			}
+		}
+
+		n := strings.Compare(fmt.Sprintf("%v", doc[FieldID]), id)
+		if database.IDDescending(keys) {
+			return -n
+		}
+		return n
+	}
+
+	valuesOf := func(doc map[string]any) []any {
+		values := make([]any, len(fields))
+		for i, field := range fields {
+			if field != FieldID {
+				values[i], _ = database.Lookup(doc, field)
+			}
+		}
+		return values
+	}
+
+	list = sortSlice(list, func(a, b map[string]any) bool {
+		return position(a, valuesOf(b), fmt.Sprintf("%v", b[FieldID])) < 0
+	})
+
+	result.Page = params.Page
+	result.Size = params.Size
+	result.Total = int64(len(list))
+
+	start := (params.Page - 1) * params.Size
+	if len(params.Cursor) > 0 {
+		c, err := database.DecodeCursor(params)
+		if err != nil {
+			return result, err
+		}
+
+		start = int64(sort.Search(len(list), func(i int) bool {
+			return position(list[i], c.Values, c.ID) > 0
+		}))
+	}
+
+	if l := int64(len(list)); start > l {
+		start = l
+	}
+
+	end := start + params.Size
+	if l := int64(len(list)); end > l {
+		end = l
+	}
+
+	result.Results = list[start:end]
+
+	if n := int64(len(result.Results)); n > 0 && n == params.Size {
+		last := result.Results[n-1]
+		result.Cursor = database.EncodeCursor(params, valuesOf(last), fmt.Sprintf("%v", last[FieldID]))
+	}
+
+	for _, doc := range result.Results {
+		projection.Apply(doc)
+	}
+	return
+}
+
+// compareValues returns -1, 0 or 1 when comparing a and b. Missing values
+// are sorted first and other values are compared based on their type.
+func compareValues(a, b any) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	_, aTime := a.(time.Time)
+	_, bTime := b.(time.Time)
+	if aTime || bTime {
+		if x, ok := toTime(a); ok {
+			if y, ok := toTime(b); ok {
+				if x.Before(y) {
+					return -1
+				} else if x.After(y) {
+					return 1
+				}
+				return 0
+			}
+		}
+	}
+
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			if x < y {
+				return -1
+			} else if x > y {
+				return 1
+			}
+			return 0
+		}
+	}
+
+	if x, ok := a.(bool); ok {
+		if y, ok := b.(bool); ok {
+			if x == y {
+				return 0
+			} else if y {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+}
+
 /*
 func create_bolt[T any](m *Memory, dbName, col, id string, v T) error {
 	bucketName := fmt.Sprintf("%s_%s", dbName, col)
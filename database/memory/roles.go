@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+func (m *Memory) ListRoles(dbName string) ([]model.Role, error) {
+	list, err := all[model.Role](m, dbName, "sb_roles")
+	if err != nil {
+		return nil, err
+	}
+
+	sortSlice(list, func(a, b model.Role) bool {
+		return a.Level < b.Level
+	})
+	return list, nil
+}
+
+func (m *Memory) SaveRole(dbName string, role model.Role) error {
+	list, err := m.ListRoles(dbName)
+	if err != nil {
+		return err
+	}
+
+	role.ID = m.NewID()
+	role.Created = time.Now()
+	for _, r := range list {
+		if r.Name == role.Name {
+			role.ID = r.ID
+			role.Created = r.Created
+		}
+	}
+
+	return create(m, dbName, "sb_roles", role.ID, role)
+}
+
+func (m *Memory) DeleteRole(dbName, name string) error {
+	list, err := m.ListRoles(dbName)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s_sb_roles", dbName)
+
+	mx.Lock()
+	defer mx.Unlock()
+
+	for _, r := range list {
+		if r.Name == name {
+			delete(m.DB[key], r.ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+func (m *Memory) ListRules(dbName string) ([]model.CollectionRules, error) {
+	list, err := all[model.CollectionRules](m, dbName, "sb_rules")
+	if err != nil {
+		return nil, err
+	}
+
+	sortSlice(list, func(a, b model.CollectionRules) bool {
+		return a.Collection < b.Collection
+	})
+	return list, nil
+}
+
+func (m *Memory) GetRules(dbName, col string) (rules model.CollectionRules, ok bool, err error) {
+	list, err := m.ListRules(dbName)
+	if err != nil {
+		return
+	}
+
+	for _, r := range list {
+		if r.Collection == col {
+			return r, true, nil
+		}
+	}
+	return
+}
+
+func (m *Memory) SaveRules(dbName string, rules model.CollectionRules) error {
+	existing, ok, err := m.GetRules(dbName, rules.Collection)
+	if err != nil {
+		return err
+	} else if ok {
+		rules.ID = existing.ID
+	} else {
+		rules.ID = m.NewID()
+	}
+
+	rules.Updated = time.Now()
+
+	return create(m, dbName, "sb_rules", rules.ID, rules)
+}
+
+func (m *Memory) DeleteRules(dbName, col string) error {
+	rules, ok, err := m.GetRules(dbName, col)
+	if err != nil || !ok {
+		return err
+	}
+
+	key := fmt.Sprintf("%s_sb_rules", dbName)
+
+	mx.Lock()
+	delete(m.DB[key], rules.ID)
+	mx.Unlock()
+	return nil
+}
+
+// secureRead returns the documents the caller can read, the collection's
+// rule replaces the permissions from its name
+func (m *Memory) secureRead(auth model.Auth, dbName, col string, list []map[string]any) ([]map[string]any, error) {
+	rule, ruled, err := database.SecureFilter(m, auth, dbName, col, false, nil)
+	if err != nil {
+		return nil, err
+	} else if !ruled {
+		return secureRead(auth, col, list), nil
+	}
+	return filterByClauses(list, rule), nil
+}
+
+// canWrite returns true if the caller can write the document, the
+// collection's rule replaces the permissions from its name
+func (m *Memory) canWrite(auth model.Auth, dbName, col string, doc map[string]any) (bool, error) {
+	rule, ruled, err := database.SecureFilter(m, auth, dbName, col, true, nil)
+	if err != nil {
+		return false, err
+	} else if !ruled {
+		return canWrite(auth, col, doc), nil
+	}
+	return database.Match(doc, rule), nil
+}
+
+// canUpdate returns database.ErrPermissionDenied when one of the documents
+// would not satisfy the collection's write rule once updated
+func (m *Memory) canUpdate(auth model.Auth, dbName, col string, docs []map[string]any, update map[string]any) error {
+	rule, ruled, err := database.SecureFilter(m, auth, dbName, col, true, nil)
+	if err != nil || !ruled {
+		return err
+	}
+
+	for _, doc := range docs {
+		if err := database.CanUpdate(rule, doc, update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
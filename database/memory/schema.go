@@ -0,0 +1,74 @@
+package memory
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+func (m *Memory) ListSchemas(dbName string) ([]model.CollectionSchema, error) {
+	list, err := all[model.CollectionSchema](m, dbName, "sb_schemas")
+	if err != nil {
+		return nil, err
+	}
+
+	sortSlice(list, func(a, b model.CollectionSchema) bool {
+		return a.Collection < b.Collection
+	})
+	return list, nil
+}
+
+func (m *Memory) GetSchema(dbName, col string) (schema model.CollectionSchema, ok bool, err error) {
+	list, err := m.ListSchemas(dbName)
+	if err != nil {
+		return
+	}
+
+	for _, s := range list {
+		if s.Collection == col {
+			return s, true, nil
+		}
+	}
+	return
+}
+
+func (m *Memory) SaveSchema(dbName string, schema model.CollectionSchema) error {
+	existing, ok, err := m.GetSchema(dbName, schema.Collection)
+	if err != nil {
+		return err
+	} else if ok {
+		schema.ID = existing.ID
+	} else {
+		schema.ID = m.NewID()
+	}
+
+	schema.Updated = time.Now()
+
+	return create(m, dbName, "sb_schemas", schema.ID, schema)
+}
+
+func (m *Memory) DeleteSchema(dbName, col string) error {
+	schema, ok, err := m.GetSchema(dbName, col)
+	if err != nil || !ok {
+		return err
+	}
+
+	key := fmt.Sprintf("%s_sb_schemas", dbName)
+
+	mx.Lock()
+	delete(m.DB[key], schema.ID)
+	mx.Unlock()
+	return nil
+}
+
+// enforceSchema validates the document against the collection's schema
+// when it has one
+func (m *Memory) enforceSchema(dbName, col string, doc map[string]any, partial bool) error {
+	schema, ok, err := m.GetSchema(dbName, col)
+	if err != nil || !ok {
+		return err
+	}
+	return database.ApplySchema(schema.Schema, doc, partial)
+}
@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+// rotateMx makes RotateSession's compare and swap atomic
+var rotateMx sync.Mutex
+
+func (m *Memory) CreateSession(dbName string, s model.Session) error {
+	s.Created = time.Now()
+	s.Refreshed = s.Created
+	return create(m, dbName, "sb_sessions", s.ID, s)
+}
+
+func (m *Memory) GetSession(dbName, id string) (s model.Session, err error) {
+	if err = getByID(m, dbName, "sb_sessions", id, &s); err != nil {
+		return
+	} else if s.ID != id {
+		err = errDocumentNotFound
+	}
+	return
+}
+
+func (m *Memory) ListSessions(dbName, userID string) ([]model.Session, error) {
+	list, err := all[model.Session](m, dbName, "sb_sessions")
+	if err != nil {
+		return nil, err
+	}
+
+	list = filter(list, func(s model.Session) bool {
+		return s.UserID == userID
+	})
+
+	sortSlice(list, func(a, b model.Session) bool {
+		return a.Created.After(b.Created)
+	})
+	return list, nil
+}
+
+func (m *Memory) RotateSession(dbName, id, oldHash, newHash string, expires time.Time) (bool, error) {
+	rotateMx.Lock()
+	defer rotateMx.Unlock()
+
+	s, err := m.GetSession(dbName, id)
+	if err != nil {
+		return false, nil
+	} else if s.RefreshHash != oldHash {
+		return false, nil
+	}
+
+	s.RefreshHash = newHash
+	s.Refreshed = time.Now()
+	s.Expires = expires
+	return true, create(m, dbName, "sb_sessions", s.ID, s)
+}
+
+func (m *Memory) DeleteSession(dbName, id string) error {
+	key := fmt.Sprintf("%s_sb_sessions", dbName)
+
+	mx.Lock()
+	delete(m.DB[key], id)
+	mx.Unlock()
+	return nil
+}
+
+func (m *Memory) DeleteSessions(dbName, userID string) error {
+	list, err := m.ListSessions(dbName, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range list {
+		if err := m.DeleteSession(dbName, s.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
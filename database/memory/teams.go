@@ -0,0 +1,20 @@
+package memory
+
+import (
+	"errors"
+	"sort"
+)
+
+func (m *Memory) ListUserTeams(dbName, userID string) ([]string, error) {
+	var teams []string
+	if err := getByID(m, dbName, "sb_user_teams", userID, &teams); err != nil && !errors.Is(err, errDocumentNotFound) {
+		return nil, err
+	}
+
+	sort.Strings(teams)
+	return teams, nil
+}
+
+func (m *Memory) SetUserTeams(dbName, userID string, teams []string) error {
+	return create(m, dbName, "sb_user_teams", userID, teams)
+}
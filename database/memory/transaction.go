@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/staticbackendhq/core/database"
+)
+
+// txMx serializes the transactions
+var txMx = &sync.Mutex{}
+
+// WithTransaction runs fn on a copy of the data. When fn returns nil the
+// documents it created, changed or removed are applied to the data, other
+// changes made meanwhile outside of the transaction are kept.
+func (m *Memory) WithTransaction(fn func(tx database.Persister) error) error {
+	// nested transactions are part of the current one
+	if m.tx {
+		return fn(m)
+	}
+
+	txMx.Lock()
+	defer txMx.Unlock()
+
+	mx.Lock()
+	snapshot := copyDB(m.DB)
+	data := copyDB(m.DB)
+	mx.Unlock()
+
+	events := &database.TxEvents{}
+
+	txm := &Memory{DB: data, PublishDocument: events.Publish, tx: true}
+	if err := fn(txm); err != nil {
+		return err
+	}
+
+	mx.Lock()
+	for key, repo := range data {
+		live, ok := m.DB[key]
+		if !ok {
+			live = make(map[string][]byte)
+			m.DB[key] = live
+		}
+
+		before := snapshot[key]
+		for id, b := range repo {
+			if old, ok := before[id]; !ok || !bytes.Equal(old, b) {
+				live[id] = b
+			}
+		}
+
+		for id := range before {
+			if _, ok := repo[id]; !ok {
+				delete(live, id)
+			}
+		}
+	}
+
+	for key := range snapshot {
+		if _, ok := data[key]; !ok {
+			delete(m.DB, key)
+		}
+	}
+	mx.Unlock()
+
+	events.Flush(m.PublishDocument)
+	return nil
+}
+
+func copyDB(db map[string]map[string][]byte) map[string]map[string][]byte {
+	c := make(map[string]map[string][]byte)
+	for key, repo := range db {
+		docs := make(map[string][]byte)
+		for id, b := range repo {
+			docs[id] = b
+		}
+		c[key] = docs
+	}
+	return c
+}
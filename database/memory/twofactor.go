@@ -0,0 +1,49 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+func (m *Memory) GetTwoFactor(dbName, userID string) (tf model.TwoFactor, err error) {
+	if err = getByID(m, dbName, "sb_two_factor", userID, &tf); errors.Is(err, errDocumentNotFound) {
+		return model.TwoFactor{}, nil
+	}
+	return
+}
+
+func (m *Memory) SaveTwoFactor(dbName string, tf model.TwoFactor) error {
+	existing, err := m.GetTwoFactor(dbName, tf.UserID)
+	if err != nil {
+		return err
+	}
+
+	tf.Created = existing.Created
+	if tf.Created.IsZero() {
+		tf.Created = time.Now()
+	}
+	return create(m, dbName, "sb_two_factor", tf.UserID, tf)
+}
+
+func (m *Memory) DeleteTwoFactor(dbName, userID string) error {
+	key := fmt.Sprintf("%s_sb_two_factor", dbName)
+
+	mx.Lock()
+	delete(m.DB[key], userID)
+	mx.Unlock()
+	return nil
+}
+
+func (m *Memory) GetSettings(dbName string) (s model.DatabaseSettings, err error) {
+	if err = getByID(m, dbName, "sb_settings", "default", &s); errors.Is(err, errDocumentNotFound) {
+		return model.DatabaseSettings{}, nil
+	}
+	return
+}
+
+func (m *Memory) SaveSettings(dbName string, s model.DatabaseSettings) error {
+	return create(m, dbName, "sb_settings", "default", s)
+}
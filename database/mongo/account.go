@@ -28,6 +28,7 @@ type LocalToken struct {
 	Role      int                `bson:"role" json:"role"`
 	ResetCode string             `bson:"resetCode" json:"-"`
 	Created   time.Time          `bson:"created" json:"created"`
+	Verified  bool               `bson:"verified" json:"emailVerified"`
 }
 
 func toLocalToken(token model.User) LocalToken {
@@ -50,19 +51,21 @@ func toLocalToken(token model.User) LocalToken {
 		Role:      token.Role,
 		ResetCode: token.ResetCode,
 		Created:   token.Created,
+		Verified:  token.EmailVerified,
 	}
 }
 
 func fromLocalToken(tok LocalToken) model.User {
 	return model.User{
-		ID:        tok.ID.Hex(),
-		AccountID: tok.AccountID.Hex(),
-		Token:     tok.Token,
-		Email:     tok.Email,
-		Password:  tok.Password,
-		Role:      tok.Role,
-		ResetCode: tok.ResetCode,
-		Created:   tok.Created,
+		ID:            tok.ID.Hex(),
+		AccountID:     tok.AccountID.Hex(),
+		Token:         tok.Token,
+		Email:         tok.Email,
+		Password:      tok.Password,
+		Role:          tok.Role,
+		ResetCode:     tok.ResetCode,
+		Created:       tok.Created,
+		EmailVerified: tok.Verified,
 	}
 }
 
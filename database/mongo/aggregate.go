@@ -0,0 +1,131 @@
+package mongo
+
+import (
+	"fmt"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (mg *Mongo) Aggregate(auth model.Auth, dbName, col string, clauses database.Filter, groupBy []string, metrics []database.Metric) (rows []map[string]any, err error) {
+	if err = database.ValidateAggregate(groupBy, metrics); err != nil {
+		return
+	}
+
+	db := mg.Client.Database(dbName)
+
+	acctID, userID, err := parseObjectID(auth)
+	if err != nil {
+		return
+	}
+
+	filter := toBSON(clauses)
+
+	if err = mg.secure(auth, acctID, userID, dbName, col, false, filter); err != nil {
+		return
+	}
+
+	// group by fields and metric names are aliased since fields can contain
+	// dots which are not allowed as a $group key
+	id := bson.M{}
+	sort := bson.D{}
+	for i, field := range groupBy {
+		key := fmt.Sprintf("g%d", i)
+		id[key] = bson.M{"$ifNull": bson.A{"$" + field, nil}}
+		sort = append(sort, bson.E{Key: "_id." + key, Value: 1})
+	}
+
+	group := bson.M{"_id": id}
+	for i, metric := range metrics {
+		group[fmt.Sprintf("m%d", i)] = accumulator(metric)
+	}
+
+	pipeline := bson.A{
+		bson.M{"$match": filter},
+		bson.M{"$group": group},
+	}
+	if len(sort) > 0 {
+		pipeline = append(pipeline, bson.M{"$sort": sort})
+	}
+
+	cur, err := db.Collection(model.CleanCollectionName(col)).Aggregate(mg.Ctx, pipeline)
+	if err != nil {
+		return
+	}
+	defer cur.Close(mg.Ctx)
+
+	for cur.Next(mg.Ctx) {
+		var v bson.M
+		if err = cur.Decode(&v); err != nil {
+			return
+		}
+
+		keys, _ := v["_id"].(bson.M)
+
+		row := make(map[string]any)
+		for i, field := range groupBy {
+			row[field] = keys[fmt.Sprintf("g%d", i)]
+		}
+
+		for i, metric := range metrics {
+			value := v[fmt.Sprintf("m%d", i)]
+
+			// the distinct values are counted here, nulls are excluded
+			if set, ok := value.(primitive.A); ok {
+				n := 0
+				for _, x := range set {
+					if x != nil {
+						n++
+					}
+				}
+				value = n
+			}
+
+			row[metric.Name] = database.MetricValue(metric.Op, value)
+		}
+
+		rows = append(rows, row)
+	}
+
+	if err = cur.Err(); err != nil {
+		return
+	}
+
+	// without group by an empty collection still returns a single row
+	if len(groupBy) == 0 && len(rows) == 0 {
+		row := make(map[string]any)
+		for _, metric := range metrics {
+			row[metric.Name] = database.MetricValue(metric.Op, nil)
+		}
+		rows = append(rows, row)
+	}
+	return
+}
+
+// accumulator returns the $group accumulator of the metric, the number
+// metrics ignore values that are not numbers
+func accumulator(metric database.Metric) bson.M {
+	field := "$" + metric.Field
+	isNumber := bson.M{"$in": bson.A{
+		bson.M{"$type": field},
+		bson.A{"double", "int", "long", "decimal"},
+	}}
+	number := bson.M{"$cond": bson.A{isNumber, field, nil}}
+
+	switch metric.Op {
+	case database.MetricCountDistinct:
+		return bson.M{"$addToSet": field}
+	case database.MetricSum:
+		return bson.M{"$sum": number}
+	case database.MetricAvg:
+		return bson.M{"$avg": number}
+	case database.MetricMin:
+		return bson.M{"$min": number}
+	case database.MetricMax:
+		return bson.M{"$max": number}
+	default:
+		return bson.M{"$sum": 1}
+	}
+}
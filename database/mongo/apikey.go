@@ -0,0 +1,135 @@
+package mongo
+
+import (
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type LocalAPIKey struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	Name      string             `bson:"name" json:"name"`
+	KeyHash   string             `bson:"hash" json:"-"`
+	AccountID primitive.ObjectID `bson:"accountId" json:"accountId"`
+	UserID    primitive.ObjectID `bson:"userId" json:"userId"`
+	Email     string             `bson:"email" json:"email"`
+	Scopes    []string           `bson:"scopes" json:"scopes"`
+	Expires   *time.Time         `bson:"expires" json:"expires"`
+	LastUsed  *time.Time         `bson:"lastUsed" json:"lastUsed"`
+	Created   time.Time          `bson:"created" json:"created"`
+}
+
+func fromLocalAPIKey(lk LocalAPIKey) model.APIKey {
+	return model.APIKey{
+		ID:        lk.ID.Hex(),
+		Name:      lk.Name,
+		KeyHash:   lk.KeyHash,
+		AccountID: lk.AccountID.Hex(),
+		UserID:    lk.UserID.Hex(),
+		Email:     lk.Email,
+		Scopes:    lk.Scopes,
+		Expires:   lk.Expires,
+		LastUsed:  lk.LastUsed,
+		Created:   lk.Created,
+	}
+}
+
+func (mg *Mongo) CreateAPIKey(dbName string, key model.APIKey) error {
+	db := mg.Client.Database(dbName)
+
+	id, err := primitive.ObjectIDFromHex(key.ID)
+	if err != nil {
+		return err
+	}
+	acctID, err := primitive.ObjectIDFromHex(key.AccountID)
+	if err != nil {
+		return err
+	}
+	userID, err := primitive.ObjectIDFromHex(key.UserID)
+	if err != nil {
+		return err
+	}
+
+	lk := LocalAPIKey{
+		ID:        id,
+		Name:      key.Name,
+		KeyHash:   key.KeyHash,
+		AccountID: acctID,
+		UserID:    userID,
+		Email:     key.Email,
+		Scopes:    key.Scopes,
+		Expires:   key.Expires,
+		Created:   time.Now(),
+	}
+
+	_, err = db.Collection("sb_api_keys").InsertOne(mg.Ctx, lk)
+	return err
+}
+
+func (mg *Mongo) ListAPIKeys(dbName string) ([]model.APIKey, error) {
+	db := mg.Client.Database(dbName)
+
+	opts := options.Find().SetSort(bson.M{"created": -1})
+	cur, err := db.Collection("sb_api_keys").Find(mg.Ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(mg.Ctx)
+
+	var list []model.APIKey
+	for cur.Next(mg.Ctx) {
+		var lk LocalAPIKey
+		if err := cur.Decode(&lk); err != nil {
+			return nil, err
+		}
+
+		list = append(list, fromLocalAPIKey(lk))
+	}
+
+	return list, cur.Err()
+}
+
+func (mg *Mongo) GetAPIKey(dbName, id string) (key model.APIKey, err error) {
+	db := mg.Client.Database(dbName)
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return
+	}
+
+	var lk LocalAPIKey
+	sr := db.Collection("sb_api_keys").FindOne(mg.Ctx, bson.M{FieldID: oid})
+	if err = sr.Decode(&lk); err != nil {
+		return
+	}
+
+	return fromLocalAPIKey(lk), nil
+}
+
+func (mg *Mongo) RevokeAPIKey(dbName, id string) error {
+	db := mg.Client.Database(dbName)
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("sb_api_keys").DeleteOne(mg.Ctx, bson.M{FieldID: oid})
+	return err
+}
+
+func (mg *Mongo) TouchAPIKey(dbName, id string, used time.Time) error {
+	db := mg.Client.Database(dbName)
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{"$set": bson.M{"lastUsed": used}}
+	_, err = db.Collection("sb_api_keys").UpdateOne(mg.Ctx, bson.M{FieldID: oid}, update)
+	return err
+}
@@ -2,13 +2,15 @@ package mongo
 
 import (
 	"fmt"
-	"strings"
 	"sync"
+	"time"
 
+	"github.com/staticbackendhq/core/database"
 	"github.com/staticbackendhq/core/model"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -17,6 +19,12 @@ type Base struct {
 }
 
 func (mg *Mongo) CreateDocument(auth model.Auth, dbName, col string, doc map[string]interface{}) (map[string]interface{}, error) {
+	if err := mg.enforceSchema(dbName, col, doc, false); err != nil {
+		return nil, err
+	} else if err := database.CanCreate(mg, auth, dbName, col, doc); err != nil {
+		return nil, err
+	}
+
 	db := mg.Client.Database(dbName)
 
 	delete(doc, "id")
@@ -34,6 +42,8 @@ func (mg *Mongo) CreateDocument(auth model.Auth, dbName, col string, doc map[str
 	doc[FieldID] = newID
 	doc[FieldAccountID] = acctID
 	doc[FieldOwnerID] = userID
+	doc[database.FieldVersion] = int64(1)
+	doc[database.FieldUpdated] = time.Now()
 
 	if _, err := db.Collection(model.CleanCollectionName(col)).InsertOne(mg.Ctx, doc); err != nil {
 		return nil, err
@@ -125,6 +135,10 @@ func (mg *Mongo) BulkCreateDocument(auth model.Auth, dbName, col string, docs []
 		doc, ok := item.(map[string]interface{})
 		if !ok {
 			return fmt.Errorf("unable to cast docs to map")
+		} else if err := mg.enforceSchema(dbName, col, doc, false); err != nil {
+			return err
+		} else if err := database.CanCreate(mg, auth, dbName, col, doc); err != nil {
+			return err
 		}
 
 		delete(doc, "id")
@@ -135,6 +149,8 @@ func (mg *Mongo) BulkCreateDocument(auth model.Auth, dbName, col string, docs []
 		doc[FieldID] = primitive.NewObjectID()
 		doc[FieldAccountID] = acctID
 		doc[FieldOwnerID] = userID
+		doc[database.FieldVersion] = int64(1)
+		doc[database.FieldUpdated] = time.Now()
 	}
 
 	if _, err := db.Collection(model.CleanCollectionName(col)).InsertMany(mg.Ctx, docs); err != nil {
@@ -158,7 +174,9 @@ func (mg *Mongo) ListDocuments(auth model.Auth, dbName, col string, params model
 
 	filter := bson.M{}
 
-	secureRead(acctID, userID, auth.Role, col, filter)
+	if err := mg.secure(auth, acctID, userID, dbName, col, false, filter); err != nil {
+		return result, err
+	}
 
 	count, err := db.Collection(model.CleanCollectionName(col)).CountDocuments(mg.Ctx, filter)
 	if err != nil {
@@ -170,20 +188,19 @@ func (mg *Mongo) ListDocuments(auth model.Auth, dbName, col string, params model
 
 	result.Total = count
 
-	skips := params.Size * (params.Page - 1)
-
-	if len(params.SortBy) == 0 || strings.EqualFold(params.SortBy, "id") {
-		params.SortBy = FieldID
+	projection, err := database.ParseFields(params.Fields)
+	if err != nil {
+		return result, err
 	}
-	sortBy := bson.M{params.SortBy: 1}
-	if params.SortDescending {
-		sortBy[params.SortBy] = -1
+
+	opt, err := setPaging(params, filter)
+	if err != nil {
+		return result, err
 	}
 
-	opt := options.Find()
-	opt.SetSkip(skips)
-	opt.SetLimit(params.Size)
-	opt.SetSort(sortBy)
+	if proj := toProjection(projection.Keep(dataFields(params)...)); proj != nil {
+		opt.SetProjection(proj)
+	}
 
 	cur, err := db.Collection(model.CleanCollectionName(col)).Find(mg.Ctx, filter, opt)
 	if err != nil {
@@ -213,11 +230,16 @@ func (mg *Mongo) ListDocuments(auth model.Auth, dbName, col string, params model
 	}
 
 	result.Results = results
+	result.Cursor = nextCursor(params, results)
+
+	for _, doc := range results {
+		projection.Apply(doc)
+	}
 
 	return result, nil
 }
 
-func (mg *Mongo) QueryDocuments(auth model.Auth, dbName, col string, filter map[string]interface{}, params model.ListParams) (model.PagedResult, error) {
+func (mg *Mongo) QueryDocuments(auth model.Auth, dbName, col string, clauses database.Filter, params model.ListParams) (model.PagedResult, error) {
 	db := mg.Client.Database(dbName)
 
 	result := model.PagedResult{
@@ -230,7 +252,11 @@ func (mg *Mongo) QueryDocuments(auth model.Auth, dbName, col string, filter map[
 		return result, err
 	}
 
-	secureRead(acctID, userID, auth.Role, col, filter)
+	filter := toBSON(clauses)
+
+	if err := mg.secure(auth, acctID, userID, dbName, col, false, filter); err != nil {
+		return result, err
+	}
 
 	count, err := db.Collection(model.CleanCollectionName(col)).CountDocuments(mg.Ctx, filter)
 	if err != nil {
@@ -247,20 +273,19 @@ func (mg *Mongo) QueryDocuments(auth model.Auth, dbName, col string, filter map[
 		return result, nil
 	}
 
-	skips := params.Size * (params.Page - 1)
-
-	if len(params.SortBy) == 0 || strings.EqualFold(params.SortBy, "id") {
-		params.SortBy = FieldID
+	projection, err := database.ParseFields(params.Fields)
+	if err != nil {
+		return result, err
 	}
-	sortBy := bson.M{params.SortBy: 1}
-	if params.SortDescending {
-		sortBy[params.SortBy] = -1
+
+	opt, err := setPaging(params, filter)
+	if err != nil {
+		return result, err
 	}
 
-	opt := options.Find()
-	opt.SetSkip(skips)
-	opt.SetLimit(params.Size)
-	opt.SetSort(sortBy)
+	if proj := toProjection(projection.Keep(dataFields(params)...)); proj != nil {
+		opt.SetProjection(proj)
+	}
 
 	cur, err := db.Collection(model.CleanCollectionName(col)).Find(mg.Ctx, filter, opt)
 	if err != nil {
@@ -285,15 +310,25 @@ func (mg *Mongo) QueryDocuments(auth model.Auth, dbName, col string, filter map[
 	}
 
 	result.Results = results
+	result.Cursor = nextCursor(params, results)
+
+	for _, doc := range results {
+		projection.Apply(doc)
+	}
 
 	return result, nil
 }
 
-func (mg *Mongo) GetDocumentByID(auth model.Auth, dbName, col, id string) (map[string]interface{}, error) {
+func (mg *Mongo) GetDocumentByID(auth model.Auth, dbName, col, id string, fields ...string) (map[string]interface{}, error) {
 	db := mg.Client.Database(dbName)
 
 	var result map[string]interface{}
 
+	projection, err := database.ParseFields(fields)
+	if err != nil {
+		return result, err
+	}
+
 	oid, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return result, err
@@ -306,9 +341,16 @@ func (mg *Mongo) GetDocumentByID(auth model.Auth, dbName, col, id string) (map[s
 
 	filter := bson.M{FieldID: oid}
 
-	secureRead(acctID, userID, auth.Role, col, filter)
+	if err := mg.secure(auth, acctID, userID, dbName, col, false, filter); err != nil {
+		return result, err
+	}
 
-	sr := db.Collection(model.CleanCollectionName(col)).FindOne(mg.Ctx, filter)
+	opt := options.FindOne()
+	if proj := toProjection(projection); proj != nil {
+		opt.SetProjection(proj)
+	}
+
+	sr := db.Collection(model.CleanCollectionName(col)).FindOne(mg.Ctx, filter, opt)
 	if err := sr.Decode(&result); err != nil {
 		return result, err
 	} else if err := sr.Err(); err != nil {
@@ -317,12 +359,19 @@ func (mg *Mongo) GetDocumentByID(auth model.Auth, dbName, col, id string) (map[s
 
 	cleanMap(result)
 
+	projection.Apply(result)
+
 	return result, nil
 }
 
-func (mg *Mongo) GetDocumentsByIDs(auth model.Auth, dbName, col string, ids []string) (docs []map[string]interface{}, err error) {
+func (mg *Mongo) GetDocumentsByIDs(auth model.Auth, dbName, col string, ids []string, fields ...string) (docs []map[string]interface{}, err error) {
 	db := mg.Client.Database(dbName)
 
+	projection, err := database.ParseFields(fields)
+	if err != nil {
+		return []map[string]interface{}{}, err
+	}
+
 	var oids []primitive.ObjectID
 
 	for _, id := range ids {
@@ -340,9 +389,16 @@ func (mg *Mongo) GetDocumentsByIDs(auth model.Auth, dbName, col string, ids []st
 
 	filter := bson.M{FieldID: bson.M{"$in": oids}}
 
-	secureRead(acctID, userID, auth.Role, col, filter)
+	if err := mg.secure(auth, acctID, userID, dbName, col, false, filter); err != nil {
+		return []map[string]interface{}{}, err
+	}
 
-	cur, err := db.Collection(model.CleanCollectionName(col)).Find(mg.Ctx, filter)
+	opt := options.Find()
+	if proj := toProjection(projection); proj != nil {
+		opt.SetProjection(proj)
+	}
+
+	cur, err := db.Collection(model.CleanCollectionName(col)).Find(mg.Ctx, filter, opt)
 	if err != nil {
 		return docs, err
 	}
@@ -352,6 +408,7 @@ func (mg *Mongo) GetDocumentsByIDs(auth model.Auth, dbName, col string, ids []st
 			return []map[string]interface{}{}, err
 		}
 		cleanMap(v)
+		projection.Apply(v)
 		docs = append(docs, v)
 	}
 	return
@@ -370,21 +427,53 @@ func (mg *Mongo) UpdateDocument(auth model.Auth, dbName, col, id string, doc map
 		return nil, err
 	}
 
+	expected, hasExpected, err := database.ExpectedVersion(doc)
+	if err != nil {
+		return nil, err
+	}
+
 	removeNotEditableFields(doc)
 
+	if err := mg.enforceSchema(dbName, col, doc, true); err != nil {
+		return nil, err
+	}
+
 	filter := bson.M{FieldID: oid}
 
-	secureWrite(acctID, userID, auth.Role, col, filter)
+	if err := mg.secure(auth, acctID, userID, dbName, col, true, filter); err != nil {
+		return nil, err
+	} else if err := mg.canUpdate(auth, dbName, col, filter, doc); err != nil {
+		return nil, err
+	}
 
-	newProps := bson.M{}
+	newProps := bson.M{database.FieldUpdated: time.Now()}
 	for k, v := range doc {
 		newProps[k] = v
 	}
 
-	update := bson.M{"$set": newProps}
+	update := bson.M{"$set": newProps, "$inc": bson.M{database.FieldVersion: 1}}
 
-	res := db.Collection(model.CleanCollectionName(col)).FindOneAndUpdate(mg.Ctx, filter, update)
-	if err := res.Err(); err != nil {
+	versionFilter := bson.M{database.FieldVersion: expected}
+	if expected == 0 {
+		// documents created before versions were added have none
+		versionFilter = bson.M{database.FieldVersion: bson.M{"$in": bson.A{0, nil}}}
+	}
+
+	updateFilter := filter
+	if hasExpected {
+		updateFilter = bson.M{"$and": bson.A{filter, versionFilter}}
+	}
+
+	res := db.Collection(model.CleanCollectionName(col)).FindOneAndUpdate(mg.Ctx, updateFilter, update)
+	if err := res.Err(); err == mongo.ErrNoDocuments && hasExpected {
+		// the document exists but with another version
+		if n, err := db.Collection(model.CleanCollectionName(col)).CountDocuments(mg.Ctx, filter); err != nil {
+			return doc, err
+		} else if n > 0 {
+			return doc, database.ErrVersionConflict
+		}
+		return doc, res.Err()
+	} else if err != nil {
 		return doc, err
 	}
 
@@ -398,12 +487,12 @@ func (mg *Mongo) UpdateDocument(auth model.Auth, dbName, col, id string, doc map
 
 	cleanMap(result)
 
-	mg.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBUpdated, result)
+	mg.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBUpdated, database.Updated(result, database.ChangedFields(doc)))
 
 	return result, nil
 }
 
-func (mg *Mongo) UpdateDocuments(auth model.Auth, dbName, col string, filters map[string]interface{}, updateFields map[string]interface{}) (n int64, err error) {
+func (mg *Mongo) UpdateDocuments(auth model.Auth, dbName, col string, clauses database.Filter, updateFields map[string]interface{}) (n int64, err error) {
 	db := mg.Client.Database(dbName)
 
 	acctID, userID, err := parseObjectID(auth)
@@ -411,7 +500,11 @@ func (mg *Mongo) UpdateDocuments(auth model.Auth, dbName, col string, filters ma
 		return 0, err
 	}
 
-	secureWrite(acctID, userID, auth.Role, col, filters)
+	filters := toBSON(clauses)
+
+	if err := mg.secure(auth, acctID, userID, dbName, col, true, filters); err != nil {
+		return 0, err
+	}
 	removeNotEditableFields(updateFields)
 
 	var ids []string
@@ -435,27 +528,37 @@ func (mg *Mongo) UpdateDocuments(auth model.Auth, dbName, col string, filters ma
 		return 0, nil
 	}
 
-	newProps := bson.M{}
+	// the version is not checked for multiple documents
+	if _, _, err := database.ExpectedVersion(updateFields); err != nil {
+		return 0, err
+	} else if err := mg.enforceSchema(dbName, col, updateFields, true); err != nil {
+		return 0, err
+	} else if err := mg.canUpdate(auth, dbName, col, filters, updateFields); err != nil {
+		return 0, err
+	}
+
+	newProps := bson.M{database.FieldUpdated: time.Now()}
 	for k, v := range updateFields {
 		newProps[k] = v
 	}
 
-	update := bson.M{"$set": newProps}
+	update := bson.M{"$set": newProps, "$inc": bson.M{database.FieldVersion: 1}}
 
 	res, err := db.Collection(model.CleanCollectionName(col)).UpdateMany(mg.Ctx, filters, update)
 	if err != nil {
 		return 0, err
 	}
 
-	go func() {
+	fields := database.ChangedFields(updateFields)
+	mg.async(func() {
 		docs, err := mg.GetDocumentsByIDs(auth, dbName, col, ids)
 		if err != nil {
 			mg.log.Error().Err(err).Msgf("the documents with ids=%s are not received for publishDocument event", ids)
 		}
 		for _, doc := range docs {
-			mg.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBUpdated, doc)
+			mg.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBUpdated, database.Updated(doc, fields))
 		}
-	}()
+	})
 	return res.ModifiedCount, err
 }
 
@@ -474,9 +577,14 @@ func (mg *Mongo) IncrementValue(auth model.Auth, dbName, col, id, field string,
 
 	filter := bson.M{FieldID: oid}
 
-	secureWrite(acctID, userID, auth.Role, col, filter)
+	if err := mg.secure(auth, acctID, userID, dbName, col, true, filter); err != nil {
+		return err
+	}
 
-	update := bson.M{"$inc": bson.M{field: n}}
+	update := bson.M{
+		"$inc": bson.M{field: n, database.FieldVersion: 1},
+		"$set": bson.M{database.FieldUpdated: time.Now()},
+	}
 
 	res := db.Collection(model.CleanCollectionName(col)).FindOneAndUpdate(mg.Ctx, filter, update)
 	if err := res.Err(); err != nil {
@@ -488,7 +596,7 @@ func (mg *Mongo) IncrementValue(auth model.Auth, dbName, col, id, field string,
 		return err
 	}
 
-	mg.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBUpdated, updated)
+	mg.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBUpdated, database.Updated(updated, []string{field}))
 
 	return nil
 }
@@ -508,7 +616,9 @@ func (mg *Mongo) DeleteDocument(auth model.Auth, dbName, col, id string) (int64,
 
 	filter := bson.M{FieldID: oid}
 
-	secureWrite(acctID, userID, auth.Role, col, filter)
+	if err := mg.secure(auth, acctID, userID, dbName, col, true, filter); err != nil {
+		return 0, err
+	}
 
 	res, err := db.Collection(model.CleanCollectionName(col)).DeleteOne(mg.Ctx, filter)
 	if err != nil {
@@ -520,7 +630,7 @@ func (mg *Mongo) DeleteDocument(auth model.Auth, dbName, col, id string) (int64,
 	return res.DeletedCount, nil
 }
 
-func (mg *Mongo) DeleteDocuments(auth model.Auth, dbName, col string, filters map[string]any) (int64, error) {
+func (mg *Mongo) DeleteDocuments(auth model.Auth, dbName, col string, clauses database.Filter) (int64, error) {
 	db := mg.Client.Database(dbName)
 
 	acctID, userID, err := parseObjectID(auth)
@@ -528,14 +638,18 @@ func (mg *Mongo) DeleteDocuments(auth model.Auth, dbName, col string, filters ma
 		return 0, err
 	}
 
-	secureWrite(acctID, userID, auth.Role, col, filters)
+	filters := toBSON(clauses)
+
+	if err := mg.secure(auth, acctID, userID, dbName, col, true, filters); err != nil {
+		return 0, err
+	}
 
 	res, err := db.Collection(model.CleanCollectionName(col)).DeleteMany(mg.Ctx, filters)
 	if err != nil {
 		return 0, err
 	}
 
-	go func() {
+	mg.async(func() {
 		var ids []string
 		findOpts := options.Find().SetProjection(bson.M{FieldID: 1})
 		cur, err := db.Collection(model.CleanCollectionName(col)).Find(mg.Ctx, filters, findOpts)
@@ -557,7 +671,7 @@ func (mg *Mongo) DeleteDocuments(auth model.Auth, dbName, col string, filters ma
 		for _, id := range ids {
 			mg.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBDeleted, id)
 		}
-	}()
+	})
 
 	return res.DeletedCount, nil
 }
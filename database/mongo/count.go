@@ -1,10 +1,11 @@
 package mongo
 
 import (
+	"github.com/staticbackendhq/core/database"
 	"github.com/staticbackendhq/core/model"
 )
 
-func (mg *Mongo) Count(auth model.Auth, dbName, col string, filter map[string]interface{}) (count int64, err error) {
+func (mg *Mongo) Count(auth model.Auth, dbName, col string, clauses database.Filter) (count int64, err error) {
 	db := mg.Client.Database(dbName)
 
 	acctID, userID, err := parseObjectID(auth)
@@ -12,7 +13,11 @@ func (mg *Mongo) Count(auth model.Auth, dbName, col string, filter map[string]in
 		return
 	}
 
-	secureRead(acctID, userID, auth.Role, col, filter)
+	filter := toBSON(clauses)
+
+	if err = mg.secure(auth, acctID, userID, dbName, col, false, filter); err != nil {
+		return
+	}
 
 	count, err = db.Collection(model.CleanCollectionName(col)).CountDocuments(mg.Ctx, filter)
 	if err != nil {
@@ -1,6 +1,10 @@
 package mongo
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/staticbackendhq/core/database/dbtest"
+)
 
 func TestCount(t *testing.T) {
 	task1 := newTask("task_with_filter", false)
@@ -51,3 +55,7 @@ func TestCountWithNoFilters(t *testing.T) {
 		t.Fatalf("expected 19 got %v", count)
 	}
 }
+
+func TestAggregate(t *testing.T) {
+	dbtest.Aggregate(t, datastore, adminAuth, confDBName)
+}
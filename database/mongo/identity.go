@@ -0,0 +1,141 @@
+package mongo
+
+import (
+	"errors"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type LocalIdentity struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	AccountID primitive.ObjectID `bson:"accountId" json:"accountId"`
+	UserID    primitive.ObjectID `bson:"userId" json:"userId"`
+	Provider  string             `bson:"provider" json:"provider"`
+	Subject   string             `bson:"subject" json:"subject"`
+	Email     string             `bson:"email" json:"email"`
+	Linked    time.Time          `bson:"linked" json:"linked"`
+}
+
+func fromLocalIdentity(li LocalIdentity) model.Identity {
+	return model.Identity{
+		ID:        li.ID.Hex(),
+		AccountID: li.AccountID.Hex(),
+		UserID:    li.UserID.Hex(),
+		Provider:  li.Provider,
+		Subject:   li.Subject,
+		Email:     li.Email,
+		Linked:    li.Linked,
+	}
+}
+
+func (mg *Mongo) AddIdentity(dbName string, ident model.Identity) (id string, err error) {
+	db := mg.Client.Database(dbName)
+
+	acctID, err := primitive.ObjectIDFromHex(ident.AccountID)
+	if err != nil {
+		return
+	}
+	userID, err := primitive.ObjectIDFromHex(ident.UserID)
+	if err != nil {
+		return
+	}
+
+	oid := primitive.NewObjectID()
+
+	// the identity is only inserted if the provider's subject is not linked
+	filter := bson.M{"provider": ident.Provider, "subject": ident.Subject}
+	update := bson.M{"$setOnInsert": bson.M{
+		FieldID:     oid,
+		"accountId": acctID,
+		"userId":    userID,
+		"email":     ident.Email,
+		"linked":    time.Now(),
+	}}
+
+	opts := options.Update().SetUpsert(true)
+	res, err := db.Collection("sb_identities").UpdateOne(mg.Ctx, filter, update, opts)
+	if err != nil {
+		return
+	} else if res.UpsertedCount == 0 {
+		err = errors.New("this identity is already linked to a user")
+		return
+	}
+
+	return oid.Hex(), nil
+}
+
+func (mg *Mongo) FindIdentity(dbName, provider, subject string) (ident model.Identity, err error) {
+	db := mg.Client.Database(dbName)
+
+	var li LocalIdentity
+	filter := bson.M{"provider": provider, "subject": subject}
+	sr := db.Collection("sb_identities").FindOne(mg.Ctx, filter)
+	if err = sr.Decode(&li); err == mongo.ErrNoDocuments {
+		return model.Identity{}, nil
+	} else if err != nil {
+		return
+	}
+
+	return fromLocalIdentity(li), nil
+}
+
+func (mg *Mongo) ListIdentities(dbName, userID string) ([]model.Identity, error) {
+	db := mg.Client.Database(dbName)
+
+	oid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.M{"linked": 1})
+	cur, err := db.Collection("sb_identities").Find(mg.Ctx, bson.M{"userId": oid}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(mg.Ctx)
+
+	var list []model.Identity
+	for cur.Next(mg.Ctx) {
+		var li LocalIdentity
+		if err := cur.Decode(&li); err != nil {
+			return nil, err
+		}
+
+		list = append(list, fromLocalIdentity(li))
+	}
+
+	return list, cur.Err()
+}
+
+func (mg *Mongo) DeleteIdentity(dbName, userID, id string) error {
+	db := mg.Client.Database(dbName)
+
+	uid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("sb_identities").DeleteOne(mg.Ctx, bson.M{FieldID: oid, "userId": uid})
+	return err
+}
+
+func (mg *Mongo) DeleteIdentities(dbName, userID string) error {
+	db := mg.Client.Database(dbName)
+
+	oid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("sb_identities").DeleteMany(mg.Ctx, bson.M{"userId": oid})
+	return err
+}
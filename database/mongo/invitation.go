@@ -0,0 +1,126 @@
+package mongo
+
+import (
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type LocalInvitation struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	AccountID primitive.ObjectID `bson:"accountId" json:"accountId"`
+	Email     string             `bson:"email" json:"email"`
+	Role      int                `bson:"role" json:"role"`
+	TokenHash string             `bson:"hash" json:"-"`
+	InvitedBy primitive.ObjectID `bson:"invitedBy" json:"invitedBy"`
+	Created   time.Time          `bson:"created" json:"created"`
+	Expires   time.Time          `bson:"expires" json:"expires"`
+}
+
+func fromLocalInvitation(li LocalInvitation) model.Invitation {
+	return model.Invitation{
+		ID:        li.ID.Hex(),
+		AccountID: li.AccountID.Hex(),
+		Email:     li.Email,
+		Role:      li.Role,
+		TokenHash: li.TokenHash,
+		InvitedBy: li.InvitedBy.Hex(),
+		Created:   li.Created,
+		Expires:   li.Expires,
+	}
+}
+
+func (mg *Mongo) CreateInvitation(dbName string, inv model.Invitation) error {
+	db := mg.Client.Database(dbName)
+
+	id, err := primitive.ObjectIDFromHex(inv.ID)
+	if err != nil {
+		return err
+	}
+	acctID, err := primitive.ObjectIDFromHex(inv.AccountID)
+	if err != nil {
+		return err
+	}
+	invitedBy, err := primitive.ObjectIDFromHex(inv.InvitedBy)
+	if err != nil {
+		return err
+	}
+
+	li := LocalInvitation{
+		ID:        id,
+		AccountID: acctID,
+		Email:     inv.Email,
+		Role:      inv.Role,
+		TokenHash: inv.TokenHash,
+		InvitedBy: invitedBy,
+		Created:   time.Now(),
+		Expires:   inv.Expires,
+	}
+
+	_, err = db.Collection("sb_invitations").InsertOne(mg.Ctx, li)
+	return err
+}
+
+func (mg *Mongo) GetInvitation(dbName, id string) (inv model.Invitation, err error) {
+	db := mg.Client.Database(dbName)
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return
+	}
+
+	var li LocalInvitation
+	sr := db.Collection("sb_invitations").FindOne(mg.Ctx, bson.M{FieldID: oid})
+	if err = sr.Decode(&li); err != nil {
+		return
+	}
+
+	return fromLocalInvitation(li), nil
+}
+
+func (mg *Mongo) ListInvitations(dbName, accountID string) ([]model.Invitation, error) {
+	db := mg.Client.Database(dbName)
+
+	oid, err := primitive.ObjectIDFromHex(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.M{"created": -1})
+	cur, err := db.Collection("sb_invitations").Find(mg.Ctx, bson.M{"accountId": oid}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(mg.Ctx)
+
+	var list []model.Invitation
+	for cur.Next(mg.Ctx) {
+		var li LocalInvitation
+		if err := cur.Decode(&li); err != nil {
+			return nil, err
+		}
+
+		list = append(list, fromLocalInvitation(li))
+	}
+
+	return list, cur.Err()
+}
+
+func (mg *Mongo) DeleteInvitation(dbName, accountID, id string) error {
+	db := mg.Client.Database(dbName)
+
+	acctID, err := primitive.ObjectIDFromHex(accountID)
+	if err != nil {
+		return err
+	}
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("sb_invitations").DeleteOne(mg.Ctx, bson.M{FieldID: oid, "accountId": acctID})
+	return err
+}
@@ -98,6 +98,20 @@ func (mg *Mongo) UserSetPassword(dbName, tokenID, password string) error {
 	return nil
 }
 
+func (mg *Mongo) SetEmailVerified(dbName, userID string) error {
+	db := mg.Client.Database(dbName)
+
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{"verified": true}}
+	_, err = db.Collection("sb_tokens").UpdateOne(mg.Ctx, filter, update)
+	return err
+}
+
 func (mg *Mongo) GetFirstUserFromAccountID(dbName, accountID string) (tok model.User, err error) {
 	db := mg.Client.Database(dbName)
 
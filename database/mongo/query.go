@@ -1,54 +1,176 @@
 package mongo
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
 	"strings"
+	"time"
 
+	"github.com/staticbackendhq/core/database"
 	"github.com/staticbackendhq/core/internal"
+	"github.com/staticbackendhq/core/model"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func (mg *Mongo) ParseQuery(clauses [][]interface{}) (map[string]interface{}, error) {
-	filter := bson.M{}
-	for i, clause := range clauses {
-		if len(clause) != 3 {
-			return filter, fmt.Errorf("the %d query clause did not contains the required 3 parameters (field, operator, value)", i+1)
+func (mg *Mongo) ParseQuery(clauses [][]interface{}) (database.Filter, error) {
+	return database.ParseQuery(clauses)
+}
+
+// toBSON compiles the filter into a Mongo query document
+func toBSON(filter database.Filter) bson.M {
+	m := bson.M{}
+
+	// nested groups and conditions on a field that already uses the same
+	// operators are ANDed via $and so they don't collide
+	var and bson.A
+	for _, clause := range filter {
+		if clause.IsGroup() {
+			and = append(and, groupToBSON(clause))
+			continue
 		}
 
-		field, ok := clause[0].(string)
+		clause = systemField(clause)
+		cond := clauseToBSON(clause)
+
+		existing, ok := m[clause.Field].(bson.M)
 		if !ok {
-			return filter, fmt.Errorf("the %d query clause's field parameter must be a string: %v", i+1, clause[0])
+			m[clause.Field] = cond
+			continue
 		}
 
-		op, ok := clause[1].(string)
-		if !ok {
-			return filter, fmt.Errorf("the %d query clause's operator must be a string: %v", i+1, clause[1])
-		}
-
-		switch op {
-		case "=", "==":
-			filter[field] = clause[2]
-		case "!=", "<>":
-			filter[field] = bson.M{"$ne": clause[2]}
-		case ">":
-			filter[field] = bson.M{"$gt": clause[2]}
-		case "<":
-			filter[field] = bson.M{"$lt": clause[2]}
-		case ">=":
-			filter[field] = bson.M{"$gte": clause[2]}
-		case "<=":
-			filter[field] = bson.M{"$lte": clause[2]}
-		case "in":
-			filter[field] = bson.M{"$in": clause[2]}
-		case "!in", "nin":
-			filter[field] = bson.M{"$nin": clause[2]}
-		default:
-			return filter, fmt.Errorf("the %d query clause's operator: %s is not supported at the moment", i+1, op)
+		collide := false
+		for k := range cond {
+			if _, ok := existing[k]; ok {
+				collide = true
+			}
 		}
+
+		if collide {
+			and = append(and, bson.M{clause.Field: cond})
+			continue
+		}
+
+		for k, v := range cond {
+			existing[k] = v
+		}
+	}
+
+	if len(and) > 0 {
+		m["$and"] = and
+	}
+	return m
+}
+
+// systemField maps the owner field to its stored name and converts the
+// account and owner IDs to ObjectID, i.e. for the rules comparing them with
+// the caller's IDs
+func systemField(clause database.Clause) database.Clause {
+	switch clause.Field {
+	case FieldAccountID:
+	case "ownerId":
+		clause.Field = FieldOwnerID
+	default:
+		return clause
+	}
+
+	toObjectID := func(v any) any {
+		if s, ok := v.(string); ok {
+			if oid, err := primitive.ObjectIDFromHex(s); err == nil {
+				return oid
+			}
+		}
+		return v
+	}
+
+	if list, ok := clause.Value.([]any); ok {
+		ids := make([]any, len(list))
+		for i, v := range list {
+			ids[i] = toObjectID(v)
+		}
+		clause.Value = ids
+	} else {
+		clause.Value = toObjectID(clause.Value)
+	}
+	return clause
+}
+
+// clauseToBSON returns the operators document of a single clause
+func clauseToBSON(clause database.Clause) bson.M {
+	val := toValue(clause.Value)
+
+	switch clause.Op {
+	case database.OpEqual:
+		return bson.M{"$eq": val}
+	case database.OpNotEqual:
+		// $ne and $nin match the documents missing the field, null is added so
+		// they don't, like the other data stores
+		return bson.M{"$nin": bson.A{val, nil}}
+	case database.OpGreater:
+		return bson.M{"$gt": val}
+	case database.OpLower:
+		return bson.M{"$lt": val}
+	case database.OpGreaterThanEqual:
+		return bson.M{"$gte": val}
+	case database.OpLowerThanEqual:
+		return bson.M{"$lte": val}
+	case database.OpIn:
+		return bson.M{"$in": val}
+	case database.OpNotIn:
+		return bson.M{"$nin": append(bson.A{nil}, clause.Value.([]any)...)}
+	case database.OpExists:
+		return bson.M{"$exists": true}
+	case database.OpNotExists:
+		return bson.M{"$exists": false}
+	case database.OpContains:
+		return bson.M{"$regex": regexp.QuoteMeta(clause.Value.(string))}
+	case database.OpStartsWith:
+		return bson.M{"$regex": "^" + regexp.QuoteMeta(clause.Value.(string))}
+	case database.OpEqualFold:
+		return bson.M{"$regex": "^" + regexp.QuoteMeta(clause.Value.(string)) + "$", "$options": "i"}
+	case database.OpRegex:
+		return bson.M{"$regex": clause.Value}
+	case database.OpBetween:
+		bounds := clause.Value.([]any)
+		return bson.M{"$gte": toValue(bounds[0]), "$lte": toValue(bounds[1])}
+	case database.OpContainsAny:
+		// $in and $all also match a scalar field equal to the value
+		return bson.M{"$type": "array", "$in": val}
+	case database.OpContainsAll:
+		return bson.M{"$type": "array", "$all": val}
+	case database.OpSize:
+		return bson.M{"$size": int64(clause.Value.(float64))}
 	}
+	return bson.M{}
+}
+
+// toValue converts time to their stored representation. Documents are stored
+// from their JSON representation, time are strings in RFC3339 format.
+func toValue(v any) any {
+	if t, ok := v.(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+	return v
+}
 
-	return filter, nil
+// groupToBSON compiles a nested group into its $or, $and or $nor document
+func groupToBSON(clause database.Clause) bson.M {
+	var list bson.A
+	for _, c := range clause.Clauses {
+		list = append(list, toBSON(database.Filter{c}))
+	}
+
+	switch clause.Group {
+	case database.GroupOr:
+		return bson.M{"$or": list}
+	case database.GroupNot:
+		// $nor of a single $and document negates all the clauses together
+		return bson.M{"$nor": bson.A{bson.M{"$and": list}}}
+	default:
+		return bson.M{"$and": list}
+	}
 }
 
 func secureRead(acctID, userID primitive.ObjectID, role int, col string, filter bson.M) {
@@ -76,3 +198,194 @@ func secureWrite(acctID, userID primitive.ObjectID, role int, col string, filter
 		}
 	}
 }
+
+// sortFields returns the fields of the sort keys, the creation order is the
+// _id order
+func sortFields(keys []model.SortKey) []string {
+	fields := make([]string, len(keys))
+	for i, key := range keys {
+		if key.Field == database.SortCreated || key.Field == database.SortID {
+			fields[i] = FieldID
+			continue
+		}
+		fields[i] = key.Field
+	}
+	return fields
+}
+
+// setPaging returns the find options sorting on the (sort keys, _id). When
+// the params contain a cursor the keyset condition is added to the filter.
+func setPaging(params model.ListParams, filter bson.M) (*options.FindOptions, error) {
+	keys, err := database.SortKeys(params)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := sortFields(keys)
+
+	var sortBy bson.D
+	for i, key := range keys {
+		sortBy = append(sortBy, bson.E{Key: fields[i], Value: sortDirection(key.Descending)})
+
+		// _id is unique, the following keys are not needed
+		if fields[i] == FieldID {
+			keys, fields = keys[:i+1], fields[:i+1]
+			break
+		}
+	}
+
+	last := keys[len(keys)-1]
+	if fields[len(fields)-1] != FieldID {
+		sortBy = append(sortBy, bson.E{Key: FieldID, Value: sortDirection(last.Descending)})
+	}
+
+	opt := options.Find()
+	opt.SetLimit(params.Size)
+	opt.SetSort(sortBy)
+
+	if len(params.Cursor) == 0 {
+		opt.SetSkip(params.Size * (params.Page - 1))
+		return opt, nil
+	}
+
+	c, err := database.DecodeCursor(params)
+	if err != nil {
+		return nil, err
+	}
+
+	oid, err := primitive.ObjectIDFromHex(c.ID)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ... OR (k1 = v1 AND ... AND _id > oid)
+	// missing values are sorted first
+	var or bson.A
+	equals := bson.M{}
+	for i, key := range keys {
+		field, v := fields[i], c.Values[i]
+
+		var after bson.M
+		switch {
+		case field == FieldID:
+			after = bson.M{FieldID: bson.M{comparison(key.Descending): oid}}
+		case v == nil && key.Descending:
+			// nothing is sorted before missing values in descending order
+		case v == nil:
+			after = bson.M{field: bson.M{"$ne": nil}}
+		case key.Descending:
+			after = bson.M{"$or": bson.A{
+				bson.M{field: bson.M{"$lt": v}},
+				bson.M{field: nil},
+			}}
+		default:
+			after = bson.M{field: bson.M{"$gt": v}}
+		}
+
+		if after != nil {
+			or = append(or, bson.M{"$and": bson.A{copyM(equals), after}})
+		}
+
+		if field == FieldID {
+			equals[field] = oid
+		} else {
+			equals[field] = v
+		}
+	}
+
+	if fields[len(fields)-1] != FieldID {
+		after := bson.M{FieldID: bson.M{comparison(last.Descending): oid}}
+		or = append(or, bson.M{"$and": bson.A{equals, after}})
+	}
+
+	and, _ := filter["$and"].(bson.A)
+	filter["$and"] = append(and, bson.M{"$or": or})
+
+	return opt, nil
+}
+
+func sortDirection(desc bool) int {
+	if desc {
+		return -1
+	}
+	return 1
+}
+
+func comparison(desc bool) string {
+	if desc {
+		return "$lt"
+	}
+	return "$gt"
+}
+
+func copyM(m bson.M) bson.M {
+	c := bson.M{}
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// nextCursor returns the cursor of the last document if the page is full
+func nextCursor(params model.ListParams, results []map[string]interface{}) string {
+	n := int64(len(results))
+	if n == 0 || n < params.Size {
+		return ""
+	}
+
+	keys, err := database.SortKeys(params)
+	if err != nil {
+		return ""
+	}
+
+	last := results[n-1]
+
+	values := make([]any, len(keys))
+	for i, field := range sortFields(keys) {
+		if field != FieldID {
+			values[i], _ = database.Lookup(last, field)
+		}
+	}
+	return database.EncodeCursor(params, values, fmt.Sprintf("%v", last["id"]))
+}
+
+// dataFields returns the sort fields stored in the document
+func dataFields(params model.ListParams) (fields []string) {
+	keys, _ := database.SortKeys(params)
+	for _, field := range sortFields(keys) {
+		if field != FieldID {
+			fields = append(fields, field)
+		}
+	}
+	return
+}
+
+// toProjection returns the projection document, nil returns all fields
+func toProjection(p database.Projection) bson.M {
+	if p.IsEmpty() {
+		return nil
+	}
+
+	m := bson.M{}
+	if len(p.Include) > 0 {
+		// _id is returned by default
+		m[FieldAccountID] = 1
+		for _, field := range p.Include {
+			if field != "id" {
+				m[field] = 1
+			}
+		}
+		return m
+	}
+
+	for _, field := range p.Exclude {
+		if field != "id" && field != FieldAccountID {
+			m[field] = 0
+		}
+	}
+
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
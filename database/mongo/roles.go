@@ -0,0 +1,74 @@
+package mongo
+
+import (
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type LocalRole struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	Name        string             `bson:"name" json:"name"`
+	Level       int                `bson:"level" json:"level"`
+	Permissions []string           `bson:"perms" json:"permissions"`
+	Created     time.Time          `bson:"created" json:"created"`
+}
+
+func fromLocalRole(lr LocalRole) model.Role {
+	return model.Role{
+		ID:          lr.ID.Hex(),
+		Name:        lr.Name,
+		Level:       lr.Level,
+		Permissions: lr.Permissions,
+		Created:     lr.Created,
+	}
+}
+
+func (mg *Mongo) ListRoles(dbName string) ([]model.Role, error) {
+	db := mg.Client.Database(dbName)
+
+	opts := options.Find().SetSort(bson.M{"level": 1})
+	cur, err := db.Collection("sb_roles").Find(mg.Ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(mg.Ctx)
+
+	var list []model.Role
+	for cur.Next(mg.Ctx) {
+		var lr LocalRole
+		if err := cur.Decode(&lr); err != nil {
+			return nil, err
+		}
+
+		list = append(list, fromLocalRole(lr))
+	}
+
+	return list, cur.Err()
+}
+
+func (mg *Mongo) SaveRole(dbName string, role model.Role) error {
+	db := mg.Client.Database(dbName)
+
+	update := bson.M{
+		"$set": bson.M{"level": role.Level, "perms": role.Permissions},
+		"$setOnInsert": bson.M{
+			FieldID:   primitive.NewObjectID(),
+			"created": time.Now(),
+		},
+	}
+
+	opts := options.Update().SetUpsert(true)
+	_, err := db.Collection("sb_roles").UpdateOne(mg.Ctx, bson.M{"name": role.Name}, update, opts)
+	return err
+}
+
+func (mg *Mongo) DeleteRole(dbName, name string) error {
+	db := mg.Client.Database(dbName)
+
+	_, err := db.Collection("sb_roles").DeleteOne(mg.Ctx, bson.M{"name": name})
+	return err
+}
@@ -0,0 +1,169 @@
+package mongo
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LocalRules stores the rules as JSON, the clauses' values keep their JSON
+// types
+type LocalRules struct {
+	ID         primitive.ObjectID `bson:"_id" json:"id"`
+	Collection string             `bson:"col" json:"collection"`
+	Rules      string             `bson:"rules" json:"rules"`
+	Updated    time.Time          `bson:"updated" json:"updated"`
+}
+
+func fromLocalRules(lr LocalRules) (model.CollectionRules, error) {
+	r := model.CollectionRules{
+		ID:         lr.ID.Hex(),
+		Collection: lr.Collection,
+		Updated:    lr.Updated,
+	}
+
+	err := json.Unmarshal([]byte(lr.Rules), &r.Rules)
+	return r, err
+}
+
+func (mg *Mongo) ListRules(dbName string) ([]model.CollectionRules, error) {
+	db := mg.Client.Database(dbName)
+
+	opts := options.Find().SetSort(bson.M{"col": 1})
+	cur, err := db.Collection("sb_rules").Find(mg.Ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(mg.Ctx)
+
+	var list []model.CollectionRules
+	for cur.Next(mg.Ctx) {
+		var lr LocalRules
+		if err := cur.Decode(&lr); err != nil {
+			return nil, err
+		}
+
+		r, err := fromLocalRules(lr)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, r)
+	}
+
+	return list, cur.Err()
+}
+
+func (mg *Mongo) GetRules(dbName, col string) (rules model.CollectionRules, ok bool, err error) {
+	db := mg.Client.Database(dbName)
+
+	var lr LocalRules
+	sr := db.Collection("sb_rules").FindOne(mg.Ctx, bson.M{"col": col})
+	if err = sr.Decode(&lr); err == mongo.ErrNoDocuments {
+		return rules, false, nil
+	} else if err != nil {
+		return
+	}
+
+	rules, err = fromLocalRules(lr)
+	return rules, err == nil, err
+}
+
+func (mg *Mongo) SaveRules(dbName string, rules model.CollectionRules) error {
+	db := mg.Client.Database(dbName)
+
+	b, err := json.Marshal(rules.Rules)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{
+		"$set":         bson.M{"rules": string(b), "updated": time.Now()},
+		"$setOnInsert": bson.M{FieldID: primitive.NewObjectID()},
+	}
+
+	opts := options.Update().SetUpsert(true)
+	_, err = db.Collection("sb_rules").UpdateOne(mg.Ctx, bson.M{"col": rules.Collection}, update, opts)
+	return err
+}
+
+func (mg *Mongo) DeleteRules(dbName, col string) error {
+	db := mg.Client.Database(dbName)
+
+	_, err := db.Collection("sb_rules").DeleteOne(mg.Ctx, bson.M{"col": col})
+	return err
+}
+
+// secure adds the conditions securing the access to a collection to the
+// filter, its rule replaces the permissions from its name
+func (mg *Mongo) secure(auth model.Auth, acctID, userID primitive.ObjectID, dbName, col string, write bool, filter bson.M) error {
+	rule, ruled, err := database.SecureFilter(mg, auth, dbName, col, write, nil)
+	if err != nil {
+		return err
+	} else if !ruled {
+		if write {
+			secureWrite(acctID, userID, auth.Role, col, filter)
+		} else {
+			secureRead(acctID, userID, auth.Role, col, filter)
+		}
+		return nil
+	}
+
+	if len(rule) > 0 {
+		and, _ := filter["$and"].(bson.A)
+		filter["$and"] = append(and, toBSON(rule))
+	}
+	return nil
+}
+
+// canUpdate returns database.ErrPermissionDenied when one of the documents
+// matching the filter would not satisfy the collection's write rule once
+// updated
+func (mg *Mongo) canUpdate(auth model.Auth, dbName, col string, filter bson.M, update map[string]any) error {
+	rule, ruled, err := database.SecureFilter(mg, auth, dbName, col, true, nil)
+	if err != nil || !ruled {
+		return err
+	}
+
+	db := mg.Client.Database(dbName)
+
+	cur, err := db.Collection(model.CleanCollectionName(col)).Find(mg.Ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(mg.Ctx)
+
+	for cur.Next(mg.Ctx) {
+		var result bson.M
+		if err := cur.Decode(&result); err != nil {
+			return err
+		}
+
+		owner, _ := result[FieldOwnerID].(primitive.ObjectID)
+		cleanMap(result)
+
+		// the rules are matched on the JSON values, i.e. the arrays are
+		// decoded as primitive.A
+		b, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+
+		var doc map[string]any
+		if err := json.Unmarshal(b, &doc); err != nil {
+			return err
+		}
+		doc["ownerId"] = owner.Hex()
+
+		if err := database.CanUpdate(rule, doc, update); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
@@ -0,0 +1,111 @@
+package mongo
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LocalSchema stores the schema definition as JSON, the default and enum
+// values keep their JSON types
+type LocalSchema struct {
+	ID         primitive.ObjectID `bson:"_id" json:"id"`
+	Collection string             `bson:"col" json:"collection"`
+	Definition string             `bson:"definition" json:"definition"`
+	Updated    time.Time          `bson:"updated" json:"updated"`
+}
+
+func fromLocalSchema(ls LocalSchema) (model.CollectionSchema, error) {
+	s := model.CollectionSchema{
+		ID:         ls.ID.Hex(),
+		Collection: ls.Collection,
+		Updated:    ls.Updated,
+	}
+
+	err := json.Unmarshal([]byte(ls.Definition), &s.Schema)
+	return s, err
+}
+
+func (mg *Mongo) ListSchemas(dbName string) ([]model.CollectionSchema, error) {
+	db := mg.Client.Database(dbName)
+
+	opts := options.Find().SetSort(bson.M{"col": 1})
+	cur, err := db.Collection("sb_schemas").Find(mg.Ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(mg.Ctx)
+
+	var list []model.CollectionSchema
+	for cur.Next(mg.Ctx) {
+		var ls LocalSchema
+		if err := cur.Decode(&ls); err != nil {
+			return nil, err
+		}
+
+		s, err := fromLocalSchema(ls)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, s)
+	}
+
+	return list, cur.Err()
+}
+
+func (mg *Mongo) GetSchema(dbName, col string) (schema model.CollectionSchema, ok bool, err error) {
+	db := mg.Client.Database(dbName)
+
+	var ls LocalSchema
+	sr := db.Collection("sb_schemas").FindOne(mg.Ctx, bson.M{"col": col})
+	if err = sr.Decode(&ls); err == mongo.ErrNoDocuments {
+		return schema, false, nil
+	} else if err != nil {
+		return
+	}
+
+	schema, err = fromLocalSchema(ls)
+	return schema, err == nil, err
+}
+
+func (mg *Mongo) SaveSchema(dbName string, schema model.CollectionSchema) error {
+	db := mg.Client.Database(dbName)
+
+	b, err := json.Marshal(schema.Schema)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{
+		"$set":         bson.M{"definition": string(b), "updated": time.Now()},
+		"$setOnInsert": bson.M{FieldID: primitive.NewObjectID()},
+	}
+
+	opts := options.Update().SetUpsert(true)
+	_, err = db.Collection("sb_schemas").UpdateOne(mg.Ctx, bson.M{"col": schema.Collection}, update, opts)
+	return err
+}
+
+func (mg *Mongo) DeleteSchema(dbName, col string) error {
+	db := mg.Client.Database(dbName)
+
+	_, err := db.Collection("sb_schemas").DeleteOne(mg.Ctx, bson.M{"col": col})
+	return err
+}
+
+// enforceSchema validates the document against the collection's schema
+// when it has one
+func (mg *Mongo) enforceSchema(dbName, col string, doc map[string]any, partial bool) error {
+	schema, ok, err := mg.GetSchema(dbName, col)
+	if err != nil || !ok {
+		return err
+	}
+	return database.ApplySchema(schema.Schema, doc, partial)
+}
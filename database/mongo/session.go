@@ -0,0 +1,154 @@
+package mongo
+
+import (
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type LocalSession struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	AccountID   primitive.ObjectID `bson:"accountId" json:"accountId"`
+	UserID      primitive.ObjectID `bson:"userId" json:"userId"`
+	RefreshHash string             `bson:"hash" json:"-"`
+	Created     time.Time          `bson:"created" json:"created"`
+	Refreshed   time.Time          `bson:"refreshed" json:"refreshed"`
+	Expires     time.Time          `bson:"expires" json:"expires"`
+}
+
+func fromLocalSession(ls LocalSession) model.Session {
+	return model.Session{
+		ID:          ls.ID.Hex(),
+		AccountID:   ls.AccountID.Hex(),
+		UserID:      ls.UserID.Hex(),
+		RefreshHash: ls.RefreshHash,
+		Created:     ls.Created,
+		Refreshed:   ls.Refreshed,
+		Expires:     ls.Expires,
+	}
+}
+
+func (mg *Mongo) CreateSession(dbName string, s model.Session) error {
+	db := mg.Client.Database(dbName)
+
+	id, err := primitive.ObjectIDFromHex(s.ID)
+	if err != nil {
+		return err
+	}
+	acctID, err := primitive.ObjectIDFromHex(s.AccountID)
+	if err != nil {
+		return err
+	}
+	userID, err := primitive.ObjectIDFromHex(s.UserID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	ls := LocalSession{
+		ID:          id,
+		AccountID:   acctID,
+		UserID:      userID,
+		RefreshHash: s.RefreshHash,
+		Created:     now,
+		Refreshed:   now,
+		Expires:     s.Expires,
+	}
+
+	_, err = db.Collection("sb_sessions").InsertOne(mg.Ctx, ls)
+	return err
+}
+
+func (mg *Mongo) GetSession(dbName, id string) (s model.Session, err error) {
+	db := mg.Client.Database(dbName)
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return
+	}
+
+	var ls LocalSession
+	sr := db.Collection("sb_sessions").FindOne(mg.Ctx, bson.M{FieldID: oid})
+	if err = sr.Decode(&ls); err != nil {
+		return
+	}
+
+	return fromLocalSession(ls), nil
+}
+
+func (mg *Mongo) ListSessions(dbName, userID string) ([]model.Session, error) {
+	db := mg.Client.Database(dbName)
+
+	oid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.M{"created": -1})
+	cur, err := db.Collection("sb_sessions").Find(mg.Ctx, bson.M{"userId": oid}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(mg.Ctx)
+
+	var list []model.Session
+	for cur.Next(mg.Ctx) {
+		var ls LocalSession
+		if err := cur.Decode(&ls); err != nil {
+			return nil, err
+		}
+
+		list = append(list, fromLocalSession(ls))
+	}
+
+	return list, cur.Err()
+}
+
+func (mg *Mongo) RotateSession(dbName, id, oldHash, newHash string, expires time.Time) (bool, error) {
+	db := mg.Client.Database(dbName)
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+
+	filter := bson.M{FieldID: oid, "hash": oldHash}
+	update := bson.M{"$set": bson.M{
+		"hash":      newHash,
+		"refreshed": time.Now(),
+		"expires":   expires,
+	}}
+
+	res, err := db.Collection("sb_sessions").UpdateOne(mg.Ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (mg *Mongo) DeleteSession(dbName, id string) error {
+	db := mg.Client.Database(dbName)
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("sb_sessions").DeleteOne(mg.Ctx, bson.M{FieldID: oid})
+	return err
+}
+
+func (mg *Mongo) DeleteSessions(dbName, userID string) error {
+	db := mg.Client.Database(dbName)
+
+	oid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("sb_sessions").DeleteMany(mg.Ctx, bson.M{"userId": oid})
+	return err
+}
@@ -0,0 +1,42 @@
+package mongo
+
+import (
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type LocalUserTeams struct {
+	UserID string   `bson:"userId" json:"userId"`
+	Teams  []string `bson:"teams" json:"teams"`
+}
+
+func (mg *Mongo) ListUserTeams(dbName, userID string) ([]string, error) {
+	db := mg.Client.Database(dbName)
+
+	var lt LocalUserTeams
+	err := db.Collection("sb_user_teams").FindOne(mg.Ctx, bson.M{"userId": userID}).Decode(&lt)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(lt.Teams)
+	return lt.Teams, nil
+}
+
+func (mg *Mongo) SetUserTeams(dbName, userID string, teams []string) error {
+	db := mg.Client.Database(dbName)
+
+	if teams == nil {
+		teams = []string{}
+	}
+
+	update := bson.M{"$set": bson.M{"teams": teams}}
+	opts := options.Update().SetUpsert(true)
+	_, err := db.Collection("sb_user_teams").UpdateOne(mg.Ctx, bson.M{"userId": userID}, update, opts)
+	return err
+}
@@ -0,0 +1,57 @@
+package mongo
+
+import (
+	"github.com/staticbackendhq/core/database"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// inTransaction returns true if the persister runs in a session transaction
+func (mg *Mongo) inTransaction() bool {
+	_, ok := mg.Ctx.(mongo.SessionContext)
+	return ok
+}
+
+// async runs fn in a goroutine, inside a transaction it runs before returning
+// since the session cannot be used once it ends
+func (mg *Mongo) async(fn func()) {
+	if mg.inTransaction() {
+		fn()
+		return
+	}
+	go fn()
+}
+
+// WithTransaction runs fn in a session transaction, MongoDB requires a
+// replica set for transactions. The driver may retry fn on transient errors.
+func (mg *Mongo) WithTransaction(fn func(tx database.Persister) error) error {
+	// nested transactions are part of the current one
+	if mg.inTransaction() {
+		return fn(mg)
+	}
+
+	sess, err := mg.Client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer sess.EndSession(mg.Ctx)
+
+	events := &database.TxEvents{}
+
+	_, err = sess.WithTransaction(mg.Ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		events.Reset()
+
+		txmg := &Mongo{
+			Client:          mg.Client,
+			Ctx:             sc,
+			PublishDocument: events.Publish,
+			log:             mg.log,
+		}
+		return nil, fn(txmg)
+	})
+	if err != nil {
+		return err
+	}
+
+	events.Flush(mg.PublishDocument)
+	return nil
+}
@@ -0,0 +1,175 @@
+package mongo
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type LocalTwoFactor struct {
+	ID            primitive.ObjectID `bson:"_id" json:"id"`
+	UserID        primitive.ObjectID `bson:"userId" json:"userId"`
+	Secret        []byte             `bson:"secret" json:"-"`
+	Enabled       bool               `bson:"enabled" json:"enabled"`
+	RecoveryCodes []string           `bson:"codes" json:"-"`
+	LastStep      int64              `bson:"lastStep" json:"-"`
+	Created       time.Time          `bson:"created" json:"created"`
+}
+
+type LocalEmailVerification struct {
+	Enabled     bool     `bson:"enabled" json:"enabled"`
+	BlockLogin  bool     `bson:"blockLogin" json:"blockLogin"`
+	Permissions []string `bson:"perms" json:"permissions"`
+	Link        string   `bson:"link" json:"link"`
+	FromEmail   string   `bson:"fromEmail" json:"fromEmail"`
+	FromName    string   `bson:"fromName" json:"fromName"`
+	Subject     string   `bson:"subject" json:"subject"`
+	Body        string   `bson:"body" json:"body"`
+}
+
+type LocalPasswordPolicy struct {
+	MinLength      int  `bson:"minLength" json:"minLength"`
+	RequireLower   bool `bson:"lower" json:"requireLower"`
+	RequireUpper   bool `bson:"upper" json:"requireUpper"`
+	RequireDigit   bool `bson:"digit" json:"requireDigit"`
+	RequireSymbol  bool `bson:"symbol" json:"requireSymbol"`
+	RejectBreached bool `bson:"breached" json:"rejectBreached"`
+}
+
+type LocalChannelRetention struct {
+	Channel     string `bson:"channel" json:"channel"`
+	MaxMessages int    `bson:"maxMessages" json:"maxMessages"`
+	MaxMinutes  int    `bson:"maxMinutes" json:"maxMinutes"`
+}
+
+// LocalSettings stores the channel rules as JSON like the collection rules
+type LocalSettings struct {
+	ID                   primitive.ObjectID      `bson:"_id" json:"id"`
+	RequireRootTwoFactor bool                    `bson:"rootTwoFactor" json:"requireRootTwoFactor"`
+	EmailVerification    LocalEmailVerification  `bson:"emailVerification" json:"emailVerification"`
+	PasswordPolicy       LocalPasswordPolicy     `bson:"passwordPolicy" json:"passwordPolicy"`
+	ChannelRetention     []LocalChannelRetention `bson:"retention" json:"channelRetention"`
+	ChannelRules         string                  `bson:"channelRules" json:"channelRules"`
+	Updated              time.Time               `bson:"updated" json:"updated"`
+}
+
+func (mg *Mongo) GetTwoFactor(dbName, userID string) (tf model.TwoFactor, err error) {
+	db := mg.Client.Database(dbName)
+
+	oid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return
+	}
+
+	var lt LocalTwoFactor
+	sr := db.Collection("sb_two_factor").FindOne(mg.Ctx, bson.M{"userId": oid})
+	if err = sr.Decode(&lt); err == mongo.ErrNoDocuments {
+		return model.TwoFactor{}, nil
+	} else if err != nil {
+		return
+	}
+
+	tf = model.TwoFactor{
+		UserID:        lt.UserID.Hex(),
+		Secret:        lt.Secret,
+		Enabled:       lt.Enabled,
+		RecoveryCodes: lt.RecoveryCodes,
+		LastStep:      lt.LastStep,
+		Created:       lt.Created,
+	}
+	return
+}
+
+func (mg *Mongo) SaveTwoFactor(dbName string, tf model.TwoFactor) error {
+	db := mg.Client.Database(dbName)
+
+	oid, err := primitive.ObjectIDFromHex(tf.UserID)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"secret":   tf.Secret,
+			"enabled":  tf.Enabled,
+			"codes":    tf.RecoveryCodes,
+			"lastStep": tf.LastStep,
+		},
+		"$setOnInsert": bson.M{FieldID: primitive.NewObjectID(), "created": time.Now()},
+	}
+
+	opts := options.Update().SetUpsert(true)
+	_, err = db.Collection("sb_two_factor").UpdateOne(mg.Ctx, bson.M{"userId": oid}, update, opts)
+	return err
+}
+
+func (mg *Mongo) DeleteTwoFactor(dbName, userID string) error {
+	db := mg.Client.Database(dbName)
+
+	oid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection("sb_two_factor").DeleteOne(mg.Ctx, bson.M{"userId": oid})
+	return err
+}
+
+func (mg *Mongo) GetSettings(dbName string) (s model.DatabaseSettings, err error) {
+	db := mg.Client.Database(dbName)
+
+	var ls LocalSettings
+	sr := db.Collection("sb_settings").FindOne(mg.Ctx, bson.M{})
+	if err = sr.Decode(&ls); err == mongo.ErrNoDocuments {
+		return model.DatabaseSettings{}, nil
+	} else if err != nil {
+		return
+	}
+
+	s.RequireRootTwoFactor = ls.RequireRootTwoFactor
+	s.EmailVerification = model.EmailVerification(ls.EmailVerification)
+	s.PasswordPolicy = model.PasswordPolicy(ls.PasswordPolicy)
+	for _, r := range ls.ChannelRetention {
+		s.ChannelRetention = append(s.ChannelRetention, model.ChannelRetention(r))
+	}
+
+	if len(ls.ChannelRules) > 0 {
+		err = json.Unmarshal([]byte(ls.ChannelRules), &s.ChannelRules)
+	}
+	return
+}
+
+func (mg *Mongo) SaveSettings(dbName string, s model.DatabaseSettings) error {
+	db := mg.Client.Database(dbName)
+
+	retention := make([]LocalChannelRetention, 0)
+	for _, r := range s.ChannelRetention {
+		retention = append(retention, LocalChannelRetention(r))
+	}
+
+	channelRules, err := json.Marshal(s.ChannelRules)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"rootTwoFactor":     s.RequireRootTwoFactor,
+			"emailVerification": LocalEmailVerification(s.EmailVerification),
+			"passwordPolicy":    LocalPasswordPolicy(s.PasswordPolicy),
+			"retention":         retention,
+			"channelRules":      string(channelRules),
+			"updated":           time.Now(),
+		},
+		"$setOnInsert": bson.M{FieldID: primitive.NewObjectID()},
+	}
+
+	opts := options.Update().SetUpsert(true)
+	_, err = db.Collection("sb_settings").UpdateOne(mg.Ctx, bson.M{}, update, opts)
+	return err
+}
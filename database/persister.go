@@ -1,6 +1,8 @@
 package database
 
 import (
+	"time"
+
 	"github.com/staticbackendhq/core/model"
 )
 
@@ -81,6 +83,8 @@ type Persister interface {
 	SetUserRole(dbName, email string, role int) error
 	// UserSetPassword user initiated password reset
 	UserSetPassword(dbName, userID, password string) error
+	// SetEmailVerified marks the email of a user as verified
+	SetEmailVerified(dbName, userID string) error
 	// RemoveUser permanently removes a user from an account
 	RemoveUser(auth model.Auth, dbName, userID string) error
 
@@ -92,24 +96,142 @@ type Persister interface {
 	// ListDocuments lists records from a collection ordered/sorted by params
 	ListDocuments(auth model.Auth, dbName, col string, params model.ListParams) (model.PagedResult, error)
 	// QueryDocuments filters record based on criterias ordered/sorted by params
-	QueryDocuments(auth model.Auth, dbName, col string, filter map[string]interface{}, params model.ListParams) (model.PagedResult, error)
-	// GetDocumentByID returns a record by its ID
-	GetDocumentByID(auth model.Auth, dbName, col, id string) (map[string]interface{}, error)
-	// GetDocumentsByIDs returns a list of records by multiple ids
-	GetDocumentsByIDs(auth model.Auth, dbName, col string, ids []string) ([]map[string]interface{}, error)
-	// UpdateDocument updates a full or partial record
+	QueryDocuments(auth model.Auth, dbName, col string, filter Filter, params model.ListParams) (model.PagedResult, error)
+	// GetDocumentByID returns a record by its ID, the optional fields are
+	// a projection (see ParseFields)
+	GetDocumentByID(auth model.Auth, dbName, col, id string, fields ...string) (map[string]interface{}, error)
+	// GetDocumentsByIDs returns a list of records by multiple ids, the
+	// optional fields are a projection (see ParseFields)
+	GetDocumentsByIDs(auth model.Auth, dbName, col string, ids []string, fields ...string) ([]map[string]interface{}, error)
+	// UpdateDocument updates a full or partial record and increments its
+	// version. When doc contains a version (FieldVersion) the record is only
+	// updated if it's the current version, otherwise ErrVersionConflict is
+	// returned.
 	UpdateDocument(auth model.Auth, dbName, col, id string, doc map[string]interface{}) (map[string]interface{}, error)
 	// UpdateDocuments updates multiple records matching filters
-	UpdateDocuments(auth model.Auth, dbName, col string, filters map[string]interface{}, updateFields map[string]interface{}) (int64, error)
+	UpdateDocuments(auth model.Auth, dbName, col string, filters Filter, updateFields map[string]interface{}) (int64, error)
 	// IncrementValue increments/decrements a specific field in a record
 	IncrementValue(auth model.Auth, dbName, col, id, field string, n int) error
 	// DeleteDocument removes a record by its ID
 	DeleteDocument(auth model.Auth, dbName, col, id string) (int64, error)
-	DeleteDocuments(auth model.Auth, dbName, col string, filters map[string]interface{}) (int64, error)
+	DeleteDocuments(auth model.Auth, dbName, col string, filters Filter) (int64, error)
 	// ListCollections returns all collections for a database
 	ListCollections(dbName string) ([]string, error)
-	// ParseQuery parses the filters into an internal query clauses
-	ParseQuery(clauses [][]interface{}) (map[string]interface{}, error)
+	// ParseQuery parses the raw clauses into a typed Filter
+	ParseQuery(clauses [][]interface{}) (Filter, error)
+	// WithTransaction runs fn in a transaction, the operations made with tx
+	// are committed when fn returns nil and rolled back otherwise. Document
+	// events are published after the commit.
+	WithTransaction(fn func(tx Persister) error) error
+
+	// collection schemas
+	// ListSchemas returns the collection schemas of a database
+	ListSchemas(dbName string) ([]model.CollectionSchema, error)
+	// GetSchema returns the schema of a collection, ok is false when the
+	// collection has no schema
+	GetSchema(dbName, col string) (schema model.CollectionSchema, ok bool, err error)
+	// SaveSchema creates or replaces the schema of a collection, it's
+	// enforced when creating and updating documents (see ApplySchema)
+	SaveSchema(dbName string, schema model.CollectionSchema) error
+	// DeleteSchema removes the schema of a collection
+	DeleteSchema(dbName, col string) error
+
+	// collection rules
+	// ListRules returns the permission rules of a database's collections
+	ListRules(dbName string) ([]model.CollectionRules, error)
+	// GetRules returns the permission rules of a collection, ok is false when
+	// the collection has no rules
+	GetRules(dbName, col string) (rules model.CollectionRules, ok bool, err error)
+	// SaveRules creates or replaces the permission rules of a collection,
+	// they replace the permissions from the collection's name (see ResolveRule)
+	SaveRules(dbName string, rules model.CollectionRules) error
+	// DeleteRules removes the permission rules of a collection
+	DeleteRules(dbName, col string) error
+
+	// named roles
+	// ListRoles returns the named roles of a database ordered by level
+	ListRoles(dbName string) ([]model.Role, error)
+	// SaveRole creates or replaces a named role by its name (see ValidateRole)
+	SaveRole(dbName string, role model.Role) error
+	// DeleteRole removes a named role, the users having it keep its level
+	DeleteRole(dbName, name string) error
+
+	// teams
+	// ListUserTeams returns the teams of a user ordered by name
+	ListUserTeams(dbName, userID string) ([]string, error)
+	// SetUserTeams replaces the teams of a user (see ValidateTeams)
+	SetUserTeams(dbName, userID string, teams []string) error
+
+	// API keys
+	// CreateAPIKey creates an API key, its ID is set by the caller since it's
+	// part of the key (see NewAPIKey)
+	CreateAPIKey(dbName string, key model.APIKey) error
+	// ListAPIKeys returns the API keys of a database
+	ListAPIKeys(dbName string) ([]model.APIKey, error)
+	// GetAPIKey returns an API key by its ID
+	GetAPIKey(dbName, id string) (model.APIKey, error)
+	// RevokeAPIKey removes an API key
+	RevokeAPIKey(dbName, id string) error
+	// TouchAPIKey sets the last time an API key was used
+	TouchAPIKey(dbName, id string, used time.Time) error
+
+	// sessions
+	// CreateSession creates a session, its ID is set by the caller since it's
+	// part of the refresh token (see NewRefreshToken)
+	CreateSession(dbName string, s model.Session) error
+	// GetSession returns a session by its ID
+	GetSession(dbName, id string) (model.Session, error)
+	// ListSessions returns the sessions of a user, most recent first
+	ListSessions(dbName, userID string) ([]model.Session, error)
+	// RotateSession replaces the refresh token hash of a session only if it's
+	// still oldHash, false is returned when the session was not updated
+	RotateSession(dbName, id, oldHash, newHash string, expires time.Time) (bool, error)
+	// DeleteSession removes a session
+	DeleteSession(dbName, id string) error
+	// DeleteSessions removes all sessions of a user
+	DeleteSessions(dbName, userID string) error
+
+	// two-factor authentication
+	// GetTwoFactor returns the two-factor authentication of a user, its
+	// UserID is empty when the user never enrolled
+	GetTwoFactor(dbName, userID string) (model.TwoFactor, error)
+	// SaveTwoFactor creates or replaces the two-factor authentication of a user
+	SaveTwoFactor(dbName string, tf model.TwoFactor) error
+	// DeleteTwoFactor removes the two-factor authentication of a user
+	DeleteTwoFactor(dbName, userID string) error
+
+	// external login identities
+	// AddIdentity links an external login identity to a user, there's only
+	// one identity per provider and subject
+	AddIdentity(dbName string, ident model.Identity) (id string, err error)
+	// FindIdentity returns the identity of a provider's subject, its ID is
+	// empty when it's not linked to a user
+	FindIdentity(dbName, provider, subject string) (model.Identity, error)
+	// ListIdentities returns the identities linked to a user
+	ListIdentities(dbName, userID string) ([]model.Identity, error)
+	// DeleteIdentity unlinks an identity from a user
+	DeleteIdentity(dbName, userID, id string) error
+	// DeleteIdentities unlinks all identities of a user
+	DeleteIdentities(dbName, userID string) error
+
+	// account invitations
+	// CreateInvitation creates an invitation, its ID is set by the caller
+	// since it's part of the token (see NewInvitationToken)
+	CreateInvitation(dbName string, inv model.Invitation) error
+	// GetInvitation returns an invitation by its ID
+	GetInvitation(dbName, id string) (model.Invitation, error)
+	// ListInvitations returns the pending invitations of an account, most
+	// recent first
+	ListInvitations(dbName, accountID string) ([]model.Invitation, error)
+	// DeleteInvitation removes an invitation of an account
+	DeleteInvitation(dbName, accountID, id string) error
+
+	// database settings
+	// GetSettings returns the settings of a database, the zero value is
+	// returned when they were never saved
+	GetSettings(dbName string) (model.DatabaseSettings, error)
+	// SaveSettings replaces the settings of a database
+	SaveSettings(dbName string, s model.DatabaseSettings) error
 
 	// form functions
 	// AddFormSubmission adds a form submission
@@ -159,5 +281,8 @@ type Persister interface {
 	// ListAllFiles lists all file
 	ListAllFiles(dbName, accountID string) ([]model.File, error)
 	// Count returns the numbers of entries in a collection based on optional filters
-	Count(auth model.Auth, dbName, col string, filters map[string]interface{}) (int64, error)
+	Count(auth model.Auth, dbName, col string, filters Filter) (int64, error)
+	// Aggregate returns the metrics for each group of records matching the
+	// filters ordered by the group by values (see ValidateAggregate)
+	Aggregate(auth model.Auth, dbName, col string, filters Filter, groupBy []string, metrics []Metric) ([]map[string]any, error)
 }
@@ -13,7 +13,7 @@ func (pg *PostgreSQL) FindUser(dbName, userID, token string) (tok model.User, er
 	WHERE id = $1 AND token = $2
 `, dbName)
 
-	row := pg.DB.QueryRow(qry, userID, token)
+	row := pg.conn().QueryRow(qry, userID, token)
 
 	err = scanToken(row, &tok)
 	return
@@ -26,7 +26,7 @@ func (pg *PostgreSQL) FindRootUser(dbName, userID, accountID, token string) (tok
 		WHERE id = $1 AND account_id = $2 AND token = $3
 `, dbName)
 
-	row := pg.DB.QueryRow(qry, userID, accountID, token)
+	row := pg.conn().QueryRow(qry, userID, accountID, token)
 
 	err = scanToken(row, &tok)
 	return
@@ -39,7 +39,7 @@ func (pg *PostgreSQL) GetRootForBase(dbName string) (tok model.User, err error)
 	WHERE role = 100
 `, dbName)
 
-	row := pg.DB.QueryRow(qry)
+	row := pg.conn().QueryRow(qry)
 
 	err = scanToken(row, &tok)
 	return
@@ -52,7 +52,7 @@ func (pg *PostgreSQL) ListAccounts(dbName string) ([]model.Account, error) {
 	ORDER BY created DESC;
 	`, dbName)
 
-	rows, err := pg.DB.Query(qry)
+	rows, err := pg.conn().Query(qry)
 	if err != nil {
 		return nil, err
 	}
@@ -81,7 +81,7 @@ func (pg *PostgreSQL) ListUsers(dbName, accountID string) ([]model.User, error)
 	WHERE account_id = $1;
 	`, dbName)
 
-	rows, err := pg.DB.Query(qry, accountID)
+	rows, err := pg.conn().Query(qry, accountID)
 	if err != nil {
 		return nil, err
 	}
@@ -110,7 +110,7 @@ func (pg *PostgreSQL) FindUserByEmail(dbName, email string) (tok model.User, err
 	WHERE email = $1
 `, dbName)
 
-	row := pg.DB.QueryRow(qry, email)
+	row := pg.conn().QueryRow(qry, email)
 
 	err = scanToken(row, &tok)
 	return
@@ -123,7 +123,7 @@ func (pg *PostgreSQL) GetUserByID(dbName, accountID, userID string) (user model.
 	WHERE id = $1 AND account_id = $2;
 `, dbName)
 
-	row := pg.DB.QueryRow(qry, userID, accountID)
+	row := pg.conn().QueryRow(qry, userID, accountID)
 
 	err = scanToken(row, &user)
 	return
@@ -139,6 +139,7 @@ func scanToken(rows Scanner, tok *model.User) error {
 		&tok.Role,
 		&tok.ResetCode,
 		&tok.Created,
+		&tok.EmailVerified,
 	)
 }
 
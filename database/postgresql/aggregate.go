@@ -0,0 +1,118 @@
+package postgresql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+func (pg *PostgreSQL) Aggregate(auth model.Auth, dbName, col string, filters database.Filter, groupBy []string, metrics []database.Metric) (rows []map[string]any, err error) {
+	if err = database.ValidateAggregate(groupBy, metrics); err != nil {
+		return
+	}
+
+	where, filters, err := pg.secure(auth, dbName, col, false, filters)
+	if err != nil {
+		return
+	}
+
+	where, args := applyFilter(where, filters, 3)
+	args = append([]any{auth.AccountID, auth.UserID}, args...)
+
+	var columns, positions []string
+	for i, field := range groupBy {
+		columns = append(columns, sortExpr(field))
+		positions = append(positions, fmt.Sprintf("%d", i+1))
+	}
+
+	for _, metric := range metrics {
+		columns = append(columns, metricExpr(metric))
+	}
+
+	var grouping string
+	if len(positions) > 0 {
+		grouping = fmt.Sprintf(
+			"GROUP BY %s ORDER BY %s",
+			strings.Join(positions, ", "),
+			strings.Join(positions, ", "),
+		)
+	}
+
+	qry := fmt.Sprintf(`
+		SELECT %s
+		FROM %s.%s
+		%s
+		%s;
+	`, strings.Join(columns, ", "), dbName, model.CleanCollectionName(col), where, grouping)
+
+	result, err := pg.conn().Query(qry, args...)
+	if err != nil {
+		if !isTableExists(err) {
+			return nil, nil
+		}
+		return
+	}
+	defer result.Close()
+
+	for result.Next() {
+		groups := make([][]byte, len(groupBy))
+		values := make([]any, len(metrics))
+
+		var dest []any
+		for i := range groups {
+			dest = append(dest, &groups[i])
+		}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+
+		if err = result.Scan(dest...); err != nil {
+			return
+		}
+
+		row := make(map[string]any)
+		for i, field := range groupBy {
+			var v any
+			if err = json.Unmarshal(groups[i], &v); err != nil {
+				return
+			}
+			row[field] = v
+		}
+
+		for i, metric := range metrics {
+			row[metric.Name] = database.MetricValue(metric.Op, values[i])
+		}
+
+		rows = append(rows, row)
+	}
+
+	err = result.Err()
+	return
+}
+
+// metricExpr returns the SQL aggregate expression for the metric, the number
+// metrics ignore values that are not a JSON number
+func metricExpr(metric database.Metric) string {
+	number := fmt.Sprintf(
+		"CASE WHEN jsonb_typeof(%s) = 'number' THEN (%s)::numeric END",
+		jsonField(metric.Field), jsonText(metric.Field),
+	)
+
+	switch metric.Op {
+	case database.MetricCountDistinct:
+		return fmt.Sprintf("COUNT(DISTINCT NULLIF(%s, 'null'::jsonb))", jsonField(metric.Field))
+	case database.MetricSum:
+		return fmt.Sprintf("COALESCE(SUM(%s), 0)::float8", number)
+	case database.MetricAvg:
+		return fmt.Sprintf("AVG(%s)::float8", number)
+	case database.MetricMin:
+		return fmt.Sprintf("MIN(%s)::float8", number)
+	case database.MetricMax:
+		return fmt.Sprintf("MAX(%s)::float8", number)
+	default:
+		return "COUNT(*)"
+	}
+}
@@ -0,0 +1,113 @@
+package postgresql
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+func (pg *PostgreSQL) CreateAPIKey(dbName string, key model.APIKey) error {
+	b, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return err
+	}
+
+	qry := fmt.Sprintf(`
+		INSERT INTO %s.sb_api_keys(id, name, key_hash, account_id, user_id, email, scopes, expires, created)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, dbName)
+
+	_, err = pg.conn().Exec(
+		qry,
+		key.ID,
+		key.Name,
+		key.KeyHash,
+		key.AccountID,
+		key.UserID,
+		key.Email,
+		b,
+		key.Expires,
+		time.Now(),
+	)
+	return err
+}
+
+func (pg *PostgreSQL) ListAPIKeys(dbName string) (results []model.APIKey, err error) {
+	qry := fmt.Sprintf(`
+		SELECT * 
+		FROM %s.sb_api_keys
+		ORDER BY created DESC
+	`, dbName)
+
+	rows, err := pg.conn().Query(qry)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var k model.APIKey
+		if err = scanAPIKey(rows, &k); err != nil {
+			return
+		}
+
+		results = append(results, k)
+	}
+
+	err = rows.Err()
+	return
+}
+
+func (pg *PostgreSQL) GetAPIKey(dbName, id string) (key model.APIKey, err error) {
+	qry := fmt.Sprintf(`
+		SELECT * 
+		FROM %s.sb_api_keys
+		WHERE id = $1
+	`, dbName)
+
+	err = scanAPIKey(pg.conn().QueryRow(qry, id), &key)
+	return
+}
+
+func (pg *PostgreSQL) RevokeAPIKey(dbName, id string) error {
+	qry := fmt.Sprintf(`
+		DELETE FROM %s.sb_api_keys
+		WHERE id = $1
+	`, dbName)
+
+	_, err := pg.conn().Exec(qry, id)
+	return err
+}
+
+func (pg *PostgreSQL) TouchAPIKey(dbName, id string, used time.Time) error {
+	qry := fmt.Sprintf(`
+		UPDATE %s.sb_api_keys SET
+			last_used = $2
+		WHERE id = $1
+	`, dbName)
+
+	_, err := pg.conn().Exec(qry, id, used)
+	return err
+}
+
+func scanAPIKey(rows Scanner, k *model.APIKey) error {
+	var b []byte
+	err := rows.Scan(
+		&k.ID,
+		&k.Name,
+		&k.KeyHash,
+		&k.AccountID,
+		&k.UserID,
+		&k.Email,
+		&b,
+		&k.Expires,
+		&k.LastUsed,
+		&k.Created,
+	)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &k.Scopes)
+}
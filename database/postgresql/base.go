@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/lib/pq"
+	"github.com/staticbackendhq/core/database"
 	"github.com/staticbackendhq/core/model"
 )
 
@@ -42,6 +43,12 @@ func (j *JSONB) Scan(value interface{}) error {
 }
 
 func (pg *PostgreSQL) CreateDocument(auth model.Auth, dbName, col string, doc map[string]interface{}) (inserted map[string]interface{}, err error) {
+	if err = pg.enforceSchema(dbName, col, doc, false); err != nil {
+		return nil, err
+	} else if err = database.CanCreate(pg, auth, dbName, col, doc); err != nil {
+		return nil, err
+	}
+
 	inserted = doc
 
 	cleancol := model.CleanCollectionName(col)
@@ -61,7 +68,7 @@ func (pg *PostgreSQL) CreateDocument(auth model.Auth, dbName, col string, doc ma
 		CREATE INDEX IF NOT EXISTS %s_acctid_idx ON %s.%s (account_id);			
 	`, dbName, cleancol, dbName, dbName, cleancol, dbName, cleancol)
 
-	if _, err = pg.DB.Exec(qry); err != nil {
+	if _, err = pg.conn().Exec(qry); err != nil {
 		err = fmt.Errorf("error creating table: %w", err)
 		return
 	}
@@ -74,13 +81,17 @@ func (pg *PostgreSQL) CreateDocument(auth model.Auth, dbName, col string, doc ma
 		RETURNING id;
 	`, dbName, model.CleanCollectionName(col))
 
+	now := time.Now()
+	doc[database.FieldVersion] = 1
+	doc[database.FieldUpdated] = now
+
 	b, err := json.Marshal(doc)
 	if err != nil {
 		err = fmt.Errorf("error executing INSERT: %w", err)
 		return
 	}
 
-	err = pg.DB.QueryRow(qry, auth.AccountID, auth.UserID, b, time.Now()).Scan(&id)
+	err = pg.conn().QueryRow(qry, auth.AccountID, auth.UserID, b, now).Scan(&id)
 	if err != nil {
 		err = fmt.Errorf("error getting the new row ID: %w", err)
 	}
@@ -96,23 +107,40 @@ func (pg *PostgreSQL) CreateDocument(auth model.Auth, dbName, col string, doc ma
 func (pg *PostgreSQL) BulkCreateDocument(auth model.Auth, dbName, col string, docs []interface{}) error {
 	//TODO: Naive implementation, not sure if PostgreSQL
 	// has a better way for bulk insert, but will suffice for now.
-	for _, doc := range docs {
-		d, ok := doc.(map[string]interface{})
-		if !ok {
-			return errors.New("unable to cast doc as map[string]interface{}")
-		}
-
-		if _, err := pg.CreateDocument(auth, dbName, col, d); err != nil {
-			return err
+	// All documents are created or none of them.
+	return pg.WithTransaction(func(tx database.Persister) error {
+		for _, doc := range docs {
+			d, ok := doc.(map[string]interface{})
+			if !ok {
+				return errors.New("unable to cast doc as map[string]interface{}")
+			}
+
+			if _, err := tx.CreateDocument(auth, dbName, col, d); err != nil {
+				return err
+			}
 		}
-	}
-	return nil
+		return nil
+	})
 }
 
 func (pg *PostgreSQL) ListDocuments(auth model.Auth, dbName, col string, params model.ListParams) (result model.PagedResult, err error) {
-	where := secureRead(auth, col)
+	where, filters, err := pg.secure(auth, dbName, col, false, nil)
+	if err != nil {
+		return
+	}
 
-	paging := setPaging(params)
+	where, args := applyFilter(where, filters, 3)
+	args = append([]any{auth.AccountID, auth.UserID}, args...)
+
+	cond, paging, pagingArgs, err := setPaging(params, len(args)+1)
+	if err != nil {
+		return
+	}
+
+	projection, err := database.ParseFields(params.Fields)
+	if err != nil {
+		return
+	}
 
 	result.Page = params.Page
 	result.Size = params.Size
@@ -123,7 +151,7 @@ func (pg *PostgreSQL) ListDocuments(auth model.Auth, dbName, col string, params
 		%s
 	`, dbName, model.CleanCollectionName(col), where)
 
-	if err = pg.DB.QueryRow(qry, auth.AccountID, auth.UserID).Scan(&result.Total); err != nil {
+	if err = pg.conn().QueryRow(qry, args...).Scan(&result.Total); err != nil {
 		if !isTableExists(err) {
 			return result, nil
 		}
@@ -131,19 +159,20 @@ func (pg *PostgreSQL) ListDocuments(auth model.Auth, dbName, col string, params
 	}
 
 	qry = fmt.Sprintf(`
-		SELECT * 
+		SELECT %s
 		FROM %s.%s 
 		%s
 		%s
-	`, dbName, model.CleanCollectionName(col), where, paging)
+	`, selectColumns(projection.Keep(dataFields(params)...)), dbName, model.CleanCollectionName(col), where+cond, paging)
 
-	rows, err := pg.DB.Query(qry, auth.AccountID, auth.UserID)
+	rows, err := pg.conn().Query(qry, append(args, pagingArgs...)...)
 	if err != nil {
 		pg.log.Error().Err(err).Msg("error in select")
 		return
 	}
 	defer rows.Close()
 
+	var last Document
 	for rows.Next() {
 		var doc Document
 		if err = scanDocument(rows, &doc); err != nil {
@@ -154,17 +183,41 @@ func (pg *PostgreSQL) ListDocuments(auth model.Auth, dbName, col string, params
 		doc.Data[FieldAccountID] = doc.AccountID
 
 		result.Results = append(result.Results, doc.Data)
+		last = doc
 	}
 
-	err = rows.Err()
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	if n := int64(len(result.Results)); n > 0 && n == params.Size {
+		result.Cursor = database.EncodeCursor(params, cursorValues(params, last), last.ID)
+	}
+
+	for _, doc := range result.Results {
+		projection.Apply(doc)
+	}
 	return
 }
 
-func (pg *PostgreSQL) QueryDocuments(auth model.Auth, dbName, col string, filters map[string]interface{}, params model.ListParams) (result model.PagedResult, err error) {
-	where := secureRead(auth, col)
-	where = applyFilter(where, filters)
+func (pg *PostgreSQL) QueryDocuments(auth model.Auth, dbName, col string, filters database.Filter, params model.ListParams) (result model.PagedResult, err error) {
+	where, filters, err := pg.secure(auth, dbName, col, false, filters)
+	if err != nil {
+		return
+	}
+
+	where, args := applyFilter(where, filters, 3)
+	args = append([]any{auth.AccountID, auth.UserID}, args...)
 
-	paging := setPaging(params)
+	cond, paging, pagingArgs, err := setPaging(params, len(args)+1)
+	if err != nil {
+		return
+	}
+
+	projection, err := database.ParseFields(params.Fields)
+	if err != nil {
+		return
+	}
 
 	result.Page = params.Page
 	result.Size = params.Size
@@ -175,7 +228,7 @@ func (pg *PostgreSQL) QueryDocuments(auth model.Auth, dbName, col string, filter
 		%s
 	`, dbName, model.CleanCollectionName(col), where)
 
-	if err = pg.DB.QueryRow(qry, auth.AccountID, auth.UserID).Scan(&result.Total); err != nil {
+	if err = pg.conn().QueryRow(qry, args...).Scan(&result.Total); err != nil {
 		if !isTableExists(err) {
 			return result, nil
 		}
@@ -183,18 +236,19 @@ func (pg *PostgreSQL) QueryDocuments(auth model.Auth, dbName, col string, filter
 	}
 
 	qry = fmt.Sprintf(`
-		SELECT * 
+		SELECT %s
 		FROM %s.%s 
 		%s
 		%s
-	`, dbName, model.CleanCollectionName(col), where, paging)
+	`, selectColumns(projection.Keep(dataFields(params)...)), dbName, model.CleanCollectionName(col), where+cond, paging)
 
-	rows, err := pg.DB.Query(qry, auth.AccountID, auth.UserID)
+	rows, err := pg.conn().Query(qry, append(args, pagingArgs...)...)
 	if err != nil {
 		return
 	}
 	defer rows.Close()
 
+	var last Document
 	for rows.Next() {
 		var doc Document
 		if err = scanDocument(rows, &doc); err != nil {
@@ -205,22 +259,44 @@ func (pg *PostgreSQL) QueryDocuments(auth model.Auth, dbName, col string, filter
 		doc.Data[FieldAccountID] = doc.AccountID
 
 		result.Results = append(result.Results, doc.Data)
+		last = doc
 	}
 
-	err = rows.Err()
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	if n := int64(len(result.Results)); n > 0 && n == params.Size {
+		result.Cursor = database.EncodeCursor(params, cursorValues(params, last), last.ID)
+	}
+
+	for _, doc := range result.Results {
+		projection.Apply(doc)
+	}
 	return
 }
 
-func (pg *PostgreSQL) GetDocumentByID(auth model.Auth, dbName, col, id string) (map[string]interface{}, error) {
-	where := secureRead(auth, col)
+func (pg *PostgreSQL) GetDocumentByID(auth model.Auth, dbName, col, id string, fields ...string) (map[string]interface{}, error) {
+	where, filters, err := pg.secure(auth, dbName, col, false, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	where, args := applyFilter(where, filters, 4)
+	args = append([]any{auth.AccountID, auth.UserID, id}, args...)
+
+	projection, err := database.ParseFields(fields)
+	if err != nil {
+		return nil, err
+	}
 
 	qry := fmt.Sprintf(`
-		SELECT * 
+		SELECT %s
 		FROM %s.%s 
 		%s AND id = $3
-	`, dbName, model.CleanCollectionName(col), where)
+	`, selectColumns(projection), dbName, model.CleanCollectionName(col), where)
 
-	row := pg.DB.QueryRow(qry, auth.AccountID, auth.UserID, id)
+	row := pg.conn().QueryRow(qry, args...)
 
 	var doc Document
 	if err := scanDocument(row, &doc); err != nil {
@@ -230,19 +306,32 @@ func (pg *PostgreSQL) GetDocumentByID(auth model.Auth, dbName, col, id string) (
 	doc.Data[FieldID] = doc.ID
 	doc.Data[FieldAccountID] = doc.AccountID
 
+	projection.Apply(doc.Data)
+
 	return doc.Data, nil
 }
 
-func (pg *PostgreSQL) GetDocumentsByIDs(auth model.Auth, dbName, col string, ids []string) (docs []map[string]interface{}, err error) {
-	where := secureRead(auth, col)
+func (pg *PostgreSQL) GetDocumentsByIDs(auth model.Auth, dbName, col string, ids []string, fields ...string) (docs []map[string]interface{}, err error) {
+	where, filters, err := pg.secure(auth, dbName, col, false, nil)
+	if err != nil {
+		return []map[string]interface{}{}, err
+	}
+
+	where, args := applyFilter(where, filters, 4)
+	args = append([]any{auth.AccountID, auth.UserID, pq.Array(ids)}, args...)
+
+	projection, err := database.ParseFields(fields)
+	if err != nil {
+		return []map[string]interface{}{}, err
+	}
 
 	qry := fmt.Sprintf(`
-		SELECT * 
+		SELECT %s
 		FROM %s.%s 
-		%s AND id in ('%s'::uuid)
-	`, dbName, model.CleanCollectionName(col), where, strings.Join(ids, "'::uuid,'"))
+		%s AND id = ANY($3::uuid[])
+	`, selectColumns(projection), dbName, model.CleanCollectionName(col), where)
 
-	rows, err := pg.DB.Query(qry, auth.AccountID, auth.UserID)
+	rows, err := pg.conn().Query(qry, args...)
 	if err != nil {
 		return []map[string]interface{}{}, err
 	}
@@ -256,6 +345,9 @@ func (pg *PostgreSQL) GetDocumentsByIDs(auth model.Auth, dbName, col string, ids
 
 		doc.Data[FieldID] = doc.ID
 		doc.Data[FieldAccountID] = doc.AccountID
+
+		projection.Apply(doc.Data)
+
 		docs = append(docs, doc.Data)
 	}
 
@@ -263,21 +355,56 @@ func (pg *PostgreSQL) GetDocumentsByIDs(auth model.Auth, dbName, col string, ids
 }
 
 func (pg *PostgreSQL) UpdateDocument(auth model.Auth, dbName, col, id string, doc map[string]interface{}) (map[string]interface{}, error) {
-	where := secureWrite(auth, col)
+	expected, hasExpected, err := database.ExpectedVersion(doc)
+	if err != nil {
+		return nil, err
+	} else if err := pg.enforceSchema(dbName, col, doc, true); err != nil {
+		return nil, err
+	} else if err := pg.canUpdate(auth, dbName, col, []string{id}, doc); err != nil {
+		return nil, err
+	}
+
+	doc[database.FieldUpdated] = time.Now()
+
+	where, filters, err := pg.secure(auth, dbName, col, true, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []any{auth.AccountID, auth.UserID, id, b}
+	if hasExpected {
+		where += fmt.Sprintf("AND %s = $5 ", versionExpr)
+		args = append(args, expected)
+	}
+
+	where, ruleArgs := applyFilter(where, filters, len(args)+1)
+	args = append(args, ruleArgs...)
 
 	qry := fmt.Sprintf(`
 		UPDATE %s.%s SET
-			data = data || $4
+			data = data || $4 || %s
 		%s AND id = $3
-	`, dbName, model.CleanCollectionName(col), where)
+	`, dbName, model.CleanCollectionName(col), nextVersion, where)
 
-	b, err := json.Marshal(doc)
+	res, err := pg.conn().Exec(qry, args...)
 	if err != nil {
 		return nil, err
 	}
 
-	if _, err := pg.DB.Exec(qry, auth.AccountID, auth.UserID, id, b); err != nil {
+	if n, err := res.RowsAffected(); err != nil {
 		return nil, err
+	} else if n == 0 && hasExpected {
+		current, err := pg.GetDocumentByID(auth, dbName, col, id)
+		if err != nil {
+			return nil, err
+		} else if database.Version(current) != expected {
+			return nil, database.ErrVersionConflict
+		}
 	}
 
 	updated, err := pg.GetDocumentByID(auth, dbName, col, id)
@@ -285,14 +412,19 @@ func (pg *PostgreSQL) UpdateDocument(auth model.Auth, dbName, col, id string, do
 		return nil, err
 	}
 
-	pg.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBUpdated, updated)
+	pg.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBUpdated, database.Updated(updated, database.ChangedFields(doc)))
 
 	return updated, nil
 }
 
-func (pg *PostgreSQL) UpdateDocuments(auth model.Auth, dbName, col string, filters map[string]interface{}, updateFields map[string]interface{}) (n int64, err error) {
-	where := secureWrite(auth, col)
-	where = applyFilter(where, filters)
+func (pg *PostgreSQL) UpdateDocuments(auth model.Auth, dbName, col string, filters database.Filter, updateFields map[string]interface{}) (n int64, err error) {
+	where, filters, err := pg.secure(auth, dbName, col, true, filters)
+	if err != nil {
+		return
+	}
+
+	where, args := applyFilter(where, filters, 3)
+	args = append([]any{auth.AccountID, auth.UserID}, args...)
 
 	var ids []string
 	qry := fmt.Sprintf(`
@@ -301,7 +433,7 @@ func (pg *PostgreSQL) UpdateDocuments(auth model.Auth, dbName, col string, filte
 		%s
 	`, dbName, model.CleanCollectionName(col), where)
 
-	rows, err := pg.DB.Query(qry, auth.AccountID, auth.UserID)
+	rows, err := pg.conn().Query(qry, args...)
 	if err != nil {
 		return
 	}
@@ -317,17 +449,28 @@ func (pg *PostgreSQL) UpdateDocuments(auth model.Auth, dbName, col string, filte
 		return 0, nil
 	}
 
+	// the version is not checked for multiple documents
+	if _, _, err = database.ExpectedVersion(updateFields); err != nil {
+		return 0, err
+	} else if err = pg.enforceSchema(dbName, col, updateFields, true); err != nil {
+		return 0, err
+	} else if err = pg.canUpdate(auth, dbName, col, ids, updateFields); err != nil {
+		return 0, err
+	}
+
+	updateFields[database.FieldUpdated] = time.Now()
+
 	qry = fmt.Sprintf(`
 		UPDATE %s.%s SET
-			data = data || $3
+			data = data || $%d || %s
 		%s
-	`, dbName, model.CleanCollectionName(col), where)
+	`, dbName, model.CleanCollectionName(col), len(args)+1, nextVersion, where)
 
 	b, err := json.Marshal(updateFields)
 	if err != nil {
 		return 0, err
 	}
-	res, err := pg.DB.Exec(qry, auth.AccountID, auth.UserID, b)
+	res, err := pg.conn().Exec(qry, append(args, b)...)
 	if err != nil {
 		return 0, err
 	}
@@ -336,28 +479,40 @@ func (pg *PostgreSQL) UpdateDocuments(auth model.Auth, dbName, col string, filte
 		return 0, err
 	}
 
-	go func() {
+	fields := database.ChangedFields(updateFields)
+	pg.async(func() {
 		docs, err := pg.GetDocumentsByIDs(auth, dbName, col, ids)
 		if err != nil {
 			pg.log.Error().Err(err).Msgf("the documents with ids=%s are not received for publishDocument event", ids)
 		}
 		for _, doc := range docs {
-			pg.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBUpdated, doc)
+			pg.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBUpdated, database.Updated(doc, fields))
 		}
-	}()
+	})
 	return
 }
 
 func (pg *PostgreSQL) IncrementValue(auth model.Auth, dbName, col, id, field string, n int) error {
-	where := secureWrite(auth, col)
+	where, filters, err := pg.secure(auth, dbName, col, true, nil)
+	if err != nil {
+		return err
+	}
+
+	ts, err := json.Marshal(map[string]any{database.FieldUpdated: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	where, args := applyFilter(where, filters, 6)
+	args = append([]any{auth.AccountID, auth.UserID, id, n, ts}, args...)
 
 	qry := fmt.Sprintf(`
 		UPDATE %s.%s SET
-		data = jsonb_set(data, '{%s}', (COALESCE(data->>'%s','0')::int + $4)::text::jsonb)
+		data = jsonb_set(data, '{%s}', (COALESCE(data->>'%s','0')::int + $4)::text::jsonb) || %s || $5
 		%s AND id = $3
-	`, dbName, model.CleanCollectionName(col), field, field, where)
+	`, dbName, model.CleanCollectionName(col), field, field, nextVersion, where)
 
-	if _, err := pg.DB.Exec(qry, auth.AccountID, auth.UserID, id, n); err != nil {
+	if _, err := pg.conn().Exec(qry, args...); err != nil {
 		return err
 	}
 
@@ -366,13 +521,19 @@ func (pg *PostgreSQL) IncrementValue(auth model.Auth, dbName, col, id, field str
 		return err
 	}
 
-	pg.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBUpdated, updated)
+	pg.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBUpdated, database.Updated(updated, []string{field}))
 
 	return nil
 }
 
 func (pg *PostgreSQL) DeleteDocument(auth model.Auth, dbName, col, id string) (int64, error) {
-	where := secureWrite(auth, col)
+	where, filters, err := pg.secure(auth, dbName, col, true, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	where, args := applyFilter(where, filters, 4)
+	args = append([]any{auth.AccountID, auth.UserID, id}, args...)
 
 	qry := fmt.Sprintf(`
 		DELETE 
@@ -380,7 +541,7 @@ func (pg *PostgreSQL) DeleteDocument(auth model.Auth, dbName, col, id string) (i
 		%s AND id = $3
 	`, dbName, model.CleanCollectionName(col), where)
 
-	res, err := pg.DB.Exec(qry, auth.AccountID, auth.UserID, id)
+	res, err := pg.conn().Exec(qry, args...)
 	if err != nil {
 		return 0, err
 	}
@@ -389,9 +550,14 @@ func (pg *PostgreSQL) DeleteDocument(auth model.Auth, dbName, col, id string) (i
 	return res.RowsAffected()
 }
 
-func (pg *PostgreSQL) DeleteDocuments(auth model.Auth, dbName, col string, filters map[string]any) (n int64, err error) {
-	where := secureWrite(auth, col)
-	where = applyFilter(where, filters)
+func (pg *PostgreSQL) DeleteDocuments(auth model.Auth, dbName, col string, filters database.Filter) (n int64, err error) {
+	where, filters, err := pg.secure(auth, dbName, col, true, filters)
+	if err != nil {
+		return
+	}
+
+	where, args := applyFilter(where, filters, 3)
+	args = append([]any{auth.AccountID, auth.UserID}, args...)
 
 	var ids []string
 	qry := fmt.Sprintf(`
@@ -400,7 +566,7 @@ func (pg *PostgreSQL) DeleteDocuments(auth model.Auth, dbName, col string, filte
 		%s
 	`, dbName, model.CleanCollectionName(col), where)
 
-	rows, err := pg.DB.Query(qry, auth.AccountID, auth.UserID)
+	rows, err := pg.conn().Query(qry, args...)
 	if err != nil {
 		return
 	}
@@ -421,16 +587,16 @@ func (pg *PostgreSQL) DeleteDocuments(auth model.Auth, dbName, col string, filte
 		%s
 	`, dbName, model.CleanCollectionName(col), where)
 
-	res, err := pg.DB.Exec(qry, auth.AccountID, auth.UserID)
+	res, err := pg.conn().Exec(qry, args...)
 	if err != nil {
 		return 0, err
 	}
 
-	go func() {
+	pg.async(func() {
 		for _, id := range ids {
 			pg.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBDeleted, id)
 		}
-	}()
+	})
 
 	return res.RowsAffected()
 }
@@ -440,7 +606,7 @@ func (pg *PostgreSQL) ListCollections(dbName string) (results []string, err erro
 		SELECT table_name FROM information_schema.tables WHERE table_schema='%s'
 	`, strings.ToLower(dbName))
 
-	rows, err := pg.DB.Query(qry)
+	rows, err := pg.conn().Query(qry)
 	if err != nil {
 		return
 	}
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/staticbackendhq/core/database/dbtest"
 	"github.com/staticbackendhq/core/model"
 )
 
@@ -469,3 +470,185 @@ func TestQueryWithInOperator(t *testing.T) {
 		t.Errorf("expected to find blueTask ID in result set")
 	}
 }
+
+func TestQueryTypedComparisonAndQuotes(t *testing.T) {
+	col := "typedqry"
+
+	var many []interface{}
+	for i, likes := range []int{2, 10, 30} {
+		many = append(many, map[string]interface{}{
+			"title":   fmt.Sprintf("it's task %d", i),
+			"likes":   float64(likes),
+			"done":    likes > 5,
+			"created": time.Now().Add(time.Duration(-likes) * time.Hour).Format(time.RFC3339Nano),
+		})
+	}
+
+	if err := datastore.BulkCreateDocument(adminAuth, confDBName, col, many); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		clauses  [][]interface{}
+		expected int64
+	}{
+		{"numeric range", [][]interface{}{{"likes", ">", 5}, {"likes", "<=", 30}}, 2},
+		{"boolean", [][]interface{}{{"done", "=", false}}, 1},
+		{"timestamp", [][]interface{}{{"created", ">", time.Now().Add(-12 * time.Hour)}}, 2},
+		{"quote in value", [][]interface{}{{"title", "=", "it's task 1"}}, 1},
+		{"injection attempt", [][]interface{}{{"title", "=", "x' OR '1'='1"}}, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			filters, err := datastore.ParseQuery(tc.clauses)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := datastore.QueryDocuments(adminAuth, confDBName, col, filters, model.ListParams{Page: 1, Size: 10})
+			if err != nil {
+				t.Fatal(err)
+			} else if result.Total != tc.expected {
+				t.Errorf("expected total to be %d got %d", tc.expected, result.Total)
+			}
+		})
+	}
+}
+
+func TestQueryNestedGroups(t *testing.T) {
+	col := "groupqry"
+
+	var many []interface{}
+	for i, likes := range []int{2, 10, 30} {
+		many = append(many, map[string]interface{}{
+			"title": fmt.Sprintf("task %d", i),
+			"likes": float64(likes),
+			"done":  likes > 5,
+		})
+	}
+
+	if err := datastore.BulkCreateDocument(adminAuth, confDBName, col, many); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		clauses  [][]interface{}
+		expected int64
+	}{
+		{"or", [][]interface{}{
+			{"or", [][]interface{}{{"likes", "=", 2}, {"likes", "=", 30}}},
+		}, 2},
+		{"and inside or", [][]interface{}{
+			{"or", [][]interface{}{
+				{"and", [][]interface{}{{"done", "=", true}, {"likes", "<", 20}}},
+				{"likes", "=", 2},
+			}},
+		}, 2},
+		{"not", [][]interface{}{
+			{"not", [][]interface{}{{"likes", ">", 5}}},
+		}, 1},
+		{"mixed with flat clauses", [][]interface{}{
+			{"done", "=", true},
+			{"OR", [][]interface{}{{"likes", "=", 2}, {"likes", "=", 30}}},
+		}, 1},
+		{"multiple groups", [][]interface{}{
+			{"or", [][]interface{}{{"likes", "=", 2}, {"likes", "=", 10}}},
+			{"or", [][]interface{}{{"likes", "=", 10}, {"likes", "=", 30}}},
+		}, 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			filters, err := datastore.ParseQuery(tc.clauses)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := datastore.QueryDocuments(adminAuth, confDBName, col, filters, model.ListParams{Page: 1, Size: 10})
+			if err != nil {
+				t.Fatal(err)
+			} else if result.Total != tc.expected {
+				t.Errorf("expected total to be %d got %d", tc.expected, result.Total)
+			}
+		})
+	}
+}
+
+func TestQueryConformance(t *testing.T) {
+	dbtest.QueryConformance(t, datastore, adminAuth, confDBName)
+}
+
+func TestCursorPagination(t *testing.T) {
+	dbtest.CursorPagination(t, datastore, adminAuth, confDBName)
+}
+
+func TestProjectionAndSort(t *testing.T) {
+	dbtest.ProjectionAndSort(t, datastore, adminAuth, confDBName)
+}
+
+func TestTransaction(t *testing.T) {
+	dbtest.Transaction(t, datastore, adminAuth, confDBName)
+}
+
+func TestVersioning(t *testing.T) {
+	dbtest.Versioning(t, datastore, adminAuth, confDBName)
+}
+
+func TestSchemas(t *testing.T) {
+	dbtest.Schemas(t, datastore, adminAuth, confDBName)
+}
+
+func TestExpand(t *testing.T) {
+	dbtest.Expand(t, datastore, adminAuth, confDBName)
+}
+
+func TestRules(t *testing.T) {
+	dbtest.Rules(t, datastore, adminAuth, confDBName)
+}
+
+func TestRoles(t *testing.T) {
+	dbtest.Roles(t, datastore, adminAuth, confDBName)
+}
+
+func TestTeams(t *testing.T) {
+	dbtest.Teams(t, datastore, adminAuth, confDBName)
+}
+
+func TestAPIKeys(t *testing.T) {
+	dbtest.APIKeys(t, datastore, adminAuth, confDBName)
+}
+
+func TestSessions(t *testing.T) {
+	dbtest.Sessions(t, datastore, adminAuth, confDBName)
+}
+
+func TestTwoFactor(t *testing.T) {
+	dbtest.TwoFactor(t, datastore, adminAuth, confDBName)
+}
+
+func TestIdentities(t *testing.T) {
+	dbtest.Identities(t, datastore, adminAuth, confDBName)
+}
+
+func TestEmailVerification(t *testing.T) {
+	dbtest.EmailVerification(t, datastore, adminAuth, confDBName)
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	dbtest.PasswordPolicy(t, datastore, confDBName)
+}
+
+func TestInvitations(t *testing.T) {
+	dbtest.Invitations(t, datastore, adminAuth, confDBName)
+}
+
+func TestChannelRetention(t *testing.T) {
+	dbtest.ChannelRetention(t, datastore, confDBName)
+}
+
+func TestChannelRules(t *testing.T) {
+	dbtest.ChannelRules(t, datastore, confDBName)
+}
@@ -3,12 +3,18 @@ package postgresql
 import (
 	"fmt"
 
+	"github.com/staticbackendhq/core/database"
 	"github.com/staticbackendhq/core/model"
 )
 
-func (pg *PostgreSQL) Count(auth model.Auth, dbName, col string, filters map[string]interface{}) (count int64, err error) {
-	where := secureRead(auth, col)
-	where = applyFilter(where, filters)
+func (pg *PostgreSQL) Count(auth model.Auth, dbName, col string, filters database.Filter) (count int64, err error) {
+	where, filters, err := pg.secure(auth, dbName, col, false, filters)
+	if err != nil {
+		return -1, err
+	}
+
+	where, args := applyFilter(where, filters, 3)
+	args = append([]any{auth.AccountID, auth.UserID}, args...)
 
 	query := fmt.Sprintf(`
     SELECT COUNT(*)
@@ -16,7 +22,7 @@ func (pg *PostgreSQL) Count(auth model.Auth, dbName, col string, filters map[str
     %s;
     `, dbName, model.CleanCollectionName(col), where)
 
-	err = pg.DB.QueryRow(query, auth.AccountID, auth.UserID).Scan(&count)
+	err = pg.conn().QueryRow(query, args...).Scan(&count)
 	if err != nil {
 		return -1, err
 	}
@@ -20,7 +20,7 @@ func (pg *PostgreSQL) AddFormSubmission(dbName, form string, doc map[string]inte
 		VALUES($1, $2, $3)
 	`, dbName)
 
-	if _, err := pg.DB.Exec(qry, form, jsonb, time.Now()); err != nil {
+	if _, err := pg.conn().Exec(qry, form, jsonb, time.Now()); err != nil {
 		return err
 	}
 	return nil
@@ -40,7 +40,7 @@ func (pg *PostgreSQL) ListFormSubmissions(dbName, name string) (results []map[st
 		LIMIT 100;
 	`, dbName, where)
 
-	rows, err := pg.DB.Query(qry, name)
+	rows, err := pg.conn().Query(qry, name)
 	if err != nil {
 		return
 	}
@@ -66,7 +66,7 @@ func (pg *PostgreSQL) GetForms(dbName string) (results []string, err error) {
 		GROUP BY name
 	`, dbName)
 
-	rows, err := pg.DB.Query(qry)
+	rows, err := pg.conn().Query(qry)
 	if err != nil {
 		return
 	}
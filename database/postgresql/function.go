@@ -15,7 +15,7 @@ func (pg *PostgreSQL) AddFunction(dbName string, data model.ExecData) (id string
 		RETURNING id;
 	`, dbName)
 
-	err = pg.DB.QueryRow(
+	err = pg.conn().QueryRow(
 		qry,
 		data.FunctionName,
 		data.TriggerTopic,
@@ -34,7 +34,7 @@ func (pg *PostgreSQL) UpdateFunction(dbName, id, code, trigger string) error {
 		WHERE id = $1 AND trigger_topic = $2
 	`, dbName)
 
-	if _, err := pg.DB.Exec(qry, id, trigger, code); err != nil {
+	if _, err := pg.conn().Exec(qry, id, trigger, code); err != nil {
 		return err
 	}
 	return nil
@@ -47,7 +47,7 @@ func (pg *PostgreSQL) GetFunctionForExecution(dbName, name string) (result model
 		WHERE function_name = $1
 	`, dbName)
 
-	row := pg.DB.QueryRow(qry, name)
+	row := pg.conn().QueryRow(qry, name)
 
 	err = scanExecData(row, &result)
 	return
@@ -60,7 +60,7 @@ func (pg *PostgreSQL) GetFunctionByID(dbName, id string) (result model.ExecData,
 		WHERE id = $1
 	`, dbName)
 
-	row := pg.DB.QueryRow(qry, id)
+	row := pg.conn().QueryRow(qry, id)
 
 	err = scanExecData(row, &result)
 	if err != nil {
@@ -75,7 +75,7 @@ func (pg *PostgreSQL) GetFunctionByID(dbName, id string) (result model.ExecData,
 		LIMIT 50;
 	`, dbName)
 
-	rows, err := pg.DB.Query(qry, id)
+	rows, err := pg.conn().Query(qry, id)
 	if err != nil {
 		return
 	}
@@ -101,7 +101,7 @@ func (pg *PostgreSQL) GetFunctionByName(dbName, name string) (result model.ExecD
 		WHERE function_name = $1
 	`, dbName)
 
-	row := pg.DB.QueryRow(qry, name)
+	row := pg.conn().QueryRow(qry, name)
 
 	err = scanExecData(row, &result)
 	if err != nil {
@@ -117,7 +117,7 @@ func (pg *PostgreSQL) GetFunctionByName(dbName, name string) (result model.ExecD
 		LIMIT 50;
 	`, dbName)
 
-	rows, err := pg.DB.Query(qry, result.ID)
+	rows, err := pg.conn().Query(qry, result.ID)
 	if err != nil {
 		return
 	}
@@ -143,7 +143,7 @@ func (pg *PostgreSQL) ListFunctions(dbName string) (results []model.ExecData, er
 		ORDER BY last_updated DESC
 	`, dbName)
 
-	rows, err := pg.DB.Query(qry)
+	rows, err := pg.conn().Query(qry)
 	if err != nil {
 		return
 	}
@@ -170,7 +170,7 @@ func (pg *PostgreSQL) ListFunctionsByTrigger(dbName, trigger string) (results []
 		ORDER BY last_updated DESC
 	`, dbName)
 
-	rows, err := pg.DB.Query(qry, trigger)
+	rows, err := pg.conn().Query(qry, trigger)
 	if err != nil {
 		return
 	}
@@ -195,7 +195,7 @@ func (pg *PostgreSQL) DeleteFunction(dbName, name string) error {
 		WHERE function_name = $1
 	`, dbName)
 
-	if _, err := pg.DB.Exec(qry, name); err != nil {
+	if _, err := pg.conn().Exec(qry, name); err != nil {
 		return err
 	}
 	return nil
@@ -208,7 +208,7 @@ func (pg *PostgreSQL) RanFunction(dbName, id string, rh model.ExecHistory) error
 		WHERE id = $1
 	`, dbName)
 
-	if _, err := pg.DB.Exec(qry, id, time.Now()); err != nil {
+	if _, err := pg.conn().Exec(qry, id, time.Now()); err != nil {
 		return err
 	}
 
@@ -217,7 +217,7 @@ func (pg *PostgreSQL) RanFunction(dbName, id string, rh model.ExecHistory) error
 		VALUES($1, $2, $3, $4, $5, $6)
 	`, dbName)
 
-	_, err := pg.DB.Exec(
+	_, err := pg.conn().Exec(
 		qry,
 		id,
 		rh.Version,
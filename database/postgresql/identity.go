@@ -0,0 +1,102 @@
+package postgresql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+func (pg *PostgreSQL) AddIdentity(dbName string, ident model.Identity) (id string, err error) {
+	qry := fmt.Sprintf(`
+		INSERT INTO %s.sb_identities(account_id, user_id, provider, subject, email, linked)
+		VALUES($1, $2, $3, $4, $5, $6)
+		RETURNING id;
+	`, dbName)
+
+	err = pg.conn().QueryRow(
+		qry,
+		ident.AccountID,
+		ident.UserID,
+		ident.Provider,
+		ident.Subject,
+		ident.Email,
+		time.Now(),
+	).Scan(&id)
+	return
+}
+
+func (pg *PostgreSQL) FindIdentity(dbName, provider, subject string) (ident model.Identity, err error) {
+	qry := fmt.Sprintf(`
+		SELECT * 
+		FROM %s.sb_identities
+		WHERE provider = $1 AND subject = $2
+	`, dbName)
+
+	err = scanIdentity(pg.conn().QueryRow(qry, provider, subject), &ident)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Identity{}, nil
+	}
+	return
+}
+
+func (pg *PostgreSQL) ListIdentities(dbName, userID string) (results []model.Identity, err error) {
+	qry := fmt.Sprintf(`
+		SELECT * 
+		FROM %s.sb_identities
+		WHERE user_id = $1
+		ORDER BY linked
+	`, dbName)
+
+	rows, err := pg.conn().Query(qry, userID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ident model.Identity
+		if err = scanIdentity(rows, &ident); err != nil {
+			return
+		}
+
+		results = append(results, ident)
+	}
+
+	err = rows.Err()
+	return
+}
+
+func (pg *PostgreSQL) DeleteIdentity(dbName, userID, id string) error {
+	qry := fmt.Sprintf(`
+		DELETE FROM %s.sb_identities
+		WHERE user_id = $1 AND id = $2
+	`, dbName)
+
+	_, err := pg.conn().Exec(qry, userID, id)
+	return err
+}
+
+func (pg *PostgreSQL) DeleteIdentities(dbName, userID string) error {
+	qry := fmt.Sprintf(`
+		DELETE FROM %s.sb_identities
+		WHERE user_id = $1
+	`, dbName)
+
+	_, err := pg.conn().Exec(qry, userID)
+	return err
+}
+
+func scanIdentity(rows Scanner, ident *model.Identity) error {
+	return rows.Scan(
+		&ident.ID,
+		&ident.AccountID,
+		&ident.UserID,
+		&ident.Provider,
+		&ident.Subject,
+		&ident.Email,
+		&ident.Linked,
+	)
+}
@@ -0,0 +1,89 @@
+package postgresql
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+func (pg *PostgreSQL) CreateInvitation(dbName string, inv model.Invitation) error {
+	qry := fmt.Sprintf(`
+		INSERT INTO %s.sb_invitations(id, account_id, email, role, token_hash, invited_by, created, expires)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+	`, dbName)
+
+	_, err := pg.conn().Exec(
+		qry,
+		inv.ID,
+		inv.AccountID,
+		inv.Email,
+		inv.Role,
+		inv.TokenHash,
+		inv.InvitedBy,
+		time.Now(),
+		inv.Expires,
+	)
+	return err
+}
+
+func (pg *PostgreSQL) GetInvitation(dbName, id string) (inv model.Invitation, err error) {
+	qry := fmt.Sprintf(`
+		SELECT * 
+		FROM %s.sb_invitations
+		WHERE id = $1
+	`, dbName)
+
+	err = scanInvitation(pg.conn().QueryRow(qry, id), &inv)
+	return
+}
+
+func (pg *PostgreSQL) ListInvitations(dbName, accountID string) (results []model.Invitation, err error) {
+	qry := fmt.Sprintf(`
+		SELECT * 
+		FROM %s.sb_invitations
+		WHERE account_id = $1
+		ORDER BY created DESC
+	`, dbName)
+
+	rows, err := pg.conn().Query(qry, accountID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var inv model.Invitation
+		if err = scanInvitation(rows, &inv); err != nil {
+			return
+		}
+
+		results = append(results, inv)
+	}
+
+	err = rows.Err()
+	return
+}
+
+func (pg *PostgreSQL) DeleteInvitation(dbName, accountID, id string) error {
+	qry := fmt.Sprintf(`
+		DELETE FROM %s.sb_invitations
+		WHERE account_id = $1 AND id = $2
+	`, dbName)
+
+	_, err := pg.conn().Exec(qry, accountID, id)
+	return err
+}
+
+func scanInvitation(rows Scanner, inv *model.Invitation) error {
+	return rows.Scan(
+		&inv.ID,
+		&inv.AccountID,
+		&inv.Email,
+		&inv.Role,
+		&inv.TokenHash,
+		&inv.InvitedBy,
+		&inv.Created,
+		&inv.Expires,
+	)
+}
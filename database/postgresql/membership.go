@@ -14,7 +14,7 @@ func (pg *PostgreSQL) CreateAccount(dbName, email string) (id string, err error)
 		RETURNING id;
 	`, dbName)
 
-	err = pg.DB.QueryRow(qry, email, time.Now()).Scan(&id)
+	err = pg.conn().QueryRow(qry, email, time.Now()).Scan(&id)
 	return
 }
 
@@ -27,7 +27,7 @@ func (pg *PostgreSQL) CreateUser(dbName string, tok model.User) (id string, err
 		RETURNING id;
 	`, dbName)
 
-	err = pg.DB.QueryRow(
+	err = pg.conn().QueryRow(
 		qry,
 		tok.AccountID,
 		tok.Email,
@@ -48,7 +48,7 @@ func (pg *PostgreSQL) UserEmailExists(dbName, email string) (exists bool, err er
 	`, dbName)
 
 	var count int
-	err = pg.DB.QueryRow(qry, email).Scan(&count)
+	err = pg.conn().QueryRow(qry, email).Scan(&count)
 
 	exists = count > 0
 	return
@@ -60,7 +60,7 @@ func (pg *PostgreSQL) SetUserRole(dbName, email string, role int) error {
 		WHERE email = $1;
 	`, dbName)
 
-	if _, err := pg.DB.Exec(qry, email, role); err != nil {
+	if _, err := pg.conn().Exec(qry, email, role); err != nil {
 		return err
 	}
 	return nil
@@ -72,12 +72,22 @@ func (pg *PostgreSQL) UserSetPassword(dbName, tokenID, password string) error {
 		WHERE id = $1;
 	`, dbName)
 
-	if _, err := pg.DB.Exec(qry, tokenID, password); err != nil {
+	if _, err := pg.conn().Exec(qry, tokenID, password); err != nil {
 		return err
 	}
 	return nil
 }
 
+func (pg *PostgreSQL) SetEmailVerified(dbName, userID string) error {
+	qry := fmt.Sprintf(`
+		UPDATE %s.sb_tokens SET email_verified = TRUE
+		WHERE id = $1;
+	`, dbName)
+
+	_, err := pg.conn().Exec(qry, userID)
+	return err
+}
+
 func (pg *PostgreSQL) GetFirstUserFromAccountID(dbName, accountID string) (tok model.User, err error) {
 	qry := fmt.Sprintf(`
 		SELECT * 
@@ -87,7 +97,7 @@ func (pg *PostgreSQL) GetFirstUserFromAccountID(dbName, accountID string) (tok m
 		LIMIT 1
 	`, dbName)
 
-	row := pg.DB.QueryRow(qry, accountID)
+	row := pg.conn().QueryRow(qry, accountID)
 
 	err = scanToken(row, &tok)
 	return
@@ -100,7 +110,7 @@ func (pg *PostgreSQL) SetPasswordResetCode(dbName, userID, code string) error {
 	WHERE id = $1
 `, dbName)
 
-	_, err := pg.DB.Exec(qry, userID, code)
+	_, err := pg.conn().Exec(qry, userID, code)
 	if err != nil {
 		return err
 	}
@@ -114,7 +124,7 @@ func (pg *PostgreSQL) ResetPassword(dbName, email, code, password string) error
 		WHERE email = $1 AND reset_code = $2
 	`, dbName)
 
-	if _, err := pg.DB.Exec(qry, email, code, password); err != nil {
+	if _, err := pg.conn().Exec(qry, email, code, password); err != nil {
 		return err
 	}
 	return nil
@@ -126,7 +136,7 @@ func (pg *PostgreSQL) RemoveUser(auth model.Auth, dbName, userID string) error {
 	WHERE account_id = $1 AND id = $2;
 	`, dbName)
 
-	if _, err := pg.DB.Exec(qry, auth.AccountID, userID); err != nil {
+	if _, err := pg.conn().Exec(qry, auth.AccountID, userID); err != nil {
 		return err
 	}
 	return nil
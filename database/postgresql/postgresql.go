@@ -17,6 +17,9 @@ type PostgreSQL struct {
 	DB              *sql.DB
 	PublishDocument cache.PublishDocumentEvent
 	log             *logger.Logger
+
+	// tx is set for the persister passed to WithTransaction
+	tx *sql.Tx
 }
 
 //go:embed sql
@@ -50,7 +53,7 @@ func (pg *PostgreSQL) CreateIndex(dbName, col, field string) error {
 	qry = strings.Replace(qry, "{field}", field, -1)
 	qry = strings.Replace(qry, "{schema}", dbName, -1)
 
-	if _, err := pg.DB.Exec(qry); err != nil {
+	if _, err := pg.conn().Exec(qry); err != nil {
 		return err
 	}
 	return nil
@@ -1,61 +1,202 @@
 package postgresql
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/staticbackendhq/core/database"
 	"github.com/staticbackendhq/core/internal"
 	"github.com/staticbackendhq/core/model"
 )
 
-func (mg *PostgreSQL) ParseQuery(clauses [][]interface{}) (map[string]interface{}, error) {
-	filter := make(map[string]interface{})
+func (pg *PostgreSQL) ParseQuery(clauses [][]interface{}) (database.Filter, error) {
+	return database.ParseQuery(clauses)
+}
 
-	for i, clause := range clauses {
-		if len(clause) != 3 {
-			return filter, fmt.Errorf("the %d query clause did not contains the required 3 parameters (field, operator, value)", i+1)
-		}
+// systemColumns are the document fields stored in their own column
+var systemColumns = map[string]string{
+	"accountId": "account_id",
+	"ownerId":   "owner_id",
+}
 
-		field, ok := clause[0].(string)
-		if !ok {
-			return filter, fmt.Errorf("the %d query clause's field parameter must be a string: %v", i+1, clause[0])
-		}
+// applyFilter appends the filter clauses to the where statement. Values are
+// bound as placeholders starting at $n and returned as args in order.
+func applyFilter(where string, filter database.Filter, n int) (string, []any) {
+	var args []any
+	for _, clause := range filter {
+		expr, vals := compileClause(clause, n+len(args))
+		where += " AND " + expr
+		args = append(args, vals...)
+	}
+	return where, args
+}
 
-		origField := field
+// compileGroup returns the parenthesized SQL expression of a nested group and
+// the values to bind starting at the $n placeholder.
+func compileGroup(clause database.Clause, n int) (string, []any) {
+	var exprs []string
+	var args []any
+	for _, c := range clause.Clauses {
+		expr, vals := compileClause(c, n+len(args))
+		exprs = append(exprs, expr)
+		args = append(args, vals...)
+	}
 
-		field = fmt.Sprintf(`data->>'%s'`, field)
+	switch clause.Group {
+	case database.GroupOr:
+		return "(" + strings.Join(exprs, " OR ") + ")", args
+	case database.GroupNot:
+		// a comparison on a missing field is NULL and so would its negation,
+		// the group is false instead so NOT matches the document
+		return "NOT COALESCE((" + strings.Join(exprs, " AND ") + "), false)", args
+	default:
+		return "(" + strings.Join(exprs, " AND ") + ")", args
+	}
+}
 
-		op, ok := clause[1].(string)
-		if !ok {
-			return filter, fmt.Errorf("the %d query clause's operator must be a string: %v", i+1, clause[1])
-		}
+// compileClause returns the SQL expression for a clause and the values to
+// bind starting at the $n placeholder.
+func compileClause(clause database.Clause, n int) (string, []any) {
+	if clause.IsGroup() {
+		return compileGroup(clause, n)
+	}
 
-		switch op {
-		case "=", "==":
-			filter[field+" = "] = clause[2]
-		case "!=", "<>":
-			filter[field+" != "] = clause[2]
-		case ">", "<", ">=", "<=":
-			filter[field+" "+op+" "] = clause[2]
-		case "in", "!in":
-			field = fmt.Sprintf("data->'%s' ? ", origField)
-			if strings.HasPrefix(op, "!") {
-				field = " NOT " + field
-			}
-			filter[field] = clause[2]
+	text := jsonText(clause.Field)
+	typeOf := fmt.Sprintf("jsonb_typeof(%s)", jsonField(clause.Field))
+	field := jsonField(clause.Field)
+	exists := jsonExists(clause.Field)
+
+	// the account and owner are stored in columns, i.e. for the rules
+	// comparing them with the caller's IDs
+	if column, ok := systemColumns[clause.Field]; ok {
+		text = column + "::text"
+		typeOf = "'string'"
+		field = fmt.Sprintf("to_jsonb(%s::text)", column)
+		exists = column + " IS NOT NULL"
+	}
+
+	switch clause.Op {
+	case database.OpExists:
+		return exists, nil
+	case database.OpNotExists:
+		return fmt.Sprintf("NOT (%s)", exists), nil
+	case database.OpContains:
+		return fmt.Sprintf("(%s = 'string' AND strpos(%s, $%d) > 0)", typeOf, text, n), []any{clause.Value}
+	case database.OpStartsWith:
+		return fmt.Sprintf("(%s = 'string' AND strpos(%s, $%d) = 1)", typeOf, text, n), []any{clause.Value}
+	case database.OpEqualFold:
+		return fmt.Sprintf("(%s = 'string' AND lower(%s) = lower($%d))", typeOf, text, n), []any{clause.Value}
+	case database.OpRegex:
+		return fmt.Sprintf("(%s = 'string' AND %s ~ $%d)", typeOf, text, n), []any{clause.Value}
+	case database.OpBetween:
+		bounds := clause.Value.([]any)
+		args := []any{bounds[0], bounds[1]}
+
+		switch clause.Kind {
+		case database.KindNumber:
+			expr := fmt.Sprintf(
+				"(CASE WHEN %s = 'number' THEN (%s)::numeric END) BETWEEN $%d AND $%d",
+				typeOf, text, n, n+1,
+			)
+			return expr, args
+		case database.KindTime:
+			expr := fmt.Sprintf(
+				`(CASE WHEN %s ~ '^\d{4}-\d{2}-\d{2}' THEN (%s)::timestamptz END) BETWEEN $%d AND $%d`,
+				text, text, n, n+1,
+			)
+			return expr, args
 		default:
-			return filter, fmt.Errorf("the %d query clause's operator: %s is not supported at the moment", i+1, op)
+			return fmt.Sprintf("%s BETWEEN $%d AND $%d", text, n, n+1), args
+		}
+	case database.OpContainsAny:
+		// jsonb_array_elements fails on non-array values, they're replaced
+		// by an empty array
+		expr := fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM jsonb_array_elements(CASE WHEN %s = 'array' THEN %s ELSE '[]'::jsonb END) AS e WHERE $%d::jsonb @> jsonb_build_array(e))",
+			typeOf, field, n,
+		)
+		return expr, []any{toJSON(clause.Value)}
+	case database.OpContainsAll:
+		expr := fmt.Sprintf("COALESCE(%s = 'array' AND %s @> $%d::jsonb, false)", typeOf, field, n)
+		return expr, []any{toJSON(clause.Value)}
+	case database.OpSize:
+		expr := fmt.Sprintf("(CASE WHEN %s = 'array' THEN jsonb_array_length(%s) END) = $%d", typeOf, field, n)
+		return expr, []any{clause.Value}
+	}
+
+	switch clause.Kind {
+	case database.KindNull:
+		if clause.Op == database.OpNotEqual {
+			return text + " IS NOT NULL", nil
+		}
+		return text + " IS NULL", nil
+	case database.KindList:
+		// the values are compared as jsonb so numbers and booleans only
+		// match their own type, an array matches if one of its items does
+		expr := fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM jsonb_array_elements(CASE WHEN %s = 'array' THEN %s ELSE jsonb_build_array(%s) END) AS e WHERE $%d::jsonb @> jsonb_build_array(e))",
+			typeOf, field, field, n,
+		)
+		if clause.Op == database.OpNotIn {
+			expr = fmt.Sprintf("(%s IS NOT NULL AND NOT %s)", text, expr)
 		}
+		return expr, []any{toJSON(clause.Value)}
+	case database.KindNumber:
+		expr := fmt.Sprintf("(CASE WHEN %s = 'number' THEN (%s)::numeric END) %s $%d", typeOf, text, clause.Op, n)
+		return expr, []any{clause.Value}
+	case database.KindBool:
+		expr := fmt.Sprintf("(CASE WHEN %s = 'boolean' THEN (%s)::boolean END) %s $%d", typeOf, text, clause.Op, n)
+		return expr, []any{clause.Value}
+	case database.KindTime:
+		expr := fmt.Sprintf(
+			`(CASE WHEN %s ~ '^\d{4}-\d{2}-\d{2}' THEN (%s)::timestamptz END) %s $%d`,
+			text, text, clause.Op, n,
+		)
+		return expr, []any{clause.Value}
+	default:
+		expr := fmt.Sprintf("(CASE WHEN %s = 'string' THEN %s END) %s $%d", typeOf, text, clause.Op, n)
+		return expr, []any{clause.Value}
 	}
+}
+
+// jsonField returns the jsonb value of a data field, a dotted field is the
+// path of a nested value
+func jsonField(field string) string {
+	return "data->'" + strings.Join(strings.Split(field, "."), "'->'") + "'"
+}
 
-	return filter, nil
+// jsonText returns the text value of a data field
+func jsonText(field string) string {
+	parts := strings.Split(field, ".")
+	last := len(parts) - 1
+	if last == 0 {
+		return fmt.Sprintf("data->>'%s'", field)
+	}
+	return fmt.Sprintf("%s->>'%s'", jsonField(strings.Join(parts[:last], ".")), parts[last])
 }
 
-func applyFilter(where string, filters map[string]interface{}) string {
-	for field, val := range filters {
-		where += fmt.Sprintf(" AND %s '%v'", field, val)
+// jsonExists returns the condition of a data field being set. The parent of
+// a nested field must be an object, ? also matches the items of an array.
+func jsonExists(field string) string {
+	i := strings.LastIndex(field, ".")
+	if i < 0 {
+		return fmt.Sprintf("data ? '%s'", field)
 	}
-	return where
+
+	parent := jsonField(field[:i])
+	return fmt.Sprintf("COALESCE(jsonb_typeof(%s) = 'object' AND %s ? '%s', false)", parent, parent, field[i+1:])
+}
+
+// toJSON returns the JSON representation of a list value to bind as jsonb
+func toJSON(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "[]"
+	}
+	return string(b)
 }
 
 func secureRead(auth model.Auth, col string) string {
@@ -94,18 +235,165 @@ func secureWrite(auth model.Auth, col string) string {
 	}
 }
 
-func setPaging(params model.ListParams) string {
-	if len(params.SortBy) == 0 {
-		params.SortBy = "created"
+// versionExpr is the version of a document, 0 when it was created before
+// versions were added
+var versionExpr = fmt.Sprintf(
+	"(CASE WHEN jsonb_typeof(data->'%s') = 'number' THEN (data->>'%s')::bigint ELSE 0 END)",
+	database.FieldVersion, database.FieldVersion,
+)
+
+// nextVersion is the jsonb object incrementing the document's version
+var nextVersion = fmt.Sprintf("jsonb_build_object('%s', %s + 1)", database.FieldVersion, versionExpr)
+
+// sortExpr returns the SQL expression to sort on. Data fields are compared
+// as jsonb with missing values sorted first.
+func sortExpr(sortBy string) string {
+	switch sortBy {
+	case database.SortCreated, database.SortID:
+		return sortBy
+	default:
+		return fmt.Sprintf("COALESCE(%s, 'null'::jsonb)", jsonField(sortBy))
+	}
+}
+
+// setPaging returns the ORDER BY and LIMIT clauses. When the params contain a
+// cursor it also returns the keyset condition to append to the where clause
+// with its values bound starting at the $n placeholder.
+func setPaging(params model.ListParams, n int) (cond string, paging string, args []any, err error) {
+	keys, err := database.SortKeys(params)
+	if err != nil {
+		return
+	}
+
+	var orderBy []string
+	for _, key := range keys {
+		orderBy = append(orderBy, fmt.Sprintf("%s %s", sortExpr(key.Field), direction(key.Descending)))
+	}
+
+	last := keys[len(keys)-1]
+	if last.Field != database.SortID {
+		orderBy = append(orderBy, "id "+direction(last.Descending))
+	}
+
+	if len(params.Cursor) == 0 {
+		offset := (params.Page - 1) * params.Size
+		paging = fmt.Sprintf("ORDER BY %s\nLIMIT %d OFFSET %d", strings.Join(orderBy, ", "), params.Size, offset)
+		return
+	}
+
+	c, err := database.DecodeCursor(params)
+	if err != nil {
+		return
+	}
+
+	// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ... OR (k1 = v1 AND ... AND id > c.ID)
+	var or, equals []string
+	for i, key := range keys {
+		expr := sortExpr(key.Field)
+		ph := fmt.Sprintf("$%d", n+len(args))
+
+		switch key.Field {
+		case database.SortID:
+			ph += "::uuid"
+			args = append(args, c.ID)
+		case database.SortCreated:
+			created, ok := c.Values[i].(string)
+			if !ok {
+				return "", "", nil, errors.New("invalid cursor")
+			}
+
+			t, err := time.Parse(time.RFC3339Nano, created)
+			if err != nil {
+				return "", "", nil, errors.New("invalid cursor")
+			}
+
+			ph += "::timestamp"
+			args = append(args, t)
+		default:
+			ph += "::jsonb"
+			args = append(args, toJSON(c.Values[i]))
+		}
+
+		after := fmt.Sprintf("%s %s %s", expr, comparison(key.Descending), ph)
+		or = append(or, "("+strings.Join(append(equals[:len(equals):len(equals)], after), " AND ")+")")
+		equals = append(equals, fmt.Sprintf("%s = %s", expr, ph))
+	}
+
+	if last.Field != database.SortID {
+		after := fmt.Sprintf("id %s $%d::uuid", comparison(last.Descending), n+len(args))
+		or = append(or, "("+strings.Join(append(equals, after), " AND ")+")")
+		args = append(args, c.ID)
+	}
+
+	cond = " AND (" + strings.Join(or, " OR ") + ")"
+	paging = fmt.Sprintf("ORDER BY %s\nLIMIT %d", strings.Join(orderBy, ", "), params.Size)
+	return
+}
+
+func direction(desc bool) string {
+	if desc {
+		return "DESC"
 	}
+	return "ASC"
+}
 
-	direction := "ASC"
-	if params.SortDescending {
-		direction = "DESC"
+func comparison(desc bool) string {
+	if desc {
+		return "<"
 	}
+	return ">"
+}
 
-	orderBy := fmt.Sprintf("ORDER BY %s %s", params.SortBy, direction)
+// cursorValues returns the document's values for the sort keys
+func cursorValues(params model.ListParams, doc Document) []any {
+	keys, _ := database.SortKeys(params)
 
-	offset := (params.Page - 1) * params.Size
-	return fmt.Sprintf("%s\nLIMIT %d OFFSET %d", orderBy, params.Size, offset)
+	var values []any
+	for _, key := range keys {
+		switch key.Field {
+		case database.SortCreated:
+			values = append(values, doc.Created)
+		case database.SortID:
+			values = append(values, nil)
+		default:
+			v, _ := database.Lookup(doc.Data, key.Field)
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// dataFields returns the sort fields stored in the document's data
+func dataFields(params model.ListParams) (fields []string) {
+	keys, _ := database.SortKeys(params)
+	for _, key := range keys {
+		if key.Field != database.SortCreated && key.Field != database.SortID {
+			fields = append(fields, key.Field)
+		}
+	}
+	return
+}
+
+// selectColumns returns the document columns with the data projected
+func selectColumns(p database.Projection) string {
+	data := "data"
+	if len(p.Include) > 0 {
+		data = fmt.Sprintf(
+			"COALESCE((SELECT jsonb_object_agg(key, value) FROM jsonb_each(data) WHERE key IN (%s)), '{}'::jsonb)",
+			quoteFields(p.Include),
+		)
+	} else if len(p.Exclude) > 0 {
+		data = fmt.Sprintf("data - ARRAY[%s]", quoteFields(p.Exclude))
+	}
+	return "id, account_id, owner_id, " + data + ", created"
+}
+
+// quoteFields returns the comma separated fields as SQL strings, the
+// projection fields are validated by ParseFields
+func quoteFields(fields []string) string {
+	var quoted []string
+	for _, field := range fields {
+		quoted = append(quoted, "'"+field+"'")
+	}
+	return strings.Join(quoted, ", ")
 }
@@ -0,0 +1,74 @@
+package postgresql
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+func (pg *PostgreSQL) ListRoles(dbName string) (results []model.Role, err error) {
+	qry := fmt.Sprintf(`
+		SELECT id, name, level, permissions, created
+		FROM %s.sb_roles
+		ORDER BY level
+	`, dbName)
+
+	rows, err := pg.conn().Query(qry)
+	if err != nil {
+		if !isTableExists(err) {
+			return nil, nil
+		}
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r model.Role
+		if err = scanRole(rows, &r); err != nil {
+			return
+		}
+
+		results = append(results, r)
+	}
+
+	err = rows.Err()
+	return
+}
+
+func (pg *PostgreSQL) SaveRole(dbName string, role model.Role) error {
+	b, err := json.Marshal(role.Permissions)
+	if err != nil {
+		return err
+	}
+
+	qry := fmt.Sprintf(`
+		INSERT INTO %s.sb_roles(name, level, permissions, created)
+		VALUES($1, $2, $3, $4)
+		ON CONFLICT (name) DO UPDATE SET
+			level = EXCLUDED.level,
+			permissions = EXCLUDED.permissions
+	`, dbName)
+
+	_, err = pg.conn().Exec(qry, role.Name, role.Level, b, time.Now())
+	return err
+}
+
+func (pg *PostgreSQL) DeleteRole(dbName, name string) error {
+	qry := fmt.Sprintf(`
+		DELETE FROM %s.sb_roles
+		WHERE name = $1
+	`, dbName)
+
+	_, err := pg.conn().Exec(qry, name)
+	return err
+}
+
+func scanRole(rows Scanner, r *model.Role) error {
+	var b []byte
+	if err := rows.Scan(&r.ID, &r.Name, &r.Level, &b, &r.Created); err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &r.Permissions)
+}
@@ -0,0 +1,141 @@
+package postgresql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+func (pg *PostgreSQL) ListRules(dbName string) (results []model.CollectionRules, err error) {
+	qry := fmt.Sprintf(`
+		SELECT id, col, rules, updated
+		FROM %s.sb_rules
+		ORDER BY col
+	`, dbName)
+
+	rows, err := pg.conn().Query(qry)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r model.CollectionRules
+		if err = scanRules(rows, &r); err != nil {
+			return
+		}
+
+		results = append(results, r)
+	}
+
+	err = rows.Err()
+	return
+}
+
+func (pg *PostgreSQL) GetRules(dbName, col string) (rules model.CollectionRules, ok bool, err error) {
+	qry := fmt.Sprintf(`
+		SELECT id, col, rules, updated
+		FROM %s.sb_rules
+		WHERE col = $1
+	`, dbName)
+
+	err = scanRules(pg.conn().QueryRow(qry, col), &rules)
+	if errors.Is(err, sql.ErrNoRows) || (err != nil && !isTableExists(err)) {
+		return rules, false, nil
+	} else if err != nil {
+		return
+	}
+	return rules, true, nil
+}
+
+func (pg *PostgreSQL) SaveRules(dbName string, rules model.CollectionRules) error {
+	b, err := json.Marshal(rules.Rules)
+	if err != nil {
+		return err
+	}
+
+	qry := fmt.Sprintf(`
+		INSERT INTO %s.sb_rules(col, rules, updated)
+		VALUES($1, $2, $3)
+		ON CONFLICT (col) DO UPDATE SET
+			rules = EXCLUDED.rules,
+			updated = EXCLUDED.updated
+	`, dbName)
+
+	_, err = pg.conn().Exec(qry, rules.Collection, b, time.Now())
+	return err
+}
+
+func (pg *PostgreSQL) DeleteRules(dbName, col string) error {
+	qry := fmt.Sprintf(`
+		DELETE FROM %s.sb_rules
+		WHERE col = $1
+	`, dbName)
+
+	_, err := pg.conn().Exec(qry, col)
+	return err
+}
+
+// secure returns the where statement and the filters securing the access to
+// a collection, its rule replaces the permissions from its name
+func (pg *PostgreSQL) secure(auth model.Auth, dbName, col string, write bool, filters database.Filter) (string, database.Filter, error) {
+	filters, ruled, err := database.SecureFilter(pg, auth, dbName, col, write, filters)
+	if err != nil {
+		return "", nil, err
+	} else if ruled {
+		return "WHERE $1=$1 AND $2=$2 ", filters, nil
+	} else if write {
+		return secureWrite(auth, col), filters, nil
+	}
+	return secureRead(auth, col), filters, nil
+}
+
+func scanRules(rows Scanner, r *model.CollectionRules) error {
+	var b []byte
+	if err := rows.Scan(&r.ID, &r.Collection, &b, &r.Updated); err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &r.Rules)
+}
+
+// canUpdate returns database.ErrPermissionDenied when one of the documents
+// would not satisfy the collection's write rule once updated
+func (pg *PostgreSQL) canUpdate(auth model.Auth, dbName, col string, ids []string, update map[string]any) error {
+	rule, ruled, err := database.SecureFilter(pg, auth, dbName, col, true, nil)
+	if err != nil || !ruled {
+		return err
+	}
+
+	qry := fmt.Sprintf(`
+		SELECT id, account_id, owner_id, data, created
+		FROM %s.%s
+		WHERE id = ANY($1::uuid[])
+	`, dbName, model.CleanCollectionName(col))
+
+	rows, err := pg.conn().Query(qry, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var doc Document
+		if err := scanDocument(rows, &doc); err != nil {
+			return err
+		}
+
+		doc.Data[FieldAccountID] = doc.AccountID
+		doc.Data["ownerId"] = doc.OwnerID
+
+		if err := database.CanUpdate(rule, doc.Data, update); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
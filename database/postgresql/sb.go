@@ -12,7 +12,7 @@ func (pg *PostgreSQL) CreateTenant(customer model.Tenant) (c model.Tenant, err e
 	var id string
 	c = customer
 
-	err = pg.DB.QueryRow(`
+	err = pg.conn().QueryRow(`
 	INSERT INTO sb.customers(email, stripe_id, sub_id, plan, is_active, created)
 	VALUES($1, $2, $3, $4, $5, $6)
 	RETURNING id;
@@ -33,13 +33,13 @@ func (pg *PostgreSQL) CreateTenant(customer model.Tenant) (c model.Tenant, err e
 func (pg *PostgreSQL) CreateDatabase(base model.DatabaseConfig) (b model.DatabaseConfig, err error) {
 	b = base
 
-	_, err = pg.DB.Exec(fmt.Sprintf("CREATE SCHEMA %s;", b.Name))
+	_, err = pg.conn().Exec(fmt.Sprintf("CREATE SCHEMA %s;", b.Name))
 	if err != nil {
 		return
 	}
 
 	var id string
-	err = pg.DB.QueryRow(`
+	err = pg.conn().QueryRow(`
 	INSERT INTO sb.apps(customer_id, name, allowed_domain, is_active, monthly_email_sent, created)
 	VALUES($1, $2, $3, $4, $5, $6)
 	RETURNING id;
@@ -76,7 +76,8 @@ func (pg *PostgreSQL) createSystemTables(schema string) error {
 			password TEXT NOT NULL,
 			role INTEGER NOT NULL,
 			reset_code TEXT NOT NULL,
-			created timestamp NOT NULL			
+			created timestamp NOT NULL,
+			email_verified BOOLEAN NOT NULL DEFAULT FALSE
 		);
 
 		CREATE TABLE IF NOT EXISTS {schema}.sb_forms (
@@ -127,9 +128,103 @@ func (pg *PostgreSQL) createSystemTables(schema string) error {
 			interval TEXT NOT NULL,
 			last_run timestamp NOT NULL
 		);
+
+		CREATE TABLE IF NOT EXISTS {schema}.sb_schemas (
+			id uuid PRIMARY KEY DEFAULT uuid_generate_v4 (),
+			col TEXT UNIQUE NOT NULL,
+			definition JSONB NOT NULL,
+			updated timestamp NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS {schema}.sb_rules (
+			id uuid PRIMARY KEY DEFAULT uuid_generate_v4 (),
+			col TEXT UNIQUE NOT NULL,
+			rules JSONB NOT NULL,
+			updated timestamp NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS {schema}.sb_roles (
+			id uuid PRIMARY KEY DEFAULT uuid_generate_v4 (),
+			name TEXT UNIQUE NOT NULL,
+			level INTEGER UNIQUE NOT NULL,
+			permissions JSONB NOT NULL,
+			created timestamp NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS {schema}.sb_api_keys (
+			id uuid PRIMARY KEY,
+			name TEXT NOT NULL,
+			key_hash TEXT NOT NULL,
+			account_id uuid REFERENCES {schema}.sb_accounts(id) ON DELETE CASCADE,
+			user_id uuid REFERENCES {schema}.sb_tokens(id) ON DELETE CASCADE,
+			email TEXT NOT NULL,
+			scopes JSONB NOT NULL,
+			expires timestamp NULL,
+			last_used timestamp NULL,
+			created timestamp NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS {schema}.sb_sessions (
+			id uuid PRIMARY KEY,
+			account_id uuid REFERENCES {schema}.sb_accounts(id) ON DELETE CASCADE,
+			user_id uuid REFERENCES {schema}.sb_tokens(id) ON DELETE CASCADE,
+			refresh_hash TEXT NOT NULL,
+			created timestamp NOT NULL,
+			refreshed timestamp NOT NULL,
+			expires timestamp NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS sb_sessions_user_id_idx ON {schema}.sb_sessions (user_id);
+
+		CREATE TABLE IF NOT EXISTS {schema}.sb_two_factor (
+			user_id uuid PRIMARY KEY REFERENCES {schema}.sb_tokens(id) ON DELETE CASCADE,
+			secret BYTEA NOT NULL,
+			enabled BOOLEAN NOT NULL,
+			recovery_codes JSONB NOT NULL,
+			last_step BIGINT NOT NULL,
+			created timestamp NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS {schema}.sb_identities (
+			id uuid PRIMARY KEY DEFAULT uuid_generate_v4 (),
+			account_id uuid REFERENCES {schema}.sb_accounts(id) ON DELETE CASCADE,
+			user_id uuid REFERENCES {schema}.sb_tokens(id) ON DELETE CASCADE,
+			provider TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			email TEXT NOT NULL,
+			linked timestamp NOT NULL,
+			UNIQUE (provider, subject)
+		);
+
+		CREATE INDEX IF NOT EXISTS sb_identities_user_id_idx ON {schema}.sb_identities (user_id);
+
+		CREATE TABLE IF NOT EXISTS {schema}.sb_invitations (
+			id uuid PRIMARY KEY,
+			account_id uuid REFERENCES {schema}.sb_accounts(id) ON DELETE CASCADE,
+			email TEXT NOT NULL,
+			role INTEGER NOT NULL,
+			token_hash TEXT NOT NULL,
+			invited_by uuid REFERENCES {schema}.sb_tokens(id) ON DELETE CASCADE,
+			created timestamp NOT NULL,
+			expires timestamp NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS sb_invitations_account_id_idx ON {schema}.sb_invitations (account_id);
+
+		CREATE TABLE IF NOT EXISTS {schema}.sb_user_teams (
+			user_id uuid REFERENCES {schema}.sb_tokens(id) ON DELETE CASCADE,
+			team TEXT NOT NULL,
+			PRIMARY KEY (user_id, team)
+		);
+
+		CREATE TABLE IF NOT EXISTS {schema}.sb_settings (
+			id TEXT PRIMARY KEY,
+			data JSONB NOT NULL,
+			updated timestamp NOT NULL
+		);
 	`, "{schema}", schema, -1)
 
-	if _, err := pg.DB.Exec(qry); err != nil {
+	if _, err := pg.conn().Exec(qry); err != nil {
 		return err
 	}
 
@@ -138,7 +233,7 @@ func (pg *PostgreSQL) createSystemTables(schema string) error {
 
 func (pg *PostgreSQL) EmailExists(email string) (bool, error) {
 	var count int
-	err := pg.DB.QueryRow(`
+	err := pg.conn().QueryRow(`
 		SELECT COUNT(*) FROM sb.customers WHERE email = $1
 	`, email).Scan(&count)
 	if err != nil {
@@ -148,7 +243,7 @@ func (pg *PostgreSQL) EmailExists(email string) (bool, error) {
 }
 
 func (pg *PostgreSQL) FindTenant(tenantID string) (customer model.Tenant, err error) {
-	row := pg.DB.QueryRow(`
+	row := pg.conn().QueryRow(`
 		SELECT * 
 		FROM sb.customers
 		WHERE id = $1
@@ -159,7 +254,7 @@ func (pg *PostgreSQL) FindTenant(tenantID string) (customer model.Tenant, err er
 }
 
 func (pg *PostgreSQL) FindDatabase(baseID string) (base model.DatabaseConfig, err error) {
-	row := pg.DB.QueryRow(`
+	row := pg.conn().QueryRow(`
 		SELECT * 
 		FROM sb.apps 
 		WHERE id = $1
@@ -171,7 +266,7 @@ func (pg *PostgreSQL) FindDatabase(baseID string) (base model.DatabaseConfig, er
 
 func (pg *PostgreSQL) DatabaseExists(name string) (exists bool, err error) {
 	var count int
-	err = pg.DB.QueryRow(`
+	err = pg.conn().QueryRow(`
 		SELECT COUNT(*) 
 		FROM sb.apps 
 		WHERE name = $1
@@ -182,7 +277,7 @@ func (pg *PostgreSQL) DatabaseExists(name string) (exists bool, err error) {
 }
 
 func (pg *PostgreSQL) ListDatabases() (results []model.DatabaseConfig, err error) {
-	rows, err := pg.DB.Query(`
+	rows, err := pg.conn().Query(`
 		SELECT * 
 		FROM sb.apps 
 		WHERE is_active = true
@@ -206,7 +301,7 @@ func (pg *PostgreSQL) ListDatabases() (results []model.DatabaseConfig, err error
 }
 
 func (pg *PostgreSQL) IncrementMonthlyEmailSent(baseID string) error {
-	_, err := pg.DB.Exec(`
+	_, err := pg.conn().Exec(`
 		UPDATE sb.apps SET monthly_email_sent = monthly_email_sent + 1
 		WHERE id = $1;
 	`, baseID)
@@ -215,7 +310,7 @@ func (pg *PostgreSQL) IncrementMonthlyEmailSent(baseID string) error {
 }
 
 func (pg *PostgreSQL) GetTenantByStripeID(stripeID string) (cus model.Tenant, err error) {
-	row := pg.DB.QueryRow(`
+	row := pg.conn().QueryRow(`
 		SELECT * 
 		FROM sb.customers 
 		WHERE stripe_id = $1
@@ -243,7 +338,7 @@ func (pg *PostgreSQL) ActivateTenant(tenantID string, active bool) error {
 }
 
 func (pg *PostgreSQL) ChangeTenantPlan(tenantID string, plan int) error {
-	if _, err := pg.DB.Exec(`UPDATE sb.customers SET plan = $2 WHERE id = $1`, tenantID, plan); err != nil {
+	if _, err := pg.conn().Exec(`UPDATE sb.customers SET plan = $2 WHERE id = $1`, tenantID, plan); err != nil {
 		return err
 	}
 	return nil
@@ -255,7 +350,7 @@ func (pg *PostgreSQL) EnableExternalLogin(tenantID string, config map[string]mod
 		return err
 	}
 
-	if _, err := pg.DB.Exec(`UPDATE sb.customers SET external_logins = $2 WHERE id = $1`, tenantID, b); err != nil {
+	if _, err := pg.conn().Exec(`UPDATE sb.customers SET external_logins = $2 WHERE id = $1`, tenantID, b); err != nil {
 		return err
 	}
 	return nil
@@ -263,7 +358,7 @@ func (pg *PostgreSQL) EnableExternalLogin(tenantID string, config map[string]mod
 
 func (pg *PostgreSQL) NewID() string {
 	var id string
-	if err := pg.DB.QueryRow(`SELECT uuid_generate_v4 ()`).Scan(&id); err != nil {
+	if err := pg.conn().QueryRow(`SELECT uuid_generate_v4 ()`).Scan(&id); err != nil {
 		pg.log.Error().Err(err).Msg("error in postgresql.NewID")
 		return ""
 	}
@@ -271,12 +366,12 @@ func (pg *PostgreSQL) NewID() string {
 }
 
 func (pg *PostgreSQL) DeleteTenant(dbName, email string) error {
-	_, err := pg.DB.Exec(fmt.Sprintf(`DROP SCHEMA IF EXISTS %s CASCADE;`, dbName))
+	_, err := pg.conn().Exec(fmt.Sprintf(`DROP SCHEMA IF EXISTS %s CASCADE;`, dbName))
 	if err != nil {
 		return err
 	}
 
-	_, err = pg.DB.Exec(`
+	_, err = pg.conn().Exec(`
 		DELETE FROM sb.customers WHERE email = $1;
 	`, email)
 
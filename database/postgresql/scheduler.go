@@ -30,7 +30,7 @@ func (pg *PostgreSQL) ListTasksByBase(dbName string) (results []model.Task, err
 		FROM %s.sb_tasks 
 	`, dbName)
 
-	rows, err := pg.DB.Query(qry)
+	rows, err := pg.conn().Query(qry)
 	if err != nil {
 		return
 	}
@@ -57,7 +57,7 @@ func (pg *PostgreSQL) AddTask(dbName string, task model.Task) (id string, err er
 
 	id = pg.NewID()
 
-	_, err = pg.DB.Exec(
+	_, err = pg.conn().Exec(
 		qry,
 		id,
 		task.ID,
@@ -76,7 +76,7 @@ func (sl *PostgreSQL) DeleteTask(dbName, id string) error {
 	WHERE id = $1;
 	`, dbName)
 
-	if _, err := sl.DB.Exec(qry, id); err != nil {
+	if _, err := sl.conn().Exec(qry, id); err != nil {
 		return err
 	}
 	return nil
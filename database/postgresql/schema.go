@@ -0,0 +1,100 @@
+package postgresql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+func (pg *PostgreSQL) ListSchemas(dbName string) (results []model.CollectionSchema, err error) {
+	qry := fmt.Sprintf(`
+		SELECT id, col, definition, updated
+		FROM %s.sb_schemas
+		ORDER BY col
+	`, dbName)
+
+	rows, err := pg.conn().Query(qry)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s model.CollectionSchema
+		if err = scanSchema(rows, &s); err != nil {
+			return
+		}
+
+		results = append(results, s)
+	}
+
+	err = rows.Err()
+	return
+}
+
+func (pg *PostgreSQL) GetSchema(dbName, col string) (schema model.CollectionSchema, ok bool, err error) {
+	qry := fmt.Sprintf(`
+		SELECT id, col, definition, updated
+		FROM %s.sb_schemas
+		WHERE col = $1
+	`, dbName)
+
+	err = scanSchema(pg.conn().QueryRow(qry, col), &schema)
+	if errors.Is(err, sql.ErrNoRows) {
+		return schema, false, nil
+	} else if err != nil {
+		return
+	}
+	return schema, true, nil
+}
+
+func (pg *PostgreSQL) SaveSchema(dbName string, schema model.CollectionSchema) error {
+	b, err := json.Marshal(schema.Schema)
+	if err != nil {
+		return err
+	}
+
+	qry := fmt.Sprintf(`
+		INSERT INTO %s.sb_schemas(col, definition, updated)
+		VALUES($1, $2, $3)
+		ON CONFLICT (col) DO UPDATE SET
+			definition = EXCLUDED.definition,
+			updated = EXCLUDED.updated
+	`, dbName)
+
+	_, err = pg.conn().Exec(qry, schema.Collection, b, time.Now())
+	return err
+}
+
+func (pg *PostgreSQL) DeleteSchema(dbName, col string) error {
+	qry := fmt.Sprintf(`
+		DELETE FROM %s.sb_schemas
+		WHERE col = $1
+	`, dbName)
+
+	_, err := pg.conn().Exec(qry, col)
+	return err
+}
+
+// enforceSchema validates the document against the collection's schema
+// when it has one
+func (pg *PostgreSQL) enforceSchema(dbName, col string, doc map[string]any, partial bool) error {
+	schema, ok, err := pg.GetSchema(dbName, col)
+	if err != nil || !ok {
+		return err
+	}
+	return database.ApplySchema(schema.Schema, doc, partial)
+}
+
+func scanSchema(rows Scanner, s *model.CollectionSchema) error {
+	var b []byte
+	if err := rows.Scan(&s.ID, &s.Collection, &b, &s.Updated); err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &s.Schema)
+}
@@ -0,0 +1,114 @@
+package postgresql
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+func (pg *PostgreSQL) CreateSession(dbName string, s model.Session) error {
+	qry := fmt.Sprintf(`
+		INSERT INTO %s.sb_sessions(id, account_id, user_id, refresh_hash, created, refreshed, expires)
+		VALUES($1, $2, $3, $4, $5, $5, $6)
+	`, dbName)
+
+	_, err := pg.conn().Exec(
+		qry,
+		s.ID,
+		s.AccountID,
+		s.UserID,
+		s.RefreshHash,
+		time.Now(),
+		s.Expires,
+	)
+	return err
+}
+
+func (pg *PostgreSQL) GetSession(dbName, id string) (s model.Session, err error) {
+	qry := fmt.Sprintf(`
+		SELECT * 
+		FROM %s.sb_sessions
+		WHERE id = $1
+	`, dbName)
+
+	err = scanSession(pg.conn().QueryRow(qry, id), &s)
+	return
+}
+
+func (pg *PostgreSQL) ListSessions(dbName, userID string) (results []model.Session, err error) {
+	qry := fmt.Sprintf(`
+		SELECT * 
+		FROM %s.sb_sessions
+		WHERE user_id = $1
+		ORDER BY created DESC
+	`, dbName)
+
+	rows, err := pg.conn().Query(qry, userID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s model.Session
+		if err = scanSession(rows, &s); err != nil {
+			return
+		}
+
+		results = append(results, s)
+	}
+
+	err = rows.Err()
+	return
+}
+
+func (pg *PostgreSQL) RotateSession(dbName, id, oldHash, newHash string, expires time.Time) (bool, error) {
+	qry := fmt.Sprintf(`
+		UPDATE %s.sb_sessions SET
+			refresh_hash = $3,
+			refreshed = $4,
+			expires = $5
+		WHERE id = $1 AND refresh_hash = $2
+	`, dbName)
+
+	res, err := pg.conn().Exec(qry, id, oldHash, newHash, time.Now(), expires)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (pg *PostgreSQL) DeleteSession(dbName, id string) error {
+	qry := fmt.Sprintf(`
+		DELETE FROM %s.sb_sessions
+		WHERE id = $1
+	`, dbName)
+
+	_, err := pg.conn().Exec(qry, id)
+	return err
+}
+
+func (pg *PostgreSQL) DeleteSessions(dbName, userID string) error {
+	qry := fmt.Sprintf(`
+		DELETE FROM %s.sb_sessions
+		WHERE user_id = $1
+	`, dbName)
+
+	_, err := pg.conn().Exec(qry, userID)
+	return err
+}
+
+func scanSession(rows Scanner, s *model.Session) error {
+	return rows.Scan(
+		&s.ID,
+		&s.AccountID,
+		&s.UserID,
+		&s.RefreshHash,
+		&s.Created,
+		&s.Refreshed,
+		&s.Expires,
+	)
+}
@@ -0,0 +1,19 @@
+DO $$
+DECLARE
+	app RECORD;
+BEGIN
+	FOR app IN
+		SELECT a.name
+		FROM sb.apps a
+		JOIN information_schema.schemata s ON s.schema_name = a.name
+	LOOP
+		EXECUTE format('
+			CREATE TABLE IF NOT EXISTS %s.sb_schemas (
+				id uuid PRIMARY KEY DEFAULT uuid_generate_v4 (),
+				col TEXT UNIQUE NOT NULL,
+				definition JSONB NOT NULL,
+				updated timestamp NOT NULL
+			);
+		', app.name);
+	END LOOP;
+END $$;
@@ -0,0 +1,20 @@
+DO $$
+DECLARE
+	app RECORD;
+BEGIN
+	FOR app IN
+		SELECT a.name
+		FROM sb.apps a
+		JOIN information_schema.schemata s ON s.schema_name = a.name
+	LOOP
+		EXECUTE format('
+			CREATE TABLE IF NOT EXISTS %s.sb_roles (
+				id uuid PRIMARY KEY DEFAULT uuid_generate_v4 (),
+				name TEXT UNIQUE NOT NULL,
+				level INTEGER UNIQUE NOT NULL,
+				permissions JSONB NOT NULL,
+				created timestamp NOT NULL
+			);
+		', app.name);
+	END LOOP;
+END $$;
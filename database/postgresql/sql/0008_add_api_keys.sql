@@ -0,0 +1,25 @@
+DO $$
+DECLARE
+	app RECORD;
+BEGIN
+	FOR app IN
+		SELECT a.name
+		FROM sb.apps a
+		JOIN information_schema.schemata s ON s.schema_name = a.name
+	LOOP
+		EXECUTE format('
+			CREATE TABLE IF NOT EXISTS %1$s.sb_api_keys (
+				id uuid PRIMARY KEY,
+				name TEXT NOT NULL,
+				key_hash TEXT NOT NULL,
+				account_id uuid REFERENCES %1$s.sb_accounts(id) ON DELETE CASCADE,
+				user_id uuid REFERENCES %1$s.sb_tokens(id) ON DELETE CASCADE,
+				email TEXT NOT NULL,
+				scopes JSONB NOT NULL,
+				expires timestamp NULL,
+				last_used timestamp NULL,
+				created timestamp NOT NULL
+			);
+		', app.name);
+	END LOOP;
+END $$;
@@ -0,0 +1,24 @@
+DO $$
+DECLARE
+	app RECORD;
+BEGIN
+	FOR app IN
+		SELECT a.name
+		FROM sb.apps a
+		JOIN information_schema.schemata s ON s.schema_name = a.name
+	LOOP
+		EXECUTE format('
+			CREATE TABLE IF NOT EXISTS %1$s.sb_sessions (
+				id uuid PRIMARY KEY,
+				account_id uuid REFERENCES %1$s.sb_accounts(id) ON DELETE CASCADE,
+				user_id uuid REFERENCES %1$s.sb_tokens(id) ON DELETE CASCADE,
+				refresh_hash TEXT NOT NULL,
+				created timestamp NOT NULL,
+				refreshed timestamp NOT NULL,
+				expires timestamp NOT NULL
+			);
+
+			CREATE INDEX IF NOT EXISTS sb_sessions_user_id_idx ON %1$s.sb_sessions (user_id);
+		', app.name);
+	END LOOP;
+END $$;
@@ -0,0 +1,27 @@
+DO $$
+DECLARE
+	app RECORD;
+BEGIN
+	FOR app IN
+		SELECT a.name
+		FROM sb.apps a
+		JOIN information_schema.schemata s ON s.schema_name = a.name
+	LOOP
+		EXECUTE format('
+			CREATE TABLE IF NOT EXISTS %1$s.sb_two_factor (
+				user_id uuid PRIMARY KEY REFERENCES %1$s.sb_tokens(id) ON DELETE CASCADE,
+				secret BYTEA NOT NULL,
+				enabled BOOLEAN NOT NULL,
+				recovery_codes JSONB NOT NULL,
+				last_step BIGINT NOT NULL,
+				created timestamp NOT NULL
+			);
+
+			CREATE TABLE IF NOT EXISTS %1$s.sb_settings (
+				id TEXT PRIMARY KEY,
+				data JSONB NOT NULL,
+				updated timestamp NOT NULL
+			);
+		', app.name);
+	END LOOP;
+END $$;
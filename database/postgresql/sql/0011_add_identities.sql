@@ -0,0 +1,25 @@
+DO $$
+DECLARE
+	app RECORD;
+BEGIN
+	FOR app IN
+		SELECT a.name
+		FROM sb.apps a
+		JOIN information_schema.schemata s ON s.schema_name = a.name
+	LOOP
+		EXECUTE format('
+			CREATE TABLE IF NOT EXISTS %1$s.sb_identities (
+				id uuid PRIMARY KEY DEFAULT uuid_generate_v4 (),
+				account_id uuid REFERENCES %1$s.sb_accounts(id) ON DELETE CASCADE,
+				user_id uuid REFERENCES %1$s.sb_tokens(id) ON DELETE CASCADE,
+				provider TEXT NOT NULL,
+				subject TEXT NOT NULL,
+				email TEXT NOT NULL,
+				linked timestamp NOT NULL,
+				UNIQUE (provider, subject)
+			);
+
+			CREATE INDEX IF NOT EXISTS sb_identities_user_id_idx ON %1$s.sb_identities (user_id);
+		', app.name);
+	END LOOP;
+END $$;
@@ -0,0 +1,25 @@
+DO $$
+DECLARE
+	app RECORD;
+BEGIN
+	FOR app IN
+		SELECT a.name
+		FROM sb.apps a
+		JOIN information_schema.schemata s ON s.schema_name = a.name
+	LOOP
+		EXECUTE format('
+			CREATE TABLE IF NOT EXISTS %1$s.sb_invitations (
+				id uuid PRIMARY KEY,
+				account_id uuid REFERENCES %1$s.sb_accounts(id) ON DELETE CASCADE,
+				email TEXT NOT NULL,
+				role INTEGER NOT NULL,
+				token_hash TEXT NOT NULL,
+				invited_by uuid REFERENCES %1$s.sb_tokens(id) ON DELETE CASCADE,
+				created timestamp NOT NULL,
+				expires timestamp NOT NULL
+			);
+
+			CREATE INDEX IF NOT EXISTS sb_invitations_account_id_idx ON %1$s.sb_invitations (account_id);
+		', app.name);
+	END LOOP;
+END $$;
@@ -0,0 +1,18 @@
+DO $$
+DECLARE
+	app RECORD;
+BEGIN
+	FOR app IN
+		SELECT a.name
+		FROM sb.apps a
+		JOIN information_schema.schemata s ON s.schema_name = a.name
+	LOOP
+		EXECUTE format('
+			CREATE TABLE IF NOT EXISTS %1$s.sb_user_teams (
+				user_id uuid REFERENCES %1$s.sb_tokens(id) ON DELETE CASCADE,
+				team TEXT NOT NULL,
+				PRIMARY KEY (user_id, team)
+			);
+		', app.name);
+	END LOOP;
+END $$;
@@ -13,7 +13,7 @@ func (pg *PostgreSQL) AddFile(dbName string, f model.File) (id string, err error
 		RETURNING id;
 	`, dbName)
 
-	err = pg.DB.QueryRow(
+	err = pg.conn().QueryRow(
 		qry,
 		f.AccountID,
 		f.Key,
@@ -31,7 +31,7 @@ func (pg *PostgreSQL) GetFileByID(dbName, fileID string) (f model.File, err erro
 		WHERE id = $1
 	`, dbName)
 
-	row := pg.DB.QueryRow(qry, fileID)
+	row := pg.conn().QueryRow(qry, fileID)
 
 	err = scanFile(row, &f)
 	return
@@ -44,7 +44,7 @@ func (pg *PostgreSQL) DeleteFile(dbName, fileID string) error {
 		WHERE id = $1
 	`, dbName)
 
-	if _, err := pg.DB.Exec(qry, fileID); err != nil {
+	if _, err := pg.conn().Exec(qry, fileID); err != nil {
 		return err
 	}
 	return nil
@@ -65,7 +65,7 @@ func (pg *PostgreSQL) ListAllFiles(dbName, accountID string) (results []model.Fi
 		%s
 	`, dbName, where)
 
-	rows, err := pg.DB.Query(qry, accountID)
+	rows, err := pg.conn().Query(qry, accountID)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,69 @@
+package postgresql
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func (pg *PostgreSQL) ListUserTeams(dbName, userID string) (teams []string, err error) {
+	// the users' IDs are UUID, i.e. the experimental public tokens have no
+	// teams
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, nil
+	}
+
+	qry := fmt.Sprintf(`
+		SELECT team
+		FROM %s.sb_user_teams
+		WHERE user_id = $1
+		ORDER BY team
+	`, dbName)
+
+	rows, err := pg.conn().Query(qry, userID)
+	if err != nil {
+		if !isTableExists(err) {
+			return nil, nil
+		}
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var team string
+		if err = rows.Scan(&team); err != nil {
+			return
+		}
+
+		teams = append(teams, team)
+	}
+
+	err = rows.Err()
+	return
+}
+
+func (pg *PostgreSQL) SetUserTeams(dbName, userID string, teams []string) error {
+	// the teams are replaced without removing the ones the user keeps
+	if teams == nil {
+		teams = []string{}
+	}
+
+	qry := fmt.Sprintf(`
+		DELETE FROM %s.sb_user_teams
+		WHERE user_id = $1 AND NOT (team = ANY($2))
+	`, dbName)
+
+	if _, err := pg.conn().Exec(qry, userID, pq.Array(teams)); err != nil {
+		return err
+	}
+
+	qry = fmt.Sprintf(`
+		INSERT INTO %s.sb_user_teams(user_id, team)
+		SELECT $1, unnest($2::text[])
+		ON CONFLICT DO NOTHING
+	`, dbName)
+
+	_, err := pg.conn().Exec(qry, userID, pq.Array(teams))
+	return err
+}
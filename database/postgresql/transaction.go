@@ -0,0 +1,73 @@
+package postgresql
+
+import (
+	"database/sql"
+
+	"github.com/staticbackendhq/core/database"
+)
+
+// querier is implemented by *sql.DB and *sql.Tx so the same queries run
+// inside and outside of a transaction
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// conn returns the current transaction or the database
+func (pg *PostgreSQL) conn() querier {
+	if pg.tx != nil {
+		return pg.tx
+	}
+	return pg.DB
+}
+
+// async runs fn in a goroutine, inside a transaction it runs before returning
+// since the transaction cannot be used once it ends
+func (pg *PostgreSQL) async(fn func()) {
+	if pg.tx != nil {
+		fn()
+		return
+	}
+	go fn()
+}
+
+func (pg *PostgreSQL) WithTransaction(fn func(tx database.Persister) error) (err error) {
+	// nested transactions are part of the current one
+	if pg.tx != nil {
+		return fn(pg)
+	}
+
+	tx, err := pg.DB.Begin()
+	if err != nil {
+		return
+	}
+
+	events := &database.TxEvents{}
+
+	txpg := &PostgreSQL{
+		DB:              pg.DB,
+		PublishDocument: events.Publish,
+		log:             pg.log,
+		tx:              tx,
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(txpg); err != nil {
+		tx.Rollback()
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		return
+	}
+
+	events.Flush(pg.PublishDocument)
+	return
+}
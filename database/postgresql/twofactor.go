@@ -0,0 +1,104 @@
+package postgresql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+func (pg *PostgreSQL) GetTwoFactor(dbName, userID string) (tf model.TwoFactor, err error) {
+	qry := fmt.Sprintf(`
+		SELECT user_id, secret, enabled, recovery_codes, last_step, created
+		FROM %s.sb_two_factor
+		WHERE user_id = $1
+	`, dbName)
+
+	var b []byte
+	err = pg.conn().QueryRow(qry, userID).Scan(
+		&tf.UserID,
+		&tf.Secret,
+		&tf.Enabled,
+		&b,
+		&tf.LastStep,
+		&tf.Created,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.TwoFactor{}, nil
+	} else if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(b, &tf.RecoveryCodes)
+	return
+}
+
+func (pg *PostgreSQL) SaveTwoFactor(dbName string, tf model.TwoFactor) error {
+	b, err := json.Marshal(tf.RecoveryCodes)
+	if err != nil {
+		return err
+	}
+
+	qry := fmt.Sprintf(`
+		INSERT INTO %s.sb_two_factor(user_id, secret, enabled, recovery_codes, last_step, created)
+		VALUES($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE SET
+			secret = EXCLUDED.secret,
+			enabled = EXCLUDED.enabled,
+			recovery_codes = EXCLUDED.recovery_codes,
+			last_step = EXCLUDED.last_step
+	`, dbName)
+
+	_, err = pg.conn().Exec(qry, tf.UserID, tf.Secret, tf.Enabled, b, tf.LastStep, time.Now())
+	return err
+}
+
+func (pg *PostgreSQL) DeleteTwoFactor(dbName, userID string) error {
+	qry := fmt.Sprintf(`
+		DELETE FROM %s.sb_two_factor
+		WHERE user_id = $1
+	`, dbName)
+
+	_, err := pg.conn().Exec(qry, userID)
+	return err
+}
+
+func (pg *PostgreSQL) GetSettings(dbName string) (s model.DatabaseSettings, err error) {
+	qry := fmt.Sprintf(`
+		SELECT data
+		FROM %s.sb_settings
+		WHERE id = 'default'
+	`, dbName)
+
+	var b []byte
+	err = pg.conn().QueryRow(qry).Scan(&b)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.DatabaseSettings{}, nil
+	} else if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(b, &s)
+	return
+}
+
+func (pg *PostgreSQL) SaveSettings(dbName string, s model.DatabaseSettings) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	qry := fmt.Sprintf(`
+		INSERT INTO %s.sb_settings(id, data, updated)
+		VALUES('default', $1, $2)
+		ON CONFLICT (id) DO UPDATE SET
+			data = EXCLUDED.data,
+			updated = EXCLUDED.updated
+	`, dbName)
+
+	_, err = pg.conn().Exec(qry, b, time.Now())
+	return err
+}
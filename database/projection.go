@@ -0,0 +1,108 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var validProjectionField = regexp.MustCompile(`^[a-zA-Z0-9_\-]+$`)
+
+// Fields always returned with a document
+var projectionKeptFields = []string{"id", "accountId"}
+
+// Projection is the list of top-level fields to return (Include) or to
+// remove (Exclude) from documents. An empty Projection returns everything.
+type Projection struct {
+	Include []string
+	Exclude []string
+}
+
+// ParseFields returns the Projection for the fields. Fields prefixed with a -
+// are excluded, including and excluding fields cannot be mixed.
+func ParseFields(fields []string) (p Projection, err error) {
+	for _, field := range fields {
+		exclude := strings.HasPrefix(field, "-")
+		name := strings.TrimPrefix(field, "-")
+
+		if !validProjectionField.MatchString(name) {
+			return p, fmt.Errorf("invalid projection field: %s", field)
+		}
+
+		if exclude {
+			p.Exclude = append(p.Exclude, name)
+		} else {
+			p.Include = append(p.Include, name)
+		}
+	}
+
+	if len(p.Include) > 0 && len(p.Exclude) > 0 {
+		return p, errors.New("a projection cannot include and exclude fields at the same time")
+	}
+	return
+}
+
+// IsEmpty returns true if the projection returns all fields
+func (p Projection) IsEmpty() bool {
+	return len(p.Include) == 0 && len(p.Exclude) == 0
+}
+
+// Keep returns a projection that does not remove the fields. It's used to
+// keep the sort fields needed to build a cursor.
+func (p Projection) Keep(fields ...string) Projection {
+	if p.IsEmpty() {
+		return p
+	}
+
+	var keep Projection
+	if len(p.Include) > 0 {
+		keep.Include = append(keep.Include, p.Include...)
+		for _, field := range fields {
+			if !contains(keep.Include, field) {
+				keep.Include = append(keep.Include, field)
+			}
+		}
+		return keep
+	}
+
+	for _, field := range p.Exclude {
+		if !contains(fields, field) {
+			keep.Exclude = append(keep.Exclude, field)
+		}
+	}
+
+	// nothing left to exclude means returning everything
+	if len(keep.Exclude) == 0 {
+		return Projection{}
+	}
+	return keep
+}
+
+// Apply removes the fields from the document based on the projection. The
+// id and accountId fields are always returned.
+func (p Projection) Apply(doc map[string]any) {
+	if len(p.Include) > 0 {
+		for k := range doc {
+			if !contains(p.Include, k) && !contains(projectionKeptFields, k) {
+				delete(doc, k)
+			}
+		}
+		return
+	}
+
+	for _, field := range p.Exclude {
+		if !contains(projectionKeptFields, field) {
+			delete(doc, field)
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
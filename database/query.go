@@ -0,0 +1,291 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Query operators supported by ParseQuery. Other than !exists, they never
+// match a document missing the field, including != and !in, a not group
+// matches those.
+const (
+	OpEqual            = "="
+	OpNotEqual         = "!="
+	OpGreater          = ">"
+	OpLower            = "<"
+	OpGreaterThanEqual = ">="
+	OpLowerThanEqual   = "<="
+	OpIn               = "in"
+	OpNotIn            = "!in"
+	OpContains         = "contains"
+	OpStartsWith       = "startsWith"
+	OpEqualFold        = "ieq"
+	OpRegex            = "regex"
+	OpExists           = "exists"
+	OpNotExists        = "!exists"
+	OpBetween          = "between"
+	OpContainsAny      = "containsAny"
+	OpContainsAll      = "containsAll"
+	OpSize             = "size"
+)
+
+// Groups combining nested clauses
+const (
+	GroupAnd = "and"
+	GroupOr  = "or"
+	GroupNot = "not"
+)
+
+// ValueKind indicates how a clause's value should be compared, the document
+// values of another kind never match, i.e. the number 10 and "10"
+type ValueKind int
+
+const (
+	KindText ValueKind = iota
+	KindNumber
+	KindBool
+	KindTime
+	KindList
+	KindNull
+)
+
+// Filter is the typed representation of query clauses. All clauses are
+// ANDed together. A nil Filter matches all documents.
+type Filter []Clause
+
+// Clause is a single "field operator value" criteria. The Value is normalized
+// based on its Kind: float64 for numbers, time.Time for timestamps and
+// []any for lists. For the between operator the Value is a []any holding the
+// lower and upper bounds and the Kind is the kind of the bounds.
+//
+// When Group is set the clause is a nested group (and, or, not) and only its
+// Clauses are used. A "not" group negates the AND of its Clauses.
+type Clause struct {
+	Field string
+	Op    string
+	Value any
+	Kind  ValueKind
+
+	Group   string
+	Clauses Filter
+}
+
+// IsGroup returns true if this clause is a nested group of clauses
+func (c Clause) IsGroup() bool {
+	return len(c.Group) > 0
+}
+
+var validField = regexp.MustCompile(`^[a-zA-Z0-9_\-]+(\.[a-zA-Z0-9_\-]+)*$`)
+
+// ParseQuery validates the raw clauses (field, operator, value) and returns
+// a typed Filter the data store implementations compile into their own query
+// language. A dotted field is the path of a nested value, i.e. "author.name".
+//
+// Clauses can be nested in groups with a 2 parameters clause (group, clauses)
+// where group is either "and", "or" or "not":
+//
+//	[["done", "=", false], ["or", [["likes", ">", 10], ["tags", "in", "hot"]]]]
+func ParseQuery(clauses [][]interface{}) (Filter, error) {
+	return parseClauses(clauses, "")
+}
+
+func parseClauses(clauses [][]interface{}, parent string) (Filter, error) {
+	var filter Filter
+
+	for i, clause := range clauses {
+		pos := fmt.Sprintf("%s%d", parent, i+1)
+
+		if len(clause) == 2 {
+			group, err := parseGroup(clause, pos)
+			if err != nil {
+				return nil, err
+			}
+
+			filter = append(filter, group)
+			continue
+		}
+
+		if len(clause) != 3 {
+			return nil, fmt.Errorf("the %s query clause did not contains the required 3 parameters (field, operator, value)", pos)
+		}
+
+		field, ok := clause[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("the %s query clause's field parameter must be a string: %v", pos, clause[0])
+		} else if !validField.MatchString(field) {
+			return nil, fmt.Errorf("the %s query clause's field parameter contains invalid characters: %s", pos, field)
+		}
+
+		op, ok := clause[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("the %s query clause's operator must be a string: %v", pos, clause[1])
+		}
+
+		switch op {
+		case "=", "==":
+			op = OpEqual
+		case "!=", "<>":
+			op = OpNotEqual
+		case ">", "<", ">=", "<=":
+		case "in":
+			op = OpIn
+		case "!in", "nin":
+			op = OpNotIn
+		case OpContains, OpStartsWith, OpEqualFold, OpRegex, OpExists, OpNotExists,
+			OpBetween, OpContainsAny, OpContainsAll, OpSize:
+		default:
+			return nil, fmt.Errorf("the %s query clause's operator: %s is not supported at the moment", pos, op)
+		}
+
+		val, kind, err := parseValue(op, clause[2])
+		if err != nil {
+			return nil, fmt.Errorf("the %s query clause's operator: %s %v", pos, op, err)
+		}
+
+		// exists false is the same as !exists
+		if b, ok := clause[2].(bool); ok && !b && op == OpExists {
+			op = OpNotExists
+		}
+
+		filter = append(filter, Clause{Field: field, Op: op, Value: val, Kind: kind})
+	}
+
+	return filter, nil
+}
+
+// parseGroup parses a (group, clauses) nested group
+func parseGroup(clause []interface{}, pos string) (Clause, error) {
+	group, ok := clause[0].(string)
+	if !ok {
+		return Clause{}, fmt.Errorf("the %s query clause's group must be a string: %v", pos, clause[0])
+	}
+
+	group = strings.ToLower(group)
+	if group != GroupAnd && group != GroupOr && group != GroupNot {
+		return Clause{}, fmt.Errorf("the %s query clause's group: %s is not supported, use and, or, not", pos, group)
+	}
+
+	rv := reflect.ValueOf(clause[1])
+	if rv.Kind() != reflect.Slice || rv.Len() == 0 {
+		return Clause{}, fmt.Errorf("the %s query clause's %s group must contains a non-empty list of clauses", pos, group)
+	}
+
+	var nested [][]interface{}
+	for i := 0; i < rv.Len(); i++ {
+		item := reflect.ValueOf(rv.Index(i).Interface())
+		if item.Kind() != reflect.Slice {
+			return Clause{}, fmt.Errorf("the %s%d query clause must be a list", pos+".", i+1)
+		}
+
+		c := make([]interface{}, item.Len())
+		for j := 0; j < item.Len(); j++ {
+			c[j] = item.Index(j).Interface()
+		}
+		nested = append(nested, c)
+	}
+
+	clauses, err := parseClauses(nested, pos+".")
+	if err != nil {
+		return Clause{}, err
+	}
+
+	return Clause{Group: group, Clauses: clauses}, nil
+}
+
+// parseValue validates and normalizes the value based on the operator
+func parseValue(op string, v any) (any, ValueKind, error) {
+	switch op {
+	case OpExists, OpNotExists:
+		// the value is not used, ["field", "exists", true] reads better
+		return nil, KindNull, nil
+	case OpContains, OpStartsWith, OpEqualFold, OpRegex:
+		s, ok := v.(string)
+		if !ok {
+			return nil, KindText, errors.New("requires a string as value")
+		}
+
+		if op == OpRegex {
+			if _, err := regexp.Compile(s); err != nil {
+				return nil, KindText, fmt.Errorf("has an invalid regular expression: %v", err)
+			}
+		}
+		return s, KindText, nil
+	case OpSize:
+		val, kind := normalizeValue(v)
+		if kind != KindNumber {
+			return nil, kind, errors.New("requires a number as value")
+		}
+		return val, kind, nil
+	case OpBetween:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array || rv.Len() != 2 {
+			return nil, KindList, errors.New("requires a list of 2 values (lower, upper)")
+		}
+
+		lower, lk := normalizeValue(rv.Index(0).Interface())
+		upper, uk := normalizeValue(rv.Index(1).Interface())
+		if lk != uk || (lk != KindNumber && lk != KindTime && lk != KindText) {
+			return nil, KindList, errors.New("requires bounds of the same type (number, time or string)")
+		}
+		return []any{lower, upper}, lk, nil
+	}
+
+	val, kind := normalizeValue(v)
+
+	if op == OpIn || op == OpNotIn || op == OpContainsAny || op == OpContainsAll {
+		// a single value is accepted as a list of one element
+		if kind != KindList {
+			val, kind = []any{val}, KindList
+		}
+	} else if kind == KindList {
+		return nil, kind, errors.New("does not accept a list as value")
+	} else if kind == KindNull && op != OpEqual && op != OpNotEqual {
+		return nil, kind, errors.New("does not accept a null value")
+	}
+	return val, kind, nil
+}
+
+// normalizeValue returns the value converted to its comparison type
+func normalizeValue(v any) (any, ValueKind) {
+	switch x := v.(type) {
+	case nil:
+		return nil, KindNull
+	case bool:
+		return x, KindBool
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, x); err == nil {
+			return t, KindTime
+		}
+		return x, KindText
+	case time.Time:
+		return x, KindTime
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), KindNumber
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), KindNumber
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), KindNumber
+	case reflect.Slice, reflect.Array:
+		list := make([]any, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			item := rv.Index(i).Interface()
+			// list items are matched as-is, strings are not parsed as time
+			if _, ok := item.(string); ok {
+				list[i] = item
+				continue
+			}
+			list[i], _ = normalizeValue(item)
+		}
+		return list, KindList
+	}
+
+	return fmt.Sprintf("%v", v), KindText
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseQueryValueKinds(t *testing.T) {
+	now := time.Now()
+
+	clauses := [][]interface{}{
+		{"title", "==", "task"},
+		{"likes", ">", 5},
+		{"done", "<>", true},
+		{"created", ">=", now.Format(time.RFC3339Nano)},
+		{"tags", "in", "red"},
+		{"deleted", "=", nil},
+	}
+
+	filter, err := ParseQuery(clauses)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		op   string
+		kind ValueKind
+	}{
+		{OpEqual, KindText},
+		{OpGreater, KindNumber},
+		{OpNotEqual, KindBool},
+		{OpGreaterThanEqual, KindTime},
+		{OpIn, KindList},
+		{OpEqual, KindNull},
+	}
+
+	for i, exp := range expected {
+		if filter[i].Op != exp.op {
+			t.Errorf("clause %d: expected op %s got %s", i, exp.op, filter[i].Op)
+		} else if filter[i].Kind != exp.kind {
+			t.Errorf("clause %d: expected kind %d got %d", i, exp.kind, filter[i].Kind)
+		}
+	}
+
+	if v, ok := filter[1].Value.(float64); !ok || v != 5 {
+		t.Errorf("expected numeric value to be normalized to float64 got %v", filter[1].Value)
+	}
+}
+
+func TestParseQueryRejectsInvalidClauses(t *testing.T) {
+	tests := [][][]interface{}{
+		{{"title", "="}},
+		{{"title' OR 1=1 --", "=", "x"}},
+		{{"title", "like", "x"}},
+		{{"likes", ">", []int{1, 2}}},
+		{{"likes", ">", nil}},
+		{{"title", "contains", 1}},
+		{{"title", "regex", "([a-z"}},
+		{{"likes", "between", []int{1}}},
+		{{"likes", "between", []interface{}{1, "x"}}},
+		{{"tags", "size", "2"}},
+	}
+
+	for _, clauses := range tests {
+		if _, err := ParseQuery(clauses); err == nil {
+			t.Errorf("expected an error for clauses %v", clauses)
+		}
+	}
+}
+
+func TestParseQueryNestedGroups(t *testing.T) {
+	clauses := [][]interface{}{
+		{"done", "=", false},
+		{"Or", []interface{}{
+			[]interface{}{"likes", ">", 10},
+			[]interface{}{"not", [][]interface{}{{"tags", "in", "hot"}}},
+		}},
+	}
+
+	filter, err := ParseQuery(clauses)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(filter) != 2 {
+		t.Fatalf("expected 2 clauses got %d", len(filter))
+	}
+
+	or := filter[1]
+	if !or.IsGroup() || or.Group != GroupOr {
+		t.Fatalf("expected an or group got %v", or)
+	} else if len(or.Clauses) != 2 {
+		t.Fatalf("expected 2 nested clauses got %d", len(or.Clauses))
+	} else if or.Clauses[1].Group != GroupNot || or.Clauses[1].Clauses[0].Kind != KindList {
+		t.Errorf("expected a not group with a list clause got %v", or.Clauses[1])
+	}
+
+	invalid := [][][]interface{}{
+		{{"xor", [][]interface{}{{"a", "=", 1}}}},
+		{{"or", [][]interface{}{}}},
+		{{"or", "a"}},
+		{{"or", [][]interface{}{{"a", "like", 1}}}},
+	}
+
+	for _, clauses := range invalid {
+		if _, err := ParseQuery(clauses); err == nil {
+			t.Errorf("expected an error for clauses %v", clauses)
+		}
+	}
+}
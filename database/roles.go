@@ -0,0 +1,94 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+const (
+	// MinRoleLevel is the lowest level of a named role, 0 is for the users
+	// without a role
+	MinRoleLevel = 1
+	// MaxRoleLevel is the highest level of a named role, 100 is root
+	MaxRoleLevel = 99
+)
+
+// ErrRoleNotFound is returned when a named role does not exist
+var ErrRoleNotFound = errors.New("role not found")
+
+var validRoleName = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// ValidateRole validates a named role against the existing roles of its
+// database, the level of a role must be unique
+func ValidateRole(role model.Role, existing []model.Role) error {
+	if !validRoleName.MatchString(role.Name) {
+		return fmt.Errorf("invalid role name %q, use lowercase letters, digits, - and _", role.Name)
+	} else if role.Level < MinRoleLevel || role.Level > MaxRoleLevel {
+		return fmt.Errorf("the role's level must be between %d and %d", MinRoleLevel, MaxRoleLevel)
+	}
+
+	for _, p := range role.Permissions {
+		if len(p) == 0 || strings.ContainsAny(p, " \t\n,") {
+			return fmt.Errorf("invalid permission %q", p)
+		}
+	}
+
+	for _, r := range existing {
+		if r.Level == role.Level && r.Name != role.Name {
+			return fmt.Errorf("the level %d is already used by the role %s", role.Level, r.Name)
+		}
+	}
+	return nil
+}
+
+// FindRole returns the named role of a level, ok is false when no role has
+// this level
+func FindRole(datastore Persister, dbName string, level int) (role model.Role, ok bool, err error) {
+	if level < MinRoleLevel || level > MaxRoleLevel {
+		return
+	}
+
+	roles, err := datastore.ListRoles(dbName)
+	if err != nil {
+		return
+	}
+
+	for _, r := range roles {
+		if r.Level == level {
+			return r, true, nil
+		}
+	}
+	return
+}
+
+// FindRoleByName returns a named role, ErrRoleNotFound is returned when it
+// does not exist
+func FindRoleByName(datastore Persister, dbName, name string) (model.Role, error) {
+	roles, err := datastore.ListRoles(dbName)
+	if err != nil {
+		return model.Role{}, err
+	}
+
+	for _, r := range roles {
+		if r.Name == name {
+			return r, nil
+		}
+	}
+	return model.Role{}, ErrRoleNotFound
+}
+
+// WithRoleName sets the name of the caller's role, it's empty when their
+// level has no named role
+func WithRoleName(datastore Persister, dbName string, auth model.Auth) (model.Auth, error) {
+	role, _, err := FindRole(datastore, dbName, auth.Role)
+	if err != nil {
+		return auth, err
+	}
+
+	auth.RoleName = role.Name
+	return auth, nil
+}
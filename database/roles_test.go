@@ -0,0 +1,37 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+func TestValidateRole(t *testing.T) {
+	existing := []model.Role{{Name: "editor", Level: 40}}
+
+	valid := []model.Role{
+		{Name: "billing-admin", Level: 50, Permissions: []string{"billing:read", "billing:write"}},
+		// replacing a role keeps its level
+		{Name: "editor", Level: 40},
+	}
+	for _, role := range valid {
+		if err := ValidateRole(role, existing); err != nil {
+			t.Errorf("expected %v to be valid got %v", role, err)
+		}
+	}
+
+	invalid := []model.Role{
+		{Name: "Editor", Level: 30},
+		{Name: "no spaces", Level: 30},
+		{Name: "viewer", Level: 0},
+		{Name: "viewer", Level: 100},
+		{Name: "viewer", Level: 40},
+		{Name: "viewer", Level: 30, Permissions: []string{"a b"}},
+		{Name: "viewer", Level: 30, Permissions: []string{""}},
+	}
+	for _, role := range invalid {
+		if err := ValidateRole(role, existing); err == nil {
+			t.Errorf("expected an error for %v", role)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+// AuthPrefix prefixes the rule fields and values referencing the caller
+const AuthPrefix = "auth."
+
+// ErrPermissionDenied is returned when a new document does not satisfy its
+// collection's write rule
+var ErrPermissionDenied = errors.New("permission denied by the collection rules")
+
+// denyAll is a filter no document satisfies, it's used when the clauses
+// referencing only the caller deny the access
+var denyAll = Filter{{Group: GroupAnd, Clauses: Filter{
+	{Field: "id", Op: OpExists, Kind: KindNull},
+	{Field: "id", Op: OpNotExists, Kind: KindNull},
+}}}
+
+// authValue returns the caller's value referenced by a rule
+func authValue(auth model.Auth, ref string) (any, error) {
+	switch strings.TrimPrefix(ref, AuthPrefix) {
+	case "accountId":
+		return auth.AccountID, nil
+	case "userId":
+		return auth.UserID, nil
+	case "email":
+		return auth.Email, nil
+	case "role":
+		return auth.Role, nil
+	case "emailVerified":
+		return auth.EmailVerified, nil
+	case "teams":
+		return auth.Teams, nil
+	}
+	return nil, fmt.Errorf("unknown %s, the caller's fields are accountId, userId, email, emailVerified, role and teams", ref)
+}
+
+// ValidateRules validates the clauses of the read and write rules
+func ValidateRules(rules model.Rules) error {
+	if _, err := ResolveRule(rules.Read, model.Auth{}); err != nil {
+		return fmt.Errorf("invalid read rule: %w", err)
+	} else if _, err := ResolveRule(rules.Write, model.Auth{}); err != nil {
+		return fmt.Errorf("invalid write rule: %w", err)
+	}
+	return nil
+}
+
+// ResolveRule returns the filter the documents must satisfy for the caller.
+// The caller's values are substituted and the clauses referencing only the
+// caller are evaluated, i.e. [["auth.role", ">=", 50]] returns an empty
+// filter for an admin and a filter matching no documents for other users.
+// The role is compared by name when the value is a string.
+func ResolveRule(rule [][]any, auth model.Auth) (Filter, error) {
+	raw, known, allowed, err := resolveAnd(rule, auth)
+	if err != nil {
+		return nil, err
+	} else if known && allowed {
+		return Filter{}, nil
+	} else if known {
+		return denyAll, nil
+	}
+	return ParseQuery(raw)
+}
+
+// resolveAnd resolves clauses ANDed together, known is true when the result
+// does not depend on the documents. All clauses are resolved so invalid ones
+// are reported even when the result is already known.
+func resolveAnd(clauses [][]any, auth model.Auth) (raw [][]any, known, allowed bool, err error) {
+	allowed = true
+	for _, clause := range clauses {
+		r, k, v, err := resolveClause(clause, auth)
+		if err != nil {
+			return nil, false, false, err
+		} else if !k {
+			raw = append(raw, r)
+		} else if !v {
+			allowed = false
+		}
+	}
+
+	if !allowed {
+		return nil, true, false, nil
+	}
+	return raw, len(raw) == 0, true, nil
+}
+
+// resolveOr resolves clauses ORed together
+func resolveOr(clauses [][]any, auth model.Auth) (raw [][]any, known, allowed bool, err error) {
+	for _, clause := range clauses {
+		r, k, v, err := resolveClause(clause, auth)
+		if err != nil {
+			return nil, false, false, err
+		} else if !k {
+			raw = append(raw, r)
+		} else if v {
+			allowed = true
+		}
+	}
+
+	if allowed {
+		return nil, true, true, nil
+	}
+	return raw, len(raw) == 0, false, nil
+}
+
+func resolveClause(clause []any, auth model.Auth) (raw []any, known, allowed bool, err error) {
+	if len(clause) == 2 {
+		return resolveGroup(clause, auth)
+	} else if len(clause) != 3 {
+		return nil, false, false, fmt.Errorf("the rule clause %v must be (field, operator, value) or (group, clauses)", clause)
+	}
+
+	field, ok := clause[0].(string)
+	if !ok {
+		return nil, false, false, fmt.Errorf("the rule clause's field must be a string: %v", clause[0])
+	}
+
+	val, err := substitute(clause[2], auth)
+	if err != nil {
+		return nil, false, false, err
+	}
+
+	if !strings.HasPrefix(field, AuthPrefix) {
+		raw = []any{field, clause[1], val}
+		// the clause is parsed on its own to validate it
+		_, err = ParseQuery([][]any{raw})
+		return raw, false, false, err
+	}
+
+	v, err := authValue(auth, field)
+	if err != nil {
+		return nil, false, false, err
+	} else if field == AuthPrefix+"role" && isRoleName(val) {
+		// the role is compared by name, i.e. ["auth.role", "in", ["editor"]]
+		v = auth.RoleName
+	}
+
+	filter, err := ParseQuery([][]any{{"value", clause[1], val}})
+	if err != nil {
+		return nil, false, false, err
+	}
+	return nil, true, Match(map[string]any{"value": v}, filter), nil
+}
+
+func resolveGroup(clause []any, auth model.Auth) (raw []any, known, allowed bool, err error) {
+	group, ok := clause[0].(string)
+	if !ok {
+		return nil, false, false, fmt.Errorf("the rule clause's group must be a string: %v", clause[0])
+	}
+
+	rv := reflect.ValueOf(clause[1])
+	if !isList(rv) || rv.Len() == 0 {
+		return nil, false, false, fmt.Errorf("the rule's %s group must contains a non-empty list of clauses", group)
+	}
+
+	var clauses [][]any
+	for i := 0; i < rv.Len(); i++ {
+		item := reflect.ValueOf(rv.Index(i).Interface())
+		if !isList(item) {
+			return nil, false, false, fmt.Errorf("the rule's %s group clauses must be lists", group)
+		}
+
+		c := make([]any, item.Len())
+		for j := 0; j < item.Len(); j++ {
+			c[j] = item.Index(j).Interface()
+		}
+		clauses = append(clauses, c)
+	}
+
+	var nested [][]any
+	switch strings.ToLower(group) {
+	case GroupAnd:
+		nested, known, allowed, err = resolveAnd(clauses, auth)
+	case GroupOr:
+		nested, known, allowed, err = resolveOr(clauses, auth)
+	case GroupNot:
+		nested, known, allowed, err = resolveAnd(clauses, auth)
+		allowed = !allowed
+	default:
+		return nil, false, false, fmt.Errorf("the rule's group: %s is not supported, use and, or, not", group)
+	}
+
+	if err != nil || known {
+		return nil, known, allowed, err
+	}
+	return []any{group, nested}, false, false, nil
+}
+
+// isRoleName returns true if the value is a role name or a list of names
+func isRoleName(v any) bool {
+	if _, ok := v.(string); ok {
+		return true
+	}
+
+	rv := reflect.ValueOf(v)
+	if !isList(rv) || rv.Len() == 0 {
+		return false
+	}
+
+	_, ok := rv.Index(0).Interface().(string)
+	return ok
+}
+
+// substitute replaces the values referencing the caller
+func substitute(v any, auth model.Auth) (any, error) {
+	if s, ok := v.(string); ok && strings.HasPrefix(s, AuthPrefix) {
+		return authValue(auth, s)
+	}
+
+	rv := reflect.ValueOf(v)
+	if !isList(rv) {
+		return v, nil
+	}
+
+	list := make([]any, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		item, err := substitute(rv.Index(i).Interface(), auth)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = item
+	}
+	return list, nil
+}
+
+// RuleFilter returns the filter enforcing the read or write rule for the
+// caller, ok is false when the rule is empty and the permissions from the
+// collection's name apply
+func RuleFilter(rules model.Rules, write bool, auth model.Auth) (filter Filter, ok bool, err error) {
+	rule := rules.Read
+	if write {
+		rule = rules.Write
+	}
+
+	if len(rule) == 0 {
+		return nil, false, nil
+	}
+
+	filter, err = ResolveRule(rule, auth)
+	return filter, err == nil, err
+}
+
+// SecureFilter returns the filter with the collection's read or write rule
+// added. ok is false when the collection has no rule for the operation or the
+// caller is root, the data stores then apply the permissions from the
+// collection's name.
+func SecureFilter(datastore Persister, auth model.Auth, dbName, col string, write bool, filter Filter) (Filter, bool, error) {
+	if auth.Role == 100 {
+		return filter, false, nil
+	}
+
+	rules, ok, err := datastore.GetRules(dbName, col)
+	if err != nil || !ok {
+		return filter, false, err
+	}
+
+	// the role's name and the teams are resolved since they may have changed
+	// after the caller's session was created
+	auth, err = WithRoleName(datastore, dbName, auth)
+	if err != nil {
+		return filter, false, err
+	}
+
+	auth, err = WithTeams(datastore, dbName, auth)
+	if err != nil {
+		return filter, false, err
+	}
+
+	rule, ok, err := RuleFilter(rules.Rules, write, auth)
+	if err != nil || !ok {
+		return filter, false, err
+	}
+
+	secured := make(Filter, 0, len(filter)+len(rule))
+	secured = append(secured, filter...)
+	return append(secured, rule...), true, nil
+}
+
+// CanCreate returns ErrPermissionDenied when the new document, owned by the
+// caller, does not satisfy the collection's write rule
+func CanCreate(datastore Persister, auth model.Auth, dbName, col string, doc map[string]any) error {
+	rule, ok, err := SecureFilter(datastore, auth, dbName, col, true, nil)
+	if err != nil || !ok {
+		return err
+	}
+
+	owned := make(map[string]any, len(doc)+2)
+	for k, v := range doc {
+		owned[k] = v
+	}
+	owned["accountId"] = auth.AccountID
+	owned["ownerId"] = auth.UserID
+
+	if !Match(owned, rule) {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+// CanUpdate returns ErrPermissionDenied when the document, once updated, does
+// not satisfy the write rule. The stored document satisfying the rule is not
+// enough, the update could move it out of the rule. The document must have
+// its accountId and ownerId, the fields maintained by the data stores are
+// not updated.
+func CanUpdate(rule Filter, doc, update map[string]any) error {
+	updated := make(map[string]any, len(doc)+len(update))
+	for k, v := range doc {
+		updated[k] = v
+	}
+	for k, v := range update {
+		if !systemFields[k] {
+			updated[k] = v
+		}
+	}
+
+	if !Match(updated, rule) {
+		return ErrPermissionDenied
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+func parseRule(t *testing.T, s string) [][]any {
+	var rule [][]any
+	if err := json.Unmarshal([]byte(s), &rule); err != nil {
+		t.Fatal(err)
+	}
+	return rule
+}
+
+func TestResolveRule(t *testing.T) {
+	admin := model.Auth{AccountID: "acct1", UserID: "user1", Email: "admin@test.com", Role: 50}
+	member := model.Auth{AccountID: "acct1", UserID: "user2", Email: "member@test.com", Role: 0}
+	manager := model.Auth{AccountID: "acct1", UserID: "user3", Role: 40, RoleName: "manager"}
+	verified := model.Auth{AccountID: "acct1", UserID: "user4", EmailVerified: true}
+	red := model.Auth{AccountID: "acct1", UserID: "user5", Teams: []string{"red", "green"}}
+
+	docs := []map[string]any{
+		{"id": "1", "ownerId": "user1", "teamId": "red", "public": false},
+		{"id": "2", "ownerId": "user2", "teamId": "blue", "public": false},
+		{"id": "3", "ownerId": "user1", "teamId": "blue", "public": true},
+	}
+
+	tests := []struct {
+		name    string
+		rule    string
+		auth    model.Auth
+		matches []string
+	}{
+		{"role allowed", `[["auth.role", ">=", 50]]`, admin, []string{"1", "2", "3"}},
+		{"role denied", `[["auth.role", ">=", 50]]`, member, nil},
+		{"owner", `[["ownerId", "=", "auth.userId"]]`, member, []string{"2"}},
+		{"in list", `[["ownerId", "in", ["auth.userId", "user1"]]]`, member, []string{"1", "2", "3"}},
+		{"or with role", `[["or", [["auth.role", ">=", 50], ["ownerId", "=", "auth.userId"]]]]`, member, []string{"2"}},
+		{"or allowed by role", `[["or", [["auth.role", ">=", 50], ["ownerId", "=", "auth.userId"]]]]`, admin, []string{"1", "2", "3"}},
+		{"or on documents", `[["or", [["public", "=", true], ["ownerId", "=", "auth.userId"]]]]`, member, []string{"2", "3"}},
+		{"and with role", `[["auth.email", "=", "member@test.com"], ["teamId", "=", "blue"]]`, member, []string{"2", "3"}},
+		{"not", `[["not", [["auth.role", ">=", 50]]], ["teamId", "=", "red"]]`, member, []string{"1"}},
+		{"not denied", `[["not", [["auth.role", ">=", 50]]], ["teamId", "=", "red"]]`, admin, nil},
+		{"role name", `[["auth.role", "in", ["manager", "owner"]], ["teamId", "=", "blue"]]`, manager, []string{"2", "3"}},
+		{"role name denied", `[["auth.role", "=", "manager"]]`, member, nil},
+		{"email not verified", `[["auth.emailVerified", "=", true]]`, member, nil},
+		{"email verified", `[["auth.emailVerified", "=", true], ["public", "=", true]]`, verified, []string{"3"}},
+		{"team", `[["teamId", "in", "auth.teams"]]`, red, []string{"1"}},
+		{"without team", `[["teamId", "in", "auth.teams"]]`, member, nil},
+		{"team membership", `[["auth.teams", "containsAny", ["blue", "red"]]]`, red, []string{"1", "2", "3"}},
+		{"team membership denied", `[["auth.teams", "containsAny", ["blue"]]]`, red, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := ResolveRule(parseRule(t, tt.rule), tt.auth)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var matches []string
+			for _, doc := range docs {
+				if Match(doc, filter) {
+					matches = append(matches, doc["id"].(string))
+				}
+			}
+
+			if len(matches) != len(tt.matches) {
+				t.Fatalf("expected %v got %v", tt.matches, matches)
+			}
+			for i := range matches {
+				if matches[i] != tt.matches[i] {
+					t.Errorf("expected %v got %v", tt.matches, matches)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveRuleMembers(t *testing.T) {
+	if err := ValidateRules(model.Rules{Read: parseRule(t, `[["teamId", "in", "auth.teams"]]`)}); err != nil {
+		t.Errorf("expected auth.teams to be valid got %v", err)
+	}
+
+	// the members can also be stored in the documents
+	rule := parseRule(t, `[["members", "containsAny", ["auth.userId"]]]`)
+	filter, err := ResolveRule(rule, model.Auth{UserID: "user1"})
+	if err != nil {
+		t.Fatal(err)
+	} else if !Match(map[string]any{"members": []any{"user2", "user1"}}, filter) {
+		t.Error("expected the caller to be found in the members")
+	}
+}
+
+func TestValidateRulesRejectsInvalidRules(t *testing.T) {
+	tests := []string{
+		`[["auth.groups", "in", ["red"]]]`,
+		`[["teamId", "=", "auth.password"]]`,
+		`[["teamId", "~", "red"]]`,
+		`[["teamId", "="]]`,
+		`[["xor", [["teamId", "=", "red"]]]]`,
+		`[["or", []]]`,
+		// invalid clauses are reported even when the result is already known
+		`[["auth.role", "<", 0], ["teamId", "~", "red"]]`,
+	}
+
+	for _, s := range tests {
+		if err := ValidateRules(model.Rules{Read: parseRule(t, s)}); err == nil {
+			t.Errorf("expected an error for the read rule %s", s)
+		}
+
+		if err := ValidateRules(model.Rules{Write: parseRule(t, s)}); err == nil {
+			t.Errorf("expected an error for the write rule %s", s)
+		}
+	}
+}
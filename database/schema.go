@@ -0,0 +1,322 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+// Schema types, an empty type accepts any value
+const (
+	SchemaString  = "string"
+	SchemaNumber  = "number"
+	SchemaInteger = "integer"
+	SchemaBoolean = "boolean"
+	SchemaObject  = "object"
+	SchemaArray   = "array"
+)
+
+// fields maintained by the persisters, they are not validated
+var systemFields = map[string]bool{
+	"id":         true,
+	"_id":        true,
+	"accountId":  true,
+	"ownerId":    true,
+	FieldVersion: true,
+	FieldUpdated: true,
+}
+
+// FieldError is a field not matching the schema
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when a document does not match its
+// collection's schema
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	var msgs []string
+	for _, fe := range e.Errors {
+		msgs = append(msgs, fmt.Sprintf("%s %s", fe.Field, fe.Message))
+	}
+	return "invalid document: " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) add(field, format string, args ...any) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// IsValidationError returns true when err is a ValidationError
+func IsValidationError(err error) bool {
+	var ve *ValidationError
+	return errors.As(err, &ve)
+}
+
+// ValidateSchema checks that a schema definition is valid, a collection's
+// schema must be an object schema
+func ValidateSchema(s model.Schema) error {
+	if s.Type != SchemaObject {
+		return errors.New(`a collection's schema must have the "object" type`)
+	}
+	return validateDefinition("", &s)
+}
+
+func validateDefinition(path string, s *model.Schema) error {
+	name := path
+	if len(name) == 0 {
+		name = "schema"
+	}
+
+	switch s.Type {
+	case "", SchemaString, SchemaNumber, SchemaInteger, SchemaBoolean, SchemaObject, SchemaArray:
+	default:
+		return fmt.Errorf("%s: unknown type %q", name, s.Type)
+	}
+
+	if len(s.Properties) > 0 && s.Type != SchemaObject {
+		return fmt.Errorf("%s: properties requires the object type", name)
+	} else if s.Items != nil && s.Type != SchemaArray {
+		return fmt.Errorf("%s: items requires the array type", name)
+	} else if s.Minimum != nil && s.Maximum != nil && *s.Minimum > *s.Maximum {
+		return fmt.Errorf("%s: minimum is greater than maximum", name)
+	} else if s.MinLength != nil && s.MaxLength != nil && *s.MinLength > *s.MaxLength {
+		return fmt.Errorf("%s: minLength is greater than maxLength", name)
+	} else if s.MinItems != nil && s.MaxItems != nil && *s.MinItems > *s.MaxItems {
+		return fmt.Errorf("%s: minItems is greater than maxItems", name)
+	}
+
+	for field, prop := range s.Properties {
+		if prop == nil {
+			return fmt.Errorf("%s: property %s has no schema", name, field)
+		} else if err := validateDefinition(fieldPath(path, field), prop); err != nil {
+			return err
+		}
+	}
+
+	if s.Items != nil {
+		if err := validateDefinition(path+"[]", s.Items); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range s.Enum {
+		ve := &ValidationError{}
+		validateValue(ve, name, s, v)
+		if len(ve.Errors) > 0 {
+			return fmt.Errorf("enum value %v: %v", v, ve)
+		}
+	}
+
+	if s.Default != nil {
+		ve := &ValidationError{}
+		validateValue(ve, name, s, copyValue(s.Default))
+		if len(ve.Errors) > 0 {
+			return fmt.Errorf("default value: %v", ve)
+		}
+	}
+	return nil
+}
+
+// ApplySchema validates a document against its collection's schema. For
+// creation (partial is false) the missing fields are set to their default
+// value and the required fields are checked. For updates (partial is true)
+// only the fields present are validated.
+func ApplySchema(s model.Schema, doc map[string]any, partial bool) error {
+	ve := &ValidationError{}
+	validateObject(ve, "", &s, doc, partial)
+
+	if len(ve.Errors) > 0 {
+		return ve
+	}
+	return nil
+}
+
+func validateObject(ve *ValidationError, path string, s *model.Schema, doc map[string]any, partial bool) {
+	if !partial {
+		for field, prop := range s.Properties {
+			if _, ok := doc[field]; !ok && prop != nil && prop.Default != nil {
+				doc[field] = copyValue(prop.Default)
+			}
+		}
+	}
+
+	for _, field := range s.Required {
+		v, ok := doc[field]
+		if (!ok && !partial) || (ok && v == nil) {
+			ve.add(fieldPath(path, field), "is required")
+		}
+	}
+
+	// fields are validated in order for consistent error messages
+	var fields []string
+	for field := range doc {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		if len(path) == 0 && systemFields[field] {
+			continue
+		}
+
+		prop, ok := s.Properties[field]
+		if !ok || prop == nil {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				ve.add(fieldPath(path, field), "is not allowed")
+			}
+			continue
+		}
+
+		// a null value is the same as a missing field
+		if v := doc[field]; v != nil {
+			validateValue(ve, fieldPath(path, field), prop, v)
+		}
+	}
+}
+
+func validateValue(ve *ValidationError, path string, s *model.Schema, v any) {
+	if !matchType(s.Type, v) {
+		ve.add(path, "must be of type %s", s.Type)
+		return
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		ve.add(path, "must be one of %v", s.Enum)
+	}
+
+	switch val := v.(type) {
+	case string:
+		n := utf8.RuneCountInString(val)
+		if s.MinLength != nil && n < *s.MinLength {
+			ve.add(path, "must have at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			ve.add(path, "must have at most %d characters", *s.MaxLength)
+		}
+	case map[string]any:
+		validateObject(ve, path, s, val, false)
+	}
+
+	if f, ok := toNumber(v); ok {
+		if s.Minimum != nil && f < *s.Minimum {
+			ve.add(path, "must be greater than or equal to %v", *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			ve.add(path, "must be less than or equal to %v", *s.Maximum)
+		}
+	}
+
+	if rv := reflect.ValueOf(v); isList(rv) {
+		if s.MinItems != nil && rv.Len() < *s.MinItems {
+			ve.add(path, "must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && rv.Len() > *s.MaxItems {
+			ve.add(path, "must have at most %d items", *s.MaxItems)
+		}
+
+		if s.Items != nil {
+			for i := 0; i < rv.Len(); i++ {
+				item := rv.Index(i).Interface()
+				if item == nil {
+					continue
+				}
+				validateValue(ve, fmt.Sprintf("%s[%d]", path, i), s.Items, item)
+			}
+		}
+	}
+}
+
+func matchType(typ string, v any) bool {
+	switch typ {
+	case SchemaString:
+		switch v.(type) {
+		case string, time.Time:
+			return true
+		}
+		return false
+	case SchemaNumber:
+		_, ok := toNumber(v)
+		return ok
+	case SchemaInteger:
+		f, ok := toNumber(v)
+		return ok && f == math.Trunc(f)
+	case SchemaBoolean:
+		_, ok := v.(bool)
+		return ok
+	case SchemaObject:
+		_, ok := v.(map[string]any)
+		return ok
+	case SchemaArray:
+		return isList(reflect.ValueOf(v))
+	}
+	return true
+}
+
+func inEnum(enum []any, v any) bool {
+	for _, e := range enum {
+		if a, ok := toNumber(e); ok {
+			if b, ok := toNumber(v); ok && a == b {
+				return true
+			}
+			continue
+		}
+
+		if reflect.DeepEqual(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func toNumber(v any) (float64, bool) {
+	if _, ok := v.(string); ok {
+		return 0, false
+	}
+
+	f, kind := normalizeValue(v)
+	if kind != KindNumber {
+		return 0, false
+	}
+	return f.(float64), true
+}
+
+func isList(rv reflect.Value) bool {
+	if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+		return false
+	}
+	return rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array
+}
+
+// copyValue returns a deep copy of a default value so documents do not share
+// the schema's maps and slices
+func copyValue(v any) any {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+
+	var c any
+	if err := json.Unmarshal(b, &c); err != nil {
+		return v
+	}
+	return c
+}
+
+func fieldPath(path, field string) string {
+	if len(path) == 0 {
+		return field
+	}
+	return path + "." + field
+}
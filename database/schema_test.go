@@ -0,0 +1,115 @@
+package database
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+const taskSchema = `{
+	"type": "object",
+	"required": ["title"],
+	"additionalProperties": false,
+	"properties": {
+		"title": {"type": "string", "minLength": 3},
+		"status": {"type": "string", "enum": ["todo", "done"], "default": "todo"},
+		"priority": {"type": "integer", "minimum": 1, "maximum": 5},
+		"tags": {"type": "array", "maxItems": 2, "items": {"type": "string"}},
+		"meta": {
+			"type": "object",
+			"required": ["source"],
+			"properties": {"source": {"type": "string"}, "count": {"type": "number", "default": 0}}
+		}
+	}
+}`
+
+func parseSchema(t *testing.T, s string) model.Schema {
+	var schema model.Schema
+	if err := json.Unmarshal([]byte(s), &schema); err != nil {
+		t.Fatal(err)
+	} else if err := ValidateSchema(schema); err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+func TestApplySchemaDefaults(t *testing.T) {
+	schema := parseSchema(t, taskSchema)
+
+	doc := map[string]any{"id": "system fields are ignored", "title": "task", "meta": map[string]any{"source": "ui"}}
+	if err := ApplySchema(schema, doc, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc["status"] != "todo" {
+		t.Errorf("expected default status todo got %v", doc["status"])
+	} else if meta := doc["meta"].(map[string]any); meta["count"] != float64(0) {
+		t.Errorf("expected nested default count 0 got %v", meta["count"])
+	}
+
+	// defaults are not applied on updates
+	update := map[string]any{"priority": 2}
+	if err := ApplySchema(schema, update, true); err != nil {
+		t.Fatal(err)
+	} else if _, ok := update["status"]; ok {
+		t.Errorf("expected no default on partial update got %v", update)
+	}
+}
+
+func TestApplySchemaErrors(t *testing.T) {
+	schema := parseSchema(t, taskSchema)
+
+	tests := []struct {
+		name    string
+		doc     map[string]any
+		partial bool
+		errMsg  string
+	}{
+		{"required", map[string]any{"priority": 1}, false, "title is required"},
+		{"required on update", map[string]any{"title": nil}, true, "title is required"},
+		{"type", map[string]any{"title": 123}, false, "title must be of type string"},
+		{"min length", map[string]any{"title": "ab"}, false, "title must have at least 3 characters"},
+		{"enum", map[string]any{"title": "task", "status": "late"}, false, "status must be one of"},
+		{"integer", map[string]any{"title": "task", "priority": 2.5}, false, "priority must be of type integer"},
+		{"maximum", map[string]any{"priority": 6}, true, "priority must be less than or equal to 5"},
+		{"max items", map[string]any{"tags": []any{"a", "b", "c"}}, true, "tags must have at most 2 items"},
+		{"items", map[string]any{"tags": []any{"a", true}}, true, "tags[1] must be of type string"},
+		{"nested required", map[string]any{"meta": map[string]any{}}, true, "meta.source is required"},
+		{"additional", map[string]any{"title": "task", "other": 1}, false, "other is not allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ApplySchema(schema, tt.doc, tt.partial)
+			if !IsValidationError(err) {
+				t.Fatalf("expected a validation error got %v", err)
+			} else if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error to contain %q got %q", tt.errMsg, err.Error())
+			}
+		})
+	}
+}
+
+func TestValidateSchemaRejectsInvalidDefinitions(t *testing.T) {
+	tests := []string{
+		`{"type": "string"}`,
+		`{"type": "object", "properties": {"a": {"type": "text"}}}`,
+		`{"type": "object", "properties": {"a": {"type": "number", "minimum": 5, "maximum": 1}}}`,
+		`{"type": "object", "properties": {"a": {"type": "string", "items": {"type": "string"}}}}`,
+		`{"type": "object", "properties": {"a": {"type": "integer", "default": "one"}}}`,
+		`{"type": "object", "properties": {"a": {"type": "string", "enum": ["x", 2]}}}`,
+	}
+
+	for _, s := range tests {
+		var schema model.Schema
+		if err := json.Unmarshal([]byte(s), &schema); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ValidateSchema(schema); err == nil {
+			t.Errorf("expected an error for %s", s)
+		}
+	}
+}
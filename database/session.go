@@ -0,0 +1,18 @@
+package database
+
+import "fmt"
+
+// RefreshTokenPrefix prefixes the refresh tokens so they're distinguishable
+// from the access tokens and API keys
+const RefreshTokenPrefix = "sbr_"
+
+// NewRefreshToken returns the refresh token given to the user and the hash of
+// its secret that's stored with the session
+func NewRefreshToken(sessionID, secret string) (token, hash string) {
+	return fmt.Sprintf("%s%s.%s", RefreshTokenPrefix, sessionID, secret), HashAPIKeySecret(secret)
+}
+
+// ParseRefreshToken returns the session ID and secret of a refresh token
+func ParseRefreshToken(token string) (sessionID, secret string, ok bool) {
+	return parseSecretKey(RefreshTokenPrefix, token)
+}
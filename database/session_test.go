@@ -0,0 +1,21 @@
+package database
+
+import "testing"
+
+func TestParseRefreshToken(t *testing.T) {
+	token, hash := NewRefreshToken("session-id", "secret")
+
+	id, secret, ok := ParseRefreshToken(token)
+	if !ok {
+		t.Fatalf("expected %s to be parsed", token)
+	} else if id != "session-id" || secret != "secret" {
+		t.Errorf("expected session-id and secret got %s and %s", id, secret)
+	} else if HashAPIKeySecret(secret) != hash {
+		t.Error("expected the secret's hash to match")
+	}
+
+	key, _ := NewAPIKey("session-id", "secret")
+	if _, _, ok := ParseRefreshToken(key); ok {
+		t.Errorf("expected the API key %s not to be a refresh token", key)
+	}
+}
@@ -0,0 +1,121 @@
+package sqlite
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+func (sl *SQLite) Aggregate(auth model.Auth, dbName, col string, filters database.Filter, groupBy []string, metrics []database.Metric) (rows []map[string]any, err error) {
+	if err = database.ValidateAggregate(groupBy, metrics); err != nil {
+		return
+	}
+
+	where, filters, err := sl.secure(auth, dbName, col, false, filters)
+	if err != nil {
+		return
+	}
+
+	where, args := applyFilter(where, filters, 3)
+	args = append([]any{auth.AccountID, auth.UserID}, args...)
+
+	// groups are the JSON text of the values and sorted on the SQL values so
+	// numbers are not compared as text
+	var columns, positions, orders []string
+	for i, field := range groupBy {
+		columns = append(columns, fmt.Sprintf("COALESCE(data -> '$.%s', 'null')", field))
+		positions = append(positions, fmt.Sprintf("%d", i+1))
+		orders = append(orders, fmt.Sprintf("MIN(%s)", sortExpr(field)))
+	}
+
+	for _, metric := range metrics {
+		columns = append(columns, metricExpr(metric))
+	}
+
+	var grouping string
+	if len(positions) > 0 {
+		grouping = fmt.Sprintf(
+			"GROUP BY %s ORDER BY %s",
+			strings.Join(positions, ", "),
+			strings.Join(orders, ", "),
+		)
+	}
+
+	qry := fmt.Sprintf(`
+		SELECT %s
+		FROM %s_%s
+		%s
+		%s;
+	`, strings.Join(columns, ", "), dbName, model.CleanCollectionName(col), where, grouping)
+
+	result, err := sl.conn().Query(qry, args...)
+	if err != nil {
+		if !isTableExists(err) {
+			return nil, nil
+		}
+		return
+	}
+	defer result.Close()
+
+	for result.Next() {
+		groups := make([]string, len(groupBy))
+		values := make([]any, len(metrics))
+
+		var dest []any
+		for i := range groups {
+			dest = append(dest, &groups[i])
+		}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+
+		if err = result.Scan(dest...); err != nil {
+			return
+		}
+
+		row := make(map[string]any)
+		for i, field := range groupBy {
+			var v any
+			if err = json.Unmarshal([]byte(groups[i]), &v); err != nil {
+				return
+			}
+			row[field] = v
+		}
+
+		for i, metric := range metrics {
+			row[metric.Name] = database.MetricValue(metric.Op, values[i])
+		}
+
+		rows = append(rows, row)
+	}
+
+	err = result.Err()
+	return
+}
+
+// metricExpr returns the SQL aggregate expression for the metric, the number
+// metrics ignore values that are not a JSON number
+func metricExpr(metric database.Metric) string {
+	number := fmt.Sprintf(
+		"CASE WHEN json_type(data, '$.%s') IN ('integer', 'real') THEN json_extract(data, '$.%s') END",
+		metric.Field, metric.Field,
+	)
+
+	switch metric.Op {
+	case database.MetricCountDistinct:
+		return fmt.Sprintf("COUNT(DISTINCT NULLIF(data -> '$.%s', 'null'))", metric.Field)
+	case database.MetricSum:
+		return fmt.Sprintf("TOTAL(%s)", number)
+	case database.MetricAvg:
+		return fmt.Sprintf("AVG(%s)", number)
+	case database.MetricMin:
+		return fmt.Sprintf("MIN(%s)", number)
+	case database.MetricMax:
+		return fmt.Sprintf("MAX(%s)", number)
+	default:
+		return "COUNT(*)"
+	}
+}
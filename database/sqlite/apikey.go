@@ -0,0 +1,140 @@
+package sqlite
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+// apiKeysTable is created with the system tables and when creating keys for
+// databases created before API keys were added
+const apiKeysTable = `
+		CREATE TABLE IF NOT EXISTS {schema}_sb_api_keys (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			key_hash TEXT NOT NULL,
+			account_id TEXT REFERENCES {schema}_sb_accounts(id) ON DELETE CASCADE,
+			user_id TEXT REFERENCES {schema}_sb_tokens(id) ON DELETE CASCADE,
+			email TEXT NOT NULL,
+			scopes TEXT NOT NULL,
+			expires timestamp NULL,
+			last_used timestamp NULL,
+			created timestamp NOT NULL
+		);
+`
+
+func (sl *SQLite) CreateAPIKey(dbName string, key model.APIKey) error {
+	if _, err := sl.conn().Exec(strings.Replace(apiKeysTable, "{schema}", dbName, -1)); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return err
+	}
+
+	qry := fmt.Sprintf(`
+		INSERT INTO %s_sb_api_keys(id, name, key_hash, account_id, user_id, email, scopes, expires, created)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, dbName)
+
+	_, err = sl.conn().Exec(
+		qry,
+		key.ID,
+		key.Name,
+		key.KeyHash,
+		key.AccountID,
+		key.UserID,
+		key.Email,
+		string(b),
+		key.Expires,
+		time.Now(),
+	)
+	return err
+}
+
+func (sl *SQLite) ListAPIKeys(dbName string) (results []model.APIKey, err error) {
+	qry := fmt.Sprintf(`
+		SELECT * 
+		FROM %s_sb_api_keys
+		ORDER BY created DESC
+	`, dbName)
+
+	rows, err := sl.conn().Query(qry)
+	if err != nil {
+		if !isTableExists(err) {
+			return nil, nil
+		}
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var k model.APIKey
+		if err = scanAPIKey(rows, &k); err != nil {
+			return
+		}
+
+		results = append(results, k)
+	}
+
+	err = rows.Err()
+	return
+}
+
+func (sl *SQLite) GetAPIKey(dbName, id string) (key model.APIKey, err error) {
+	qry := fmt.Sprintf(`
+		SELECT * 
+		FROM %s_sb_api_keys
+		WHERE id = $1
+	`, dbName)
+
+	err = scanAPIKey(sl.conn().QueryRow(qry, id), &key)
+	return
+}
+
+func (sl *SQLite) RevokeAPIKey(dbName, id string) error {
+	qry := fmt.Sprintf(`
+		DELETE FROM %s_sb_api_keys
+		WHERE id = $1
+	`, dbName)
+
+	if _, err := sl.conn().Exec(qry, id); err != nil && isTableExists(err) {
+		return err
+	}
+	return nil
+}
+
+func (sl *SQLite) TouchAPIKey(dbName, id string, used time.Time) error {
+	qry := fmt.Sprintf(`
+		UPDATE %s_sb_api_keys SET
+			last_used = $2
+		WHERE id = $1
+	`, dbName)
+
+	_, err := sl.conn().Exec(qry, id, used)
+	return err
+}
+
+func scanAPIKey(rows Scanner, k *model.APIKey) error {
+	var s string
+	err := rows.Scan(
+		&k.ID,
+		&k.Name,
+		&k.KeyHash,
+		&k.AccountID,
+		&k.UserID,
+		&k.Email,
+		&s,
+		&k.Expires,
+		&k.LastUsed,
+		&k.Created,
+	)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(s), &k.Scopes)
+}
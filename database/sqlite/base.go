@@ -10,6 +10,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/staticbackendhq/core/database"
 	"github.com/staticbackendhq/core/model"
 )
 
@@ -34,6 +35,11 @@ func (j JSON) Value() (driver.Value, error) {
 }
 
 func (j *JSON) Scan(value interface{}) error {
+	// projected data are returned as text by the JSON functions
+	if s, ok := value.(string); ok {
+		value = []byte(s)
+	}
+
 	b, ok := value.([]uint8)
 	if !ok {
 		return errors.New("type assertion to []byte failed")
@@ -43,6 +49,12 @@ func (j *JSON) Scan(value interface{}) error {
 }
 
 func (sl *SQLite) CreateDocument(auth model.Auth, dbName, col string, doc map[string]interface{}) (inserted map[string]interface{}, err error) {
+	if err = sl.enforceSchema(dbName, col, doc, false); err != nil {
+		return nil, err
+	} else if err = database.CanCreate(sl, auth, dbName, col, doc); err != nil {
+		return nil, err
+	}
+
 	inserted = doc
 
 	cleancol := model.CleanCollectionName(col)
@@ -71,7 +83,7 @@ func (sl *SQLite) CreateDocument(auth model.Auth, dbName, col string, doc map[st
 		CREATE INDEX IF NOT EXISTS %s_%s_acctid_idx ON %s_%s (account_id);			
 	`, dbName, cleancol, dbName, dbName, dbName, cleancol, dbName, cleancol)
 
-		if _, err = sl.DB.Exec(qry); err != nil {
+		if _, err = sl.conn().Exec(qry); err != nil {
 			err = fmt.Errorf("error creating table: %w", err)
 			return
 		}
@@ -86,6 +98,10 @@ func (sl *SQLite) CreateDocument(auth model.Auth, dbName, col string, doc map[st
 		VALUES($1, $2, $3, $4, $5);
 	`, dbName, model.CleanCollectionName(col))
 
+	now := time.Now()
+	doc[database.FieldVersion] = 1
+	doc[database.FieldUpdated] = now
+
 	b, err := json.Marshal(doc)
 	if err != nil {
 		err = fmt.Errorf("error executing INSERT: %w", err)
@@ -95,7 +111,7 @@ func (sl *SQLite) CreateDocument(auth model.Auth, dbName, col string, doc map[st
 	// TODO: sqlite BUSY error in unit test
 	time.Sleep(10 * time.Millisecond)
 
-	_, err = sl.DB.Exec(qry, id, auth.AccountID, auth.UserID, b, time.Now())
+	_, err = sl.conn().Exec(qry, id, auth.AccountID, auth.UserID, b, now)
 	if err != nil {
 		err = fmt.Errorf("error getting the new row ID: %w", err)
 	}
@@ -111,23 +127,40 @@ func (sl *SQLite) CreateDocument(auth model.Auth, dbName, col string, doc map[st
 func (sl *SQLite) BulkCreateDocument(auth model.Auth, dbName, col string, docs []interface{}) error {
 	//TODO: Naive implementation, not sure if SQLite
 	// has a better way for bulk insert, but will suffice for now.
-	for _, doc := range docs {
-		d, ok := doc.(map[string]interface{})
-		if !ok {
-			return errors.New("unable to cast doc as map[string]interface{}")
-		}
-
-		if _, err := sl.CreateDocument(auth, dbName, col, d); err != nil {
-			return err
+	// All documents are created or none of them.
+	return sl.WithTransaction(func(tx database.Persister) error {
+		for _, doc := range docs {
+			d, ok := doc.(map[string]interface{})
+			if !ok {
+				return errors.New("unable to cast doc as map[string]interface{}")
+			}
+
+			if _, err := tx.CreateDocument(auth, dbName, col, d); err != nil {
+				return err
+			}
 		}
-	}
-	return nil
+		return nil
+	})
 }
 
 func (sl *SQLite) ListDocuments(auth model.Auth, dbName, col string, params model.ListParams) (result model.PagedResult, err error) {
-	where := secureRead(auth, col)
+	where, filters, err := sl.secure(auth, dbName, col, false, nil)
+	if err != nil {
+		return
+	}
 
-	paging := setPaging(params)
+	where, args := applyFilter(where, filters, 3)
+	args = append([]any{auth.AccountID, auth.UserID}, args...)
+
+	cond, paging, pagingArgs, err := setPaging(params, len(args)+1)
+	if err != nil {
+		return
+	}
+
+	projection, err := database.ParseFields(params.Fields)
+	if err != nil {
+		return
+	}
 
 	result.Page = params.Page
 	result.Size = params.Size
@@ -138,7 +171,7 @@ func (sl *SQLite) ListDocuments(auth model.Auth, dbName, col string, params mode
 		%s
 	`, dbName, model.CleanCollectionName(col), where)
 
-	if err = sl.DB.QueryRow(qry, auth.AccountID, auth.UserID).Scan(&result.Total); err != nil {
+	if err = sl.conn().QueryRow(qry, args...).Scan(&result.Total); err != nil {
 		if !isTableExists(err) {
 			return result, nil
 		}
@@ -146,19 +179,20 @@ func (sl *SQLite) ListDocuments(auth model.Auth, dbName, col string, params mode
 	}
 
 	qry = fmt.Sprintf(`
-		SELECT * 
+		SELECT %s
 		FROM %s_%s 
 		%s
 		%s
-	`, dbName, model.CleanCollectionName(col), where, paging)
+	`, selectColumns(projection.Keep(dataFields(params)...)), dbName, model.CleanCollectionName(col), where+cond, paging)
 
-	rows, err := sl.DB.Query(qry, auth.AccountID, auth.UserID)
+	rows, err := sl.conn().Query(qry, append(args, pagingArgs...)...)
 	if err != nil {
 		sl.log.Error().Err(err).Msg("error in select")
 		return
 	}
 	defer rows.Close()
 
+	var last Document
 	for rows.Next() {
 		var doc Document
 		if err = scanDocument(rows, &doc); err != nil {
@@ -169,17 +203,41 @@ func (sl *SQLite) ListDocuments(auth model.Auth, dbName, col string, params mode
 		doc.Data[FieldAccountID] = doc.AccountID
 
 		result.Results = append(result.Results, doc.Data)
+		last = doc
 	}
 
-	err = rows.Err()
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	if n := int64(len(result.Results)); n > 0 && n == params.Size {
+		result.Cursor = database.EncodeCursor(params, cursorValues(params, last), last.ID)
+	}
+
+	for _, doc := range result.Results {
+		projection.Apply(doc)
+	}
 	return
 }
 
-func (sl *SQLite) QueryDocuments(auth model.Auth, dbName, col string, filters map[string]interface{}, params model.ListParams) (result model.PagedResult, err error) {
-	where := secureRead(auth, col)
-	where = applyFilter(where, filters)
+func (sl *SQLite) QueryDocuments(auth model.Auth, dbName, col string, filters database.Filter, params model.ListParams) (result model.PagedResult, err error) {
+	where, filters, err := sl.secure(auth, dbName, col, false, filters)
+	if err != nil {
+		return
+	}
+
+	where, args := applyFilter(where, filters, 3)
+	args = append([]any{auth.AccountID, auth.UserID}, args...)
+
+	cond, paging, pagingArgs, err := setPaging(params, len(args)+1)
+	if err != nil {
+		return
+	}
 
-	paging := setPaging(params)
+	projection, err := database.ParseFields(params.Fields)
+	if err != nil {
+		return
+	}
 
 	result.Page = params.Page
 	result.Size = params.Size
@@ -190,7 +248,7 @@ func (sl *SQLite) QueryDocuments(auth model.Auth, dbName, col string, filters ma
 		%s
 	`, dbName, model.CleanCollectionName(col), where)
 
-	if err = sl.DB.QueryRow(qry, auth.AccountID, auth.UserID).Scan(&result.Total); err != nil {
+	if err = sl.conn().QueryRow(qry, args...).Scan(&result.Total); err != nil {
 		if !isTableExists(err) {
 			return result, nil
 		}
@@ -198,18 +256,19 @@ func (sl *SQLite) QueryDocuments(auth model.Auth, dbName, col string, filters ma
 	}
 
 	qry = fmt.Sprintf(`
-		SELECT * 
+		SELECT %s
 		FROM %s_%s 
 		%s
 		%s
-	`, dbName, model.CleanCollectionName(col), where, paging)
+	`, selectColumns(projection.Keep(dataFields(params)...)), dbName, model.CleanCollectionName(col), where+cond, paging)
 
-	rows, err := sl.DB.Query(qry, auth.AccountID, auth.UserID)
+	rows, err := sl.conn().Query(qry, append(args, pagingArgs...)...)
 	if err != nil {
 		return
 	}
 	defer rows.Close()
 
+	var last Document
 	for rows.Next() {
 		var doc Document
 		if err = scanDocument(rows, &doc); err != nil {
@@ -220,22 +279,44 @@ func (sl *SQLite) QueryDocuments(auth model.Auth, dbName, col string, filters ma
 		doc.Data[FieldAccountID] = doc.AccountID
 
 		result.Results = append(result.Results, doc.Data)
+		last = doc
 	}
 
-	err = rows.Err()
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	if n := int64(len(result.Results)); n > 0 && n == params.Size {
+		result.Cursor = database.EncodeCursor(params, cursorValues(params, last), last.ID)
+	}
+
+	for _, doc := range result.Results {
+		projection.Apply(doc)
+	}
 	return
 }
 
-func (sl *SQLite) GetDocumentByID(auth model.Auth, dbName, col, id string) (map[string]interface{}, error) {
-	where := secureRead(auth, col)
+func (sl *SQLite) GetDocumentByID(auth model.Auth, dbName, col, id string, fields ...string) (map[string]interface{}, error) {
+	where, filters, err := sl.secure(auth, dbName, col, false, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	where, args := applyFilter(where, filters, 4)
+	args = append([]any{auth.AccountID, auth.UserID, id}, args...)
+
+	projection, err := database.ParseFields(fields)
+	if err != nil {
+		return nil, err
+	}
 
 	qry := fmt.Sprintf(`
-		SELECT * 
+		SELECT %s
 		FROM %s_%s 
 		%s AND id = $3
-	`, dbName, model.CleanCollectionName(col), where)
+	`, selectColumns(projection), dbName, model.CleanCollectionName(col), where)
 
-	row := sl.DB.QueryRow(qry, auth.AccountID, auth.UserID, id)
+	row := sl.conn().QueryRow(qry, args...)
 
 	var doc Document
 	if err := scanDocument(row, &doc); err != nil {
@@ -245,19 +326,40 @@ func (sl *SQLite) GetDocumentByID(auth model.Auth, dbName, col, id string) (map[
 	doc.Data[FieldID] = doc.ID
 	doc.Data[FieldAccountID] = doc.AccountID
 
+	projection.Apply(doc.Data)
+
 	return doc.Data, nil
 }
 
-func (sl *SQLite) GetDocumentsByIDs(auth model.Auth, dbName, col string, ids []string) (docs []map[string]interface{}, err error) {
-	where := secureRead(auth, col)
+func (sl *SQLite) GetDocumentsByIDs(auth model.Auth, dbName, col string, ids []string, fields ...string) (docs []map[string]interface{}, err error) {
+	where, filters, err := sl.secure(auth, dbName, col, false, nil)
+	if err != nil {
+		return []map[string]interface{}{}, err
+	}
+
+	projection, err := database.ParseFields(fields)
+	if err != nil {
+		return []map[string]interface{}{}, err
+	}
+
+	args := []any{auth.AccountID, auth.UserID}
+
+	var placeholders []string
+	for _, id := range ids {
+		args = append(args, id)
+		placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+	}
+
+	where, ruleArgs := applyFilter(where, filters, len(args)+1)
+	args = append(args, ruleArgs...)
 
 	qry := fmt.Sprintf(`
-		SELECT * 
+		SELECT %s
 		FROM %s_%s 
-		%s AND id in ('%s')
-	`, dbName, model.CleanCollectionName(col), where, strings.Join(ids, "','"))
+		%s AND id in (%s)
+	`, selectColumns(projection), dbName, model.CleanCollectionName(col), where, strings.Join(placeholders, ", "))
 
-	rows, err := sl.DB.Query(qry, auth.AccountID, auth.UserID)
+	rows, err := sl.conn().Query(qry, args...)
 	if err != nil {
 		return []map[string]interface{}{}, err
 	}
@@ -271,6 +373,9 @@ func (sl *SQLite) GetDocumentsByIDs(auth model.Auth, dbName, col string, ids []s
 
 		doc.Data[FieldID] = doc.ID
 		doc.Data[FieldAccountID] = doc.AccountID
+
+		projection.Apply(doc.Data)
+
 		docs = append(docs, doc.Data)
 	}
 
@@ -278,16 +383,40 @@ func (sl *SQLite) GetDocumentsByIDs(auth model.Auth, dbName, col string, ids []s
 }
 
 func (sl *SQLite) UpdateDocument(auth model.Auth, dbName, col, id string, doc map[string]interface{}) (map[string]interface{}, error) {
+	expected, hasExpected, err := database.ExpectedVersion(doc)
+	if err != nil {
+		return nil, err
+	} else if err := sl.enforceSchema(dbName, col, doc, true); err != nil {
+		return nil, err
+	}
+
 	orig, err := sl.GetDocumentByID(auth, dbName, col, id)
 	if err != nil {
 		return nil, err
 	}
 
+	version := database.Version(orig)
+	if hasExpected && version != expected {
+		return nil, database.ErrVersionConflict
+	} else if err := sl.canUpdate(auth, dbName, col, []string{id}, doc); err != nil {
+		return nil, err
+	}
+
 	for key, val := range doc {
 		orig[key] = val
 	}
 
-	where := secureWrite(auth, col)
+	orig[database.FieldVersion] = version + 1
+	orig[database.FieldUpdated] = time.Now()
+
+	// the version is checked again in case the document was
+	// modified since it was read
+	where, filters, err := sl.secure(auth, dbName, col, true, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	where, args := applyFilter(where+fmt.Sprintf("AND %s = $5 ", versionExpr), filters, 6)
 
 	qry := fmt.Sprintf(`
 		UPDATE %s_%s SET
@@ -300,24 +429,43 @@ func (sl *SQLite) UpdateDocument(auth model.Auth, dbName, col, id string, doc ma
 		return nil, err
 	}
 
-	if _, err := sl.DB.Exec(qry, auth.AccountID, auth.UserID, id, b); err != nil {
+	args = append([]any{auth.AccountID, auth.UserID, id, b, version}, args...)
+
+	res, err := sl.conn().Exec(qry, args...)
+	if err != nil {
 		return nil, err
 	}
 
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		current, err := sl.GetDocumentByID(auth, dbName, col, id)
+		if err != nil {
+			return nil, err
+		} else if database.Version(current) != version {
+			return nil, database.ErrVersionConflict
+		}
+	}
+
 	updated, err := sl.GetDocumentByID(auth, dbName, col, id)
 	if err != nil {
 		fmt.Println("DEBUG: in getbyid", err)
 		return nil, err
 	}
 
-	sl.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBUpdated, updated)
+	sl.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBUpdated, database.Updated(updated, database.ChangedFields(doc)))
 
 	return updated, nil
 }
 
-func (sl *SQLite) UpdateDocuments(auth model.Auth, dbName, col string, filters map[string]interface{}, updateFields map[string]interface{}) (n int64, err error) {
-	where := secureWrite(auth, col)
-	where = applyFilter(where, filters)
+func (sl *SQLite) UpdateDocuments(auth model.Auth, dbName, col string, filters database.Filter, updateFields map[string]interface{}) (n int64, err error) {
+	where, filters, err := sl.secure(auth, dbName, col, true, filters)
+	if err != nil {
+		return
+	}
+
+	where, args := applyFilter(where, filters, 3)
+	args = append([]any{auth.AccountID, auth.UserID}, args...)
 
 	var ids []string
 	qry := fmt.Sprintf(`
@@ -326,7 +474,7 @@ func (sl *SQLite) UpdateDocuments(auth model.Auth, dbName, col string, filters m
 		%s
 	`, dbName, model.CleanCollectionName(col), where)
 
-	rows, err := sl.DB.Query(qry, auth.AccountID, auth.UserID)
+	rows, err := sl.conn().Query(qry, args...)
 	if err != nil {
 		return
 	}
@@ -344,17 +492,45 @@ func (sl *SQLite) UpdateDocuments(auth model.Auth, dbName, col string, filters m
 		return 0, nil
 	}
 
-	qry = fmt.Sprintf(`
-		UPDATE %s_%s SET
-			data = json_set(data, '$', $3)
-		%s
-	`, dbName, model.CleanCollectionName(col), where)
+	// the version is not checked for multiple documents
+	if _, _, err = database.ExpectedVersion(updateFields); err != nil {
+		return 0, err
+	} else if err = sl.enforceSchema(dbName, col, updateFields, true); err != nil {
+		return 0, err
+	} else if err = sl.canUpdate(auth, dbName, col, ids, updateFields); err != nil {
+		return 0, err
+	}
+
+	// each field is set individually, the rest of the document is kept
+	var sets []string
+	for key, val := range updateFields {
+		b, err := json.Marshal(val)
+		if err != nil {
+			return 0, err
+		}
+
+		args = append(args, jsonPath(key), string(b))
+		sets = append(sets, fmt.Sprintf("$%d, json($%d)", len(args)-1, len(args)))
+	}
 
-	b, err := json.Marshal(updateFields)
+	ts, err := json.Marshal(time.Now())
 	if err != nil {
 		return 0, err
 	}
-	res, err := sl.DB.Exec(qry, auth.AccountID, auth.UserID, b)
+
+	args = append(args, string(ts))
+	sets = append(sets,
+		fmt.Sprintf("'$.%s', %s + 1", database.FieldVersion, versionExpr),
+		fmt.Sprintf("'$.%s', json($%d)", database.FieldUpdated, len(args)),
+	)
+
+	qry = fmt.Sprintf(`
+		UPDATE %s_%s SET
+			data = json_set(data, %s)
+		%s
+	`, dbName, model.CleanCollectionName(col), strings.Join(sets, ", "), where)
+
+	res, err := sl.conn().Exec(qry, args...)
 	if err != nil {
 		return 0, err
 	}
@@ -363,15 +539,16 @@ func (sl *SQLite) UpdateDocuments(auth model.Auth, dbName, col string, filters m
 		return 0, err
 	}
 
-	go func() {
+	fields := database.ChangedFields(updateFields)
+	sl.async(func() {
 		docs, err := sl.GetDocumentsByIDs(auth, dbName, col, ids)
 		if err != nil {
 			sl.log.Error().Err(err).Msgf("the documents with ids=%s are not received for publishDocument event", ids)
 		}
 		for _, doc := range docs {
-			sl.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBUpdated, doc)
+			sl.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBUpdated, database.Updated(doc, fields))
 		}
-	}()
+	})
 	return
 }
 
@@ -400,13 +577,19 @@ func (sl *SQLite) IncrementValue(auth model.Auth, dbName, col, id, field string,
 		return err
 	}
 
-	sl.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBUpdated, doc)
+	sl.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBUpdated, database.Updated(doc, []string{field}))
 
 	return nil
 }
 
 func (sl *SQLite) DeleteDocument(auth model.Auth, dbName, col, id string) (int64, error) {
-	where := secureWrite(auth, col)
+	where, filters, err := sl.secure(auth, dbName, col, true, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	where, args := applyFilter(where, filters, 4)
+	args = append([]any{auth.AccountID, auth.UserID, id}, args...)
 
 	qry := fmt.Sprintf(`
 		DELETE 
@@ -414,7 +597,7 @@ func (sl *SQLite) DeleteDocument(auth model.Auth, dbName, col, id string) (int64
 		%s AND id = $3
 	`, dbName, model.CleanCollectionName(col), where)
 
-	res, err := sl.DB.Exec(qry, auth.AccountID, auth.UserID, id)
+	res, err := sl.conn().Exec(qry, args...)
 	if err != nil {
 		return 0, err
 	}
@@ -423,9 +606,14 @@ func (sl *SQLite) DeleteDocument(auth model.Auth, dbName, col, id string) (int64
 	return res.RowsAffected()
 }
 
-func (sl *SQLite) DeleteDocuments(auth model.Auth, dbName, col string, filters map[string]any) (n int64, err error) {
-	where := secureWrite(auth, col)
-	where = applyFilter(where, filters)
+func (sl *SQLite) DeleteDocuments(auth model.Auth, dbName, col string, filters database.Filter) (n int64, err error) {
+	where, filters, err := sl.secure(auth, dbName, col, true, filters)
+	if err != nil {
+		return
+	}
+
+	where, args := applyFilter(where, filters, 3)
+	args = append([]any{auth.AccountID, auth.UserID}, args...)
 
 	var ids []string
 	qry := fmt.Sprintf(`
@@ -434,7 +622,7 @@ func (sl *SQLite) DeleteDocuments(auth model.Auth, dbName, col string, filters m
 		%s
 	`, dbName, model.CleanCollectionName(col), where)
 
-	rows, err := sl.DB.Query(qry, auth.AccountID, auth.UserID)
+	rows, err := sl.conn().Query(qry, args...)
 	if err != nil {
 		return
 	}
@@ -456,16 +644,16 @@ func (sl *SQLite) DeleteDocuments(auth model.Auth, dbName, col string, filters m
 		%s
 	`, dbName, model.CleanCollectionName(col), where)
 
-	res, err := sl.DB.Exec(qry, auth.AccountID, auth.UserID)
+	res, err := sl.conn().Exec(qry, args...)
 	if err != nil {
 		return 0, err
 	}
 
-	go func() {
+	sl.async(func() {
 		for _, id := range ids {
 			sl.PublishDocument(auth, dbName, "db-"+col, model.MsgTypeDBDeleted, id)
 		}
-	}()
+	})
 
 	return res.RowsAffected()
 }
@@ -478,7 +666,7 @@ func (sl *SQLite) ListCollections(dbName string) (results []string, err error) {
 		ORDER BY name;
 	`, strings.ToLower(dbName)+"_%")
 
-	rows, err := sl.DB.Query(qry)
+	rows, err := sl.conn().Query(qry)
 	if err != nil {
 		return
 	}
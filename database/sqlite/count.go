@@ -3,12 +3,18 @@ package sqlite
 import (
 	"fmt"
 
+	"github.com/staticbackendhq/core/database"
 	"github.com/staticbackendhq/core/model"
 )
 
-func (sl *SQLite) Count(auth model.Auth, dbName, col string, filters map[string]interface{}) (count int64, err error) {
-	where := secureRead(auth, col)
-	where = applyFilter(where, filters)
+func (sl *SQLite) Count(auth model.Auth, dbName, col string, filters database.Filter) (count int64, err error) {
+	where, filters, err := sl.secure(auth, dbName, col, false, filters)
+	if err != nil {
+		return -1, err
+	}
+
+	where, args := applyFilter(where, filters, 3)
+	args = append([]any{auth.AccountID, auth.UserID}, args...)
 
 	query := fmt.Sprintf(`
     SELECT COUNT(*)
@@ -16,7 +22,7 @@ func (sl *SQLite) Count(auth model.Auth, dbName, col string, filters map[string]
     %s;
     `, dbName, model.CleanCollectionName(col), where)
 
-	err = sl.DB.QueryRow(query, auth.AccountID, auth.UserID).Scan(&count)
+	err = sl.conn().QueryRow(query, args...).Scan(&count)
 	if err != nil {
 		return -1, err
 	}
@@ -2,6 +2,8 @@ package sqlite
 
 import (
 	"testing"
+
+	"github.com/staticbackendhq/core/database/dbtest"
 )
 
 func TestCount(t *testing.T) {
@@ -55,3 +57,7 @@ func TestCountWithNoFilter(t *testing.T) {
 		t.Fatalf("expected 21 got %v", count)
 	}
 }
+
+func TestAggregate(t *testing.T) {
+	dbtest.Aggregate(t, datastore, adminAuth, confDBName)
+}
@@ -20,7 +20,7 @@ func (sl *SQLite) AddFormSubmission(dbName, form string, doc map[string]interfac
 		VALUES($1, $2, $3)
 	`, dbName)
 
-	if _, err := sl.DB.Exec(qry, form, jsonb, time.Now()); err != nil {
+	if _, err := sl.conn().Exec(qry, form, jsonb, time.Now()); err != nil {
 		return err
 	}
 	return nil
@@ -40,7 +40,7 @@ func (sl *SQLite) ListFormSubmissions(dbName, name string) (results []map[string
 		LIMIT 100;
 	`, dbName, where)
 
-	rows, err := sl.DB.Query(qry, name)
+	rows, err := sl.conn().Query(qry, name)
 	if err != nil {
 		return
 	}
@@ -66,7 +66,7 @@ func (sl *SQLite) GetForms(dbName string) (results []string, err error) {
 		GROUP BY name
 	`, dbName)
 
-	rows, err := sl.DB.Query(qry)
+	rows, err := sl.conn().Query(qry)
 	if err != nil {
 		return
 	}
@@ -16,7 +16,7 @@ func (sl *SQLite) AddFunction(dbName string, data model.ExecData) (id string, er
 		VALUES($1, $2, $3, $4, $5, $6, $7);
 	`, dbName)
 
-	_, err = sl.DB.Exec(
+	_, err = sl.conn().Exec(
 		qry,
 		id,
 		data.FunctionName,
@@ -36,7 +36,7 @@ func (sl *SQLite) UpdateFunction(dbName, id, code, trigger string) error {
 		WHERE id = $1 AND trigger_topic = $2
 	`, dbName)
 
-	if _, err := sl.DB.Exec(qry, id, trigger, code); err != nil {
+	if _, err := sl.conn().Exec(qry, id, trigger, code); err != nil {
 		return err
 	}
 	return nil
@@ -49,7 +49,7 @@ func (sl *SQLite) GetFunctionForExecution(dbName, name string) (result model.Exe
 		WHERE function_name = $1
 	`, dbName)
 
-	row := sl.DB.QueryRow(qry, name)
+	row := sl.conn().QueryRow(qry, name)
 
 	err = scanExecData(row, &result)
 	return
@@ -62,7 +62,7 @@ func (sl *SQLite) GetFunctionByID(dbName, id string) (result model.ExecData, err
 		WHERE id = $1
 	`, dbName)
 
-	row := sl.DB.QueryRow(qry, id)
+	row := sl.conn().QueryRow(qry, id)
 
 	err = scanExecData(row, &result)
 	if err != nil {
@@ -77,7 +77,7 @@ func (sl *SQLite) GetFunctionByID(dbName, id string) (result model.ExecData, err
 		LIMIT 50;
 	`, dbName)
 
-	rows, err := sl.DB.Query(qry, id)
+	rows, err := sl.conn().Query(qry, id)
 	if err != nil {
 		return
 	}
@@ -103,7 +103,7 @@ func (sl *SQLite) GetFunctionByName(dbName, name string) (result model.ExecData,
 		WHERE function_name = $1
 	`, dbName)
 
-	row := sl.DB.QueryRow(qry, name)
+	row := sl.conn().QueryRow(qry, name)
 
 	err = scanExecData(row, &result)
 	if err != nil {
@@ -119,7 +119,7 @@ func (sl *SQLite) GetFunctionByName(dbName, name string) (result model.ExecData,
 		LIMIT 50;
 	`, dbName)
 
-	rows, err := sl.DB.Query(qry, result.ID)
+	rows, err := sl.conn().Query(qry, result.ID)
 	if err != nil {
 		return
 	}
@@ -145,7 +145,7 @@ func (sl *SQLite) ListFunctions(dbName string) (results []model.ExecData, err er
 		ORDER BY last_updated DESC
 	`, dbName)
 
-	rows, err := sl.DB.Query(qry)
+	rows, err := sl.conn().Query(qry)
 	if err != nil {
 		return
 	}
@@ -172,7 +172,7 @@ func (sl *SQLite) ListFunctionsByTrigger(dbName, trigger string) (results []mode
 		ORDER BY last_updated DESC
 	`, dbName)
 
-	rows, err := sl.DB.Query(qry, trigger)
+	rows, err := sl.conn().Query(qry, trigger)
 	if err != nil {
 		return
 	}
@@ -197,7 +197,7 @@ func (sl *SQLite) DeleteFunction(dbName, name string) error {
 		WHERE function_name = $1
 	`, dbName)
 
-	if _, err := sl.DB.Exec(qry, name); err != nil {
+	if _, err := sl.conn().Exec(qry, name); err != nil {
 		return err
 	}
 	return nil
@@ -210,7 +210,7 @@ func (sl *SQLite) RanFunction(dbName, id string, rh model.ExecHistory) error {
 		WHERE id = $1
 	`, dbName)
 
-	if _, err := sl.DB.Exec(qry, id, time.Now()); err != nil {
+	if _, err := sl.conn().Exec(qry, id, time.Now()); err != nil {
 		return err
 	}
 
@@ -221,7 +221,7 @@ func (sl *SQLite) RanFunction(dbName, id string, rh model.ExecHistory) error {
 
 	newID := sl.NewID()
 
-	_, err := sl.DB.Exec(
+	_, err := sl.conn().Exec(
 		qry,
 		newID,
 		id,
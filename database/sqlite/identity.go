@@ -0,0 +1,132 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+// identitiesTable is created with the system tables and when linking
+// identities for databases created before identities were added
+const identitiesTable = `
+		CREATE TABLE IF NOT EXISTS {schema}_sb_identities (
+			id TEXT PRIMARY KEY,
+			account_id TEXT REFERENCES {schema}_sb_accounts(id) ON DELETE CASCADE,
+			user_id TEXT REFERENCES {schema}_sb_tokens(id) ON DELETE CASCADE,
+			provider TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			email TEXT NOT NULL,
+			linked timestamp NOT NULL,
+			UNIQUE (provider, subject)
+		);
+
+		CREATE INDEX IF NOT EXISTS {schema}_sb_identities_user_id_idx ON {schema}_sb_identities (user_id);
+`
+
+func (sl *SQLite) AddIdentity(dbName string, ident model.Identity) (id string, err error) {
+	if _, err = sl.conn().Exec(strings.Replace(identitiesTable, "{schema}", dbName, -1)); err != nil {
+		return
+	}
+
+	qry := fmt.Sprintf(`
+		INSERT INTO %s_sb_identities(id, account_id, user_id, provider, subject, email, linked)
+		VALUES($1, $2, $3, $4, $5, $6, $7)
+	`, dbName)
+
+	id = sl.NewID()
+	_, err = sl.conn().Exec(
+		qry,
+		id,
+		ident.AccountID,
+		ident.UserID,
+		ident.Provider,
+		ident.Subject,
+		ident.Email,
+		time.Now(),
+	)
+	return
+}
+
+func (sl *SQLite) FindIdentity(dbName, provider, subject string) (ident model.Identity, err error) {
+	qry := fmt.Sprintf(`
+		SELECT * 
+		FROM %s_sb_identities
+		WHERE provider = $1 AND subject = $2
+	`, dbName)
+
+	err = scanIdentity(sl.conn().QueryRow(qry, provider, subject), &ident)
+	if errors.Is(err, sql.ErrNoRows) || (err != nil && !isTableExists(err)) {
+		return model.Identity{}, nil
+	}
+	return
+}
+
+func (sl *SQLite) ListIdentities(dbName, userID string) (results []model.Identity, err error) {
+	qry := fmt.Sprintf(`
+		SELECT * 
+		FROM %s_sb_identities
+		WHERE user_id = $1
+		ORDER BY linked
+	`, dbName)
+
+	rows, err := sl.conn().Query(qry, userID)
+	if err != nil {
+		if !isTableExists(err) {
+			return nil, nil
+		}
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ident model.Identity
+		if err = scanIdentity(rows, &ident); err != nil {
+			return
+		}
+
+		results = append(results, ident)
+	}
+
+	err = rows.Err()
+	return
+}
+
+func (sl *SQLite) DeleteIdentity(dbName, userID, id string) error {
+	qry := fmt.Sprintf(`
+		DELETE FROM %s_sb_identities
+		WHERE user_id = $1 AND id = $2
+	`, dbName)
+
+	if _, err := sl.conn().Exec(qry, userID, id); err != nil && isTableExists(err) {
+		return err
+	}
+	return nil
+}
+
+func (sl *SQLite) DeleteIdentities(dbName, userID string) error {
+	qry := fmt.Sprintf(`
+		DELETE FROM %s_sb_identities
+		WHERE user_id = $1
+	`, dbName)
+
+	if _, err := sl.conn().Exec(qry, userID); err != nil && isTableExists(err) {
+		return err
+	}
+	return nil
+}
+
+func scanIdentity(rows Scanner, ident *model.Identity) error {
+	return rows.Scan(
+		&ident.ID,
+		&ident.AccountID,
+		&ident.UserID,
+		&ident.Provider,
+		&ident.Subject,
+		&ident.Email,
+		&ident.Linked,
+	)
+}
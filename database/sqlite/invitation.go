@@ -0,0 +1,116 @@
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+// invitationsTable is created with the system tables and when creating
+// invitations for databases created before invitations were added
+const invitationsTable = `
+		CREATE TABLE IF NOT EXISTS {schema}_sb_invitations (
+			id TEXT PRIMARY KEY,
+			account_id TEXT REFERENCES {schema}_sb_accounts(id) ON DELETE CASCADE,
+			email TEXT NOT NULL,
+			role INTEGER NOT NULL,
+			token_hash TEXT NOT NULL,
+			invited_by TEXT REFERENCES {schema}_sb_tokens(id) ON DELETE CASCADE,
+			created timestamp NOT NULL,
+			expires timestamp NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS {schema}_sb_invitations_account_id_idx ON {schema}_sb_invitations (account_id);
+`
+
+func (sl *SQLite) CreateInvitation(dbName string, inv model.Invitation) error {
+	if _, err := sl.conn().Exec(strings.Replace(invitationsTable, "{schema}", dbName, -1)); err != nil {
+		return err
+	}
+
+	qry := fmt.Sprintf(`
+		INSERT INTO %s_sb_invitations(id, account_id, email, role, token_hash, invited_by, created, expires)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+	`, dbName)
+
+	_, err := sl.conn().Exec(
+		qry,
+		inv.ID,
+		inv.AccountID,
+		inv.Email,
+		inv.Role,
+		inv.TokenHash,
+		inv.InvitedBy,
+		time.Now(),
+		inv.Expires,
+	)
+	return err
+}
+
+func (sl *SQLite) GetInvitation(dbName, id string) (inv model.Invitation, err error) {
+	qry := fmt.Sprintf(`
+		SELECT * 
+		FROM %s_sb_invitations
+		WHERE id = $1
+	`, dbName)
+
+	err = scanInvitation(sl.conn().QueryRow(qry, id), &inv)
+	return
+}
+
+func (sl *SQLite) ListInvitations(dbName, accountID string) (results []model.Invitation, err error) {
+	qry := fmt.Sprintf(`
+		SELECT * 
+		FROM %s_sb_invitations
+		WHERE account_id = $1
+		ORDER BY created DESC
+	`, dbName)
+
+	rows, err := sl.conn().Query(qry, accountID)
+	if err != nil {
+		if !isTableExists(err) {
+			return nil, nil
+		}
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var inv model.Invitation
+		if err = scanInvitation(rows, &inv); err != nil {
+			return
+		}
+
+		results = append(results, inv)
+	}
+
+	err = rows.Err()
+	return
+}
+
+func (sl *SQLite) DeleteInvitation(dbName, accountID, id string) error {
+	qry := fmt.Sprintf(`
+		DELETE FROM %s_sb_invitations
+		WHERE account_id = $1 AND id = $2
+	`, dbName)
+
+	if _, err := sl.conn().Exec(qry, accountID, id); err != nil && isTableExists(err) {
+		return err
+	}
+	return nil
+}
+
+func scanInvitation(rows Scanner, inv *model.Invitation) error {
+	return rows.Scan(
+		&inv.ID,
+		&inv.AccountID,
+		&inv.Email,
+		&inv.Role,
+		&inv.TokenHash,
+		&inv.InvitedBy,
+		&inv.Created,
+		&inv.Expires,
+	)
+}
@@ -15,7 +15,7 @@ func (sl *SQLite) CreateAccount(dbName, email string) (id string, err error) {
 		VALUES($1, $2, $3);
 	`, dbName)
 
-	_, err = sl.DB.Exec(qry, id, email, time.Now())
+	_, err = sl.conn().Exec(qry, id, email, time.Now())
 	return
 }
 
@@ -29,7 +29,7 @@ func (sl *SQLite) CreateUser(dbName string, tok model.User) (id string, err erro
 		VALUES($1, $2, $3, $4, $5, $6, $7, $8);
 	`, dbName)
 
-	_, err = sl.DB.Exec(
+	_, err = sl.conn().Exec(
 		qry,
 		id,
 		tok.AccountID,
@@ -51,7 +51,7 @@ func (sl *SQLite) UserEmailExists(dbName, email string) (exists bool, err error)
 	`, dbName)
 
 	var count int
-	err = sl.DB.QueryRow(qry, email).Scan(&count)
+	err = sl.conn().QueryRow(qry, email).Scan(&count)
 
 	exists = count > 0
 	return
@@ -63,7 +63,7 @@ func (sl *SQLite) SetUserRole(dbName, email string, role int) error {
 		WHERE email = $1;
 	`, dbName)
 
-	if _, err := sl.DB.Exec(qry, email, role); err != nil {
+	if _, err := sl.conn().Exec(qry, email, role); err != nil {
 		return err
 	}
 	return nil
@@ -75,12 +75,22 @@ func (sl *SQLite) UserSetPassword(dbName, tokenID, password string) error {
 		WHERE id = $1;
 	`, dbName)
 
-	if _, err := sl.DB.Exec(qry, tokenID, password); err != nil {
+	if _, err := sl.conn().Exec(qry, tokenID, password); err != nil {
 		return err
 	}
 	return nil
 }
 
+func (sl *SQLite) SetEmailVerified(dbName, userID string) error {
+	qry := fmt.Sprintf(`
+		UPDATE %s_sb_tokens SET email_verified = TRUE
+		WHERE id = $1;
+	`, dbName)
+
+	_, err := sl.conn().Exec(qry, userID)
+	return err
+}
+
 func (sl *SQLite) GetFirstUserFromAccountID(dbName, accountID string) (tok model.User, err error) {
 	qry := fmt.Sprintf(`
 		SELECT * 
@@ -90,7 +100,7 @@ func (sl *SQLite) GetFirstUserFromAccountID(dbName, accountID string) (tok model
 		LIMIT 1
 	`, dbName)
 
-	row := sl.DB.QueryRow(qry, accountID)
+	row := sl.conn().QueryRow(qry, accountID)
 
 	err = scanToken(row, &tok)
 	return
@@ -103,7 +113,7 @@ func (sl *SQLite) SetPasswordResetCode(dbName, userID, code string) error {
 	WHERE id = $1
 `, dbName)
 
-	_, err := sl.DB.Exec(qry, userID, code)
+	_, err := sl.conn().Exec(qry, userID, code)
 	if err != nil {
 		return err
 	}
@@ -117,7 +127,7 @@ func (sl *SQLite) ResetPassword(dbName, email, code, password string) error {
 		WHERE email = $1 AND reset_code = $2
 	`, dbName)
 
-	if _, err := sl.DB.Exec(qry, email, code, password); err != nil {
+	if _, err := sl.conn().Exec(qry, email, code, password); err != nil {
 		return err
 	}
 	return nil
@@ -129,7 +139,7 @@ func (sl *SQLite) RemoveUser(auth model.Auth, dbName, userID string) error {
 	WHERE account_id = $1 AND id = $2;
 	`, dbName)
 
-	if _, err := sl.DB.Exec(qry, auth.AccountID, userID); err != nil {
+	if _, err := sl.conn().Exec(qry, auth.AccountID, userID); err != nil {
 		return err
 	}
 	return nil
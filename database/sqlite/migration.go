@@ -22,6 +22,55 @@ func migrate(db *sql.DB) error {
 	if err := ensureVersion(db); err != nil {
 		return err
 	}
+
+	if err := ensureEmailVerified(db); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ensureEmailVerified adds the email_verified column to the users of the
+// databases created before it was added, SQLite has no ADD COLUMN IF NOT
+// EXISTS so the columns are checked for each database
+func ensureEmailVerified(db *sql.DB) error {
+	rows, err := db.Query(`SELECT name FROM sb_apps`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		names = append(names, name)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		var columns, found int
+		err := db.QueryRow(`
+			SELECT COUNT(*), COUNT(CASE WHEN name = 'email_verified' THEN 1 END)
+			FROM pragma_table_info($1)
+		`, name+"_sb_tokens").Scan(&columns, &found)
+		if err != nil {
+			return err
+		} else if columns == 0 || found > 0 {
+			continue
+		}
+
+		qry := fmt.Sprintf(`
+			ALTER TABLE %s_sb_tokens ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE
+		`, name)
+		if _, err := db.Exec(qry); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
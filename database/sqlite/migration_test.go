@@ -51,3 +51,40 @@ func TestMigrateUp(t *testing.T) {
 		}
 	*/
 }
+
+func TestEnsureEmailVerified(t *testing.T) {
+	qry := `
+		INSERT INTO sb_apps(id, customer_id, name, allowed_domain, is_active, monthly_email_sent, created)
+		VALUES('olddb-app', $1, 'olddb', '', true, 0, CURRENT_TIMESTAMP);
+
+		CREATE TABLE olddb_sb_tokens (
+			id TEXT PRIMARY KEY,
+			email TEXT NOT NULL
+		);
+
+		INSERT INTO olddb_sb_tokens(id, email)
+		VALUES('user1', 'old@test.com');
+	`
+	if _, err := datastore.DB.Exec(qry, dbTest.TenantID); err != nil {
+		t.Fatal(err)
+	}
+	defer datastore.DB.Exec(`
+		DELETE FROM sb_apps WHERE id = 'olddb-app';
+		DROP TABLE olddb_sb_tokens;
+	`)
+
+	// running it twice must not add the column again
+	for i := 0; i < 2; i++ {
+		if err := ensureEmailVerified(datastore.DB); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var verified bool
+	err := datastore.DB.QueryRow(`SELECT email_verified FROM olddb_sb_tokens`).Scan(&verified)
+	if err != nil {
+		t.Fatal(err)
+	} else if verified {
+		t.Error("expected the existing users not to be verified")
+	}
+}
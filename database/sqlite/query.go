@@ -1,78 +1,218 @@
 package sqlite
 
 import (
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"regexp"
 	"strings"
+	"time"
 
+	"github.com/staticbackendhq/core/database"
 	"github.com/staticbackendhq/core/internal"
 	"github.com/staticbackendhq/core/model"
+	"modernc.org/sqlite"
 )
 
-func (sl *SQLite) ParseQuery(clauses [][]interface{}) (map[string]interface{}, error) {
-	filter := make(map[string]interface{})
-
-	for i, clause := range clauses {
-		if len(clause) != 3 {
-			return filter, fmt.Errorf("the %d query clause did not contains the required 3 parameters (field, operator, value)", i+1)
+func init() {
+	// SQLite does not implement the REGEXP operator, it calls the user
+	// defined regexp(pattern, value) function
+	sqlite.MustRegisterDeterministicScalarFunction("regexp", 2, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		pattern, ok := args[0].(string)
+		if !ok {
+			return false, nil
 		}
 
-		field, ok := clause[0].(string)
+		value, ok := args[1].(string)
 		if !ok {
-			return filter, fmt.Errorf("the %d query clause's field parameter must be a string: %v", i+1, clause[0])
+			return false, nil
 		}
 
-		origField := field
+		return regexp.MatchString(pattern, value)
+	})
+}
 
-		field = fmt.Sprintf(`json_extract(data, "$.%s")`, field)
+func (sl *SQLite) ParseQuery(clauses [][]interface{}) (database.Filter, error) {
+	return database.ParseQuery(clauses)
+}
 
-		op, ok := clause[1].(string)
-		if !ok {
-			return filter, fmt.Errorf("the %d query clause's operator must be a string: %v", i+1, clause[1])
-		}
+// systemColumns are the document fields stored in their own column
+var systemColumns = map[string]string{
+	"accountId": "account_id",
+	"ownerId":   "owner_id",
+}
 
-		switch op {
-		case "=", "==":
-			filter[field+" = "] = clause[2]
-		case "!=", "<>":
-			filter[field+" != "] = clause[2]
-		case ">", "<", ">=", "<=":
-			filter[field+" "+op+" "] = clause[2]
-		case "in", "!in":
-			field = fmt.Sprintf(`EXISTS (SELECT 1 FROM json_each(json_extract(data, "$.%s")) WHERE value IN (_in_))`, origField)
-
-			if strings.HasPrefix(op, "!") {
-				field = " NOT " + field
-			}
-			filter[field+" "] = clause[2]
+// applyFilter appends the filter clauses to the where statement. Values are
+// bound as placeholders starting at $n and returned as args in order.
+func applyFilter(where string, filter database.Filter, n int) (string, []any) {
+	var args []any
+	for _, clause := range filter {
+		expr, vals := compileClause(clause, n+len(args))
+		where += " AND " + expr
+		args = append(args, vals...)
+	}
+	return where, args
+}
+
+// compileGroup returns the parenthesized SQL expression of a nested group and
+// the values to bind starting at the $n placeholder.
+func compileGroup(clause database.Clause, n int) (string, []any) {
+	var exprs []string
+	var args []any
+	for _, c := range clause.Clauses {
+		expr, vals := compileClause(c, n+len(args))
+		exprs = append(exprs, expr)
+		args = append(args, vals...)
+	}
+
+	switch clause.Group {
+	case database.GroupOr:
+		return "(" + strings.Join(exprs, " OR ") + ")", args
+	case database.GroupNot:
+		// a comparison on a missing field is NULL and so would its negation,
+		// the group is false instead so NOT matches the document
+		return "NOT COALESCE((" + strings.Join(exprs, " AND ") + "), false)", args
+	default:
+		return "(" + strings.Join(exprs, " AND ") + ")", args
+	}
+}
+
+// compileClause returns the SQL expression for a clause and the values to
+// bind starting at the $n placeholder.
+func compileClause(clause database.Clause, n int) (string, []any) {
+	if clause.IsGroup() {
+		return compileGroup(clause, n)
+	}
+
+	value := fmt.Sprintf("json_extract(data, '$.%s')", clause.Field)
+	typeOf := fmt.Sprintf("json_type(data, '$.%s')", clause.Field)
+
+	// the account and owner are stored in columns, i.e. for the rules
+	// comparing them with the caller's IDs
+	column, isColumn := systemColumns[clause.Field]
+	if isColumn {
+		value = column
+		typeOf = fmt.Sprintf("(CASE WHEN %s IS NOT NULL THEN 'text' END)", column)
+	}
+
+	switch clause.Op {
+	case database.OpExists:
+		// json_type returns 'null' for a null value and NULL for a missing path
+		return typeOf + " IS NOT NULL", nil
+	case database.OpNotExists:
+		return typeOf + " IS NULL", nil
+	case database.OpContains:
+		return fmt.Sprintf("(%s = 'text' AND instr(%s, $%d) > 0)", typeOf, value, n), []any{clause.Value}
+	case database.OpStartsWith:
+		return fmt.Sprintf("(%s = 'text' AND instr(%s, $%d) = 1)", typeOf, value, n), []any{clause.Value}
+	case database.OpEqualFold:
+		return fmt.Sprintf("(%s = 'text' AND lower(%s) = lower($%d))", typeOf, value, n), []any{clause.Value}
+	case database.OpRegex:
+		return fmt.Sprintf("(%s = 'text' AND %s REGEXP $%d)", typeOf, value, n), []any{clause.Value}
+	case database.OpBetween:
+		bounds := clause.Value.([]any)
+		args := []any{bindValue(bounds[0]), bindValue(bounds[1])}
+
+		switch clause.Kind {
+		case database.KindNumber:
+			return fmt.Sprintf("(CASE WHEN %s IN ('integer', 'real') THEN %s END) BETWEEN $%d AND $%d", typeOf, value, n, n+1), args
+		case database.KindTime:
+			return fmt.Sprintf("julianday(%s) BETWEEN julianday($%d) AND julianday($%d)", value, n, n+1), args
 		default:
-			return filter, fmt.Errorf("the %d query clause's operator: %s is not supported at the moment", i+1, op)
+			return fmt.Sprintf("%s BETWEEN $%d AND $%d", value, n, n+1), args
 		}
+	case database.OpContainsAny:
+		in, args := bindList(clause.Value.([]any), n)
+		expr := fmt.Sprintf(
+			"(%s = 'array' AND EXISTS (SELECT 1 FROM json_each(data, '$.%s') WHERE value IN (%s)))",
+			typeOf, clause.Field, in,
+		)
+		return expr, args
+	case database.OpContainsAll:
+		list := distinct(clause.Value.([]any))
+		in, args := bindList(list, n)
+		expr := fmt.Sprintf(
+			"(%s = 'array' AND (SELECT COUNT(DISTINCT value) FROM json_each(data, '$.%s') WHERE value IN (%s)) = %d)",
+			typeOf, clause.Field, in, len(list),
+		)
+		return expr, args
+	case database.OpSize:
+		expr := fmt.Sprintf("(CASE WHEN %s = 'array' THEN json_array_length(data, '$.%s') END) = $%d", typeOf, clause.Field, n)
+		return expr, []any{clause.Value}
 	}
 
-	return filter, nil
+	switch clause.Kind {
+	case database.KindNull:
+		if clause.Op == database.OpNotEqual {
+			return value + " IS NOT NULL", nil
+		}
+		return value + " IS NULL", nil
+	case database.KindList:
+		in, args := bindList(clause.Value.([]any), n)
+		expr := fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(data, '$.%s') WHERE value IN (%s))", clause.Field, in)
+		if isColumn {
+			expr = fmt.Sprintf("COALESCE(%s IN (%s), false)", column, in)
+		}
+		if clause.Op == database.OpNotIn {
+			expr = fmt.Sprintf("(%s IS NOT NULL AND NOT %s)", value, expr)
+		}
+		return expr, args
+	case database.KindNumber:
+		expr := fmt.Sprintf("(CASE WHEN %s IN ('integer', 'real') THEN %s END) %s $%d", typeOf, value, clause.Op, n)
+		return expr, []any{clause.Value}
+	case database.KindBool:
+		expr := fmt.Sprintf("(CASE WHEN %s IN ('true', 'false') THEN %s END) %s $%d", typeOf, value, clause.Op, n)
+		return expr, []any{bindValue(clause.Value)}
+	case database.KindTime:
+		expr := fmt.Sprintf("julianday(%s) %s julianday($%d)", value, clause.Op, n)
+		return expr, []any{bindValue(clause.Value)}
+	default:
+		expr := fmt.Sprintf("(CASE WHEN %s = 'text' THEN %s END) %s $%d", typeOf, value, clause.Op, n)
+		return expr, []any{clause.Value}
+	}
 }
 
-func applyFilter(where string, filters map[string]interface{}) string {
-	for field, val := range filters {
-		if s, ok := val.(string); ok {
-			s = strings.Replace(s, "'", "''", -1)
-			where += fmt.Sprintf(" AND %s '%v'", field, s)
-		} else if list, ok := val.([]string); ok {
-			//TODO: this is dirty as F, just to make the in and !in operator tests pass
-			// this will need lots of thinking and refactoring
+// bindList returns the comma separated placeholders starting at $n and the
+// values to bind for a list
+func bindList(list []any, n int) (string, []any) {
+	var placeholders []string
+	var args []any
+	for i, v := range list {
+		placeholders = append(placeholders, fmt.Sprintf("$%d", n+i))
+		args = append(args, bindValue(v))
+	}
+	return strings.Join(placeholders, ", "), args
+}
 
-			var s string
-			for _, item := range list {
-				s += fmt.Sprintf("'%s', ", strings.Replace(item, "'", "''", -1))
-			}
+// distinct removes the duplicate values of a list
+func distinct(list []any) []any {
+	seen := make(map[string]bool)
+
+	var unique []any
+	for _, v := range list {
+		key := fmt.Sprintf("%v", v)
+		if seen[key] {
+			continue
+		}
 
-			s = strings.TrimRight(s, ", ")
-			where += fmt.Sprintf(" AND %s", strings.Replace(field, "_in_", s, -1))
-		} else {
-			where += fmt.Sprintf(" AND %s %v", field, val)
+		seen[key] = true
+		unique = append(unique, v)
+	}
+	return unique
+}
+
+// bindValue converts values to the type SQLite's JSON functions return
+func bindValue(v any) any {
+	switch x := v.(type) {
+	case bool:
+		if x {
+			return 1
 		}
+		return 0
+	case time.Time:
+		return x.UTC().Format("2006-01-02T15:04:05.000Z")
 	}
-	return where
+	return v
 }
 
 func secureRead(auth model.Auth, col string) string {
@@ -111,18 +251,196 @@ func secureWrite(auth model.Auth, col string) string {
 	}
 }
 
-func setPaging(params model.ListParams) string {
-	if len(params.SortBy) == 0 {
-		params.SortBy = "created"
+// versionExpr is the version of a document, 0 when it was created before
+// versions were added
+var versionExpr = fmt.Sprintf(
+	"COALESCE(CASE WHEN json_type(data, '$.%s') = 'integer' THEN json_extract(data, '$.%s') END, 0)",
+	database.FieldVersion, database.FieldVersion,
+)
+
+// jsonPath returns the JSON path of a top level field
+func jsonPath(field string) string {
+	return `$."` + strings.ReplaceAll(field, `"`, `\"`) + `"`
+}
+
+// createdExpr is the created column without the monotonic clock reading
+// time.Time.String adds when the driver stores the time
+const createdExpr = "substr(created, 1, instr(created || ' m=', ' m=') - 1)"
+
+// sortExpr returns the SQL expression to sort on
+func sortExpr(sortBy string) string {
+	switch sortBy {
+	case database.SortCreated:
+		return createdExpr
+	case database.SortID:
+		return "id"
+	default:
+		return fmt.Sprintf("json_extract(data, '$.%s')", sortBy)
+	}
+}
+
+// setPaging returns the ORDER BY and LIMIT clauses. When the params contain a
+// cursor it also returns the keyset condition to append to the where clause
+// with its values bound starting at the $n placeholder.
+func setPaging(params model.ListParams, n int) (cond string, paging string, args []any, err error) {
+	keys, err := database.SortKeys(params)
+	if err != nil {
+		return
+	}
+
+	var orderBy []string
+	for _, key := range keys {
+		orderBy = append(orderBy, fmt.Sprintf("%s %s", sortExpr(key.Field), direction(key.Descending)))
+	}
+
+	last := keys[len(keys)-1]
+	if last.Field != database.SortID {
+		orderBy = append(orderBy, "id "+direction(last.Descending))
+	}
+
+	if len(params.Cursor) == 0 {
+		offset := (params.Page - 1) * params.Size
+		paging = fmt.Sprintf("ORDER BY %s\nLIMIT %d OFFSET %d", strings.Join(orderBy, ", "), params.Size, offset)
+		return
+	}
+
+	c, err := database.DecodeCursor(params)
+	if err != nil {
+		return
+	}
+
+	// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ... OR (k1 = v1 AND ... AND id > c.ID)
+	// missing values are sorted first
+	var or, equals []string
+	for i, key := range keys {
+		expr := sortExpr(key.Field)
+		ph := fmt.Sprintf("$%d", n+len(args))
+
+		var after, equal string
+		switch {
+		case key.Field == database.SortID:
+			args = append(args, c.ID)
+			after = fmt.Sprintf("id %s %s", comparison(key.Descending), ph)
+		case key.Field == database.SortCreated:
+			created, ok := c.Values[i].(string)
+			if !ok {
+				return "", "", nil, errors.New("invalid cursor")
+			}
+
+			t, err := time.Parse(time.RFC3339Nano, created)
+			if err != nil {
+				return "", "", nil, errors.New("invalid cursor")
+			}
+
+			// same format as the driver without the monotonic clock reading
+			args = append(args, t.In(time.Local).Format("2006-01-02 15:04:05.999999999 -0700 MST"))
+			after = fmt.Sprintf("%s %s %s", expr, comparison(key.Descending), ph)
+			equal = fmt.Sprintf("%s = %s", expr, ph)
+		case c.Values[i] == nil:
+			// nothing is sorted before missing values in descending order
+			if !key.Descending {
+				after = expr + " IS NOT NULL"
+			}
+			equal = expr + " IS NULL"
+		default:
+			args = append(args, bindValue(c.Values[i]))
+			after = fmt.Sprintf("%s %s %s", expr, comparison(key.Descending), ph)
+			if key.Descending {
+				after = fmt.Sprintf("(%s OR %s IS NULL)", after, expr)
+			}
+			equal = fmt.Sprintf("%s = %s", expr, ph)
+		}
+
+		if len(after) > 0 {
+			or = append(or, "("+strings.Join(append(equals[:len(equals):len(equals)], after), " AND ")+")")
+		}
+		equals = append(equals, equal)
+	}
+
+	if last.Field != database.SortID {
+		after := fmt.Sprintf("id %s $%d", comparison(last.Descending), n+len(args))
+		or = append(or, "("+strings.Join(append(equals, after), " AND ")+")")
+		args = append(args, c.ID)
+	}
+
+	cond = " AND (" + strings.Join(or, " OR ") + ")"
+	paging = fmt.Sprintf("ORDER BY %s\nLIMIT %d", strings.Join(orderBy, ", "), params.Size)
+	return
+}
+
+func direction(desc bool) string {
+	if desc {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+func comparison(desc bool) string {
+	if desc {
+		return "<"
+	}
+	return ">"
+}
+
+// cursorValues returns the document's values for the sort keys
+func cursorValues(params model.ListParams, doc Document) []any {
+	keys, _ := database.SortKeys(params)
+
+	var values []any
+	for _, key := range keys {
+		switch key.Field {
+		case database.SortCreated:
+			values = append(values, doc.Created)
+		case database.SortID:
+			values = append(values, nil)
+		default:
+			v, _ := database.Lookup(doc.Data, key.Field)
+			values = append(values, v)
+		}
 	}
+	return values
+}
 
-	direction := "ASC"
-	if params.SortDescending {
-		direction = "DESC"
+// dataFields returns the sort fields stored in the document's data
+func dataFields(params model.ListParams) (fields []string) {
+	keys, _ := database.SortKeys(params)
+	for _, key := range keys {
+		if key.Field != database.SortCreated && key.Field != database.SortID {
+			fields = append(fields, key.Field)
+		}
 	}
+	return
+}
 
-	orderBy := fmt.Sprintf("ORDER BY %s %s", params.SortBy, direction)
+// selectColumns returns the document columns with the data projected
+func selectColumns(p database.Projection) string {
+	data := "data"
+	if len(p.Include) > 0 {
+		// json_each returns booleans as integer
+		data = fmt.Sprintf(`
+			COALESCE((
+				SELECT json_group_object(key, CASE type WHEN 'true' THEN json('true') WHEN 'false' THEN json('false') ELSE value END)
+				FROM json_each(data)
+				WHERE key IN (%s)
+			), '{}')`,
+			quoteFields(p.Include),
+		)
+	} else if len(p.Exclude) > 0 {
+		var paths []string
+		for _, field := range p.Exclude {
+			paths = append(paths, "'$."+field+"'")
+		}
+		data = fmt.Sprintf("json_remove(data, %s)", strings.Join(paths, ", "))
+	}
+	return "id, account_id, owner_id, " + data + ", created"
+}
 
-	offset := (params.Page - 1) * params.Size
-	return fmt.Sprintf("%s\nLIMIT %d OFFSET %d", orderBy, params.Size, offset)
+// quoteFields returns the comma separated fields as SQL strings, the
+// projection fields are validated by ParseFields
+func quoteFields(fields []string) string {
+	var quoted []string
+	for _, field := range fields {
+		quoted = append(quoted, "'"+field+"'")
+	}
+	return strings.Join(quoted, ", ")
 }
@@ -0,0 +1,93 @@
+package sqlite
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+// rolesTable is created with the system tables and when saving roles for
+// databases created before named roles were added
+const rolesTable = `
+		CREATE TABLE IF NOT EXISTS {schema}_sb_roles (
+			id TEXT PRIMARY KEY,
+			name TEXT UNIQUE NOT NULL,
+			level INTEGER UNIQUE NOT NULL,
+			permissions TEXT NOT NULL,
+			created timestamp NOT NULL
+		);
+`
+
+func (sl *SQLite) ListRoles(dbName string) (results []model.Role, err error) {
+	qry := fmt.Sprintf(`
+		SELECT id, name, level, permissions, created
+		FROM %s_sb_roles
+		ORDER BY level
+	`, dbName)
+
+	rows, err := sl.conn().Query(qry)
+	if err != nil {
+		if !isTableExists(err) {
+			return nil, nil
+		}
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r model.Role
+		if err = scanRole(rows, &r); err != nil {
+			return
+		}
+
+		results = append(results, r)
+	}
+
+	err = rows.Err()
+	return
+}
+
+func (sl *SQLite) SaveRole(dbName string, role model.Role) error {
+	if _, err := sl.conn().Exec(strings.Replace(rolesTable, "{schema}", dbName, -1)); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(role.Permissions)
+	if err != nil {
+		return err
+	}
+
+	qry := fmt.Sprintf(`
+		INSERT INTO %s_sb_roles(id, name, level, permissions, created)
+		VALUES($1, $2, $3, $4, $5)
+		ON CONFLICT (name) DO UPDATE SET
+			level = excluded.level,
+			permissions = excluded.permissions
+	`, dbName)
+
+	_, err = sl.conn().Exec(qry, sl.NewID(), role.Name, role.Level, string(b), time.Now())
+	return err
+}
+
+func (sl *SQLite) DeleteRole(dbName, name string) error {
+	qry := fmt.Sprintf(`
+		DELETE FROM %s_sb_roles
+		WHERE name = $1
+	`, dbName)
+
+	if _, err := sl.conn().Exec(qry, name); err != nil && isTableExists(err) {
+		return err
+	}
+	return nil
+}
+
+func scanRole(rows Scanner, r *model.Role) error {
+	var s string
+	if err := rows.Scan(&r.ID, &r.Name, &r.Level, &s, &r.Created); err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(s), &r.Permissions)
+}
@@ -0,0 +1,167 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// rulesTable is created with the system tables and when saving rules for
+// databases created before collection rules were added
+const rulesTable = `
+		CREATE TABLE IF NOT EXISTS {schema}_sb_rules (
+			id TEXT PRIMARY KEY,
+			col TEXT UNIQUE NOT NULL,
+			rules TEXT NOT NULL,
+			updated timestamp NOT NULL
+		);
+`
+
+func (sl *SQLite) ListRules(dbName string) (results []model.CollectionRules, err error) {
+	qry := fmt.Sprintf(`
+		SELECT id, col, rules, updated
+		FROM %s_sb_rules
+		ORDER BY col
+	`, dbName)
+
+	rows, err := sl.conn().Query(qry)
+	if err != nil {
+		if !isTableExists(err) {
+			return nil, nil
+		}
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r model.CollectionRules
+		if err = scanRules(rows, &r); err != nil {
+			return
+		}
+
+		results = append(results, r)
+	}
+
+	err = rows.Err()
+	return
+}
+
+func (sl *SQLite) GetRules(dbName, col string) (rules model.CollectionRules, ok bool, err error) {
+	qry := fmt.Sprintf(`
+		SELECT id, col, rules, updated
+		FROM %s_sb_rules
+		WHERE col = $1
+	`, dbName)
+
+	err = scanRules(sl.conn().QueryRow(qry, col), &rules)
+	if errors.Is(err, sql.ErrNoRows) || (err != nil && !isTableExists(err)) {
+		return rules, false, nil
+	} else if err != nil {
+		return
+	}
+	return rules, true, nil
+}
+
+func (sl *SQLite) SaveRules(dbName string, rules model.CollectionRules) error {
+	if _, err := sl.conn().Exec(strings.Replace(rulesTable, "{schema}", dbName, -1)); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(rules.Rules)
+	if err != nil {
+		return err
+	}
+
+	qry := fmt.Sprintf(`
+		INSERT INTO %s_sb_rules(id, col, rules, updated)
+		VALUES($1, $2, $3, $4)
+		ON CONFLICT (col) DO UPDATE SET
+			rules = excluded.rules,
+			updated = excluded.updated
+	`, dbName)
+
+	_, err = sl.conn().Exec(qry, sl.NewID(), rules.Collection, string(b), time.Now())
+	return err
+}
+
+func (sl *SQLite) DeleteRules(dbName, col string) error {
+	qry := fmt.Sprintf(`
+		DELETE FROM %s_sb_rules
+		WHERE col = $1
+	`, dbName)
+
+	if _, err := sl.conn().Exec(qry, col); err != nil && isTableExists(err) {
+		return err
+	}
+	return nil
+}
+
+// secure returns the where statement and the filters securing the access to
+// a collection, its rule replaces the permissions from its name
+func (sl *SQLite) secure(auth model.Auth, dbName, col string, write bool, filters database.Filter) (string, database.Filter, error) {
+	filters, ruled, err := database.SecureFilter(sl, auth, dbName, col, write, filters)
+	if err != nil {
+		return "", nil, err
+	} else if ruled {
+		return "WHERE $1=$1 AND $2=$2 ", filters, nil
+	} else if write {
+		return secureWrite(auth, col), filters, nil
+	}
+	return secureRead(auth, col), filters, nil
+}
+
+func scanRules(rows Scanner, r *model.CollectionRules) error {
+	var rules string
+	if err := rows.Scan(&r.ID, &r.Collection, &rules, &r.Updated); err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(rules), &r.Rules)
+}
+
+// canUpdate returns database.ErrPermissionDenied when one of the documents
+// would not satisfy the collection's write rule once updated
+func (sl *SQLite) canUpdate(auth model.Auth, dbName, col string, ids []string, update map[string]any) error {
+	rule, ruled, err := database.SecureFilter(sl, auth, dbName, col, true, nil)
+	if err != nil || !ruled {
+		return err
+	}
+
+	list := make([]any, len(ids))
+	for i, id := range ids {
+		list[i] = id
+	}
+	in, args := bindList(list, 1)
+
+	qry := fmt.Sprintf(`
+		SELECT id, account_id, owner_id, data, created
+		FROM %s_%s
+		WHERE id IN (%s)
+	`, dbName, model.CleanCollectionName(col), in)
+
+	rows, err := sl.conn().Query(qry, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var doc Document
+		if err := scanDocument(rows, &doc); err != nil {
+			return err
+		}
+
+		doc.Data[FieldAccountID] = doc.AccountID
+		doc.Data["ownerId"] = doc.OwnerID
+
+		if err := database.CanUpdate(rule, doc.Data, update); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
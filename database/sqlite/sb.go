@@ -12,7 +12,7 @@ func (sl *SQLite) CreateTenant(customer model.Tenant) (c model.Tenant, err error
 	id := sl.NewID()
 	c = customer
 
-	_, err = sl.DB.Exec(`
+	_, err = sl.conn().Exec(`
 	INSERT INTO sb_customers(id, email, stripe_id, sub_id, plan, is_active, created)
 	VALUES($1, $2, $3, $4, $5, $6, $7);
 	`, id, customer.Email,
@@ -32,7 +32,7 @@ func (sl *SQLite) CreateTenant(customer model.Tenant) (c model.Tenant, err error
 func (sl *SQLite) CreateDatabase(base model.DatabaseConfig) (b model.DatabaseConfig, err error) {
 	b = base
 
-	_, err = sl.DB.Exec(`
+	_, err = sl.conn().Exec(`
 	INSERT INTO sb_apps(id, customer_id, name, allowed_domain, is_active, monthly_email_sent, created)
 	VALUES($1, $2, $3, $4, $5, $6, $7);
 	`, base.ID, base.TenantID,
@@ -66,7 +66,8 @@ func (sl *SQLite) createSystemTables(schema string) error {
 			password TEXT NOT NULL,
 			role INTEGER NOT NULL,
 			reset_code TEXT NOT NULL,
-			created timestamp NOT NULL			
+			created timestamp NOT NULL,
+			email_verified BOOLEAN NOT NULL DEFAULT FALSE
 		);
 
 		CREATE TABLE IF NOT EXISTS {schema}_sb_forms (
@@ -117,9 +118,9 @@ func (sl *SQLite) createSystemTables(schema string) error {
 			interval TEXT NOT NULL,
 			last_run timestamp NOT NULL
 		);
-	`, "{schema}", schema, -1)
+	`+schemasTable+rulesTable+rolesTable+apiKeysTable+sessionsTable+twoFactorTable+identitiesTable+invitationsTable+teamsTable, "{schema}", schema, -1)
 
-	if _, err := sl.DB.Exec(qry); err != nil {
+	if _, err := sl.conn().Exec(qry); err != nil {
 		return err
 	}
 
@@ -128,7 +129,7 @@ func (sl *SQLite) createSystemTables(schema string) error {
 
 func (sl *SQLite) EmailExists(email string) (bool, error) {
 	var count int
-	err := sl.DB.QueryRow(`
+	err := sl.conn().QueryRow(`
 		SELECT COUNT(*) FROM sb_customers WHERE email = $1
 	`, email).Scan(&count)
 	if err != nil {
@@ -138,7 +139,7 @@ func (sl *SQLite) EmailExists(email string) (bool, error) {
 }
 
 func (sl *SQLite) FindTenant(tenantID string) (customer model.Tenant, err error) {
-	row := sl.DB.QueryRow(`
+	row := sl.conn().QueryRow(`
 		SELECT * 
 		FROM sb_customers
 		WHERE id = $1
@@ -149,7 +150,7 @@ func (sl *SQLite) FindTenant(tenantID string) (customer model.Tenant, err error)
 }
 
 func (sl *SQLite) FindDatabase(baseID string) (base model.DatabaseConfig, err error) {
-	row := sl.DB.QueryRow(`
+	row := sl.conn().QueryRow(`
 		SELECT * 
 		FROM sb_apps 
 		WHERE id = $1
@@ -161,7 +162,7 @@ func (sl *SQLite) FindDatabase(baseID string) (base model.DatabaseConfig, err er
 
 func (sl *SQLite) DatabaseExists(name string) (exists bool, err error) {
 	var count int
-	err = sl.DB.QueryRow(`
+	err = sl.conn().QueryRow(`
 		SELECT COUNT(*) 
 		FROM sb_apps 
 		WHERE name = $1
@@ -172,7 +173,7 @@ func (sl *SQLite) DatabaseExists(name string) (exists bool, err error) {
 }
 
 func (sl *SQLite) ListDatabases() (results []model.DatabaseConfig, err error) {
-	rows, err := sl.DB.Query(`
+	rows, err := sl.conn().Query(`
 		SELECT * 
 		FROM sb_apps 
 		WHERE is_active = true
@@ -196,7 +197,7 @@ func (sl *SQLite) ListDatabases() (results []model.DatabaseConfig, err error) {
 }
 
 func (sl *SQLite) IncrementMonthlyEmailSent(baseID string) error {
-	_, err := sl.DB.Exec(`
+	_, err := sl.conn().Exec(`
 		UPDATE sb_apps SET monthly_email_sent = monthly_email_sent + 1
 		WHERE id = $1;
 	`, baseID)
@@ -205,7 +206,7 @@ func (sl *SQLite) IncrementMonthlyEmailSent(baseID string) error {
 }
 
 func (sl *SQLite) GetTenantByStripeID(stripeID string) (cus model.Tenant, err error) {
-	row := sl.DB.QueryRow(`
+	row := sl.conn().QueryRow(`
 		SELECT * 
 		FROM sb_customers 
 		WHERE stripe_id = $1
@@ -233,7 +234,7 @@ func (sl *SQLite) ActivateTenant(tenantID string, active bool) error {
 }
 
 func (sl *SQLite) ChangeTenantPlan(tenantID string, plan int) error {
-	if _, err := sl.DB.Exec(`UPDATE sb_customers SET plan = $2 WHERE id = $1`, tenantID, plan); err != nil {
+	if _, err := sl.conn().Exec(`UPDATE sb_customers SET plan = $2 WHERE id = $1`, tenantID, plan); err != nil {
 		return err
 	}
 	return nil
@@ -245,7 +246,7 @@ func (sl *SQLite) EnableExternalLogin(tenantID string, config map[string]model.O
 		return err
 	}
 
-	if _, err := sl.DB.Exec(`UPDATE sb_customers SET external_logins = $2 WHERE id = $1`, tenantID, b); err != nil {
+	if _, err := sl.conn().Exec(`UPDATE sb_customers SET external_logins = $2 WHERE id = $1`, tenantID, b); err != nil {
 		return err
 	}
 	return nil
@@ -266,7 +267,7 @@ func (sl *SQLite) DeleteTenant(dbName, email string) error {
 	}
 
 	for _, table := range tables {
-		if _, err := sl.DB.Exec(fmt.Sprintf("DROP TABLE %s", table)); err != nil {
+		if _, err := sl.conn().Exec(fmt.Sprintf("DROP TABLE %s", table)); err != nil {
 			return err
 		}
 	}
@@ -30,7 +30,7 @@ func (sl *SQLite) ListTasksByBase(dbName string) (results []model.Task, err erro
 		FROM %s_sb_tasks 
 	`, dbName)
 
-	rows, err := sl.DB.Query(qry)
+	rows, err := sl.conn().Query(qry)
 	if err != nil {
 		return
 	}
@@ -57,7 +57,7 @@ func (sl *SQLite) AddTask(dbName string, task model.Task) (id string, err error)
 
 	id = sl.NewID()
 
-	_, err = sl.DB.Exec(
+	_, err = sl.conn().Exec(
 		qry,
 		id,
 		task.Name,
@@ -76,7 +76,7 @@ func (sl *SQLite) DeleteTask(dbName, id string) error {
 	WHERE id = $1;
 	`, dbName)
 
-	if _, err := sl.DB.Exec(qry, id); err != nil {
+	if _, err := sl.conn().Exec(qry, id); err != nil {
 		return err
 	}
 	return nil
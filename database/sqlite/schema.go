@@ -0,0 +1,121 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// schemasTable is created with the system tables and when saving a schema
+// for databases created before collection schemas were added
+const schemasTable = `
+		CREATE TABLE IF NOT EXISTS {schema}_sb_schemas (
+			id TEXT PRIMARY KEY,
+			col TEXT UNIQUE NOT NULL,
+			definition TEXT NOT NULL,
+			updated timestamp NOT NULL
+		);
+`
+
+func (sl *SQLite) ListSchemas(dbName string) (results []model.CollectionSchema, err error) {
+	qry := fmt.Sprintf(`
+		SELECT id, col, definition, updated
+		FROM %s_sb_schemas
+		ORDER BY col
+	`, dbName)
+
+	rows, err := sl.conn().Query(qry)
+	if err != nil {
+		if !isTableExists(err) {
+			return nil, nil
+		}
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s model.CollectionSchema
+		if err = scanSchema(rows, &s); err != nil {
+			return
+		}
+
+		results = append(results, s)
+	}
+
+	err = rows.Err()
+	return
+}
+
+func (sl *SQLite) GetSchema(dbName, col string) (schema model.CollectionSchema, ok bool, err error) {
+	qry := fmt.Sprintf(`
+		SELECT id, col, definition, updated
+		FROM %s_sb_schemas
+		WHERE col = $1
+	`, dbName)
+
+	err = scanSchema(sl.conn().QueryRow(qry, col), &schema)
+	if errors.Is(err, sql.ErrNoRows) || (err != nil && !isTableExists(err)) {
+		return schema, false, nil
+	} else if err != nil {
+		return
+	}
+	return schema, true, nil
+}
+
+func (sl *SQLite) SaveSchema(dbName string, schema model.CollectionSchema) error {
+	if _, err := sl.conn().Exec(strings.Replace(schemasTable, "{schema}", dbName, -1)); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(schema.Schema)
+	if err != nil {
+		return err
+	}
+
+	qry := fmt.Sprintf(`
+		INSERT INTO %s_sb_schemas(id, col, definition, updated)
+		VALUES($1, $2, $3, $4)
+		ON CONFLICT (col) DO UPDATE SET
+			definition = excluded.definition,
+			updated = excluded.updated
+	`, dbName)
+
+	_, err = sl.conn().Exec(qry, sl.NewID(), schema.Collection, string(b), time.Now())
+	return err
+}
+
+func (sl *SQLite) DeleteSchema(dbName, col string) error {
+	qry := fmt.Sprintf(`
+		DELETE FROM %s_sb_schemas
+		WHERE col = $1
+	`, dbName)
+
+	if _, err := sl.conn().Exec(qry, col); err != nil && isTableExists(err) {
+		return err
+	}
+	return nil
+}
+
+// enforceSchema validates the document against the collection's schema
+// when it has one
+func (sl *SQLite) enforceSchema(dbName, col string, doc map[string]any, partial bool) error {
+	schema, ok, err := sl.GetSchema(dbName, col)
+	if err != nil || !ok {
+		return err
+	}
+	return database.ApplySchema(schema.Schema, doc, partial)
+}
+
+func scanSchema(rows Scanner, s *model.CollectionSchema) error {
+	var def string
+	if err := rows.Scan(&s.ID, &s.Collection, &def, &s.Updated); err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(def), &s.Schema)
+}
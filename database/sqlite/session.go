@@ -0,0 +1,147 @@
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+// sessionsTable is created with the system tables and when creating sessions
+// for databases created before sessions were added
+const sessionsTable = `
+		CREATE TABLE IF NOT EXISTS {schema}_sb_sessions (
+			id TEXT PRIMARY KEY,
+			account_id TEXT REFERENCES {schema}_sb_accounts(id) ON DELETE CASCADE,
+			user_id TEXT REFERENCES {schema}_sb_tokens(id) ON DELETE CASCADE,
+			refresh_hash TEXT NOT NULL,
+			created timestamp NOT NULL,
+			refreshed timestamp NOT NULL,
+			expires timestamp NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS {schema}_sb_sessions_user_id_idx ON {schema}_sb_sessions (user_id);
+`
+
+func (sl *SQLite) CreateSession(dbName string, s model.Session) error {
+	if _, err := sl.conn().Exec(strings.Replace(sessionsTable, "{schema}", dbName, -1)); err != nil {
+		return err
+	}
+
+	qry := fmt.Sprintf(`
+		INSERT INTO %s_sb_sessions(id, account_id, user_id, refresh_hash, created, refreshed, expires)
+		VALUES($1, $2, $3, $4, $5, $6, $7)
+	`, dbName)
+
+	now := time.Now()
+	_, err := sl.conn().Exec(
+		qry,
+		s.ID,
+		s.AccountID,
+		s.UserID,
+		s.RefreshHash,
+		now,
+		now,
+		s.Expires,
+	)
+	return err
+}
+
+func (sl *SQLite) GetSession(dbName, id string) (s model.Session, err error) {
+	qry := fmt.Sprintf(`
+		SELECT * 
+		FROM %s_sb_sessions
+		WHERE id = $1
+	`, dbName)
+
+	err = scanSession(sl.conn().QueryRow(qry, id), &s)
+	return
+}
+
+func (sl *SQLite) ListSessions(dbName, userID string) (results []model.Session, err error) {
+	qry := fmt.Sprintf(`
+		SELECT * 
+		FROM %s_sb_sessions
+		WHERE user_id = $1
+		ORDER BY created DESC
+	`, dbName)
+
+	rows, err := sl.conn().Query(qry, userID)
+	if err != nil {
+		if !isTableExists(err) {
+			return nil, nil
+		}
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s model.Session
+		if err = scanSession(rows, &s); err != nil {
+			return
+		}
+
+		results = append(results, s)
+	}
+
+	err = rows.Err()
+	return
+}
+
+func (sl *SQLite) RotateSession(dbName, id, oldHash, newHash string, expires time.Time) (bool, error) {
+	qry := fmt.Sprintf(`
+		UPDATE %s_sb_sessions SET
+			refresh_hash = $3,
+			refreshed = $4,
+			expires = $5
+		WHERE id = $1 AND refresh_hash = $2
+	`, dbName)
+
+	res, err := sl.conn().Exec(qry, id, oldHash, newHash, time.Now(), expires)
+	if err != nil {
+		if !isTableExists(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (sl *SQLite) DeleteSession(dbName, id string) error {
+	qry := fmt.Sprintf(`
+		DELETE FROM %s_sb_sessions
+		WHERE id = $1
+	`, dbName)
+
+	if _, err := sl.conn().Exec(qry, id); err != nil && isTableExists(err) {
+		return err
+	}
+	return nil
+}
+
+func (sl *SQLite) DeleteSessions(dbName, userID string) error {
+	qry := fmt.Sprintf(`
+		DELETE FROM %s_sb_sessions
+		WHERE user_id = $1
+	`, dbName)
+
+	if _, err := sl.conn().Exec(qry, userID); err != nil && isTableExists(err) {
+		return err
+	}
+	return nil
+}
+
+func scanSession(rows Scanner, s *model.Session) error {
+	return rows.Scan(
+		&s.ID,
+		&s.AccountID,
+		&s.UserID,
+		&s.RefreshHash,
+		&s.Created,
+		&s.Refreshed,
+		&s.Expires,
+	)
+}
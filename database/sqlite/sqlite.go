@@ -20,6 +20,9 @@ type SQLite struct {
 	log             *logger.Logger
 
 	collections map[string]bool
+
+	// tx is set for the persister passed to WithTransaction
+	tx *sql.Tx
 }
 
 func New(db *sql.DB, pubdoc cache.PublishDocumentEvent, log *logger.Logger) database.Persister {
@@ -56,7 +59,7 @@ func (sl *SQLite) CreateIndex(dbName, col, field string) error {
 		qry = strings.Replace(qry, "{field}", field, -1)
 		qry = strings.Replace(qry, "{schema}", dbName, -1)
 
-		if _, err := sl.DB.Exec(qry); err != nil {
+		if _, err := sl.conn().Exec(qry); err != nil {
 			return err
 		}
 	*/
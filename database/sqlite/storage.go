@@ -14,7 +14,7 @@ func (sl *SQLite) AddFile(dbName string, f model.File) (id string, err error) {
 		VALUES($1, $2, $3, $4, $5, $6);
 	`, dbName)
 
-	_, err = sl.DB.Exec(
+	_, err = sl.conn().Exec(
 		qry,
 		id,
 		f.AccountID,
@@ -33,7 +33,7 @@ func (sl *SQLite) GetFileByID(dbName, fileID string) (f model.File, err error) {
 		WHERE id = $1
 	`, dbName)
 
-	row := sl.DB.QueryRow(qry, fileID)
+	row := sl.conn().QueryRow(qry, fileID)
 
 	err = scanFile(row, &f)
 	return
@@ -46,7 +46,7 @@ func (sl *SQLite) DeleteFile(dbName, fileID string) error {
 		WHERE id = $1;
 	`, dbName)
 
-	if _, err := sl.DB.Exec(qry, fileID); err != nil {
+	if _, err := sl.conn().Exec(qry, fileID); err != nil {
 		return err
 	}
 	return nil
@@ -67,7 +67,7 @@ func (sl *SQLite) ListAllFiles(dbName, accountID string) (results []model.File,
 		%s
 	`, dbName, where)
 
-	rows, err := sl.DB.Query(qry, accountID)
+	rows, err := sl.conn().Query(qry, accountID)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,80 @@
+package sqlite
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// teamsTable is created with the system tables and when setting the teams
+// of users of databases created before teams were added
+const teamsTable = `
+		CREATE TABLE IF NOT EXISTS {schema}_sb_user_teams (
+			user_id TEXT NOT NULL,
+			team TEXT NOT NULL,
+			PRIMARY KEY (user_id, team)
+		);
+`
+
+func (sl *SQLite) ListUserTeams(dbName, userID string) (teams []string, err error) {
+	qry := fmt.Sprintf(`
+		SELECT team
+		FROM %s_sb_user_teams
+		WHERE user_id = $1
+		ORDER BY team
+	`, dbName)
+
+	rows, err := sl.conn().Query(qry, userID)
+	if err != nil {
+		if !isTableExists(err) {
+			return nil, nil
+		}
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var team string
+		if err = rows.Scan(&team); err != nil {
+			return
+		}
+
+		teams = append(teams, team)
+	}
+
+	err = rows.Err()
+	return
+}
+
+func (sl *SQLite) SetUserTeams(dbName, userID string, teams []string) error {
+	if _, err := sl.conn().Exec(strings.Replace(teamsTable, "{schema}", dbName, -1)); err != nil {
+		return err
+	}
+
+	if teams == nil {
+		teams = []string{}
+	}
+
+	b, err := json.Marshal(teams)
+	if err != nil {
+		return err
+	}
+
+	// the teams are replaced without removing the ones the user keeps
+	qry := fmt.Sprintf(`
+		DELETE FROM %s_sb_user_teams
+		WHERE user_id = $1 AND team NOT IN (SELECT value FROM json_each($2))
+	`, dbName)
+
+	if _, err := sl.conn().Exec(qry, userID, string(b)); err != nil {
+		return err
+	}
+
+	qry = fmt.Sprintf(`
+		INSERT OR IGNORE INTO %s_sb_user_teams(user_id, team)
+		SELECT $1, value FROM json_each($2)
+	`, dbName)
+
+	_, err = sl.conn().Exec(qry, userID, string(b))
+	return err
+}
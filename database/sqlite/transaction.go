@@ -0,0 +1,84 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"github.com/staticbackendhq/core/database"
+)
+
+// querier is implemented by *sql.DB and *sql.Tx so the same queries run
+// inside and outside of a transaction
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// conn returns the current transaction or the database
+func (sl *SQLite) conn() querier {
+	if sl.tx != nil {
+		return sl.tx
+	}
+	return sl.DB
+}
+
+// async runs fn in a goroutine, inside a transaction it runs before returning
+// since the transaction cannot be used once it ends
+func (sl *SQLite) async(fn func()) {
+	if sl.tx != nil {
+		fn()
+		return
+	}
+	go fn()
+}
+
+func (sl *SQLite) WithTransaction(fn func(tx database.Persister) error) (err error) {
+	// nested transactions are part of the current one
+	if sl.tx != nil {
+		return fn(sl)
+	}
+
+	tx, err := sl.DB.Begin()
+	if err != nil {
+		return
+	}
+
+	events := &database.TxEvents{}
+
+	// tables created in the transaction are only known once it's committed
+	collections := make(map[string]bool)
+	for col := range sl.collections {
+		collections[col] = true
+	}
+
+	txsl := &SQLite{
+		DB:              sl.DB,
+		PublishDocument: events.Publish,
+		log:             sl.log,
+		collections:     collections,
+		tx:              tx,
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(txsl); err != nil {
+		tx.Rollback()
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		return
+	}
+
+	for col := range collections {
+		sl.collections[col] = true
+	}
+
+	events.Flush(sl.PublishDocument)
+	return
+}
@@ -0,0 +1,135 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+// twoFactorTable is created with the system tables and when saving the
+// two-factor authentication or settings of databases created before they
+// were added
+const twoFactorTable = `
+		CREATE TABLE IF NOT EXISTS {schema}_sb_two_factor (
+			user_id TEXT PRIMARY KEY REFERENCES {schema}_sb_tokens(id) ON DELETE CASCADE,
+			secret BLOB NOT NULL,
+			enabled BOOLEAN NOT NULL,
+			recovery_codes TEXT NOT NULL,
+			last_step INTEGER NOT NULL,
+			created timestamp NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS {schema}_sb_settings (
+			id TEXT PRIMARY KEY,
+			data TEXT NOT NULL,
+			updated timestamp NOT NULL
+		);
+`
+
+func (sl *SQLite) GetTwoFactor(dbName, userID string) (tf model.TwoFactor, err error) {
+	qry := fmt.Sprintf(`
+		SELECT user_id, secret, enabled, recovery_codes, last_step, created
+		FROM %s_sb_two_factor
+		WHERE user_id = $1
+	`, dbName)
+
+	var s string
+	err = sl.conn().QueryRow(qry, userID).Scan(
+		&tf.UserID,
+		&tf.Secret,
+		&tf.Enabled,
+		&s,
+		&tf.LastStep,
+		&tf.Created,
+	)
+	if errors.Is(err, sql.ErrNoRows) || (err != nil && !isTableExists(err)) {
+		return model.TwoFactor{}, nil
+	} else if err != nil {
+		return
+	}
+
+	err = json.Unmarshal([]byte(s), &tf.RecoveryCodes)
+	return
+}
+
+func (sl *SQLite) SaveTwoFactor(dbName string, tf model.TwoFactor) error {
+	if _, err := sl.conn().Exec(strings.Replace(twoFactorTable, "{schema}", dbName, -1)); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(tf.RecoveryCodes)
+	if err != nil {
+		return err
+	}
+
+	qry := fmt.Sprintf(`
+		INSERT INTO %s_sb_two_factor(user_id, secret, enabled, recovery_codes, last_step, created)
+		VALUES($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE SET
+			secret = excluded.secret,
+			enabled = excluded.enabled,
+			recovery_codes = excluded.recovery_codes,
+			last_step = excluded.last_step
+	`, dbName)
+
+	_, err = sl.conn().Exec(qry, tf.UserID, tf.Secret, tf.Enabled, string(b), tf.LastStep, time.Now())
+	return err
+}
+
+func (sl *SQLite) DeleteTwoFactor(dbName, userID string) error {
+	qry := fmt.Sprintf(`
+		DELETE FROM %s_sb_two_factor
+		WHERE user_id = $1
+	`, dbName)
+
+	if _, err := sl.conn().Exec(qry, userID); err != nil && isTableExists(err) {
+		return err
+	}
+	return nil
+}
+
+func (sl *SQLite) GetSettings(dbName string) (s model.DatabaseSettings, err error) {
+	qry := fmt.Sprintf(`
+		SELECT data
+		FROM %s_sb_settings
+		WHERE id = 'default'
+	`, dbName)
+
+	var data string
+	err = sl.conn().QueryRow(qry).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) || (err != nil && !isTableExists(err)) {
+		return model.DatabaseSettings{}, nil
+	} else if err != nil {
+		return
+	}
+
+	err = json.Unmarshal([]byte(data), &s)
+	return
+}
+
+func (sl *SQLite) SaveSettings(dbName string, s model.DatabaseSettings) error {
+	if _, err := sl.conn().Exec(strings.Replace(twoFactorTable, "{schema}", dbName, -1)); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	qry := fmt.Sprintf(`
+		INSERT INTO %s_sb_settings(id, data, updated)
+		VALUES('default', $1, $2)
+		ON CONFLICT (id) DO UPDATE SET
+			data = excluded.data,
+			updated = excluded.updated
+	`, dbName)
+
+	_, err = sl.conn().Exec(qry, string(b), time.Now())
+	return err
+}
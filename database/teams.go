@@ -0,0 +1,50 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+// MaxUserTeams is the maximum number of teams of a user
+const MaxUserTeams = 100
+
+var validTeamName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// ValidateTeams validates the teams of a user, the names are usually the ID
+// of a team document i.e. [["teamId", "in", "auth.teams"]]
+func ValidateTeams(teams []string) error {
+	if len(teams) > MaxUserTeams {
+		return fmt.Errorf("a user cannot be in more than %d teams", MaxUserTeams)
+	}
+
+	seen := make(map[string]bool)
+	for _, team := range teams {
+		if !validTeamName.MatchString(team) {
+			return fmt.Errorf("invalid team name %q, use letters, digits, - and _", team)
+		} else if seen[team] {
+			return fmt.Errorf("duplicate team %s", team)
+		}
+
+		seen[team] = true
+	}
+	return nil
+}
+
+// WithTeams sets the teams of the caller, they're resolved when the rules
+// are evaluated since they might have changed after the caller's session was
+// created
+func WithTeams(datastore Persister, dbName string, auth model.Auth) (model.Auth, error) {
+	if len(auth.UserID) == 0 {
+		return auth, nil
+	}
+
+	teams, err := datastore.ListUserTeams(dbName, auth.UserID)
+	if err != nil {
+		return auth, err
+	}
+
+	auth.Teams = teams
+	return auth, nil
+}
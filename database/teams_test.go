@@ -0,0 +1,29 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestValidateTeams(t *testing.T) {
+	if err := ValidateTeams([]string{"red", "team_2", "5dc37900-2a2e-46d9-8a5d-6699376975ad"}); err != nil {
+		t.Errorf("expected the teams to be valid got %v", err)
+	}
+
+	var tooMany []string
+	for i := 0; i <= MaxUserTeams; i++ {
+		tooMany = append(tooMany, fmt.Sprintf("team-%d", i))
+	}
+
+	invalid := [][]string{
+		{"no spaces"},
+		{""},
+		{"red", "red"},
+		tooMany,
+	}
+	for _, teams := range invalid {
+		if err := ValidateTeams(teams); err == nil {
+			t.Errorf("expected an error for %v", teams)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"sync"
+
+	"github.com/staticbackendhq/core/model"
+)
+
+type txEvent struct {
+	auth    model.Auth
+	dbName  string
+	channel string
+	typ     string
+	v       interface{}
+}
+
+// TxEvents holds the document events published during a transaction. They
+// are only published once the transaction is committed and dropped on
+// rollback.
+type TxEvents struct {
+	mu     sync.Mutex
+	events []txEvent
+}
+
+// Publish queues a document event, it has the same signature as the
+// persisters' PublishDocument
+func (e *TxEvents) Publish(auth model.Auth, dbName, channel, typ string, v interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.events = append(e.events, txEvent{auth, dbName, channel, typ, v})
+}
+
+// Reset drops the queued events, used when a transaction is retried
+func (e *TxEvents) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.events = nil
+}
+
+// Flush publishes the queued events in order
+func (e *TxEvents) Flush(publish func(auth model.Auth, dbName, channel, typ string, v interface{})) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, ev := range e.events {
+		publish(ev.auth, ev.dbName, ev.channel, ev.typ, ev.v)
+	}
+	e.events = nil
+}
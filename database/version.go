@@ -0,0 +1,74 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// Fields maintained on every document. The version starts at 1 and is
+// incremented on each update, documents created before versions were added
+// have version 0.
+const (
+	FieldVersion = "version"
+	FieldUpdated = "updated"
+)
+
+// ErrVersionConflict is returned when updating a document with an expected
+// version that does not match its current version
+var ErrVersionConflict = errors.New("the document was modified, its version does not match")
+
+// Version returns the version of the document, 0 when it has none
+func Version(doc map[string]any) int64 {
+	v, _ := toVersion(doc[FieldVersion])
+	return v
+}
+
+// ExpectedVersion removes the version and updated fields from the update and
+// returns the expected version when the update has one
+func ExpectedVersion(doc map[string]any) (version int64, ok bool, err error) {
+	v, exists := doc[FieldVersion]
+
+	delete(doc, FieldVersion)
+	delete(doc, FieldUpdated)
+
+	if !exists || v == nil {
+		return 0, false, nil
+	}
+
+	version, ok = toVersion(v)
+	if !ok {
+		return 0, false, fmt.Errorf("invalid version: %v", v)
+	}
+	return version, true, nil
+}
+
+// ParseVersion parses a version, as sent in an ETag or If-Match header
+func ParseVersion(s string) (int64, error) {
+	v, ok := toVersion(s)
+	if !ok {
+		return 0, fmt.Errorf("invalid version: %s", s)
+	}
+	return v, nil
+}
+
+func toVersion(v any) (int64, bool) {
+	if s, ok := v.(string); ok {
+		n, err := strconv.ParseInt(s, 10, 64)
+		return n, err == nil && n >= 0
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), rv.Int() >= 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		return int64(f), f >= 0 && f == math.Trunc(f)
+	}
+	return 0, false
+}
@@ -3,6 +3,8 @@ package staticbackend
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -10,6 +12,7 @@ import (
 
 	"github.com/staticbackendhq/core/backend"
 	"github.com/staticbackendhq/core/cache"
+	"github.com/staticbackendhq/core/database"
 	"github.com/staticbackendhq/core/logger"
 	"github.com/staticbackendhq/core/middleware"
 	"github.com/staticbackendhq/core/model"
@@ -82,7 +85,7 @@ func (database *Database) add(w http.ResponseWriter, r *http.Request) {
 
 	doc, err = backend.DB.CreateDocument(auth, conf.Name, col, doc)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeDBError(w, err)
 		return
 	}
 
@@ -105,7 +108,7 @@ func (database *Database) bulkAdd(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := backend.DB.BulkCreateDocument(auth, conf.Name, col, v); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeDBError(w, err)
 		return
 	}
 
@@ -118,7 +121,22 @@ func (database *Database) list(w http.ResponseWriter, r *http.Request) {
 	params := model.ListParams{
 		Page:           page,
 		Size:           size,
+		SortBy:         r.URL.Query().Get("sort"),
 		SortDescending: len(r.URL.Query().Get("desc")) > 0,
+		Sort:           getSortKeys(r.URL),
+		Fields:         getFields(r.URL),
+		Cursor:         r.URL.Query().Get("cursor"),
+	}
+
+	if err := validateListParams(params); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	expansions, err := getExpand(r.URL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	conf, auth, err := middleware.Extract(r, true)
@@ -135,6 +153,11 @@ func (database *Database) list(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := expand(auth, conf.Name, result.Results, expansions); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	respond(w, http.StatusOK, result)
 }
 
@@ -172,6 +195,52 @@ func (database *Database) count(w http.ResponseWriter, r *http.Request) {
 	respond(w, http.StatusOK, map[string]int64{"count": result})
 }
 
+// AggregateData is the body of an aggregation request
+type AggregateData struct {
+	Filter  [][]interface{}   `json:"filter"`
+	GroupBy []string          `json:"groupBy"`
+	Metrics []database.Metric `json:"metrics"`
+}
+
+func (database *Database) aggregate(w http.ResponseWriter, r *http.Request) {
+	var data AggregateData
+	if err := parseBody(r.Body, &data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter, err := backend.DB.ParseQuery(data.Filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateAggregate(data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	conf, auth, err := middleware.Extract(r, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	col := getURLPart(r.URL.Path, 3)
+
+	rows, err := backend.DB.Aggregate(auth, conf.Name, col, filter, data.GroupBy, data.Metrics)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if rows == nil {
+		rows = []map[string]any{}
+	}
+
+	respond(w, http.StatusOK, rows)
+}
+
 func (database *Database) get(w http.ResponseWriter, r *http.Request) {
 	conf, auth, err := middleware.Extract(r, true)
 	if err != nil {
@@ -182,12 +251,31 @@ func (database *Database) get(w http.ResponseWriter, r *http.Request) {
 	col := getURLPart(r.URL.Path, 2)
 	id := getURLPart(r.URL.Path, 3)
 
-	result, err := backend.DB.GetDocumentByID(auth, conf.Name, col, id)
+	fields := getFields(r.URL)
+	if err := validateFields(fields); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	expansions, err := getExpand(r.URL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := backend.DB.GetDocumentByID(auth, conf.Name, col, id, fields...)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	if err := expand(auth, conf.Name, []map[string]any{result}, expansions); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	setETag(w, result)
+
 	respond(w, http.StatusOK, result)
 }
 
@@ -215,6 +303,20 @@ func (database *Database) query(w http.ResponseWriter, r *http.Request) {
 		Size:           size,
 		SortBy:         sort,
 		SortDescending: len(r.URL.Query().Get("desc")) > 0,
+		Sort:           getSortKeys(r.URL),
+		Fields:         getFields(r.URL),
+		Cursor:         r.URL.Query().Get("cursor"),
+	}
+
+	if err := validateListParams(params); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	expansions, err := getExpand(r.URL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	conf, auth, err := middleware.Extract(r, true)
@@ -232,6 +334,11 @@ func (database *Database) query(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := expand(auth, conf.Name, result.Results, expansions); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	respond(w, http.StatusOK, result)
 }
 
@@ -255,7 +362,13 @@ func (database *Database) getByIds(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := backend.DB.GetDocumentsByIDs(auth, conf.Name, col, ids)
+	fields := getFields(r.URL)
+	if err := validateFields(fields); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := backend.DB.GetDocumentsByIDs(auth, conf.Name, col, ids, fields...)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -286,12 +399,19 @@ func (database *Database) update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := setExpectedVersion(doc, r.Header.Get("If-Match")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	result, err := backend.DB.UpdateDocument(auth, conf.Name, col, id, doc)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeDBError(w, err)
 		return
 	}
 
+	setETag(w, result)
+
 	respond(w, http.StatusOK, result)
 }
 
@@ -321,7 +441,7 @@ func (database *Database) bulkUpdate(w http.ResponseWriter, r *http.Request) {
 
 	count, err := backend.DB.UpdateDocuments(auth, conf.Name, col, filter, v.UpdateFields)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeDBError(w, err)
 		return
 	}
 
@@ -479,6 +599,115 @@ func getPagination(u *url.URL) (page int64, size int64) {
 	return
 }
 
+// getSortKeys returns the sort keys when sort is a comma separated list of
+// fields, a field prefixed with a - is sorted descending: sort=-likes,title
+func getSortKeys(u *url.URL) (keys []model.SortKey) {
+	sort := u.Query().Get("sort")
+	if !strings.Contains(sort, ",") && !strings.HasPrefix(sort, "-") {
+		// a single field uses the sort and desc parameters
+		return nil
+	}
+
+	for _, field := range strings.Split(sort, ",") {
+		field = strings.TrimSpace(field)
+		keys = append(keys, model.SortKey{
+			Field:      strings.TrimPrefix(field, "-"),
+			Descending: strings.HasPrefix(field, "-"),
+		})
+	}
+	return
+}
+
+// getFields returns the comma separated projection fields: fields=title,likes
+func getFields(u *url.URL) (fields []string) {
+	for _, field := range strings.Split(u.Query().Get("fields"), ",") {
+		if field = strings.TrimSpace(field); len(field) > 0 {
+			fields = append(fields, field)
+		}
+	}
+	return
+}
+
+// getExpand returns the references to expand from the expand parameters, in
+// the field:collection format
+func getExpand(u *url.URL) ([]database.Expansion, error) {
+	return database.ParseExpand(u.Query()["expand"])
+}
+
+// expand replaces the references of the documents by the referenced documents
+// the user can read
+func expand(auth model.Auth, dbName string, docs []map[string]any, expansions []database.Expansion) error {
+	return database.Expand(backend.DB, auth, dbName, docs, expansions)
+}
+
+// validateFields returns an error if the projection is invalid
+func validateFields(fields []string) error {
+	_, err := database.ParseFields(fields)
+	return err
+}
+
+// validateAggregate returns an error if the group by fields or metrics are invalid
+func validateAggregate(data AggregateData) error {
+	return database.ValidateAggregate(data.GroupBy, data.Metrics)
+}
+
+// validateListParams returns an error if the sort keys, the projection or
+// the cursor are invalid
+func validateListParams(params model.ListParams) error {
+	if _, err := database.SortKeys(params); err != nil {
+		return err
+	} else if err := validateFields(params.Fields); err != nil {
+		return err
+	} else if len(params.Cursor) == 0 {
+		return nil
+	}
+
+	_, err := database.DecodeCursor(params)
+	return err
+}
+
+// setExpectedVersion sets the version the update expects from the If-Match
+// header, it takes precedence over a version in the body
+func setExpectedVersion(doc map[string]any, etag string) error {
+	if len(etag) == 0 {
+		return nil
+	}
+
+	etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+
+	version, err := database.ParseVersion(strings.Trim(etag, `"`))
+	if err != nil {
+		return err
+	}
+
+	doc[database.FieldVersion] = version
+	return nil
+}
+
+// setETag sets the ETag header to the document's version
+func setETag(w http.ResponseWriter, doc map[string]any) {
+	if _, ok := doc[database.FieldVersion]; !ok {
+		return
+	}
+
+	w.Header().Set("ETag", fmt.Sprintf(`"%d"`, database.Version(doc)))
+}
+
+// writeDBError responds with the status matching the persister's error, 400
+// for documents not matching their schema and 409 for version conflicts
+func writeDBError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if database.IsValidationError(err) {
+		status = http.StatusBadRequest
+	} else if errors.Is(err, database.ErrVersionConflict) {
+		status = http.StatusConflict
+	} else if errors.Is(err, database.ErrPermissionDenied) {
+		status = http.StatusForbidden
+	}
+
+	http.Error(w, err.Error(), status)
+}
+
 type SearchData struct {
 	Col      string `json:"col"`
 	Keywords string `json:"keywords"`
@@ -10,10 +10,12 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/staticbackendhq/core/backend"
+	"github.com/staticbackendhq/core/database"
 	"github.com/staticbackendhq/core/internal"
 	"github.com/staticbackendhq/core/middleware"
 	"github.com/staticbackendhq/core/model"
@@ -82,6 +84,36 @@ func dbReq(t *testing.T, hf func(http.ResponseWriter, *http.Request), method, pa
 	return w.Result()
 }
 
+// userReq sends a JSON request as the non-root test user, the extra
+// middlewares are chained after the authentication
+func userReq(t *testing.T, hf func(http.ResponseWriter, *http.Request), method, path string, v interface{}, mws ...middleware.Middleware) *http.Response {
+	return tokenReq(t, hf, method, path, userToken, v, mws...)
+}
+
+// tokenReq sends a JSON request authenticated by a session token or an API key
+func tokenReq(t *testing.T, hf func(http.ResponseWriter, *http.Request), method, path, token string, v interface{}, mws ...middleware.Middleware) *http.Response {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal("error marshaling post data:", err)
+	}
+
+	req := httptest.NewRequest(method, path, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("SB-PUBLIC-KEY", pubKey)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	stdAuth := []middleware.Middleware{
+		middleware.WithDB(backend.DB, backend.Cache, getStripePortalURL),
+		middleware.RequireAuth(backend.DB, backend.Cache),
+	}
+
+	w := httptest.NewRecorder()
+	h := middleware.Chain(http.HandlerFunc(hf), append(stdAuth, mws...)...)
+	h.ServeHTTP(w, req)
+
+	return w.Result()
+}
+
 func GetResponseBody(t *testing.T, resp *http.Response) string {
 	defer resp.Body.Close()
 	b, err := ioutil.ReadAll(resp.Body)
@@ -196,6 +228,124 @@ func TestDBUpdateShouldNotOverwriteDoc(t *testing.T) {
 	}
 }
 
+func TestDBUpdateIfMatch(t *testing.T) {
+	resp := dbReq(t, db.add, "POST", "/db/versions", Task{Title: "versioned"})
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+
+	var saved Task
+	if err := parseBody(resp.Body, &saved); err != nil {
+		t.Fatal(err)
+	}
+
+	resp = dbReq(t, db.get, "GET", "/db/versions/"+saved.ID, nil)
+	defer resp.Body.Close()
+
+	if etag := resp.Header.Get("ETag"); etag != `"1"` {
+		t.Fatalf("expected ETag \"1\" got %s", etag)
+	}
+
+	ifMatch := func(etag string) func(http.ResponseWriter, *http.Request) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			r.Header.Set("If-Match", etag)
+			db.update(w, r)
+		}
+	}
+
+	update := map[string]any{"done": true}
+
+	resp = dbReq(t, ifMatch(`"1"`), "PUT", "/db/versions/"+saved.ID, update)
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	} else if etag := resp.Header.Get("ETag"); etag != `"2"` {
+		t.Errorf("expected ETag \"2\" got %s", etag)
+	}
+
+	// the document is now at version 2
+	resp = dbReq(t, ifMatch(`W/"1"`), "PUT", "/db/versions/"+saved.ID, update)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected status 409 got %s", resp.Status)
+	}
+
+	update[database.FieldVersion] = 1
+
+	resp = dbReq(t, db.update, "PUT", "/db/versions/"+saved.ID, update)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected status 409 with the version in the body got %s", resp.Status)
+	}
+
+	resp = dbReq(t, ifMatch("abc"), "PUT", "/db/versions/"+saved.ID, update)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 got %s", resp.Status)
+	}
+}
+
+func TestDBSchemaValidation(t *testing.T) {
+	schema := model.CollectionSchema{
+		Collection: "schematasks",
+		Schema: model.Schema{
+			Type:     database.SchemaObject,
+			Required: []string{"title"},
+			Properties: map[string]*model.Schema{
+				"title": {Type: database.SchemaString},
+				"done":  {Type: database.SchemaBoolean, Default: false},
+			},
+		},
+	}
+	if err := backend.DB.SaveSchema(dbName, schema); err != nil {
+		t.Fatal(err)
+	}
+	defer backend.DB.DeleteSchema(dbName, schema.Collection)
+
+	resp := dbReq(t, db.add, "POST", "/db/schematasks", map[string]any{"done": true})
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected status 400 got %s", resp.Status)
+	} else if body := GetResponseBody(t, resp); !strings.Contains(body, "title is required") {
+		t.Errorf("expected the error to name the field got %s", body)
+	}
+
+	resp = dbReq(t, db.add, "POST", "/db/schematasks", map[string]any{"title": "valid"})
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+
+	var saved Task
+	if err := parseBody(resp.Body, &saved); err != nil {
+		t.Fatal(err)
+	}
+
+	resp = dbReq(t, db.update, "PUT", "/db/schematasks/"+saved.ID, map[string]any{"done": "yes"})
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 got %s", resp.Status)
+	}
+
+	bulk := []any{map[string]any{"title": "bulk"}, map[string]any{"title": 1}}
+
+	resp = dbReq(t, db.bulkAdd, "POST", "/db/schematasks?bulk=1", bulk)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 got %s", resp.Status)
+	}
+}
+
 func TestDBListCollections(t *testing.T) {
 	req := httptest.NewRequest("GET", "/sudolistall", nil)
 	w := httptest.NewRecorder()
@@ -402,6 +552,202 @@ func TestDBGetByIds(t *testing.T) {
 	}
 }
 
+func TestDBExpand(t *testing.T) {
+	resp := dbReq(t, db.add, "POST", "/db/expandusers", map[string]any{"name": "expanded author"})
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+
+	var author map[string]any
+	if err := parseBody(resp.Body, &author); err != nil {
+		t.Fatal(err)
+	}
+
+	post := map[string]any{"title": "expanded post", "author": author["id"]}
+	resp = dbReq(t, db.add, "POST", "/db/expandposts", post)
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+
+	var saved map[string]any
+	if err := parseBody(resp.Body, &saved); err != nil {
+		t.Fatal(err)
+	}
+
+	resp = dbReq(t, db.list, "GET", "/db/expandposts?expand=author:expandusers", nil)
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+
+	var result model.PagedResult
+	if err := parseBody(resp.Body, &result); err != nil {
+		t.Fatal(err)
+	} else if len(result.Results) != 1 {
+		t.Fatalf("expected 1 post got %d", len(result.Results))
+	} else if expanded, ok := result.Results[0]["author"].(map[string]any); !ok || expanded["name"] != "expanded author" {
+		t.Errorf("expected the author to be expanded got %v", result.Results[0]["author"])
+	}
+
+	resp = dbReq(t, db.get, "GET", fmt.Sprintf("/db/expandposts/%v?expand=author:expandusers", saved["id"]), nil)
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+
+	var doc map[string]any
+	if err := parseBody(resp.Body, &doc); err != nil {
+		t.Fatal(err)
+	} else if expanded, ok := doc["author"].(map[string]any); !ok || expanded["id"] != author["id"] {
+		t.Errorf("expected the author to be expanded got %v", doc["author"])
+	}
+
+	resp = dbReq(t, db.list, "GET", "/db/expandposts?expand=author:sb_accounts", nil)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 got %s", resp.Status)
+	}
+}
+
+func TestDBRules(t *testing.T) {
+	rules := model.CollectionRules{
+		Collection: "ruledtasks",
+		Rules: model.Rules{
+			Write: [][]any{{"auth.role", ">=", 50}},
+		},
+	}
+	if err := backend.DB.SaveRules(dbName, rules); err != nil {
+		t.Fatal(err)
+	}
+	defer backend.DB.DeleteRules(dbName, rules.Collection)
+
+	// root bypasses the rules
+	resp := dbReq(t, db.add, "POST", "/db/ruledtasks", Task{Title: "by admin"})
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+
+	resp = userReq(t, db.add, "POST", "/db/ruledtasks", Task{Title: "by user"})
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected status 403 got %s", GetResponseBody(t, resp))
+	}
+}
+
+func TestDBListFieldsAndSort(t *testing.T) {
+	tasks := []Task{
+		{Title: "fields b", Done: true, Count: 1},
+		{Title: "fields a", Done: false, Count: 2},
+		{Title: "fields c", Done: true, Count: 3},
+	}
+
+	for _, task := range tasks {
+		resp := dbReq(t, db.add, "POST", "/db/fieldsort", task)
+		defer resp.Body.Close()
+
+		if resp.StatusCode > 299 {
+			t.Fatal(GetResponseBody(t, resp))
+		}
+	}
+
+	resp := dbReq(t, db.list, "GET", "/db/fieldsort?sort=-done,title&fields=title", nil)
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+
+	var result model.PagedResult
+	if err := parseBody(resp.Body, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	var titles []string
+	for _, doc := range result.Results {
+		if _, ok := doc["count"]; ok {
+			t.Errorf("expected count to not be returned got %v", doc)
+		}
+
+		titles = append(titles, fmt.Sprintf("%v", doc["title"]))
+	}
+
+	expected := []string{"fields b", "fields c", "fields a"}
+	if !reflect.DeepEqual(expected, titles) {
+		t.Errorf("expected %v got %v", expected, titles)
+	}
+
+	resp2 := dbReq(t, db.list, "GET", "/db/fieldsort?fields=title,-count", nil)
+	defer resp2.Body.Close()
+
+	if resp2.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 got %s", resp2.Status)
+	}
+}
+
+func TestDBAggregate(t *testing.T) {
+	tasks := []Task{
+		{Title: "agg a", Done: true, Count: 2},
+		{Title: "agg b", Done: true, Count: 4},
+		{Title: "agg c", Done: false, Count: 1},
+	}
+
+	for _, task := range tasks {
+		resp := dbReq(t, db.add, "POST", "/db/aggtasks", task)
+		defer resp.Body.Close()
+
+		if resp.StatusCode > 299 {
+			t.Fatal(GetResponseBody(t, resp))
+		}
+	}
+
+	data := AggregateData{
+		GroupBy: []string{"done"},
+		Metrics: []database.Metric{
+			{Name: "n", Op: database.MetricCount},
+			{Name: "total", Op: database.MetricSum, Field: "count"},
+		},
+	}
+
+	resp := dbReq(t, db.aggregate, "POST", "/db/aggregate/aggtasks", data)
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+
+	var rows []map[string]any
+	if err := parseBody(resp.Body, &rows); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []map[string]any{
+		{"done": false, "n": 1.0, "total": 1.0},
+		{"done": true, "n": 2.0, "total": 6.0},
+	}
+	if !reflect.DeepEqual(expected, rows) {
+		t.Errorf("expected %v got %v", expected, rows)
+	}
+
+	data.Metrics = nil
+
+	resp2 := dbReq(t, db.aggregate, "POST", "/db/aggregate/aggtasks", data)
+	defer resp2.Body.Close()
+
+	if resp2.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 got %s", resp2.Status)
+	}
+}
+
 func TestDBIncrease(t *testing.T) {
 	task :=
 		Task{
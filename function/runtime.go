@@ -37,38 +37,77 @@ type Result struct {
 	Content interface{} `json:"content"`
 }
 
+// ApproveTimeout is the maximum duration of the functions approving an action
+var ApproveTimeout = 5 * time.Second
+
+// ErrApproveTimeout is returned when a function approving an action is
+// interrupted after the ApproveTimeout
+var ErrApproveTimeout = errors.New("the function did not complete in time")
+
 func (env *ExecutionEnvironment) Execute(data interface{}) error {
+	_, err := env.run(data, 0, true)
+	return err
+}
+
+// Approve executes the function and returns true if its handle function
+// returns true. The function is interrupted after the ApproveTimeout and
+// its runs are not added to the execution history.
+func (env *ExecutionEnvironment) Approve(data interface{}) (bool, error) {
+	v, err := env.run(data, ApproveTimeout, false)
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) {
+		return false, ErrApproveTimeout
+	} else if err != nil {
+		return false, err
+	}
+	return v.Export() == true, nil
+}
+
+// run executes the function's handle function and returns its value, the
+// function is interrupted after the timeout if it's not zero. The run is
+// added to the function's execution history when history is true.
+func (env *ExecutionEnvironment) run(data interface{}, timeout time.Duration, history bool) (goja.Value, error) {
 	vm := goja.New()
 	vm.SetFieldNameMapper(goja.TagFieldNameMapper("json", true))
 
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			vm.Interrupt("timeout")
+		})
+		defer timer.Stop()
+	}
+
 	if err := env.addHelpers(vm); err != nil {
-		return err
+		return nil, err
+	}
+	if err := env.addAuth(vm); err != nil {
+		return nil, err
 	}
 	if err := env.addDatabaseFunctions(vm); err != nil {
-		return err
+		return nil, err
 	}
 	if err := env.addVolatileFunctions(vm); err != nil {
-		return err
+		return nil, err
 	}
 	if err := env.addSearch(vm); err != nil {
-		return err
+		return nil, err
 	}
 	if err := env.addSendMail(vm); err != nil {
-		return err
+		return nil, err
 	}
 
 	if _, err := vm.RunString(env.Data.Code); err != nil {
-		return err
+		return nil, err
 	}
 
 	handler, ok := goja.AssertFunction(vm.Get("handle"))
 	if !ok {
-		return errors.New(`unable to find function "handle"`)
+		return nil, errors.New(`unable to find function "handle"`)
 	}
 
 	args, err := env.prepareArguments(vm, data)
 	if err != nil {
-		return fmt.Errorf("error preparing argument: %v", err)
+		return nil, fmt.Errorf("error preparing argument: %v", err)
 	}
 
 	env.CurrentRun = model.ExecHistory{
@@ -79,13 +118,15 @@ func (env *ExecutionEnvironment) Execute(data interface{}) error {
 
 	env.CurrentRun.Output = append(env.CurrentRun.Output, "Function started")
 
-	_, err = handler(goja.Undefined(), args...)
-	go env.complete(err)
+	v, err := handler(goja.Undefined(), args...)
+	if history {
+		go env.complete(err)
+	}
 	if err != nil {
-		return fmt.Errorf("error executing your function: %v", err)
+		return nil, fmt.Errorf("error executing your function: %w", err)
 	}
 
-	return nil
+	return v, nil
 }
 
 func (env *ExecutionEnvironment) prepareArguments(vm *goja.Runtime, data interface{}) ([]goja.Value, error) {
@@ -141,6 +182,29 @@ func (env *ExecutionEnvironment) prepareArguments(vm *goja.Runtime, data interfa
 	return args, nil
 }
 
+// addAuth exposes the caller as auth, their named role's permissions are
+// included so functions can check them
+func (env *ExecutionEnvironment) addAuth(vm *goja.Runtime) error {
+	role, _, err := database.FindRole(env.DataStore, env.BaseName, env.Auth.Role)
+	if err != nil {
+		return err
+	}
+
+	permissions := role.Permissions
+	if permissions == nil {
+		permissions = make([]string, 0)
+	}
+
+	return vm.Set("auth", map[string]any{
+		"accountId":   env.Auth.AccountID,
+		"userId":      env.Auth.UserID,
+		"email":       env.Auth.Email,
+		"role":        env.Auth.Role,
+		"roleName":    role.Name,
+		"permissions": permissions,
+	})
+}
+
 func (env *ExecutionEnvironment) addHelpers(vm *goja.Runtime) error {
 	err := vm.Set("log", func(call goja.FunctionCall) goja.Value {
 		if len(call.Arguments) == 0 {
@@ -427,6 +491,42 @@ func (env *ExecutionEnvironment) addDatabaseFunctions(vm *goja.Runtime) error {
 	if err != nil {
 		return err
 	}
+
+	err = vm.Set("transaction", func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) != 1 {
+			return vm.ToValue(Result{Content: "argument missmatch: you need 1 argument for transaction(fn)"})
+		}
+
+		fn, ok := goja.AssertFunction(call.Argument(0))
+		if !ok {
+			return vm.ToValue(Result{Content: "the first argument should be a function"})
+		}
+
+		// the database functions called by fn use the transaction, it's
+		// rolled back if fn throws
+		var content interface{}
+		store := env.DataStore
+		err := store.WithTransaction(func(tx database.Persister) error {
+			env.DataStore = tx
+			defer func() { env.DataStore = store }()
+
+			v, err := fn(goja.Undefined())
+			if err != nil {
+				return err
+			}
+
+			content = v.Export()
+			return nil
+		})
+		if err != nil {
+			return vm.ToValue(Result{Content: fmt.Sprintf("error executing transaction: %v", err)})
+		}
+
+		return vm.ToValue(Result{OK: true, Content: content})
+	})
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -528,6 +628,27 @@ func (env *ExecutionEnvironment) addVolatileFunctions(vm *goja.Runtime) error {
 		return err
 	}
 
+	err = vm.Set("presence", func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) != 1 {
+			return vm.ToValue(Result{Content: "argument missmatch: you need 1 argument for presence(channel)"})
+		}
+
+		var channel string
+		if err := vm.ExportTo(call.Argument(0), &channel); err != nil {
+			return vm.ToValue(Result{Content: "the first argument should be a string"})
+		}
+
+		p, err := cache.GetPresence(env.Volatile, env.BaseName, channel)
+		if err != nil {
+			return vm.ToValue(Result{Content: fmt.Sprintf("error getting the channel presence: %v", err)})
+		}
+
+		return vm.ToValue(Result{OK: true, Content: p})
+	})
+	if err != nil {
+		return err
+	}
+
 	err = vm.Set("cacheGet", func(call goja.FunctionCall) goja.Value {
 		if len(call.Arguments) != 1 {
 			return vm.ToValue(Result{Content: "argument missmatch: you need 1 argument for cacheGet(key)"})
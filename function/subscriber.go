@@ -47,7 +47,12 @@ func (sub *Subscriber) process(msg model.Command) {
 	case model.MsgTypeChanOut,
 		model.MsgTypeDBCreated,
 		model.MsgTypeDBUpdated,
-		model.MsgTypeDBDeleted:
+		model.MsgTypeDBDeleted,
+		model.MsgTypeLoginFailed,
+		model.MsgTypePresenceJoined,
+		model.MsgTypePresenceLeft,
+		model.MsgTypePresenceTimeout,
+		model.MsgTypePresenceUpdated:
 		sub.handleRealtimeEvents(msg)
 	default:
 		// for user triggered events, we enforce a max of 5 msg / 60 secs
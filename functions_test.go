@@ -232,6 +232,139 @@ func TestFunctionsExecuteDBOperations(t *testing.T) {
 	time.Sleep(500 * time.Millisecond)
 }
 
+func TestFunctionsTransaction(t *testing.T) {
+	code := `
+	function handle(body) {
+		create("jstx", {step: "before"});
+
+		var res = transaction(function() {
+			create("jstx", {step: "rolled back"});
+			throw new Error("abort");
+		});
+		if (res.ok) {
+			log("ERROR: expected the transaction to be rolled back");
+			return;
+		}
+
+		res = transaction(function() {
+			var created = create("jstx", {step: "committed"});
+			return created.content.id;
+		});
+		if (!res.ok) {
+			log("ERROR: committing the transaction");
+			log(res.content);
+			return;
+		}
+
+		var qres = query("jstx", [["step", "!=", "before"]]);
+		if (!qres.ok) {
+			log("ERROR: querying documents");
+			log(qres.content);
+			return;
+		}
+
+		if (qres.content.results.length != 1 || qres.content.results[0].id != res.content) {
+			log("ERROR: expected only the committed doc");
+			log(qres.content.results);
+		}
+	}`
+	data := model.ExecData{
+		FunctionName: "unittesttx",
+		Code:         code,
+		TriggerTopic: "web",
+	}
+	addResp := dbReq(t, funexec.add, "POST", "/", data, true)
+	defer addResp.Body.Close()
+
+	if addResp.StatusCode != http.StatusOK {
+		t.Fatal(GetResponseBody(t, addResp))
+	}
+
+	execResp := dbReq(t, funexec.exec, "POST", "/fn/exec/unittesttx", url.Values{}, false, true)
+	defer execResp.Body.Close()
+
+	if execResp.StatusCode != http.StatusOK {
+		t.Fatal(GetResponseBody(t, execResp))
+	}
+
+	// the execution history is saved asynchronously
+	time.Sleep(200 * time.Millisecond)
+
+	infoResp := dbReq(t, funexec.info, "GET", "/fn/info/unittesttx", nil, true)
+	defer infoResp.Body.Close()
+
+	if infoResp.StatusCode >= 299 {
+		t.Fatal(GetResponseBody(t, infoResp))
+	}
+
+	var checkFn model.ExecData
+	if err := parseBody(infoResp.Body, &checkFn); err != nil {
+		t.Fatal(err)
+	} else if len(checkFn.History) == 0 {
+		t.Fatal("expected the function execution history")
+	}
+
+	for _, h := range checkFn.History {
+		for _, line := range h.Output {
+			if strings.Contains(line, "ERROR") {
+				t.Fatalf("found error in function exec log: %v", h.Output)
+			}
+		}
+	}
+}
+
+func TestFunctionsAuth(t *testing.T) {
+	code := `
+	function handle(body) {
+		if (auth.role != 100 || auth.email != "` + admEmail + `" || auth.userId.length == 0) {
+			log("ERROR: unexpected auth");
+			log(auth);
+		}
+		if (auth.roleName != "" || auth.permissions.length != 0) {
+			log("ERROR: expected no named role for root");
+		}
+	}`
+	data := model.ExecData{
+		FunctionName: "unittestauth",
+		Code:         code,
+		TriggerTopic: "web",
+	}
+	addResp := dbReq(t, funexec.add, "POST", "/", data, true)
+	defer addResp.Body.Close()
+
+	if addResp.StatusCode != http.StatusOK {
+		t.Fatal(GetResponseBody(t, addResp))
+	}
+
+	execResp := dbReq(t, funexec.exec, "POST", "/fn/exec/unittestauth", url.Values{}, false, true)
+	defer execResp.Body.Close()
+
+	if execResp.StatusCode != http.StatusOK {
+		t.Fatal(GetResponseBody(t, execResp))
+	}
+
+	// the execution history is saved asynchronously
+	time.Sleep(200 * time.Millisecond)
+
+	infoResp := dbReq(t, funexec.info, "GET", "/fn/info/unittestauth", nil, true)
+	defer infoResp.Body.Close()
+
+	var checkFn model.ExecData
+	if err := parseBody(infoResp.Body, &checkFn); err != nil {
+		t.Fatal(err)
+	} else if len(checkFn.History) == 0 {
+		t.Fatal("expected the function execution history")
+	}
+
+	for _, h := range checkFn.History {
+		for _, line := range h.Output {
+			if strings.Contains(line, "ERROR") {
+				t.Fatalf("found error in function exec log: %v", h.Output)
+			}
+		}
+	}
+}
+
 func TestFunctionTriggerByDBChanges(t *testing.T) {
 	code := `
 	function handle(channel, type, data) {
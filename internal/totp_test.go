@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 test vectors for SHA1, truncated to 6 digits
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	tests := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for ts, expected := range tests {
+		code, err := TOTPCode(secret, time.Unix(ts, 0))
+		if err != nil {
+			t.Fatal(err)
+		} else if code != expected {
+			t.Errorf("at %d expected %s got %s", ts, expected, code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	code, err := TOTPCode(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	step, ok := ValidateTOTP(secret, code, now, 0)
+	if !ok {
+		t.Fatal("expected the current code to be valid")
+	}
+
+	// the previous period is accepted for clock drift
+	if _, ok := ValidateTOTP(secret, code, now.Add(30*time.Second), 0); !ok {
+		t.Error("expected the code of the previous period to be valid")
+	}
+
+	if _, ok := ValidateTOTP(secret, code, now.Add(2*time.Minute), 0); ok {
+		t.Error("expected an old code to be rejected")
+	}
+
+	if _, ok := ValidateTOTP(secret, code, now, step); ok {
+		t.Error("expected a used code to be rejected")
+	}
+
+	if _, ok := ValidateTOTP(secret, "12345", now, 0); ok {
+		t.Error("expected a 5 digits code to be rejected")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("StaticBackend", "me@test.com", "ABCDEF")
+	if !strings.HasPrefix(uri, "otpauth://totp/StaticBackend:me@test.com?") {
+		t.Errorf("unexpected label in %s", uri)
+	} else if !strings.Contains(uri, "secret=ABCDEF") || !strings.Contains(uri, "issuer=StaticBackend") {
+		t.Errorf("expected the secret and issuer in %s", uri)
+	}
+}
@@ -0,0 +1,135 @@
+package staticbackend
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/staticbackendhq/core/backend"
+	"github.com/staticbackendhq/core/middleware"
+)
+
+func (a *accounts) invitations(w http.ResponseWriter, r *http.Request) {
+	conf, auth, err := middleware.Extract(r, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mship := backend.Membership(conf)
+
+	if r.Method == http.MethodGet {
+		list, err := mship.ListInvitations(auth)
+		if errors.Is(err, backend.ErrInvitationNotAllowed) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		respond(w, http.StatusOK, list)
+		return
+	}
+
+	var data backend.InvitationData
+	if err := parseBody(r.Body, &data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	inv, err := mship.Invite(auth, data)
+	if errors.Is(err, backend.ErrInvitationNotAllowed) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	respond(w, http.StatusOK, inv)
+}
+
+func (a *accounts) revokeInvitation(w http.ResponseWriter, r *http.Request) {
+	conf, auth, err := middleware.Extract(r, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := getURLPart(r.URL.Path, 3)
+
+	mship := backend.Membership(conf)
+	if err := mship.RevokeInvitation(auth, id); errors.Is(err, backend.ErrInvitationNotAllowed) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respond(w, http.StatusOK, true)
+}
+
+func (m *membership) acceptInvitation(w http.ResponseWriter, r *http.Request) {
+	conf, _, err := middleware.Extract(r, false)
+	if err != nil {
+		http.Error(w, "invalid StaticBackend key", http.StatusUnauthorized)
+		return
+	}
+
+	var data struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := parseBody(r.Body, &data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mship := backend.Membership(conf)
+	tokens, _, err := mship.AcceptInvitation(data.Token, data.Password)
+	if errors.Is(err, backend.ErrTwoFactorRequired) {
+		respond(w, http.StatusOK, tokens)
+		return
+	} else if errors.Is(err, backend.ErrWeakPassword) || errors.Is(err, backend.ErrInvalidInvitation) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondSession(w, tokens)
+}
+
+func (m *membership) invitationLink(w http.ResponseWriter, r *http.Request) {
+	conf, _, err := middleware.Extract(r, false)
+	if err != nil {
+		http.Error(w, "invalid StaticBackend key", http.StatusUnauthorized)
+		return
+	}
+
+	var data struct {
+		Token string `json:"token"`
+	}
+	if err := parseBody(r.Body, &data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reqID, err := backend.Membership(conf).NewInvitationLink(data.Token)
+	if errors.Is(err, backend.ErrInvalidInvitation) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// the reqid is used with /oauth/login to accept with a provider
+	respond(w, http.StatusOK, struct {
+		ReqID   string    `json:"reqid"`
+		Expires time.Time `json:"expires"`
+	}{reqID, time.Now().Add(backend.IdentityLinkTTL)})
+}
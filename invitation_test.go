@@ -0,0 +1,190 @@
+package staticbackend
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/staticbackendhq/core/backend"
+	"github.com/staticbackendhq/core/model"
+)
+
+func inviteToken(t *testing.T, mailer *capturedMailer, email string) string {
+	mail := mailer.last(t)
+	if mail.To != email {
+		t.Fatalf("expected an invitation sent to %s got %s", email, mail.To)
+	}
+
+	m := linkToken.FindStringSubmatch(mail.HTMLBody)
+	if m == nil {
+		t.Fatalf("expected a token in the link got %s", mail.HTMLBody)
+	}
+
+	token, err := url.QueryUnescape(m[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func invite(t *testing.T, token, email string, role int) (model.Invitation, int) {
+	data := backend.InvitationData{
+		Email: email,
+		Role:  role,
+		Link:  "https://app.test.com/accept",
+		Body:  "<a href='[link]'>accept</a>",
+	}
+
+	resp := tokenReq(t, acct.invitations, "POST", "/account/invitations", token, data)
+	defer resp.Body.Close()
+
+	var inv model.Invitation
+	if resp.StatusCode == http.StatusOK {
+		if err := parseBody(resp.Body, &inv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return inv, resp.StatusCode
+}
+
+func listInvitations(t *testing.T, token string) ([]model.Invitation, int) {
+	resp := tokenReq(t, acct.invitations, "GET", "/account/invitations", token, nil)
+	defer resp.Body.Close()
+
+	var list []model.Invitation
+	if resp.StatusCode == http.StatusOK {
+		if err := parseBody(resp.Body, &list); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return list, resp.StatusCode
+}
+
+func acceptInvitation(t *testing.T, token string) *http.Response {
+	data := map[string]string{"token": token, "password": "sessions-pw"}
+	return pubReq(t, mship.acceptInvitation, "POST", "/invitations/accept", data)
+}
+
+func TestInvitations(t *testing.T) {
+	mailer := &capturedMailer{}
+	emailer := backend.Emailer
+	backend.Emailer = mailer
+	defer func() { backend.Emailer = emailer }()
+
+	owner := signIn(t, mship.register, "/register", "inv-owner@test.com")
+
+	if _, status := invite(t, owner.Token, "inv-admin@test.com", 100); status != http.StatusForbidden {
+		t.Errorf("expected status 403 inviting with a higher role got %d", status)
+	}
+
+	inv, status := invite(t, owner.Token, "inv-member@test.com", 10)
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200 got %d", status)
+	}
+	token := inviteToken(t, mailer, "inv-member@test.com")
+
+	if list, _ := listInvitations(t, owner.Token); len(list) != 1 || list[0].ID != inv.ID {
+		t.Fatalf("expected the pending invitation got %v", list)
+	}
+
+	resp := acceptInvitation(t, token)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+
+	var access string
+	if err := parseBody(resp.Body, &access); err != nil {
+		t.Fatal(err)
+	}
+
+	resp = tokenReq(t, mship.me, "GET", "/me", access, nil)
+	defer resp.Body.Close()
+
+	var me model.Auth
+	if err := parseBody(resp.Body, &me); err != nil {
+		t.Fatal(err)
+	} else if me.AccountID != inv.AccountID || me.Role != 10 || !me.EmailVerified {
+		t.Errorf("expected the invitee to join the account with the role got %v", me)
+	}
+
+	resp = acceptInvitation(t, token)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected an accepted invitation to be rejected with 400 got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	if list, _ := listInvitations(t, owner.Token); len(list) != 0 {
+		t.Errorf("expected no pending invitations got %v", list)
+	}
+
+	// the members cannot manage the invitations
+	if _, status := listInvitations(t, access); status != http.StatusForbidden {
+		t.Errorf("expected status 403 for a member got %d", status)
+	}
+
+	inv, _ = invite(t, owner.Token, "inv-revoked@test.com", 0)
+	token = inviteToken(t, mailer, "inv-revoked@test.com")
+
+	resp = tokenReq(t, acct.revokeInvitation, "DELETE", "/account/invitations/"+inv.ID, owner.Token, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+	resp.Body.Close()
+
+	resp = acceptInvitation(t, token)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a revoked invitation to be rejected with 400 got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func TestAcceptInvitationWithOAuth(t *testing.T) {
+	if err := loadTemplates(); err != nil {
+		t.Fatal(err)
+	}
+
+	mailer := &capturedMailer{}
+	emailer := backend.Emailer
+	backend.Emailer = mailer
+	defer func() { backend.Emailer = emailer }()
+
+	owner := signIn(t, mship.register, "/register", "inv-oauth-owner@test.com")
+
+	inv, status := invite(t, owner.Token, "inv-oauth@test.com", 0)
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200 got %d", status)
+	}
+	token := inviteToken(t, mailer, "inv-oauth@test.com")
+
+	srv := newStubIssuer(t, "stub-client", map[string]any{"sub": "invite-1", "email": "someone@example.com"})
+	defer srv.Close()
+
+	info := model.OAuthConfig{ConsumerKey: "stub-client", ConsumerSecret: "stub-secret", Issuer: srv.URL, Scopes: "email"}
+	defer enableTestProvider(t, "stub-invite", info)()
+
+	el := &ExternalLogins{log: backend.Log}
+
+	resp := pubReq(t, mship.invitationLink, "POST", "/invitations/oauth", map[string]string{"token": token})
+	defer resp.Body.Close()
+
+	var data struct {
+		ReqID string `json:"reqid"`
+	}
+	if err := parseBody(resp.Body, &data); err != nil {
+		t.Fatal(err)
+	} else if len(data.ReqID) == 0 {
+		t.Fatal("expected a request ID")
+	}
+
+	// the invitee joins with the invitation's email
+	extuser := oidcLogin(t, el, "stub-invite", data.ReqID)
+	if email := meEmail(t, extuser.Token); email != inv.Email {
+		t.Errorf("expected the invitee to be signed in got %s", email)
+	}
+
+	// the identity is linked to the invitee
+	extuser = oidcLogin(t, el, "stub-invite", "invite-signin")
+	if email := meEmail(t, extuser.Token); email != inv.Email {
+		t.Errorf("expected the linked identity to sign in the invitee got %s", email)
+	}
+}
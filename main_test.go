@@ -12,7 +12,9 @@ import (
 
 	"github.com/staticbackendhq/core/backend"
 	"github.com/staticbackendhq/core/config"
+	"github.com/staticbackendhq/core/middleware"
 	"github.com/staticbackendhq/core/model"
+	"github.com/staticbackendhq/core/realtime"
 )
 
 const (
@@ -51,16 +53,13 @@ func TestMain(m *testing.M) {
 
 	deleteAndSetupTestAccount()
 
-	//TODO: re-enable this when we move back to WebSocket instead of SSE
-	/*
-		hub := newHub(backend.Cache)
-		go hub.run()
-	*/
+	broker := realtime.NewBroker(validateRealtimeAuth, backend.Cache, backend.Log)
+	broker.SetChannelAuthorizer(authorizeRealtimeChannel)
 
-	ws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		//TODO: re-enable this when we move back to WebSocket instead of SSE
-		//serveWs(backend.Log, hub, w, r)
-	}))
+	ws := httptest.NewServer(middleware.Chain(
+		http.HandlerFunc(broker.AcceptWebSocket),
+		middleware.WithDB(backend.DB, backend.Cache, getStripePortalURL),
+	))
 	defer ws.Close()
 
 	wsURL = "ws" + strings.TrimPrefix(ws.URL, "http")
@@ -2,12 +2,15 @@ package staticbackend
 
 import (
 	"encoding/json"
-	"fmt"
-
+	"errors"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/staticbackendhq/core/backend"
+	"github.com/staticbackendhq/core/database"
 	"github.com/staticbackendhq/core/internal"
 	"github.com/staticbackendhq/core/logger"
 	"github.com/staticbackendhq/core/middleware"
@@ -56,13 +59,23 @@ func (m *membership) login(w http.ResponseWriter, r *http.Request) {
 
 	mship := backend.Membership(conf)
 
-	token, err := mship.Authenticate(l.Email, l.Password)
-	if err != nil {
+	tokens, err := mship.AuthenticateFrom(l.Email, l.Password, clientIP(r))
+	if errors.Is(err, backend.ErrTooManyAttempts) {
+		respondLockout(w, err)
+		return
+	} else if errors.Is(err, backend.ErrTwoFactorRequired) {
+		// the challenge is verified with a code via /login/2fa
+		respond(w, http.StatusOK, tokens)
+		return
+	} else if errors.Is(err, backend.ErrRootTwoFactorRequired) || errors.Is(err, backend.ErrEmailNotVerified) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	} else if err != nil {
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
-	respond(w, http.StatusOK, token)
+	respondSession(w, tokens)
 }
 
 func (m *membership) register(w http.ResponseWriter, r *http.Request) {
@@ -80,13 +93,147 @@ func (m *membership) register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	mship := backend.Membership(conf)
-	token, err := mship.Register(l.Email, l.Password)
+	tokens, err := mship.Register(l.Email, l.Password)
+	if errors.Is(err, backend.ErrEmailNotVerified) {
+		// the user is created and must verify their email to sign in
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	} else if errors.Is(err, backend.ErrWeakPassword) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondSession(w, tokens)
+}
+
+// respondLockout responds with a 429 HTTP error and the Retry-After header
+// while the sign in attempts are locked out
+func respondLockout(w http.ResponseWriter, err error) {
+	var lockout *backend.LockoutError
+	if errors.As(err, &lockout) {
+		secs := int(math.Ceil(lockout.RetryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(secs))
+	}
+	http.Error(w, err.Error(), http.StatusTooManyRequests)
+}
+
+// clientIP returns the IP of the request's connection. The X-Forwarded-For
+// header is ignored since clients can set it to escape their lockout.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// respondSession responds with the access token like before sessions were
+// added, the refresh token is sent in the SB-Refresh-Token header
+func respondSession(w http.ResponseWriter, tokens model.Tokens) {
+	w.Header().Set("SB-Refresh-Token", tokens.RefreshToken)
+	respond(w, http.StatusOK, tokens.Token)
+}
+
+func (m *membership) refresh(w http.ResponseWriter, r *http.Request) {
+	conf, _, err := middleware.Extract(r, false)
+	if err != nil {
+		http.Error(w, "invalid StaticBackend key", http.StatusUnauthorized)
+		return
+	}
+
+	var data struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := parseBody(r.Body, &data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mship := backend.Membership(conf)
+	tokens, err := mship.RefreshSession(data.RefreshToken)
+	if errors.Is(err, backend.ErrInvalidRefreshToken) {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respond(w, http.StatusOK, tokens)
+}
+
+func (m *membership) logout(w http.ResponseWriter, r *http.Request) {
+	conf, auth, err := middleware.Extract(r, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	} else if len(auth.SessionID) == 0 {
+		http.Error(w, "only session tokens can be signed out", http.StatusBadRequest)
+		return
+	}
+
+	mship := backend.Membership(conf)
+	if err := mship.EndSession(auth.SessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respond(w, http.StatusOK, true)
+}
+
+func (m *membership) logoutAll(w http.ResponseWriter, r *http.Request) {
+	conf, auth, err := middleware.Extract(r, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	mship := backend.Membership(conf)
+	if err := mship.EndAllSessions(auth.UserID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respond(w, http.StatusOK, true)
+}
+
+func (m *membership) sessions(w http.ResponseWriter, r *http.Request) {
+	conf, auth, err := middleware.Extract(r, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	mship := backend.Membership(conf)
+	list, err := mship.ListSessions(auth.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respond(w, http.StatusOK, list)
+}
+
+func (m *membership) sudoSessions(w http.ResponseWriter, r *http.Request) {
+	conf, _, err := middleware.Extract(r, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := getURLPart(r.URL.Path, 2)
+
+	mship := backend.Membership(conf)
+	list, err := mship.ListSessions(id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	respond(w, http.StatusOK, token)
+	respond(w, http.StatusOK, list)
 }
 
 func (m *membership) setResetCode(w http.ResponseWriter, r *http.Request) {
@@ -131,7 +278,10 @@ func (m *membership) resetPassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	mship := backend.Membership(conf)
-	if err := mship.ResetPassword(data.Email, data.Code, data.Password); err != nil {
+	if err := mship.ResetPassword(data.Email, data.Code, data.Password); errors.Is(err, backend.ErrWeakPassword) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -139,8 +289,6 @@ func (m *membership) resetPassword(w http.ResponseWriter, r *http.Request) {
 	respond(w, http.StatusOK, true)
 }
 
-/*
-TODO: those function are not used in the API ???
 func (m *membership) setRole(w http.ResponseWriter, r *http.Request) {
 	conf, a, err := middleware.Extract(r, true)
 	if err != nil || a.Role < 100 {
@@ -148,17 +296,118 @@ func (m *membership) setRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the role is either a level or the name of a role of the database
 	var data = new(struct {
 		Email string `json:"email"`
 		Role  int    `json:"role"`
+		Name  string `json:"name"`
 	})
 	if err := parseBody(r.Body, &data); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
+	} else if data.Role < 0 || data.Role > middleware.RootRole {
+		http.Error(w, "the role must be between 0 and 100", http.StatusBadRequest)
+		return
 	}
 
 	mship := backend.Membership(conf)
-	if err := mship.SetUserRole(data.Email, data.Role); err != nil {
+	if len(data.Name) > 0 {
+		err = mship.SetUserRoleName(data.Email, data.Name)
+	} else {
+		err = mship.SetUserRole(data.Email, data.Role)
+	}
+
+	if errors.Is(err, database.ErrRoleNotFound) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respond(w, http.StatusOK, true)
+}
+
+func (m *membership) setTeams(w http.ResponseWriter, r *http.Request) {
+	conf, a, err := middleware.Extract(r, true)
+	if err != nil || a.Role < 100 {
+		http.Error(w, "insufficient priviledges", http.StatusUnauthorized)
+		return
+	}
+
+	// the teams replace the current ones, an empty list removes the user
+	// from all its teams
+	var data = new(struct {
+		Email string   `json:"email"`
+		Teams []string `json:"teams"`
+	})
+	if err := parseBody(r.Body, &data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err := database.ValidateTeams(data.Teams); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := backend.Membership(conf).SetUserTeams(data.Email, data.Teams); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respond(w, http.StatusOK, true)
+}
+
+func (m *membership) listRoles(w http.ResponseWriter, r *http.Request) {
+	conf, _, err := middleware.Extract(r, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	roles, err := backend.DB.ListRoles(conf.Name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if roles == nil {
+		roles = make([]model.Role, 0)
+	}
+
+	respond(w, http.StatusOK, roles)
+}
+
+func (m *membership) saveRole(w http.ResponseWriter, r *http.Request) {
+	conf, _, err := middleware.Extract(r, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var role model.Role
+	if err := parseBody(r.Body, &role); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := saveRole(conf.Name, role); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	respond(w, http.StatusOK, true)
+}
+
+func (m *membership) deleteRole(w http.ResponseWriter, r *http.Request) {
+	conf, _, err := middleware.Extract(r, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name := getURLPart(r.URL.Path, 3)
+
+	if err := backend.DB.DeleteRole(conf.Name, name); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -166,6 +415,21 @@ func (m *membership) setRole(w http.ResponseWriter, r *http.Request) {
 	respond(w, http.StatusOK, true)
 }
 
+// saveRole validates and saves a named role
+func saveRole(dbName string, role model.Role) error {
+	existing, err := backend.DB.ListRoles(dbName)
+	if err != nil {
+		return err
+	}
+
+	if err := database.ValidateRole(role, existing); err != nil {
+		return err
+	}
+	return backend.DB.SaveRole(dbName, role)
+}
+
+/*
+TODO: this function is not used in the API ???
 func (m *membership) setPassword(w http.ResponseWriter, r *http.Request) {
 	conf, a, err := middleware.Extract(r, true)
 	if err != nil || a.Role < 100 {
@@ -208,27 +472,14 @@ func (m *membership) sudoGetTokenFromAccountID(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	token := fmt.Sprintf("%s|%s", tok.ID, tok.Token)
-
-	jwtBytes, err := backend.GetJWT(token)
+	mship := backend.Membership(conf)
+	tokens, err := mship.StartSession(tok)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	auth := model.Auth{
-		AccountID: tok.AccountID,
-		UserID:    tok.ID,
-		Email:     tok.Email,
-		Role:      tok.Role,
-		Token:     tok.Token,
-	}
-	if err := backend.Cache.SetTyped(token, auth); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	respond(w, http.StatusOK, string(jwtBytes))
+	respondSession(w, tokens)
 }
 
 func (m *membership) me(w http.ResponseWriter, r *http.Request) {
@@ -255,18 +506,25 @@ func (m *membership) magicLink(w http.ResponseWriter, r *http.Request) {
 		email := r.URL.Query().Get("email")
 		code := r.URL.Query().Get("code")
 
-		token, err := mship.ValidateMagicLink(email, code)
-		if err != nil {
-			if strings.Contains(err.Error(), "maximum") {
-				http.Error(w, err.Error(), http.StatusTooManyRequests)
-				return
-			}
-
+		tokens, err := mship.ValidateMagicLink(email, code)
+		if errors.Is(err, backend.ErrTwoFactorRequired) {
+			respond(w, http.StatusOK, tokens)
+			return
+		} else if errors.Is(err, backend.ErrRootTwoFactorRequired) || errors.Is(err, backend.ErrEmailNotVerified) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		} else if errors.Is(err, backend.ErrTooManyAttempts) {
+			respondLockout(w, err)
+			return
+		} else if errors.Is(err, backend.ErrInvalidMagicLinkCode) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		} else if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		respond(w, http.StatusOK, token)
+		respondSession(w, tokens)
 		return
 	}
 
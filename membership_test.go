@@ -1,10 +1,16 @@
 package staticbackend
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"github.com/staticbackendhq/core/backend"
+	"github.com/staticbackendhq/core/middleware"
 	"github.com/staticbackendhq/core/model"
 )
 
@@ -63,3 +69,386 @@ func TestMagicLink(t *testing.T) {
 		t.Fatal(GetResponseBody(t, resp2))
 	}
 }
+
+func TestRoles(t *testing.T) {
+	role := model.Role{Name: "editor", Level: 40, Permissions: []string{"posts:write"}}
+
+	resp := dbReq(t, mship.saveRole, "POST", "/roles/save", role, true)
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+	defer backend.DB.DeleteRole(dbName, role.Name)
+
+	invalid := model.Role{Name: "other", Level: 40}
+	resp = dbReq(t, mship.saveRole, "POST", "/roles/save", invalid, true)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a duplicate level got %s", resp.Status)
+	}
+
+	resp = dbReq(t, mship.listRoles, "GET", "/roles", nil, true)
+	defer resp.Body.Close()
+
+	var roles []model.Role
+	if err := parseBody(resp.Body, &roles); err != nil {
+		t.Fatal(err)
+	} else if len(roles) != 1 || roles[0].Name != role.Name || !roles[0].Can("posts:write") {
+		t.Errorf("expected the editor role got %v", roles)
+	}
+
+	// the user does not have the role yet
+	resp = userReq(t, mship.me, "GET", "/me", nil, middleware.RequireRole(backend.DB, role.Name))
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected status 403 got %s", resp.Status)
+	}
+
+	data := map[string]any{"email": userEmail, "name": role.Name}
+	resp = dbReq(t, mship.setRole, "POST", "/setrole", data)
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+	defer backend.DB.SetUserRole(dbName, userEmail, 0)
+
+	resp = userReq(t, mship.me, "GET", "/me", nil, middleware.RequireRole(backend.DB, role.Name))
+	defer resp.Body.Close()
+
+	var me model.Auth
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	} else if err := parseBody(resp.Body, &me); err != nil {
+		t.Fatal(err)
+	} else if me.Role != role.Level || me.RoleName != role.Name {
+		t.Errorf("expected the editor role got %d %s", me.Role, me.RoleName)
+	}
+
+	resp = userReq(t, mship.me, "GET", "/me", nil, middleware.RequirePermission(backend.DB, "posts:write"))
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		t.Error(GetResponseBody(t, resp))
+	}
+
+	resp = userReq(t, mship.me, "GET", "/me", nil, middleware.RequirePermission(backend.DB, "billing:read"))
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected status 403 got %s", resp.Status)
+	}
+
+	data = map[string]any{"email": userEmail, "name": "unknown"}
+	resp = dbReq(t, mship.setRole, "POST", "/setrole", data)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown role got %s", resp.Status)
+	}
+}
+
+func TestSetTeams(t *testing.T) {
+	rules := model.CollectionRules{
+		Collection: "teamtasks",
+		Rules: model.Rules{
+			Read: [][]any{{"teamId", "in", "auth.teams"}},
+		},
+	}
+	if err := backend.DB.SaveRules(dbName, rules); err != nil {
+		t.Fatal(err)
+	}
+	defer backend.DB.DeleteRules(dbName, rules.Collection)
+
+	for _, team := range []string{"red", "blue"} {
+		doc := map[string]any{"title": "task of " + team, "teamId": team}
+		resp := dbReq(t, db.add, "POST", "/db/teamtasks", doc)
+		defer resp.Body.Close()
+
+		if resp.StatusCode > 299 {
+			t.Fatal(GetResponseBody(t, resp))
+		}
+	}
+
+	invalid := map[string]any{"email": userEmail, "teams": []string{"red", "red"}}
+	resp := dbReq(t, mship.setTeams, "POST", "/setteams", invalid)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for duplicate teams got %s", resp.Status)
+	}
+
+	data := map[string]any{"email": userEmail, "teams": []string{"red"}}
+	resp = dbReq(t, mship.setTeams, "POST", "/setteams", data)
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+
+	resp = userReq(t, db.list, "GET", "/db/teamtasks", nil)
+	defer resp.Body.Close()
+
+	var result model.PagedResult
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	} else if err := parseBody(resp.Body, &result); err != nil {
+		t.Fatal(err)
+	} else if len(result.Results) != 1 {
+		t.Fatalf("expected 1 task of the red team got %v", result.Results)
+	} else if result.Results[0]["teamId"] != "red" {
+		t.Errorf("expected the red team's task got %v", result.Results[0])
+	}
+
+	// an empty list removes the user from all its teams
+	data = map[string]any{"email": userEmail, "teams": []string{}}
+	resp = dbReq(t, mship.setTeams, "POST", "/setteams", data)
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+
+	resp = userReq(t, db.list, "GET", "/db/teamtasks", nil)
+	defer resp.Body.Close()
+
+	result = model.PagedResult{}
+	if err := parseBody(resp.Body, &result); err != nil {
+		t.Fatal(err)
+	} else if len(result.Results) != 0 {
+		t.Errorf("expected no tasks without teams got %v", result.Results)
+	}
+}
+
+// pubReq sends a JSON request without authentication
+func pubReq(t *testing.T, hf func(http.ResponseWriter, *http.Request), method, path string, v interface{}) *http.Response {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal("error marshaling post data:", err)
+	}
+
+	req := httptest.NewRequest(method, path, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("SB-PUBLIC-KEY", pubKey)
+
+	w := httptest.NewRecorder()
+	h := middleware.Chain(http.HandlerFunc(hf), middleware.WithDB(backend.DB, backend.Cache, getStripePortalURL))
+	h.ServeHTTP(w, req)
+
+	return w.Result()
+}
+
+func signIn(t *testing.T, hf func(http.ResponseWriter, *http.Request), path, email string) model.Tokens {
+	resp := pubReq(t, hf, "POST", path, model.Login{Email: email, Password: "sessions-pw"})
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+
+	var tokens model.Tokens
+	if err := parseBody(resp.Body, &tokens.Token); err != nil {
+		t.Fatal(err)
+	}
+
+	tokens.RefreshToken = resp.Header.Get("SB-Refresh-Token")
+	if len(tokens.Token) == 0 || len(tokens.RefreshToken) == 0 {
+		t.Fatalf("expected an access and a refresh token got %v", tokens)
+	}
+	return tokens
+}
+
+func refreshSession(t *testing.T, refreshToken string) (model.Tokens, int) {
+	data := map[string]string{"refreshToken": refreshToken}
+	resp := pubReq(t, mship.refresh, "POST", "/refresh", data)
+	defer resp.Body.Close()
+
+	var tokens model.Tokens
+	if resp.StatusCode == http.StatusOK {
+		if err := parseBody(resp.Body, &tokens); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tokens, resp.StatusCode
+}
+
+func meStatus(t *testing.T, token string) int {
+	resp := tokenReq(t, mship.me, "GET", "/me", token, nil)
+	defer resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestSessionRefreshAndLogout(t *testing.T) {
+	first := signIn(t, mship.register, "/register", "sessions@test.com")
+	second := signIn(t, mship.login, "/login", "sessions@test.com")
+
+	resp := tokenReq(t, mship.sessions, "GET", "/sessions", first.Token, nil)
+	defer resp.Body.Close()
+
+	var list []model.Session
+	if err := parseBody(resp.Body, &list); err != nil {
+		t.Fatal(err)
+	} else if len(list) != 2 {
+		t.Fatalf("expected 2 sessions got %v", list)
+	}
+
+	refreshed, status := refreshSession(t, first.RefreshToken)
+	if status != http.StatusOK {
+		t.Fatalf("expected status 200 got %d", status)
+	} else if refreshed.RefreshToken == first.RefreshToken {
+		t.Error("expected the refresh token to be rotated")
+	} else if s := meStatus(t, refreshed.Token); s != http.StatusOK {
+		t.Errorf("expected the refreshed access token to be valid got %d", s)
+	}
+
+	// using a refresh token twice revokes its session
+	if _, status := refreshSession(t, first.RefreshToken); status != http.StatusUnauthorized {
+		t.Errorf("expected reusing a refresh token to return 401 got %d", status)
+	}
+	if s := meStatus(t, refreshed.Token); s != http.StatusUnauthorized {
+		t.Errorf("expected the revoked session's access token to be rejected got %d", s)
+	}
+	if _, status := refreshSession(t, refreshed.RefreshToken); status != http.StatusUnauthorized {
+		t.Errorf("expected the revoked session not to refresh got %d", status)
+	}
+
+	// the other session is still active until it signs out
+	if s := meStatus(t, second.Token); s != http.StatusOK {
+		t.Fatalf("expected the other session to be valid got %d", s)
+	}
+
+	resp2 := tokenReq(t, mship.logout, "POST", "/logout", second.Token, nil)
+	defer resp2.Body.Close()
+
+	if resp2.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp2))
+	}
+
+	if s := meStatus(t, second.Token); s != http.StatusUnauthorized {
+		t.Errorf("expected the signed out access token to be rejected got %d", s)
+	}
+	if _, status := refreshSession(t, second.RefreshToken); status != http.StatusUnauthorized {
+		t.Errorf("expected the signed out session not to refresh got %d", status)
+	}
+}
+
+func TestSessionRefreshTokenCors(t *testing.T) {
+	b, err := json.Marshal(model.Login{Email: admEmail, Password: password})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", "/login", bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("SB-PUBLIC-KEY", pubKey)
+	req.Header.Set("Origin", "https://app.example.com")
+
+	w := httptest.NewRecorder()
+	h := middleware.Chain(http.HandlerFunc(mship.login), middleware.Cors(), middleware.WithDB(backend.DB, backend.Cache, getStripePortalURL))
+	h.ServeHTTP(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	} else if len(resp.Header.Get("SB-Refresh-Token")) == 0 {
+		t.Fatal("expected a refresh token")
+	}
+
+	// browsers only let cross-origin clients read the exposed headers
+	exposed := resp.Header.Get("Access-Control-Expose-Headers")
+	if !strings.Contains(exposed, "SB-Refresh-Token") {
+		t.Errorf("expected the refresh token header to be exposed got %q", exposed)
+	}
+}
+
+func TestSessionLogoutAll(t *testing.T) {
+	first := signIn(t, mship.register, "/register", "sessions-all@test.com")
+	second := signIn(t, mship.login, "/login", "sessions-all@test.com")
+
+	resp := tokenReq(t, mship.logoutAll, "POST", "/logout/all", first.Token, nil)
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+
+	for _, tokens := range []model.Tokens{first, second} {
+		if s := meStatus(t, tokens.Token); s != http.StatusUnauthorized {
+			t.Errorf("expected all access tokens to be rejected got %d", s)
+		}
+		if _, status := refreshSession(t, tokens.RefreshToken); status != http.StatusUnauthorized {
+			t.Errorf("expected all sessions not to refresh got %d", status)
+		}
+	}
+
+	// a new sign in is not affected
+	third := signIn(t, mship.login, "/login", "sessions-all@test.com")
+	if s := meStatus(t, third.Token); s != http.StatusOK {
+		t.Errorf("expected a new session to be valid got %d", s)
+	}
+}
+
+func TestRemoveUserEndsSessions(t *testing.T) {
+	data := model.Login{Email: "sessions-removed@test.com", Password: "sessions-pw"}
+	resp := dbReq(t, acct.addUser, "POST", "/account/users", data)
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+
+	tokens := signIn(t, mship.login, "/login", data.Email)
+
+	resp2 := tokenReq(t, mship.me, "GET", "/me", tokens.Token, nil)
+	defer resp2.Body.Close()
+
+	var me model.Auth
+	if err := parseBody(resp2.Body, &me); err != nil {
+		t.Fatal(err)
+	}
+
+	resp3 := dbReq(t, acct.deleteUser, "DELETE", "/account/users/"+me.UserID, nil)
+	defer resp3.Body.Close()
+
+	if resp3.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp3))
+	}
+
+	if s := meStatus(t, tokens.Token); s != http.StatusUnauthorized {
+		t.Errorf("expected the removed user's access token to be rejected got %d", s)
+	}
+	if _, status := refreshSession(t, tokens.RefreshToken); status != http.StatusUnauthorized {
+		t.Errorf("expected the removed user's session not to refresh got %d", status)
+	}
+}
+
+func TestSudoSessions(t *testing.T) {
+	resp := dbReq(t, mship.me, "GET", "/me", nil)
+	defer resp.Body.Close()
+
+	var me model.Auth
+	if err := parseBody(resp.Body, &me); err != nil {
+		t.Fatal(err)
+	}
+
+	resp2 := dbReq(t, mship.sudoSessions, "GET", "/sudosessions/"+me.UserID, nil, true)
+	defer resp2.Body.Close()
+
+	if resp2.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp2))
+	}
+
+	var list []model.Session
+	if err := parseBody(resp2.Body, &list); err != nil {
+		t.Fatal(err)
+	} else if len(list) == 0 {
+		t.Error("expected the admin's sessions to be listed")
+	} else if list[0].UserID != me.UserID {
+		t.Errorf("expected the sessions of %s got %v", me.UserID, list)
+	}
+}
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// apiKeyTouchInterval limits how often the last used time of a key is saved
+const apiKeyTouchInterval = time.Minute
+
+var errInvalidAPIKey = errors.New("invalid API key")
+
+// ValidateAPIKey validates an API key and returns the authentication of the
+// user it acts as
+func ValidateAPIKey(datastore database.Persister, ctx context.Context, key string) (model.APIKey, model.Auth, error) {
+	conf, ok := ctx.Value(ContextBase).(model.DatabaseConfig)
+	if !ok {
+		return model.APIKey{}, model.Auth{}, fmt.Errorf("invalid StaticBackend public token")
+	}
+
+	id, secret, ok := database.ParseAPIKey(key)
+	if !ok {
+		return model.APIKey{}, model.Auth{}, errInvalidAPIKey
+	}
+
+	k, err := datastore.GetAPIKey(conf.Name, id)
+	if err != nil {
+		return k, model.Auth{}, errInvalidAPIKey
+	}
+
+	hash := database.HashAPIKeySecret(secret)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(k.KeyHash)) != 1 {
+		return k, model.Auth{}, errInvalidAPIKey
+	} else if k.IsExpired() {
+		return k, model.Auth{}, errors.New("this API key has expired")
+	}
+
+	// the key acts as its user with their current role
+	user, err := datastore.GetUserByID(conf.Name, k.AccountID, k.UserID)
+	if err != nil {
+		return k, model.Auth{}, fmt.Errorf("error retrieving the API key's user: %v", err)
+	}
+
+	cus, err := datastore.FindTenant(conf.TenantID)
+	if err != nil {
+		return k, model.Auth{}, fmt.Errorf("error retrieving your customer account: %v", err)
+	}
+
+	a := model.Auth{
+		AccountID: user.AccountID,
+		UserID:    user.ID,
+		Email:     user.Email,
+		Role:      user.Role,
+		Token:     user.Token,
+		Plan:      cus.Plan,
+
+		EmailVerified: user.EmailVerified,
+	}
+
+	a, err = database.WithRoleName(datastore, conf.Name, a)
+	if err != nil {
+		return k, a, err
+	}
+
+	if now := time.Now(); k.LastUsed == nil || now.Sub(*k.LastUsed) > apiKeyTouchInterval {
+		if err := datastore.TouchAPIKey(conf.Name, k.ID, now); err != nil {
+			return k, a, err
+		}
+	}
+
+	return k, a, nil
+}
+
+// APIKeyScope returns the scope a request made with an API key requires, ok
+// is false when API keys cannot access the route. An empty scope is allowed
+// for all keys.
+func APIKeyScope(r *http.Request) (scope string, ok bool) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) == 0 {
+		return "", false
+	}
+
+	part := func(i int) string {
+		if len(parts) <= i {
+			return ""
+		}
+		return parts[i]
+	}
+
+	scoped := func(action, col string) (string, bool) {
+		return action + ":" + col, len(col) > 0
+	}
+
+	switch parts[0] {
+	case "db":
+		if part(1) == "count" || part(1) == "aggregate" {
+			return scoped("read", part(2))
+		} else if r.Method == http.MethodGet {
+			return scoped("read", part(1))
+		}
+		return scoped("write", part(1))
+	case "query":
+		return scoped("read", part(1))
+	case "inc":
+		return scoped("write", part(1))
+	case "fn":
+		return database.ScopeFunctionExec, part(1) == "exec"
+	case "storage":
+		return database.ScopeStorageUpload, part(1) == "upload"
+	case "newid":
+		return "", true
+	}
+	return "", false
+}
+
+// requireAPIKey authenticates a request made with an API key, a 403 HTTP
+// error is returned when the key does not have the scope of the route
+func requireAPIKey(datastore database.Persister, key string, next http.Handler, w http.ResponseWriter, r *http.Request) {
+	k, auth, err := ValidateAPIKey(datastore, r.Context(), key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	scope, ok := APIKeyScope(r)
+	if !ok || (len(scope) > 0 && !k.Allows(scope)) {
+		http.Error(w, fmt.Sprintf("the API key %s is not allowed to call %s", k.Name, r.URL.Path), http.StatusForbidden)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), ContextAuth, auth)
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gbrlsnchs/jwt/v3"
 	"github.com/staticbackendhq/core/cache"
@@ -16,10 +17,11 @@ const (
 	RootRole = 100
 )
 
-// RequireAuth validates that a session token is valid.
+// RequireAuth validates that a session token or an API key is valid.
 // If not valid a 401 HTTP error is returned.
 //
 // The request must have an HTTP Header of: Authorization: Bearer "session-token".
+// API keys are limited to the routes allowed by their scopes (see APIKeyScope).
 func RequireAuth(datastore database.Persister, volatile cache.Volatilizer) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -56,12 +58,18 @@ func RequireAuth(datastore database.Persister, volatile cache.Volatilizer) Middl
 
 			key = strings.Replace(key, "Bearer ", "", -1)
 
+			if strings.HasPrefix(key, database.APIKeyPrefix) {
+				requireAPIKey(datastore, key, next, w, r)
+				return
+			}
+
 			ctx := r.Context()
 
 			auth, err := ValidateAuthKey(datastore, volatile, ctx, key)
 			if err != nil {
+				// 401 tells the clients to refresh their session
 				err = fmt.Errorf("error validating auth key: %w", err)
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
 				return
 			}
 
@@ -72,26 +80,38 @@ func RequireAuth(datastore database.Persister, volatile cache.Volatilizer) Middl
 	}
 }
 
-// ValidateAuthKey validates a session token
+// ValidateAuthKey validates a session token, it must not be expired and its
+// session must not be revoked
 func ValidateAuthKey(datastore database.Persister, volatile cache.Volatilizer, ctx context.Context, key string) (model.Auth, error) {
 	a := model.Auth{}
 
 	var pl model.JWTPayload
-	if _, err := jwt.Verify([]byte(key), model.HashSecret, &pl); err != nil {
+	expValidator := jwt.ExpirationTimeValidator(time.Now())
+	validator := jwt.ValidatePayload(&pl.Payload, expValidator)
+	if _, err := jwt.Verify([]byte(key), model.HashSecret, &pl, validator); err != nil {
 		return a, fmt.Errorf("could not verify your authentication token: %s", err.Error())
 	}
 
+	if len(pl.Session) == 0 || cache.IsSessionRevoked(volatile, pl.Session) {
+		return a, fmt.Errorf("your session has ended, please sign in again")
+	}
+
 	conf, ok := ctx.Value(ContextBase).(model.DatabaseConfig)
 	if !ok {
 		return a, fmt.Errorf("invalid StaticBackend public token")
 	}
 
+	// the cached user must be from this database, the others are not found
+	// below
 	var auth model.Auth
-	if err := volatile.GetTyped(pl.Token, &auth); err == nil {
+	var base model.DatabaseConfig
+	if err := volatile.GetTyped(pl.Token, &auth); err == nil &&
+		volatile.GetTyped("base:"+pl.Token, &base) == nil && base.Name == conf.Name {
+		auth.SessionID = pl.Session
 		return auth, nil
 	}
 
-	parts := strings.Split(key, "|")
+	parts := strings.Split(pl.Token, "|")
 	if len(parts) != 2 {
 		return a, fmt.Errorf("invalid authentication token")
 	}
@@ -118,7 +138,15 @@ func ValidateAuthKey(datastore database.Persister, volatile cache.Volatilizer, c
 		Role:      token.Role,
 		Token:     token.Token,
 		Plan:      cus.Plan,
+
+		EmailVerified: token.EmailVerified,
 	}
+
+	a, err = database.WithRoleName(datastore, conf.Name, a)
+	if err != nil {
+		return a, fmt.Errorf("error retrieving your role: %v", err)
+	}
+
 	if err := volatile.SetTyped(pl.Token, a); err != nil {
 		return a, err
 	}
@@ -128,9 +156,23 @@ func ValidateAuthKey(datastore database.Persister, volatile cache.Volatilizer, c
 		return a, err
 	}
 
+	a.SessionID = pl.Session
 	return a, nil
 }
 
+// AuthKeyExpiration returns the time a session token expires
+func AuthKeyExpiration(key string) (time.Time, error) {
+	var pl model.JWTPayload
+	if _, err := jwt.Verify([]byte(key), model.HashSecret, &pl); err != nil {
+		return time.Time{}, fmt.Errorf("could not verify your authentication token: %s", err.Error())
+	}
+
+	if pl.ExpirationTime == nil {
+		return time.Time{}, fmt.Errorf("your authentication token has no expiration")
+	}
+	return pl.ExpirationTime.Time, nil
+}
+
 // RequireRoot validates that the token provided is for a "root" user.
 func RequireRoot(datastore database.Persister, volatile cache.Volatilizer) Middleware {
 	return func(next http.Handler) http.Handler {
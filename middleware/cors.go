@@ -5,6 +5,10 @@ import (
 	"strings"
 )
 
+// exposedHeaders are the response headers browser clients can read from
+// another origin, i.e. the refresh token of a new session
+const exposedHeaders = "SB-Refresh-Token, ETag, Retry-After"
+
 // Cors enables calls via remote origin to handle external JavaScript calls mainly.
 func Cors() Middleware {
 	return func(next http.Handler) http.Handler {
@@ -30,6 +34,7 @@ func Cors() Middleware {
 			headers.Set("Access-Control-Allow-Methods", strings.ToUpper(r.Header.Get("Access-Control-Request-Method")))
 
 			headers.Set("Access-Control-Allow-Headers", r.Header.Get("Access-Control-Request-Headers"))
+			headers.Set("Access-Control-Expose-Headers", exposedHeaders)
 
 			if r.Method == "OPTIONS" {
 				w.WriteHeader(http.StatusOK)
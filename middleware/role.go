@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// RequireRole validates that the authenticated user has one of the named
+// roles of the database. Root users are always allowed.
+//
+// It must be chained after RequireAuth or RequireRoot, a 403 HTTP error is
+// returned when the user does not have one of the roles.
+func RequireRole(datastore database.Persister, roles ...string) Middleware {
+	return requireRole(datastore, func(role model.Role) bool {
+		for _, name := range roles {
+			if role.Name == name {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// RequirePermission validates that the named role of the authenticated user
+// grants the permission. Root users are always allowed.
+//
+// It must be chained after RequireAuth or RequireRoot, a 403 HTTP error is
+// returned when the user's role does not grant the permission or when the
+// permission is denied to unverified users (see model.EmailVerification).
+func RequirePermission(datastore database.Persister, permission string) Middleware {
+	allowed := requireRole(datastore, func(role model.Role) bool {
+		return role.Can(permission)
+	})
+
+	return func(next http.Handler) http.Handler {
+		return allowed(requireVerifiedEmail(datastore, permission, next))
+	}
+}
+
+// requireVerifiedEmail returns a 403 HTTP error when the user has not verified
+// their email and the database denies the permission to unverified users
+func requireVerifiedEmail(datastore database.Persister, permission string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conf, auth, err := Extract(r, true)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		if auth.Role >= RootRole || auth.EmailVerified {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		settings, err := datastore.GetSettings(conf.Name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		} else if settings.EmailVerification.Denies(permission) {
+			http.Error(w, "you must verify your email first", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func requireRole(datastore database.Persister, allowed func(model.Role) bool) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conf, auth, err := Extract(r, true)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			if auth.Role >= RootRole {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// the role is looked up on each request since it may have
+			// changed after the session was created
+			role, ok, err := database.FindRole(datastore, conf.Name, auth.Role)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			} else if !ok || !allowed(role) {
+				http.Error(w, "insufficient privileges", http.StatusForbidden)
+				return
+			}
+
+			auth.RoleName = role.Name
+			ctx := context.WithValue(r.Context(), ContextAuth, auth)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -21,8 +21,16 @@ type Auth struct {
 	UserID    string `json:"userId"`
 	Email     string `json:"email"`
 	Role      int    `json:"role"`
-	Token     string `json:"-"`
-	Plan      int    `json:"-"`
+	RoleName  string `json:"roleName,omitempty"`
+	SessionID string `json:"sessionId,omitempty"`
+	// Teams are the teams of the user, they're set when evaluating the
+	// collection rules referencing auth.teams
+	Teams []string `json:"teams,omitempty"`
+	// EmailVerified is true once the user verified their email, see
+	// EmailVerification
+	EmailVerified bool   `json:"emailVerified"`
+	Token         string `json:"-"`
+	Plan          int    `json:"-"`
 }
 
 func (auth Auth) ReconstructToken() string {
@@ -32,10 +40,11 @@ func (auth Auth) ReconstructToken() string {
 	return fmt.Sprintf("%s|%s", auth.UserID, auth.Token)
 }
 
-// JWTPayload contains the current user token
+// JWTPayload contains the current user token and the session it belongs to
 type JWTPayload struct {
 	jwt.Payload
-	Token string `json:"token,omitempty"`
+	Token   string `json:"token,omitempty"`
+	Session string `json:"sid,omitempty"`
 }
 
 type Account struct {
@@ -45,14 +54,15 @@ type Account struct {
 }
 
 type User struct {
-	ID        string    `json:"id"`
-	AccountID string    `json:"accountId"`
-	Token     string    `json:"token"`
-	Email     string    `json:"email"`
-	Password  string    `json:"-"`
-	Role      int       `json:"role"`
-	ResetCode string    `json:"-"`
-	Created   time.Time `json:"created"`
+	ID            string    `json:"id"`
+	AccountID     string    `json:"accountId"`
+	Token         string    `json:"token"`
+	Email         string    `json:"email"`
+	Password      string    `json:"-"`
+	Role          int       `json:"role"`
+	ResetCode     string    `json:"-"`
+	Created       time.Time `json:"created"`
+	EmailVerified bool      `json:"emailVerified"`
 }
 
 type Login struct {
@@ -81,13 +91,36 @@ type Tenant struct {
 }
 
 func EncryptExternalLogins(tokens map[string]OAuthConfig) ([]byte, error) {
-	key := []byte(config.Current.AppSecret)
-
 	b, err := json.Marshal(tokens)
 	if err != nil {
 		return nil, err
 	}
 
+	return Encrypt(b)
+}
+
+func (cus *Tenant) GetExternalLogins() (map[string]OAuthConfig, error) {
+	if len(cus.ExternalLogins) == 0 {
+		return make(map[string]OAuthConfig), nil
+	}
+
+	b, err := Decrypt(cus.ExternalLogins)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]OAuthConfig)
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Encrypt encrypts secrets stored in the database with the app's secret
+func Encrypt(b []byte) ([]byte, error) {
+	key := []byte(config.Current.AppSecret)
+
 	c, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -106,14 +139,10 @@ func EncryptExternalLogins(tokens map[string]OAuthConfig) ([]byte, error) {
 	return gcm.Seal(nonce, nonce, b, nil), nil
 }
 
-func (cus *Tenant) GetExternalLogins() (map[string]OAuthConfig, error) {
+// Decrypt decrypts a value encrypted with Encrypt
+func Decrypt(ciphertext []byte) ([]byte, error) {
 	key := []byte(config.Current.AppSecret)
 
-	ciphertext := cus.ExternalLogins
-	if len(ciphertext) == 0 {
-		return make(map[string]OAuthConfig), nil
-	}
-
 	c, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -130,17 +159,7 @@ func (cus *Tenant) GetExternalLogins() (map[string]OAuthConfig, error) {
 	}
 
 	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
-	b, err := gcm.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	m := make(map[string]OAuthConfig)
-	if err := json.Unmarshal(b, &m); err != nil {
-		return nil, err
-	}
-
-	return m, nil
+	return gcm.Open(nil, nonce, ciphertext, nil)
 }
 
 func (cus *Tenant) GetProvider(provider string) (cfg OAuthConfig, ok bool) {
@@ -156,4 +175,29 @@ func (cus *Tenant) GetProvider(provider string) (cfg OAuthConfig, ok bool) {
 type OAuthConfig struct {
 	ConsumerKey    string
 	ConsumerSecret string
+	// Issuer is set for the OpenID Connect providers, their endpoints are
+	// discovered from the issuer's /.well-known/openid-configuration
+	Issuer string `json:",omitempty"`
+	// Scopes are space separated like the OAuth scope parameter
+	Scopes string `json:",omitempty"`
+	Claims ClaimMapping
+	// TrustEmail links existing users by email for providers not sending
+	// an email_verified claim, only set it when the provider verifies emails
+	TrustEmail bool `json:",omitempty"`
+}
+
+// IsOIDC returns true for the generic OpenID Connect providers
+func (cfg OAuthConfig) IsOIDC() bool {
+	return len(cfg.Issuer) > 0
+}
+
+// ClaimMapping maps the user's fields to the claims of an OpenID Connect
+// provider, the standard claims are used for the empty fields
+type ClaimMapping struct {
+	ID        string `json:",omitempty"`
+	Email     string `json:",omitempty"`
+	Name      string `json:",omitempty"`
+	FirstName string `json:",omitempty"`
+	LastName  string `json:",omitempty"`
+	AvatarURL string `json:",omitempty"`
 }
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKey is a scoped credential of a database for server-to-server access.
+// Requests using a key act as the user who created it, limited to its scopes.
+type APIKey struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	KeyHash   string     `json:"-"`
+	AccountID string     `json:"accountId"`
+	UserID    string     `json:"userId"`
+	Email     string     `json:"email"`
+	Scopes    []string   `json:"scopes"`
+	Expires   *time.Time `json:"expires"`
+	LastUsed  *time.Time `json:"lastUsed"`
+	Created   time.Time  `json:"created"`
+}
+
+// IsExpired returns true if the key has an expiry in the past
+func (k APIKey) IsExpired() bool {
+	return k.Expires != nil && k.Expires.Before(time.Now())
+}
+
+// Allows returns true if the key has the scope, the "read:*" and "write:*"
+// scopes allow all collections
+func (k APIKey) Allows(scope string) bool {
+	action, _, _ := strings.Cut(scope, ":")
+
+	for _, s := range k.Scopes {
+		if s == scope || (s == action+":*" && (action == "read" || action == "write")) {
+			return true
+		}
+	}
+	return false
+}
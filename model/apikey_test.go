@@ -0,0 +1,36 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAPIKeyAllows(t *testing.T) {
+	k := APIKey{Scopes: []string{"read:*", "write:tasks", "fn:exec"}}
+
+	allowed := []string{"read:tasks", "read:users", "write:tasks", "fn:exec"}
+	for _, scope := range allowed {
+		if !k.Allows(scope) {
+			t.Errorf("expected %s to be allowed", scope)
+		}
+	}
+
+	denied := []string{"write:users", "storage:upload", "fn:*"}
+	for _, scope := range denied {
+		if k.Allows(scope) {
+			t.Errorf("expected %s to be denied", scope)
+		}
+	}
+}
+
+func TestAPIKeyIsExpired(t *testing.T) {
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Minute)
+
+	if (APIKey{}).IsExpired() {
+		t.Error("expected a key without expiry not to expire")
+	} else if !(APIKey{Expires: &past}).IsExpired() {
+		t.Error("expected the key to be expired")
+	} else if (APIKey{Expires: &future}).IsExpired() {
+		t.Error("expected the key not to be expired")
+	}
+}
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// LoginFailure is the data of the login_failed audit events, LockedUntil is
+// set when the email and IP are locked out after this failure
+type LoginFailure struct {
+	Email       string     `json:"email"`
+	IP          string     `json:"ip"`
+	Method      string     `json:"method"`
+	Failures    int        `json:"failures"`
+	LockedUntil *time.Time `json:"lockedUntil,omitempty"`
+	Time        time.Time  `json:"time"`
+}
@@ -25,6 +25,8 @@ type PagedResult struct {
 	Size    int64                    `json:"size"`
 	Total   int64                    `json:"total"`
 	Results []map[string]interface{} `json:"results"`
+	// Cursor is set when the page is full, it's used to get the next page
+	Cursor string `json:"cursor,omitempty"`
 }
 
 type ListParams struct {
@@ -32,6 +34,21 @@ type ListParams struct {
 	Size           int64
 	SortBy         string
 	SortDescending bool
+	// Sort is an ordered list of sort keys, it takes precedence over
+	// SortBy and SortDescending
+	Sort []SortKey
+	// Fields limits the returned fields, fields prefixed with a - are
+	// excluded instead
+	Fields []string
+	// Cursor starts the page after the cursor's document instead of
+	// using the Page offset
+	Cursor string
+}
+
+// SortKey is a field to sort on with its direction
+type SortKey struct {
+	Field      string
+	Descending bool
 }
 
 var (
@@ -48,6 +65,9 @@ func init() {
 
 const (
 	SystemID = "sb"
+	// AuditChannel receives the audit events, i.e. the failed sign in. Only
+	// root users can join it and they receive their database's events.
+	AuditChannel = "sb-audit"
 
 	MsgTypeError        = "error"
 	MsgTypeOk           = "ok"
@@ -65,22 +85,44 @@ const (
 	MsgTypeDBDeleted    = "db_deleted"
 	MsgTypeFunctionCall = "fn_call"
 	MsgTypeHTTPResponse = "http_response"
+	MsgTypeLoginFailed  = "login_failed"
+
+	MsgTypePresenceSet     = "presence_set"
+	MsgTypePresenceJoined  = "presence_joined"
+	MsgTypePresenceLeft    = "presence_left"
+	MsgTypePresenceTimeout = "presence_timeout"
+	MsgTypePresenceUpdated = "presence_updated"
+
+	// MsgTypeDBLeft is sent to a filtered database subscription when an
+	// update moves a document out of its filter, the deleted documents are
+	// sent whether they matched the filter or not
+	MsgTypeDBLeft = "db_left"
 )
 
 type Command struct {
-	SID           string `json:"sid"`
-	Type          string `json:"type"`
-	Data          string `json:"data"`
-	Channel       string `json:"channel"`
-	Token         string `json:"token"`
-	Auth          Auth   `json:"auth"`
-	Base          string `json:"base"`
-	IsSystemEvent bool   `json:"-"`
+	SID           string   `json:"sid"`
+	ID            string   `json:"id,omitempty"`
+	Type          string   `json:"type"`
+	Data          string   `json:"data"`
+	Channel       string   `json:"channel"`
+	Token         string   `json:"token"`
+	Auth          Auth     `json:"auth"`
+	Base          string   `json:"base"`
+	Filter        [][]any  `json:"filter,omitempty"`
+	Fields        []string `json:"fields,omitempty"`
+	IsSystemEvent bool     `json:"-"`
+}
+
+// DocumentUpdate is published for the updated documents, the event's data is
+// the document and its fields are the changed ones
+type DocumentUpdate struct {
+	Document any
+	Fields   []string
 }
 
 func (msg Command) IsDBEvent() bool {
 	switch msg.Type {
-	case MsgTypeDBCreated, MsgTypeDBUpdated, MsgTypeDBDeleted:
+	case MsgTypeDBCreated, MsgTypeDBUpdated, MsgTypeDBDeleted, MsgTypeDBLeft:
 		return true
 	}
 	return false
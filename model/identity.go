@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// Identity links a user to their account on an external login provider. The
+// Subject is the provider's unique ID of the user, the email can change.
+type Identity struct {
+	ID        string    `json:"id"`
+	AccountID string    `json:"accountId"`
+	UserID    string    `json:"userId"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	Linked    time.Time `json:"linked"`
+}
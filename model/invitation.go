@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// Invitation invites an email to join an account with a role. It's accepted
+// with its token which is only emailed to the invitee.
+type Invitation struct {
+	ID        string    `json:"id"`
+	AccountID string    `json:"accountId"`
+	Email     string    `json:"email"`
+	Role      int       `json:"role"`
+	TokenHash string    `json:"-"`
+	InvitedBy string    `json:"invitedBy"`
+	Created   time.Time `json:"created"`
+	Expires   time.Time `json:"expires"`
+}
+
+// IsExpired returns true if the invitation can no longer be accepted
+func (inv Invitation) IsExpired() bool {
+	return inv.Expires.Before(time.Now())
+}
@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// PresenceMember is a realtime connection that joined a channel. It's the
+// data of the presence_* events published to the channel.
+type PresenceMember struct {
+	ConnectionID string         `json:"connectionId"`
+	UserID       string         `json:"userId"`
+	Metadata     map[string]any `json:"metadata"`
+	Joined       time.Time      `json:"joined"`
+	LastSeen     time.Time      `json:"lastSeen"`
+}
+
+// Presence lists the members of a channel across all instances
+type Presence struct {
+	Channel string           `json:"channel"`
+	Count   int              `json:"count"`
+	Members []PresenceMember `json:"members"`
+}
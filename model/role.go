@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// Role is a named role of a database. Users having the role have its level
+// as their Role, the levels of the named roles are between 1 and 99.
+type Role struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Level       int       `json:"level"`
+	Permissions []string  `json:"permissions"`
+	Created     time.Time `json:"created"`
+}
+
+// Can returns true if the role has the permission
+func (r Role) Can(permission string) bool {
+	for _, p := range r.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
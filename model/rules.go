@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Rules are the declarative permissions of a collection. Each rule is a list
+// of query clauses (see database.ParseQuery) a document must satisfy to be
+// read or written. Values and fields prefixed by "auth." reference the
+// caller, i.e. [["ownerId", "=", "auth.userId"]] or [["auth.role", ">=", 50]].
+// The teams set with /setteams are matched with [["teamId", "in", "auth.teams"]].
+//
+// An empty rule keeps the permissions from the collection's name.
+type Rules struct {
+	Read  [][]any `json:"read,omitempty"`
+	Write [][]any `json:"write,omitempty"`
+}
+
+// CollectionRules are the rules enforced when reading and writing the
+// documents of a collection
+type CollectionRules struct {
+	ID         string    `json:"id"`
+	Collection string    `json:"collection"`
+	Rules      Rules     `json:"rules"`
+	Updated    time.Time `json:"updated"`
+}
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// Schema is a JSON-Schema-like definition of a value. A collection's schema
+// is an object schema describing its documents' fields.
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Default              any                `json:"default,omitempty"`
+}
+
+// CollectionSchema is the schema enforced when creating and updating the
+// documents of a collection
+type CollectionSchema struct {
+	ID         string    `json:"id"`
+	Collection string    `json:"collection"`
+	Schema     Schema    `json:"schema"`
+	Updated    time.Time `json:"updated"`
+}
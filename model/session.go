@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// Session is a signed in device of a user. Its refresh token is rotated each
+// time it's used to get a new access token.
+type Session struct {
+	ID          string    `json:"id"`
+	AccountID   string    `json:"accountId"`
+	UserID      string    `json:"userId"`
+	RefreshHash string    `json:"-"`
+	Created     time.Time `json:"created"`
+	Refreshed   time.Time `json:"refreshed"`
+	Expires     time.Time `json:"expires"`
+}
+
+// IsExpired returns true if the session's refresh token has expired
+func (s Session) IsExpired() bool {
+	return s.Expires.Before(time.Now())
+}
+
+// Tokens are returned when signing in and refreshing a session. The access
+// token is short-lived, the refresh token is used once to get new tokens.
+//
+// Only the Challenge is set when the user has two-factor authentication, it's
+// exchanged for the tokens with a valid code.
+type Tokens struct {
+	Token        string    `json:"token,omitempty"`
+	RefreshToken string    `json:"refreshToken,omitempty"`
+	Expires      time.Time `json:"expires"`
+	Challenge    string    `json:"challenge,omitempty"`
+}
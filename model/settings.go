@@ -0,0 +1,105 @@
+package model
+
+import "strings"
+
+// DatabaseSettings are the security settings of a database
+type DatabaseSettings struct {
+	// RequireRootTwoFactor requires the root users to sign in with two-factor
+	// authentication, including in the web UI
+	RequireRootTwoFactor bool `json:"requireRootTwoFactor"`
+	// EmailVerification sends a verification email to the new users
+	EmailVerification EmailVerification `json:"emailVerification"`
+	// PasswordPolicy is enforced when users set their password
+	PasswordPolicy PasswordPolicy `json:"passwordPolicy"`
+	// ChannelRetention keeps the messages of realtime channels for replay
+	ChannelRetention []ChannelRetention `json:"channelRetention"`
+	// ChannelRules are the permissions of the realtime channels
+	ChannelRules []ChannelRule `json:"channelRules"`
+}
+
+// ChannelRule are the permissions of the realtime channels matching its
+// Pattern, the channels matching no rule can be joined and written to by any
+// connection. The pattern's {name} placeholders match a part of the channel's
+// name and are the fields of the rules' clauses (see Rules), i.e. the pattern
+// user-{id} with the read rule [["id", "=", "auth.userId"]]. An empty rule
+// allows any connection.
+//
+// The JoinFunction is the name of a server-side function approving the joins
+// allowed by the Read rule, its handle(channel, type, params) function must
+// return true.
+type ChannelRule struct {
+	Pattern      string  `json:"pattern"`
+	Read         [][]any `json:"read,omitempty"`
+	Write        [][]any `json:"write,omitempty"`
+	JoinFunction string  `json:"joinFunction,omitempty"`
+}
+
+// ChannelRetention keeps the last messages published to a realtime channel so
+// reconnecting clients receive the ones they missed. The Channel ending with
+// * matches the channels starting with its prefix. A zero limit is not
+// enforced.
+type ChannelRetention struct {
+	Channel     string `json:"channel"`
+	MaxMessages int    `json:"maxMessages"`
+	MaxMinutes  int    `json:"maxMinutes"`
+}
+
+// Matches returns true if the retention applies to the channel
+func (r ChannelRetention) Matches(channel string) bool {
+	if strings.HasSuffix(r.Channel, "*") {
+		return strings.HasPrefix(channel, strings.TrimSuffix(r.Channel, "*"))
+	}
+	return r.Channel == channel
+}
+
+// FindChannelRetention returns the first retention matching the channel
+func FindChannelRetention(retention []ChannelRetention, channel string) (ChannelRetention, bool) {
+	for _, r := range retention {
+		if r.Matches(channel) {
+			return r, true
+		}
+	}
+	return ChannelRetention{}, false
+}
+
+// PasswordPolicy are the requirements of the users' passwords, the zero value
+// accepts any password
+type PasswordPolicy struct {
+	MinLength     int  `json:"minLength"`
+	RequireLower  bool `json:"requireLower"`
+	RequireUpper  bool `json:"requireUpper"`
+	RequireDigit  bool `json:"requireDigit"`
+	RequireSymbol bool `json:"requireSymbol"`
+	// RejectBreached rejects the commonly used passwords found in breaches
+	RejectBreached bool `json:"rejectBreached"`
+}
+
+// EmailVerification are the settings of the email verification of the users
+// registering. The Body is the email's HTML where [link] is replaced by the
+// verification link, the token is appended to the Link as ?token=.
+type EmailVerification struct {
+	Enabled bool `json:"enabled"`
+	// BlockLogin prevents unverified users from signing in
+	BlockLogin bool `json:"blockLogin"`
+	// Permissions of the named roles denied to unverified users
+	Permissions []string `json:"permissions"`
+	Link        string   `json:"link"`
+	FromEmail   string   `json:"fromEmail"`
+	FromName    string   `json:"fromName"`
+	Subject     string   `json:"subject"`
+	Body        string   `json:"body"`
+}
+
+// Denies returns true if an unverified user is denied the permission
+func (ev EmailVerification) Denies(permission string) bool {
+	if !ev.Enabled {
+		return false
+	}
+
+	for _, p := range ev.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
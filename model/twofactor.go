@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// TwoFactor is the TOTP two-factor authentication of a user. It's enabled
+// once the user verified a first code from their authenticator app.
+type TwoFactor struct {
+	UserID string `json:"userId"`
+	// Secret is the TOTP secret encrypted with the app's secret
+	Secret  []byte `json:"-"`
+	Enabled bool   `json:"enabled"`
+	// RecoveryCodes are the hashes of the unused recovery codes
+	RecoveryCodes []string `json:"-"`
+	// LastStep is the time step of the last code used, a code cannot be
+	// used twice
+	LastStep int64     `json:"-"`
+	Created  time.Time `json:"created"`
+}
+
+// TwoFactorSetup is returned when a user starts enrolling their
+// authenticator app
+type TwoFactorSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
@@ -4,7 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/staticbackendhq/core/backend"
 	"github.com/staticbackendhq/core/config"
@@ -14,7 +17,9 @@ import (
 
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/providers/facebook"
+	"github.com/markbates/goth/providers/github"
 	"github.com/markbates/goth/providers/google"
+	"github.com/markbates/goth/providers/openidConnect"
 	"github.com/markbates/goth/providers/twitter"
 )
 
@@ -22,19 +27,31 @@ const (
 	OAuthProviderTwitter  = "twitter"
 	OAuthProviderFacebook = "facebook"
 	OAuthProviderGoogle   = "google"
+	OAuthProviderGitHub   = "github"
 )
 
+// the name of an OpenID Connect provider is part of the OAuth state which is
+// separated by _
+var validProviderName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// defaultOIDCScopes are requested when an OpenID Connect provider has no
+// scopes, the email is required to sign in
+var defaultOIDCScopes = []string{"openid", "email", "profile"}
+
 type ExternalLogins struct {
 	log *logger.Logger
 }
 
 type ExternalUser struct {
-	Token     string `json:"token"`
-	Email     string `json:"email"`
-	Name      string `json:"name"`
-	FirstName string `json:"first"`
-	LastName  string `json:"last"`
-	AvatarURL string `json:"avatarUrl"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	Challenge    string `json:"challenge,omitempty"`
+	Email        string `json:"email"`
+	Name         string `json:"name"`
+	FirstName    string `json:"first"`
+	LastName     string `json:"last"`
+	AvatarURL    string `json:"avatarUrl"`
+	Linked       bool   `json:"linked,omitempty"`
 }
 
 func (el *ExternalLogins) login() http.Handler {
@@ -141,7 +158,7 @@ func (el *ExternalLogins) callback() http.Handler {
 		}
 
 		sess, err := p.UnmarshalSession(value)
-		if err := backend.Cache.GetTyped(reqID+"_session", &value); err != nil {
+		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -169,20 +186,62 @@ func (el *ExternalLogins) callback() http.Handler {
 				return
 			}
 
-			accessTokens := fmt.Sprintf("%s|%s", user.AccessToken, user.AccessTokenSecret)
-			sessionToken, err := el.registerOrLogin(conf, provider, user.Email, accessTokens)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+			if len(user.UserID) == 0 {
+				http.Error(w, "the provider did not return the user's ID", http.StatusBadRequest)
 				return
 			}
 
+			ident := model.Identity{
+				Provider: provider,
+				Subject:  user.UserID,
+				Email:    user.Email,
+			}
+
+			mship := backend.Membership(conf)
+
+			var tokens model.Tokens
+			linked := backend.IsIdentityLink(reqID)
+			if linked {
+				if _, err := mship.LinkIdentity(reqID, ident); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			} else if backend.IsInvitationLink(reqID) {
+				tokens, err = mship.AcceptInvitationWithIdentity(reqID, ident)
+				if errors.Is(err, backend.ErrInvalidInvitation) || errors.Is(err, backend.ErrIdentityInUse) {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				} else if err != nil && !errors.Is(err, backend.ErrTwoFactorRequired) {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			} else if len(user.Email) == 0 {
+				http.Error(w, "the provider did not return an email address", http.StatusBadRequest)
+				return
+			} else {
+				tokens, err = mship.SignInWithIdentity(ident, isEmailVerified(info, user))
+				if errors.Is(err, backend.ErrIdentityNotLinked) {
+					http.Error(w, err.Error(), http.StatusConflict)
+					return
+				} else if errors.Is(err, backend.ErrRootTwoFactorRequired) || errors.Is(err, backend.ErrEmailNotVerified) {
+					http.Error(w, err.Error(), http.StatusForbidden)
+					return
+				} else if err != nil && !errors.Is(err, backend.ErrTwoFactorRequired) {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+
 			extuser := ExternalUser{
-				Token:     sessionToken,
-				Email:     user.Email,
-				Name:      user.Name,
-				FirstName: user.FirstName,
-				LastName:  user.LastName,
-				AvatarURL: user.AvatarURL,
+				Token:        tokens.Token,
+				RefreshToken: tokens.RefreshToken,
+				Challenge:    tokens.Challenge,
+				Email:        user.Email,
+				Name:         user.Name,
+				FirstName:    user.FirstName,
+				LastName:     user.LastName,
+				AvatarURL:    user.AvatarURL,
+				Linked:       linked,
 			}
 
 			if err := backend.Cache.SetTyped("extuser_"+reqID, extuser); err != nil {
@@ -209,50 +268,79 @@ func (*ExternalLogins) getUser(w http.ResponseWriter, r *http.Request) {
 	respond(w, http.StatusOK, extuser)
 }
 
-func (el *ExternalLogins) registerOrLogin(conf model.DatabaseConfig, provider, email, accessToken string) (sessionToken string, err error) {
-	email = strings.ToLower(email)
+// isEmailVerified returns true if the provider verified the user's email,
+// the tenant can trust the emails of a provider not sending a verified claim
+func isEmailVerified(info model.OAuthConfig, user goth.User) bool {
+	if info.TrustEmail {
+		return true
+	}
 
-	exists, err := backend.DB.UserEmailExists(conf.Name, email)
+	for _, claim := range []string{"email_verified", "verified_email"} {
+		switch v := user.RawData[claim].(type) {
+		case bool:
+			if v {
+				return true
+			}
+		case string:
+			if v == "true" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (el *ExternalLogins) link(w http.ResponseWriter, r *http.Request) {
+	conf, auth, err := middleware.Extract(r, true)
 	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if exists {
-		return el.signIn(conf, email)
+	reqID, err := backend.Membership(conf).NewIdentityLink(auth)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	return el.signUp(conf, provider, email, accessToken)
+	// the reqid is used with /oauth/login to link the provider
+	respond(w, http.StatusOK, struct {
+		ReqID   string    `json:"reqid"`
+		Expires time.Time `json:"expires"`
+	}{reqID, time.Now().Add(backend.IdentityLinkTTL)})
 }
 
-func (el *ExternalLogins) signIn(conf model.DatabaseConfig, email string) (sessionToken string, err error) {
-	tok, err := backend.DB.FindUserByEmail(conf.Name, email)
+func (el *ExternalLogins) unlink(w http.ResponseWriter, r *http.Request) {
+	conf, auth, err := middleware.Extract(r, true)
 	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	token := fmt.Sprintf("%s|%s", tok.ID, tok.Token)
+	id := getURLPart(r.URL.Path, 3)
 
-	b, err := backend.GetJWT(token)
-	if err != nil {
+	if err := backend.Membership(conf).UnlinkIdentity(auth, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	sessionToken = string(b)
-	return
+	respond(w, http.StatusOK, true)
 }
 
-func (el *ExternalLogins) signUp(conf model.DatabaseConfig, provider, email, accessToken string) (sessionToken string, err error) {
-	pw := fmt.Sprintf("%s:%s", provider, accessToken)
-
-	mship := backend.Membership(conf)
+func (el *ExternalLogins) identities(w http.ResponseWriter, r *http.Request) {
+	conf, auth, err := middleware.Extract(r, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	b, _, err := mship.CreateAccountAndUser(email, pw, 0)
+	list, err := backend.Membership(conf).ListIdentities(auth)
 	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	sessionToken = string(b)
-	return
+	respond(w, http.StatusOK, list)
 }
 
 func (el *ExternalLogins) getProvider(dbID, provider, reqID string, info model.OAuthConfig) (p goth.Provider, err error) {
@@ -261,16 +349,101 @@ func (el *ExternalLogins) getProvider(dbID, provider, reqID string, info model.O
 		config.Current.AppURL,
 	)
 
+	if info.IsOIDC() {
+		return newOIDCProvider(provider, callbackURL, info)
+	}
+
 	if provider == OAuthProviderTwitter {
 		return twitter.New(info.ConsumerKey, info.ConsumerSecret, callbackURL), nil
 	} else if provider == OAuthProviderFacebook {
 		return facebook.New(info.ConsumerKey, info.ConsumerSecret, callbackURL), nil
 	} else if provider == OAuthProviderGoogle {
 		return google.New(info.ConsumerKey, info.ConsumerSecret, callbackURL), nil
+	} else if provider == OAuthProviderGitHub {
+		return github.New(info.ConsumerKey, info.ConsumerSecret, callbackURL, "user:email"), nil
 	}
 	return twitter.New("", "", ""), errors.New("invalid auth provider")
 }
 
+func isBuiltinProvider(provider string) bool {
+	switch provider {
+	case OAuthProviderTwitter, OAuthProviderFacebook, OAuthProviderGoogle, OAuthProviderGitHub:
+		return true
+	}
+	return false
+}
+
+// validateProvider validates the configuration of a provider before it's
+// enabled, the OpenID Connect providers are named by the tenant and need an
+// issuer while the built-in ones cannot have one
+func validateProvider(provider string, info model.OAuthConfig) error {
+	if len(info.ConsumerKey) == 0 || len(info.ConsumerSecret) == 0 {
+		return errors.New("the client ID and secret are required")
+	}
+
+	if !info.IsOIDC() {
+		if !isBuiltinProvider(provider) {
+			return fmt.Errorf("invalid auth provider: %s", provider)
+		}
+		return nil
+	}
+
+	if isBuiltinProvider(provider) {
+		return fmt.Errorf("the name %s is reserved for a built-in provider", provider)
+	} else if !validProviderName.MatchString(provider) {
+		return fmt.Errorf("invalid provider name %q, use lowercase letters, digits and -", provider)
+	}
+
+	u, err := url.Parse(info.Issuer)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || len(u.Host) == 0 {
+		return fmt.Errorf("invalid issuer URL: %s", info.Issuer)
+	}
+	return nil
+}
+
+// newOIDCProvider discovers the endpoints of an OpenID Connect provider from
+// its issuer and applies the claim mapping
+func newOIDCProvider(name, callbackURL string, info model.OAuthConfig) (goth.Provider, error) {
+	issuer := strings.TrimSuffix(info.Issuer, "/")
+
+	scopes := strings.Fields(info.Scopes)
+	if len(scopes) == 0 {
+		scopes = defaultOIDCScopes
+	}
+
+	discoveryURL := issuer + "/.well-known/openid-configuration"
+
+	p, err := openidConnect.New(info.ConsumerKey, info.ConsumerSecret, callbackURL, discoveryURL, scopes...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to discover the OpenID configuration of %s: %w", issuer, err)
+	}
+
+	// the ID tokens are validated against the discovered issuer, it must be
+	// the one that's configured
+	if strings.TrimSuffix(p.OpenIDConfig.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("the discovered issuer %q does not match %q", p.OpenIDConfig.Issuer, info.Issuer)
+	}
+
+	p.SetName(name)
+
+	c := info.Claims
+	p.UserIdClaims = withClaim(c.ID, p.UserIdClaims)
+	p.EmailClaims = withClaim(c.Email, p.EmailClaims)
+	p.NameClaims = withClaim(c.Name, p.NameClaims)
+	p.FirstNameClaims = withClaim(c.FirstName, p.FirstNameClaims)
+	p.LastNameClaims = withClaim(c.LastName, p.LastNameClaims)
+	p.AvatarURLClaims = withClaim(c.AvatarURL, p.AvatarURLClaims)
+	return p, nil
+}
+
+// withClaim puts a mapped claim before the standard ones
+func withClaim(claim string, claims []string) []string {
+	if len(claim) == 0 {
+		return claims
+	}
+	return append([]string{claim}, claims...)
+}
+
 func (*ExternalLogins) getState(r *http.Request) string {
 	params := r.URL.Query()
 	if params.Encode() == "" && r.Method == http.MethodPost {
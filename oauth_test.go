@@ -0,0 +1,356 @@
+package staticbackend
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/staticbackendhq/core/backend"
+	"github.com/staticbackendhq/core/model"
+)
+
+// newStubIssuer returns an OpenID Connect issuer exchanging the "stub-code"
+// code for an unsigned ID token having the claims
+func newStubIssuer(t *testing.T, clientID string, claims map[string]any) *httptest.Server {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 srv.URL,
+			"authorization_endpoint": srv.URL + "/authorize",
+			"token_endpoint":         srv.URL + "/token",
+			"userinfo_endpoint":      srv.URL + "/userinfo",
+		})
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "stub-code" {
+			http.Error(w, `{"error": "invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		idClaims := map[string]any{
+			"iss": srv.URL,
+			"aud": clientID,
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+		for k, v := range claims {
+			idClaims[k] = v
+		}
+
+		b, err := json.Marshal(idClaims)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		enc := base64.RawURLEncoding
+		idToken := enc.EncodeToString([]byte(`{"alg":"none"}`)) + "." + enc.EncodeToString(b) + ".sig"
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "stub-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idToken,
+		})
+	})
+
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer stub-access-token" {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{"sub": claims["sub"], "given_name": "Stub"})
+	})
+
+	return srv
+}
+
+func enableTestProvider(t *testing.T, name string, info model.OAuthConfig) func() {
+	conf, err := backend.DB.FindDatabase(pubKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cus, err := backend.DB.FindTenant(conf.TenantID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logins, err := cus.GetExternalLogins()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logins[name] = info
+	if err := backend.DB.EnableExternalLogin(cus.ID, logins); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		delete(logins, name)
+		if err := backend.DB.EnableExternalLogin(cus.ID, logins); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// oidcCallback goes through the /oauth/login and /oauth/callback flow
+func oidcCallback(t *testing.T, el *ExternalLogins, provider, reqID string) *httptest.ResponseRecorder {
+	resp := pubReq(t, el.login().ServeHTTP, "GET", "/oauth/login?provider="+provider+"&reqid="+reqID, nil)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTemporaryRedirect {
+		t.Fatalf("expected a redirect got %s", GetResponseBody(t, resp))
+	}
+
+	authURL, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if authURL.Path != "/authorize" {
+		t.Errorf("expected the discovered authorization endpoint got %s", authURL)
+	} else if scope := authURL.Query().Get("scope"); scope != "email openid" {
+		t.Errorf("expected the email and openid scopes got %s", scope)
+	}
+
+	callbackURL := "/oauth/callback/?code=stub-code&state=" + url.QueryEscape(authURL.Query().Get("state"))
+	req := httptest.NewRequest("GET", callbackURL, nil)
+	w := httptest.NewRecorder()
+	el.callback().ServeHTTP(w, req)
+	return w
+}
+
+// oidcLogin goes through the OAuth flow and returns the user from
+// /oauth/get-user
+func oidcLogin(t *testing.T, el *ExternalLogins, provider, reqID string) ExternalUser {
+	w := oidcCallback(t, el, provider, reqID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the callback to succeed got %d %s", w.Code, w.Body.String())
+	}
+
+	resp2 := pubReq(t, el.getUser, "GET", "/oauth/get-user?reqid="+reqID, nil)
+	defer resp2.Body.Close()
+
+	var extuser ExternalUser
+	if err := parseBody(resp2.Body, &extuser); err != nil {
+		t.Fatal(err)
+	}
+	return extuser
+}
+
+func TestOIDCLogin(t *testing.T) {
+	if err := loadTemplates(); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := newStubIssuer(t, "stub-client", map[string]any{"sub": "user-42", "mail": "oidc@test.com"})
+	defer srv.Close()
+
+	defer enableTestProvider(t, "stub-idp", model.OAuthConfig{
+		ConsumerKey:    "stub-client",
+		ConsumerSecret: "stub-secret",
+		Issuer:         srv.URL + "/",
+		Scopes:         "email",
+		Claims:         model.ClaimMapping{Email: "mail"},
+	})()
+
+	el := &ExternalLogins{log: backend.Log}
+
+	extuser := oidcLogin(t, el, "stub-idp", "oidc-signup")
+	if extuser.Email != "oidc@test.com" {
+		t.Errorf("expected the mapped email claim got %s", extuser.Email)
+	} else if extuser.FirstName != "Stub" {
+		t.Errorf("expected the first name from the userinfo got %s", extuser.FirstName)
+	} else if s := meStatus(t, extuser.Token); s != http.StatusOK {
+		t.Errorf("expected the token to be valid got %d", s)
+	}
+
+	// signing in again uses the existing user
+	extuser2 := oidcLogin(t, el, "stub-idp", "oidc-signin")
+	if len(extuser2.Token) == 0 || extuser2.Token == extuser.Token {
+		t.Errorf("expected a new token for the same user got %v", extuser2)
+	}
+}
+
+func meEmail(t *testing.T, token string) string {
+	resp := tokenReq(t, mship.me, "GET", "/me", token, nil)
+	defer resp.Body.Close()
+
+	var auth model.Auth
+	if err := parseBody(resp.Body, &auth); err != nil {
+		t.Fatal(err)
+	}
+	return auth.Email
+}
+
+func TestOIDCDoesNotMergeUnverifiedEmail(t *testing.T) {
+	if err := loadTemplates(); err != nil {
+		t.Fatal(err)
+	}
+
+	signIn(t, mship.register, "/register", "merge@test.com")
+
+	unverified := newStubIssuer(t, "stub-client", map[string]any{"sub": "merge-1", "email": "merge@test.com"})
+	defer unverified.Close()
+
+	verified := newStubIssuer(t, "stub-client", map[string]any{"sub": "merge-2", "email": "Merge@test.com", "email_verified": true})
+	defer verified.Close()
+
+	info := model.OAuthConfig{ConsumerKey: "stub-client", ConsumerSecret: "stub-secret", Issuer: unverified.URL, Scopes: "email"}
+	defer enableTestProvider(t, "stub-unverified", info)()
+
+	info.Issuer = verified.URL
+	defer enableTestProvider(t, "stub-verified", info)()
+
+	el := &ExternalLogins{log: backend.Log}
+
+	if w := oidcCallback(t, el, "stub-unverified", "merge-unverified"); w.Code != http.StatusConflict {
+		t.Errorf("expected an unverified email not to be merged got %d %s", w.Code, w.Body.String())
+	}
+
+	extuser := oidcLogin(t, el, "stub-verified", "merge-verified")
+	if email := meEmail(t, extuser.Token); email != "merge@test.com" {
+		t.Fatalf("expected a verified email to sign in the existing user got %s", email)
+	}
+
+	resp := tokenReq(t, el.identities, "GET", "/oauth/identities", extuser.Token, nil)
+	defer resp.Body.Close()
+
+	var list []model.Identity
+	if err := parseBody(resp.Body, &list); err != nil {
+		t.Fatal(err)
+	} else if len(list) != 1 || list[0].Provider != "stub-verified" || list[0].Subject != "merge-2" {
+		t.Errorf("expected the verified identity to be linked got %v", list)
+	}
+}
+
+func TestLinkIdentity(t *testing.T) {
+	if err := loadTemplates(); err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := signIn(t, mship.register, "/register", "link@test.com")
+	other := signIn(t, mship.register, "/register", "link-other@test.com")
+
+	srv := newStubIssuer(t, "stub-client", map[string]any{"sub": "link-1", "email": "link@example.com"})
+	defer srv.Close()
+
+	info := model.OAuthConfig{ConsumerKey: "stub-client", ConsumerSecret: "stub-secret", Issuer: srv.URL, Scopes: "email"}
+	defer enableTestProvider(t, "stub-link", info)()
+
+	el := &ExternalLogins{log: backend.Log}
+
+	linkReqID := func(token string) string {
+		resp := tokenReq(t, el.link, "POST", "/oauth/link", token, nil)
+		defer resp.Body.Close()
+
+		var data struct {
+			ReqID string `json:"reqid"`
+		}
+		if err := parseBody(resp.Body, &data); err != nil {
+			t.Fatal(err)
+		} else if len(data.ReqID) == 0 {
+			t.Fatal("expected a link request ID")
+		}
+		return data.ReqID
+	}
+
+	reqID := linkReqID(tokens.Token)
+	if extuser := oidcLogin(t, el, "stub-link", reqID); !extuser.Linked || len(extuser.Token) > 0 {
+		t.Errorf("expected the identity to be linked without a session got %v", extuser)
+	}
+
+	// a link request is used once, it's now a sign in
+	if extuser := oidcLogin(t, el, "stub-link", reqID); extuser.Linked || len(extuser.Token) == 0 {
+		t.Errorf("expected the link request to be used once got %v", extuser)
+	}
+
+	// the identity cannot be linked to another user
+	if w := oidcCallback(t, el, "stub-link", linkReqID(other.Token)); w.Code != http.StatusBadRequest {
+		t.Errorf("expected an error linking an identity of another user got %d %s", w.Code, w.Body.String())
+	}
+
+	extuser := oidcLogin(t, el, "stub-link", "link-signin")
+	if email := meEmail(t, extuser.Token); email != "link@test.com" {
+		t.Errorf("expected the linked identity to sign in the user got %s", email)
+	}
+
+	resp := tokenReq(t, el.identities, "GET", "/oauth/identities", tokens.Token, nil)
+	defer resp.Body.Close()
+
+	var list []model.Identity
+	if err := parseBody(resp.Body, &list); err != nil {
+		t.Fatal(err)
+	} else if len(list) != 1 {
+		t.Fatalf("expected 1 identity got %v", list)
+	}
+
+	resp2 := tokenReq(t, el.unlink, "DELETE", "/oauth/unlink/"+list[0].ID, tokens.Token, nil)
+	defer resp2.Body.Close()
+
+	if resp2.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp2))
+	}
+
+	// once unlinked the identity signs up a new user
+	extuser = oidcLogin(t, el, "stub-link", "link-signup")
+	if email := meEmail(t, extuser.Token); email != "link@example.com" {
+		t.Errorf("expected the unlinked identity to sign up a new user got %s", email)
+	}
+}
+
+func TestOIDCIssuerMismatch(t *testing.T) {
+	srv := newStubIssuer(t, "stub-client", map[string]any{"sub": "user-42"})
+	defer srv.Close()
+
+	info := model.OAuthConfig{
+		ConsumerKey:    "stub-client",
+		ConsumerSecret: "stub-secret",
+		Issuer:         strings.Replace(srv.URL, "127.0.0.1", "localhost", 1),
+	}
+	defer enableTestProvider(t, "stub-mismatch", info)()
+
+	el := &ExternalLogins{log: backend.Log}
+
+	resp := pubReq(t, el.login().ServeHTTP, "GET", "/oauth/login?provider=stub-mismatch&reqid=oidc-mismatch", nil)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected an error for a mismatched issuer got %d", resp.StatusCode)
+	}
+}
+
+func TestValidateProvider(t *testing.T) {
+	keys := model.OAuthConfig{ConsumerKey: "key", ConsumerSecret: "secret"}
+	oidc := model.OAuthConfig{ConsumerKey: "key", ConsumerSecret: "secret", Issuer: "https://auth.test.com/realms/app"}
+
+	tests := []struct {
+		name     string
+		provider string
+		info     model.OAuthConfig
+		valid    bool
+	}{
+		{"built-in", OAuthProviderGitHub, keys, true},
+		{"oidc", "keycloak", oidc, true},
+		{"missing secret", OAuthProviderGoogle, model.OAuthConfig{ConsumerKey: "key"}, false},
+		{"unknown without issuer", "keycloak", keys, false},
+		{"built-in with issuer", OAuthProviderGoogle, oidc, false},
+		{"underscore in name", "my_idp", oidc, false},
+		{"relative issuer", "keycloak", model.OAuthConfig{ConsumerKey: "key", ConsumerSecret: "secret", Issuer: "/realms/app"}, false},
+	}
+
+	for _, tt := range tests {
+		if err := validateProvider(tt.provider, tt.info); (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid to be %t got %v", tt.name, tt.valid, err)
+		}
+	}
+}
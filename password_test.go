@@ -0,0 +1,114 @@
+package staticbackend
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/staticbackendhq/core/backend"
+	"github.com/staticbackendhq/core/model"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	settings, err := backend.DB.GetSettings(dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.DB.SaveSettings(dbName, settings)
+
+	policy := model.PasswordPolicy{MinLength: 10, RequireDigit: true, RejectBreached: true}
+	resp := dbReq(t, mship.passwordPolicy, "POST", "/settings/password-policy", policy, true)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+	resp.Body.Close()
+
+	tests := []struct {
+		password string
+		status   int
+	}{
+		{"short1", http.StatusBadRequest},
+		{"no-digits-here", http.StatusBadRequest},
+		{"password123", http.StatusBadRequest},
+		{"policy-pass-42", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		login := model.Login{Email: "policy@test.com", Password: tt.password}
+		resp := pubReq(t, mship.register, "POST", "/register", login)
+		if resp.StatusCode != tt.status {
+			t.Errorf("expected status %d for %s got %d", tt.status, tt.password, resp.StatusCode)
+		}
+		resp.Body.Close()
+	}
+
+	invalid := model.PasswordPolicy{MinLength: 500}
+	resp = dbReq(t, mship.passwordPolicy, "POST", "/settings/password-policy", invalid, true)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid policy got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func TestLoginLockout(t *testing.T) {
+	addr := fmt.Sprintf("lockout%d@test.com", time.Now().UnixNano())
+	signIn(t, mship.register, "/register", addr)
+
+	wrong := model.Login{Email: addr, Password: "wrong-pw"}
+	for i := 0; i < backend.LoginAttemptsBeforeLockout; i++ {
+		resp := pubReq(t, mship.login, "POST", "/login", wrong)
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected status 401 on attempt %d got %d", i+1, resp.StatusCode)
+		}
+		resp.Body.Close()
+	}
+
+	// the correct password is locked out too
+	resp := pubReq(t, mship.login, "POST", "/login", model.Login{Email: addr, Password: "sessions-pw"})
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429 got %d", resp.StatusCode)
+	} else if len(resp.Header.Get("Retry-After")) == 0 {
+		t.Error("expected a Retry-After header")
+	}
+}
+
+func TestMagicLinkInvalidCode(t *testing.T) {
+	addr := fmt.Sprintf("magic%d@test.com", time.Now().UnixNano())
+	signIn(t, mship.register, "/register", addr)
+
+	data := backend.MagicLinkData{
+		FromEmail: "unit@test.com",
+		FromName:  "Unit Test",
+		Email:     addr,
+		Subject:   "Your magic link",
+		Body:      "<p>[link]</p>",
+		MagicLink: "https://mycustom.link/with-code",
+	}
+	resp := pubReq(t, mship.magicLink, "POST", "/login/magic", data)
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+	resp.Body.Close()
+
+	resp = pubReq(t, mship.magicLink, "GET", "/login/magic?email="+addr+"&code=123", nil)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected an invalid code to be rejected with 401 got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	// in dev mode, the code is always 666333
+	resp = pubReq(t, mship.magicLink, "GET", "/login/magic?email="+addr+"&code=666333", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+	resp.Body.Close()
+
+	resp = pubReq(t, mship.magicLink, "GET", "/login/magic?email="+addr+"&code=666333", nil)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected a used code to be rejected with 401 got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,42 @@
+package staticbackend
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/staticbackendhq/core/backend"
+	"github.com/staticbackendhq/core/cache"
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/middleware"
+)
+
+// getPresence returns the members of a realtime channel across all instances
+func getPresence(w http.ResponseWriter, r *http.Request) {
+	conf, auth, err := middleware.Extract(r, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	channel := getURLPart(r.URL.Path, 2)
+	if len(channel) == 0 {
+		http.Error(w, "missing channel in URL", http.StatusBadRequest)
+		return
+	}
+
+	if err := backend.AuthorizeChannel(conf.Name, channel, auth, false); errors.Is(err, database.ErrChannelDenied) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	p, err := cache.GetPresence(backend.Cache, conf.Name, channel)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respond(w, http.StatusOK, p)
+}
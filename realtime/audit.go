@@ -0,0 +1,37 @@
+package realtime
+
+import (
+	"errors"
+
+	"github.com/staticbackendhq/core/cache"
+	"github.com/staticbackendhq/core/model"
+)
+
+// auditMatcher only lets the root users of the connection's database join the
+// audit channel. The channel is shared by all databases, a connection only
+// receives the events of its database.
+func (b *Broker) auditMatcher(msg model.Command) (matcher, error) {
+	var auth model.Auth
+	if len(msg.Token) > 0 {
+		if a, err := cache.GetSessionAuth(b.pubsub, msg.Token); err == nil {
+			auth = a
+		}
+	}
+
+	if auth.Role < 100 {
+		return nil, errors.New("only root users can join the audit channel")
+	}
+
+	// the tokens are cached for all connections, the token must be from the
+	// connection's database
+	dbName := b.dbName(msg.SID)
+
+	var conf model.DatabaseConfig
+	if err := b.pubsub.GetTyped("base:"+msg.Token, &conf); err != nil || conf.Name != dbName {
+		return nil, errors.New("only root users can join the audit channel")
+	}
+
+	return func(m model.Command) (model.Command, bool) {
+		return m, m.Base == dbName
+	}, nil
+}
@@ -3,6 +3,7 @@ package realtime
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -18,10 +19,23 @@ import (
 // Validator validates a session token
 type Validator func(context.Context, string) (string, error)
 
+// ChannelAuthorizer returns an error if the token is not allowed to join
+// (read) or write to a channel of a database
+type ChannelAuthorizer func(dbName, channel, token string, write bool) error
+
+// sendBufferSize is the number of outbound messages buffered per connection
+// before the connection is considered too slow and gets closed
+const sendBufferSize = 256
+
 // ConnectionData holds a channel for each web socket connection
 type ConnectionData struct {
+	id       string
 	ctx      context.Context
+	cancel   context.CancelFunc
 	messages chan model.Command
+	// lastEventID is the ID of the last message a reconnecting client
+	// received, the messages it missed are replayed when joining channels
+	lastEventID string
 }
 
 // Broker is used to hold all web socket connections
@@ -32,8 +46,14 @@ type Broker struct {
 	clients            map[chan model.Command]string
 	ids                map[string]chan model.Command
 	conf               map[string]context.Context
+	cancels            map[chan model.Command]context.CancelFunc
+	lastEventIDs       map[string]string
 	subscriptions      map[string][]chan bool
+	presence           map[string]map[string]model.PresenceMember
+	presenceOps        chan func()
+	tasks              chan func()
 	validateAuth       Validator
+	authorizeChannel   ChannelAuthorizer
 
 	pubsub cache.Volatilizer
 
@@ -49,43 +69,133 @@ func NewBroker(v Validator, pubsub cache.Volatilizer, log *logger.Logger) *Broke
 		clients:            make(map[chan model.Command]string),
 		ids:                make(map[string]chan model.Command),
 		conf:               make(map[string]context.Context),
+		cancels:            make(map[chan model.Command]context.CancelFunc),
+		lastEventIDs:       make(map[string]string),
 		subscriptions:      make(map[string][]chan bool),
+		presence:           make(map[string]map[string]model.PresenceMember),
+		presenceOps:        make(chan func(), presenceQueueSize),
+		tasks:              make(chan func()),
 		validateAuth:       v,
 		pubsub:             pubsub,
 		log:                log,
 	}
 
 	go b.start()
+	go b.applyPresence()
 
 	return b
 }
 
 func (b *Broker) start() {
+	heartbeat := time.NewTicker(cache.PresenceHeartbeat)
+	defer heartbeat.Stop()
+
 	for {
 		select {
 		case data := <-b.newConnections:
-			id, err := uuid.NewUUID()
-			if err != nil {
-				b.log.Error().Err(err)
-			}
-
-			b.clients[data.messages] = id.String()
-			b.ids[id.String()] = data.messages
-			b.conf[id.String()] = data.ctx
+			b.clients[data.messages] = data.id
+			b.ids[data.id] = data.messages
+			b.conf[data.id] = data.ctx
+			b.cancels[data.messages] = data.cancel
+			b.lastEventIDs[data.id] = data.lastEventID
 
 			msg := model.Command{
 				Type: model.MsgTypeInit,
-				Data: id.String(),
+				Data: data.id,
 			}
 
-			data.messages <- msg
+			b.send(data.messages, msg)
 		case c := <-b.closingConnections:
 			b.unsub(c)
 		case msg := <-b.Broadcast:
 			clients, payload := b.getTargets(msg)
 			for _, c := range clients {
-				c <- payload
+				b.send(c, payload)
 			}
+		case task := <-b.tasks:
+			task()
+		case <-heartbeat.C:
+			b.refreshPresence()
+		}
+	}
+}
+
+// SetChannelAuthorizer sets the function authorizing the connections to join
+// and write to channels, all channels are open when it's not set
+func (b *Broker) SetChannelAuthorizer(fn ChannelAuthorizer) {
+	b.tasks <- func() {
+		b.authorizeChannel = fn
+	}
+}
+
+// authorize replies to the sender with the result of fn once the connection
+// is authorized to access the channel. The authorization runs outside of the
+// Broker's loop since it might query the database or execute a function.
+func (b *Broker) authorize(msg model.Command, channel string, write bool, fn func() model.Command) (sockets []chan model.Command, payload model.Command) {
+	if b.authorizeChannel == nil {
+		return []chan model.Command{b.ids[msg.SID]}, fn()
+	}
+
+	authorizeChannel, dbName := b.authorizeChannel, b.dbName(msg.SID)
+	go func() {
+		err := authorizeChannel(dbName, channel, msg.Token, write)
+
+		b.tasks <- func() {
+			sender, ok := b.ids[msg.SID]
+			if !ok {
+				// the connection closed in the meantime
+				return
+			}
+
+			if err != nil {
+				b.send(sender, model.Command{Type: model.MsgTypeError, Data: err.Error(), Channel: channel})
+				return
+			}
+
+			b.send(sender, fn())
+		}
+	}()
+
+	return nil, payload
+}
+
+// newConnection returns a connection ready to be registered in the Broker.
+// The connection's context is cancelled when the transport's request
+// completes or when the Broker closes a slow connection.
+func (b *Broker) newConnection(r *http.Request) ConnectionData {
+	id, err := uuid.NewUUID()
+	if err != nil {
+		b.log.Error().Err(err)
+	}
+
+	// EventSource sends the header when reconnecting, the query string is
+	// used by the other clients
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if len(lastEventID) == 0 {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	return ConnectionData{
+		id:          id.String(),
+		ctx:         ctx,
+		cancel:      cancel,
+		messages:    make(chan model.Command, sendBufferSize),
+		lastEventID: lastEventID,
+	}
+}
+
+// send queues a message without blocking the Broker. A connection that does
+// not keep up with its messages is closed, the client is expected to
+// reconnect.
+func (b *Broker) send(c chan model.Command, msg model.Command) {
+	select {
+	case c <- msg:
+	default:
+		b.log.Warn().Msgf("closing slow connection: %s", b.clients[c])
+
+		if cancel, ok := b.cancels[c]; ok {
+			cancel()
 		}
 	}
 }
@@ -96,19 +206,30 @@ func (b *Broker) unsub(c chan model.Command) {
 	id, ok := b.clients[c]
 	if !ok {
 		b.log.Info().Msg("cannot find connection id")
+		return
 	}
 
 	subs, ok := b.subscriptions[id]
 	if ok {
 		for _, ch := range subs {
-			ch <- true
+			close(ch)
 		}
 	}
 
+	if cancel, ok := b.cancels[c]; ok {
+		cancel()
+	}
+
+	b.leaveAll(id)
+
 	delete(b.ids, id)
+	delete(b.conf, id)
+	delete(b.cancels, c)
+	delete(b.lastEventIDs, id)
+	delete(b.subscriptions, id)
 }
 
-// Accept turns a request into a web socket request and creates a new
+// Accept turns a request into a Server Sent Event stream and creates a new
 // connection in the Broker
 func (b *Broker) Accept(w http.ResponseWriter, r *http.Request) {
 	// check if writer handles flushing
@@ -125,11 +246,8 @@ func (b *Broker) Accept(w http.ResponseWriter, r *http.Request) {
 	//w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	// each connection has their own message channel
-	messages := make(chan model.Command)
-	data := ConnectionData{
-		ctx:      r.Context(),
-		messages: messages,
-	}
+	data := b.newConnection(r)
+	messages := data.messages
 	b.newConnections <- data
 
 	// make sure we'r removing this connection
@@ -139,7 +257,7 @@ func (b *Broker) Accept(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	// handles the client-side disconnection
-	ctx := r.Context()
+	ctx := data.ctx
 
 	// broadcast messages
 	for {
@@ -153,12 +271,37 @@ func (b *Broker) Accept(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 
+			// the retained messages have an ID for the clients to
+			// replay the ones they missed when reconnecting
+			if len(msg.ID) > 0 {
+				fmt.Fprintf(w, "id: %s\n", msg.ID)
+			}
 			fmt.Fprintf(w, "data: %s\n\n", bytes)
 
 			// flush immediately.
 			flusher.Flush()
 		case <-ctx.Done():
-			b.closingConnections <- messages
+			return
+		}
+	}
+}
+
+// replay sends the retained messages of a channel that a connection missed.
+// Messages published while the channel is replayed might be received twice,
+// their ID identifies them.
+func (b *Broker) replay(sender chan model.Command, done chan bool, token, dbName, channel, lastID string) {
+	msgs, err := b.pubsub.History(token, dbName, channel, lastID)
+	if errors.Is(err, cache.ErrInvalidEventID) {
+		msgs = []model.Command{{Type: model.MsgTypeError, Data: err.Error(), Channel: channel}}
+	} else if err != nil {
+		b.log.Error().Err(err).Msgf("error getting the history of %s", channel)
+		return
+	}
+
+	for _, msg := range msgs {
+		select {
+		case sender <- msg:
+		case <-done:
 			return
 		}
 	}
@@ -195,40 +338,27 @@ func (b *Broker) getTargets(msg model.Command) (sockets []chan model.Command, pa
 
 		payload = model.Command{Type: model.MsgTypeToken, Data: msg.Data}
 	case model.MsgTypeJoin:
-		subs, ok := b.subscriptions[msg.SID]
-		if !ok {
-			subs = make([]chan bool, 0)
-		}
-
-		closesub := make(chan bool)
-
-		subs = append(subs, closesub)
-		b.subscriptions[msg.SID] = subs
-
-		go b.pubsub.Subscribe(sender, msg.Token, msg.Data, closesub)
-
-		joinedMsg := model.Command{
-			Type:    model.MsgTypeJoined,
-			Data:    msg.SID,
-			Channel: msg.Data,
+		match, err := b.joinMatcher(msg)
+		if err != nil {
+			payload = model.Command{Type: model.MsgTypeError, Data: err.Error(), Channel: msg.Data}
+			return
 		}
-		// make sure the subscription had time to kick-off
-		go func(m model.Command) {
-			time.Sleep(250 * time.Millisecond)
-			if err := b.pubsub.Publish(joinedMsg); err != nil {
-				b.log.Error().Err(err)
-			}
-		}(joinedMsg)
 
-		payload = model.Command{Type: model.MsgTypeOk, Data: msg.Data}
+		return b.authorize(msg, msg.Data, false, func() model.Command {
+			return b.subscribe(msg, sender, match)
+		})
 	case model.MsgTypePresence:
-		v, err := b.pubsub.Get(msg.Data)
-		if err != nil {
-			//TODO: Make sure it's because the channel key does not exists
-			v = "0"
+		dbName := b.dbName(msg.SID)
+		return b.authorize(msg, msg.Data, false, func() model.Command {
+			return b.getPresence(dbName, msg.Data)
+		})
+	case model.MsgTypePresenceSet:
+		if err := b.setPresence(msg); err != nil {
+			payload = model.Command{Type: model.MsgTypeError, Data: err.Error()}
+			return
 		}
 
-		payload = model.Command{Type: model.MsgTypePresence, Data: v}
+		payload = model.Command{Type: model.MsgTypeOk, Channel: msg.Channel}
 	case model.MsgTypeChanIn:
 		if len(msg.Channel) == 0 {
 			payload = model.Command{Type: model.MsgTypeError, Data: "no channel was specified"}
@@ -239,15 +369,26 @@ func (b *Broker) getTargets(msg model.Command) (sockets []chan model.Command, pa
 				Data: "you cannot write to database channel",
 			}
 			return
+		} else if strings.EqualFold(msg.Channel, model.AuditChannel) {
+			payload = model.Command{
+				Type: model.MsgTypeError,
+				Data: "you cannot write to the audit channel",
+			}
+			return
 		}
 
-		go func() {
-			if err := b.pubsub.Publish(msg); err != nil {
-				b.log.Error().Err(err)
-			}
-		}()
+		// the database is needed to retain the message
+		msg.Base = b.dbName(msg.SID)
+
+		return b.authorize(msg, msg.Channel, true, func() model.Command {
+			go func() {
+				if err := b.pubsub.Publish(msg); err != nil {
+					b.log.Error().Err(err)
+				}
+			}()
 
-		payload = model.Command{Type: model.MsgTypeOk}
+			return model.Command{Type: model.MsgTypeOk}
+		})
 	default:
 		payload.Type = model.MsgTypeError
 		payload.Data = fmt.Sprintf(`%s command not found`, msg.Type)
@@ -255,3 +396,74 @@ func (b *Broker) getTargets(msg model.Command) (sockets []chan model.Command, pa
 
 	return
 }
+
+// subscribe subscribes the sender to the channel it joins, tracks its
+// presence and replays the messages it missed. The messages of a filtered
+// channel are delivered once they pass match, all of them when it's nil.
+func (b *Broker) subscribe(msg model.Command, sender chan model.Command, match matcher) model.Command {
+	subs, ok := b.subscriptions[msg.SID]
+	if !ok {
+		subs = make([]chan bool, 0)
+	}
+
+	closesub := make(chan bool)
+
+	subs = append(subs, closesub)
+	b.subscriptions[msg.SID] = subs
+
+	// the subscription never blocks on a slow connection
+	target := make(chan model.Command)
+	go b.forward(target, sender, match, closesub)
+
+	go b.pubsub.Subscribe(target, msg.Token, msg.Data, closesub)
+
+	dbName, member := b.join(msg)
+
+	// the client can replay a channel from its last message ID
+	lastID := msg.ID
+	if len(lastID) == 0 {
+		lastID = b.lastEventIDs[msg.SID]
+	}
+
+	joinedMsg := model.Command{
+		Type:    model.MsgTypeJoined,
+		Data:    msg.SID,
+		Channel: msg.Data,
+		Base:    dbName,
+	}
+	// make sure the subscription had time to kick-off
+	go func(m model.Command) {
+		time.Sleep(250 * time.Millisecond)
+
+		if len(lastID) > 0 {
+			b.replay(target, closesub, msg.Token, dbName, m.Channel, lastID)
+		}
+
+		if err := b.pubsub.Publish(joinedMsg); err != nil {
+			b.log.Error().Err(err)
+		}
+
+		err := cache.PublishPresence(b.pubsub, dbName, m.Channel, model.MsgTypePresenceJoined, member)
+		if err != nil {
+			b.log.Error().Err(err).Msg("error publishing presence event")
+		}
+	}(joinedMsg)
+
+	return model.Command{Type: model.MsgTypeOk, Data: msg.Data}
+}
+
+// getPresence returns the members of a channel
+func (b *Broker) getPresence(dbName, channel string) model.Command {
+	p, err := cache.GetPresence(b.pubsub, dbName, channel)
+	if err != nil {
+		b.log.Error().Err(err).Msg("error getting channel presence")
+		return model.Command{Type: model.MsgTypeError, Data: "unable to get the channel presence"}
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return model.Command{Type: model.MsgTypeError, Data: "unable to get the channel presence"}
+	}
+
+	return model.Command{Type: model.MsgTypePresence, Data: string(data), Channel: channel}
+}
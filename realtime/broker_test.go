@@ -0,0 +1,100 @@
+package realtime
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/staticbackendhq/core/cache"
+	"github.com/staticbackendhq/core/config"
+	"github.com/staticbackendhq/core/logger"
+	"github.com/staticbackendhq/core/model"
+)
+
+func TestBrokerClosesSlowConnection(t *testing.T) {
+	log := logger.Get(config.LoadConfig())
+	pubsub := cache.NewDevCache(log)
+
+	validate := func(ctx context.Context, key string) (string, error) {
+		return key, nil
+	}
+	b := NewBroker(validate, pubsub, log)
+
+	// the client never reads its messages past the join reply
+	data := b.newConnection(httptest.NewRequest("GET", "/ws", nil))
+	b.newConnections <- data
+
+	b.Broadcast <- model.Command{SID: data.id, Type: model.MsgTypeJoin, Data: "slow-room"}
+
+	timeout := time.After(3 * time.Second)
+	for joined := false; !joined; {
+		select {
+		case msg := <-data.messages:
+			joined = msg.Type == model.MsgTypeOk
+		case <-timeout:
+			t.Fatal("expected the join to be replied")
+		}
+	}
+
+	// the subscription starts in the background
+	time.Sleep(300 * time.Millisecond)
+
+	for i := 0; i < 2*sendBufferSize; i++ {
+		msg := model.Command{Type: model.MsgTypeChanOut, Data: "hi", Channel: "slow-room"}
+		if err := pubsub.Publish(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case <-data.ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the slow connection to be closed")
+	}
+}
+
+func TestBrokerKeepsLeavesWhenPresenceQueueIsFull(t *testing.T) {
+	log := logger.Get(config.LoadConfig())
+	pubsub := cache.NewDevCache(log)
+
+	validate := func(ctx context.Context, key string) (string, error) {
+		return key, nil
+	}
+	b := NewBroker(validate, pubsub, log)
+
+	member := model.PresenceMember{ConnectionID: "leaving", Joined: time.Now(), LastSeen: time.Now()}
+	if err := cache.SetPresence(pubsub, "", "busy-room", member); err != nil {
+		t.Fatal(err)
+	}
+
+	// the cache is slow, the queue fills up behind the pending update
+	unblock := make(chan bool)
+	b.presenceOps <- func() { <-unblock }
+	for i := 0; i < presenceQueueSize; i++ {
+		b.presenceOps <- func() {}
+	}
+
+	b.tasks <- func() {
+		b.presence[member.ConnectionID] = map[string]model.PresenceMember{"busy-room": member}
+		b.leaveAll(member.ConnectionID)
+	}
+
+	close(unblock)
+
+	timeout := time.After(3 * time.Second)
+	for {
+		p, err := cache.GetPresence(pubsub, "", "busy-room")
+		if err != nil {
+			t.Fatal(err)
+		} else if p.Count == 0 {
+			return
+		}
+
+		select {
+		case <-timeout:
+			t.Fatalf("expected the member to leave got %v", p.Members)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
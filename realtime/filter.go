@@ -0,0 +1,127 @@
+package realtime
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/staticbackendhq/core/database"
+	"github.com/staticbackendhq/core/model"
+)
+
+// parseDocumentFilter returns the filter of a join command, only the
+// database channels can be filtered
+func parseDocumentFilter(msg model.Command) (database.Filter, error) {
+	if len(msg.Filter) == 0 {
+		return nil, nil
+	} else if !strings.HasPrefix(strings.ToLower(msg.Data), "db-") {
+		return nil, errors.New("only the database channels can be filtered")
+	}
+
+	return database.ParseQuery(msg.Filter)
+}
+
+// matcher returns the message of a joined channel to deliver, ok is false
+// when it's not delivered
+type matcher func(msg model.Command) (m model.Command, ok bool)
+
+// joinMatcher returns how the messages of the channel a command joins are
+// filtered, it's nil when they are all delivered
+func (b *Broker) joinMatcher(msg model.Command) (matcher, error) {
+	if strings.EqualFold(msg.Data, model.AuditChannel) {
+		return b.auditMatcher(msg)
+	}
+
+	filter, err := parseDocumentFilter(msg)
+	if err != nil || filter == nil {
+		return nil, err
+	}
+
+	return documentMatcher(filter), nil
+}
+
+// forward delivers the messages of a subscription passing match, all of them
+// when match is nil, until the subscription is closed. Like the Broker's
+// messages they are queued without blocking, the Broker closes a connection
+// that does not keep up.
+func (b *Broker) forward(in, send chan model.Command, match matcher, done chan bool) {
+	for {
+		select {
+		case msg := <-in:
+			if match != nil {
+				var ok bool
+				if msg, ok = match(msg); !ok {
+					continue
+				}
+			}
+
+			select {
+			case send <- msg:
+				continue
+			default:
+			}
+
+			// the connection's buffer is full, the Broker closes it if it's
+			// still full
+			select {
+			case b.tasks <- func() { b.send(send, msg) }:
+			case <-done:
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// documentMatcher delivers the database events matching the filter. An
+// update changing a filtered field of a document no longer matching is
+// delivered as a db_left event, the subscriber might have received the
+// document before. The deleted events only have the document's id, they are
+// delivered whether the document matched or not.
+func documentMatcher(filter database.Filter) matcher {
+	fields := filterFields(filter, make(map[string]bool))
+
+	return func(m model.Command) (model.Command, bool) {
+		if matchDocument(m, filter) {
+			return m, true
+		} else if m.Type != model.MsgTypeDBUpdated {
+			return m, false
+		}
+
+		for _, f := range m.Fields {
+			if fields[f] {
+				m.Type = model.MsgTypeDBLeft
+				return m, true
+			}
+		}
+		return m, false
+	}
+}
+
+// filterFields adds the fields of the filter's clauses, including the nested
+// ones
+func filterFields(filter database.Filter, fields map[string]bool) map[string]bool {
+	for _, clause := range filter {
+		if clause.IsGroup() {
+			filterFields(clause.Clauses, fields)
+		} else {
+			fields[clause.Field] = true
+		}
+	}
+	return fields
+}
+
+// matchDocument returns true if the document of a database event matches
+// the filter, the deleted events always match
+func matchDocument(msg model.Command, filter database.Filter) bool {
+	if msg.Type != model.MsgTypeDBCreated && msg.Type != model.MsgTypeDBUpdated {
+		return true
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(msg.Data), &doc); err != nil {
+		return false
+	}
+	return database.Match(doc, filter)
+}
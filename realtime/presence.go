@@ -0,0 +1,183 @@
+package realtime
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/staticbackendhq/core/cache"
+	"github.com/staticbackendhq/core/middleware"
+	"github.com/staticbackendhq/core/model"
+)
+
+const (
+	// presenceQueueSize is the number of presence updates waiting to be
+	// applied to the cache before the joins and updates are dropped and the
+	// leaves wait for room
+	presenceQueueSize = 1024
+
+	// maxPresenceMetadataSize is the maximum size of a member's metadata
+	maxPresenceMetadataSize = 2048
+)
+
+// dbName returns the database name of a connection
+func (b *Broker) dbName(id string) string {
+	ctx, ok := b.conf[id]
+	if !ok {
+		return ""
+	}
+
+	conf, ok := ctx.Value(middleware.ContextBase).(model.DatabaseConfig)
+	if !ok {
+		return ""
+	}
+	return conf.Name
+}
+
+// join tracks the presence of a connection in the channel it joins. The
+// member's user is set if the connection is authenticated.
+func (b *Broker) join(msg model.Command) (string, model.PresenceMember) {
+	channels, ok := b.presence[msg.SID]
+	if !ok {
+		channels = make(map[string]model.PresenceMember)
+		b.presence[msg.SID] = channels
+	}
+
+	member, ok := channels[msg.Data]
+	if !ok {
+		member = model.PresenceMember{ConnectionID: msg.SID, Joined: time.Now()}
+
+		if len(msg.Token) > 0 {
+			if auth, err := cache.GetSessionAuth(b.pubsub, msg.Token); err == nil {
+				member.UserID = auth.UserID
+			}
+		}
+	}
+
+	member.LastSeen = time.Now()
+	channels[msg.Data] = member
+
+	dbName, channel := b.dbName(msg.SID), msg.Data
+	b.queuePresence(func() {
+		if err := cache.SetPresence(b.pubsub, dbName, channel, member); err != nil {
+			b.log.Error().Err(err).Msg("error setting presence")
+		}
+	})
+
+	return dbName, member
+}
+
+// setPresence replaces the metadata of a connection in a joined channel
+func (b *Broker) setPresence(msg model.Command) error {
+	member, ok := b.presence[msg.SID][msg.Channel]
+	if !ok {
+		return errors.New("you must join the channel first")
+	} else if len(msg.Data) > maxPresenceMetadataSize {
+		return errors.New("the presence metadata is too large")
+	}
+
+	var meta map[string]any
+	if err := json.Unmarshal([]byte(msg.Data), &meta); err != nil {
+		return errors.New("the presence metadata must be a JSON object")
+	}
+
+	member.Metadata = meta
+	b.presence[msg.SID][msg.Channel] = member
+
+	dbName, channel := b.dbName(msg.SID), msg.Channel
+	b.queuePresence(func() {
+		if err := cache.SetPresence(b.pubsub, dbName, channel, member); err != nil {
+			b.log.Error().Err(err).Msg("error setting presence")
+			return
+		}
+
+		err := cache.PublishPresence(b.pubsub, dbName, channel, model.MsgTypePresenceUpdated, member)
+		if err != nil {
+			b.log.Error().Err(err).Msg("error publishing presence event")
+		}
+	})
+	return nil
+}
+
+// leaveAll removes a closing connection from the channels it joined
+func (b *Broker) leaveAll(id string) {
+	dbName := b.dbName(id)
+	for channel, member := range b.presence[id] {
+		channel, member := channel, member
+		b.queueLeave(func() {
+			removed, err := cache.RemovePresence(b.pubsub, dbName, channel, member.ConnectionID)
+			if err != nil {
+				b.log.Error().Err(err).Msg("error removing presence")
+				return
+			} else if !removed {
+				// it timed out already
+				return
+			}
+
+			err = cache.PublishPresence(b.pubsub, dbName, channel, model.MsgTypePresenceLeft, member)
+			if err != nil {
+				b.log.Error().Err(err).Msg("error publishing presence event")
+			}
+		})
+	}
+
+	delete(b.presence, id)
+}
+
+// refreshPresence refreshes the members of this instance and removes the
+// ones that timed out, i.e. their instance stopped without removing them
+func (b *Broker) refreshPresence() {
+	type dbChannel struct{ dbName, channel string }
+	channels := make(map[dbChannel]bool)
+
+	now := time.Now()
+	for id, joined := range b.presence {
+		dbName := b.dbName(id)
+		for channel, member := range joined {
+			member.LastSeen = now
+			joined[channel] = member
+
+			channel, member := channel, member
+			b.queuePresence(func() {
+				if err := cache.SetPresence(b.pubsub, dbName, channel, member); err != nil {
+					b.log.Error().Err(err).Msg("error refreshing presence")
+				}
+			})
+
+			channels[dbChannel{dbName, channel}] = true
+		}
+	}
+
+	b.queuePresence(func() {
+		for c := range channels {
+			if _, err := cache.GetPresence(b.pubsub, c.dbName, c.channel); err != nil {
+				b.log.Error().Err(err).Msg("error removing timed out presence")
+			}
+		}
+	})
+}
+
+// queuePresence queues a presence update without blocking the Broker, the
+// updates are applied in order. A dropped join or refresh is applied by the
+// next refresh.
+func (b *Broker) queuePresence(fn func()) {
+	select {
+	case b.presenceOps <- fn:
+	default:
+		b.log.Warn().Msg("presence queue is full, dropping an update")
+	}
+}
+
+// queueLeave queues the removal of a member, it blocks the Broker until the
+// queue has room. A dropped leave would keep the member present until it
+// times out, and it must be applied after the member's join which is why it
+// does not have its own queue.
+func (b *Broker) queueLeave(fn func()) {
+	b.presenceOps <- fn
+}
+
+func (b *Broker) applyPresence() {
+	for fn := range b.presenceOps {
+		fn()
+	}
+}
@@ -0,0 +1,121 @@
+package realtime
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/staticbackendhq/core/model"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Time allowed to write a message to the peer.
+	writeWait = 10 * time.Second
+
+	// Time allowed to read the next pong message from the peer.
+	pongWait = 60 * time.Second
+
+	// Send pings to peer with this period. Must be less than pongWait.
+	pingPeriod = (pongWait * 9) / 10
+
+	// Maximum message size allowed from peer.
+	maxMessageSize = 64 * 1024
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// connections are authorized via the public key and session token
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+// AcceptWebSocket upgrades a request to a web socket and creates a new
+// connection in the Broker. Commands sent by the client are handled the same
+// way as the ones received for Server Sent Event connections.
+func (b *Broker) AcceptWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader already replied with an HTTP error
+		b.log.Warn().Err(err).Msg("error upgrading to web socket")
+		return
+	}
+
+	data := b.newConnection(r)
+	b.newConnections <- data
+
+	go b.writePump(conn, data)
+
+	b.readPump(conn, data)
+
+	data.cancel()
+	b.closingConnections <- data.messages
+}
+
+// readPump pumps commands from the web socket connection to the Broker.
+//
+// It runs in the request's goroutine, which ensures there is at most one
+// reader on a connection.
+func (b *Broker) readPump(conn *websocket.Conn, data ConnectionData) {
+	defer conn.Close()
+
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		var msg model.Command
+		if err := conn.ReadJSON(&msg); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure, websocket.CloseAbnormalClosure) {
+				b.log.Warn().Err(err).Msg("error reading web socket message")
+			}
+			return
+		}
+
+		// the connection identifies the sender, not the client
+		msg.SID = data.id
+
+		select {
+		case b.Broadcast <- msg:
+		case <-data.ctx.Done():
+			return
+		}
+	}
+}
+
+// writePump pumps messages from the Broker to the web socket connection and
+// pings the client to detect dead connections.
+//
+// A goroutine running writePump is started for each connection, which
+// ensures there is at most one writer on a connection.
+func (b *Broker) writePump(conn *websocket.Conn, data ConnectionData) {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+
+	for {
+		select {
+		case msg := <-data.messages:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteJSON(msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-data.ctx.Done():
+			// the client disconnected or was too slow to receive its messages
+			msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "")
+			conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait))
+			return
+		}
+	}
+}
@@ -0,0 +1,693 @@
+package staticbackend
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/staticbackendhq/core/backend"
+	"github.com/staticbackendhq/core/cache"
+	"github.com/staticbackendhq/core/function"
+	"github.com/staticbackendhq/core/model"
+
+	"github.com/gorilla/websocket"
+)
+
+func wsConnect(t *testing.T) (*websocket.Conn, string) {
+	return wsReconnect(t, "")
+}
+
+// wsReconnect connects with the ID of the last message received
+func wsReconnect(t *testing.T, lastEventID string) (*websocket.Conn, string) {
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL+"?sbpk="+pubKey+"&lastEventId="+lastEventID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	init := wsRead(t, conn)
+	if init.Type != model.MsgTypeInit || len(init.Data) == 0 {
+		t.Fatalf("expected an init message with the connection id got %v", init)
+	}
+	return conn, init.Data
+}
+
+func wsRead(t *testing.T, conn *websocket.Conn) model.Command {
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+
+	var msg model.Command
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+// wsReadType skips the messages until one of the expected type arrives
+func wsReadType(t *testing.T, conn *websocket.Conn, msgType string) model.Command {
+	for {
+		if msg := wsRead(t, conn); msg.Type == msgType {
+			return msg
+		}
+	}
+}
+
+func wsAuth(t *testing.T, conn *websocket.Conn, token string) string {
+	if err := conn.WriteJSON(model.Command{Type: model.MsgTypeAuth, Data: token}); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := wsRead(t, conn)
+	if msg.Type != model.MsgTypeToken {
+		t.Fatalf("expected a token message got %v", msg)
+	}
+	return msg.Data
+}
+
+func TestWebSocketCommands(t *testing.T) {
+	conn, _ := wsConnect(t)
+	defer conn.Close()
+
+	if err := conn.WriteJSON(model.Command{Type: model.MsgTypeEcho, Data: "hello"}); err != nil {
+		t.Fatal(err)
+	} else if msg := wsRead(t, conn); msg.Data != "echo: hello" {
+		t.Errorf("expected the echo reply got %v", msg)
+	}
+
+	if err := conn.WriteJSON(model.Command{Type: model.MsgTypeAuth, Data: "invalid"}); err != nil {
+		t.Fatal(err)
+	} else if msg := wsRead(t, conn); msg.Type != model.MsgTypeError {
+		t.Errorf("expected an error for an invalid token got %v", msg)
+	}
+
+	if err := conn.WriteJSON(model.Command{Type: "unknown"}); err != nil {
+		t.Fatal(err)
+	} else if msg := wsRead(t, conn); msg.Type != model.MsgTypeError {
+		t.Errorf("expected an error for an unknown command got %v", msg)
+	}
+}
+
+func TestWebSocketChannel(t *testing.T) {
+	sender, senderID := wsConnect(t)
+	defer sender.Close()
+
+	receiver, _ := wsConnect(t)
+	defer receiver.Close()
+
+	token := wsAuth(t, receiver, userToken)
+
+	join := model.Command{Type: model.MsgTypeJoin, Data: "ws-room", Token: token}
+	if err := receiver.WriteJSON(join); err != nil {
+		t.Fatal(err)
+	} else if msg := wsRead(t, receiver); msg.Type != model.MsgTypeOk {
+		t.Fatalf("expected an ok reply when joining got %v", msg)
+	}
+
+	// the joined event is published once the subscription is ready
+	wsReadType(t, receiver, model.MsgTypeJoined)
+
+	token = wsAuth(t, sender, userToken)
+
+	// the sender cannot impersonate another connection
+	msg := model.Command{
+		SID:     "spoofed",
+		Type:    model.MsgTypeChanIn,
+		Data:    "hello room",
+		Channel: "ws-room",
+		Token:   token,
+	}
+	if err := sender.WriteJSON(msg); err != nil {
+		t.Fatal(err)
+	} else if reply := wsRead(t, sender); reply.Type != model.MsgTypeOk {
+		t.Fatalf("expected an ok reply when sending got %v", reply)
+	}
+
+	out := wsReadType(t, receiver, model.MsgTypeChanOut)
+	if out.Data != "hello room" || out.SID != senderID {
+		t.Errorf("expected the message from %s got %v", senderID, out)
+	}
+
+	msg.Channel = "db-tasks"
+	if err := sender.WriteJSON(msg); err != nil {
+		t.Fatal(err)
+	} else if reply := wsRead(t, sender); reply.Type != model.MsgTypeError {
+		t.Errorf("expected an error writing to a database channel got %v", reply)
+	}
+}
+
+func wsJoin(t *testing.T, conn *websocket.Conn, channel, token string) {
+	join := model.Command{Type: model.MsgTypeJoin, Data: channel, Token: token}
+	if err := conn.WriteJSON(join); err != nil {
+		t.Fatal(err)
+	} else if msg := wsRead(t, conn); msg.Type != model.MsgTypeOk {
+		t.Fatalf("expected an ok reply when joining got %v", msg)
+	}
+}
+
+func presenceEvent(t *testing.T, conn *websocket.Conn, msgType string) model.PresenceMember {
+	msg := wsReadType(t, conn, msgType)
+
+	var member model.PresenceMember
+	if err := json.Unmarshal([]byte(msg.Data), &member); err != nil {
+		t.Fatal(err)
+	}
+	return member
+}
+
+func TestWebSocketPresence(t *testing.T) {
+	watcher, watcherID := wsConnect(t)
+	defer watcher.Close()
+
+	wsJoin(t, watcher, "presence-room", wsAuth(t, watcher, adminToken))
+	if m := presenceEvent(t, watcher, model.MsgTypePresenceJoined); m.ConnectionID != watcherID {
+		t.Fatalf("expected the watcher to join got %v", m)
+	}
+
+	member, memberID := wsConnect(t)
+	token := wsAuth(t, member, userToken)
+	wsJoin(t, member, "presence-room", token)
+
+	joined := presenceEvent(t, watcher, model.MsgTypePresenceJoined)
+	if joined.ConnectionID != memberID || len(joined.UserID) == 0 {
+		t.Errorf("expected the member to join with its user got %v", joined)
+	}
+
+	set := model.Command{Type: model.MsgTypePresenceSet, Channel: "presence-room", Data: `{"status":"away"}`}
+	if err := member.WriteJSON(set); err != nil {
+		t.Fatal(err)
+	}
+
+	if m := presenceEvent(t, watcher, model.MsgTypePresenceUpdated); m.Metadata["status"] != "away" {
+		t.Errorf("expected the member's metadata got %v", m.Metadata)
+	}
+
+	if err := watcher.WriteJSON(model.Command{Type: model.MsgTypePresence, Data: "presence-room"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var p model.Presence
+	if msg := wsReadType(t, watcher, model.MsgTypePresence); json.Unmarshal([]byte(msg.Data), &p) != nil {
+		t.Fatalf("expected the channel presence got %v", msg)
+	} else if p.Count != 2 || p.Members[0].ConnectionID != watcherID {
+		t.Errorf("expected 2 members ordered by join time got %v", p)
+	}
+
+	resp := tokenReq(t, getPresence, "GET", "/presence/presence-room", userToken, nil)
+	defer resp.Body.Close()
+
+	if err := parseBody(resp.Body, &p); err != nil {
+		t.Fatal(err)
+	} else if p.Count != 2 {
+		t.Errorf("expected 2 members from the API got %v", p)
+	}
+
+	member.Close()
+
+	if m := presenceEvent(t, watcher, model.MsgTypePresenceLeft); m.ConnectionID != memberID {
+		t.Errorf("expected the member to leave got %v", m)
+	}
+}
+
+func TestWebSocketReplay(t *testing.T) {
+	retention := []model.ChannelRetention{{Channel: "replay-*", MaxMessages: 10}}
+	resp := dbReq(t, channelRetention, "POST", "/settings/channel-retention", retention, true)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+	defer func() {
+		resp := dbReq(t, channelRetention, "POST", "/settings/channel-retention", []model.ChannelRetention{}, true)
+		resp.Body.Close()
+	}()
+
+	sender, _ := wsConnect(t)
+	defer sender.Close()
+
+	receiver, _ := wsConnect(t)
+	wsJoin(t, receiver, "replay-room", "")
+	wsReadType(t, receiver, model.MsgTypeJoined)
+
+	send := func(data string) {
+		msg := model.Command{Type: model.MsgTypeChanIn, Data: data, Channel: "replay-room"}
+		if err := sender.WriteJSON(msg); err != nil {
+			t.Fatal(err)
+		} else if reply := wsRead(t, sender); reply.Type != model.MsgTypeOk {
+			t.Fatalf("expected an ok reply when sending got %v", reply)
+		}
+	}
+
+	send("before")
+
+	last := wsReadType(t, receiver, model.MsgTypeChanOut)
+	if len(last.ID) == 0 {
+		t.Fatal("expected the retained message to have an ID")
+	}
+	receiver.Close()
+
+	// the receiver misses those while disconnected
+	send("missed 1")
+	send("missed 2")
+
+	receiver, _ = wsReconnect(t, last.ID)
+	defer receiver.Close()
+
+	wsJoin(t, receiver, "replay-room", "")
+
+	for _, data := range []string{"missed 1", "missed 2"} {
+		if msg := wsReadType(t, receiver, model.MsgTypeChanOut); msg.Data != data {
+			t.Errorf("expected the missed message %s got %v", data, msg)
+		}
+	}
+}
+
+func TestWebSocketChannelRules(t *testing.T) {
+	code := `
+	function handle(channel, type, params) {
+		return params.name === "yes";
+	}`
+	data := model.ExecData{
+		FunctionName: "approve-join",
+		Code:         code,
+		TriggerTopic: "web",
+	}
+	resp := dbReq(t, funexec.add, "POST", "/", data, true)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+	resp.Body.Close()
+
+	looping := model.ExecData{
+		FunctionName: "looping-join",
+		Code:         `function handle() { while (true) {} }`,
+		TriggerTopic: "web",
+	}
+	resp = dbReq(t, funexec.add, "POST", "/", looping, true)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+	resp.Body.Close()
+
+	timeout := function.ApproveTimeout
+	function.ApproveTimeout = 200 * time.Millisecond
+	defer func() { function.ApproveTimeout = timeout }()
+
+	rules := []model.ChannelRule{
+		{
+			Pattern: "private-{id}",
+			Read:    [][]any{{"id", "=", "auth.userId"}},
+			Write:   [][]any{{"id", "=", "auth.userId"}},
+		},
+		{Pattern: "approved-{name}", JoinFunction: "approve-join"},
+		{Pattern: "looping-{name}", JoinFunction: "looping-join"},
+	}
+	resp = dbReq(t, channelRules, "POST", "/settings/channel-rules", rules, true)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+	resp.Body.Close()
+	defer func() {
+		resp := dbReq(t, channelRules, "POST", "/settings/channel-rules", []model.ChannelRule{}, true)
+		resp.Body.Close()
+	}()
+
+	conn, _ := wsConnect(t)
+	defer conn.Close()
+
+	token := wsAuth(t, conn, userToken)
+
+	var auth model.Auth
+	if err := backend.Cache.GetTyped(token, &auth); err != nil {
+		t.Fatal(err)
+	}
+
+	// the replies are read past the joined channels' events
+	send := func(msg model.Command) model.Command {
+		if err := conn.WriteJSON(msg); err != nil {
+			t.Fatal(err)
+		}
+
+		for {
+			reply := wsRead(t, conn)
+			if reply.Type != model.MsgTypeJoined && !strings.HasPrefix(reply.Type, "presence_") {
+				return reply
+			}
+		}
+	}
+
+	deny := func(msg model.Command) {
+		if reply := send(msg); reply.Type != model.MsgTypeError {
+			t.Errorf("expected %s on %s to be denied got %v", msg.Type, msg.Channel+msg.Data, reply)
+		}
+	}
+	allow := func(msg model.Command) {
+		if reply := send(msg); reply.Type != model.MsgTypeOk {
+			t.Fatalf("expected %s on %s to be allowed got %v", msg.Type, msg.Channel+msg.Data, reply)
+		}
+	}
+
+	// the user can only access their own private channel
+	deny(model.Command{Type: model.MsgTypeJoin, Data: "private-someone", Token: token})
+	deny(model.Command{Type: model.MsgTypeChanIn, Data: "hi", Channel: "private-someone", Token: token})
+	deny(model.Command{Type: model.MsgTypeJoin, Data: "private-" + auth.UserID})
+
+	own := "private-" + auth.UserID
+	allow(model.Command{Type: model.MsgTypeJoin, Data: own, Token: token})
+	wsReadType(t, conn, model.MsgTypeJoined)
+
+	allow(model.Command{Type: model.MsgTypeChanIn, Data: "hi", Channel: own, Token: token})
+	if out := wsReadType(t, conn, model.MsgTypeChanOut); out.Data != "hi" {
+		t.Errorf("expected the message hi got %v", out)
+	}
+
+	// the join function approves the joins
+	deny(model.Command{Type: model.MsgTypeJoin, Data: "approved-no", Token: token})
+	allow(model.Command{Type: model.MsgTypeJoin, Data: "approved-yes", Token: token})
+
+	// a join function that never returns is interrupted and denies the join
+	deny(model.Command{Type: model.MsgTypeJoin, Data: "looping-room", Token: token})
+
+	// the approvals are not added to the function's history
+	if fn, err := backend.DB.GetFunctionByName(dbName, "approve-join"); err != nil {
+		t.Fatal(err)
+	} else if len(fn.History) > 0 {
+		t.Errorf("expected the joins not to be added to the history got %v", fn.History)
+	}
+
+	// channels without rules are open
+	allow(model.Command{Type: model.MsgTypeJoin, Data: "open-room"})
+}
+
+func TestWebSocketFilteredDB(t *testing.T) {
+	conn, _ := wsConnect(t)
+	defer conn.Close()
+
+	token := wsAuth(t, conn, adminToken)
+
+	join := model.Command{Type: model.MsgTypeJoin, Data: "chat", Token: token, Filter: [][]any{{"done", "=", false}}}
+	if err := conn.WriteJSON(join); err != nil {
+		t.Fatal(err)
+	} else if reply := wsRead(t, conn); reply.Type != model.MsgTypeError {
+		t.Errorf("expected an error filtering a custom channel got %v", reply)
+	}
+
+	join.Data = "db-livetasks"
+	join.Filter = [][]any{{"done", "~", false}}
+	if err := conn.WriteJSON(join); err != nil {
+		t.Fatal(err)
+	} else if reply := wsRead(t, conn); reply.Type != model.MsgTypeError {
+		t.Errorf("expected an error with an invalid filter got %v", reply)
+	}
+
+	join.Filter = [][]any{{"done", "=", false}}
+	if err := conn.WriteJSON(join); err != nil {
+		t.Fatal(err)
+	} else if reply := wsRead(t, conn); reply.Type != model.MsgTypeOk {
+		t.Fatalf("expected an ok reply when joining got %v", reply)
+	}
+	wsReadType(t, conn, model.MsgTypeJoined)
+
+	add := func(task Task) Task {
+		resp := dbReq(t, db.add, "POST", "/db/livetasks", task)
+		defer resp.Body.Close()
+
+		if resp.StatusCode > 299 {
+			t.Fatal(GetResponseBody(t, resp))
+		}
+
+		var saved Task
+		if err := parseBody(resp.Body, &saved); err != nil {
+			t.Fatal(err)
+		}
+		return saved
+	}
+
+	open := add(Task{Title: "open"})
+
+	var created Task
+	msg := wsReadType(t, conn, model.MsgTypeDBCreated)
+	if err := json.Unmarshal([]byte(msg.Data), &created); err != nil {
+		t.Fatal(err)
+	} else if created.ID != open.ID {
+		t.Errorf("expected the open task to be created got %v", created)
+	}
+
+	add(Task{Title: "closed", Done: true})
+
+	update := map[string]any{"title": "renamed"}
+	resp := dbReq(t, db.update, "PUT", "/db/livetasks/"+open.ID, update)
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+	resp.Body.Close()
+
+	// the events might arrive out of order, the done task does not match the
+	// filter and must not be received
+	var updated *model.Command
+	conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+	for {
+		var msg model.Command
+		if err := conn.ReadJSON(&msg); err != nil {
+			break
+		} else if !msg.IsDBEvent() {
+			continue
+		}
+
+		var task Task
+		if err := json.Unmarshal([]byte(msg.Data), &task); err != nil {
+			t.Fatal(err)
+		}
+
+		if task.Done {
+			t.Errorf("expected the done task to be filtered out got %v", msg)
+		} else if msg.Type == model.MsgTypeDBUpdated && task.ID == open.ID {
+			updated = &msg
+		}
+	}
+
+	if updated == nil {
+		t.Fatal("expected the open task's update")
+	} else if !reflect.DeepEqual(updated.Fields, []string{"title"}) {
+		t.Errorf("expected the changed fields to be [title] got %v", updated.Fields)
+	}
+}
+
+func TestWebSocketFilteredDBLeft(t *testing.T) {
+	conn, _ := wsConnect(t)
+	defer conn.Close()
+
+	join := model.Command{Type: model.MsgTypeJoin, Data: "db-lefttasks", Token: wsAuth(t, conn, adminToken), Filter: [][]any{{"done", "=", false}}}
+	if err := conn.WriteJSON(join); err != nil {
+		t.Fatal(err)
+	} else if reply := wsRead(t, conn); reply.Type != model.MsgTypeOk {
+		t.Fatalf("expected an ok reply when joining got %v", reply)
+	}
+
+	resp := dbReq(t, db.add, "POST", "/db/lefttasks", Task{Title: "open"})
+	defer resp.Body.Close()
+
+	var open Task
+	if err := parseBody(resp.Body, &open); err != nil {
+		t.Fatal(err)
+	}
+
+	// an update moving the task out of the filter is received as left
+	resp2 := dbReq(t, db.update, "PUT", "/db/lefttasks/"+open.ID, map[string]any{"done": true})
+	defer resp2.Body.Close()
+
+	if resp2.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp2))
+	}
+
+	var left Task
+	msg := wsReadType(t, conn, model.MsgTypeDBLeft)
+	if err := json.Unmarshal([]byte(msg.Data), &left); err != nil {
+		t.Fatal(err)
+	} else if left.ID != open.ID || !left.Done {
+		t.Errorf("expected the open task to leave the filter got %v", left)
+	}
+
+	// the deleted events are received whether the task matched or not
+	resp3 := dbReq(t, db.del, "DELETE", "/db/lefttasks/"+open.ID, nil)
+	defer resp3.Body.Close()
+
+	if resp3.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp3))
+	}
+
+	wsReadType(t, conn, model.MsgTypeDBDeleted)
+}
+
+func TestWebSocketDBReadRule(t *testing.T) {
+	conf, err := backend.DB.FindDatabase(pubKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := backend.DB.FindUserByEmail(dbName, admEmail)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := backend.Membership(conf).CreateUser(root.AccountID, "reviewer@test.com", "sessions-pw", 42); err != nil {
+		t.Fatal(err)
+	}
+	tokens := signIn(t, mship.login, "/login", "reviewer@test.com")
+
+	// the role is named after the reviewer's session was created
+	if err := backend.DB.SaveRole(dbName, model.Role{Name: "reviewer", Level: 42}); err != nil {
+		t.Fatal(err)
+	}
+	defer backend.DB.DeleteRole(dbName, "reviewer")
+
+	rules := model.CollectionRules{
+		Collection: "reviewedtasks",
+		Rules:      model.Rules{Read: [][]any{{"auth.role", "in", []any{"reviewer"}}}},
+	}
+	if err := backend.SaveCollectionRules(dbName, rules); err != nil {
+		t.Fatal(err)
+	}
+	defer backend.DeleteCollectionRules(dbName, rules.Collection)
+
+	reviewer, _ := wsConnect(t)
+	defer reviewer.Close()
+	wsJoin(t, reviewer, "db-reviewedtasks", wsAuth(t, reviewer, tokens.Token))
+
+	user, _ := wsConnect(t)
+	defer user.Close()
+	wsJoin(t, user, "db-reviewedtasks", wsAuth(t, user, userToken))
+
+	resp := dbReq(t, db.add, "POST", "/db/reviewedtasks", Task{Title: "reviewed"})
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+
+	wsReadType(t, reviewer, model.MsgTypeDBCreated)
+
+	user.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+	for {
+		var msg model.Command
+		if err := user.ReadJSON(&msg); err != nil {
+			break
+		} else if msg.IsDBEvent() {
+			t.Errorf("expected the user without the role not to receive the event got %v", msg)
+		}
+	}
+}
+
+func TestWebSocketAuditChannel(t *testing.T) {
+	conn, _ := wsConnect(t)
+	defer conn.Close()
+
+	join := model.Command{Type: model.MsgTypeJoin, Data: model.AuditChannel, Token: wsAuth(t, conn, userToken)}
+	if err := conn.WriteJSON(join); err != nil {
+		t.Fatal(err)
+	} else if reply := wsRead(t, conn); reply.Type != model.MsgTypeError {
+		t.Fatalf("expected a user to be denied joining the audit channel got %v", reply)
+	}
+
+	join.Data = "SB-AUDIT"
+	if err := conn.WriteJSON(join); err != nil {
+		t.Fatal(err)
+	} else if reply := wsRead(t, conn); reply.Type != model.MsgTypeError {
+		t.Fatalf("expected a user to be denied joining the audit channel got %v", reply)
+	}
+
+	root, _ := wsConnect(t)
+	defer root.Close()
+
+	wsJoin(t, root, model.AuditChannel, wsAuth(t, root, adminToken))
+	wsReadType(t, root, model.MsgTypeJoined)
+
+	// the events of other databases are not received
+	for _, base := range []string{"otherdb", dbName} {
+		msg := model.Command{
+			SID:     model.SystemID,
+			Type:    model.MsgTypeLoginFailed,
+			Data:    base,
+			Channel: model.AuditChannel,
+			Base:    base,
+		}
+		if err := backend.Cache.Publish(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if msg := wsReadType(t, root, model.MsgTypeLoginFailed); msg.Base != dbName {
+		t.Errorf("expected the event of %s got %v", dbName, msg)
+	}
+
+	// the root token of another database validated on one of its connections
+	other := model.Auth{AccountID: "other-account", UserID: "other-root", Role: 100}
+	if err := cache.SetSessionAuth(backend.Cache, "other-db-root", other, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	} else if err := backend.Cache.SetTyped("base:other-db-root", model.DatabaseConfig{Name: "otherdb"}); err != nil {
+		t.Fatal(err)
+	}
+
+	join = model.Command{Type: model.MsgTypeJoin, Data: model.AuditChannel, Token: "other-db-root"}
+	if err := conn.WriteJSON(join); err != nil {
+		t.Fatal(err)
+	} else if reply := wsRead(t, conn); reply.Type != model.MsgTypeError {
+		t.Fatalf("expected the root user of another database to be denied got %v", reply)
+	}
+}
+
+func TestWebSocketRevokedSession(t *testing.T) {
+	rules := []model.ChannelRule{{Pattern: "private-{id}", Read: [][]any{{"id", "=", "auth.userId"}}}}
+	resp := dbReq(t, channelRules, "POST", "/settings/channel-rules", rules, true)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+	resp.Body.Close()
+	defer func() {
+		resp := dbReq(t, channelRules, "POST", "/settings/channel-rules", []model.ChannelRule{}, true)
+		resp.Body.Close()
+	}()
+
+	tokens := signIn(t, mship.register, "/register", "realtime-session@test.com")
+
+	conn, _ := wsConnect(t)
+	defer conn.Close()
+
+	token := wsAuth(t, conn, tokens.Token)
+
+	auth, err := cache.GetSessionAuth(backend.Cache, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	own := "private-" + auth.UserID
+	wsJoin(t, conn, own, token)
+	wsReadType(t, conn, model.MsgTypeJoined)
+
+	resp = tokenReq(t, mship.logout, "POST", "/logout", tokens.Token, nil)
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+	resp.Body.Close()
+
+	// the connection outlives its session, the lookups refuse the token
+	if _, err := cache.GetSessionAuth(backend.Cache, token); err != cache.ErrSessionEnded {
+		t.Errorf("expected the session to have ended got %v", err)
+	}
+
+	if err := conn.WriteJSON(model.Command{Type: model.MsgTypeJoin, Data: own, Token: token}); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		reply := wsRead(t, conn)
+		if reply.Type == model.MsgTypeJoined || strings.HasPrefix(reply.Type, "presence_") {
+			continue
+		} else if reply.Type != model.MsgTypeError {
+			t.Errorf("expected the signed out user to be denied got %v", reply)
+		}
+		break
+	}
+
+	wsJoin(t, conn, "session-room", token)
+	if m := presenceEvent(t, conn, model.MsgTypePresenceJoined); len(m.UserID) > 0 {
+		t.Errorf("expected the signed out user to join anonymously got %v", m)
+	}
+}
@@ -0,0 +1,47 @@
+package staticbackend
+
+import (
+	"net/http"
+
+	"github.com/staticbackendhq/core/backend"
+	"github.com/staticbackendhq/core/middleware"
+	"github.com/staticbackendhq/core/model"
+)
+
+// channelRetention returns or replaces the retention of the realtime channels
+func channelRetention(w http.ResponseWriter, r *http.Request) {
+	conf, _, err := middleware.Extract(r, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		settings, err := backend.DB.GetSettings(conf.Name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		retention := settings.ChannelRetention
+		if retention == nil {
+			retention = make([]model.ChannelRetention, 0)
+		}
+
+		respond(w, http.StatusOK, retention)
+		return
+	}
+
+	var retention []model.ChannelRetention
+	if err := parseBody(r.Body, &retention); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := backend.SetChannelRetention(conf.Name, retention); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	respond(w, http.StatusOK, true)
+}
@@ -13,7 +13,9 @@ import (
 	"syscall"
 
 	"github.com/staticbackendhq/core/backend"
+	"github.com/staticbackendhq/core/cache"
 	"github.com/staticbackendhq/core/config"
+	"github.com/staticbackendhq/core/database"
 	"github.com/staticbackendhq/core/internal"
 	"github.com/staticbackendhq/core/logger"
 	"github.com/staticbackendhq/core/middleware"
@@ -53,55 +55,9 @@ func Start(c config.AppConfig, log *logger.Logger) {
 	// all services like the Datastore, Filestore, Emailers, etc.
 	backend.Setup(c)
 
-	// websockets
-	//TODO: need to rename the hub.tofix and socket.tofix files
-	// when returning to full WebSocket instead of SSE
-	// should move the hub and socket to the realtime package
-	//hub := newHub(backend.Cache)
-	//go hub.run()
-
-	// Server Send Event, alternative to websocket
-	b := realtime.NewBroker(func(ctx context.Context, key string) (string, error) {
-		//TODO: Experimental, let un-authenticated user connect
-		// useful for an Intercom-like SaaS I'm building.
-		if strings.HasPrefix(key, "__tmp__experimental_public") {
-			// let's create the most minimal authentication possible
-			a := model.Auth{
-				AccountID: internal.RandStringRunes(30),
-				UserID:    internal.RandStringRunes(30),
-				Email:     "exp@tmp.com",
-				Role:      0,
-				Token:     key,
-			}
-
-			if err := backend.Cache.SetTyped(key, a); err != nil {
-				return key, err
-			}
-
-			return key, nil
-		}
-
-		auth, err := middleware.ValidateAuthKey(backend.DB, backend.Cache, ctx, key)
-		if err != nil {
-			return "", err
-		}
-
-		// set base:token useful when executing pubsub event message / function
-		conf, ok := ctx.Value(middleware.ContextBase).(model.DatabaseConfig)
-		if !ok {
-			return "", errors.New("could not find base config")
-		}
-
-		//TODO: Lots of repetition of this, needs to be refactor
-		if err := backend.Cache.SetTyped(key, auth); err != nil {
-			return "", err
-		}
-		if err := backend.Cache.SetTyped("base:"+key, conf); err != nil {
-			return "", err
-		}
-
-		return key, nil
-	}, backend.Cache, log)
+	// realtime broker shared by the WebSocket and Server Sent Event transports
+	b := realtime.NewBroker(validateRealtimeAuth, backend.Cache, log)
+	b.SetChannelAuthorizer(authorizeRealtimeChannel)
 
 	database := &Database{
 		cache: backend.Cache,
@@ -134,12 +90,33 @@ func Start(c config.AppConfig, log *logger.Logger) {
 	m := &membership{log: log}
 
 	http.Handle("/login/magic", middleware.Chain(http.HandlerFunc(m.magicLink), pubWithDB...))
+	http.Handle("/login/2fa", middleware.Chain(http.HandlerFunc(m.loginTwoFactor), pubWithDB...))
 	http.Handle("/login", middleware.Chain(http.HandlerFunc(m.login), pubWithDB...))
 	http.Handle("/register", middleware.Chain(http.HandlerFunc(m.register), pubWithDB...))
+	http.Handle("/refresh", middleware.Chain(http.HandlerFunc(m.refresh), pubWithDB...))
+	http.Handle("/logout/all", middleware.Chain(http.HandlerFunc(m.logoutAll), stdAuth...))
+	http.Handle("/logout", middleware.Chain(http.HandlerFunc(m.logout), stdAuth...))
+	http.Handle("/sessions", middleware.Chain(http.HandlerFunc(m.sessions), stdAuth...))
+	http.Handle("/2fa/setup", middleware.Chain(http.HandlerFunc(m.setupTwoFactor), stdAuth...))
+	http.Handle("/2fa/enable", middleware.Chain(http.HandlerFunc(m.enableTwoFactor), stdAuth...))
+	http.Handle("/2fa/disable", middleware.Chain(http.HandlerFunc(m.disableTwoFactor), stdAuth...))
+	http.Handle("/2fa/recovery-codes", middleware.Chain(http.HandlerFunc(m.recoveryCodes), stdAuth...))
 	http.Handle("/email", middleware.Chain(http.HandlerFunc(m.emailExists), pubWithDB...))
+	http.Handle("/invitations/accept", middleware.Chain(http.HandlerFunc(m.acceptInvitation), pubWithDB...))
+	http.Handle("/invitations/oauth", middleware.Chain(http.HandlerFunc(m.invitationLink), pubWithDB...))
+	http.Handle("/verify-email/resend", middleware.Chain(http.HandlerFunc(m.resendVerification), pubWithDB...))
+	http.Handle("/verify-email", middleware.Chain(http.HandlerFunc(m.verifyEmail), pubWithDB...))
+	http.Handle("/settings/password-policy", middleware.Chain(http.HandlerFunc(m.passwordPolicy), stdRoot...))
+	http.Handle("/settings/channel-retention", middleware.Chain(http.HandlerFunc(channelRetention), stdRoot...))
+	http.Handle("/settings/channel-rules", middleware.Chain(http.HandlerFunc(channelRules), stdRoot...))
+	http.Handle("/settings/email-verification", middleware.Chain(http.HandlerFunc(m.emailVerificationSettings), stdRoot...))
 	http.Handle("/password/resetcode", middleware.Chain(http.HandlerFunc(m.setResetCode), stdRoot...))
 	http.Handle("/password/reset", middleware.Chain(http.HandlerFunc(m.resetPassword), pubWithDB...))
-	//http.Handle("/setrole", chain(http.HandlerFunc(setRole), withDB))
+	http.Handle("/setrole", middleware.Chain(http.HandlerFunc(m.setRole), stdAuth...))
+	http.Handle("/setteams", middleware.Chain(http.HandlerFunc(m.setTeams), stdAuth...))
+	http.Handle("/roles/del/", middleware.Chain(http.HandlerFunc(m.deleteRole), stdRoot...))
+	http.Handle("/roles/save", middleware.Chain(http.HandlerFunc(m.saveRole), stdRoot...))
+	http.Handle("/roles", middleware.Chain(http.HandlerFunc(m.listRoles), stdRoot...))
 	http.Handle("/me", middleware.Chain(http.HandlerFunc(m.me), stdAuth...))
 
 	// oauth handlers
@@ -147,12 +124,17 @@ func Start(c config.AppConfig, log *logger.Logger) {
 	http.Handle("/oauth/login", middleware.Chain(el.login(), pubWithDB...))
 	http.Handle("/oauth/callback/", middleware.Chain(el.callback(), stdPub...))
 	http.Handle("/oauth/get-user", middleware.Chain(http.HandlerFunc(el.getUser), pubWithDB...))
+	http.Handle("/oauth/link", middleware.Chain(http.HandlerFunc(el.link), stdAuth...))
+	http.Handle("/oauth/unlink/", middleware.Chain(http.HandlerFunc(el.unlink), stdAuth...))
+	http.Handle("/oauth/identities", middleware.Chain(http.HandlerFunc(el.identities), stdAuth...))
 
 	http.Handle("/sudogettoken/", middleware.Chain(http.HandlerFunc(m.sudoGetTokenFromAccountID), stdRoot...))
+	http.Handle("/sudosessions/", middleware.Chain(http.HandlerFunc(m.sudoSessions), stdRoot...))
 
 	// database routes
 	http.Handle("/db/", middleware.Chain(http.HandlerFunc(database.dbreq), stdAuth...))
 	http.Handle("/db/count/", middleware.Chain(http.HandlerFunc(database.count), stdAuth...))
+	http.Handle("/db/aggregate/", middleware.Chain(http.HandlerFunc(database.aggregate), stdAuth...))
 	http.Handle("/query/", middleware.Chain(http.HandlerFunc(database.query), stdAuth...))
 	http.Handle("/inc/", middleware.Chain(http.HandlerFunc(database.increase), stdAuth...))
 	http.Handle("/sudoquery/", middleware.Chain(http.HandlerFunc(database.query), stdRoot...))
@@ -181,18 +163,23 @@ func Start(c config.AppConfig, log *logger.Logger) {
 	http.Handle("/account/portal", middleware.Chain(http.HandlerFunc(acct.portal), stdRoot...))
 	http.Handle("/account/users/", middleware.Chain(http.HandlerFunc(acct.deleteUser), stdAuth...))
 	http.Handle("/account/users", middleware.Chain(http.HandlerFunc(acct.addUser), stdAuth...))
+	http.Handle("/account/invitations/", middleware.Chain(http.HandlerFunc(acct.revokeInvitation), stdAuth...))
+	http.Handle("/account/invitations", middleware.Chain(http.HandlerFunc(acct.invitations), stdAuth...))
 	http.Handle("/account/add-db", middleware.Chain(http.HandlerFunc(acct.addDatabase), stdAuth...))
 
+	// API keys
+	keys := &apiKeys{log: log}
+	http.Handle("/apikeys/create", middleware.Chain(http.HandlerFunc(keys.create), stdRoot...))
+	http.Handle("/apikeys/revoke/", middleware.Chain(http.HandlerFunc(keys.revoke), stdRoot...))
+	http.Handle("/apikeys", middleware.Chain(http.HandlerFunc(keys.list), stdRoot...))
+
 	// stripe webhooks
 	swh := stripeWebhook{log: log}
 	http.HandleFunc("/stripe", swh.process)
 
 	http.HandleFunc("/ping", ping)
 
-	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		//TODO: when we move from SSE to full WebSocket re-enable this upgrade
-		//serveWs(log, hub, w, r)
-	})
+	http.Handle("/ws", middleware.Chain(http.HandlerFunc(b.AcceptWebSocket), pubWithDB...))
 
 	http.Handle("/sse/connect", middleware.Chain(http.HandlerFunc(b.Accept), pubWithDB...))
 	receiveMessage := func(w http.ResponseWriter, r *http.Request) {
@@ -220,6 +207,7 @@ func Start(c config.AppConfig, log *logger.Logger) {
 
 	// pubsub
 	http.Handle("/publish-message", middleware.Chain(http.HandlerFunc(publishMessage), stdRoot...))
+	http.Handle("/presence/", middleware.Chain(http.HandlerFunc(getPresence), stdAuth...))
 
 	// extras routes
 	ex := &extras{log: log}
@@ -240,11 +228,20 @@ func Start(c config.AppConfig, log *logger.Logger) {
 	http.HandleFunc("/ui/login", webUI.auth)
 	http.Handle("/ui/accounts", middleware.Chain(http.HandlerFunc(webUI.accounts), stdRoot...))
 	http.Handle("/ui/users/", middleware.Chain(http.HandlerFunc(webUI.users), stdRoot...))
+	http.Handle("/ui/roles", middleware.Chain(http.HandlerFunc(webUI.roles), stdRoot...))
+	http.Handle("/ui/roles/del", middleware.Chain(http.HandlerFunc(webUI.rolesDel), stdRoot...))
+	http.Handle("/ui/apikeys", middleware.Chain(http.HandlerFunc(webUI.apiKeys), stdRoot...))
+	http.Handle("/ui/apikeys/revoke", middleware.Chain(http.HandlerFunc(webUI.apiKeysRevoke), stdRoot...))
+	http.Handle("/ui/2fa", middleware.Chain(http.HandlerFunc(webUI.twoFactor), stdRoot...))
 	http.Handle("/ui/logins", middleware.Chain(http.HandlerFunc(webUI.logins), stdRoot...))
 	http.Handle("/ui/enable-login", middleware.Chain(http.HandlerFunc(webUI.enableExternalLogin), stdRoot...))
 	http.Handle("/ui/db", middleware.Chain(http.HandlerFunc(webUI.dbCols), stdRoot...))
 	http.Handle("/ui/db/save", middleware.Chain(http.HandlerFunc(webUI.dbSave), stdRoot...))
 	http.Handle("/ui/db/del/", middleware.Chain(http.HandlerFunc(webUI.dbDel), stdRoot...))
+	http.Handle("/ui/db/schema", middleware.Chain(http.HandlerFunc(webUI.dbSchema), stdRoot...))
+	http.Handle("/ui/db/schema/del", middleware.Chain(http.HandlerFunc(webUI.dbSchemaDel), stdRoot...))
+	http.Handle("/ui/db/rules", middleware.Chain(http.HandlerFunc(webUI.dbRules), stdRoot...))
+	http.Handle("/ui/db/rules/del", middleware.Chain(http.HandlerFunc(webUI.dbRulesDel), stdRoot...))
 	http.Handle("/ui/db/", middleware.Chain(http.HandlerFunc(webUI.dbDoc), stdRoot...))
 	http.Handle("/ui/fn/new", middleware.Chain(http.HandlerFunc(webUI.fnNew), stdRoot...))
 	http.Handle("/ui/fn/save", middleware.Chain(http.HandlerFunc(webUI.fnSave), stdRoot...))
@@ -373,6 +370,9 @@ func publishMessage(w http.ResponseWriter, r *http.Request) {
 	if err := parseBody(r.Body, &data); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
+	} else if strings.EqualFold(data.Channel, model.AuditChannel) {
+		http.Error(w, "you cannot write to the audit channel", http.StatusBadRequest)
+		return
 	}
 
 	msg := model.Command{
@@ -400,3 +400,76 @@ func getURLPart(s string, idx int) string {
 	}
 	return parts[idx]
 }
+
+// validateRealtimeAuth validates the session token of a realtime connection
+func validateRealtimeAuth(ctx context.Context, key string) (string, error) {
+	//TODO: Experimental, let un-authenticated user connect
+	// useful for an Intercom-like SaaS I'm building.
+	if strings.HasPrefix(key, "__tmp__experimental_public") {
+		// let's create the most minimal authentication possible
+		a := model.Auth{
+			AccountID: internal.RandStringRunes(30),
+			UserID:    internal.RandStringRunes(30),
+			Email:     "exp@tmp.com",
+			Role:      0,
+			Token:     key,
+		}
+
+		if err := backend.Cache.SetTyped(key, a); err != nil {
+			return key, err
+		}
+
+		return key, nil
+	}
+
+	auth, err := middleware.ValidateAuthKey(backend.DB, backend.Cache, ctx, key)
+	if err != nil {
+		return "", err
+	}
+
+	// set base:token useful when executing pubsub event message / function
+	conf, ok := ctx.Value(middleware.ContextBase).(model.DatabaseConfig)
+	if !ok {
+		return "", errors.New("could not find base config")
+	}
+
+	expires, err := middleware.AuthKeyExpiration(key)
+	if err != nil {
+		return "", err
+	}
+
+	// the session and expiration are checked again on every lookup, the
+	// connection outlives the validation
+	if err := cache.SetSessionAuth(backend.Cache, key, auth, expires); err != nil {
+		return "", err
+	}
+
+	//TODO: Lots of repetition of this, needs to be refactor
+	if err := backend.Cache.SetTyped("base:"+key, conf); err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// authorizeRealtimeChannel applies the database's channel rules to the
+// realtime connections joining or writing to a channel
+func authorizeRealtimeChannel(dbName, channel, token string, write bool) error {
+	var auth model.Auth
+	if len(token) > 0 {
+		// an invalid token or an ended session is treated as an anonymous
+		// connection
+		a, err := cache.GetSessionAuth(backend.Cache, token)
+		if err == nil {
+			auth = a
+		}
+	}
+
+	err := backend.AuthorizeChannel(dbName, channel, auth, write)
+	if err == nil || errors.Is(err, database.ErrChannelDenied) {
+		return err
+	}
+
+	backend.Log.Error().Err(err).Msgf("error authorizing the channel %s", channel)
+	return errors.New("unable to authorize the channel")
+}
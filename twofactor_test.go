@@ -0,0 +1,329 @@
+package staticbackend
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/staticbackendhq/core/backend"
+	"github.com/staticbackendhq/core/internal"
+	"github.com/staticbackendhq/core/model"
+)
+
+func totpCode(t *testing.T, secret string, at time.Time) string {
+	code, err := internal.TOTPCode(secret, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func loginChallenge(t *testing.T, email, password string) string {
+	resp := pubReq(t, mship.login, "POST", "/login", model.Login{Email: email, Password: password})
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+
+	var tokens model.Tokens
+	if err := parseBody(resp.Body, &tokens); err != nil {
+		t.Fatal(err)
+	} else if len(tokens.Challenge) == 0 || len(tokens.Token) > 0 {
+		t.Fatalf("expected only a challenge got %v", tokens)
+	}
+	return tokens.Challenge
+}
+
+func verifyChallenge(t *testing.T, challenge, code string) *http.Response {
+	return pubReq(t, mship.loginTwoFactor, "POST", "/login/2fa", twoFactorCode{Challenge: challenge, Code: code})
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	tokens := signIn(t, mship.register, "/register", "2fa@test.com")
+
+	resp := tokenReq(t, mship.setupTwoFactor, "POST", "/2fa/setup", tokens.Token, nil)
+	defer resp.Body.Close()
+
+	var setup model.TwoFactorSetup
+	if err := parseBody(resp.Body, &setup); err != nil {
+		t.Fatal(err)
+	} else if len(setup.Secret) == 0 {
+		t.Fatalf("expected a secret got %v", setup)
+	}
+
+	// the user can still sign in with their password until it's enabled
+	signIn(t, mship.login, "/login", "2fa@test.com")
+
+	now := time.Now()
+	data := twoFactorCode{Code: totpCode(t, setup.Secret, now)}
+	resp2 := tokenReq(t, mship.enableTwoFactor, "POST", "/2fa/enable", tokens.Token, data)
+	defer resp2.Body.Close()
+
+	var codes []string
+	if resp2.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp2))
+	} else if err := parseBody(resp2.Body, &codes); err != nil {
+		t.Fatal(err)
+	} else if len(codes) != 10 {
+		t.Fatalf("expected 10 recovery codes got %v", codes)
+	}
+
+	challenge := loginChallenge(t, "2fa@test.com", "sessions-pw")
+
+	// the code used to enable cannot be used again
+	resp3 := verifyChallenge(t, challenge, data.Code)
+	defer resp3.Body.Close()
+
+	if resp3.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected a used code to return 401 got %s", GetResponseBody(t, resp3))
+	}
+
+	resp4 := verifyChallenge(t, challenge, totpCode(t, setup.Secret, now.Add(30*time.Second)))
+	defer resp4.Body.Close()
+
+	if resp4.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp4))
+	}
+
+	var token string
+	if err := parseBody(resp4.Body, &token); err != nil {
+		t.Fatal(err)
+	} else if s := meStatus(t, token); s != http.StatusOK {
+		t.Errorf("expected the access token to be valid got %d", s)
+	} else if len(resp4.Header.Get("SB-Refresh-Token")) == 0 {
+		t.Error("expected a refresh token")
+	}
+
+	// a challenge is used once
+	resp5 := verifyChallenge(t, challenge, codes[0])
+	defer resp5.Body.Close()
+
+	if resp5.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected a used challenge to return 401 got %s", GetResponseBody(t, resp5))
+	}
+
+	// a recovery code signs in once
+	for i, expected := range []int{http.StatusOK, http.StatusUnauthorized} {
+		resp := verifyChallenge(t, loginChallenge(t, "2fa@test.com", "sessions-pw"), codes[0])
+		defer resp.Body.Close()
+
+		if resp.StatusCode != expected {
+			t.Errorf("recovery code use %d: expected %d got %s", i+1, expected, GetResponseBody(t, resp))
+		}
+	}
+
+	data = twoFactorCode{Code: codes[1]}
+	resp6 := tokenReq(t, mship.disableTwoFactor, "POST", "/2fa/disable", tokens.Token, data)
+	defer resp6.Body.Close()
+
+	if resp6.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp6))
+	}
+
+	signIn(t, mship.login, "/login", "2fa@test.com")
+}
+
+func TestTwoFactorChallengeAttempts(t *testing.T) {
+	tokens := signIn(t, mship.register, "/register", "2fa-attempts@test.com")
+
+	resp := tokenReq(t, mship.setupTwoFactor, "POST", "/2fa/setup", tokens.Token, nil)
+	defer resp.Body.Close()
+
+	var setup model.TwoFactorSetup
+	if err := parseBody(resp.Body, &setup); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	data := twoFactorCode{Code: totpCode(t, setup.Secret, now)}
+	resp2 := tokenReq(t, mship.enableTwoFactor, "POST", "/2fa/enable", tokens.Token, data)
+	defer resp2.Body.Close()
+
+	if resp2.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp2))
+	}
+
+	challenge := loginChallenge(t, "2fa-attempts@test.com", "sessions-pw")
+	for i := 0; i < 5; i++ {
+		resp := verifyChallenge(t, challenge, "000000")
+		resp.Body.Close()
+	}
+
+	resp3 := verifyChallenge(t, challenge, totpCode(t, setup.Secret, now.Add(30*time.Second)))
+	defer resp3.Body.Close()
+
+	if resp3.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected the challenge to end after 5 invalid codes got %s", GetResponseBody(t, resp3))
+	}
+}
+
+func TestTwoFactorUserLockout(t *testing.T) {
+	tokens := signIn(t, mship.register, "/register", "2fa-lockout@test.com")
+
+	resp := tokenReq(t, mship.setupTwoFactor, "POST", "/2fa/setup", tokens.Token, nil)
+	defer resp.Body.Close()
+
+	var setup model.TwoFactorSetup
+	if err := parseBody(resp.Body, &setup); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	data := twoFactorCode{Code: totpCode(t, setup.Secret, now)}
+	resp2 := tokenReq(t, mship.enableTwoFactor, "POST", "/2fa/enable", tokens.Token, data)
+	defer resp2.Body.Close()
+
+	if resp2.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp2))
+	}
+
+	// signing in with the password again does not give more attempts
+	for i := 0; i < backend.TwoFactorAttempts; i++ {
+		resp := verifyChallenge(t, loginChallenge(t, "2fa-lockout@test.com", "sessions-pw"), "000000")
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: expected 401 got %d", i+1, resp.StatusCode)
+		}
+	}
+
+	challenge := loginChallenge(t, "2fa-lockout@test.com", "sessions-pw")
+	resp3 := verifyChallenge(t, challenge, totpCode(t, setup.Secret, now.Add(30*time.Second)))
+	defer resp3.Body.Close()
+
+	if resp3.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected the user to be locked out got %s", GetResponseBody(t, resp3))
+	} else if len(resp3.Header.Get("Retry-After")) == 0 {
+		t.Error("expected a Retry-After header")
+	}
+}
+
+func TestTwoFactorKeepsPasswordFailures(t *testing.T) {
+	addr := "2fa-failures@test.com"
+	tokens := signIn(t, mship.register, "/register", addr)
+
+	resp := tokenReq(t, mship.setupTwoFactor, "POST", "/2fa/setup", tokens.Token, nil)
+	defer resp.Body.Close()
+
+	var setup model.TwoFactorSetup
+	if err := parseBody(resp.Body, &setup); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	data := twoFactorCode{Code: totpCode(t, setup.Secret, now)}
+	resp2 := tokenReq(t, mship.enableTwoFactor, "POST", "/2fa/enable", tokens.Token, data)
+	defer resp2.Body.Close()
+
+	if resp2.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp2))
+	}
+
+	wrong := func() {
+		resp := pubReq(t, mship.login, "POST", "/login", model.Login{Email: addr, Password: "wrong-pw"})
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected status 401 got %d", resp.StatusCode)
+		}
+	}
+
+	for i := 1; i < backend.LoginAttemptsBeforeLockout; i++ {
+		wrong()
+	}
+
+	// the failures are forgotten once the second factor passes
+	challenge := loginChallenge(t, addr, "sessions-pw")
+	resp3 := verifyChallenge(t, challenge, totpCode(t, setup.Secret, now.Add(30*time.Second)))
+	defer resp3.Body.Close()
+
+	if resp3.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp3))
+	}
+
+	for i := 1; i < backend.LoginAttemptsBeforeLockout; i++ {
+		wrong()
+	}
+
+	// but not by the password alone
+	loginChallenge(t, addr, "sessions-pw")
+	wrong()
+
+	resp4 := pubReq(t, mship.login, "POST", "/login", model.Login{Email: addr, Password: "sessions-pw"})
+	defer resp4.Body.Close()
+
+	if resp4.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected the password failures to be kept until the second factor passes got %d", resp4.StatusCode)
+	}
+}
+
+func TestRootTwoFactorRequired(t *testing.T) {
+	conf, err := backend.DB.FindDatabase(pubKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usr := backend.Membership(conf)
+
+	root, err := backend.DB.FindUserByEmail(dbName, admEmail)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth := model.Auth{AccountID: root.AccountID, UserID: root.ID, Email: root.Email, Role: root.Role}
+
+	if err := usr.RequireRootTwoFactor(auth, true); err == nil {
+		t.Fatal("expected an error requiring 2FA without having it enabled")
+	}
+
+	setup, err := usr.SetupTwoFactor(auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.DB.DeleteTwoFactor(dbName, root.ID)
+
+	if _, err := usr.EnableTwoFactor(auth, totpCode(t, setup.Secret, time.Now())); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := usr.RequireRootTwoFactor(auth, true); err != nil {
+		t.Fatal(err)
+	}
+	defer usr.RequireRootTwoFactor(auth, false)
+
+	// a root user without 2FA cannot sign in
+	if _, _, err := usr.CreateUser(root.AccountID, "root-no-2fa@test.com", "sessions-pw", 100); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := pubReq(t, mship.login, "POST", "/login", model.Login{Email: "root-no-2fa@test.com", Password: "sessions-pw"})
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected a root user without 2FA to get 403 got %s", GetResponseBody(t, resp))
+	}
+
+	// nor through an external login
+	if err := loadTemplates(); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := newStubIssuer(t, "stub-client", map[string]any{"sub": "root-1", "email": "root-no-2fa@test.com", "email_verified": true})
+	defer srv.Close()
+
+	defer enableTestProvider(t, "stub-root", model.OAuthConfig{ConsumerKey: "stub-client", ConsumerSecret: "stub-secret", Issuer: srv.URL, Scopes: "email"})()
+
+	el := &ExternalLogins{log: backend.Log}
+	if w := oidcCallback(t, el, "stub-root", "root-no-2fa"); w.Code != http.StatusForbidden {
+		t.Errorf("expected an external login of a root user without 2FA to get 403 got %d %s", w.Code, w.Body.String())
+	}
+
+	// the root user having 2FA gets a challenge
+	loginChallenge(t, admEmail, password)
+
+	// the root users cannot disable their 2FA while it's required
+	if err := usr.DisableTwoFactor(auth, totpCode(t, setup.Secret, time.Now().Add(30*time.Second))); err == nil {
+		t.Error("expected an error disabling 2FA while it's required")
+	}
+}
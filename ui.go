@@ -2,6 +2,7 @@ package staticbackend
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -9,6 +10,7 @@ import (
 	"time"
 
 	"github.com/staticbackendhq/core/backend"
+	"github.com/staticbackendhq/core/database"
 	"github.com/staticbackendhq/core/logger"
 	"github.com/staticbackendhq/core/middleware"
 	"github.com/staticbackendhq/core/model"
@@ -64,11 +66,30 @@ func (x ui) auth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, err := middleware.ValidateRootToken(backend.DB, conf.Name, token); err != nil {
+	tok, err := middleware.ValidateRootToken(backend.DB, conf.Name, token)
+	if err != nil {
 		render(w, r, "login.html", nil, &Flash{Type: "danger", Message: "invalid public key / token"}, x.log)
 		return
 	}
 
+	settings, err := backend.DB.GetSettings(conf.Name)
+	if err != nil {
+		renderErr(w, r, err, x.log)
+		return
+	}
+
+	if settings.RequireRootTwoFactor {
+		mship := backend.Membership(conf)
+		ok, err := mship.CheckTwoFactor(tok.ID, r.Form.Get("code"))
+		if err != nil {
+			renderErr(w, r, err, x.log)
+			return
+		} else if !ok {
+			render(w, r, "login.html", nil, &Flash{Type: "danger", Message: "a valid two-factor authentication code is required"}, x.log)
+			return
+		}
+	}
+
 	ckToken := &http.Cookie{
 		Name:     "token",
 		Value:    token,
@@ -145,8 +166,43 @@ func (x ui) enableExternalLogin(w http.ResponseWriter, r *http.Request) {
 		keys = model.OAuthConfig{}
 	}
 
+	// generic OpenID Connect providers are named by the tenant
+	if provider == "oidc" {
+		provider = strings.ToLower(strings.TrimSpace(r.Form.Get("name")))
+
+		keys = model.OAuthConfig{
+			Issuer: strings.TrimSpace(r.Form.Get("issuer")),
+			Scopes: strings.Join(strings.Fields(strings.ReplaceAll(r.Form.Get("scopes"), ",", " ")), " "),
+			Claims: model.ClaimMapping{
+				ID:        strings.TrimSpace(r.Form.Get("claimid")),
+				Email:     strings.TrimSpace(r.Form.Get("claimemail")),
+				Name:      strings.TrimSpace(r.Form.Get("claimname")),
+				FirstName: strings.TrimSpace(r.Form.Get("claimfirst")),
+				LastName:  strings.TrimSpace(r.Form.Get("claimlast")),
+				AvatarURL: strings.TrimSpace(r.Form.Get("claimavatar")),
+			},
+		}
+	}
+
 	keys.ConsumerKey = apikey
 	keys.ConsumerSecret = secret
+	keys.TrustEmail = r.Form.Get("trustemail") == "yes"
+
+	if err := validateProvider(provider, keys); err != nil {
+		flash := &Flash{Type: "danger", Message: err.Error()}
+		render(w, r, "logins.html", logins, flash, x.log)
+		return
+	}
+
+	// the issuer's configuration is discovered now so a misconfigured
+	// provider is reported before users try to sign in with it
+	if keys.IsOIDC() {
+		if _, err := newOIDCProvider(provider, "", keys); err != nil {
+			flash := &Flash{Type: "danger", Message: err.Error()}
+			render(w, r, "logins.html", logins, flash, x.log)
+			return
+		}
+	}
 
 	logins[provider] = keys
 
@@ -207,7 +263,7 @@ func (x *ui) dbCols(w http.ResponseWriter, r *http.Request) {
 		SortBy:         "id",
 	}
 
-	filter := make(map[string]interface{})
+	var filter database.Filter
 
 	// handle post
 	if r.Method == http.MethodPost {
@@ -372,6 +428,202 @@ func (x ui) dbDel(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/ui/db", http.StatusSeeOther)
 }
 
+func (x ui) dbSchema(w http.ResponseWriter, r *http.Request) {
+	conf, _, err := middleware.Extract(r, false)
+	if err != nil {
+		renderErr(w, r, err, x.log)
+		return
+	}
+
+	data := new(struct {
+		Collection string
+		Definition string
+		Schemas    []model.CollectionSchema
+	})
+
+	var flash *Flash
+
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			renderErr(w, r, err, x.log)
+			return
+		}
+
+		data.Collection = r.Form.Get("col")
+		data.Definition = r.Form.Get("definition")
+
+		if err := x.saveSchema(conf.Name, data.Collection, data.Definition); err != nil {
+			flash = &Flash{Type: "danger", Message: err.Error()}
+		} else {
+			flash = &Flash{Type: "success", Message: "Schema saved, it applies to the next writes"}
+		}
+	} else {
+		data.Collection = r.URL.Query().Get("col")
+	}
+
+	schemas, err := backend.DB.ListSchemas(conf.Name)
+	if err != nil {
+		renderErr(w, r, err, x.log)
+		return
+	}
+
+	data.Schemas = schemas
+
+	if len(data.Definition) == 0 {
+		for _, s := range schemas {
+			if s.Collection != data.Collection {
+				continue
+			}
+
+			b, err := json.MarshalIndent(s.Schema, "", "  ")
+			if err != nil {
+				renderErr(w, r, err, x.log)
+				return
+			}
+
+			data.Definition = string(b)
+		}
+	}
+
+	render(w, r, "db_schema.html", data, flash, x.log)
+}
+
+func (x ui) saveSchema(dbName, col, definition string) error {
+	if len(col) == 0 {
+		return errors.New("the collection is required")
+	}
+
+	var schema model.Schema
+	if err := json.Unmarshal([]byte(definition), &schema); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	} else if err := database.ValidateSchema(schema); err != nil {
+		return err
+	}
+
+	return backend.DB.SaveSchema(dbName, model.CollectionSchema{Collection: col, Schema: schema})
+}
+
+func (x ui) dbSchemaDel(w http.ResponseWriter, r *http.Request) {
+	conf, _, err := middleware.Extract(r, false)
+	if err != nil {
+		renderErr(w, r, err, x.log)
+		return
+	}
+
+	if err := backend.DB.DeleteSchema(conf.Name, r.URL.Query().Get("col")); err != nil {
+		renderErr(w, r, err, x.log)
+		return
+	}
+
+	http.Redirect(w, r, "/ui/db/schema", http.StatusSeeOther)
+}
+
+func (x ui) dbRules(w http.ResponseWriter, r *http.Request) {
+	conf, _, err := middleware.Extract(r, false)
+	if err != nil {
+		renderErr(w, r, err, x.log)
+		return
+	}
+
+	data := new(struct {
+		Collection string
+		Read       string
+		Write      string
+		Rules      []model.CollectionRules
+	})
+
+	var flash *Flash
+
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			renderErr(w, r, err, x.log)
+			return
+		}
+
+		data.Collection = r.Form.Get("col")
+		data.Read = r.Form.Get("read")
+		data.Write = r.Form.Get("write")
+
+		if err := x.saveRules(conf.Name, data.Collection, data.Read, data.Write); err != nil {
+			flash = &Flash{Type: "danger", Message: err.Error()}
+		} else {
+			flash = &Flash{Type: "success", Message: "Rules saved, they replace the permissions from the collection name"}
+		}
+	} else {
+		data.Collection = r.URL.Query().Get("col")
+	}
+
+	rules, err := backend.DB.ListRules(conf.Name)
+	if err != nil {
+		renderErr(w, r, err, x.log)
+		return
+	}
+
+	data.Rules = rules
+
+	if r.Method != http.MethodPost {
+		for _, cr := range rules {
+			if cr.Collection != data.Collection {
+				continue
+			}
+
+			if data.Read, err = indentRule(cr.Rules.Read); err != nil {
+				renderErr(w, r, err, x.log)
+				return
+			} else if data.Write, err = indentRule(cr.Rules.Write); err != nil {
+				renderErr(w, r, err, x.log)
+				return
+			}
+		}
+	}
+
+	render(w, r, "db_rules.html", data, flash, x.log)
+}
+
+func indentRule(rule [][]any) (string, error) {
+	if len(rule) == 0 {
+		return "", nil
+	}
+
+	b, err := json.MarshalIndent(rule, "", "  ")
+	return string(b), err
+}
+
+func (x ui) saveRules(dbName, col, read, write string) error {
+	if len(col) == 0 {
+		return errors.New("the collection is required")
+	}
+
+	var rules model.Rules
+	if len(strings.TrimSpace(read)) > 0 {
+		if err := json.Unmarshal([]byte(read), &rules.Read); err != nil {
+			return fmt.Errorf("invalid read rule JSON: %w", err)
+		}
+	}
+	if len(strings.TrimSpace(write)) > 0 {
+		if err := json.Unmarshal([]byte(write), &rules.Write); err != nil {
+			return fmt.Errorf("invalid write rule JSON: %w", err)
+		}
+	}
+
+	return backend.SaveCollectionRules(dbName, model.CollectionRules{Collection: col, Rules: rules})
+}
+
+func (x ui) dbRulesDel(w http.ResponseWriter, r *http.Request) {
+	conf, _, err := middleware.Extract(r, false)
+	if err != nil {
+		renderErr(w, r, err, x.log)
+		return
+	}
+
+	if err := backend.DeleteCollectionRules(conf.Name, r.URL.Query().Get("col")); err != nil {
+		renderErr(w, r, err, x.log)
+		return
+	}
+
+	http.Redirect(w, r, "/ui/db/rules", http.StatusSeeOther)
+}
+
 func (ui) readColumnNames(docs []map[string]interface{}) []string {
 	if len(docs) == 0 {
 		return nil
@@ -597,15 +849,255 @@ func (x ui) users(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id := getURLPart(r.URL.Path, 3)
+	data := new(struct {
+		AccountID string
+		Users     []model.User
+		Roles     []model.Role
+	})
+
+	data.AccountID = getURLPart(r.URL.Path, 3)
 
-	users, err := backend.DB.ListUsers(conf.Name, id)
+	var flash *Flash
+
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			renderErr(w, r, err, x.log)
+			return
+		}
+
+		email := r.Form.Get("email")
+		role, err := strconv.Atoi(r.Form.Get("role"))
+		if err != nil {
+			renderErr(w, r, err, x.log)
+			return
+		}
+
+		mship := backend.Membership(conf)
+		if err := mship.SetUserRole(email, role); err != nil {
+			flash = &Flash{Type: "danger", Message: err.Error()}
+		} else {
+			flash = &Flash{Type: "success", Message: fmt.Sprintf("The role of %s was changed", email)}
+		}
+	}
+
+	users, err := backend.DB.ListUsers(conf.Name, data.AccountID)
+	if err != nil {
+		renderErr(w, r, err, x.log)
+		return
+	}
+
+	roles, err := backend.DB.ListRoles(conf.Name)
+	if err != nil {
+		renderErr(w, r, err, x.log)
+		return
+	}
+
+	data.Users = users
+	data.Roles = roles
+
+	render(w, r, "users_list.html", data, flash, x.log)
+}
+
+func (x ui) roles(w http.ResponseWriter, r *http.Request) {
+	conf, _, err := middleware.Extract(r, false)
+	if err != nil {
+		renderErr(w, r, err, x.log)
+		return
+	}
+
+	data := new(struct {
+		Role  model.Role
+		Roles []model.Role
+	})
+
+	var flash *Flash
+
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			renderErr(w, r, err, x.log)
+			return
+		}
+
+		data.Role.Name = strings.TrimSpace(r.Form.Get("name"))
+		data.Role.Level, _ = strconv.Atoi(r.Form.Get("level"))
+		for _, p := range strings.Split(r.Form.Get("permissions"), ",") {
+			if p = strings.TrimSpace(p); len(p) > 0 {
+				data.Role.Permissions = append(data.Role.Permissions, p)
+			}
+		}
+
+		if err := saveRole(conf.Name, data.Role); err != nil {
+			flash = &Flash{Type: "danger", Message: err.Error()}
+		} else {
+			flash = &Flash{Type: "success", Message: "Role saved"}
+		}
+	}
+
+	roles, err := backend.DB.ListRoles(conf.Name)
+	if err != nil {
+		renderErr(w, r, err, x.log)
+		return
+	}
+
+	data.Roles = roles
+
+	if name := r.URL.Query().Get("name"); r.Method != http.MethodPost && len(name) > 0 {
+		for _, role := range roles {
+			if role.Name == name {
+				data.Role = role
+			}
+		}
+	}
+
+	render(w, r, "roles.html", data, flash, x.log)
+}
+
+func (x ui) rolesDel(w http.ResponseWriter, r *http.Request) {
+	conf, _, err := middleware.Extract(r, false)
+	if err != nil {
+		renderErr(w, r, err, x.log)
+		return
+	}
+
+	if err := backend.DB.DeleteRole(conf.Name, r.URL.Query().Get("name")); err != nil {
+		renderErr(w, r, err, x.log)
+		return
+	}
+
+	http.Redirect(w, r, "/ui/roles", http.StatusSeeOther)
+}
+
+func (x ui) apiKeys(w http.ResponseWriter, r *http.Request) {
+	conf, auth, err := middleware.Extract(r, true)
+	if err != nil {
+		renderErr(w, r, err, x.log)
+		return
+	}
+
+	var flash *Flash
+
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			renderErr(w, r, err, x.log)
+			return
+		}
+
+		var scopes []string
+		for _, s := range strings.Split(r.Form.Get("scopes"), ",") {
+			if s = strings.TrimSpace(s); len(s) > 0 {
+				scopes = append(scopes, s)
+			}
+		}
+
+		var expires *time.Time
+		if v := r.Form.Get("expires"); len(v) > 0 {
+			t, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				renderErr(w, r, err, x.log)
+				return
+			}
+			expires = &t
+		}
+
+		mship := backend.Membership(conf)
+		key, _, err := mship.CreateAPIKey(auth, r.Form.Get("name"), scopes, expires)
+		if err != nil {
+			flash = &Flash{Type: "danger", Message: err.Error()}
+		} else {
+			flash = &Flash{Type: "success", Message: fmt.Sprintf("Copy your API key, it will not be shown again: %s", key)}
+		}
+	}
+
+	keys, err := backend.DB.ListAPIKeys(conf.Name)
+	if err != nil {
+		renderErr(w, r, err, x.log)
+		return
+	}
+
+	render(w, r, "apikeys.html", keys, flash, x.log)
+}
+
+func (x ui) apiKeysRevoke(w http.ResponseWriter, r *http.Request) {
+	conf, _, err := middleware.Extract(r, false)
+	if err != nil {
+		renderErr(w, r, err, x.log)
+		return
+	}
+
+	if err := backend.DB.RevokeAPIKey(conf.Name, r.URL.Query().Get("id")); err != nil {
+		renderErr(w, r, err, x.log)
+		return
+	}
+
+	http.Redirect(w, r, "/ui/apikeys", http.StatusSeeOther)
+}
+
+func (x ui) twoFactor(w http.ResponseWriter, r *http.Request) {
+	conf, auth, err := middleware.Extract(r, true)
+	if err != nil {
+		renderErr(w, r, err, x.log)
+		return
+	}
+
+	data := new(struct {
+		Email         string
+		Enabled       bool
+		Setup         *model.TwoFactorSetup
+		RecoveryCodes []string
+		RequireRoot   bool
+	})
+	data.Email = auth.Email
+
+	var flash *Flash
+
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			renderErr(w, r, err, x.log)
+			return
+		}
+
+		mship := backend.Membership(conf)
+		code := r.Form.Get("code")
+
+		switch r.Form.Get("action") {
+		case "setup":
+			var setup model.TwoFactorSetup
+			if setup, err = mship.SetupTwoFactor(auth); err == nil {
+				data.Setup = &setup
+			}
+		case "enable":
+			data.RecoveryCodes, err = mship.EnableTwoFactor(auth, code)
+		case "disable":
+			err = mship.DisableTwoFactor(auth, code)
+		case "codes":
+			data.RecoveryCodes, err = mship.NewRecoveryCodes(auth, code)
+		case "require":
+			err = mship.RequireRootTwoFactor(auth, r.Form.Get("require") == "on")
+		}
+
+		if err != nil {
+			flash = &Flash{Type: "danger", Message: err.Error()}
+		} else if len(data.RecoveryCodes) > 0 {
+			flash = &Flash{Type: "success", Message: "Save your recovery codes, they will not be shown again."}
+		}
+	}
+
+	tf, err := backend.DB.GetTwoFactor(conf.Name, auth.UserID)
 	if err != nil {
 		renderErr(w, r, err, x.log)
 		return
 	}
 
-	render(w, r, "users_list.html", users, nil, nil)
+	settings, err := backend.DB.GetSettings(conf.Name)
+	if err != nil {
+		renderErr(w, r, err, x.log)
+		return
+	}
+
+	data.Enabled = tf.Enabled
+	data.RequireRoot = settings.RequireRootTwoFactor
+
+	render(w, r, "twofactor.html", data, flash, x.log)
 }
 
 func (x ui) tasks(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,200 @@
+package staticbackend
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/staticbackendhq/core/backend"
+	"github.com/staticbackendhq/core/email"
+	"github.com/staticbackendhq/core/middleware"
+	"github.com/staticbackendhq/core/model"
+)
+
+type capturedMailer struct {
+	mx    sync.Mutex
+	mails []email.SendMailData
+}
+
+func (m *capturedMailer) Send(data email.SendMailData) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	m.mails = append(m.mails, data)
+	return nil
+}
+
+func (m *capturedMailer) last(t *testing.T) email.SendMailData {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	if len(m.mails) == 0 {
+		t.Fatal("expected an email to be sent")
+	}
+	return m.mails[len(m.mails)-1]
+}
+
+var linkToken = regexp.MustCompile(`token=([^'"&]+)`)
+
+// enableEmailVerification saves the settings and captures the emails sent,
+// the returned func restores them
+func enableEmailVerification(t *testing.T, ev model.EmailVerification) (*capturedMailer, func()) {
+	settings, err := backend.DB.GetSettings(dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := dbReq(t, mship.emailVerificationSettings, "POST", "/settings/email-verification", ev, true)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+	resp.Body.Close()
+
+	mailer := &capturedMailer{}
+	emailer := backend.Emailer
+	backend.Emailer = mailer
+
+	return mailer, func() {
+		backend.Emailer = emailer
+		if err := backend.DB.SaveSettings(dbName, settings); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestEmailVerificationBlocksLogin(t *testing.T) {
+	mailer, restore := enableEmailVerification(t, model.EmailVerification{
+		Enabled:    true,
+		BlockLogin: true,
+		Link:       "https://app.test.com/verify",
+		Subject:    "Verify your email",
+		Body:       "<a href='[link]'>verify</a>",
+	})
+	defer restore()
+
+	login := model.Login{Email: "verify@test.com", Password: "sessions-pw"}
+
+	resp := pubReq(t, mship.register, "POST", "/register", login)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected status 403 got %d: %s", resp.StatusCode, GetResponseBody(t, resp))
+	}
+	resp.Body.Close()
+
+	mail := mailer.last(t)
+	if mail.To != login.Email || mail.Subject != "Verify your email" {
+		t.Fatalf("unexpected verification email %v", mail)
+	}
+
+	m := linkToken.FindStringSubmatch(mail.HTMLBody)
+	if m == nil {
+		t.Fatalf("expected a token in the link got %s", mail.HTMLBody)
+	}
+	token, err := url.QueryUnescape(m[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp = pubReq(t, mship.login, "POST", "/login", login)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected an unverified login to be denied with 403 got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	resp = pubReq(t, mship.verifyEmail, "GET", "/verify-email?token=invalid", nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected an invalid token to be rejected with 400 got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	resp = pubReq(t, mship.verifyEmail, "GET", "/verify-email?token="+url.QueryEscape(token), nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+	resp.Body.Close()
+
+	tokens := signIn(t, mship.login, "/login", login.Email)
+
+	resp = tokenReq(t, mship.me, "GET", "/me", tokens.Token, nil)
+	defer resp.Body.Close()
+
+	var auth model.Auth
+	if err := parseBody(resp.Body, &auth); err != nil {
+		t.Fatal(err)
+	} else if !auth.EmailVerified {
+		t.Error("expected the session to have a verified email")
+	}
+}
+
+func TestResendVerificationRateLimited(t *testing.T) {
+	mailer, restore := enableEmailVerification(t, model.EmailVerification{Enabled: true})
+	defer restore()
+
+	addr := fmt.Sprintf("resend%d@test.com", time.Now().UnixNano())
+
+	// unverified users can sign in when the login is not blocked
+	tokens := signIn(t, mship.register, "/register", addr)
+	if s := meStatus(t, tokens.Token); s != http.StatusOK {
+		t.Fatalf("expected status 200 got %d", s)
+	}
+
+	sent := len(mailer.mails)
+
+	data := map[string]string{"email": addr}
+
+	resp := pubReq(t, mship.resendVerification, "POST", "/verify-email/resend", data)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+	resp.Body.Close()
+
+	if len(mailer.mails) != sent+1 {
+		t.Fatalf("expected the verification email to be sent again got %d emails", len(mailer.mails)-sent)
+	}
+
+	resp = pubReq(t, mship.resendVerification, "POST", "/verify-email/resend", data)
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected status 429 got %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func TestEmailVerificationDeniesPermission(t *testing.T) {
+	role := model.Role{Name: "author", Level: 30, Permissions: []string{"posts:write", "posts:read"}}
+	if err := saveRole(dbName, role); err != nil {
+		t.Fatal(err)
+	}
+	defer backend.DB.DeleteRole(dbName, role.Name)
+
+	// the role of the user's session is updated
+	data := map[string]any{"email": userEmail, "name": role.Name}
+	resp := dbReq(t, mship.setRole, "POST", "/setrole", data)
+	if resp.StatusCode > 299 {
+		t.Fatal(GetResponseBody(t, resp))
+	}
+	resp.Body.Close()
+	defer backend.DB.SetUserRole(dbName, userEmail, 0)
+
+	_, restore := enableEmailVerification(t, model.EmailVerification{
+		Enabled:     true,
+		Permissions: []string{"posts:write"},
+	})
+	defer restore()
+
+	resp = userReq(t, mship.me, "GET", "/me", nil, middleware.RequirePermission(backend.DB, "posts:write"))
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected an unverified user to be denied with 403 got %s", resp.Status)
+	}
+
+	resp = userReq(t, mship.me, "GET", "/me", nil, middleware.RequirePermission(backend.DB, "posts:read"))
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		t.Error(GetResponseBody(t, resp))
+	}
+}